@@ -0,0 +1,82 @@
+// Package anonymize replaces the names, logins and email addresses of people
+// with stable pseudonyms before they are stored.
+//
+// A pseudonym is a keyed hash of the value, so the same person always gets
+// the same pseudonym and per-author statistics still work, while nobody
+// without the salt can recover the value or test a guess against it.
+package anonymize
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+)
+
+// prefix marks pseudonyms, so a value is never mistaken for a real name
+const prefix = "anon-"
+
+// pseudonymBytes is how much of the hash a pseudonym keeps; 64 bits keep
+// collisions out of reach for any realistic number of authors
+const pseudonymBytes = 8
+
+// emailPattern finds email addresses in free text such as commit trailers
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+// trailerPattern finds the names of the people credited by commit trailers
+// such as "Co-authored-by: Octo Cat <octo@example.com>"
+var trailerPattern = regexp.MustCompile(`(?m)^([A-Za-z-]+-by:[ \t]*)([^<\n]*[^<\s])([ \t]*<)`)
+
+// Anonymizer derives the pseudonyms of one salt. A nil Anonymizer leaves
+// every value as is, so callers need not check whether anonymization is on.
+type Anonymizer struct {
+	salt []byte
+}
+
+// New returns an Anonymizer for salt, which must stay the same for as long
+// as the data is kept: a new salt gives everyone new pseudonyms
+func New(salt string) *Anonymizer {
+	return &Anonymizer{salt: []byte(salt)}
+}
+
+// Name returns the pseudonym of a person's name, as given; the empty name
+// stays empty
+func (a *Anonymizer) Name(name string) string {
+	if a == nil || name == "" {
+		return name
+	}
+	return a.pseudonym("name", name)
+}
+
+// Login returns the pseudonym of a GitHub login, which is case insensitive
+func (a *Anonymizer) Login(login string) string {
+	if a == nil || login == "" {
+		return login
+	}
+	return a.pseudonym("login", strings.ToLower(login))
+}
+
+// Text replaces every email address in text, and the names of the people
+// trailers such as Signed-off-by and Co-authored-by credit, with their
+// pseudonyms
+func (a *Anonymizer) Text(text string) string {
+	if a == nil || !strings.Contains(text, "@") {
+		return text
+	}
+	text = trailerPattern.ReplaceAllStringFunc(text, func(trailer string) string {
+		m := trailerPattern.FindStringSubmatch(trailer)
+		return m[1] + a.Name(m[2]) + m[3]
+	})
+	return emailPattern.ReplaceAllStringFunc(text, func(email string) string {
+		return a.pseudonym("email", strings.ToLower(email))
+	})
+}
+
+// pseudonym hashes value under kind, so a name and a login spelled the same
+// do not share a pseudonym
+func (a *Anonymizer) pseudonym(kind, value string) string {
+	mac := hmac.New(sha256.New, a.salt)
+	mac.Write([]byte(kind + "\x00" + value))
+	return prefix + hex.EncodeToString(mac.Sum(nil)[:pseudonymBytes])
+}
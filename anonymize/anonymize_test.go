@@ -0,0 +1,37 @@
+package anonymize
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPseudonyms(t *testing.T) {
+	a := New("salt")
+
+	name := a.Name("Octo Cat")
+	assert.True(t, strings.HasPrefix(name, "anon-"))
+	assert.Len(t, name, len("anon-")+16)
+	assert.Equal(t, name, New("salt").Name("Octo Cat"), "pseudonyms are stable")
+	assert.NotEqual(t, name, New("pepper").Name("Octo Cat"), "pseudonyms depend on the salt")
+	assert.NotEqual(t, name, a.Name("Octo Dog"))
+	assert.Equal(t, "", a.Name(""))
+
+	assert.Equal(t, a.Login("octocat"), a.Login("OctoCat"), "logins are case insensitive")
+	assert.NotEqual(t, a.Name("octocat"), a.Login("octocat"))
+
+	message := "Fix parser\n\nCo-authored-by: Octo Cat <Octo@example.com>\nSigned-off-by: Octo Cat <octo@example.com>"
+	scrubbed := a.Text(message)
+	assert.NotContains(t, scrubbed, "example.com")
+	assert.NotContains(t, scrubbed, "Octo Cat")
+	assert.Equal(t, 2, strings.Count(scrubbed, a.Text("octo@example.com")))
+	assert.Equal(t, 2, strings.Count(scrubbed, a.Name("Octo Cat")), "trailers credit the author's pseudonym")
+	assert.True(t, strings.HasPrefix(scrubbed, "Fix parser\n\nCo-authored-by: "+a.Name("Octo Cat")+" <anon-"))
+	assert.Equal(t, "Fix #12", a.Text("Fix #12"))
+
+	var off *Anonymizer
+	assert.Equal(t, "Octo Cat", off.Name("Octo Cat"))
+	assert.Equal(t, "OctoCat", off.Login("OctoCat"))
+	assert.Equal(t, message, off.Text(message))
+}
@@ -0,0 +1,925 @@
+// Package api exposes the stored GitHub data over an HTTP query API, along
+// with an endpoint to trigger ad-hoc syncs.
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"githubapifetch/audit"
+	"githubapifetch/db"
+	"githubapifetch/features"
+	"githubapifetch/logger"
+	"githubapifetch/metrics"
+	"githubapifetch/models"
+	"githubapifetch/progress"
+	"githubapifetch/scheduler"
+	"githubapifetch/synclag"
+	"githubapifetch/tenant"
+)
+
+// Store abstracts the database queries served by the API
+// (for testability)
+type Store interface {
+	GetByName(ctx context.Context, name string) (*models.Repository, error)
+	GetRepositoryStats(ctx context.Context, repoName, pathFilter string, filter models.StatsFilter) (*models.RepositoryStats, error)
+	RollupStats(ctx context.Context, owner, group string, filter models.StatsFilter, top int) (*models.RollupStats, error)
+	ListCommits(ctx context.Context, repoName, pathFilter string, params models.PaginationParams) ([]models.Commit, error)
+	ListPathFilters(ctx context.Context, repoName string) ([]models.PathFilter, error)
+	GetTopAuthors(ctx context.Context, limit int) ([]models.AuthorStats, error)
+	GetTenantByAPIToken(ctx context.Context, token string) (*models.Tenant, error)
+	ListAuditEntries(ctx context.Context, action string, limit int) ([]models.AuditEntry, error)
+	CommitHeatmap(ctx context.Context, repoName, author string, since, until time.Time, timezone string) (*models.Heatmap, error)
+	IssueStats(ctx context.Context, repoName, label string, since, until time.Time, cal *models.WorkCalendar) (*models.IssueStats, error)
+	PullRequestStats(ctx context.Context, repoName string, authors []string, since, until time.Time, cal *models.WorkCalendar) (*models.PullRequestStats, error)
+	ReleaseCadence(ctx context.Context, since, until time.Time, prereleases bool) ([]models.ReleaseCadence, error)
+	DeploymentCommits(ctx context.Context, repoName string, githubID int64) (*models.DeploymentCommits, error)
+	CommitDeployment(ctx context.Context, repoName, sha, environment string) (*models.CommitDeployment, error)
+	GetCommitBySHA(ctx context.Context, repoName, sha string) (*models.CommitDetail, error)
+	ActivityFeed(ctx context.Context, cursor models.ActivityCursor, limit int) (*models.ActivityPage, error)
+	ListRateLimitSamples(ctx context.Context, since, until time.Time) ([]models.RateLimitSample, error)
+	ListRepositories(ctx context.Context) ([]models.Repository, error)
+	SearchRepositories(ctx context.Context, filter models.RepositoryFilter, params models.PaginationParams) ([]models.RepositoryListing, error)
+	ListOwners(ctx context.Context) ([]models.OwnerListing, error)
+	GetOwner(ctx context.Context, login string) (*models.Owner, error)
+}
+
+// ProgressSource reports the commit fetches of a tenant
+type ProgressSource interface {
+	Snapshot(tenantID int) []progress.Fetch
+}
+
+// SyncLagSource reports how far a tenant's repositories are behind GitHub
+type SyncLagSource interface {
+	Snapshot(tenantID int) []synclag.Repo
+}
+
+// FailureSource reports the repositories whose latest monitoring check failed
+type FailureSource interface {
+	Snapshot(tenantID int) []scheduler.Failure
+}
+
+// FeatureSource reports which optional features are enabled
+type FeatureSource interface {
+	Snapshot() []features.Status
+}
+
+// RepoSyncer runs one-off repository syncs for POST /repos/{owner}/{name}/sync
+type RepoSyncer interface {
+	SyncRepo(ctx context.Context, owner, name string, since time.Time) error
+}
+
+// SyncResetter resets the sync point of a repository for
+// POST /repos/{name}/reset-sync
+type SyncResetter interface {
+	ResetSyncPoint(ctx context.Context, repoName string, since time.Time) (*models.ResetSyncResult, error)
+}
+
+// Options configures the API server
+type Options struct {
+	Addr            string
+	RateLimit       float64
+	RateBurst       int
+	MaxPageSize     int
+	MaxResultWindow int
+
+	// APIToken grants access to the default tenant, as the tenants' own API
+	// tokens do to theirs
+	APIToken string
+	// AllowAnonymous serves read-only requests without a token from the
+	// default tenant. Requests that change data or read the audit log
+	// always need a token.
+	AllowAnonymous bool
+
+	// Calendar is the calendar statistics asked for in working time count
+	// by; without it they count Monday to Friday, in UTC
+	Calendar *models.WorkCalendar
+
+	// Progress serves GET /status; without it no fetches are reported
+	Progress ProgressSource
+	// SyncLag adds the repositories' sync lag to GET /status
+	SyncLag SyncLagSource
+	// Failures adds the repositories failing to sync to GET /status
+	Failures FailureSource
+	// Features adds the enabled optional features to GET /healthz
+	Features FeatureSource
+
+	// Syncer serves POST /repos/{owner}/{name}/sync; without it the route
+	// is not registered
+	Syncer RepoSyncer
+	// Resetter serves POST /repos/{name}/reset-sync; without it the route is
+	// not registered
+	Resetter SyncResetter
+}
+
+// Server serves the query API
+type Server struct {
+	store   Store
+	opts    Options
+	limiter *RateLimiter
+	http    *http.Server
+
+	// ctx bounds background syncs and is cancelled on shutdown
+	ctx    context.Context
+	cancel context.CancelFunc
+	// syncing holds the "tenant/owner/name" keys of running syncs
+	syncing sync.Map
+}
+
+// PageResponse wraps a page of list results
+type PageResponse struct {
+	Data     interface{} `json:"data"`
+	Page     int         `json:"page"`
+	PageSize int         `json:"page_size"`
+}
+
+// NewServer creates a new API server
+func NewServer(store Store, opts Options) *Server {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Server{
+		store:   store,
+		opts:    opts,
+		limiter: NewRateLimiter(opts.RateLimit, opts.RateBurst),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	s.http = &http.Server{
+		Addr:              opts.Addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s
+}
+
+// Handler returns the HTTP handler with all routes and middleware applied
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos", s.handleListRepositories)
+	mux.HandleFunc("GET /repos/{name}", s.handleGetRepository)
+	mux.HandleFunc("GET /repos/{name}/commits", s.handleListCommits)
+	mux.HandleFunc("GET /repos/{name}/commits/{sha}", s.handleGetCommit)
+	mux.HandleFunc("GET /commits/{sha}", s.handleGetCommit)
+	mux.HandleFunc("GET /owners", s.handleListOwners)
+	mux.HandleFunc("GET /owners/{login}", s.handleGetOwner)
+	mux.HandleFunc("GET /repos/{name}/stats", s.handleRepositoryStats)
+	mux.HandleFunc("GET /repos/{name}/stats/issues", s.handleIssueStats)
+	mux.HandleFunc("GET /repos/{name}/stats/pulls", s.handlePullRequestStats)
+	mux.HandleFunc("GET /stats/pulls", s.handlePullRequestStats)
+	mux.HandleFunc("GET /stats/releases", s.handleReleaseCadence)
+	mux.HandleFunc("GET /stats/rollup", s.handleRollupStats)
+	mux.HandleFunc("GET /repos/{name}/deployments/{id}/commits", s.handleDeploymentCommits)
+	mux.HandleFunc("GET /repos/{name}/commits/{sha}/deployment", s.handleCommitDeployment)
+	mux.HandleFunc("GET /repos/{name}/path-filters", s.handleListPathFilters)
+	mux.HandleFunc("GET /repos/{name}/heatmap", s.handleHeatmap)
+	mux.HandleFunc("GET /heatmap", s.handleHeatmap)
+	mux.HandleFunc("GET /authors/top", s.handleTopAuthors)
+	mux.HandleFunc("GET /activity", s.handleActivityFeed)
+	mux.HandleFunc("GET /audit", s.authenticated(s.handleAuditLog))
+	mux.HandleFunc("GET /rate-limits", s.handleRateLimits)
+	mux.HandleFunc("GET /status", s.handleStatus)
+	mux.HandleFunc("GET /healthz", s.handleHealth)
+	mux.Handle("GET /metrics", metrics.Handler())
+	if s.opts.Syncer != nil {
+		mux.HandleFunc("POST /repos/{owner}/{name}/sync", s.authenticated(s.handleSync))
+	}
+	if s.opts.Resetter != nil {
+		mux.HandleFunc("POST /repos/{name}/reset-sync", s.authenticated(s.handleResetSync))
+	}
+	return s.limiter.Middleware(s.tenantMiddleware(mux))
+}
+
+// anonymous is the actor of requests served without a token
+var anonymous = audit.Actor{Name: "anonymous", Type: models.ActorTypeAPI}
+
+// tenantMiddleware scopes each request to the tenant its bearer token belongs
+// to: the default tenant for the configured API token, or the tenant holding
+// the token. Requests without a token are rejected, unless anonymous access
+// is allowed and they only read, in which case they are served from the
+// default tenant. The token also identifies the actor of actions triggered
+// through the API. Health checks need no token.
+func (s *Server) tenantMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if auth == "" {
+			if r.Method == http.MethodGet && r.URL.Path == "/healthz" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !s.opts.AllowAnonymous || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+				writeUnauthorized(w, "missing API token")
+				return
+			}
+			ctx := tenant.WithID(r.Context(), tenant.DefaultID)
+			ctx = audit.WithActor(ctx, anonymous)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		token, ok := strings.CutPrefix(auth, "Bearer ")
+		if !ok || token == "" {
+			writeUnauthorized(w, "invalid authorization header")
+			return
+		}
+		if s.opts.APIToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.opts.APIToken)) == 1 {
+			ctx := tenant.WithID(r.Context(), tenant.DefaultID)
+			ctx = audit.WithActor(ctx, audit.Actor{Name: "token:default", Type: models.ActorTypeAPI})
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		t, err := s.store.GetTenantByAPIToken(r.Context(), token)
+		if err != nil {
+			if errors.Is(err, db.ErrTenantNotFound) {
+				writeUnauthorized(w, "invalid API token")
+				return
+			}
+			writeStoreError(w, err)
+			return
+		}
+		ctx := tenant.WithID(r.Context(), t.ID)
+		ctx = audit.WithActor(ctx, audit.Actor{Name: "token:" + t.Name, Type: models.ActorTypeAPI})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// authenticated rejects requests served anonymously, for routes that change
+// data or expose more than stored GitHub data
+func (s *Server) authenticated(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if audit.ActorFromContext(r.Context()) == anonymous {
+			writeUnauthorized(w, "missing API token")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// Start serves requests until the server is shut down
+func (s *Server) Start() error {
+	logger.Info("Starting query API", zap.String("addr", s.opts.Addr))
+	if err := s.http.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("query API failed: %w", err)
+	}
+	return nil
+}
+
+// Shutdown gracefully stops the server
+func (s *Server) Shutdown(ctx context.Context) error {
+	logger.Info("Stopping query API")
+	s.cancel()
+	return s.http.Shutdown(ctx)
+}
+
+func (s *Server) handleGetRepository(w http.ResponseWriter, r *http.Request) {
+	repo, err := s.store.GetByName(r.Context(), r.PathValue("name"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, repo)
+}
+
+func (s *Server) handleListOwners(w http.ResponseWriter, r *http.Request) {
+	owners, err := s.store.ListOwners(r.Context())
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, owners)
+}
+
+func (s *Server) handleGetOwner(w http.ResponseWriter, r *http.Request) {
+	owner, err := s.store.GetOwner(r.Context(), r.PathValue("login"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, owner)
+}
+
+func (s *Server) handleListCommits(w http.ResponseWriter, r *http.Request) {
+	params, err := s.paginationParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	commits, err := s.store.ListCommits(r.Context(), r.PathValue("name"), r.URL.Query().Get("path_filter"), params)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, PageResponse{Data: commits, Page: params.Page, PageSize: params.PageSize})
+}
+
+// handleListRepositories serves a page of the tenant's repositories, filtered
+// by owner, language, status, group and min_stars and sorted by sort
+func (s *Server) handleListRepositories(w http.ResponseWriter, r *http.Request) {
+	params, err := s.paginationParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	minStars, err := intParam(r, "min_stars", 0)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query := r.URL.Query()
+	filter := models.RepositoryFilter{
+		Owner:    query.Get("owner"),
+		Language: query.Get("language"),
+		Status:   query.Get("status"),
+		Group:    query.Get("group"),
+		MinStars: minStars,
+		Sort:     query.Get("sort"),
+	}
+	repos, err := s.store.SearchRepositories(r.Context(), filter, params)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, PageResponse{Data: repos, Page: params.Page, PageSize: params.PageSize})
+}
+
+// handleGetCommit serves a commit by its full or abbreviated SHA, in one
+// repository or, without a repository name, in any of the tenant's
+func (s *Server) handleGetCommit(w http.ResponseWriter, r *http.Request) {
+	commit, err := s.store.GetCommitBySHA(r.Context(), r.PathValue("name"), r.PathValue("sha"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, commit)
+}
+
+// handleRepositoryStats serves the commit statistics of a repository, of
+// all time or from since up to until, optionally for one author
+func (s *Server) handleRepositoryStats(w http.ResponseWriter, r *http.Request) {
+	since, err := timeParam(r, "since", time.Time{})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	until, err := timeParam(r, "until", time.Time{})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query := r.URL.Query()
+	filter := models.StatsFilter{Since: since, Until: until, Author: query.Get("author")}
+	stats, err := s.store.GetRepositoryStats(r.Context(), r.PathValue("name"), query.Get("path_filter"), filter)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// handleRollupStats serves the commit statistics of the repositories of an
+// owner or group, or of the whole tenant, with the limit busiest ones
+func (s *Server) handleRollupStats(w http.ResponseWriter, r *http.Request) {
+	since, err := timeParam(r, "since", time.Time{})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	until, err := timeParam(r, "until", time.Time{})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit, err := s.limitParam(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query := r.URL.Query()
+	filter := models.StatsFilter{Since: since, Until: until, Author: query.Get("author")}
+	stats, err := s.store.RollupStats(r.Context(), query.Get("owner"), query.Get("group"), filter, limit)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// defaultIssueStatsPeriod is the period issue and pull request statistics
+// cover without since
+const defaultIssueStatsPeriod = 90 * 24 * time.Hour
+
+// handleIssueStats serves the issue age, time to close and throughput of a
+// repository, optionally for one label, in working time with working_time
+func (s *Server) handleIssueStats(w http.ResponseWriter, r *http.Request) {
+	until, err := timeParam(r, "until", time.Now().UTC())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	since, err := timeParam(r, "since", until.Add(-defaultIssueStatsPeriod))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	cal, err := s.calendarParam(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	stats, err := s.store.IssueStats(r.Context(), r.PathValue("name"), r.URL.Query().Get("label"), since, until, cal)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// handlePullRequestStats serves the time to first review, time to merge and
+// sizes of the pull requests opened in a period, of one repository or of
+// every repository of the tenant, optionally limited to a team given as a
+// comma-separated list of authors, in working time with working_time
+func (s *Server) handlePullRequestStats(w http.ResponseWriter, r *http.Request) {
+	until, err := timeParam(r, "until", time.Now().UTC())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	since, err := timeParam(r, "since", until.Add(-defaultIssueStatsPeriod))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	cal, err := s.calendarParam(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var authors []string
+	for _, author := range strings.Split(r.URL.Query().Get("authors"), ",") {
+		if author = strings.TrimSpace(author); author != "" {
+			authors = append(authors, author)
+		}
+	}
+
+	stats, err := s.store.PullRequestStats(r.Context(), r.PathValue("name"), authors, since, until, cal)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// defaultReleaseCadencePeriod is the period release cadence covers without
+// since
+const defaultReleaseCadencePeriod = 365 * 24 * time.Hour
+
+// handleReleaseCadence serves how often each repository of the tenant
+// published releases over a period
+func (s *Server) handleReleaseCadence(w http.ResponseWriter, r *http.Request) {
+	until, err := timeParam(r, "until", time.Now().UTC())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	since, err := timeParam(r, "since", until.Add(-defaultReleaseCadencePeriod))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	prereleases := false
+	if raw := r.URL.Query().Get("prereleases"); raw != "" {
+		if prereleases, err = strconv.ParseBool(raw); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid prereleases: %q", raw))
+			return
+		}
+	}
+
+	cadence, err := s.store.ReleaseCadence(r.Context(), since, until, prereleases)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, cadence)
+}
+
+// handleDeploymentCommits serves the commits a deployment shipped
+func (s *Server) handleDeploymentCommits(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid deployment id: %q", r.PathValue("id")))
+		return
+	}
+	commits, err := s.store.DeploymentCommits(r.Context(), r.PathValue("name"), id)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, commits)
+}
+
+// defaultDeploymentEnvironment is the environment commits are traced to
+// without environment
+const defaultDeploymentEnvironment = "production"
+
+// handleCommitDeployment serves when a commit reached an environment
+func (s *Server) handleCommitDeployment(w http.ResponseWriter, r *http.Request) {
+	environment := r.URL.Query().Get("environment")
+	if environment == "" {
+		environment = defaultDeploymentEnvironment
+	}
+	deployment, err := s.store.CommitDeployment(r.Context(), r.PathValue("name"), r.PathValue("sha"), environment)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, deployment)
+}
+
+// defaultRateLimitPeriod is the period rate limit history covers without
+// since
+const defaultRateLimitPeriod = 24 * time.Hour
+
+// handleRateLimits serves the rate limits GitHub reported to the tenant's
+// syncs over a period
+func (s *Server) handleRateLimits(w http.ResponseWriter, r *http.Request) {
+	until, err := timeParam(r, "until", time.Now().UTC())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	since, err := timeParam(r, "since", until.Add(-defaultRateLimitPeriod))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	samples, err := s.store.ListRateLimitSamples(r.Context(), since, until)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, samples)
+}
+
+func (s *Server) handleListPathFilters(w http.ResponseWriter, r *http.Request) {
+	filters, err := s.store.ListPathFilters(r.Context(), r.PathValue("name"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, filters)
+}
+
+func (s *Server) handleTopAuthors(w http.ResponseWriter, r *http.Request) {
+	limit, err := s.limitParam(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	authors, err := s.store.GetTopAuthors(r.Context(), limit)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, authors)
+}
+
+// defaultHeatmapPeriod is the period a heatmap covers without since
+const defaultHeatmapPeriod = 365 * 24 * time.Hour
+
+// handleHeatmap serves the punch card of a repository, or of every
+// repository on /heatmap, optionally for one author
+func (s *Server) handleHeatmap(w http.ResponseWriter, r *http.Request) {
+	until, err := timeParam(r, "until", time.Now().UTC())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	since, err := timeParam(r, "since", until.Add(-defaultHeatmapPeriod))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query := r.URL.Query()
+	heatmap, err := s.store.CommitHeatmap(r.Context(), r.PathValue("name"), query.Get("author"), since, until, query.Get("tz"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, heatmap)
+}
+
+func (s *Server) handleAuditLog(w http.ResponseWriter, r *http.Request) {
+	limit, err := s.limitParam(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entries, err := s.store.ListAuditEntries(r.Context(), r.URL.Query().Get("action"), limit)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+// handleActivityFeed serves the latest events of the tenant's repositories,
+// newest first, from the cursor of the previous page if any
+func (s *Server) handleActivityFeed(w http.ResponseWriter, r *http.Request) {
+	limit, err := s.limitParam(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	cursor, err := models.ParseActivityCursor(r.URL.Query().Get("cursor"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := s.store.ActivityFeed(r.Context(), cursor, limit)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// handleStatus reports the progress of the tenant's running and most recent
+// commit fetches, how far its repositories are behind GitHub, which of them
+// fail to sync and when each was last synced
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	tenantID := tenant.FromContext(r.Context())
+	fetches := []progress.Fetch{}
+	if s.opts.Progress != nil {
+		fetches = s.opts.Progress.Snapshot(tenantID)
+	}
+	syncLag := []synclag.Repo{}
+	if s.opts.SyncLag != nil {
+		syncLag = s.opts.SyncLag.Snapshot(tenantID)
+	}
+	failures := []scheduler.Failure{}
+	if s.opts.Failures != nil {
+		failures = s.opts.Failures.Snapshot(tenantID)
+	}
+	// The rest of the status stays available while the database is not
+	repos, err := s.store.ListRepositories(r.Context())
+	if err != nil {
+		logger.Warn("Failed to list repositories for status", zap.Error(err))
+	}
+	syncs := make([]repositorySync, 0, len(repos))
+	for _, repo := range repos {
+		syncs = append(syncs, repositorySync{
+			Owner:         repo.Owner,
+			Name:          repo.Name,
+			Status:        repo.Status,
+			LastSyncAt:    repo.LastSyncAt,
+			LastSuccessAt: repo.LastSuccessAt,
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"fetches": fetches, "sync_lag": syncLag, "failures": failures, "repositories": syncs})
+}
+
+// repositorySync is when a repository was last synced, as GET /status
+// reports it
+type repositorySync struct {
+	Owner         string     `json:"owner"`
+	Name          string     `json:"name"`
+	Status        string     `json:"status"`
+	LastSyncAt    *time.Time `json:"last_sync_at"`
+	LastSuccessAt *time.Time `json:"last_success_at"`
+}
+
+// handleHealth answers that the server is up, with the optional features
+// enabled by default and the repositories overriding them
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	statuses := []features.Status{}
+	if s.opts.Features != nil {
+		statuses = s.opts.Features.Snapshot()
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": "ok", "features": statuses})
+}
+
+// handleSync starts a one-off sync of a repository in the background and
+// answers 202 Accepted; its progress is reported on GET /status. The optional
+// since parameter is an RFC 3339 timestamp or a YYYY-MM-DD date.
+func (s *Server) handleSync(w http.ResponseWriter, r *http.Request) {
+	owner, name := r.PathValue("owner"), r.PathValue("name")
+
+	var since time.Time
+	if raw := r.URL.Query().Get("since"); raw != "" {
+		var err error
+		if since, err = ParseSince(raw); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	key := fmt.Sprintf("%d/%s/%s", tenant.FromContext(r.Context()), owner, name)
+	if _, running := s.syncing.LoadOrStore(key, struct{}{}); running {
+		writeError(w, http.StatusConflict, "a sync of this repository is already running")
+		return
+	}
+
+	// The sync outlives the request but keeps its tenant and actor
+	ctx, cancel := context.WithCancel(context.WithoutCancel(r.Context()))
+	stop := context.AfterFunc(s.ctx, cancel)
+	go func() {
+		defer s.syncing.Delete(key)
+		defer cancel()
+		defer stop()
+		if err := s.opts.Syncer.SyncRepo(ctx, owner, name, since); err != nil {
+			logger.Error("Ad-hoc sync failed",
+				zap.Error(err),
+				zap.String("repo_owner", owner),
+				zap.String("repo_name", name))
+		}
+	}()
+
+	body := map[string]interface{}{"status": "accepted", "owner": owner, "name": name}
+	if !since.IsZero() {
+		body["since"] = since
+	}
+	writeJSON(w, http.StatusAccepted, body)
+}
+
+// ParseSince parses a sync start given as an RFC 3339 timestamp or a
+// YYYY-MM-DD date (midnight UTC)
+func ParseSince(raw string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid since %q: want RFC 3339 or YYYY-MM-DD", raw)
+	}
+	return t, nil
+}
+
+// limitParam parses the limit query parameter, capped at the maximum page size
+func (s *Server) limitParam(r *http.Request) (int, error) {
+	limit, err := intParam(r, "limit", 10)
+	if err != nil {
+		return 0, err
+	}
+	if limit < 1 {
+		limit = 10
+	}
+	if limit > s.opts.MaxPageSize {
+		limit = s.opts.MaxPageSize
+	}
+	return limit, nil
+}
+
+// paginationParams parses page and page_size, clamping the page size to the
+// configured maximum and rejecting pages beyond the maximum result window
+func (s *Server) paginationParams(r *http.Request) (models.PaginationParams, error) {
+	page, err := intParam(r, "page", 1)
+	if err != nil {
+		return models.PaginationParams{}, err
+	}
+	pageSize, err := intParam(r, "page_size", s.opts.MaxPageSize)
+	if err != nil {
+		return models.PaginationParams{}, err
+	}
+
+	params := models.NewPaginationParams(page, pageSize)
+	if params.PageSize > s.opts.MaxPageSize {
+		params.PageSize = s.opts.MaxPageSize
+	}
+	if params.Window() > s.opts.MaxResultWindow {
+		return models.PaginationParams{}, fmt.Errorf("result window too large: page * page_size must not exceed %d", s.opts.MaxResultWindow)
+	}
+	return params, nil
+}
+
+// intParam parses an optional integer query parameter
+func intParam(r *http.Request, name string, def int) (int, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, nil
+	}
+	val, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %q", name, raw)
+	}
+	return val, nil
+}
+
+// timeParam parses an RFC 3339 or YYYY-MM-DD query parameter, the latter as
+// midnight UTC
+func timeParam(r *http.Request, name string, def time.Time) (time.Time, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s: %q", name, raw)
+	}
+	return t, nil
+}
+
+// calendarParam returns the calendar statistics count working time by when
+// the working_time parameter is true, nil otherwise. The tz parameter moves
+// the calendar to another time zone, to compare teams in different regions.
+func (s *Server) calendarParam(r *http.Request) (*models.WorkCalendar, error) {
+	query := r.URL.Query()
+	raw := query.Get("working_time")
+	if raw == "" {
+		return nil, nil
+	}
+	workingTime, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid working_time: %q", raw)
+	}
+	if !workingTime {
+		return nil, nil
+	}
+	cal := models.DefaultWorkCalendar()
+	if s.opts.Calendar != nil {
+		cal = *s.opts.Calendar
+	}
+	if tz := query.Get("tz"); tz != "" {
+		if _, err := time.LoadLocation(tz); err != nil {
+			return nil, fmt.Errorf("invalid tz: %q", tz)
+		}
+		cal.Timezone = tz
+	}
+	return &cal, nil
+}
+
+// handleResetSync refetches a repository's commits from the required since
+// parameter, an RFC 3339 timestamp or a YYYY-MM-DD date, and answers with
+// how many commits were fetched and written once the refetch is done
+func (s *Server) handleResetSync(w http.ResponseWriter, r *http.Request) {
+	raw := r.URL.Query().Get("since")
+	if raw == "" {
+		writeError(w, http.StatusBadRequest, "since is required")
+		return
+	}
+	since, err := ParseSince(raw)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := s.opts.Resetter.ResetSyncPoint(r.Context(), r.PathValue("name"), since)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// writeStoreError maps database errors to HTTP status codes
+func writeStoreError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, db.ErrRepositoryNotFound), errors.Is(err, db.ErrPathFilterNotFound),
+		errors.Is(err, db.ErrDeploymentNotFound), errors.Is(err, db.ErrCommitNotFound),
+		errors.Is(err, db.ErrOwnerNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, db.ErrInvalidInput):
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		logger.Error("Query API request failed", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "internal server error")
+	}
+}
+
+// writeUnauthorized rejects a request for lack of a valid bearer token
+func writeUnauthorized(w http.ResponseWriter, msg string) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	writeError(w, http.StatusUnauthorized, msg)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		logger.Error("Failed to encode response", zap.Error(err))
+	}
+}
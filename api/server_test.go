@@ -0,0 +1,997 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"githubapifetch/audit"
+	"githubapifetch/db"
+	"githubapifetch/features"
+	"githubapifetch/models"
+	"githubapifetch/progress"
+	"githubapifetch/scheduler"
+	"githubapifetch/tenant"
+)
+
+// fakeStore is an in-memory Store recording the last pagination request
+type fakeStore struct {
+	lastParams models.PaginationParams
+	lastLimit  int
+	lastTenant int
+	lastAction string
+	lastFilter string
+	lastStats  models.StatsFilter
+	lastRepos  models.RepositoryFilter
+}
+
+func (f *fakeStore) GetByName(ctx context.Context, name string) (*models.Repository, error) {
+	f.lastTenant = tenant.FromContext(ctx)
+	if name != "test-repo" {
+		return nil, fmt.Errorf("%w: repository %s not found", db.ErrRepositoryNotFound, name)
+	}
+	return &models.Repository{ID: 1, Name: name, Owner: "test-owner"}, nil
+}
+
+func (f *fakeStore) GetRepositoryStats(ctx context.Context, repoName, pathFilter string, filter models.StatsFilter) (*models.RepositoryStats, error) {
+	f.lastFilter = pathFilter
+	f.lastStats = filter
+	if pathFilter != "" && pathFilter != "payments" {
+		return nil, fmt.Errorf("%w: %s of repository %s", db.ErrPathFilterNotFound, pathFilter, repoName)
+	}
+	return &models.RepositoryStats{TotalCommits: 10, UniqueAuthors: 2}, nil
+}
+
+func (f *fakeStore) GetCommitBySHA(ctx context.Context, repoName, sha string) (*models.CommitDetail, error) {
+	if len(sha) < 7 {
+		return nil, fmt.Errorf("%w: invalid commit SHA %q", db.ErrInvalidInput, sha)
+	}
+	if !strings.HasPrefix("abc1234def", sha) || (repoName != "" && repoName != "test-repo") {
+		return nil, fmt.Errorf("%w: commit %s", db.ErrCommitNotFound, sha)
+	}
+	return &models.CommitDetail{
+		Commit:    models.Commit{SHA: "abc1234def", Parents: []string{"0123456789"}},
+		RepoOwner: "test-owner",
+		RepoName:  "test-repo",
+	}, nil
+}
+
+func (f *fakeStore) ActivityFeed(ctx context.Context, cursor models.ActivityCursor, limit int) (*models.ActivityPage, error) {
+	events := []models.ActivityEvent{
+		{Kind: models.ActivityPullRequestMerged, At: time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC), Ref: "12", Key: "pull_request:3"},
+		{Kind: models.ActivityRelease, At: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), Ref: "v1.0.0", Key: "release:2"},
+		{Kind: models.ActivityCommit, At: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), Ref: "abc123", Key: "commit:1"},
+	}
+	page := &models.ActivityPage{Events: []models.ActivityEvent{}}
+	for _, e := range events {
+		if !cursor.IsZero() && !e.At.Before(cursor.At) && !(e.At.Equal(cursor.At) && e.Key < cursor.Key) {
+			continue
+		}
+		if len(page.Events) == limit {
+			last := page.Events[limit-1]
+			page.NextCursor = models.ActivityCursor{At: last.At, Key: last.Key}.String()
+			break
+		}
+		page.Events = append(page.Events, e)
+	}
+	return page, nil
+}
+
+func (f *fakeStore) RollupStats(ctx context.Context, owner, group string, filter models.StatsFilter, top int) (*models.RollupStats, error) {
+	f.lastStats = filter
+	if !filter.Since.IsZero() && !filter.Until.IsZero() && !filter.Since.Before(filter.Until) {
+		return nil, fmt.Errorf("%w: the period must end after it starts", db.ErrInvalidInput)
+	}
+	busiest := []models.RepositoryActivity{{RepoName: "test-repo", Owner: "test-owner", Commits: 7, Authors: 2}}
+	return &models.RollupStats{Owner: owner, Group: group, Repositories: 3, ActiveRepositories: 1, TotalCommits: 7, UniqueAuthors: 2, Busiest: busiest[:min(top, 1)]}, nil
+}
+
+func (f *fakeStore) ListCommits(ctx context.Context, repoName, pathFilter string, params models.PaginationParams) ([]models.Commit, error) {
+	f.lastParams = params
+	f.lastFilter = pathFilter
+	return []models.Commit{{SHA: "abc123"}}, nil
+}
+
+func (f *fakeStore) ListPathFilters(ctx context.Context, repoName string) ([]models.PathFilter, error) {
+	return []models.PathFilter{{ID: 1, RepoID: 1, Name: "payments", Pattern: "services/payments/**"}}, nil
+}
+
+func (f *fakeStore) GetTopAuthors(ctx context.Context, limit int) ([]models.AuthorStats, error) {
+	f.lastLimit = limit
+	return []models.AuthorStats{{AuthorName: "Test Author", Count: 3}}, nil
+}
+
+func (f *fakeStore) GetTenantByAPIToken(ctx context.Context, token string) (*models.Tenant, error) {
+	if token != "acme-token" {
+		return nil, fmt.Errorf("%w: no tenant for API token", db.ErrTenantNotFound)
+	}
+	return &models.Tenant{ID: 2, Name: "acme"}, nil
+}
+
+func (f *fakeStore) ListAuditEntries(ctx context.Context, action string, limit int) ([]models.AuditEntry, error) {
+	f.lastTenant = tenant.FromContext(ctx)
+	f.lastAction = action
+	f.lastLimit = limit
+	return []models.AuditEntry{{ID: 1, Actor: "alice", ActorType: models.ActorTypeCLI, Action: action}}, nil
+}
+
+func (f *fakeStore) CommitHeatmap(ctx context.Context, repoName, author string, since, until time.Time, timezone string) (*models.Heatmap, error) {
+	if repoName != "" && repoName != "test-repo" {
+		return nil, fmt.Errorf("%w: repository %s not found", db.ErrRepositoryNotFound, repoName)
+	}
+	heatmap := &models.Heatmap{Repo: repoName, Author: author, Since: since, Until: until, Timezone: timezone, Total: 4}
+	heatmap.Cells[1][9] = 4
+	return heatmap, nil
+}
+
+func (f *fakeStore) IssueStats(ctx context.Context, repoName, label string, since, until time.Time, cal *models.WorkCalendar) (*models.IssueStats, error) {
+	if repoName != "test-repo" {
+		return nil, fmt.Errorf("%w: repository %s not found", db.ErrRepositoryNotFound, repoName)
+	}
+	return &models.IssueStats{Repo: repoName, Label: label, Since: since, Until: until, Open: 3, Closed: 2, Calendar: cal}, nil
+}
+
+func (f *fakeStore) PullRequestStats(ctx context.Context, repoName string, authors []string, since, until time.Time, cal *models.WorkCalendar) (*models.PullRequestStats, error) {
+	if repoName != "" && repoName != "test-repo" {
+		return nil, fmt.Errorf("%w: repository %s not found", db.ErrRepositoryNotFound, repoName)
+	}
+	return &models.PullRequestStats{Repo: repoName, Authors: authors, Since: since, Until: until, Opened: 4, Merged: 3, Calendar: cal}, nil
+}
+
+func (f *fakeStore) ReleaseCadence(ctx context.Context, since, until time.Time, prereleases bool) ([]models.ReleaseCadence, error) {
+	releases := 4
+	if prereleases {
+		releases = 6
+	}
+	return []models.ReleaseCadence{{RepoName: "test-repo", Releases: releases, LastRelease: &until}}, nil
+}
+
+func (f *fakeStore) DeploymentCommits(ctx context.Context, repoName string, githubID int64) (*models.DeploymentCommits, error) {
+	if githubID != 700 {
+		return nil, fmt.Errorf("%w: deployment %d of repository %s", db.ErrDeploymentNotFound, githubID, repoName)
+	}
+	return &models.DeploymentCommits{
+		Deployment: models.Deployment{GitHubID: githubID, SHA: "def", Environment: "production"},
+		Previous:   &models.Deployment{GitHubID: 600, SHA: "abc", Environment: "production"},
+		Commits:    []models.Commit{{SHA: "def"}},
+		CompareURL: "https://github.com/test-owner/test-repo/compare/abc...def",
+	}, nil
+}
+
+func (f *fakeStore) CommitDeployment(ctx context.Context, repoName, sha, environment string) (*models.CommitDeployment, error) {
+	if sha != "def" {
+		return nil, fmt.Errorf("%w: commit %s of repository %s", db.ErrCommitNotFound, sha, repoName)
+	}
+	hours := 1.5
+	return &models.CommitDeployment{
+		Commit:        models.Commit{SHA: sha},
+		Environment:   environment,
+		Deployment:    &models.Deployment{GitHubID: 700, SHA: sha, Environment: environment},
+		LeadTimeHours: &hours,
+	}, nil
+}
+
+func (f *fakeStore) ListRepositories(ctx context.Context) ([]models.Repository, error) {
+	synced := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	return []models.Repository{
+		{ID: 1, Owner: "octo", Name: "hello", Status: models.RepoStatusActive, LastSyncAt: &synced, LastSuccessAt: &synced},
+		{ID: 2, Owner: "octo", Name: "new", Status: models.RepoStatusActive},
+	}, nil
+}
+
+func (f *fakeStore) ListOwners(ctx context.Context) ([]models.OwnerListing, error) {
+	return []models.OwnerListing{
+		{Owner: models.Owner{Login: "octo", Type: models.OwnerTypeOrg, Name: "Octo Inc."}, Repositories: 3},
+	}, nil
+}
+
+func (f *fakeStore) GetOwner(ctx context.Context, login string) (*models.Owner, error) {
+	if login != "octo" {
+		return nil, fmt.Errorf("%w: %s", db.ErrOwnerNotFound, login)
+	}
+	return &models.Owner{Login: "octo", Type: models.OwnerTypeOrg, Name: "Octo Inc."}, nil
+}
+
+func (f *fakeStore) SearchRepositories(ctx context.Context, filter models.RepositoryFilter, params models.PaginationParams) ([]models.RepositoryListing, error) {
+	if filter.Sort == "forks" {
+		return nil, fmt.Errorf("%w: unknown sort %q", db.ErrInvalidInput, filter.Sort)
+	}
+	f.lastRepos, f.lastParams = filter, params
+	return []models.RepositoryListing{{Repository: models.Repository{ID: 1, Owner: "octo", Name: "hello", StarsCount: 50}, Commits: 120}}, nil
+}
+
+func (f *fakeStore) ListRateLimitSamples(ctx context.Context, since, until time.Time) ([]models.RateLimitSample, error) {
+	if !since.Before(until) {
+		return nil, fmt.Errorf("%w: the period must end after it starts", db.ErrInvalidInput)
+	}
+	return []models.RateLimitSample{
+		{Resource: "core", Limit: 5000, Remaining: 12, ResetAt: until, ObservedAt: since},
+		{Resource: "core", Limit: 5000, Remaining: 0, ResetAt: until, ObservedAt: since.Add(time.Minute)},
+	}, nil
+}
+
+// newTestServer returns a server reading the default tenant for requests
+// without a token, or with "default-token"
+func newTestServer(store Store) *Server {
+	return NewServer(store, Options{
+		RateLimit:       1000,
+		RateBurst:       1000,
+		MaxPageSize:     50,
+		MaxResultWindow: 500,
+		APIToken:        "default-token",
+		AllowAnonymous:  true,
+	})
+}
+
+func TestListCommitsPagination(t *testing.T) {
+	testCases := []struct {
+		name             string
+		query            string
+		expectedStatus   int
+		expectedPage     int
+		expectedPageSize int
+	}{
+		{
+			name:             "defaults to max page size",
+			query:            "",
+			expectedStatus:   http.StatusOK,
+			expectedPage:     1,
+			expectedPageSize: 50,
+		},
+		{
+			name:             "page size clamped to maximum",
+			query:            "?page=2&page_size=1000",
+			expectedStatus:   http.StatusOK,
+			expectedPage:     2,
+			expectedPageSize: 50,
+		},
+		{
+			name:           "result window exceeded",
+			query:          "?page=11&page_size=50",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid page",
+			query:          "?page=abc",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := &fakeStore{}
+			handler := newTestServer(store).Handler()
+
+			req := httptest.NewRequest(http.MethodGet, "/repos/test-repo/commits"+tc.query, nil)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var resp PageResponse
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+			assert.Equal(t, tc.expectedPage, resp.Page)
+			assert.Equal(t, tc.expectedPageSize, resp.PageSize)
+			assert.Equal(t, tc.expectedPageSize, store.lastParams.PageSize)
+		})
+	}
+}
+
+func TestTopAuthorsLimitCapped(t *testing.T) {
+	store := &fakeStore{}
+	handler := newTestServer(store).Handler()
+
+	req := httptest.NewRequest(http.MethodGet, "/authors/top?limit=100000", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 50, store.lastLimit)
+}
+
+func TestPathFilters(t *testing.T) {
+	store := &fakeStore{}
+	handler := newTestServer(store).Handler()
+
+	for _, path := range []string{"/repos/test-repo/stats?path_filter=payments", "/repos/test-repo/commits?path_filter=payments"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusOK, rec.Code, path)
+		assert.Equal(t, "payments", store.lastFilter, path)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/repos/test-repo/stats?path_filter=billing", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// Statistics of one author over a quarter
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/repos/test-repo/stats?since=2024-07-01&until=2024-10-01&author=Octo%20Cat", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, models.StatsFilter{
+		Since:  time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC),
+		Until:  time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC),
+		Author: "Octo Cat",
+	}, store.lastStats)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/repos/test-repo/stats?since=Q3", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/repos/test-repo/path-filters", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var filters []map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &filters))
+	require.Len(t, filters, 1)
+	assert.Equal(t, "services/payments/**", filters[0]["pattern"])
+	assert.NotContains(t, filters[0], "path_regex")
+}
+
+func TestRepositoryNotFound(t *testing.T) {
+	handler := newTestServer(&fakeStore{}).Handler()
+
+	req := httptest.NewRequest(http.MethodGet, "/repos/missing", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestRateLimiting(t *testing.T) {
+	server := NewServer(&fakeStore{}, Options{
+		RateLimit:       1,
+		RateBurst:       2,
+		MaxPageSize:     50,
+		MaxResultWindow: 500,
+		AllowAnonymous:  true,
+	})
+	handler := server.Handler()
+
+	statuses := make([]int, 0, 3)
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/repos/test-repo/stats", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		statuses = append(statuses, rec.Code)
+		if rec.Code == http.StatusTooManyRequests {
+			assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+		}
+	}
+	assert.Equal(t, []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}, statuses)
+
+	// Other clients have their own bucket
+	req := httptest.NewRequest(http.MethodGet, "/repos/test-repo/stats", nil)
+	req.RemoteAddr = "10.0.0.2:1234"
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(2, 1)
+	limiter.now = func() time.Time { return now }
+
+	allowed, _ := limiter.Allow("client")
+	assert.True(t, allowed)
+
+	allowed, wait := limiter.Allow("client")
+	assert.False(t, allowed)
+	assert.Equal(t, 500*time.Millisecond, wait)
+
+	now = now.Add(500 * time.Millisecond)
+	allowed, _ = limiter.Allow("client")
+	assert.True(t, allowed)
+}
+
+func TestTenantScoping(t *testing.T) {
+	testCases := []struct {
+		name           string
+		authorization  string
+		expectedStatus int
+		expectedTenant int
+	}{
+		{
+			name:           "no token uses default tenant",
+			expectedStatus: http.StatusOK,
+			expectedTenant: tenant.DefaultID,
+		},
+		{
+			name:           "valid token scopes to tenant",
+			authorization:  "Bearer acme-token",
+			expectedStatus: http.StatusOK,
+			expectedTenant: 2,
+		},
+		{
+			name:           "unknown token",
+			authorization:  "Bearer other-token",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "malformed header",
+			authorization:  "Basic abc",
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := &fakeStore{}
+			handler := newTestServer(store).Handler()
+
+			req := httptest.NewRequest(http.MethodGet, "/repos/test-repo", nil)
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+			if tc.expectedStatus == http.StatusOK {
+				assert.Equal(t, tc.expectedTenant, store.lastTenant)
+			}
+		})
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	store := &fakeStore{}
+	syncer := &fakeSyncer{calls: make(chan syncCall, 1), release: make(chan struct{})}
+	defer close(syncer.release)
+	opts := Options{RateLimit: 1000, RateBurst: 1000, MaxPageSize: 50, APIToken: "default-token", Syncer: syncer, Resetter: fakeResetter{}}
+	serve := func(handler http.Handler, method, path, authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Every request but health checks needs a token by default
+	handler := NewServer(store, opts).Handler()
+	rec := serve(handler, http.MethodGet, "/repos/test-repo", "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, "Bearer", rec.Header().Get("WWW-Authenticate"))
+	assert.Equal(t, http.StatusOK, serve(handler, http.MethodGet, "/healthz", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, serve(handler, http.MethodGet, "/repos/test-repo", "Bearer wrong-token").Code)
+
+	// The configured token reads the default tenant
+	store.lastTenant = 0
+	assert.Equal(t, http.StatusOK, serve(handler, http.MethodGet, "/repos/test-repo", "Bearer default-token").Code)
+	assert.Equal(t, tenant.DefaultID, store.lastTenant)
+
+	// Anonymous access only reads stored data
+	opts.AllowAnonymous = true
+	handler = NewServer(store, opts).Handler()
+	assert.Equal(t, http.StatusOK, serve(handler, http.MethodGet, "/repos/test-repo", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, serve(handler, http.MethodGet, "/audit", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, serve(handler, http.MethodPost, "/repos/octo/hello/sync", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, serve(handler, http.MethodPost, "/repos/hello/reset-sync?since=2024-03-01", "").Code)
+	assert.Empty(t, syncer.calls)
+	assert.Equal(t, http.StatusOK, serve(handler, http.MethodGet, "/audit", "Bearer default-token").Code)
+}
+
+func TestAuditLog(t *testing.T) {
+	store := &fakeStore{}
+	handler := newTestServer(store).Handler()
+
+	req := httptest.NewRequest(http.MethodGet, "/audit?action=reset-sync&limit=1000", nil)
+	req.Header.Set("Authorization", "Bearer acme-token")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "reset-sync", store.lastAction)
+	assert.Equal(t, 50, store.lastLimit)
+	assert.Equal(t, 2, store.lastTenant)
+
+	var entries []models.AuditEntry
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&entries))
+	require.Len(t, entries, 1)
+	assert.Equal(t, "alice", entries[0].Actor)
+}
+
+// fakeProgress reports one fetch per tenant
+type fakeProgress struct{}
+
+func (fakeProgress) Snapshot(tenantID int) []progress.Fetch {
+	return []progress.Fetch{{TenantID: tenantID, Owner: "octo", Name: "hello", Pages: 2, TotalPages: 5}}
+}
+
+// fakeFailures reports one failing repository per tenant
+type fakeFailures struct{}
+
+func (fakeFailures) Snapshot(tenantID int) []scheduler.Failure {
+	return []scheduler.Failure{{TenantID: tenantID, Owner: "octo", Name: "broken", Error: "sync failed"}}
+}
+
+func TestStatus(t *testing.T) {
+	server := NewServer(&fakeStore{}, Options{RateLimit: 1000, RateBurst: 1000, MaxPageSize: 50, Progress: fakeProgress{}, Failures: fakeFailures{}})
+
+	req := httptest.NewRequest(http.MethodGet, "/status", nil)
+	req.Header.Set("Authorization", "Bearer acme-token")
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var status struct {
+		Fetches      []progress.Fetch    `json:"fetches"`
+		Failures     []scheduler.Failure `json:"failures"`
+		Repositories []repositorySync    `json:"repositories"`
+	}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&status))
+	require.Len(t, status.Fetches, 1)
+	assert.Equal(t, 2, status.Fetches[0].TenantID)
+	assert.Equal(t, 5, status.Fetches[0].TotalPages)
+	require.Len(t, status.Failures, 1)
+	assert.Equal(t, "broken", status.Failures[0].Name)
+	assert.Equal(t, "sync failed", status.Failures[0].Error)
+	require.Len(t, status.Repositories, 2)
+	assert.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), *status.Repositories[0].LastSuccessAt)
+	assert.Nil(t, status.Repositories[1].LastSyncAt)
+}
+
+func TestHealth(t *testing.T) {
+	flags := features.New(map[string]bool{features.Issues: true}, []features.Override{
+		{Repo: "octo/hello", Feature: features.Issues, Enabled: false},
+		{Repo: "octo/big", Feature: features.Releases, Enabled: true},
+	})
+	server := NewServer(&fakeStore{}, Options{RateLimit: 1000, RateBurst: 1000, MaxPageSize: 50, Features: flags})
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var health struct {
+		Status   string            `json:"status"`
+		Features []features.Status `json:"features"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &health))
+	assert.Equal(t, "ok", health.Status)
+	require.Len(t, health.Features, len(features.Names))
+	assert.Equal(t, features.Status{Name: features.Issues, Enabled: true, DisabledFor: []string{"octo/hello"}}, health.Features[0])
+	assert.Equal(t, features.Status{Name: features.Releases, EnabledFor: []string{"octo/big"}}, health.Features[2])
+
+	// Without a feature source the server still reports it is up
+	rec = httptest.NewRecorder()
+	newTestServer(&fakeStore{}).Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status": "ok", "features": []}`, rec.Body.String())
+}
+
+// syncCall is a SyncRepo invocation seen by fakeSyncer
+type syncCall struct {
+	tenantID int
+	actor    audit.Actor
+	owner    string
+	name     string
+	since    time.Time
+}
+
+// fakeSyncer reports its calls and blocks each sync until release is closed
+type fakeSyncer struct {
+	calls   chan syncCall
+	release chan struct{}
+}
+
+func (f *fakeSyncer) SyncRepo(ctx context.Context, owner, name string, since time.Time) error {
+	f.calls <- syncCall{tenant.FromContext(ctx), audit.ActorFromContext(ctx), owner, name, since}
+	<-f.release
+	return nil
+}
+
+func TestSync(t *testing.T) {
+	syncer := &fakeSyncer{calls: make(chan syncCall, 1), release: make(chan struct{})}
+	server := NewServer(&fakeStore{}, Options{RateLimit: 1000, RateBurst: 1000, MaxPageSize: 50, Syncer: syncer})
+	handler := server.Handler()
+
+	post := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.Header.Set("Authorization", "Bearer acme-token")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := post("/repos/octo/hello/sync?since=2024-03-01")
+	require.Equal(t, http.StatusAccepted, rec.Code)
+
+	call := <-syncer.calls
+	assert.Equal(t, 2, call.tenantID)
+	assert.Equal(t, audit.Actor{Name: "token:acme", Type: models.ActorTypeAPI}, call.actor)
+	assert.Equal(t, "octo", call.owner)
+	assert.Equal(t, "hello", call.name)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), call.since)
+
+	// The first sync is still running
+	assert.Equal(t, http.StatusConflict, post("/repos/octo/hello/sync").Code)
+	assert.Equal(t, http.StatusBadRequest, post("/repos/octo/other/sync?since=yesterday").Code)
+
+	close(syncer.release)
+	assert.Eventually(t, func() bool {
+		return post("/repos/octo/hello/sync").Code == http.StatusAccepted
+	}, time.Second, 10*time.Millisecond)
+	assert.True(t, (<-syncer.calls).since.IsZero())
+}
+
+func TestSyncWithoutSyncer(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/repos/octo/hello/sync", nil)
+	req.Header.Set("Authorization", "Bearer default-token")
+	rec := httptest.NewRecorder()
+	newTestServer(&fakeStore{}).Handler().ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+// fakeResetter resets the sync point of "hello" only
+type fakeResetter struct{}
+
+func (fakeResetter) ResetSyncPoint(ctx context.Context, repoName string, since time.Time) (*models.ResetSyncResult, error) {
+	if repoName != "hello" {
+		return nil, fmt.Errorf("failed to get repository: %w", db.ErrRepositoryNotFound)
+	}
+	return &models.ResetSyncResult{Owner: "octo", Name: repoName, Since: since, CommitsFetched: 5, CommitsInserted: 3, CommitsUpdated: 2, DurationMS: 40}, nil
+}
+
+func TestResetSync(t *testing.T) {
+	server := NewServer(&fakeStore{}, Options{RateLimit: 1000, RateBurst: 1000, MaxPageSize: 50, APIToken: "default-token", Resetter: fakeResetter{}})
+	handler := server.Handler()
+
+	post := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.Header.Set("Authorization", "Bearer default-token")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := post("/repos/hello/reset-sync?since=2024-03-01")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var result models.ResetSyncResult
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	assert.Equal(t, models.ResetSyncResult{
+		Owner:           "octo",
+		Name:            "hello",
+		Since:           time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		CommitsFetched:  5,
+		CommitsInserted: 3,
+		CommitsUpdated:  2,
+		DurationMS:      40,
+	}, result)
+
+	assert.Equal(t, http.StatusBadRequest, post("/repos/hello/reset-sync").Code)
+	assert.Equal(t, http.StatusBadRequest, post("/repos/hello/reset-sync?since=yesterday").Code)
+	assert.Equal(t, http.StatusNotFound, post("/repos/other/reset-sync?since=2024-03-01").Code)
+
+	// Not served without a resetter
+	handler = newTestServer(&fakeStore{}).Handler()
+	assert.Equal(t, http.StatusNotFound, post("/repos/hello/reset-sync?since=2024-03-01").Code)
+}
+
+func TestHeatmap(t *testing.T) {
+	handler := newTestServer(&fakeStore{}).Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/repos/test-repo/heatmap?since=2024-01-01&until=2024-07-01T00:00:00Z&author=Ada&tz=Europe/Berlin", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var heatmap models.Heatmap
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &heatmap))
+	assert.Equal(t, "test-repo", heatmap.Repo)
+	assert.Equal(t, "Ada", heatmap.Author)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), heatmap.Since)
+	assert.Equal(t, 4, heatmap.Cells[1][9])
+
+	// Across repositories, over the last year by default
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/heatmap", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var all models.Heatmap
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &all))
+	assert.Empty(t, all.Repo)
+	assert.Equal(t, defaultHeatmapPeriod, all.Until.Sub(all.Since))
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/repos/test-repo/heatmap?since=yesterday", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/repos/other/heatmap", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestIssueStats(t *testing.T) {
+	handler := newTestServer(&fakeStore{}).Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/repos/test-repo/stats/issues?label=bug&since=2024-01-01&until=2024-04-01", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var stats models.IssueStats
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stats))
+	assert.Equal(t, "bug", stats.Label)
+	assert.Equal(t, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), stats.Until)
+	assert.Equal(t, 3, stats.Open)
+
+	// Over the last 90 days by default
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/repos/test-repo/stats/issues", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var recent models.IssueStats
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &recent))
+	assert.Empty(t, recent.Label)
+	assert.Equal(t, defaultIssueStatsPeriod, recent.Until.Sub(recent.Since))
+
+	// Without a configured calendar, working time is Monday to Friday
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/repos/test-repo/stats/issues?working_time=1", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var working models.IssueStats
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &working))
+	assert.Equal(t, &models.WorkCalendar{Timezone: "UTC", Weekend: []string{"sat", "sun"}}, working.Calendar)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/repos/other/stats/issues", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestPullRequestStats(t *testing.T) {
+	handler := newTestServer(&fakeStore{}).Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/repos/test-repo/stats/pulls?authors=octo,%20cat,&since=2024-01-01&until=2024-04-01", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var stats models.PullRequestStats
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stats))
+	assert.Equal(t, "test-repo", stats.Repo)
+	assert.Equal(t, []string{"octo", "cat"}, stats.Authors)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), stats.Since)
+	assert.Equal(t, 3, stats.Merged)
+
+	// Every repository, by everyone, over the last 90 days by default
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats/pulls", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var all models.PullRequestStats
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &all))
+	assert.Empty(t, all.Repo)
+	assert.Empty(t, all.Authors)
+	assert.Equal(t, defaultIssueStatsPeriod, all.Until.Sub(all.Since))
+	assert.Nil(t, all.Calendar)
+
+	// Working time, by the configured calendar moved to another time zone
+	cal := models.WorkCalendar{Timezone: "Europe/Berlin", Weekend: []string{"fri", "sat"}, Holidays: []string{"2024-05-01"}}
+	handler = NewServer(&fakeStore{}, Options{RateLimit: 1000, RateBurst: 1000, AllowAnonymous: true, Calendar: &cal}).Handler()
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats/pulls?working_time=true&tz=Asia/Tokyo", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var working models.PullRequestStats
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &working))
+	assert.Equal(t, &models.WorkCalendar{Timezone: "Asia/Tokyo", Weekend: []string{"fri", "sat"}, Holidays: []string{"2024-05-01"}}, working.Calendar)
+	assert.Equal(t, "Europe/Berlin", cal.Timezone, "the configured calendar is left as it was")
+
+	for _, query := range []string{"working_time=maybe", "working_time=true&tz=Mars/Olympus"} {
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats/pulls?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/repos/other/stats/pulls", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestReleaseCadence(t *testing.T) {
+	handler := newTestServer(&fakeStore{}).Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats/releases?since=2024-01-01&until=2025-01-01&prereleases=true", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var cadence []models.ReleaseCadence
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &cadence))
+	require.Len(t, cadence, 1)
+	assert.Equal(t, 6, cadence[0].Releases)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats/releases", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &cadence))
+	assert.Equal(t, 4, cadence[0].Releases)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats/releases?prereleases=maybe", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestGetCommit(t *testing.T) {
+	handler := newTestServer(&fakeStore{}).Handler()
+
+	for _, path := range []string{"/repos/test-repo/commits/abc1234def", "/commits/abc1234"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusOK, rec.Code, path)
+		var commit map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &commit))
+		assert.Equal(t, "abc1234def", commit["sha"], "the commit's fields are inlined")
+		assert.Equal(t, "test-repo", commit["repo"])
+		assert.Equal(t, []interface{}{"0123456789"}, commit["parents"])
+		assert.Nil(t, commit["files"], "files were not fetched")
+	}
+
+	for path, status := range map[string]int{
+		"/commits/abc":                    http.StatusBadRequest,
+		"/commits/fff1234":                http.StatusNotFound,
+		"/repos/other/commits/abc1234def": http.StatusNotFound,
+		"/repos/test-repo/commits/def/deployment?environment=production": http.StatusOK,
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, status, rec.Code, path)
+	}
+}
+
+func TestActivityFeed(t *testing.T) {
+	handler := newTestServer(&fakeStore{}).Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/activity?limit=2", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var page models.ActivityPage
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	require.Len(t, page.Events, 2)
+	assert.Equal(t, models.ActivityPullRequestMerged, page.Events[0].Kind)
+	require.NotEmpty(t, page.NextCursor)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/activity?limit=2&cursor="+page.NextCursor, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	page = models.ActivityPage{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	require.Len(t, page.Events, 1)
+	assert.Equal(t, "abc123", page.Events[0].Ref)
+	assert.Empty(t, page.NextCursor)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/activity?cursor=not-a-cursor", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestRollupStats(t *testing.T) {
+	store := &fakeStore{}
+	handler := newTestServer(store).Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats/rollup?owner=octo&group=payments&since=2024-01-01&author=Octo+Cat&limit=5", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var stats models.RollupStats
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stats))
+	assert.Equal(t, "octo", stats.Owner)
+	assert.Equal(t, "payments", stats.Group)
+	assert.Equal(t, 7, stats.TotalCommits)
+	require.Len(t, stats.Busiest, 1)
+	assert.Equal(t, "test-repo", stats.Busiest[0].RepoName)
+	assert.Equal(t, models.StatsFilter{Since: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Author: "Octo Cat"}, store.lastStats)
+
+	for _, query := range []string{"since=yesterday", "limit=many", "since=2024-02-01&until=2024-01-01"} {
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats/rollup?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
+
+func TestListRepositories(t *testing.T) {
+	store := &fakeStore{}
+	handler := newTestServer(store).Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/repos?owner=octo&language=Go&status=active&group=payments&min_stars=10&sort=stars&page=2&page_size=5", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var page struct {
+		Data []models.RepositoryListing `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	require.Len(t, page.Data, 1)
+	assert.Equal(t, "hello", page.Data[0].Name)
+	assert.Equal(t, 120, page.Data[0].Commits)
+	assert.Equal(t, models.RepositoryFilter{Owner: "octo", Language: "Go", Status: "active", Group: "payments", MinStars: 10, Sort: "stars"}, store.lastRepos)
+	assert.Equal(t, models.PaginationParams{Page: 2, PageSize: 5}, store.lastParams)
+
+	for _, query := range []string{"min_stars=many", "sort=forks"} {
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/repos?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
+
+func TestOwners(t *testing.T) {
+	handler := newTestServer(&fakeStore{}).Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/owners", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var owners []models.OwnerListing
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &owners))
+	require.Len(t, owners, 1)
+	assert.Equal(t, models.OwnerTypeOrg, owners[0].Type)
+	assert.Equal(t, 3, owners[0].Repositories)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/owners/octo", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var owner models.Owner
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &owner))
+	assert.Equal(t, "Octo Inc.", owner.Name)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/owners/nobody", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestDeploymentTraceability(t *testing.T) {
+	handler := newTestServer(&fakeStore{}).Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/repos/test-repo/deployments/700/commits", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var shipped models.DeploymentCommits
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &shipped))
+	assert.Equal(t, int64(600), shipped.Previous.GitHubID)
+	assert.Equal(t, "https://github.com/test-owner/test-repo/compare/abc...def", shipped.CompareURL)
+	require.Len(t, shipped.Commits, 1)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/repos/test-repo/deployments/701/commits", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/repos/test-repo/deployments/latest/commits", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// Commits are traced to production by default
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/repos/test-repo/commits/def/deployment", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var reached models.CommitDeployment
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &reached))
+	assert.Equal(t, "production", reached.Environment)
+	require.NotNil(t, reached.LeadTimeHours)
+	assert.Equal(t, 1.5, *reached.LeadTimeHours)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/repos/test-repo/commits/def/deployment?environment=staging", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &reached))
+	assert.Equal(t, "staging", reached.Environment)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/repos/test-repo/commits/fff/deployment", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestRateLimitHistory(t *testing.T) {
+	handler := newTestServer(&fakeStore{}).Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rate-limits?since=2024-03-01&until=2024-03-02", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var samples []models.RateLimitSample
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &samples))
+	require.Len(t, samples, 2)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), samples[0].ObservedAt)
+	assert.Equal(t, 0, samples[1].Remaining)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rate-limits?since=2024-03-02&until=2024-03-01", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
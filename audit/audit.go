@@ -0,0 +1,63 @@
+// Package audit identifies who triggered an administrative action.
+package audit
+
+import (
+	"context"
+	"os/user"
+
+	"githubapifetch/models"
+)
+
+// Actions recorded in the audit log
+const (
+	ActionResetSync     = "reset-sync"
+	ActionAddRepo       = "add-repo"
+	ActionRemoveRepo    = "remove-repo"
+	ActionPauseRepo     = "pause-repo"
+	ActionResumeRepo    = "resume-repo"
+	ActionAddTenant     = "add-tenant"
+	ActionEncryptToken  = "encrypt-secrets"
+	ActionReplay        = "replay"
+	ActionRequeueRepo   = "requeue-repo"
+	ActionSync          = "sync"
+	ActionImportRepos   = "import-repos"
+	ActionSetFilter     = "set-path-filter"
+	ActionRemoveFilter  = "remove-path-filter"
+	ActionDump          = "dump"
+	ActionRestore       = "restore"
+	ActionSeed          = "seed"
+	ActionImportArchive = "import-archive"
+	ActionSetGroup      = "set-group"
+	ActionPurgeRepo     = "purge-repo"
+)
+
+// Actor identifies the user or credential performing an action
+type Actor struct {
+	Name string
+	Type string
+}
+
+type ctxKey struct{}
+
+// WithActor returns a copy of ctx carrying the actor
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, ctxKey{}, actor)
+}
+
+// ActorFromContext returns the actor carried by ctx. Actions without an
+// explicit actor are attributed to the local CLI user.
+func ActorFromContext(ctx context.Context) Actor {
+	if actor, ok := ctx.Value(ctxKey{}).(Actor); ok {
+		return actor
+	}
+	return CLIActor()
+}
+
+// CLIActor returns the actor for the operating system user running the CLI
+func CLIActor() Actor {
+	name := "unknown"
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		name = u.Username
+	}
+	return Actor{Name: name, Type: models.ActorTypeCLI}
+}
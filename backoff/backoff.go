@@ -0,0 +1,114 @@
+// Package backoff decides how long to wait between retries. The GitHub
+// client's rate limit retries, database transaction retries and quarantine
+// re-checks all take a Backoff, so the strategy can be chosen in the
+// configuration while each keeps its own base delay and cap.
+package backoff
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+// Strategies selectable by name
+const (
+	StrategyConstant           = "constant"
+	StrategyExponential        = "exponential"
+	StrategyDecorrelatedJitter = "decorrelated-jitter"
+)
+
+// Backoff returns the delay before a retry
+type Backoff interface {
+	// Delay returns how long to wait before retry n, counting from 1, given
+	// the delay before the previous retry, or 0 before the first
+	Delay(n int, previous time.Duration) time.Duration
+}
+
+// Constant waits Base before every retry
+type Constant struct {
+	Base time.Duration
+}
+
+// Delay implements Backoff
+func (b Constant) Delay(int, time.Duration) time.Duration {
+	return b.Base
+}
+
+// Exponential waits Base before the first retry and doubles the delay after
+// every further one, up to Max when it is set
+type Exponential struct {
+	Base, Max time.Duration
+}
+
+// Delay implements Backoff
+func (b Exponential) Delay(n int, _ time.Duration) time.Duration {
+	delay := b.Base
+	for i := 1; i < n && delay > 0 && delay <= math.MaxInt64/2 && (b.Max <= 0 || delay < b.Max); i++ {
+		delay *= 2
+	}
+	return capped(delay, b.Max)
+}
+
+// DecorrelatedJitter waits a random time between Base and three times the
+// previous delay, up to Max when it is set. Clients retrying after the same
+// failure drift apart instead of retrying in lockstep, while the delays
+// still grow about as fast as exponential ones.
+type DecorrelatedJitter struct {
+	Base, Max time.Duration
+}
+
+// Delay implements Backoff
+func (b DecorrelatedJitter) Delay(_ int, previous time.Duration) time.Duration {
+	upper := max(previous, b.Base) * 3
+	if b.Max > 0 && upper > b.Max {
+		upper = b.Max
+	}
+	if upper <= b.Base {
+		return capped(b.Base, b.Max)
+	}
+	return b.Base + rand.N(upper-b.Base)
+}
+
+func capped(delay, limit time.Duration) time.Duration {
+	if limit > 0 && delay > limit {
+		return limit
+	}
+	return delay
+}
+
+// ParseStrategy returns the strategy named by name, in lower case, with an
+// empty name standing for exponential
+func ParseStrategy(name string) (string, error) {
+	switch strategy := strings.ToLower(name); strategy {
+	case StrategyConstant, StrategyExponential, StrategyDecorrelatedJitter:
+		return strategy, nil
+	case "":
+		return StrategyExponential, nil
+	}
+	return "", fmt.Errorf("unknown backoff strategy %q: want %s, %s or %s",
+		name, StrategyConstant, StrategyExponential, StrategyDecorrelatedJitter)
+}
+
+// New returns the Backoff of a strategy accepted by ParseStrategy, with a
+// base delay and a cap of limit, or 0 for none. Other names are exponential.
+func New(strategy string, base, limit time.Duration) Backoff {
+	switch strings.ToLower(strategy) {
+	case StrategyConstant:
+		return Constant{Base: base}
+	case StrategyDecorrelatedJitter:
+		return DecorrelatedJitter{Base: base, Max: limit}
+	}
+	return Exponential{Base: base, Max: limit}
+}
+
+// Nth returns the delay b gives before retry n, stepping through the retries
+// before it. It suits callers that only keep count of their retries.
+func Nth(b Backoff, n int) time.Duration {
+	var delay time.Duration
+	for i := 1; i <= n; i++ {
+		delay = b.Delay(i, delay)
+	}
+	return delay
+}
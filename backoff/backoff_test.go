@@ -0,0 +1,49 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExponential(t *testing.T) {
+	b := Exponential{Base: time.Second, Max: 5 * time.Second}
+	var delays []time.Duration
+	for n := 1; n <= 5; n++ {
+		delays = append(delays, b.Delay(n, 0))
+	}
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}, delays)
+	assert.Positive(t, Exponential{Base: time.Second}.Delay(1000, 0), "uncapped delays do not overflow")
+}
+
+func TestDecorrelatedJitter(t *testing.T) {
+	b := DecorrelatedJitter{Base: time.Second, Max: time.Minute}
+	previous := time.Duration(0)
+	for n := 1; n <= 100; n++ {
+		delay := b.Delay(n, previous)
+		assert.GreaterOrEqual(t, delay, time.Second)
+		assert.LessOrEqual(t, delay, min(max(previous, time.Second)*3, time.Minute))
+		previous = delay
+	}
+}
+
+func TestNew(t *testing.T) {
+	assert.Equal(t, time.Second, Nth(New(StrategyConstant, time.Second, time.Minute), 10))
+	assert.Equal(t, 8*time.Second, Nth(New(StrategyExponential, time.Second, time.Minute), 4))
+	assert.IsType(t, DecorrelatedJitter{}, New(StrategyDecorrelatedJitter, time.Second, time.Minute))
+}
+
+func TestParseStrategy(t *testing.T) {
+	strategy, err := ParseStrategy("Decorrelated-Jitter")
+	require.NoError(t, err)
+	assert.Equal(t, StrategyDecorrelatedJitter, strategy)
+
+	strategy, err = ParseStrategy("")
+	require.NoError(t, err)
+	assert.Equal(t, StrategyExponential, strategy)
+
+	_, err = ParseStrategy("linear")
+	assert.Error(t, err)
+}
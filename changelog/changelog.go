@@ -0,0 +1,209 @@
+// Package changelog assembles release notes from commit messages following
+// the Conventional Commits format, "type(scope)!: description", grouping
+// them by type and linking the issues they reference.
+//
+// See https://www.conventionalcommits.org/en/v1.0.0/
+package changelog
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"githubapifetch/models"
+)
+
+// Sections lists the commit types with a section of their own, in the
+// order they are written; commits of other types, or not following the
+// format, go to a last "Other Changes" section
+var Sections = []struct {
+	Type  string
+	Title string
+}{
+	{"feat", "Features"},
+	{"fix", "Bug Fixes"},
+	{"perf", "Performance"},
+	{"refactor", "Refactoring"},
+	{"docs", "Documentation"},
+	{"test", "Tests"},
+	{"build", "Build"},
+	{"ci", "Continuous Integration"},
+	{"chore", "Chores"},
+	{"revert", "Reverts"},
+}
+
+const otherTitle = "Other Changes"
+
+var (
+	headerPattern   = regexp.MustCompile(`^([a-zA-Z]+)(?:\(([^)]*)\))?(!)?: (.+)$`)
+	issuePattern    = regexp.MustCompile(`(?:^|[^\w/&])#(\d+)\b`)
+	breakingPattern = regexp.MustCompile(`(?m)^BREAKING[ -]CHANGE: `)
+	// squashPattern matches the pull request reference GitHub appends to
+	// squash-merged subjects, which the changelog links on its own
+	squashPattern = regexp.MustCompile(`\s*\(#\d+\)$`)
+)
+
+// Entry is one commit of a changelog
+type Entry struct {
+	// Type is the lower-cased Conventional Commit type, "" for commits not
+	// following the format
+	Type        string
+	Scope       string
+	Description string
+	Breaking    bool
+	// Issues are the issue and pull request numbers the message references
+	// as #N, in order of first reference
+	Issues []int
+	SHA    string
+	Author string
+}
+
+// Parse reads the first line of a commit message as a Conventional Commit
+// header. Messages not following the format become an entry without a type
+// described by their first line.
+func Parse(message string) Entry {
+	subject, body, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	subject = strings.TrimSpace(subject)
+	entry := Entry{Description: subject}
+	if m := headerPattern.FindStringSubmatch(subject); m != nil {
+		entry.Type = strings.ToLower(m[1])
+		entry.Scope = m[2]
+		entry.Breaking = m[3] != ""
+		entry.Description = strings.TrimSpace(m[4])
+	}
+	entry.Description = squashPattern.ReplaceAllString(entry.Description, "")
+	if breakingPattern.MatchString(body) {
+		entry.Breaking = true
+	}
+
+	seen := map[int]bool{}
+	for _, m := range issuePattern.FindAllStringSubmatch(message, -1) {
+		n, err := strconv.Atoi(m[1])
+		if err == nil && !seen[n] {
+			seen[n] = true
+			entry.Issues = append(entry.Issues, n)
+		}
+	}
+	return entry
+}
+
+// Section is the entries of one commit type
+type Section struct {
+	Type    string
+	Title   string
+	Entries []Entry
+}
+
+// Changelog is the changes between two refs
+type Changelog struct {
+	From string
+	To   string
+	// RepoURL is the HTML URL of the repository issue references and
+	// commits link to; without it they are not linked
+	RepoURL string
+	// Breaking lists the breaking changes, which also appear in their
+	// sections
+	Breaking []Entry
+	// Sections holds the non-empty sections, in the order of Sections
+	Sections []Section
+}
+
+// Build groups commits, oldest first, into a changelog. Merge commits are
+// left out: the changes they merge are listed themselves.
+func Build(from, to, repoURL string, commits []models.Commit) *Changelog {
+	c := &Changelog{From: from, To: to, RepoURL: strings.TrimSuffix(repoURL, "/")}
+	known := map[string]bool{}
+	for _, s := range Sections {
+		known[s.Type] = true
+	}
+	grouped := map[string][]Entry{}
+	for _, commit := range commits {
+		if len(commit.Parents) > 1 || strings.HasPrefix(commit.Message, "Merge ") {
+			continue
+		}
+		entry := Parse(commit.Message)
+		entry.SHA = commit.SHA
+		entry.Author = commit.AuthorName
+		if entry.Breaking {
+			c.Breaking = append(c.Breaking, entry)
+		}
+		key := entry.Type
+		if !known[key] {
+			// Keep the unknown type visible among the other changes
+			if key != "" {
+				entry.Description = key + ": " + entry.Description
+			}
+			key = ""
+		}
+		grouped[key] = append(grouped[key], entry)
+	}
+
+	for _, s := range Sections {
+		if entries := grouped[s.Type]; len(entries) > 0 {
+			c.Sections = append(c.Sections, Section{Type: s.Type, Title: s.Title, Entries: entries})
+		}
+	}
+	if entries := grouped[""]; len(entries) > 0 {
+		c.Sections = append(c.Sections, Section{Title: otherTitle, Entries: entries})
+	}
+	return c
+}
+
+// Markdown writes the changelog as Markdown
+func (c *Changelog) Markdown(w io.Writer) error {
+	var b strings.Builder
+	if c.From == "" {
+		fmt.Fprintf(&b, "## %s\n", c.To)
+	} else {
+		fmt.Fprintf(&b, "## %s (since %s)\n", c.To, c.From)
+	}
+	if len(c.Breaking) > 0 {
+		b.WriteString("\n### Breaking Changes\n\n")
+		for _, e := range c.Breaking {
+			b.WriteString(entryLine(e, c.RepoURL))
+		}
+	}
+	for _, s := range c.Sections {
+		fmt.Fprintf(&b, "\n### %s\n\n", s.Title)
+		for _, e := range s.Entries {
+			b.WriteString(entryLine(e, c.RepoURL))
+		}
+	}
+	if len(c.Sections) == 0 {
+		b.WriteString("\nNo changes.\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// entryLine renders an entry as a list item
+func entryLine(e Entry, repoURL string) string {
+	var b strings.Builder
+	b.WriteString("- ")
+	if e.Scope != "" {
+		fmt.Fprintf(&b, "**%s:** ", e.Scope)
+	}
+	b.WriteString(e.Description)
+	for _, n := range e.Issues {
+		if repoURL == "" {
+			fmt.Fprintf(&b, " (#%d)", n)
+		} else {
+			fmt.Fprintf(&b, " ([#%d](%s/issues/%d))", n, repoURL, n)
+		}
+	}
+	short := e.SHA
+	if len(short) > 7 {
+		short = short[:7]
+	}
+	switch {
+	case short == "":
+	case repoURL == "":
+		fmt.Fprintf(&b, " (%s)", short)
+	default:
+		fmt.Fprintf(&b, " ([%s](%s/commit/%s))", short, repoURL, e.SHA)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
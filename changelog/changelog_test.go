@@ -0,0 +1,72 @@
+package changelog
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"githubapifetch/models"
+)
+
+func TestParse(t *testing.T) {
+	for message, want := range map[string]Entry{
+		"feat(api): add release stats (#42)": {Type: "feat", Scope: "api", Description: "add release stats", Issues: []int{42}},
+		"Fix!: drop v1 endpoints":            {Type: "fix", Description: "drop v1 endpoints", Breaking: true},
+		"refactor: split sync\n\nBREAKING CHANGE: options moved\nCloses #7, refs #9 and #7": {
+			Type: "refactor", Description: "split sync", Breaking: true, Issues: []int{7, 9},
+		},
+		"Update README":                         {Description: "Update README"},
+		"fix crash in octo/hello#3 and a&#39;b": {Description: "fix crash in octo/hello#3 and a&#39;b"},
+	} {
+		assert.Equal(t, want, Parse(message), message)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	commits := []models.Commit{
+		{SHA: "aaaaaaaaaa", Message: "feat: add changelog (#12)", AuthorName: "Ada"},
+		{SHA: "bbbbbbbbbb", Message: "Merge pull request #12 from octo/changelog"},
+		{SHA: "cccccccccc", Message: "fix(cli)!: rename flags"},
+		{SHA: "dddddddddd", Message: "wip"},
+		{SHA: "eeeeeeeeee", Message: "style: gofmt"},
+		{SHA: "ffffffffff", Message: "feat: add cadence", Parents: []string{"a", "b"}},
+	}
+	c := Build("v1.0.0", "v1.1.0", "https://github.com/octo/hello/", commits)
+	require.Len(t, c.Sections, 3)
+	assert.Equal(t, "Features", c.Sections[0].Title)
+	assert.Len(t, c.Sections[0].Entries, 1)
+	assert.Equal(t, "Bug Fixes", c.Sections[1].Title)
+	// Unknown types go to the last section with the other changes
+	assert.Equal(t, otherTitle, c.Sections[2].Title)
+	assert.Len(t, c.Sections[2].Entries, 2)
+	require.Len(t, c.Breaking, 1)
+	assert.Equal(t, "cccccccccc", c.Breaking[0].SHA)
+
+	var b strings.Builder
+	require.NoError(t, c.Markdown(&b))
+	assert.Equal(t, `## v1.1.0 (since v1.0.0)
+
+### Breaking Changes
+
+- **cli:** rename flags ([ccccccc](https://github.com/octo/hello/commit/cccccccccc))
+
+### Features
+
+- add changelog ([#12](https://github.com/octo/hello/issues/12)) ([aaaaaaa](https://github.com/octo/hello/commit/aaaaaaaaaa))
+
+### Bug Fixes
+
+- **cli:** rename flags ([ccccccc](https://github.com/octo/hello/commit/cccccccccc))
+
+### Other Changes
+
+- wip ([ddddddd](https://github.com/octo/hello/commit/dddddddddd))
+- style: gofmt ([eeeeeee](https://github.com/octo/hello/commit/eeeeeeeeee))
+`, b.String())
+
+	b.Reset()
+	require.NoError(t, Build("", "v0.1.0", "", nil).Markdown(&b))
+	assert.Equal(t, "## v0.1.0\n\nNo changes.\n", b.String())
+}
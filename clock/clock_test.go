@@ -0,0 +1,65 @@
+package clock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeSleep(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+
+	done := make(chan error, 1)
+	go func() { done <- f.Sleep(context.Background(), time.Minute) }()
+	f.BlockUntil(1)
+
+	f.Advance(59 * time.Second)
+	select {
+	case <-done:
+		t.Fatal("sleep returned before its time")
+	default:
+	}
+	f.Advance(time.Second)
+	require.NoError(t, <-done)
+	assert.Equal(t, start.Add(time.Minute), f.Now())
+
+	// Cancelling stops the wait and drops it from the clock
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() { done <- f.Sleep(ctx, time.Hour) }()
+	f.BlockUntil(1)
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+	f.mu.Lock()
+	assert.Empty(t, f.waiters)
+	f.mu.Unlock()
+}
+
+func TestFakeTicker(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+	ticker := f.NewTicker(10 * time.Second)
+
+	f.Advance(25 * time.Second)
+	// Like time.Ticker, ticks the receiver missed are dropped
+	assert.Equal(t, start.Add(10*time.Second), <-ticker.C())
+	select {
+	case tick := <-ticker.C():
+		t.Fatalf("unexpected tick at %v", tick)
+	default:
+	}
+
+	f.Advance(5 * time.Second)
+	assert.Equal(t, start.Add(30*time.Second), <-ticker.C())
+
+	ticker.Stop()
+	f.Advance(time.Minute)
+	select {
+	case tick := <-ticker.C():
+		t.Fatalf("tick after Stop at %v", tick)
+	default:
+	}
+}
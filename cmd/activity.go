@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"githubapifetch/logger"
+	"githubapifetch/models"
+
+	"go.uber.org/zap"
+)
+
+// runActivity prints the latest commits, releases and merged pull requests
+// across the tracked repositories, newest first
+func runActivity(args []string) {
+	activityCmd := flag.NewFlagSet("activity", flag.ExitOnError)
+	limit := activityCmd.Int("limit", 20, "Number of events to print")
+	cursorFlag := activityCmd.String("cursor", "", "Cursor of the page to print, as printed after the previous one (defaults to the newest events)")
+	asJSON := activityCmd.Bool("json", false, "Print the page as JSON")
+	tenantName := activityCmd.String("tenant", "", "Tenant to report on (defaults to the default tenant)")
+
+	if err := activityCmd.Parse(args); err != nil {
+		logger.Fatal("Failed to parse activity command", zap.Error(err))
+	}
+	cursor, err := models.ParseActivityCursor(*cursorFlag)
+	if err != nil {
+		logger.Fatal("Invalid -cursor", zap.Error(err))
+	}
+
+	svc, ctx := offlineAdminService(*tenantName)
+	defer svc.Close()
+
+	page, err := svc.ActivityFeed(ctx, cursor, *limit)
+	if err != nil {
+		logger.Fatal("Failed to read activity feed", zap.Error(err))
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(page); err != nil {
+			logger.Fatal("Failed to write activity feed", zap.Error(err))
+		}
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "WHEN\tKIND\tREPO\tREF\tTITLE\tBY")
+	for _, e := range page.Events {
+		ref := e.Ref
+		switch e.Kind {
+		case models.ActivityCommit:
+			ref = ref[:min(len(ref), 7)]
+		case models.ActivityPullRequestMerged:
+			ref = "#" + ref
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s/%s\t%s\t%s\t%s\n", e.At.UTC().Format(time.DateTime), e.Kind, e.RepoOwner, e.RepoName, ref, e.Title, e.Actor)
+	}
+	w.Flush()
+	if page.NextCursor != "" {
+		fmt.Printf("\nMore: activity -cursor %s\n", page.NextCursor)
+	}
+}
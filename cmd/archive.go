@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"githubapifetch/logger"
+
+	"go.uber.org/zap"
+)
+
+// runImportArchive warm-starts the tracked repositories from GH Archive
+// dumps, in the order given, and prints a summary of each
+func runImportArchive(args []string) {
+	importCmd := flag.NewFlagSet("import-archive", flag.ExitOnError)
+	var files []string
+	importCmd.Func("file", "GH Archive dump or BigQuery export, gzipped or not (repeatable)", func(s string) error {
+		files = append(files, s)
+		return nil
+	})
+	tenantName := importCmd.String("tenant", "", "Tenant owning the repositories (defaults to the default tenant)")
+
+	if err := importCmd.Parse(args); err != nil {
+		logger.Fatal("Failed to parse import-archive command", zap.Error(err))
+	}
+
+	if len(files) == 0 {
+		logger.Fatal("Archive file is required",
+			zap.String("usage", "import-archive -file <2024-01-01-0.json.gz> [-file ...] [-tenant <name>]"))
+	}
+
+	svc, ctx := adminService(*tenantName)
+	defer svc.Close()
+
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			logger.Fatal("Failed to open archive file", zap.Error(err))
+		}
+		report, err := svc.ImportArchive(ctx, f, file)
+		f.Close()
+		if err != nil {
+			logger.Fatal("Failed to import archive", zap.Error(err))
+		}
+		fmt.Printf("%s: %d events, %d pushes to tracked repositories, %d commits for %d repositories\n",
+			file, report.Events, report.Pushes, report.Commits, report.Repositories)
+	}
+}
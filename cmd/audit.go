@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"githubapifetch/logger"
+
+	"go.uber.org/zap"
+)
+
+// runAuditLog prints recent administrative actions
+func runAuditLog(args []string) {
+	auditLogCmd := flag.NewFlagSet("audit-log", flag.ExitOnError)
+	action := auditLogCmd.String("action", "", "Only show entries for this action (e.g. reset-sync)")
+	limit := auditLogCmd.Int("limit", 50, "Maximum number of entries to show")
+	tenantName := auditLogCmd.String("tenant", "", "Tenant to show entries for (defaults to the default tenant)")
+
+	if err := auditLogCmd.Parse(args); err != nil {
+		logger.Fatal("Failed to parse audit-log command", zap.Error(err))
+	}
+
+	svc, ctx := adminService(*tenantName)
+	defer svc.Close()
+
+	entries, err := svc.AuditLog(ctx, *action, *limit)
+	if err != nil {
+		logger.Fatal("Failed to read audit log", zap.Error(err))
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tACTOR\tTYPE\tACTION\tPARAMETERS")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			e.CreatedAt.Format("2006-01-02 15:04:05"), e.Actor, e.ActorType, e.Action, e.Parameters)
+	}
+	w.Flush()
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"time"
+
+	"githubapifetch/logger"
+
+	"go.uber.org/zap"
+)
+
+// runChanges prints the rows of a table changed since a point in time, one
+// JSON object per line, for incremental extraction
+func runChanges(args []string) {
+	changesCmd := flag.NewFlagSet("changes", flag.ExitOnError)
+	table := changesCmd.String("table", "", "Table to extract, e.g. commits")
+	sinceFlag := changesCmd.String("since", "", "Extract rows changed at or after this RFC 3339 time (defaults to every row)")
+	afterKey := changesCmd.String("after-key", "", "Skip rows changed exactly at -since up to and including this key")
+	limit := changesCmd.Int("limit", 1000, "Maximum number of rows to extract")
+
+	if err := changesCmd.Parse(args); err != nil {
+		logger.Fatal("Failed to parse changes command", zap.Error(err))
+	}
+	if *table == "" {
+		logger.Fatal("Table is required", zap.String("usage", "changes -table <name> [-since <time>] [-after-key <key>] [-limit <n>]"))
+	}
+	var since time.Time
+	if *sinceFlag != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339Nano, *sinceFlag); err != nil {
+			logger.Fatal("Invalid -since, expected an RFC 3339 time", zap.String("since", *sinceFlag), zap.Error(err))
+		}
+	}
+
+	svc, ctx := offlineAdminService("")
+	defer svc.Close()
+
+	changes, err := svc.Changes(ctx, *table, since, *afterKey, *limit)
+	if err != nil {
+		logger.Fatal("Failed to read changes", zap.Error(err))
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	for _, c := range changes {
+		if err := enc.Encode(c); err != nil {
+			logger.Fatal("Failed to write changes", zap.Error(err))
+		}
+	}
+	if len(changes) > 0 {
+		last := changes[len(changes)-1]
+		logger.Info("Read changes",
+			zap.Int("rows", len(changes)),
+			zap.String("next_since", last.UpdatedAt.Format(time.RFC3339Nano)),
+			zap.String("next_after_key", last.Key))
+	}
+}
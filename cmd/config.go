@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"githubapifetch/config"
+	"githubapifetch/logger"
+
+	"go.uber.org/zap"
+)
+
+// runConfig handles the config subcommands
+func runConfig(args []string) {
+	if len(args) == 0 || args[0] != "show" {
+		logger.Fatal("Unknown config subcommand", zap.String("usage", "config show"))
+	}
+
+	// Print the configuration even when GitHub settings are missing
+	cfg := config.NewConfig()
+	if err := cfg.LoadOffline(); err != nil {
+		logger.Fatal("Failed to load configuration", zap.Error(err))
+	}
+
+	fmt.Printf("Config file: %s\n", config.ConfigFile())
+	fmt.Printf("Environment prefix: %s\n", config.EnvPrefix())
+	if chain := config.Profile(); len(chain) > 0 {
+		fmt.Printf("Profile: %s\n", strings.Join(chain, " -> "))
+	}
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+	for _, s := range cfg.Settings() {
+		value := s.Value
+		if value == "" {
+			value = "-"
+		}
+		source := string(s.Source)
+		if s.Deprecated {
+			source += " (unprefixed, deprecated)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", s.Key, value, source)
+	}
+	w.Flush()
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"time"
+
+	"githubapifetch/logger"
+	"githubapifetch/service"
+
+	"go.uber.org/zap"
+)
+
+// runRepoDiff prints how a repository changed between two times
+func runRepoDiff(args []string) {
+	diffCmd := flag.NewFlagSet("repo-diff", flag.ExitOnError)
+	repo := diffCmd.String("repo", "", "Repository name")
+	fromFlag := diffCmd.String("from", "", "Start of the period, RFC 3339 or YYYY-MM-DD")
+	toFlag := diffCmd.String("to", "", "End of the period, RFC 3339 or YYYY-MM-DD (defaults to now)")
+	format := diffCmd.String("format", service.DiffFormatText, "Output format: text, json or markdown")
+	tenantName := diffCmd.String("tenant", "", "Tenant owning the repository (defaults to the default tenant)")
+
+	if err := diffCmd.Parse(args); err != nil {
+		logger.Fatal("Failed to parse repo-diff command", zap.Error(err))
+	}
+
+	if *repo == "" || *fromFlag == "" {
+		logger.Fatal("Repository and start of the period are required",
+			zap.String("usage", "repo-diff -repo <name> -from <time> [-to <time>] [-format text|json|markdown] [-tenant <name>]"))
+	}
+	from := parseTimestamp(*fromFlag, "from")
+	to := time.Now().UTC()
+	if *toFlag != "" {
+		to = parseTimestamp(*toFlag, "to")
+	}
+
+	svc, ctx := adminService(*tenantName)
+	defer svc.Close()
+
+	diff, err := svc.RepositoryDiff(ctx, *repo, from, to)
+	if err != nil {
+		logger.Fatal("Failed to compute repository diff", zap.Error(err))
+	}
+	if err := service.WriteRepositoryDiff(os.Stdout, diff, *format); err != nil {
+		logger.Fatal("Failed to write repository diff", zap.Error(err))
+	}
+}
+
+// parseTimestamp parses an RFC 3339 or YYYY-MM-DD flag value, the latter as
+// midnight UTC
+func parseTimestamp(value, flagName string) time.Time {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t
+	}
+	return parseDay(value, flagName)
+}
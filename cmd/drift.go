@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"githubapifetch/logger"
+
+	"go.uber.org/zap"
+)
+
+// runCheckDrift compares a sample of the stored commits of a tenant's
+// repositories against GitHub and prints how far they drifted
+func runCheckDrift(args []string) {
+	driftCmd := flag.NewFlagSet("check-drift", flag.ExitOnError)
+	sample := driftCmd.Int("sample", 0, "Commits to sample per repository (defaults to DRIFT_SAMPLE_SIZE)")
+	tenantName := driftCmd.String("tenant", "", "Tenant to check (defaults to the default tenant)")
+
+	if err := driftCmd.Parse(args); err != nil {
+		logger.Fatal("Failed to parse check-drift command", zap.Error(err))
+	}
+
+	svc, ctx := adminService(*tenantName)
+	defer svc.Close()
+
+	report, err := svc.CheckDrift(ctx, *sample)
+	if err != nil {
+		logger.Fatal("Failed to check drift", zap.Error(err))
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "REPOSITORY\tSAMPLED\tMISSING\tMISMATCHED\tDRIFT\tFIELDS\tERROR")
+	failed := 0
+	for _, repo := range report.Repos {
+		if repo.Err != nil {
+			failed++
+			fmt.Fprintf(w, "%s/%s\t-\t-\t-\t-\t-\t%s\n", repo.Owner, repo.Name, repo.Err)
+			continue
+		}
+		fields := make([]string, 0, len(repo.Fields))
+		for field, n := range repo.Fields {
+			fields = append(fields, fmt.Sprintf("%s=%d", field, n))
+		}
+		slices.Sort(fields)
+		fmt.Fprintf(w, "%s/%s\t%d\t%d\t%d\t%.1f%%\t%s\t\n", repo.Owner, repo.Name,
+			repo.Sampled, repo.Missing, repo.Mismatched, repo.Percent(), strings.Join(fields, ","))
+	}
+	w.Flush()
+
+	fmt.Printf("\nDrift: %.1f%% of sampled commits\n", report.Percent())
+	if failed > 0 {
+		fmt.Printf("%d of %d repositories could not be checked\n", failed, len(report.Repos))
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"githubapifetch/logger"
+	"githubapifetch/models"
+
+	"go.uber.org/zap"
+)
+
+// runDump writes the whole database to an archive file
+func runDump(args []string) {
+	dumpCmd := flag.NewFlagSet("dump", flag.ExitOnError)
+	file := dumpCmd.String("file", "", "Archive to write, e.g. githubapifetch.dump.gz")
+
+	if err := dumpCmd.Parse(args); err != nil {
+		logger.Fatal("Failed to parse dump command", zap.Error(err))
+	}
+	if *file == "" {
+		logger.Fatal("Archive file is required", zap.String("usage", "dump -file <path>"))
+	}
+
+	svc, ctx := adminService("")
+	defer svc.Close()
+
+	f, err := os.Create(*file)
+	if err != nil {
+		logger.Fatal("Failed to create archive", zap.Error(err))
+	}
+	tables, err := svc.Dump(ctx, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(*file)
+		logger.Fatal("Failed to dump database", zap.Error(err))
+	}
+
+	printTables(tables)
+	logger.Info("Successfully dumped database", zap.String("file", *file))
+}
+
+// runRestore loads an archive written by dump into the database
+func runRestore(args []string) {
+	restoreCmd := flag.NewFlagSet("restore", flag.ExitOnError)
+	file := restoreCmd.String("file", "", "Archive written by dump")
+	replace := restoreCmd.Bool("replace", false, "Replace the data of a database that already holds repositories")
+
+	if err := restoreCmd.Parse(args); err != nil {
+		logger.Fatal("Failed to parse restore command", zap.Error(err))
+	}
+	if *file == "" {
+		logger.Fatal("Archive file is required", zap.String("usage", "restore -file <path> [-replace]"))
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		logger.Fatal("Failed to open archive", zap.Error(err))
+	}
+	defer f.Close()
+
+	svc, ctx := adminService("")
+	defer svc.Close()
+
+	tables, err := svc.Restore(ctx, f, *replace)
+	if err != nil {
+		logger.Fatal("Failed to restore database", zap.Error(err))
+	}
+
+	printTables(tables)
+	logger.Info("Successfully restored database", zap.String("file", *file))
+}
+
+// printTables prints the row count of every table of an archive
+func printTables(tables []models.DumpTable) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TABLE\tROWS")
+	for _, t := range tables {
+		fmt.Fprintf(w, "%s\t%d\n", t.Table, t.Rows)
+	}
+	w.Flush()
+}
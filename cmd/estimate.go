@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"githubapifetch/logger"
+
+	"go.uber.org/zap"
+)
+
+// runEstimate prints the GitHub API requests a tenant's repositories cost,
+// or those listed in an import file, to plan tokens before adding them
+func runEstimate(args []string) {
+	estimateCmd := flag.NewFlagSet("estimate", flag.ExitOnError)
+	file := estimateCmd.String("file", "", "CSV file with owner,name,start_date,interval rows, as for import-repos (defaults to the tracked repositories)")
+	tenantName := estimateCmd.String("tenant", "", "Tenant whose token and settings to use (defaults to the default tenant)")
+
+	if err := estimateCmd.Parse(args); err != nil {
+		logger.Fatal("Failed to parse estimate command", zap.Error(err))
+	}
+
+	var r io.Reader
+	if *file != "" {
+		f, err := os.Open(*file)
+		if err != nil {
+			logger.Fatal("Failed to open repository file", zap.Error(err))
+		}
+		defer f.Close()
+		r = f
+	}
+
+	svc, ctx := adminService(*tenantName)
+	defer svc.Close()
+
+	estimate, err := svc.EstimateRequests(ctx, r)
+	if err != nil {
+		logger.Fatal("Failed to estimate requests", zap.Error(err))
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "REPOSITORY\tSINCE\tCOMMITS\tBACKFILL\tPOLL\tPER HOUR\tERROR")
+	failed := 0
+	for _, repo := range estimate.Repos {
+		if repo.Err != nil {
+			failed++
+			fmt.Fprintf(w, "%s/%s\t-\t-\t-\t-\t-\t%s\n", repo.Owner, repo.Name, repo.Err)
+			continue
+		}
+		fmt.Fprintf(w, "%s/%s\t%s\t%d\t%d\t%ds\t%.1f\t\n", repo.Owner, repo.Name,
+			repo.Since.Format(time.DateOnly), repo.Commits, repo.Backfill, repo.PollInterval, repo.PerHour)
+	}
+	w.Flush()
+
+	fmt.Printf("\nBackfill: %d requests\n", estimate.Backfill())
+	fmt.Printf("Keeping current: %.0f requests per hour\n", estimate.PerHour())
+	fmt.Printf("Token limit: %d requests per hour\n", estimate.HourlyLimit)
+	if estimate.HourlyLimit > 0 {
+		fmt.Printf("Tokens needed to keep current: %.0f\n", math.Max(1, math.Ceil(estimate.PerHour()/float64(estimate.HourlyLimit))))
+	}
+	if hours := estimate.BackfillHours(); math.IsInf(hours, 1) {
+		fmt.Println("Keeping the repositories current alone exceeds the token's limit")
+	} else {
+		fmt.Printf("Backfill time at the limit: %.1f hours\n", hours)
+	}
+	if failed > 0 {
+		fmt.Printf("\n%d of %d repositories could not be looked up and are left out\n", failed, len(estimate.Repos))
+		os.Exit(1)
+	}
+}
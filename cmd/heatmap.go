@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"time"
+
+	"githubapifetch/logger"
+
+	"go.uber.org/zap"
+)
+
+// runHeatmap prints the commit punch card of a tenant's repositories as JSON
+func runHeatmap(args []string) {
+	heatmapCmd := flag.NewFlagSet("heatmap", flag.ExitOnError)
+	repo := heatmapCmd.String("repo", "", "Repository name (defaults to every repository)")
+	author := heatmapCmd.String("author", "", "Author name (defaults to every author)")
+	sinceFlag := heatmapCmd.String("since", "", "Start of the period, RFC 3339 or YYYY-MM-DD (defaults to a year before -until)")
+	untilFlag := heatmapCmd.String("until", "", "End of the period, RFC 3339 or YYYY-MM-DD (defaults to now)")
+	tz := heatmapCmd.String("tz", "UTC", "Time zone of the hours, such as Europe/Berlin")
+	tenantName := heatmapCmd.String("tenant", "", "Tenant to report on (defaults to the default tenant)")
+
+	if err := heatmapCmd.Parse(args); err != nil {
+		logger.Fatal("Failed to parse heatmap command", zap.Error(err))
+	}
+
+	until := time.Now().UTC()
+	if *untilFlag != "" {
+		until = parseTimestamp(*untilFlag, "until")
+	}
+	since := until.AddDate(-1, 0, 0)
+	if *sinceFlag != "" {
+		since = parseTimestamp(*sinceFlag, "since")
+	}
+
+	svc, ctx := adminService(*tenantName)
+	defer svc.Close()
+
+	heatmap, err := svc.CommitHeatmap(ctx, *repo, *author, since, until, *tz)
+	if err != nil {
+		logger.Fatal("Failed to compute heatmap", zap.Error(err))
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(heatmap); err != nil {
+		logger.Fatal("Failed to write heatmap", zap.Error(err))
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"githubapifetch/logger"
+
+	"go.uber.org/zap"
+)
+
+// runImportRepos registers the repositories listed in a CSV file and prints
+// a summary; invalid rows are listed and nothing is registered
+func runImportRepos(args []string) {
+	importCmd := flag.NewFlagSet("import-repos", flag.ExitOnError)
+	file := importCmd.String("file", "", "CSV file with owner,name,start_date,interval rows")
+	dryRun := importCmd.Bool("dry-run", false, "Validate the file without registering anything")
+	tenantName := importCmd.String("tenant", "", "Tenant owning the repositories (defaults to the default tenant)")
+
+	if err := importCmd.Parse(args); err != nil {
+		logger.Fatal("Failed to parse import-repos command", zap.Error(err))
+	}
+
+	if *file == "" {
+		logger.Fatal("Import file is required",
+			zap.String("usage", "import-repos -file <repos.csv> [-dry-run] [-tenant <name>]"))
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		logger.Fatal("Failed to open import file", zap.Error(err))
+	}
+	defer f.Close()
+
+	svc, ctx := adminService(*tenantName)
+	defer svc.Close()
+
+	report, err := svc.ImportRepositories(ctx, f, *dryRun)
+	if err != nil {
+		logger.Fatal("Failed to import repositories", zap.Error(err))
+	}
+
+	for _, rowErr := range report.Errors {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", *file, rowErr.Error())
+	}
+	switch {
+	case len(report.Errors) > 0:
+		fmt.Printf("%d rows, %d invalid; nothing imported\n", report.Rows, len(report.Errors))
+		os.Exit(1)
+	case *dryRun:
+		fmt.Printf("%d rows, all valid; nothing imported (dry run)\n", report.Rows)
+	default:
+		fmt.Printf("%d rows: %d added, %d already tracked\n", report.Rows, report.Added, report.Existing)
+	}
+}
+
+// runDiscoverStarred registers the repositories starred by the user of the
+// tenant's token and prints a summary
+func runDiscoverStarred(args []string) {
+	discoverCmd := flag.NewFlagSet("discover-starred", flag.ExitOnError)
+	tenantName := discoverCmd.String("tenant", "", "Tenant whose token's stars are registered (defaults to the default tenant)")
+
+	if err := discoverCmd.Parse(args); err != nil {
+		logger.Fatal("Failed to parse discover-starred command", zap.Error(err))
+	}
+
+	svc, ctx := adminService(*tenantName)
+	defer svc.Close()
+
+	report, err := svc.DiscoverStarred(ctx)
+	if err != nil {
+		logger.Fatal("Failed to discover starred repositories", zap.Error(err))
+	}
+	fmt.Printf("%d starred repositories: %d added, %d already tracked\n", report.Starred, report.Added, report.Existing)
+}
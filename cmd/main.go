@@ -1,11 +1,12 @@
 package main
 
 import (
-	"context"
 	"flag"
+	"fmt"
 	"os"
-	"time"
+	"strings"
 
+	"githubapifetch/config"
 	"githubapifetch/logger"
 	"githubapifetch/service"
 
@@ -19,13 +20,30 @@ func main() {
 	}
 	defer logger.Sync()
 
-	// Define command flags
-	resetSyncCmd := flag.NewFlagSet("reset-sync", flag.ExitOnError)
-	repoName := resetSyncCmd.String("repo", "", "Repository name to reset sync point for")
-	daysAgo := resetSyncCmd.Int("days", 30, "Number of days ago to reset sync point to")
+	// Global flags come before the command and configure every command
+	globalFlags := flag.NewFlagSet("githubapifetch", flag.ExitOnError)
+	configFile := globalFlags.String("config", config.DefaultConfigFile, "Config file of KEY=VALUE lines")
+	envPrefix := globalFlags.String("env-prefix", config.DefaultEnvPrefix, "Prefix of the environment variables read as settings; empty reads bare keys")
+	profile := globalFlags.String("profile", "", "Config file profile to apply, e.g. staging (defaults to $"+config.ProfileEnv+")")
+	globalFlags.Func("mode", "Run the service as "+config.RunModeAll+" (default), "+config.RunModeFetchOnly+" or "+config.RunModeIngestOnly+"; shorthand for -set RUN_MODE=<mode>", func(s string) error {
+		return config.SetFlag("RUN_MODE", s)
+	})
+	globalFlags.Func("set", "Set a configuration key as KEY=VALUE, overriding its environment variable and config file entry (repeatable)", func(s string) error {
+		key, value, ok := strings.Cut(s, "=")
+		if !ok {
+			return fmt.Errorf("want KEY=VALUE, got %q", s)
+		}
+		return config.SetFlag(key, value)
+	})
+	if err := globalFlags.Parse(os.Args[1:]); err != nil {
+		logger.Fatal("Failed to parse flags", zap.Error(err))
+	}
+	config.SetConfigFile(*configFile)
+	config.SetEnvPrefix(*envPrefix)
+	config.SetProfile(*profile)
 
 	// Check if a command was provided
-	if len(os.Args) < 2 {
+	if globalFlags.NArg() == 0 {
 		// If no command provided, start the service normally
 		svc, err := service.NewService()
 		if err != nil {
@@ -39,49 +57,87 @@ func main() {
 		return
 	}
 
+	// Skip the command name
+	command := globalFlags.Arg(0)
+	args := globalFlags.Args()[1:]
+
 	// Parse the command
-	switch os.Args[1] {
+	switch command {
 	case "reset-sync":
-		// Skip the program name and command name
-		args := os.Args[2:]
-
-		// Parse flags
-		if err := resetSyncCmd.Parse(args); err != nil {
-			logger.Fatal("Failed to parse reset-sync command", zap.Error(err))
-		}
-
-		// Validate required flags
-		if *repoName == "" {
-			logger.Fatal("Repository name is required",
-				zap.String("usage", "reset-sync -repo <repo-name> [-days <number>]"),
-				zap.Strings("args", args))
-		}
-
-		// Initialize service
-		svc, err := service.NewService()
-		if err != nil {
-			logger.Fatal("Failed to initialize service", zap.Error(err))
-		}
-		defer svc.Close()
-
-		// Calculate the new sync point date
-		newDate := time.Now().Add(-time.Duration(*daysAgo) * 24 * time.Hour)
-		logger.Info("Resetting sync point",
-			zap.String("repo", *repoName),
-			zap.Time("new_date", newDate),
-			zap.Int("days_ago", *daysAgo),
-			zap.Strings("parsed_args", args))
-
-		// Reset sync point
-		if err := svc.ResetSyncPoint(context.Background(), *repoName, newDate); err != nil {
-			logger.Fatal("Failed to reset sync point", zap.Error(err))
-		}
-
-		logger.Info("Successfully reset sync point",
-			zap.String("repo", *repoName),
-			zap.Time("new_date", newDate))
-
+		runResetSync(args)
+	case "add-tenant":
+		runAddTenant(args)
+	case "list-tenants":
+		runListTenants(args)
+	case "encrypt-secrets":
+		runEncryptSecrets(args)
+	case "add-repo":
+		runAddRepo(args)
+	case "import-repos":
+		runImportRepos(args)
+	case "import-archive":
+		runImportArchive(args)
+	case "discover-starred":
+		runDiscoverStarred(args)
+	case "remove-repo", "pause-repo", "resume-repo", "requeue-repo":
+		runSetRepoStatus(command, args)
+	case "purge-repo":
+		runPurgeRepo(args)
+	case "set-group":
+		runSetGroup(args)
+	case "list-repos":
+		runListRepos(args)
+	case "audit-log":
+		runAuditLog(args)
+	case "replay":
+		runReplay(args)
+	case "reconcile":
+		runReconcile(args)
+	case "check-drift":
+		runCheckDrift(args)
+	case "preflight":
+		runPreflight(args)
+	case "sync":
+		runSync(args)
+	case "set-path-filter":
+		runSetPathFilter(args)
+	case "remove-path-filter":
+		runRemovePathFilter(args)
+	case "list-path-filters":
+		runListPathFilters(args)
+	case "knowledge-report":
+		runKnowledgeReport(args)
+	case "repo-diff":
+		runRepoDiff(args)
+	case "heatmap":
+		runHeatmap(args)
+	case "repo-stats":
+		runRepoStats(args)
+	case "rollup-report":
+		runRollupReport(args)
+	case "activity":
+		runActivity(args)
+	case "ownership-report":
+		runOwnershipReport(args)
+	case "pr-report":
+		runPullRequestReport(args)
+	case "changelog":
+		runChangelog(args)
+	case "release-cadence":
+		runReleaseCadence(args)
+	case "estimate":
+		runEstimate(args)
+	case "dump":
+		runDump(args)
+	case "restore":
+		runRestore(args)
+	case "seed":
+		runSeed(args)
+	case "changes":
+		runChanges(args)
+	case "config":
+		runConfig(args)
 	default:
-		logger.Fatal("Unknown command", zap.String("command", os.Args[1]))
+		logger.Fatal("Unknown command", zap.String("command", command))
 	}
 }
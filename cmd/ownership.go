@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"githubapifetch/logger"
+
+	"go.uber.org/zap"
+)
+
+// runOwnershipReport prints the top committers of a repository's busiest
+// paths, optionally next to the owners its CODEOWNERS file declares
+func runOwnershipReport(args []string) {
+	reportCmd := flag.NewFlagSet("ownership-report", flag.ExitOnError)
+	repo := reportCmd.String("repo", "", "Repository name")
+	sinceFlag := reportCmd.String("since", "", "Start of the period, RFC 3339 or YYYY-MM-DD (defaults to a year before -until)")
+	untilFlag := reportCmd.String("until", "", "End of the period, RFC 3339 or YYYY-MM-DD (defaults to now)")
+	depth := reportCmd.Int("depth", 2, "Directory levels of the path prefixes")
+	top := reportCmd.Int("top", 3, "Committers listed per prefix")
+	limit := reportCmd.Int("limit", 20, "Busiest prefixes listed")
+	compare := reportCmd.Bool("codeowners", false, "Compare against the repository's CODEOWNERS file on GitHub")
+	tenantName := reportCmd.String("tenant", "", "Tenant owning the repository (defaults to the default tenant)")
+
+	if err := reportCmd.Parse(args); err != nil {
+		logger.Fatal("Failed to parse ownership-report command", zap.Error(err))
+	}
+
+	if *repo == "" {
+		logger.Fatal("Repository is required",
+			zap.String("usage", "ownership-report -repo <name> [-since <date>] [-until <date>] [-depth 2] [-top 3] [-limit 20] [-codeowners] [-tenant <name>]"))
+	}
+
+	until := time.Now().UTC()
+	if *untilFlag != "" {
+		until = parseTimestamp(*untilFlag, "until")
+	}
+	since := until.AddDate(-1, 0, 0)
+	if *sinceFlag != "" {
+		since = parseTimestamp(*sinceFlag, "since")
+	}
+
+	svc, ctx := adminService(*tenantName)
+	defer svc.Close()
+
+	report, err := svc.OwnershipReport(ctx, *repo, since, until, *depth, *top, *limit, *compare)
+	if err != nil {
+		logger.Fatal("Failed to compute ownership report", zap.Error(err))
+	}
+
+	fmt.Printf("%s/%s, commits from %s to %s\n", report.Owner, report.Name,
+		since.Format(time.RFC3339), until.Format(time.RFC3339))
+	if report.Compared {
+		if report.Codeowners == "" {
+			fmt.Println("No CODEOWNERS file")
+		} else {
+			fmt.Printf("Compared against %s\n", report.Codeowners)
+		}
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	header := "PREFIX\tCOMMITS\tTOP COMMITTERS"
+	if report.Compared {
+		header += "\tCODEOWNERS"
+	}
+	fmt.Fprintln(w, header)
+	for _, p := range report.Paths {
+		prefix := p.Prefix + "/"
+		committers := make([]string, len(p.Committers))
+		for i, c := range p.Committers {
+			committers[i] = fmt.Sprintf("%s (%d)", c.Name, c.Commits)
+		}
+		line := fmt.Sprintf("%s\t%d\t%s", prefix, p.Commits, strings.Join(committers, ", "))
+		if report.Compared {
+			owners := "UNOWNED"
+			if len(p.CodeOwners) > 0 {
+				owners = strings.Join(p.CodeOwners, " ")
+			}
+			line += "\t" + owners
+		}
+		fmt.Fprintln(w, line)
+	}
+	w.Flush()
+
+	if unowned := report.Unowned(); len(unowned) > 0 {
+		fmt.Printf("\n%d of %d busiest paths have no code owners\n", len(unowned), len(report.Paths))
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"githubapifetch/logger"
+
+	"go.uber.org/zap"
+)
+
+// runSetPathFilter adds or replaces a path filter of a repository
+func runSetPathFilter(args []string) {
+	setCmd := flag.NewFlagSet("set-path-filter", flag.ExitOnError)
+	repoName := setCmd.String("repo", "", "Repository name")
+	filter := setCmd.String("filter", "", "Filter name, e.g. payments")
+	pattern := setCmd.String("pattern", "", "Path glob, e.g. services/payments/**")
+	tenantName := setCmd.String("tenant", "", "Tenant owning the repository (defaults to the default tenant)")
+
+	if err := setCmd.Parse(args); err != nil {
+		logger.Fatal("Failed to parse set-path-filter command", zap.Error(err))
+	}
+	if *repoName == "" || *filter == "" || *pattern == "" {
+		logger.Fatal("Repository, filter and pattern are required",
+			zap.String("usage", "set-path-filter -repo <repo-name> -filter <name> -pattern <glob> [-tenant <name>]"))
+	}
+
+	svc, ctx := adminService(*tenantName)
+	defer svc.Close()
+
+	if err := svc.SetPathFilter(ctx, *repoName, *filter, *pattern); err != nil {
+		logger.Fatal("Failed to set path filter", zap.Error(err))
+	}
+	logger.Info("Successfully set path filter",
+		zap.String("repo", *repoName),
+		zap.String("filter", *filter),
+		zap.String("pattern", *pattern))
+}
+
+// runRemovePathFilter removes a path filter of a repository
+func runRemovePathFilter(args []string) {
+	removeCmd := flag.NewFlagSet("remove-path-filter", flag.ExitOnError)
+	repoName := removeCmd.String("repo", "", "Repository name")
+	filter := removeCmd.String("filter", "", "Filter name")
+	tenantName := removeCmd.String("tenant", "", "Tenant owning the repository (defaults to the default tenant)")
+
+	if err := removeCmd.Parse(args); err != nil {
+		logger.Fatal("Failed to parse remove-path-filter command", zap.Error(err))
+	}
+	if *repoName == "" || *filter == "" {
+		logger.Fatal("Repository and filter are required",
+			zap.String("usage", "remove-path-filter -repo <repo-name> -filter <name> [-tenant <name>]"))
+	}
+
+	svc, ctx := adminService(*tenantName)
+	defer svc.Close()
+
+	if err := svc.RemovePathFilter(ctx, *repoName, *filter); err != nil {
+		logger.Fatal("Failed to remove path filter", zap.Error(err))
+	}
+	logger.Info("Successfully removed path filter", zap.String("repo", *repoName), zap.String("filter", *filter))
+}
+
+// runListPathFilters prints the path filters of a repository
+func runListPathFilters(args []string) {
+	listCmd := flag.NewFlagSet("list-path-filters", flag.ExitOnError)
+	repoName := listCmd.String("repo", "", "Repository name")
+	tenantName := listCmd.String("tenant", "", "Tenant owning the repository (defaults to the default tenant)")
+
+	if err := listCmd.Parse(args); err != nil {
+		logger.Fatal("Failed to parse list-path-filters command", zap.Error(err))
+	}
+	if *repoName == "" {
+		logger.Fatal("Repository name is required",
+			zap.String("usage", "list-path-filters -repo <repo-name> [-tenant <name>]"))
+	}
+
+	svc, ctx := adminService(*tenantName)
+	defer svc.Close()
+
+	filters, err := svc.ListPathFilters(ctx, *repoName)
+	if err != nil {
+		logger.Fatal("Failed to list path filters", zap.Error(err))
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "FILTER\tPATTERN\tCREATED")
+	for _, f := range filters {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", f.Name, f.Pattern, f.CreatedAt.Format("2006-01-02 15:04:05"))
+	}
+	w.Flush()
+}
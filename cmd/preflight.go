@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"githubapifetch/logger"
+
+	"go.uber.org/zap"
+)
+
+// runPreflight checks that a tenant's GitHub token can read every repository
+// it syncs, e.g. after rotating the token, and exits non-zero if it cannot
+func runPreflight(args []string) {
+	preflightCmd := flag.NewFlagSet("preflight", flag.ExitOnError)
+	tenantName := preflightCmd.String("tenant", "", "Tenant to check (defaults to the default tenant)")
+
+	if err := preflightCmd.Parse(args); err != nil {
+		logger.Fatal("Failed to parse preflight command", zap.Error(err))
+	}
+
+	svc, ctx := adminService(*tenantName)
+	defer svc.Close()
+
+	report, err := svc.Preflight(ctx)
+	if err != nil {
+		logger.Fatal("Failed to run preflight check", zap.Error(err))
+	}
+	if report.TokenErr != nil {
+		logger.Fatal("GitHub token rejected", zap.Error(report.TokenErr))
+	}
+
+	fmt.Printf("Rate limit: %d of %d requests left, resets %s\n\n",
+		report.RateLimit.Remaining, report.RateLimit.Limit, report.RateLimit.Reset.Format("2006-01-02 15:04:05 MST"))
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "REPOSITORY\tSTATUS\tERROR")
+	for _, repo := range report.Repos {
+		status, reason := "ok", ""
+		if repo.Err != nil {
+			status, reason = "failing", repo.Err.Error()
+		}
+		fmt.Fprintf(w, "%s/%s\t%s\t%s\n", repo.Owner, repo.Name, status, reason)
+	}
+	w.Flush()
+
+	if failed := report.Failed(); len(failed) > 0 {
+		fmt.Printf("\n%d of %d repositories cannot be read\n", len(failed), len(report.Repos))
+		os.Exit(1)
+	}
+}
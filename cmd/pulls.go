@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"githubapifetch/logger"
+	"githubapifetch/models"
+
+	"go.uber.org/zap"
+)
+
+// runPullRequestReport prints how long the pull requests opened in a period
+// waited for review and to be merged, and how large they were
+func runPullRequestReport(args []string) {
+	reportCmd := flag.NewFlagSet("pr-report", flag.ExitOnError)
+	repo := reportCmd.String("repo", "", "Repository name (defaults to every repository)")
+	authorsFlag := reportCmd.String("authors", "", "Comma-separated logins of the authors to report on, such as a team (defaults to everyone)")
+	sinceFlag := reportCmd.String("since", "", "First day of the period, YYYY-MM-DD (defaults to the 90 days up to -until)")
+	untilFlag := reportCmd.String("until", "", "Last day of the period, YYYY-MM-DD (defaults to today)")
+	workingTime := reportCmd.Bool("working-time", false, "Count only the working days of the WORK_* calendar in durations")
+	asJSON := reportCmd.Bool("json", false, "Print the statistics as JSON")
+	tenantName := reportCmd.String("tenant", "", "Tenant to report on (defaults to the default tenant)")
+
+	if err := reportCmd.Parse(args); err != nil {
+		logger.Fatal("Failed to parse pr-report command", zap.Error(err))
+	}
+
+	// The period covers whole days; until is the start of the day after it
+	until := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	if *untilFlag != "" {
+		until = parseDay(*untilFlag, "until").AddDate(0, 0, 1)
+	}
+	since := until.AddDate(0, 0, -90)
+	if *sinceFlag != "" {
+		since = parseDay(*sinceFlag, "since")
+	}
+	var authors []string
+	for _, author := range strings.Split(*authorsFlag, ",") {
+		if author = strings.TrimSpace(author); author != "" {
+			authors = append(authors, author)
+		}
+	}
+
+	svc, ctx := adminService(*tenantName)
+	defer svc.Close()
+
+	stats, err := svc.PullRequestStats(ctx, *repo, authors, since, until, *workingTime)
+	if err != nil {
+		logger.Fatal("Failed to compute pull request report", zap.Error(err))
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(stats); err != nil {
+			logger.Fatal("Failed to write pull request report", zap.Error(err))
+		}
+		return
+	}
+
+	scope := "All repositories"
+	if stats.Repo != "" {
+		scope = stats.Repo
+	}
+	if len(authors) > 0 {
+		scope += ", by " + strings.Join(authors, ", ")
+	}
+	fmt.Printf("%s, pull requests opened from %s to %s\n\n", scope,
+		since.Format(time.DateOnly), until.AddDate(0, 0, -1).Format(time.DateOnly))
+	fmt.Printf("Opened: %d, reviewed: %d, merged: %d\n\n", stats.Opened, stats.Reviewed, stats.Merged)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	if stats.Calendar != nil {
+		fmt.Fprintln(w, "WORKING HOURS\tP50\tP75\tP90")
+	} else {
+		fmt.Fprintln(w, "HOURS\tP50\tP75\tP90")
+	}
+	for _, row := range []struct {
+		name        string
+		percentiles *models.DurationPercentiles
+	}{
+		{"To first review", stats.TimeToFirstReview},
+		{"To merge", stats.TimeToMerge},
+	} {
+		if row.percentiles == nil {
+			fmt.Fprintf(w, "%s\t-\t-\t-\n", row.name)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%.1f\t%.1f\t%.1f\n", row.name, row.percentiles.P50, row.percentiles.P75, row.percentiles.P90)
+	}
+	w.Flush()
+	fmt.Println()
+
+	w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "LINES CHANGED\tPULL REQUESTS")
+	for _, size := range stats.Sizes {
+		lines := fmt.Sprintf("%d+", size.MinLines)
+		if size.MaxLines > 0 {
+			lines = fmt.Sprintf("%d-%d", size.MinLines, size.MaxLines-1)
+		}
+		fmt.Fprintf(w, "%s\t%d\n", lines, size.PullRequests)
+	}
+	w.Flush()
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"flag"
+
+	"githubapifetch/logger"
+
+	"go.uber.org/zap"
+)
+
+// runReconcile checks stored repositories for renames and transfers on GitHub
+func runReconcile(args []string) {
+	reconcileCmd := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	tenantName := reconcileCmd.String("tenant", "", "Tenant to reconcile (defaults to the default tenant)")
+
+	if err := reconcileCmd.Parse(args); err != nil {
+		logger.Fatal("Failed to parse reconcile command", zap.Error(err))
+	}
+
+	svc, ctx := adminService(*tenantName)
+	defer svc.Close()
+
+	renamed, err := svc.Reconcile(ctx)
+	if err != nil {
+		logger.Fatal("Failed to reconcile repositories", zap.Error(err))
+	}
+
+	logger.Info("Successfully reconciled repositories", zap.Int("renamed", renamed))
+}
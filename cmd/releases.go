@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"githubapifetch/logger"
+
+	"go.uber.org/zap"
+)
+
+// runChangelog prints the changes between two tags of a repository as
+// Markdown
+func runChangelog(args []string) {
+	changelogCmd := flag.NewFlagSet("changelog", flag.ExitOnError)
+	repo := changelogCmd.String("repo", "", "Repository name")
+	from := changelogCmd.String("from", "", "Tag the changes start after (defaults to the release before -to)")
+	to := changelogCmd.String("to", "", "Tag the changes end at")
+	tenantName := changelogCmd.String("tenant", "", "Tenant the repository belongs to (defaults to the default tenant)")
+
+	if err := changelogCmd.Parse(args); err != nil {
+		logger.Fatal("Failed to parse changelog command", zap.Error(err))
+	}
+	if *repo == "" || *to == "" {
+		logger.Fatal("Repository and tag are required",
+			zap.String("usage", "changelog -repo <name> -to <tag> [-from <tag>] [-tenant <name>]"))
+	}
+
+	svc, ctx := adminService(*tenantName)
+	defer svc.Close()
+
+	log, err := svc.Changelog(ctx, *repo, *from, *to)
+	if err != nil {
+		logger.Fatal("Failed to assemble changelog", zap.Error(err))
+	}
+	if err := log.Markdown(os.Stdout); err != nil {
+		logger.Fatal("Failed to write changelog", zap.Error(err))
+	}
+}
+
+// runReleaseCadence prints how often each repository published releases
+func runReleaseCadence(args []string) {
+	cadenceCmd := flag.NewFlagSet("release-cadence", flag.ExitOnError)
+	sinceFlag := cadenceCmd.String("since", "", "First day of the period, YYYY-MM-DD (defaults to the year up to -until)")
+	untilFlag := cadenceCmd.String("until", "", "Last day of the period, YYYY-MM-DD (defaults to today)")
+	prereleases := cadenceCmd.Bool("prereleases", false, "Count prereleases too")
+	tenantName := cadenceCmd.String("tenant", "", "Tenant to report on (defaults to the default tenant)")
+
+	if err := cadenceCmd.Parse(args); err != nil {
+		logger.Fatal("Failed to parse release-cadence command", zap.Error(err))
+	}
+
+	// The period covers whole days; until is the start of the day after it
+	until := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	if *untilFlag != "" {
+		until = parseDay(*untilFlag, "until").AddDate(0, 0, 1)
+	}
+	since := until.AddDate(-1, 0, 0)
+	if *sinceFlag != "" {
+		since = parseDay(*sinceFlag, "since")
+	}
+
+	svc, ctx := adminService(*tenantName)
+	defer svc.Close()
+
+	cadence, err := svc.ReleaseCadence(ctx, since, until, *prereleases)
+	if err != nil {
+		logger.Fatal("Failed to compute release cadence", zap.Error(err))
+	}
+
+	fmt.Printf("Releases from %s to %s\n\n", since.Format(time.DateOnly), until.AddDate(0, 0, -1).Format(time.DateOnly))
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "REPO\tRELEASES\tLAST\tMEAN DAYS\tMEDIAN DAYS")
+	for _, c := range cadence {
+		last := "-"
+		if c.LastRelease != nil {
+			last = fmt.Sprintf("%s (%s)", c.LastTag, c.LastRelease.Format(time.DateOnly))
+		}
+		mean, median := "-", "-"
+		if c.MeanDays != nil && c.MedianDays != nil {
+			mean, median = fmt.Sprintf("%.1f", *c.MeanDays), fmt.Sprintf("%.1f", *c.MedianDays)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", c.RepoName, c.Releases, last, mean, median)
+	}
+	w.Flush()
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+
+	"githubapifetch/logger"
+
+	"go.uber.org/zap"
+)
+
+// runReplay re-processes a repository's stored raw API payloads without
+// contacting GitHub
+func runReplay(args []string) {
+	replayCmd := flag.NewFlagSet("replay", flag.ExitOnError)
+	repoName := replayCmd.String("repo", "", "Repository name to replay")
+	tenantName := replayCmd.String("tenant", "", "Tenant owning the repository (defaults to the default tenant)")
+
+	if err := replayCmd.Parse(args); err != nil {
+		logger.Fatal("Failed to parse replay command", zap.Error(err))
+	}
+
+	if *repoName == "" {
+		logger.Fatal("Repository name is required",
+			zap.String("usage", "replay -repo <repo-name> [-tenant <name>]"))
+	}
+
+	svc, ctx := adminService(*tenantName)
+	defer svc.Close()
+
+	count, err := svc.Replay(ctx, *repoName)
+	if err != nil {
+		logger.Fatal("Failed to replay repository", zap.Error(err))
+	}
+
+	logger.Info("Successfully replayed repository",
+		zap.String("repo", *repoName),
+		zap.Int("payloads", count))
+}
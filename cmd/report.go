@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"githubapifetch/logger"
+
+	"go.uber.org/zap"
+)
+
+// runKnowledgeReport prints how concentrated each repository's recent work is
+// among its top authors
+func runKnowledgeReport(args []string) {
+	reportCmd := flag.NewFlagSet("knowledge-report", flag.ExitOnError)
+	sinceFlag := reportCmd.String("since", "", "First day of the period, YYYY-MM-DD (defaults to the 90 days up to -until)")
+	untilFlag := reportCmd.String("until", "", "Last day of the period, YYYY-MM-DD (defaults to today)")
+	top := reportCmd.Int("top", 3, "Number of top authors to measure")
+	tenantName := reportCmd.String("tenant", "", "Tenant to report on (defaults to the default tenant)")
+
+	if err := reportCmd.Parse(args); err != nil {
+		logger.Fatal("Failed to parse knowledge-report command", zap.Error(err))
+	}
+
+	// The period covers whole days; until is the start of the day after it
+	until := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	if *untilFlag != "" {
+		until = parseDay(*untilFlag, "until").AddDate(0, 0, 1)
+	}
+	since := until.AddDate(0, 0, -90)
+	if *sinceFlag != "" {
+		since = parseDay(*sinceFlag, "since")
+	}
+
+	svc, ctx := adminService(*tenantName)
+	defer svc.Close()
+
+	report, err := svc.KnowledgeReport(ctx, since, until, *top)
+	if err != nil {
+		logger.Fatal("Failed to compute knowledge report", zap.Error(err))
+	}
+
+	fmt.Printf("Commits from %s to %s, top %d authors\n\n",
+		since.Format(time.DateOnly), until.AddDate(0, 0, -1).Format(time.DateOnly), *top)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "REPO\tCOMMITS\tAUTHORS\tCOMMIT SHARE\tBUS FACTOR\tFILES\tFILE SHARE")
+	for _, k := range report {
+		fileShare := "-"
+		if share, ok := k.FileShare(); ok {
+			fileShare = fmt.Sprintf("%.0f%%", share*100)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.0f%%\t%d\t%d\t%s\n",
+			k.RepoName, k.TotalCommits, k.Authors, k.CommitShare()*100, k.BusFactor, k.Files, fileShare)
+	}
+	w.Flush()
+}
+
+// parseDay parses a YYYY-MM-DD flag value as midnight UTC
+func parseDay(value, flagName string) time.Time {
+	day, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		logger.Fatal("Invalid date", zap.String("flag", flagName), zap.String("value", value), zap.Error(err))
+	}
+	return day
+}
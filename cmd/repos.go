@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"githubapifetch/config"
+	"githubapifetch/logger"
+	"githubapifetch/models"
+	"githubapifetch/service"
+
+	"go.uber.org/zap"
+)
+
+// runAddRepo starts tracking a repository and performs its initial sync
+func runAddRepo(args []string) {
+	addRepoCmd := flag.NewFlagSet("add-repo", flag.ExitOnError)
+	repo := addRepoCmd.String("repo", "", "Repository to track, as owner/name")
+	tenantName := addRepoCmd.String("tenant", "", "Tenant owning the repository (defaults to the default tenant)")
+	startDate := addRepoCmd.String("start-date", "", "Backfill start as RFC 3339, YYYY-MM-DD or auto for the repository's creation (defaults to START_DATE)")
+
+	if err := addRepoCmd.Parse(args); err != nil {
+		logger.Fatal("Failed to parse add-repo command", zap.Error(err))
+	}
+
+	owner, name, ok := strings.Cut(*repo, "/")
+	if !ok || owner == "" || name == "" {
+		logger.Fatal("Repository must be given as owner/name",
+			zap.String("usage", "add-repo -repo <owner/name> [-start-date <date|auto>] [-tenant <name>]"))
+	}
+
+	var start *time.Time
+	if *startDate != "" {
+		t, err := config.ParseStartDate(*startDate)
+		if err != nil {
+			logger.Fatal("Invalid -start-date", zap.String("start_date", *startDate), zap.Error(err))
+		}
+		start = &t
+	}
+
+	svc, ctx := adminService(*tenantName)
+	defer svc.Close()
+
+	if err := svc.AddRepository(ctx, owner, name, start); err != nil {
+		logger.Fatal("Failed to add repository", zap.Error(err))
+	}
+
+	logger.Info("Successfully added repository", zap.String("owner", owner), zap.String("repo", name))
+}
+
+// runSetRepoStatus handles remove-repo, pause-repo, resume-repo and
+// requeue-repo, which only differ in the status they apply
+func runSetRepoStatus(command string, args []string) {
+	statusCmd := flag.NewFlagSet(command, flag.ExitOnError)
+	repoName := statusCmd.String("repo", "", "Repository name")
+	tenantName := statusCmd.String("tenant", "", "Tenant owning the repository (defaults to the default tenant)")
+
+	if err := statusCmd.Parse(args); err != nil {
+		logger.Fatal("Failed to parse command", zap.String("command", command), zap.Error(err))
+	}
+
+	if *repoName == "" {
+		logger.Fatal("Repository name is required",
+			zap.String("usage", command+" -repo <repo-name> [-tenant <name>]"))
+	}
+
+	svc, ctx := adminService(*tenantName)
+	defer svc.Close()
+
+	var err error
+	switch command {
+	case "remove-repo":
+		err = svc.RemoveRepository(ctx, *repoName)
+	case "pause-repo":
+		err = svc.PauseRepository(ctx, *repoName)
+	case "resume-repo":
+		err = svc.ResumeRepository(ctx, *repoName)
+	case "requeue-repo":
+		err = svc.RequeueRepository(ctx, *repoName)
+	}
+	if err != nil {
+		logger.Fatal("Failed to update repository", zap.String("command", command), zap.Error(err))
+	}
+
+	logger.Info("Successfully updated repository", zap.String("command", command), zap.String("repo", *repoName))
+}
+
+// runPurgeRepo deletes a repository and everything stored for it. Without
+// -confirm it only prints what would be deleted.
+func runPurgeRepo(args []string) {
+	purgeCmd := flag.NewFlagSet("purge-repo", flag.ExitOnError)
+	repo := purgeCmd.String("repo", "", "Repository to purge, as owner/name")
+	confirmed := purgeCmd.Bool("confirm", false, "Delete the rows; without it they are only counted")
+	asJSON := purgeCmd.Bool("json", false, "Print the result as JSON")
+	tenantName := purgeCmd.String("tenant", "", "Tenant owning the repository (defaults to the default tenant)")
+
+	if err := purgeCmd.Parse(args); err != nil {
+		logger.Fatal("Failed to parse purge-repo command", zap.Error(err))
+	}
+
+	owner, name, ok := strings.Cut(*repo, "/")
+	if !ok || owner == "" || name == "" {
+		logger.Fatal("Repository must be given as owner/name",
+			zap.String("usage", "purge-repo -repo <owner/name> [-confirm] [-tenant <name>] [-json]"))
+	}
+
+	svc, ctx := offlineAdminService(*tenantName)
+	defer svc.Close()
+
+	result, err := svc.PurgeRepository(ctx, owner, name, !*confirmed)
+	if err != nil {
+		logger.Fatal("Failed to purge repository", zap.Error(err))
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			logger.Fatal("Failed to write purge result", zap.Error(err))
+		}
+		return
+	}
+
+	if result.DryRun {
+		fmt.Printf("Purging %s/%s would delete:\n\n", result.Owner, result.Name)
+	} else {
+		fmt.Printf("Purged %s/%s, deleting:\n\n", result.Owner, result.Name)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TABLE\tROWS")
+	for _, t := range result.Tables {
+		fmt.Fprintf(w, "%s\t%d\n", t.Table, t.Rows)
+	}
+	w.Flush()
+	if result.DryRun {
+		fmt.Println("\nNothing was deleted; run again with -confirm to delete these rows.")
+	}
+}
+
+// runSetGroup puts a repository in a group that reset-sync -group acts on
+func runSetGroup(args []string) {
+	groupCmd := flag.NewFlagSet("set-group", flag.ExitOnError)
+	repoName := groupCmd.String("repo", "", "Repository name")
+	group := groupCmd.String("group", "", "Group to put the repository in; empty takes it out of its group")
+	tenantName := groupCmd.String("tenant", "", "Tenant owning the repository (defaults to the default tenant)")
+
+	if err := groupCmd.Parse(args); err != nil {
+		logger.Fatal("Failed to parse set-group command", zap.Error(err))
+	}
+
+	if *repoName == "" {
+		logger.Fatal("Repository name is required",
+			zap.String("usage", "set-group -repo <repo-name> [-group <group>] [-tenant <name>]"))
+	}
+
+	svc, ctx := offlineAdminService(*tenantName)
+	defer svc.Close()
+
+	if err := svc.SetRepositoryGroup(ctx, *repoName, *group); err != nil {
+		logger.Fatal("Failed to set repository group", zap.Error(err))
+	}
+
+	logger.Info("Successfully set repository group", zap.String("repo", *repoName), zap.String("group", *group))
+}
+
+// runListRepos prints a page of the tracked repositories, filtered and sorted
+func runListRepos(args []string) {
+	listCmd := flag.NewFlagSet("list-repos", flag.ExitOnError)
+	owner := listCmd.String("owner", "", "Owner of the repositories (defaults to every owner)")
+	language := listCmd.String("language", "", "Primary language (defaults to every language)")
+	status := listCmd.String("status", "", "Status: active, paused, quarantined or removed (defaults to every status but removed)")
+	group := listCmd.String("group", "", "Repository group (defaults to every group)")
+	minStars := listCmd.Int("min-stars", 0, "Fewest stars a repository must have")
+	sortBy := listCmd.String("sort", models.RepoSortName, "Order: "+strings.Join(models.RepoSorts, ", "))
+	page := listCmd.Int("page", 1, "Page to print")
+	pageSize := listCmd.Int("page-size", 100, "Repositories per page")
+	asJSON := listCmd.Bool("json", false, "Print the repositories as JSON")
+	tenantName := listCmd.String("tenant", "", "Tenant owning the repositories (defaults to the default tenant)")
+
+	if err := listCmd.Parse(args); err != nil {
+		logger.Fatal("Failed to parse list-repos command", zap.Error(err))
+	}
+
+	svc, ctx := offlineAdminService(*tenantName)
+	defer svc.Close()
+
+	filter := models.RepositoryFilter{
+		Owner:    *owner,
+		Language: *language,
+		Status:   *status,
+		Group:    *group,
+		MinStars: *minStars,
+		Sort:     *sortBy,
+	}
+	repos, err := svc.SearchRepositories(ctx, filter, models.NewPaginationParams(*page, *pageSize))
+	if err != nil {
+		logger.Fatal("Failed to list repositories", zap.Error(err))
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(repos); err != nil {
+			logger.Fatal("Failed to write repositories", zap.Error(err))
+		}
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "REPO\tSTATUS\tLANGUAGE\tSTARS\tCOMMITS\tGROUP\tLAST SYNC\tLAST SUCCESS")
+	for _, r := range repos {
+		fmt.Fprintf(w, "%s/%s\t%s\t%s\t%d\t%d\t%s\t%s\t%s\n", r.Owner, r.Name, r.Status, r.Language,
+			r.StarsCount, r.Commits, r.Group, formatSyncTime(r.LastSyncAt), formatSyncTime(r.LastSuccessAt))
+	}
+	w.Flush()
+}
+
+// formatSyncTime formats when a repository was synced, "never" for nil
+func formatSyncTime(t *time.Time) string {
+	if t == nil {
+		return "never"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// adminService initializes the service and resolves the tenant context for
+// an administrative command
+func adminService(tenantName string) (*service.Service, context.Context) {
+	svc, err := service.NewService()
+	if err != nil {
+		logger.Fatal("Failed to initialize service", zap.Error(err))
+	}
+	return tenantService(svc, tenantName)
+}
+
+// offlineAdminService is adminService for commands that never call GitHub
+func offlineAdminService(tenantName string) (*service.Service, context.Context) {
+	svc, err := service.NewOfflineService()
+	if err != nil {
+		logger.Fatal("Failed to initialize service", zap.Error(err))
+	}
+	return tenantService(svc, tenantName)
+}
+
+// tenantService resolves the tenant context of an administrative command
+func tenantService(svc *service.Service, tenantName string) (*service.Service, context.Context) {
+	ctx, err := svc.TenantContext(context.Background(), tenantName)
+	if err != nil {
+		svc.Close()
+		logger.Fatal("Failed to resolve tenant", zap.Error(err))
+	}
+	return svc, ctx
+}
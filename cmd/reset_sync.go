@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"githubapifetch/logger"
+	"githubapifetch/models"
+	"githubapifetch/scheduler"
+	"githubapifetch/service"
+
+	"go.uber.org/zap"
+)
+
+const resetSyncUsage = "reset-sync -repo <repo-name> | -group <group> | -all [-days <number>] [-concurrency <n>] [-dry-run] [-yes] [-tenant <name>] [-json]"
+
+// runResetSync resets the sync point of a repository, or of every repository
+// of a group or tenant, and refetches their commits
+func runResetSync(args []string) {
+	resetSyncCmd := flag.NewFlagSet("reset-sync", flag.ExitOnError)
+	repoName := resetSyncCmd.String("repo", "", "Repository name to reset sync point for")
+	group := resetSyncCmd.String("group", "", "Reset every repository of this group instead")
+	all := resetSyncCmd.Bool("all", false, "Reset every tracked repository of the tenant instead")
+	daysAgo := resetSyncCmd.Int("days", 30, "Number of days ago to reset sync point to")
+	concurrency := resetSyncCmd.Int("concurrency", service.DefaultResetWorkers, "Repositories refetched at once by -group and -all")
+	dryRun := resetSyncCmd.Bool("dry-run", false, "List the repositories -group or -all would reset without resetting them")
+	yes := resetSyncCmd.Bool("yes", false, "Reset the repositories of -group or -all without asking for confirmation")
+	tenantName := resetSyncCmd.String("tenant", "", "Tenant owning the repository (defaults to the default tenant)")
+	asJSON := resetSyncCmd.Bool("json", false, "Print the result as JSON")
+
+	// Parse flags
+	if err := resetSyncCmd.Parse(args); err != nil {
+		logger.Fatal("Failed to parse reset-sync command", zap.Error(err))
+	}
+
+	// Exactly one of -repo, -group and -all selects what is reset
+	selected := 0
+	for _, set := range []bool{*repoName != "", *group != "", *all} {
+		if set {
+			selected++
+		}
+	}
+	if selected != 1 {
+		logger.Fatal("One of -repo, -group and -all is required",
+			zap.String("usage", resetSyncUsage),
+			zap.Strings("args", args))
+	}
+
+	svc, ctx := adminService(*tenantName)
+	defer svc.Close()
+
+	// Calculate the new sync point date
+	newDate := time.Now().Add(-time.Duration(*daysAgo) * 24 * time.Hour)
+	logger.Info("Resetting sync point",
+		zap.String("repo", *repoName),
+		zap.String("group", *group),
+		zap.Bool("all", *all),
+		zap.String("tenant", *tenantName),
+		zap.Time("new_date", newDate),
+		zap.Int("days_ago", *daysAgo),
+		zap.Strings("parsed_args", args))
+
+	if *repoName == "" {
+		repos, err := svc.RepositoriesInGroup(ctx, *group)
+		if err != nil {
+			logger.Fatal("Failed to list repositories", zap.Error(err))
+		}
+		resetSyncBulk(ctx, svc, repos, newDate, bulkResetOptions{
+			concurrency: *concurrency,
+			dryRun:      *dryRun,
+			yes:         *yes,
+			asJSON:      *asJSON,
+		})
+		return
+	}
+
+	// Reset sync point
+	result, err := svc.ResetSyncPoint(ctx, *repoName, newDate)
+	if err != nil {
+		logger.Fatal("Failed to reset sync point", zap.Error(err))
+	}
+	if *asJSON {
+		writeResetSyncJSON(result)
+		return
+	}
+
+	fmt.Printf("Reset %s/%s to %s\n", result.Owner, result.Name, result.Since.Format(time.RFC3339))
+	fmt.Printf("Fetched: %d, inserted: %d, updated: %d commits in %s\n",
+		result.CommitsFetched, result.CommitsInserted, result.CommitsUpdated,
+		time.Duration(result.DurationMS)*time.Millisecond)
+}
+
+// bulkResetOptions are the reset-sync flags that only apply to -group and -all
+type bulkResetOptions struct {
+	concurrency int
+	dryRun      bool
+	yes         bool
+	asJSON      bool
+}
+
+// bulkResetFailure is a repository reset-sync -json reports as failed
+type bulkResetFailure struct {
+	Owner string `json:"owner"`
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+// resetSyncBulk resets the sync point of repos after confirmation, printing
+// a line per repository, and exits non-zero if any of them failed
+func resetSyncBulk(ctx context.Context, svc *service.Service, repos []models.Repository, newDate time.Time, opts bulkResetOptions) {
+	if len(repos) == 0 {
+		fmt.Println("No repositories to reset")
+		return
+	}
+
+	if opts.dryRun {
+		fmt.Printf("Would reset %d repositories to %s:\n", len(repos), newDate.Format(time.RFC3339))
+		for _, repo := range repos {
+			fmt.Printf("  %s/%s\n", repo.Owner, repo.Name)
+		}
+		return
+	}
+
+	if !opts.yes && !confirm(fmt.Sprintf("Reset the sync point of %d repositories to %s?", len(repos), newDate.Format(time.RFC3339))) {
+		fmt.Println("Aborted; nothing reset")
+		os.Exit(1)
+	}
+
+	results, err := svc.ResetSyncPoints(ctx, repos, newDate, opts.concurrency)
+	failures := []bulkResetFailure{}
+	for _, repoErr := range scheduler.RepoErrors(err) {
+		failures = append(failures, bulkResetFailure{Owner: repoErr.Owner, Name: repoErr.Name, Error: repoErr.Err.Error()})
+	}
+
+	if opts.asJSON {
+		reset := []*models.ResetSyncResult{}
+		for _, result := range results {
+			if result != nil {
+				reset = append(reset, result)
+			}
+		}
+		writeResetSyncJSON(map[string]interface{}{"reset": reset, "failed": failures})
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "REPOSITORY\tFETCHED\tINSERTED\tUPDATED\tDURATION")
+		for _, result := range results {
+			if result == nil {
+				continue
+			}
+			fmt.Fprintf(w, "%s/%s\t%d\t%d\t%d\t%s\n", result.Owner, result.Name,
+				result.CommitsFetched, result.CommitsInserted, result.CommitsUpdated,
+				time.Duration(result.DurationMS)*time.Millisecond)
+		}
+		w.Flush()
+		for _, f := range failures {
+			fmt.Fprintf(os.Stderr, "%s/%s: %s\n", f.Owner, f.Name, f.Error)
+		}
+		fmt.Printf("%d repositories reset, %d failed\n", len(repos)-len(failures), len(failures))
+	}
+
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// writeResetSyncJSON prints a reset-sync result as indented JSON
+func writeResetSyncJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		logger.Fatal("Failed to write reset-sync result", zap.Error(err))
+	}
+}
+
+// confirm asks a yes/no question on the terminal; anything but y or yes,
+// including no input at all, is a no
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
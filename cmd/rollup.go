@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"githubapifetch/logger"
+	"githubapifetch/models"
+
+	"go.uber.org/zap"
+)
+
+// runRollupReport prints the commit statistics of the repositories of an
+// owner or group, or of the whole tenant, with the busiest ones
+func runRollupReport(args []string) {
+	reportCmd := flag.NewFlagSet("rollup-report", flag.ExitOnError)
+	owner := reportCmd.String("owner", "", "Owner of the repositories (defaults to every owner)")
+	group := reportCmd.String("group", "", "Repository group (defaults to every group)")
+	author := reportCmd.String("author", "", "Author name (defaults to every author)")
+	sinceFlag := reportCmd.String("since", "", "First day of the period, YYYY-MM-DD (defaults to the first commit)")
+	untilFlag := reportCmd.String("until", "", "Last day of the period, YYYY-MM-DD (defaults to today)")
+	top := reportCmd.Int("top", 10, "Number of busiest repositories to list")
+	asJSON := reportCmd.Bool("json", false, "Print the statistics as JSON")
+	tenantName := reportCmd.String("tenant", "", "Tenant to report on (defaults to the default tenant)")
+
+	if err := reportCmd.Parse(args); err != nil {
+		logger.Fatal("Failed to parse rollup-report command", zap.Error(err))
+	}
+
+	// The period covers whole days; until is the start of the day after it
+	filter := models.StatsFilter{Author: *author}
+	if *sinceFlag != "" {
+		filter.Since = parseDay(*sinceFlag, "since")
+	}
+	if *untilFlag != "" {
+		filter.Until = parseDay(*untilFlag, "until").AddDate(0, 0, 1)
+	}
+
+	svc, ctx := offlineAdminService(*tenantName)
+	defer svc.Close()
+
+	stats, err := svc.RollupStats(ctx, *owner, *group, filter, *top)
+	if err != nil {
+		logger.Fatal("Failed to compute rollup report", zap.Error(err))
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(stats); err != nil {
+			logger.Fatal("Failed to write rollup report", zap.Error(err))
+		}
+		return
+	}
+
+	scope := "All repositories"
+	switch {
+	case *owner != "" && *group != "":
+		scope = fmt.Sprintf("Repositories of %s in group %s", *owner, *group)
+	case *owner != "":
+		scope = "Repositories of " + *owner
+	case *group != "":
+		scope = "Repositories in group " + *group
+	}
+	if *author != "" {
+		scope += ", by " + *author
+	}
+	fmt.Printf("%s\n\n", scope)
+	fmt.Printf("Repositories: %d, active: %d, commits: %d, authors: %d\n\n",
+		stats.Repositories, stats.ActiveRepositories, stats.TotalCommits, stats.UniqueAuthors)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "REPO\tCOMMITS\tAUTHORS\tLAST COMMIT")
+	for _, r := range stats.Busiest {
+		fmt.Fprintf(w, "%s/%s\t%d\t%d\t%s\n", r.Owner, r.RepoName, r.Commits, r.Authors, r.LastCommitDate.Format(time.DateOnly))
+	}
+	w.Flush()
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+
+	"githubapifetch/logger"
+
+	"go.uber.org/zap"
+)
+
+// runSeed fills the database with synthetic repositories and commits for
+// development
+func runSeed(args []string) {
+	seedCmd := flag.NewFlagSet("seed", flag.ExitOnError)
+	repos := seedCmd.Int("repos", 20, "Number of repositories to generate")
+	commits := seedCmd.Int("commits", 100000, "Number of commits to generate across all repositories")
+	rngSeed := seedCmd.Int64("seed", 1, "Random seed; the same seed generates the same data")
+	tenantName := seedCmd.String("tenant", "", "Tenant to seed (defaults to the default tenant)")
+
+	if err := seedCmd.Parse(args); err != nil {
+		logger.Fatal("Failed to parse seed command", zap.Error(err))
+	}
+
+	svc, ctx := offlineAdminService(*tenantName)
+	defer svc.Close()
+
+	if err := svc.Seed(ctx, *repos, *commits, *rngSeed); err != nil {
+		logger.Fatal("Failed to seed database", zap.Error(err))
+	}
+	logger.Info("Successfully seeded database",
+		zap.Int("repos", *repos),
+		zap.Int("commits", *commits),
+		zap.Int64("seed", *rngSeed))
+}
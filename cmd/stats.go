@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+
+	"githubapifetch/logger"
+	"githubapifetch/models"
+
+	"go.uber.org/zap"
+)
+
+// runRepoStats prints the commit statistics of a repository, of all time or
+// over a period, optionally for one author
+func runRepoStats(args []string) {
+	statsCmd := flag.NewFlagSet("repo-stats", flag.ExitOnError)
+	repo := statsCmd.String("repo", "", "Repository name (required)")
+	author := statsCmd.String("author", "", "Author name (defaults to every author)")
+	pathFilter := statsCmd.String("path-filter", "", "Path filter to limit the commits to (defaults to none)")
+	sinceFlag := statsCmd.String("since", "", "Start of the period, RFC 3339 or YYYY-MM-DD (defaults to the first commit)")
+	untilFlag := statsCmd.String("until", "", "End of the period, RFC 3339 or YYYY-MM-DD, exclusive (defaults to now)")
+	tenantName := statsCmd.String("tenant", "", "Tenant to report on (defaults to the default tenant)")
+
+	if err := statsCmd.Parse(args); err != nil {
+		logger.Fatal("Failed to parse repo-stats command", zap.Error(err))
+	}
+	if *repo == "" {
+		logger.Fatal("Repository is required",
+			zap.String("usage", "repo-stats -repo <name> [-since <time>] [-until <time>] [-author <name>] [-path-filter <name>] [-tenant <name>]"))
+	}
+
+	filter := models.StatsFilter{Author: *author}
+	if *sinceFlag != "" {
+		filter.Since = parseTimestamp(*sinceFlag, "since")
+	}
+	if *untilFlag != "" {
+		filter.Until = parseTimestamp(*untilFlag, "until")
+	}
+
+	svc, ctx := offlineAdminService(*tenantName)
+	defer svc.Close()
+
+	stats, err := svc.RepositoryStats(ctx, *repo, *pathFilter, filter)
+	if err != nil {
+		logger.Fatal("Failed to compute repository statistics", zap.Error(err))
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(stats); err != nil {
+		logger.Fatal("Failed to write repository statistics", zap.Error(err))
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"time"
+
+	"githubapifetch/api"
+	"githubapifetch/logger"
+
+	"go.uber.org/zap"
+)
+
+// runSync runs a one-off sync of any repository, registering it if needed
+func runSync(args []string) {
+	syncCmd := flag.NewFlagSet("sync", flag.ExitOnError)
+	repo := syncCmd.String("repo", "", "Repository to sync, as owner/name")
+	sinceFlag := syncCmd.String("since", "", "Sync commits since this RFC 3339 time or YYYY-MM-DD date (defaults to the newest stored commit)")
+	tenantName := syncCmd.String("tenant", "", "Tenant owning the repository (defaults to the default tenant)")
+
+	if err := syncCmd.Parse(args); err != nil {
+		logger.Fatal("Failed to parse sync command", zap.Error(err))
+	}
+
+	owner, name, ok := strings.Cut(*repo, "/")
+	if !ok || owner == "" || name == "" {
+		logger.Fatal("Repository must be given as owner/name",
+			zap.String("usage", "sync -repo <owner/name> [-since <date>] [-tenant <name>]"))
+	}
+
+	var since time.Time
+	if *sinceFlag != "" {
+		var err error
+		if since, err = api.ParseSince(*sinceFlag); err != nil {
+			logger.Fatal("Invalid since", zap.Error(err))
+		}
+	}
+
+	svc, ctx := adminService(*tenantName)
+	defer svc.Close()
+
+	if err := svc.SyncRepo(ctx, owner, name, since); err != nil {
+		logger.Fatal("Failed to sync repository", zap.Error(err))
+	}
+
+	logger.Info("Successfully synced repository", zap.String("owner", owner), zap.String("repo", name))
+}
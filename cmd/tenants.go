@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"githubapifetch/logger"
+	"githubapifetch/models"
+	"githubapifetch/service"
+
+	"go.uber.org/zap"
+)
+
+// runAddTenant registers a tenant tracking a GitHub organization with its own token
+func runAddTenant(args []string) {
+	addTenantCmd := flag.NewFlagSet("add-tenant", flag.ExitOnError)
+	name := addTenantCmd.String("name", "", "Unique tenant name")
+	token := addTenantCmd.String("token", "", "GitHub token used for the tenant's repositories")
+	owner := addTenantCmd.String("owner", "", "GitHub organization or user the tenant tracks")
+	interval := addTenantCmd.Int("interval", 3600, "Poll interval in seconds")
+	apiToken := addTenantCmd.String("api-token", "", "Token granting query API access to the tenant's data")
+	repos := addTenantCmd.String("repos", "", "Comma-separated repository names to sync initially")
+
+	if err := addTenantCmd.Parse(args); err != nil {
+		logger.Fatal("Failed to parse add-tenant command", zap.Error(err))
+	}
+
+	if *name == "" || *token == "" || *owner == "" {
+		logger.Fatal("Tenant name, token and owner are required",
+			zap.String("usage", "add-tenant -name <name> -token <token> -owner <owner> [-interval <seconds>] [-api-token <token>] [-repos <a,b>]"))
+	}
+
+	t := models.Tenant{
+		Name:         *name,
+		GitHubToken:  *token,
+		RepoOwner:    *owner,
+		PollInterval: *interval,
+	}
+	if *apiToken != "" {
+		t.APIToken = apiToken
+	}
+
+	var repoNames []string
+	for _, r := range strings.Split(*repos, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			repoNames = append(repoNames, r)
+		}
+	}
+
+	svc, err := service.NewService()
+	if err != nil {
+		logger.Fatal("Failed to initialize service", zap.Error(err))
+	}
+	defer svc.Close()
+
+	id, err := svc.AddTenant(context.Background(), t, repoNames)
+	if err != nil {
+		logger.Fatal("Failed to add tenant", zap.Error(err))
+	}
+
+	logger.Info("Successfully added tenant",
+		zap.String("tenant", *name),
+		zap.Int("id", id),
+		zap.Strings("repos", repoNames))
+}
+
+// runListTenants prints all registered tenants
+func runListTenants(args []string) {
+	listTenantsCmd := flag.NewFlagSet("list-tenants", flag.ExitOnError)
+	if err := listTenantsCmd.Parse(args); err != nil {
+		logger.Fatal("Failed to parse list-tenants command", zap.Error(err))
+	}
+
+	svc, err := service.NewService()
+	if err != nil {
+		logger.Fatal("Failed to initialize service", zap.Error(err))
+	}
+	defer svc.Close()
+
+	tenants, err := svc.ListTenants(context.Background())
+	if err != nil {
+		logger.Fatal("Failed to list tenants", zap.Error(err))
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tOWNER\tPOLL INTERVAL\tCREATED")
+	for _, t := range tenants {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\n", t.ID, t.Name, t.RepoOwner, t.PollInterval, t.CreatedAt.Format("2006-01-02"))
+	}
+	w.Flush()
+}
+
+// runEncryptSecrets encrypts tenant tokens stored before ENCRYPTION_KEY was set
+func runEncryptSecrets(args []string) {
+	encryptSecretsCmd := flag.NewFlagSet("encrypt-secrets", flag.ExitOnError)
+	if err := encryptSecretsCmd.Parse(args); err != nil {
+		logger.Fatal("Failed to parse encrypt-secrets command", zap.Error(err))
+	}
+
+	svc, err := service.NewService()
+	if err != nil {
+		logger.Fatal("Failed to initialize service", zap.Error(err))
+	}
+	defer svc.Close()
+
+	count, err := svc.EncryptStoredSecrets(context.Background())
+	if err != nil {
+		logger.Fatal("Failed to encrypt stored secrets", zap.Error(err))
+	}
+
+	logger.Info("Successfully encrypted stored secrets", zap.Int("count", count))
+}
@@ -0,0 +1,154 @@
+// Package codeowners parses GitHub CODEOWNERS files and resolves the owners
+// of a path the way GitHub does: patterns follow gitignore rules, and the
+// last rule matching a path wins.
+//
+// See https://docs.github.com/en/repositories/managing-your-repositorys-settings-and-features/customizing-your-repository/about-code-owners
+package codeowners
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Rule is one line of a CODEOWNERS file. A rule without owners leaves the
+// paths it matches unowned.
+type Rule struct {
+	Pattern string
+	Owners  []string
+	Line    int
+
+	re *regexp.Regexp
+	// dirOnly is set for patterns ending in a slash, which match
+	// directories only
+	dirOnly bool
+	// shallow is set for patterns ending in /*, which GitHub matches
+	// against the files directly in a directory, not those below
+	shallow bool
+}
+
+// File is a parsed CODEOWNERS file
+type File struct {
+	Rules []Rule
+}
+
+// Parse reads a CODEOWNERS file. Blank lines and comments are skipped, as is
+// anything after a # on a rule line.
+func Parse(data []byte) (*File, error) {
+	f := &File{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		for i, field := range fields {
+			if strings.HasPrefix(field, "#") {
+				fields = fields[:i]
+				break
+			}
+		}
+		if len(fields) == 0 {
+			continue
+		}
+
+		rule, err := newRule(fields[0], fields[1:], line)
+		if err != nil {
+			return nil, err
+		}
+		f.Rules = append(f.Rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read CODEOWNERS: %w", err)
+	}
+	return f, nil
+}
+
+func newRule(pattern string, owners []string, line int) (Rule, error) {
+	rule := Rule{Pattern: pattern, Line: line}
+	if len(owners) > 0 {
+		rule.Owners = owners
+	}
+	glob := pattern
+	if strings.HasSuffix(glob, "/") {
+		rule.dirOnly = true
+		glob = strings.TrimSuffix(glob, "/")
+	}
+	// A pattern with a slash before its end is relative to the root,
+	// others match at any depth
+	anchored := strings.Contains(glob, "/")
+	rule.shallow = strings.HasSuffix(glob, "/*") && !strings.HasSuffix(glob, "/**")
+	glob = strings.TrimPrefix(glob, "/")
+	if glob == "" {
+		return Rule{}, fmt.Errorf("line %d: invalid pattern %q", line, pattern)
+	}
+
+	var b strings.Builder
+	b.WriteString("^")
+	if !anchored {
+		b.WriteString("(.*/)?")
+	}
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case glob[i] == '*':
+			b.WriteString("[^/]*")
+		case glob[i] == '?':
+			b.WriteString("[^/]")
+		case glob[i] == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return Rule{}, fmt.Errorf("line %d: invalid pattern %q: %w", line, pattern, err)
+	}
+	rule.re = re
+	return rule, nil
+}
+
+// matches reports whether the rule covers a path. A rule matching a
+// directory covers everything below it.
+func (r Rule) matches(path string, isDir bool) bool {
+	if r.shallow {
+		return !isDir && r.re.MatchString(path)
+	}
+	if (isDir || !r.dirOnly) && r.re.MatchString(path) {
+		return true
+	}
+	for dir := path; ; {
+		i := strings.LastIndexByte(dir, '/')
+		if i < 0 {
+			return false
+		}
+		dir = dir[:i]
+		if r.re.MatchString(dir) {
+			return true
+		}
+	}
+}
+
+// Owners returns the owners of a path relative to the repository root,
+// and the rule assigning them, or nil when no rule matches. Directories are
+// given with a trailing slash, and the root directory as "/". A directory
+// is owned by rules covering the directory itself, like "docs/" or "*",
+// not by rules for the files in it, like "*.go".
+func (f *File) Owners(path string) ([]string, *Rule) {
+	isDir := strings.HasSuffix(path, "/")
+	path = strings.Trim(path, "/")
+	for i := len(f.Rules) - 1; i >= 0; i-- {
+		if f.Rules[i].matches(path, isDir) {
+			return f.Rules[i].Owners, &f.Rules[i]
+		}
+	}
+	return nil, nil
+}
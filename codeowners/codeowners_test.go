@@ -0,0 +1,67 @@
+package codeowners
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const file = `# Default owners
+*       @octo/core
+
+*.go    @gopher   # Go code
+/docs/  @octo/docs
+apps/   @octo/apps
+scripts/* @ops
+/vendor/
+**/logs @ops
+`
+
+func TestOwners(t *testing.T) {
+	f, err := Parse([]byte(file))
+	require.NoError(t, err)
+	require.Len(t, f.Rules, 7)
+	assert.Equal(t, Rule{Pattern: "*.go", Owners: []string{"@gopher"}, Line: 4}, withoutMatcher(f.Rules[1]))
+
+	for path, want := range map[string][]string{
+		"/":                     {"@octo/core"},
+		"README.md":             {"@octo/core"},
+		"cmd/main.go":           {"@gopher"},
+		"cmd/":                  {"@octo/core"},
+		"docs/":                 {"@octo/docs"},
+		"docs/guide/intro.md":   {"@octo/docs"},
+		"src/docs/intro.md":     {"@octo/core"},
+		"apps/":                 {"@octo/apps"},
+		"web/apps/index.js":     {"@octo/apps"},
+		"scripts/build.sh":      {"@ops"},
+		"scripts/ci/test.sh":    {"@octo/core"},
+		"scripts/ci/":           {"@octo/core"},
+		"deploy/logs/today.txt": {"@ops"},
+		"vendor/":               nil,
+		"vendor/lib/lib.go":     nil,
+	} {
+		owners, _ := f.Owners(path)
+		assert.Equal(t, want, owners, path)
+	}
+
+	// Explicitly unowned paths match a rule, unlike paths no rule covers
+	_, rule := f.Owners("vendor/")
+	require.NotNil(t, rule)
+	assert.Equal(t, 8, rule.Line)
+
+	empty, err := Parse([]byte("docs/ @octo/docs\n"))
+	require.NoError(t, err)
+	owners, rule := empty.Owners("cmd/")
+	assert.Nil(t, owners)
+	assert.Nil(t, rule)
+}
+
+func TestParseInvalid(t *testing.T) {
+	_, err := Parse([]byte("/ @octo\n"))
+	assert.ErrorContains(t, err, "line 1")
+}
+
+func withoutMatcher(r Rule) Rule {
+	return Rule{Pattern: r.Pattern, Owners: r.Owners, Line: r.Line}
+}
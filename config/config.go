@@ -2,9 +2,16 @@ package config
 
 import (
 	"fmt"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
+
+	"githubapifetch/backoff"
+	"githubapifetch/features"
+	"githubapifetch/github"
+	"githubapifetch/models"
 )
 
 // Config holds all configuration for the application
@@ -14,6 +21,192 @@ type Config struct {
 	RepoName     string
 	PollInterval int
 	StartDate    time.Time
+
+	// GitHubTokenFile holds the GitHub token instead of GITHUB_TOKEN. It is
+	// reread every TokenReloadInterval seconds, so the token can be rotated
+	// without a restart.
+	GitHubTokenFile     string
+	TokenReloadInterval int
+
+	// GitHubAPIVersion is sent as X-GitHub-Api-Version on every request
+	GitHubAPIVersion string
+
+	// GitHubEndpoints override the 30 second timeout and the retries of
+	// timed out requests for single GitHub endpoints, from GITHUB_TIMEOUTS
+	// and GITHUB_RETRIES
+	GitHubEndpoints map[string]github.EndpointPolicy
+
+	// Webhook delivery of ingested commits; no URLs disables it
+	WebhookURLs        []string
+	WebhookSecret      string
+	WebhookMaxAttempts int
+
+	// SyncTimeout bounds, in seconds, how long one repository sync may run
+	// before it stops at a checkpoint; 0 disables the limit
+	SyncTimeout int
+
+	// SyncOverlap is how many seconds before the newest stored commit a
+	// sync starts, to pick up commits with older author dates; 0 disables
+	// the overlap
+	SyncOverlap int
+
+	// QuarantineAfter is how many consecutive failed syncs quarantine a
+	// repository; 0 disables quarantine. QuarantineBackoff is the first
+	// re-check delay in seconds, doubled after every failed re-check.
+	QuarantineAfter   int
+	QuarantineBackoff int
+
+	// SyncLagSLO is the smoothed sync lag, in seconds, above which a
+	// repository is alerted on; 0 disables alerts. SyncLagHalfLife is the
+	// half-life, in seconds, of the lag's moving average.
+	SyncLagSLO      int
+	SyncLagHalfLife int
+
+	// SyncConcurrency caps how many repositories monitoring syncs at once,
+	// across tenants, and SyncSpacingMS is the least time in milliseconds
+	// between the starts of two syncs; 0 disables either
+	SyncConcurrency int
+	SyncSpacingMS   int
+
+	// RetryBackoff is the backoff strategy of GitHub rate limit retries,
+	// transaction retries and quarantine re-checks: "exponential",
+	// "constant" or "decorrelated-jitter"
+	RetryBackoff string
+
+	// ReconcileInterval is how often, in seconds, stored repositories are
+	// checked for renames and transfers on GitHub
+	ReconcileInterval int
+
+	// DiscoverStarredInterval is how often, in seconds, the repositories
+	// starred by the user of each tenant's token are registered for
+	// monitoring; 0 disables the discovery
+	DiscoverStarredInterval int
+
+	// DriftCheckInterval is how often, in seconds, a sample of stored
+	// commits is compared against GitHub; 0 disables the check.
+	// DriftSampleSize is how many commits of each repository it samples.
+	DriftCheckInterval int
+	DriftSampleSize    int
+
+	// EncryptionKey is the base64-encoded 32-byte key wrapping the data keys
+	// of secrets stored in the database
+	EncryptionKey string
+
+	// StoreRawPayloads keeps the compressed GitHub API responses so they can
+	// be replayed after parsing or schema changes
+	StoreRawPayloads bool
+
+	// AnonymizeAuthors replaces the names and logins of commit, issue and
+	// pull request authors and reviewers, and the email addresses in commit
+	// messages, with pseudonyms keyed by AnonymizeSalt before they are stored
+	AnonymizeAuthors bool
+	AnonymizeSalt    string
+
+	// RecordRateLimits stores the rate limit every GitHub API response
+	// reports in rate_limit_samples; it is published as metrics either way
+	RecordRateLimits bool
+
+	// IsolateFailedCommits stores the rest of a batch when single commits
+	// fail to insert, recording those in rejected_commits
+	IsolateFailedCommits bool
+
+	// CompressMessages stores long commit messages gzip-compressed, keeping
+	// only their subject as text
+	CompressMessages bool
+
+	// IngestCommitParents stores the parent SHAs of every commit in
+	// commit_parents
+	IngestCommitParents bool
+
+	// SyncFirstParent stores only the commits on the first-parent chain of
+	// the default branch
+	SyncFirstParent bool
+
+	// SyncIssues syncs the issues of every repository after its commits,
+	// for the issue statistics
+	SyncIssues bool
+
+	// SyncPullRequests syncs the pull requests of every repository and
+	// their reviews after its commits, for the cycle-time statistics
+	SyncPullRequests bool
+
+	// SyncReleases syncs the published releases of every repository after
+	// its commits, for the release cadence and changelogs
+	SyncReleases bool
+
+	// SyncDeployments syncs the deployments of every repository and their
+	// statuses after its commits, to trace commits to deployments
+	SyncDeployments bool
+
+	// SyncOwners fetches the profile of the user or organization owning
+	// each repository, at most daily, for the owners API
+	SyncOwners bool
+
+	// Features enables the issue, pull request, release and deployment
+	// syncs above by default, except where FEATURE_OVERRIDES turns them on
+	// or off for single repositories
+	Features *features.Flags
+
+	// WorkCalendar is the calendar statistics asked for in working time
+	// count by: WORK_TIMEZONE, WORK_WEEKEND and WORK_HOLIDAYS
+	WorkCalendar models.WorkCalendar
+
+	// SkipPreflight skips checking at startup that the GitHub tokens can
+	// read every repository they sync
+	SkipPreflight bool
+
+	// MaxMessageBytes truncates longer commit messages at ingest; 0 keeps
+	// them whole
+	MaxMessageBytes int
+	// OmitFields lists the commit fields left out of storage, for
+	// deployments that only need counts and dates
+	OmitFields models.OmitFields
+
+	// SpoolDir is the directory commit pages are spooled to when the
+	// database goes away mid-sync, to be stored on the next sync; empty
+	// disables spooling. SpoolMaxBytes bounds the spool's size.
+	SpoolDir      string
+	SpoolMaxBytes int64
+
+	// SpoolWriteAhead spools every fetched commit page before it is stored,
+	// and stores pages in the background while the sync fetches on
+	SpoolWriteAhead bool
+
+	// RunMode splits the service into a fetch-only process, spooling what
+	// it fetches, and an ingest-only process storing the spooled pages
+	// every IngestInterval seconds; RunModeAll runs both halves together
+	RunMode        string
+	IngestInterval int
+
+	// SinkType selects the analytical store ingested commits are copied
+	// to: "clickhouse", "bigquery" or empty for none. Commits are written
+	// SinkBatchSize at a time, at least every SinkFlushInterval seconds.
+	SinkType          string
+	SinkBatchSize     int
+	SinkFlushInterval int
+
+	// ClickHouse sink settings
+	ClickHouseURL      string
+	ClickHouseTable    string
+	ClickHouseUser     string
+	ClickHousePassword string
+
+	// BigQuery sink settings
+	BigQueryProject string
+	BigQueryDataset string
+	BigQueryTable   string
+
+	// Query API settings
+	APIAddr            string
+	APIRateLimit       float64
+	APIRateBurst       int
+	APIMaxPageSize     int
+	APIMaxResultWindow int
+	// APIToken grants query API access to the default tenant
+	APIToken string
+	// APIAllowAnonymous serves read-only query API requests without a token
+	// from the default tenant
+	APIAllowAnonymous bool
 }
 
 // NewConfig creates a new Config instance
@@ -23,9 +216,21 @@ func NewConfig() *Config {
 
 // Load loads configuration from environment variables
 func (c *Config) Load() error {
-	// Set up Viper
-	viper.SetConfigFile("/app/.env")
-	viper.AutomaticEnv()
+	return c.load(true)
+}
+
+// LoadOffline loads configuration for commands that never call GitHub, for
+// which GITHUB_TOKEN, REPO_OWNER and REPO_NAME are optional
+func (c *Config) LoadOffline() error {
+	return c.load(false)
+}
+
+func (c *Config) load(requireGitHub bool) error {
+	// Set up Viper; flags set with SetFlag take precedence over the
+	// environment, which takes precedence over the selected profile and
+	// then the rest of the config file
+	viper.SetConfigFile(configFile)
+	bindEnv()
 
 	// Read .env file if it exists
 	if err := viper.ReadInConfig(); err != nil {
@@ -33,20 +238,35 @@ func (c *Config) Load() error {
 			return fmt.Errorf("failed to read config file: %w", err)
 		}
 	}
+	if err := applyProfile(); err != nil {
+		return err
+	}
 
 	// Required fields
 	c.GitHubToken = viper.GetString("GITHUB_TOKEN")
-	if c.GitHubToken == "" {
+	c.GitHubTokenFile = viper.GetString("GITHUB_TOKEN_FILE")
+	if c.GitHubTokenFile != "" {
+		token, err := ReadTokenFile(c.GitHubTokenFile)
+		if err != nil {
+			return err
+		}
+		c.GitHubToken = token
+	}
+	if c.GitHubToken == "" && requireGitHub {
 		return fmt.Errorf("GITHUB_TOKEN is required")
 	}
+	c.TokenReloadInterval = viper.GetInt("TOKEN_RELOAD_INTERVAL")
+	if c.TokenReloadInterval <= 0 {
+		c.TokenReloadInterval = 60 // Default to once a minute
+	}
 
 	c.RepoOwner = viper.GetString("REPO_OWNER")
-	if c.RepoOwner == "" {
+	if c.RepoOwner == "" && requireGitHub {
 		return fmt.Errorf("REPO_OWNER is required")
 	}
 
 	c.RepoName = viper.GetString("REPO_NAME")
-	if c.RepoName == "" {
+	if c.RepoName == "" && requireGitHub {
 		return fmt.Errorf("REPO_NAME is required")
 	}
 
@@ -56,16 +276,304 @@ func (c *Config) Load() error {
 		c.PollInterval = 3600 // Default to 1 hour
 	}
 
+	for _, u := range strings.Split(viper.GetString("WEBHOOK_URLS"), ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			c.WebhookURLs = append(c.WebhookURLs, u)
+		}
+	}
+	c.WebhookSecret = viper.GetString("WEBHOOK_SECRET")
+	if len(c.WebhookURLs) > 0 && c.WebhookSecret == "" {
+		return fmt.Errorf("WEBHOOK_SECRET is required when WEBHOOK_URLS is set")
+	}
+	c.WebhookMaxAttempts = viper.GetInt("WEBHOOK_MAX_ATTEMPTS")
+	if c.WebhookMaxAttempts <= 0 {
+		c.WebhookMaxAttempts = 5
+	}
+
+	c.GitHubAPIVersion = viper.GetString("GITHUB_API_VERSION")
+	if c.GitHubAPIVersion == "" {
+		c.GitHubAPIVersion = "2022-11-28"
+	}
+	endpoints, err := github.ParseEndpointPolicies(viper.GetString("GITHUB_TIMEOUTS"), viper.GetString("GITHUB_RETRIES"))
+	if err != nil {
+		return fmt.Errorf("invalid GITHUB_TIMEOUTS or GITHUB_RETRIES: %w", err)
+	}
+	c.GitHubEndpoints = endpoints
+
+	c.SyncTimeout = viper.GetInt("SYNC_TIMEOUT")
+	if c.SyncTimeout < 0 {
+		c.SyncTimeout = 0
+	}
+	if !viper.IsSet("SYNC_TIMEOUT") {
+		c.SyncTimeout = 3600 // Default to 1 hour
+	}
+
+	c.SyncOverlap = viper.GetInt("SYNC_OVERLAP")
+	if c.SyncOverlap < 0 {
+		c.SyncOverlap = 0
+	}
+	if !viper.IsSet("SYNC_OVERLAP") {
+		c.SyncOverlap = 86400 // Default to 24 hours
+	}
+
+	c.QuarantineAfter = viper.GetInt("QUARANTINE_AFTER")
+	if c.QuarantineAfter < 0 {
+		c.QuarantineAfter = 0
+	}
+	if !viper.IsSet("QUARANTINE_AFTER") {
+		c.QuarantineAfter = 5
+	}
+	c.QuarantineBackoff = viper.GetInt("QUARANTINE_BACKOFF")
+	if c.QuarantineBackoff <= 0 {
+		c.QuarantineBackoff = 3600 // Default to 1 hour
+	}
+
+	c.SyncLagSLO = viper.GetInt("SYNC_LAG_SLO")
+	if c.SyncLagSLO < 0 {
+		c.SyncLagSLO = 0
+	}
+	c.SyncLagHalfLife = viper.GetInt("SYNC_LAG_HALF_LIFE")
+	if c.SyncLagHalfLife <= 0 {
+		c.SyncLagHalfLife = 900 // Default to 15 minutes
+	}
+
+	c.SyncConcurrency = viper.GetInt("SYNC_CONCURRENCY")
+	if c.SyncConcurrency < 0 {
+		c.SyncConcurrency = 0
+	}
+	c.SyncSpacingMS = viper.GetInt("SYNC_SPACING_MS")
+	if c.SyncSpacingMS < 0 {
+		c.SyncSpacingMS = 0
+	}
+
+	if c.RetryBackoff, err = backoff.ParseStrategy(viper.GetString("RETRY_BACKOFF")); err != nil {
+		return fmt.Errorf("invalid RETRY_BACKOFF: %w", err)
+	}
+
+	c.ReconcileInterval = viper.GetInt("RECONCILE_INTERVAL")
+	if c.ReconcileInterval <= 0 {
+		c.ReconcileInterval = 86400 // Default to once a day
+	}
+
+	c.DiscoverStarredInterval = viper.GetInt("DISCOVER_STARRED_INTERVAL")
+	if c.DiscoverStarredInterval < 0 {
+		c.DiscoverStarredInterval = 0
+	}
+	c.DriftCheckInterval = viper.GetInt("DRIFT_CHECK_INTERVAL")
+	if c.DriftCheckInterval < 0 {
+		c.DriftCheckInterval = 0
+	}
+	c.DriftSampleSize = viper.GetInt("DRIFT_SAMPLE_SIZE")
+	if c.DriftSampleSize <= 0 {
+		c.DriftSampleSize = 20
+	}
+
 	startDateStr := viper.GetString("START_DATE")
 	if startDateStr == "" {
 		c.StartDate = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
 	} else {
 		var err error
-		c.StartDate, err = time.Parse(time.RFC3339, startDateStr)
+		c.StartDate, err = ParseStartDate(startDateStr)
 		if err != nil {
 			return fmt.Errorf("invalid START_DATE format: %w", err)
 		}
 	}
 
+	c.EncryptionKey = viper.GetString("ENCRYPTION_KEY")
+	c.StoreRawPayloads = viper.GetBool("STORE_RAW_PAYLOADS")
+	c.AnonymizeAuthors = viper.GetBool("ANONYMIZE_AUTHORS")
+	c.AnonymizeSalt = viper.GetString("ANONYMIZE_SALT")
+	if c.AnonymizeAuthors {
+		if c.AnonymizeSalt == "" {
+			return fmt.Errorf("ANONYMIZE_AUTHORS requires ANONYMIZE_SALT")
+		}
+		// Raw payloads would keep every name in the clear
+		if c.StoreRawPayloads {
+			return fmt.Errorf("ANONYMIZE_AUTHORS cannot be combined with STORE_RAW_PAYLOADS")
+		}
+	}
+	c.RecordRateLimits = viper.GetBool("RECORD_RATE_LIMITS")
+	c.IsolateFailedCommits = viper.GetBool("ISOLATE_FAILED_COMMITS")
+	c.CompressMessages = viper.GetBool("COMPRESS_MESSAGES")
+	c.IngestCommitParents = viper.GetBool("INGEST_COMMIT_PARENTS")
+	c.SyncFirstParent = viper.GetBool("SYNC_FIRST_PARENT")
+	c.SyncIssues = viper.GetBool("SYNC_ISSUES")
+	c.SyncPullRequests = viper.GetBool("SYNC_PULL_REQUESTS")
+	c.SyncReleases = viper.GetBool("SYNC_RELEASES")
+	c.SyncDeployments = viper.GetBool("SYNC_DEPLOYMENTS")
+	c.SyncOwners = viper.GetBool("SYNC_OWNERS")
+	overrides, err := features.ParseOverrides(viper.GetString("FEATURE_OVERRIDES"))
+	if err != nil {
+		return fmt.Errorf("invalid FEATURE_OVERRIDES: %w", err)
+	}
+	c.Features = features.New(map[string]bool{
+		features.Issues:       c.SyncIssues,
+		features.PullRequests: c.SyncPullRequests,
+		features.Releases:     c.SyncReleases,
+		features.Deployments:  c.SyncDeployments,
+	}, overrides)
+	weekend := "sat,sun"
+	if viper.IsSet("WORK_WEEKEND") {
+		weekend = viper.GetString("WORK_WEEKEND")
+	}
+	if c.WorkCalendar, err = models.ParseWorkCalendar(viper.GetString("WORK_TIMEZONE"), weekend, viper.GetString("WORK_HOLIDAYS")); err != nil {
+		return fmt.Errorf("invalid work calendar: %w", err)
+	}
+	c.SkipPreflight = viper.GetBool("SKIP_PREFLIGHT")
+
+	c.MaxMessageBytes = viper.GetInt("MAX_MESSAGE_BYTES")
+	if c.MaxMessageBytes < 0 {
+		c.MaxMessageBytes = 0
+	}
+	if !viper.IsSet("MAX_MESSAGE_BYTES") {
+		c.MaxMessageBytes = 65536 // Default to 64 KiB
+	}
+	if c.OmitFields, err = models.ParseOmitFields(viper.GetString("OMIT_FIELDS")); err != nil {
+		return fmt.Errorf("invalid OMIT_FIELDS: %w", err)
+	}
+
+	c.SpoolDir = viper.GetString("SPOOL_DIR")
+	c.SpoolMaxBytes = viper.GetInt64("SPOOL_MAX_BYTES")
+	if c.SpoolMaxBytes <= 0 {
+		c.SpoolMaxBytes = 256 << 20 // Default to 256 MiB
+	}
+	c.SpoolWriteAhead = viper.GetBool("SPOOL_WRITE_AHEAD")
+	if c.SpoolWriteAhead && c.SpoolDir == "" {
+		return fmt.Errorf("SPOOL_WRITE_AHEAD requires SPOOL_DIR")
+	}
+	c.RunMode = strings.ToLower(viper.GetString("RUN_MODE"))
+	switch c.RunMode {
+	case "":
+		c.RunMode = RunModeAll
+	case RunModeAll, RunModeFetchOnly, RunModeIngestOnly:
+	default:
+		return fmt.Errorf("unknown RUN_MODE %q, want %s, %s or %s", c.RunMode, RunModeAll, RunModeFetchOnly, RunModeIngestOnly)
+	}
+	if c.RunMode != RunModeAll && c.SpoolDir == "" {
+		return fmt.Errorf("RUN_MODE %s requires SPOOL_DIR", c.RunMode)
+	}
+	c.IngestInterval = viper.GetInt("INGEST_INTERVAL")
+	if c.IngestInterval <= 0 {
+		c.IngestInterval = 5 // Default to every 5 seconds
+	}
+
+	if err := c.loadSink(); err != nil {
+		return err
+	}
+
+	// Query API; an empty address disables the server
+	c.APIAddr = viper.GetString("API_ADDR")
+
+	c.APIRateLimit = viper.GetFloat64("API_RATE_LIMIT")
+	if c.APIRateLimit <= 0 {
+		c.APIRateLimit = 10 // Default to 10 requests per second per client
+	}
+
+	c.APIRateBurst = viper.GetInt("API_RATE_BURST")
+	if c.APIRateBurst <= 0 {
+		c.APIRateBurst = 20
+	}
+
+	c.APIMaxPageSize = viper.GetInt("API_MAX_PAGE_SIZE")
+	if c.APIMaxPageSize <= 0 {
+		c.APIMaxPageSize = 100
+	}
+
+	c.APIMaxResultWindow = viper.GetInt("API_MAX_RESULT_WINDOW")
+	if c.APIMaxResultWindow <= 0 {
+		c.APIMaxResultWindow = 10000
+	}
+
+	c.APIToken = viper.GetString("API_TOKEN")
+	c.APIAllowAnonymous = viper.GetBool("API_ALLOW_ANONYMOUS")
+
 	return nil
 }
+
+// loadSink loads the analytical sink settings
+func (c *Config) loadSink() error {
+	c.SinkType = strings.ToLower(viper.GetString("SINK_TYPE"))
+	c.SinkBatchSize = viper.GetInt("SINK_BATCH_SIZE")
+	if c.SinkBatchSize <= 0 {
+		c.SinkBatchSize = 500
+	}
+	c.SinkFlushInterval = viper.GetInt("SINK_FLUSH_INTERVAL")
+	if c.SinkFlushInterval <= 0 {
+		c.SinkFlushInterval = 10 // Default to 10 seconds
+	}
+
+	c.ClickHouseURL = viper.GetString("CLICKHOUSE_URL")
+	c.ClickHouseTable = viper.GetString("CLICKHOUSE_TABLE")
+	if c.ClickHouseTable == "" {
+		c.ClickHouseTable = "commits"
+	}
+	c.ClickHouseUser = viper.GetString("CLICKHOUSE_USER")
+	c.ClickHousePassword = viper.GetString("CLICKHOUSE_PASSWORD")
+
+	c.BigQueryProject = viper.GetString("BIGQUERY_PROJECT")
+	c.BigQueryDataset = viper.GetString("BIGQUERY_DATASET")
+	c.BigQueryTable = viper.GetString("BIGQUERY_TABLE")
+	if c.BigQueryTable == "" {
+		c.BigQueryTable = "commits"
+	}
+
+	switch c.SinkType {
+	case "":
+	case "clickhouse":
+		if c.ClickHouseURL == "" {
+			return fmt.Errorf("CLICKHOUSE_URL is required when SINK_TYPE is clickhouse")
+		}
+	case "bigquery":
+		if c.BigQueryProject == "" || c.BigQueryDataset == "" {
+			return fmt.Errorf("BIGQUERY_PROJECT and BIGQUERY_DATASET are required when SINK_TYPE is bigquery")
+		}
+	default:
+		return fmt.Errorf("unknown SINK_TYPE %q, want clickhouse or bigquery", c.SinkType)
+	}
+	return nil
+}
+
+// Run modes
+const (
+	RunModeAll        = "all"
+	RunModeFetchOnly  = "fetch-only"
+	RunModeIngestOnly = "ingest-only"
+)
+
+// StartDateAuto is the start date that backfills each repository from its
+// creation on GitHub, represented by the zero time
+const StartDateAuto = "auto"
+
+// ReadTokenFile reads a GitHub token from a file, ignoring surrounding
+// whitespace such as a trailing newline
+func ReadTokenFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read GITHUB_TOKEN_FILE: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// ParseStartDate parses a backfill start given as "auto", an RFC 3339
+// timestamp or a YYYY-MM-DD date (midnight UTC)
+func ParseStartDate(raw string) (time.Time, error) {
+	if strings.EqualFold(raw, StartDateAuto) {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid start date %q: want auto, RFC 3339 or YYYY-MM-DD", raw)
+	}
+	return t, nil
+}
+
+// FormatStartDate formats a start date the way ParseStartDate reads it
+func FormatStartDate(t time.Time) string {
+	if t.IsZero() {
+		return StartDateAuto
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,283 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"githubapifetch/features"
+	"githubapifetch/github"
+	"githubapifetch/models"
+)
+
+// isolate gives a test fresh viper state and a config file with contents
+func isolate(t *testing.T, contents string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), ".env")
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0o600))
+
+	viper.Reset()
+	SetConfigFile(path)
+	SetEnvPrefix(DefaultEnvPrefix)
+	SetProfile("")
+	flagValues = map[string]string{}
+	t.Cleanup(func() {
+		viper.Reset()
+		SetConfigFile(DefaultConfigFile)
+		SetEnvPrefix(DefaultEnvPrefix)
+		SetProfile("")
+		flagValues = map[string]string{}
+	})
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	isolate(t, "POLL_INTERVAL=100\nSYNC_TIMEOUT=50\nAPI_RATE_BURST=7\n")
+	t.Setenv("GITHUBAPIFETCH_POLL_INTERVAL", "200")
+	t.Setenv("GITHUBAPIFETCH_SYNC_TIMEOUT", "60")
+	t.Setenv("GITHUBAPIFETCH_GITHUB_TOKEN", "ghp_secret")
+	require.NoError(t, SetFlag("poll_interval", "300"))
+
+	cfg := NewConfig()
+	require.NoError(t, cfg.LoadOffline())
+	assert.Equal(t, 300, cfg.PollInterval)
+	assert.Equal(t, 60, cfg.SyncTimeout)
+	assert.Equal(t, 7, cfg.APIRateBurst)
+	assert.Equal(t, 5, cfg.WebhookMaxAttempts)
+
+	settings := map[string]Setting{}
+	for _, s := range cfg.Settings() {
+		settings[s.Key] = s
+	}
+	assert.Equal(t, Setting{Key: "POLL_INTERVAL", Value: "300", Source: SourceFlag}, settings["POLL_INTERVAL"])
+	assert.Equal(t, Setting{Key: "SYNC_TIMEOUT", Value: "60", Source: SourceEnv}, settings["SYNC_TIMEOUT"])
+	assert.Equal(t, Setting{Key: "API_RATE_BURST", Value: "7", Source: SourceFile}, settings["API_RATE_BURST"])
+	assert.Equal(t, Setting{Key: "WEBHOOK_MAX_ATTEMPTS", Value: "5", Source: SourceDefault}, settings["WEBHOOK_MAX_ATTEMPTS"])
+	assert.Equal(t, Setting{Key: "GITHUB_TOKEN", Value: redacted, Source: SourceEnv}, settings["GITHUB_TOKEN"])
+	assert.Equal(t, "", settings["WEBHOOK_SECRET"].Value)
+}
+
+func TestSetFlagRejectsUnknownKeys(t *testing.T) {
+	isolate(t, "")
+	assert.Error(t, SetFlag("POLL_INTERVALL", "300"))
+}
+
+func TestLoadEnvPrefix(t *testing.T) {
+	isolate(t, "")
+	t.Setenv("GITHUBAPIFETCH_POLL_INTERVAL", "200")
+	t.Setenv("POLL_INTERVAL", "100")
+	t.Setenv("SYNC_TIMEOUT", "60")
+	t.Setenv("GITHUBAPIFETCH_API_RATE_BURST", "")
+	t.Setenv("API_RATE_BURST", "7")
+
+	cfg := NewConfig()
+	require.NoError(t, cfg.LoadOffline())
+	assert.Equal(t, 200, cfg.PollInterval, "the prefixed variable wins")
+	assert.Equal(t, 60, cfg.SyncTimeout, "bare variables still work")
+	assert.Equal(t, 7, cfg.APIRateBurst, "an empty prefixed variable falls back")
+	assert.Equal(t, []string{"SYNC_TIMEOUT", "API_RATE_BURST"}, DeprecatedEnv())
+
+	settings := map[string]Setting{}
+	for _, s := range cfg.Settings() {
+		settings[s.Key] = s
+	}
+	assert.False(t, settings["POLL_INTERVAL"].Deprecated)
+	assert.True(t, settings["SYNC_TIMEOUT"].Deprecated)
+	assert.Equal(t, SourceEnv, settings["SYNC_TIMEOUT"].Source)
+
+	// Without a prefix the bare keys are the variables
+	viper.Reset()
+	SetEnvPrefix("")
+	cfg = NewConfig()
+	require.NoError(t, cfg.LoadOffline())
+	assert.Equal(t, 100, cfg.PollInterval)
+	assert.Empty(t, DeprecatedEnv())
+}
+
+func TestLoadStartDate(t *testing.T) {
+	isolate(t, "START_DATE=auto\n")
+	cfg := NewConfig()
+	require.NoError(t, cfg.LoadOffline())
+	assert.True(t, cfg.StartDate.IsZero())
+	for _, s := range cfg.Settings() {
+		if s.Key == "START_DATE" {
+			assert.Equal(t, StartDateAuto, s.Value)
+		}
+	}
+
+	t.Setenv("GITHUBAPIFETCH_START_DATE", "2023-06-01")
+	cfg = NewConfig()
+	require.NoError(t, cfg.LoadOffline())
+	assert.Equal(t, time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC), cfg.StartDate)
+
+	t.Setenv("GITHUBAPIFETCH_START_DATE", "June 2023")
+	assert.Error(t, NewConfig().LoadOffline())
+}
+
+func TestLoadProfile(t *testing.T) {
+	isolate(t, `POLL_INTERVAL=100
+SYNC_TIMEOUT=50
+API_RATE_BURST=7
+staging.POLL_INTERVAL=200
+staging.SYNC_TIMEOUT=60
+prod.EXTENDS=staging
+prod.POLL_INTERVAL=300
+loop.EXTENDS=loop
+`)
+	t.Setenv(ProfileEnv, "staging")
+	t.Setenv("GITHUBAPIFETCH_SYNC_TIMEOUT", "70")
+
+	cfg := NewConfig()
+	require.NoError(t, cfg.LoadOffline())
+	assert.Equal(t, 200, cfg.PollInterval)
+	assert.Equal(t, 70, cfg.SyncTimeout, "the environment wins over the profile")
+	assert.Equal(t, 7, cfg.APIRateBurst, "profiles inherit the unprefixed keys")
+	assert.Equal(t, []string{"staging"}, Profile())
+
+	// The flag wins over APP_ENV, and prod inherits from staging
+	viper.Reset()
+	SetProfile("prod")
+	t.Setenv("GITHUBAPIFETCH_SYNC_TIMEOUT", "")
+	cfg = NewConfig()
+	require.NoError(t, cfg.LoadOffline())
+	assert.Equal(t, 300, cfg.PollInterval)
+	assert.Equal(t, 60, cfg.SyncTimeout)
+	assert.Equal(t, []string{"prod", "staging"}, Profile())
+
+	settings := map[string]Setting{}
+	for _, s := range cfg.Settings() {
+		settings[s.Key] = s
+	}
+	assert.Equal(t, SourceProfile, settings["POLL_INTERVAL"].Source)
+	assert.Equal(t, SourceProfile, settings["SYNC_TIMEOUT"].Source)
+	assert.Equal(t, SourceFile, settings["API_RATE_BURST"].Source)
+
+	for _, name := range []string{"qa", "loop", "bad-name"} {
+		viper.Reset()
+		SetProfile(name)
+		assert.Error(t, NewConfig().LoadOffline(), name)
+	}
+}
+
+func TestLoadFeatureOverrides(t *testing.T) {
+	isolate(t, "SYNC_ISSUES=true\nFEATURE_OVERRIDES=octo/hello:issues=off, octo/big:releases=on\n")
+	cfg := NewConfig()
+	require.NoError(t, cfg.LoadOffline())
+	assert.True(t, cfg.Features.Enabled(features.Issues, "octo", "other"))
+	assert.False(t, cfg.Features.Enabled(features.Issues, "octo", "hello"))
+	assert.True(t, cfg.Features.Enabled(features.Releases, "octo", "big"))
+	assert.False(t, cfg.Features.Enabled(features.Releases, "octo", "hello"))
+
+	t.Setenv("GITHUBAPIFETCH_FEATURE_OVERRIDES", "octo/hello:traffic=on")
+	assert.Error(t, NewConfig().LoadOffline())
+}
+
+func TestLoadTokenFile(t *testing.T) {
+	isolate(t, "GITHUB_TOKEN=ghp_from_env_file\n")
+	path := filepath.Join(t.TempDir(), "github_token")
+	require.NoError(t, os.WriteFile(path, []byte("  ghp_from_secret\n"), 0o600))
+	t.Setenv("GITHUBAPIFETCH_GITHUB_TOKEN_FILE", path)
+
+	cfg := NewConfig()
+	require.NoError(t, cfg.LoadOffline())
+	assert.Equal(t, "ghp_from_secret", cfg.GitHubToken)
+	assert.Equal(t, 60, cfg.TokenReloadInterval)
+
+	t.Setenv("GITHUBAPIFETCH_GITHUB_TOKEN_FILE", filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, NewConfig().LoadOffline())
+}
+
+func TestLoadSpool(t *testing.T) {
+	isolate(t, "SPOOL_WRITE_AHEAD=true\n")
+	assert.Error(t, NewConfig().LoadOffline(), "write-ahead spooling needs a spool")
+
+	t.Setenv("GITHUBAPIFETCH_SPOOL_DIR", t.TempDir())
+	cfg := NewConfig()
+	require.NoError(t, cfg.LoadOffline())
+	assert.True(t, cfg.SpoolWriteAhead)
+	assert.Equal(t, int64(256<<20), cfg.SpoolMaxBytes)
+	assert.Equal(t, RunModeAll, cfg.RunMode)
+
+	require.NoError(t, SetFlag("RUN_MODE", "fetch-only"))
+	cfg = NewConfig()
+	require.NoError(t, cfg.LoadOffline())
+	assert.Equal(t, RunModeFetchOnly, cfg.RunMode)
+
+	require.NoError(t, SetFlag("RUN_MODE", "fetch-and-ingest"))
+	assert.Error(t, NewConfig().LoadOffline())
+}
+
+func TestLoadGitHubEndpoints(t *testing.T) {
+	isolate(t, "GITHUB_TIMEOUTS=repo=10,commits=120\nGITHUB_RETRIES=commits=2\n")
+	cfg := NewConfig()
+	require.NoError(t, cfg.LoadOffline())
+	assert.Equal(t, map[string]github.EndpointPolicy{
+		github.EndpointRepo:    {Timeout: 10 * time.Second},
+		github.EndpointCommits: {Timeout: 2 * time.Minute, Retries: 2},
+	}, cfg.GitHubEndpoints)
+
+	t.Setenv("GITHUBAPIFETCH_GITHUB_RETRIES", "issues=1")
+	assert.Error(t, NewConfig().LoadOffline())
+}
+
+func TestLoadAnonymize(t *testing.T) {
+	isolate(t, "ANONYMIZE_AUTHORS=true\n")
+	assert.Error(t, NewConfig().LoadOffline(), "pseudonyms need a salt")
+
+	t.Setenv("GITHUBAPIFETCH_ANONYMIZE_SALT", "pepper")
+	cfg := NewConfig()
+	require.NoError(t, cfg.LoadOffline())
+	assert.True(t, cfg.AnonymizeAuthors)
+	for _, s := range cfg.Settings() {
+		if s.Key == "ANONYMIZE_SALT" {
+			assert.Equal(t, redacted, s.Value)
+		}
+	}
+
+	t.Setenv("GITHUBAPIFETCH_STORE_RAW_PAYLOADS", "true")
+	assert.Error(t, NewConfig().LoadOffline(), "raw payloads would keep the names")
+}
+
+func TestLoadOmitFields(t *testing.T) {
+	isolate(t, "OMIT_FIELDS=message_body, URL\n")
+	cfg := NewConfig()
+	require.NoError(t, cfg.LoadOffline())
+	assert.Equal(t, models.OmitFields{MessageBody: true, URL: true}, cfg.OmitFields)
+	for _, s := range cfg.Settings() {
+		if s.Key == "OMIT_FIELDS" {
+			assert.Equal(t, "message_body,url", s.Value)
+		}
+	}
+
+	t.Setenv("GITHUBAPIFETCH_OMIT_FIELDS", "author_name")
+	assert.Error(t, NewConfig().LoadOffline())
+}
+
+func TestLoadWorkCalendar(t *testing.T) {
+	isolate(t, "")
+	cfg := NewConfig()
+	require.NoError(t, cfg.LoadOffline())
+	assert.Equal(t, models.DefaultWorkCalendar(), cfg.WorkCalendar)
+
+	t.Setenv("GITHUBAPIFETCH_WORK_TIMEZONE", "Asia/Dubai")
+	t.Setenv("GITHUBAPIFETCH_WORK_WEEKEND", "Sat, SUN")
+	t.Setenv("GITHUBAPIFETCH_WORK_HOLIDAYS", "2024-12-02,2024-12-03")
+	cfg = NewConfig()
+	require.NoError(t, cfg.LoadOffline())
+	assert.Equal(t, models.WorkCalendar{Timezone: "Asia/Dubai", Weekend: []string{"sat", "sun"}, Holidays: []string{"2024-12-02", "2024-12-03"}}, cfg.WorkCalendar)
+
+	for key, value := range map[string]string{
+		"GITHUBAPIFETCH_WORK_TIMEZONE": "Mars/Olympus",
+		"GITHUBAPIFETCH_WORK_WEEKEND":  "sat,sun,mon,tue,wed,thu,fri",
+		"GITHUBAPIFETCH_WORK_HOLIDAYS": "Christmas",
+	} {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv(key, value)
+			assert.Error(t, NewConfig().LoadOffline())
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// DefaultEnvPrefix namespaces the environment variables read as settings
+const DefaultEnvPrefix = "GITHUBAPIFETCH"
+
+// envPrefix is prepended, with an underscore, to every setting's key to name
+// its environment variable; empty reads the bare keys
+var envPrefix = DefaultEnvPrefix
+
+// SetEnvPrefix sets the prefix of the environment variables read as settings
+func SetEnvPrefix(prefix string) {
+	envPrefix = strings.TrimSuffix(strings.ToUpper(prefix), "_")
+}
+
+// EnvPrefix returns the prefix of the environment variables read as settings
+func EnvPrefix() string {
+	return envPrefix
+}
+
+// envName returns the environment variable holding key
+func envName(key string) string {
+	if envPrefix == "" {
+		return key
+	}
+	return envPrefix + "_" + key
+}
+
+// bindEnv makes every setting read its prefixed environment variable,
+// falling back to the bare key. The fallback keeps deployments setting
+// POSTGRES_PORT and the like working while they move to the prefix; it
+// will be removed in a future release.
+func bindEnv() {
+	viper.SetEnvPrefix(envPrefix)
+	viper.AutomaticEnv()
+	for _, s := range settings {
+		viper.BindEnv(s.key, envName(s.key), s.key)
+	}
+}
+
+// envSource reports whether key is set in the environment, and whether only
+// through its deprecated bare name. Viper ignores empty environment
+// variables, so they do not count either.
+func envSource(key string) (set, deprecated bool) {
+	if os.Getenv(envName(key)) != "" {
+		return true, false
+	}
+	if os.Getenv(key) != "" {
+		return true, envName(key) != key
+	}
+	return false, false
+}
+
+// DeprecatedEnv returns the settings read from bare environment variables
+// because their prefixed ones are unset
+func DeprecatedEnv() []string {
+	var keys []string
+	for _, s := range settings {
+		if _, ok := flagValues[s.key]; ok {
+			continue
+		}
+		if set, deprecated := envSource(s.key); set && deprecated {
+			keys = append(keys, s.key)
+		}
+	}
+	return keys
+}
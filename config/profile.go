@@ -0,0 +1,104 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// ProfileEnv is the environment variable selecting the profile when no
+// -profile flag is given
+const ProfileEnv = "APP_ENV"
+
+// extendsKey names the profile a profile inherits its settings from
+const extendsKey = "EXTENDS"
+
+// profileName is what a profile may be called; config file keys allow
+// nothing else before the dot
+var profileName = regexp.MustCompile(`^\w+$`)
+
+var (
+	// profile is the profile set with SetProfile
+	profile string
+	// profileValues holds the settings the selected profile, or a profile it
+	// inherits from, gives in the config file, and profileChain those
+	// profiles, the selected one first
+	profileValues = map[string]string{}
+	profileChain  []string
+)
+
+// SetProfile selects the profile read on load, overriding ProfileEnv
+func SetProfile(name string) {
+	profile = name
+}
+
+// Profile returns the selected profile and the profiles it inherits from,
+// most specific first; nil without a profile
+func Profile() []string {
+	return profileChain
+}
+
+// selectedProfile returns the profile set with SetProfile, else ProfileEnv
+func selectedProfile() string {
+	if profile != "" {
+		return profile
+	}
+	return os.Getenv(ProfileEnv)
+}
+
+// applyProfile merges the settings of the selected profile over the config
+// file's own. A profile is the config file's keys prefixed with its name and
+// a dot, e.g. staging.POLL_INTERVAL=120, and inherits every setting it does
+// not give from the profile named by its EXTENDS key, if any, and then from
+// the unprefixed keys. Flags and environment variables still win.
+func applyProfile() error {
+	profileValues = map[string]string{}
+	profileChain = nil
+
+	name := strings.ToLower(selectedProfile())
+	if name == "" {
+		return nil
+	}
+
+	seen := map[string]bool{}
+	for p := name; p != ""; p = strings.ToLower(viper.GetString(p + "." + extendsKey)) {
+		if !profileName.MatchString(p) {
+			return fmt.Errorf("invalid profile name %q", p)
+		}
+		if seen[p] {
+			return fmt.Errorf("profile %s extends itself through %s", p, strings.Join(profileChain, " -> "))
+		}
+		if !definesProfile(p) {
+			return fmt.Errorf("profile %s is not defined in %s", p, configFile)
+		}
+		seen[p] = true
+		profileChain = append(profileChain, p)
+	}
+
+	overrides := map[string]interface{}{}
+	for _, s := range settings {
+		for _, p := range profileChain {
+			key := p + "." + s.key
+			if viper.InConfig(key) {
+				profileValues[s.key] = viper.GetString(key)
+				overrides[s.key] = profileValues[s.key]
+				break
+			}
+		}
+	}
+	return viper.MergeConfigMap(overrides)
+}
+
+// definesProfile reports whether the config file has any key of profile p
+func definesProfile(p string) bool {
+	prefix := p + "."
+	for _, key := range viper.AllKeys() {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
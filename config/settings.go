@@ -0,0 +1,211 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Source says where the effective value of a setting came from. When a
+// setting is given in several places, a flag wins over an environment
+// variable, which wins over the selected profile, which wins over the rest of
+// the config file; otherwise the default applies.
+type Source string
+
+// Setting sources, by precedence
+const (
+	SourceFlag    Source = "flag"
+	SourceEnv     Source = "env"
+	SourceProfile Source = "profile"
+	SourceFile    Source = "file"
+	SourceDefault Source = "default"
+)
+
+// DefaultConfigFile is the config file read when no other is given
+const DefaultConfigFile = "/app/.env"
+
+// redacted replaces the value of a secret setting that is set
+const redacted = "[REDACTED]"
+
+// Setting is the effective value of one configuration key and its source
+type Setting struct {
+	Key    string
+	Value  string
+	Source Source
+	// Deprecated is set when the value came from the key's bare
+	// environment variable rather than its prefixed one
+	Deprecated bool
+}
+
+// setting describes a configuration key
+type setting struct {
+	key    string
+	secret bool
+	// value formats the effective value from a loaded Config
+	value func(c *Config) string
+}
+
+// settings lists every configuration key. Keys read by other packages
+// straight from viper show their raw value.
+var settings = []setting{
+	{key: "GITHUB_TOKEN", secret: true, value: func(c *Config) string { return c.GitHubToken }},
+	{key: "GITHUB_TOKEN_FILE", value: func(c *Config) string { return c.GitHubTokenFile }},
+	{key: "TOKEN_RELOAD_INTERVAL", value: func(c *Config) string { return strconv.Itoa(c.TokenReloadInterval) }},
+	{key: "REPO_OWNER", value: func(c *Config) string { return c.RepoOwner }},
+	{key: "REPO_NAME", value: func(c *Config) string { return c.RepoName }},
+	{key: "POLL_INTERVAL", value: func(c *Config) string { return strconv.Itoa(c.PollInterval) }},
+	{key: "START_DATE", value: func(c *Config) string { return FormatStartDate(c.StartDate) }},
+	{key: "GITHUB_API_VERSION", value: func(c *Config) string { return c.GitHubAPIVersion }},
+	{key: "GITHUB_TIMEOUTS", value: raw("GITHUB_TIMEOUTS")},
+	{key: "GITHUB_RETRIES", value: raw("GITHUB_RETRIES")},
+	{key: "WEBHOOK_URLS", value: func(c *Config) string { return strings.Join(c.WebhookURLs, ",") }},
+	{key: "WEBHOOK_SECRET", secret: true, value: func(c *Config) string { return c.WebhookSecret }},
+	{key: "WEBHOOK_MAX_ATTEMPTS", value: func(c *Config) string { return strconv.Itoa(c.WebhookMaxAttempts) }},
+	{key: "SYNC_TIMEOUT", value: func(c *Config) string { return strconv.Itoa(c.SyncTimeout) }},
+	{key: "SYNC_OVERLAP", value: func(c *Config) string { return strconv.Itoa(c.SyncOverlap) }},
+	{key: "QUARANTINE_AFTER", value: func(c *Config) string { return strconv.Itoa(c.QuarantineAfter) }},
+	{key: "QUARANTINE_BACKOFF", value: func(c *Config) string { return strconv.Itoa(c.QuarantineBackoff) }},
+	{key: "SYNC_LAG_SLO", value: func(c *Config) string { return strconv.Itoa(c.SyncLagSLO) }},
+	{key: "SYNC_LAG_HALF_LIFE", value: func(c *Config) string { return strconv.Itoa(c.SyncLagHalfLife) }},
+	{key: "SYNC_CONCURRENCY", value: func(c *Config) string { return strconv.Itoa(c.SyncConcurrency) }},
+	{key: "SYNC_SPACING_MS", value: func(c *Config) string { return strconv.Itoa(c.SyncSpacingMS) }},
+	{key: "RETRY_BACKOFF", value: func(c *Config) string { return c.RetryBackoff }},
+	{key: "RECONCILE_INTERVAL", value: func(c *Config) string { return strconv.Itoa(c.ReconcileInterval) }},
+	{key: "DISCOVER_STARRED_INTERVAL", value: func(c *Config) string { return strconv.Itoa(c.DiscoverStarredInterval) }},
+	{key: "DRIFT_CHECK_INTERVAL", value: func(c *Config) string { return strconv.Itoa(c.DriftCheckInterval) }},
+	{key: "DRIFT_SAMPLE_SIZE", value: func(c *Config) string { return strconv.Itoa(c.DriftSampleSize) }},
+	{key: "ENCRYPTION_KEY", secret: true, value: func(c *Config) string { return c.EncryptionKey }},
+	{key: "STORE_RAW_PAYLOADS", value: func(c *Config) string { return strconv.FormatBool(c.StoreRawPayloads) }},
+	{key: "ANONYMIZE_AUTHORS", value: func(c *Config) string { return strconv.FormatBool(c.AnonymizeAuthors) }},
+	{key: "ANONYMIZE_SALT", secret: true, value: func(c *Config) string { return c.AnonymizeSalt }},
+	{key: "RECORD_RATE_LIMITS", value: func(c *Config) string { return strconv.FormatBool(c.RecordRateLimits) }},
+	{key: "ISOLATE_FAILED_COMMITS", value: func(c *Config) string { return strconv.FormatBool(c.IsolateFailedCommits) }},
+	{key: "COMPRESS_MESSAGES", value: func(c *Config) string { return strconv.FormatBool(c.CompressMessages) }},
+	{key: "INGEST_COMMIT_PARENTS", value: func(c *Config) string { return strconv.FormatBool(c.IngestCommitParents) }},
+	{key: "SYNC_FIRST_PARENT", value: func(c *Config) string { return strconv.FormatBool(c.SyncFirstParent) }},
+	{key: "SYNC_ISSUES", value: func(c *Config) string { return strconv.FormatBool(c.SyncIssues) }},
+	{key: "SYNC_PULL_REQUESTS", value: func(c *Config) string { return strconv.FormatBool(c.SyncPullRequests) }},
+	{key: "SYNC_RELEASES", value: func(c *Config) string { return strconv.FormatBool(c.SyncReleases) }},
+	{key: "SYNC_DEPLOYMENTS", value: func(c *Config) string { return strconv.FormatBool(c.SyncDeployments) }},
+	{key: "SYNC_OWNERS", value: func(c *Config) string { return strconv.FormatBool(c.SyncOwners) }},
+	{key: "FEATURE_OVERRIDES", value: raw("FEATURE_OVERRIDES")},
+	{key: "WORK_TIMEZONE", value: func(c *Config) string { return c.WorkCalendar.Timezone }},
+	{key: "WORK_WEEKEND", value: func(c *Config) string { return strings.Join(c.WorkCalendar.Weekend, ",") }},
+	{key: "WORK_HOLIDAYS", value: func(c *Config) string { return strings.Join(c.WorkCalendar.Holidays, ",") }},
+	{key: "SKIP_PREFLIGHT", value: func(c *Config) string { return strconv.FormatBool(c.SkipPreflight) }},
+	{key: "MAX_MESSAGE_BYTES", value: func(c *Config) string { return strconv.Itoa(c.MaxMessageBytes) }},
+	{key: "OMIT_FIELDS", value: func(c *Config) string { return c.OmitFields.String() }},
+	{key: "SPOOL_DIR", value: func(c *Config) string { return c.SpoolDir }},
+	{key: "SPOOL_MAX_BYTES", value: func(c *Config) string { return strconv.FormatInt(c.SpoolMaxBytes, 10) }},
+	{key: "SPOOL_WRITE_AHEAD", value: func(c *Config) string { return strconv.FormatBool(c.SpoolWriteAhead) }},
+	{key: "RUN_MODE", value: func(c *Config) string { return c.RunMode }},
+	{key: "INGEST_INTERVAL", value: func(c *Config) string { return strconv.Itoa(c.IngestInterval) }},
+	{key: "SINK_TYPE", value: func(c *Config) string { return c.SinkType }},
+	{key: "SINK_BATCH_SIZE", value: func(c *Config) string { return strconv.Itoa(c.SinkBatchSize) }},
+	{key: "SINK_FLUSH_INTERVAL", value: func(c *Config) string { return strconv.Itoa(c.SinkFlushInterval) }},
+	{key: "CLICKHOUSE_URL", value: func(c *Config) string { return c.ClickHouseURL }},
+	{key: "CLICKHOUSE_TABLE", value: func(c *Config) string { return c.ClickHouseTable }},
+	{key: "CLICKHOUSE_USER", value: func(c *Config) string { return c.ClickHouseUser }},
+	{key: "CLICKHOUSE_PASSWORD", secret: true, value: func(c *Config) string { return c.ClickHousePassword }},
+	{key: "BIGQUERY_PROJECT", value: func(c *Config) string { return c.BigQueryProject }},
+	{key: "BIGQUERY_DATASET", value: func(c *Config) string { return c.BigQueryDataset }},
+	{key: "BIGQUERY_TABLE", value: func(c *Config) string { return c.BigQueryTable }},
+	{key: "API_ADDR", value: func(c *Config) string { return c.APIAddr }},
+	{key: "API_RATE_LIMIT", value: func(c *Config) string { return strconv.FormatFloat(c.APIRateLimit, 'g', -1, 64) }},
+	{key: "API_RATE_BURST", value: func(c *Config) string { return strconv.Itoa(c.APIRateBurst) }},
+	{key: "API_MAX_PAGE_SIZE", value: func(c *Config) string { return strconv.Itoa(c.APIMaxPageSize) }},
+	{key: "API_MAX_RESULT_WINDOW", value: func(c *Config) string { return strconv.Itoa(c.APIMaxResultWindow) }},
+	{key: "API_TOKEN", secret: true, value: func(c *Config) string { return c.APIToken }},
+	{key: "API_ALLOW_ANONYMOUS", value: func(c *Config) string { return strconv.FormatBool(c.APIAllowAnonymous) }},
+	{key: "DATABASE_URL", secret: true, value: raw("DATABASE_URL")},
+	{key: "POSTGRES_USER", value: raw("POSTGRES_USER")},
+	{key: "POSTGRES_PASSWORD", secret: true, value: raw("POSTGRES_PASSWORD")},
+	{key: "POSTGRES_DB", value: raw("POSTGRES_DB")},
+	{key: "POSTGRES_HOST", value: raw("POSTGRES_HOST")},
+	{key: "POSTGRES_PORT", value: raw("POSTGRES_PORT")},
+	{key: "POSTGRES_SSLMODE", value: raw("POSTGRES_SSLMODE")},
+	{key: "POSTGRES_SSLROOTCERT", value: raw("POSTGRES_SSLROOTCERT")},
+	{key: "POSTGRES_SCHEMA", value: raw("POSTGRES_SCHEMA")},
+	{key: "POSTGRES_APPLICATION_NAME", value: raw("POSTGRES_APPLICATION_NAME")},
+	{key: "DB_MAX_OPEN_CONNS", value: raw("DB_MAX_OPEN_CONNS")},
+	{key: "DB_MAX_IDLE_CONNS", value: raw("DB_MAX_IDLE_CONNS")},
+	{key: "DB_CONN_MAX_LIFETIME", value: raw("DB_CONN_MAX_LIFETIME")},
+	{key: "QUERY_LOG", value: raw("QUERY_LOG")},
+	{key: "SLOW_QUERY_MS", value: raw("SLOW_QUERY_MS")},
+}
+
+func raw(key string) func(*Config) string {
+	return func(*Config) string { return viper.GetString(key) }
+}
+
+var (
+	// configFile is the config file read on load
+	configFile = DefaultConfigFile
+	// flagValues holds the settings given as command-line flags
+	flagValues = map[string]string{}
+)
+
+// SetConfigFile sets the config file read on load
+func SetConfigFile(path string) {
+	configFile = path
+}
+
+// ConfigFile returns the config file read on load
+func ConfigFile() string {
+	return configFile
+}
+
+// SetFlag sets a configuration key from a command-line flag, overriding its
+// environment variable and config file entry
+func SetFlag(key, value string) error {
+	key = strings.ToUpper(strings.TrimSpace(key))
+	if !known(key) {
+		return fmt.Errorf("unknown configuration key %q", key)
+	}
+	flagValues[key] = value
+	viper.Set(key, value)
+	return nil
+}
+
+func known(key string) bool {
+	for _, s := range settings {
+		if s.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// source returns where the value of key comes from
+func source(key string) (Source, bool) {
+	if _, ok := flagValues[key]; ok {
+		return SourceFlag, false
+	}
+	if set, deprecated := envSource(key); set {
+		return SourceEnv, deprecated
+	}
+	if _, ok := profileValues[key]; ok {
+		return SourceProfile, false
+	}
+	if viper.InConfig(key) {
+		return SourceFile, false
+	}
+	return SourceDefault, false
+}
+
+// Settings returns the effective value and source of every configuration
+// key of a loaded Config, with secrets redacted
+func (c *Config) Settings() []Setting {
+	out := make([]Setting, 0, len(settings))
+	for _, s := range settings {
+		value := s.value(c)
+		if s.secret && value != "" {
+			value = redacted
+		}
+		src, deprecated := source(s.key)
+		out = append(out, Setting{Key: s.key, Value: value, Source: src, Deprecated: deprecated})
+	}
+	return out
+}
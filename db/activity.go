@@ -0,0 +1,127 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"githubapifetch/models"
+	"githubapifetch/tenant"
+)
+
+// activitySource is one kind of activity feed event: the table it comes
+// from and the expressions of its time, its key and its columns
+type activitySource struct {
+	kind   string
+	at     string
+	key    string
+	ref    string
+	title  string
+	actor  string
+	url    string
+	from   string
+	filter string
+}
+
+// activitySources lists the events of the activity feed. Commit dates are
+// stored as UTC without a zone, so they are converted to compare with the
+// others.
+var activitySources = []activitySource{
+	{
+		kind:  models.ActivityCommit,
+		at:    "COALESCE(c.committer_date, c.date) AT TIME ZONE 'UTC'",
+		key:   "'commit:' || c.id",
+		ref:   "c.sha",
+		title: `split_part(COALESCE(c.message, ''), E'\n', 1)`,
+		actor: "COALESCE(c.author_name, '')",
+		url:   "COALESCE(c.url, '')",
+		from:  "commits c JOIN repositories r ON r.id = c.repository_id",
+	},
+	{
+		kind:  models.ActivityRelease,
+		at:    "rl.published_at",
+		key:   "'release:' || rl.id",
+		ref:   "rl.tag_name",
+		title: "COALESCE(NULLIF(rl.name, ''), rl.tag_name)",
+		actor: "''",
+		url:   "''",
+		from:  "releases rl JOIN repositories r ON r.id = rl.repository_id",
+	},
+	{
+		kind:   models.ActivityPullRequestMerged,
+		at:     "p.merged_at",
+		key:    "'pull_request:' || p.id",
+		ref:    "p.number::text",
+		title:  "p.title",
+		actor:  "p.author_login",
+		url:    "''",
+		from:   "pull_requests p JOIN repositories r ON r.id = p.repository_id",
+		filter: " AND p.merged_at IS NOT NULL",
+	},
+}
+
+// ActivityFeed returns up to limit events of the tracked repositories of the
+// context's tenant, newest first, starting after cursor. Events at the same
+// instant are ordered by key, so a page never repeats or skips any.
+func (db *DB) ActivityFeed(ctx context.Context, cursor models.ActivityCursor, limit int) (*models.ActivityPage, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("%w: limit must be positive", ErrInvalidInput)
+	}
+
+	// Every source is limited on its own first, so only the newest rows of
+	// each are merged; one more event than asked says whether a next page
+	// exists
+	args := []interface{}{tenant.FromContext(ctx), models.RepoStatusRemoved, limit + 1}
+	after := ""
+	if !cursor.IsZero() {
+		args = append(args, cursor.At, cursor.Key)
+		after = ` AND (%[1]s, %[2]s COLLATE "C") < ($4, $5)`
+	}
+	branches := make([]string, 0, len(activitySources))
+	for _, s := range activitySources {
+		branches = append(branches, fmt.Sprintf(`(
+			SELECT '`+s.kind+`' AS kind, %[1]s AS at, r.owner AS repo_owner, r.name AS repo_name,
+				`+s.ref+` AS ref, `+s.title+` AS title, `+s.actor+` AS actor, `+s.url+` AS url,
+				%[2]s AS key, COALESCE(r.url, '') AS repo_url
+			FROM `+s.from+`
+			WHERE r.tenant_id = $1 AND r.status <> $2`+s.filter+after+`
+			ORDER BY %[1]s DESC, %[2]s COLLATE "C" DESC
+			LIMIT $3
+		)`, s.at, s.key))
+	}
+	query := `
+		SELECT kind, at, repo_owner, repo_name, ref, title, actor, url, key, repo_url
+		FROM (` + strings.Join(branches, " UNION ALL ") + `) events
+		ORDER BY at DESC, key COLLATE "C" DESC
+		LIMIT $3
+	`
+
+	var rows []struct {
+		models.ActivityEvent
+		RepoURL string `db:"repo_url"`
+	}
+	if err := db.conn.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to read activity feed: %w", err)
+	}
+
+	page := &models.ActivityPage{Events: []models.ActivityEvent{}}
+	for i, row := range rows {
+		if i == limit {
+			last := page.Events[limit-1]
+			page.NextCursor = models.ActivityCursor{At: last.At, Key: last.Key}.String()
+			break
+		}
+		event := row.ActivityEvent
+		if event.URL == "" && row.RepoURL != "" {
+			switch event.Kind {
+			case models.ActivityRelease:
+				event.URL = row.RepoURL + "/releases/tag/" + url.PathEscape(event.Ref)
+			case models.ActivityPullRequestMerged:
+				event.URL = row.RepoURL + "/pull/" + event.Ref
+			}
+		}
+		page.Events = append(page.Events, event)
+	}
+	return page, nil
+}
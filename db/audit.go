@@ -0,0 +1,57 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"githubapifetch/models"
+	"githubapifetch/tenant"
+)
+
+// RecordAudit stores an audit log entry for the context's tenant
+func (db *DB) RecordAudit(ctx context.Context, entry models.AuditEntry) error {
+	if entry.Actor == "" || entry.ActorType == "" || entry.Action == "" {
+		return fmt.Errorf("%w: audit entry requires actor, actor type and action", ErrInvalidInput)
+	}
+
+	tenantID := entry.TenantID
+	if tenantID == 0 {
+		tenantID = tenant.FromContext(ctx)
+	}
+	params := entry.Parameters
+	if len(params) == 0 {
+		params = []byte("{}")
+	}
+
+	query := `
+		INSERT INTO audit_log (tenant_id, actor, actor_type, action, parameters)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	if _, err := db.conn.ExecContext(ctx, query,
+		tenantID, entry.Actor, entry.ActorType, entry.Action, string(params),
+	); err != nil {
+		return fmt.Errorf("failed to record audit entry for %s: %w", entry.Action, err)
+	}
+	return nil
+}
+
+// ListAuditEntries returns the most recent audit entries for the context's
+// tenant, optionally filtered by action
+func (db *DB) ListAuditEntries(ctx context.Context, action string, limit int) ([]models.AuditEntry, error) {
+	if limit < 1 {
+		return nil, fmt.Errorf("%w: limit must be positive", ErrInvalidInput)
+	}
+
+	entries := []models.AuditEntry{}
+	query := `
+		SELECT id, tenant_id, actor, actor_type, action, parameters, created_at
+		FROM audit_log
+		WHERE tenant_id = $1 AND ($2 = '' OR action = $2)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3
+	`
+	if err := db.conn.SelectContext(ctx, &entries, query, tenant.FromContext(ctx), action, limit); err != nil {
+		return nil, fmt.Errorf("failed to list audit entries: %w", err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,117 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"githubapifetch/backoff"
+	"githubapifetch/logger"
+	"githubapifetch/models"
+)
+
+// benchDB connects to the Postgres named by BENCH_DATABASE_URL, which must
+// have the schema of migrations/init.sql, and skips the benchmark without it.
+// `make bench` starts a throwaway one in Docker.
+func benchDB(b *testing.B) *DB {
+	b.Helper()
+	url := os.Getenv("BENCH_DATABASE_URL")
+	if url == "" {
+		b.Skip("BENCH_DATABASE_URL not set")
+	}
+	conn, err := sqlx.Connect("postgres", url)
+	if err != nil {
+		b.Fatalf("failed to connect to benchmark database: %v", err)
+	}
+	if err := logger.Initialize("error"); err != nil {
+		b.Fatal(err)
+	}
+
+	database := &DB{conn: conn, txBackoff: backoff.Exponential{Base: TxRetryDelay}}
+	database.stmtCache.statements = make(map[string]*sqlx.Stmt)
+	b.Cleanup(func() { database.Close() })
+	return database
+}
+
+// benchRepository creates a repository for a benchmark's commits and drops
+// it, along with them, when the benchmark ends
+func benchRepository(b *testing.B, database *DB) int {
+	b.Helper()
+	ctx := context.Background()
+	name := fmt.Sprintf("bench-%d", time.Now().UnixNano())
+	if err := database.StoreRepository(ctx, models.Repository{Owner: "bench", Name: name}); err != nil {
+		b.Fatal(err)
+	}
+	repo, err := database.GetByName(ctx, name)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() {
+		if _, err := database.conn.Exec(`DELETE FROM repositories WHERE id = $1`, repo.ID); err != nil {
+			b.Errorf("failed to drop benchmark repository: %v", err)
+		}
+	})
+	return repo.ID
+}
+
+// benchCommits returns n commits of a repository whose SHAs start at first
+func benchCommits(repoID, first, n int) []models.Commit {
+	commits := make([]models.Commit, n)
+	date := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range commits {
+		sha := fmt.Sprintf("%040x", first+i)
+		commits[i] = models.Commit{
+			SHA:           sha,
+			RepoID:        repoID,
+			Message:       fmt.Sprintf("Commit %d\n\nWith a body long enough to matter", first+i),
+			AuthorName:    "Octo Cat",
+			Date:          date.Add(time.Duration(first+i) * time.Second),
+			CommitterDate: date.Add(time.Duration(first+i) * time.Second),
+			URL:           "https://github.com/bench/repo/commit/" + sha,
+		}
+	}
+	return commits
+}
+
+// BenchmarkBatchInsert measures inserting new commits and re-inserting
+// stored, unchanged ones, which the upsert turns into no-ops
+func BenchmarkBatchInsert(b *testing.B) {
+	database := benchDB(b)
+	ctx := context.Background()
+
+	for _, size := range []int{100, 1000} {
+		b.Run(fmt.Sprintf("new/%d", size), func(b *testing.B) {
+			repoID := benchRepository(b, database)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				commits := benchCommits(repoID, i*size, size)
+				b.StartTimer()
+				if err := database.BatchInsert(ctx, commits); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(b.N*size)/b.Elapsed().Seconds(), "rows/s")
+		})
+
+		b.Run(fmt.Sprintf("unchanged/%d", size), func(b *testing.B) {
+			repoID := benchRepository(b, database)
+			commits := benchCommits(repoID, 0, size)
+			if err := database.BatchInsert(ctx, commits); err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := database.BatchInsert(ctx, commits); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(b.N*size)/b.Elapsed().Seconds(), "rows/s")
+		})
+	}
+}
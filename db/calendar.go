@@ -0,0 +1,53 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"githubapifetch/models"
+)
+
+// hoursBetween returns the SQL expression of the hours from the timestamp
+// start to the timestamp end, NULL when end is. With a calendar only the
+// hours of its working days count, and the expression takes the three
+// parameters of calendarArgs, numbered from arg.
+func hoursBetween(start, end string, cal *models.WorkCalendar, arg int) string {
+	if cal == nil {
+		return fmt.Sprintf("(EXTRACT(EPOCH FROM %s - %s) / 3600)::float8", end, start)
+	}
+	tz, weekend, holidays := fmt.Sprintf("$%d::text", arg), fmt.Sprintf("$%d::int[]", arg+1), fmt.Sprintf("$%d::date[]", arg+2)
+	// Every local day the span touches counts the part of it inside the
+	// span, unless it is not worked
+	return fmt.Sprintf(`(CASE WHEN %[2]s IS NULL THEN NULL ELSE COALESCE((
+			SELECT SUM(EXTRACT(EPOCH FROM LEAST(%[2]s, (d + 1)::timestamp AT TIME ZONE %[3]s) - GREATEST(%[1]s, d::timestamp AT TIME ZONE %[3]s)))
+			FROM generate_series((%[1]s AT TIME ZONE %[3]s)::date, (%[2]s AT TIME ZONE %[3]s)::date, INTERVAL '1 day') AS days(day),
+				LATERAL (SELECT days.day::date AS d) local_day
+			WHERE EXTRACT(DOW FROM d)::int <> ALL(%[4]s) AND d <> ALL(%[5]s)
+		), 0) / 3600 END)::float8`, start, end, tz, weekend, holidays)
+}
+
+// calendarArgs returns the parameters of the expressions hoursBetween
+// returns for cal, none without a calendar
+func calendarArgs(cal *models.WorkCalendar) []interface{} {
+	if cal == nil {
+		return nil
+	}
+	holidays := cal.Holidays
+	if holidays == nil {
+		holidays = []string{}
+	}
+	return []interface{}{cal.Timezone, pq.Array(cal.WeekendDays()), pq.Array(holidays)}
+}
+
+// validCalendar checks a calendar statistics are asked to count working
+// time by, if any
+func validCalendar(cal *models.WorkCalendar) error {
+	if cal == nil {
+		return nil
+	}
+	if err := cal.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+	return nil
+}
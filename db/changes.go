@@ -0,0 +1,91 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"githubapifetch/models"
+)
+
+// changeTable says how to page through the changes of one table: the column
+// the data layer stamps on every write and an expression naming a row
+type changeTable struct {
+	updatedAt string
+	key       string
+}
+
+// changeTables lists the tables Changes can extract. Keys are text so
+// composite keys page the same way as SERIAL ids.
+var changeTables = map[string]changeTable{
+	"tenants":                 {"updated_at", "t.id::text"},
+	"repositories":            {"row_updated_at", "t.id::text"},
+	"commits":                 {"updated_at", "t.id::text"},
+	"commit_files":            {"updated_at", "t.repository_id || '/' || t.sha || '/' || t.path"},
+	"commit_parents":          {"updated_at", "t.repository_id || '/' || t.sha || '/' || t.position"},
+	"ingested_pages":          {"updated_at", "t.id::text"},
+	"sync_checkpoints":        {"updated_at", "t.repository_id::text"},
+	"repository_failures":     {"updated_at", "t.repository_id::text"},
+	"rejected_commits":        {"updated_at", "t.id::text"},
+	"repository_path_filters": {"updated_at", "t.id::text"},
+	"repository_snapshots":    {"updated_at", "t.id::text"},
+	"issues":                  {"updated_at", "t.id::text"},
+	"pull_requests":           {"updated_at", "t.id::text"},
+	"pull_request_reviews":    {"updated_at", "t.id::text"},
+	"releases":                {"updated_at", "t.id::text"},
+	"deployments":             {"updated_at", "t.id::text"},
+	"rate_limit_samples":      {"updated_at", "t.id::text"},
+	"owners":                  {"updated_at", "t.id::text"},
+	"audit_log":               {"updated_at", "t.id::text"},
+	"raw_payloads":            {"updated_at", "t.id::text"},
+	"webhook_dead_letters":    {"updated_at", "t.id::text"},
+}
+
+// Changes returns up to limit rows of table written at or after since, across
+// every tenant, oldest first. Rows changed at the same instant are ordered by
+// key; pass the last returned key as afterKey, with its UpdatedAt as since, to
+// read the next page.
+//
+// Timestamps are taken when a transaction starts, so rows changed within the
+// last minute are held back until transactions still writing them have
+// committed.
+func (db *DB) Changes(ctx context.Context, table string, since time.Time, afterKey string, limit int) ([]models.Change, error) {
+	t, ok := changeTables[table]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown table %q", ErrInvalidInput, table)
+	}
+	if limit <= 0 {
+		return nil, fmt.Errorf("%w: limit must be positive", ErrInvalidInput)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %[2]s COLLATE "C" AS key, t.%[1]s AS updated_at, row_to_json(t)::text AS row
+		FROM %[3]s t
+		WHERE t.%[1]s < CURRENT_TIMESTAMP - INTERVAL '1 minute'
+			AND (t.%[1]s, %[2]s COLLATE "C") > ($1, $2)
+		ORDER BY t.%[1]s, %[2]s COLLATE "C"
+		LIMIT $3
+	`, t.updatedAt, t.key, table)
+
+	rows, err := db.conn.QueryContext(ctx, query, since, afterKey, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read changes of %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	changes := []models.Change{}
+	for rows.Next() {
+		c := models.Change{Table: table}
+		var row string
+		if err := rows.Scan(&c.Key, &c.UpdatedAt, &row); err != nil {
+			return nil, fmt.Errorf("failed to scan change of %s: %w", table, err)
+		}
+		c.Row = json.RawMessage(row)
+		changes = append(changes, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read changes of %s: %w", table, err)
+	}
+	return changes, nil
+}
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+
+	"githubapifetch/models"
+)
+
+// GetSyncCheckpoint returns the checkpoint of a repository's interrupted sync
+func (db *DB) GetSyncCheckpoint(ctx context.Context, repoID int) (*models.SyncCheckpoint, error) {
+	var cp models.SyncCheckpoint
+	query := `SELECT repository_id, since, next_page, updated_at, COALESCE(next_sha, '') AS next_sha
+		FROM sync_checkpoints WHERE repository_id = $1`
+	if err := sqlx.GetContext(ctx, db.ext(ctx), &cp, query, repoID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: repository %d", ErrCheckpointNotFound, repoID)
+		}
+		return nil, fmt.Errorf("failed to get sync checkpoint of repository %d: %w", repoID, err)
+	}
+	return &cp, nil
+}
+
+// SaveSyncCheckpoint records how far a repository's sync got
+func (db *DB) SaveSyncCheckpoint(ctx context.Context, cp models.SyncCheckpoint) error {
+	if cp.NextPage < 1 {
+		return fmt.Errorf("%w: next page must be positive", ErrInvalidInput)
+	}
+
+	query := `
+		INSERT INTO sync_checkpoints (repository_id, since, next_page, updated_at, next_sha)
+		VALUES ($1, $2, $3, CURRENT_TIMESTAMP, NULLIF($4, ''))
+		ON CONFLICT (repository_id) DO UPDATE SET
+			since = EXCLUDED.since,
+			next_page = EXCLUDED.next_page,
+			updated_at = EXCLUDED.updated_at,
+			next_sha = EXCLUDED.next_sha
+	`
+	if _, err := db.ext(ctx).ExecContext(ctx, query, cp.RepoID, cp.Since, cp.NextPage, cp.NextSHA); err != nil {
+		return fmt.Errorf("failed to save sync checkpoint of repository %d: %w", cp.RepoID, err)
+	}
+	return nil
+}
+
+// DeleteSyncCheckpoint removes the checkpoint of a repository once its sync
+// completes or is reset
+func (db *DB) DeleteSyncCheckpoint(ctx context.Context, repoID int) error {
+	if _, err := db.ext(ctx).ExecContext(ctx, `DELETE FROM sync_checkpoints WHERE repository_id = $1`, repoID); err != nil {
+		return fmt.Errorf("failed to delete sync checkpoint of repository %d: %w", repoID, err)
+	}
+	return nil
+}
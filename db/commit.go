@@ -2,39 +2,50 @@ package db
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"sync"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"go.uber.org/zap"
 
+	"githubapifetch/logger"
+	"githubapifetch/metrics"
 	"githubapifetch/models"
+	"githubapifetch/tenant"
 )
 
-// GetLatestDate retrieves the latest commit date for a repository
-func (db *DB) GetLatestDate(ctx context.Context, repoName string) (time.Time, error) {
-	if repoName == "" {
-		return time.Time{}, fmt.Errorf("%w: repository name cannot be empty", ErrInvalidInput)
+// GetLatestDate retrieves the latest committer date of a repository's
+// commits, where incremental syncs continue from
+func (db *DB) GetLatestDate(ctx context.Context, repoID int) (time.Time, error) {
+	if repoID <= 0 {
+		return time.Time{}, fmt.Errorf("%w: invalid repository id %d", ErrInvalidInput, repoID)
 	}
 
 	var latestDate sql.NullTime
 	query := `
-		SELECT MAX(c.date) as max_date
+		SELECT MAX(COALESCE(c.committer_date, c.date)) as max_date
 		FROM commits c
 		JOIN repositories r ON c.repository_id = r.id
-		WHERE r.name = $1
+		WHERE r.id = $1 AND r.tenant_id = $2
 	`
 
-	if err := db.conn.GetContext(ctx, &latestDate, query, repoName); err != nil {
+	if err := db.conn.GetContext(ctx, &latestDate, query, repoID, tenant.FromContext(ctx)); err != nil {
 		if err == sql.ErrNoRows {
-			return time.Time{}, fmt.Errorf("%w: repository %s not found", ErrRepositoryNotFound, repoName)
+			return time.Time{}, fmt.Errorf("%w: repository %d not found", ErrRepositoryNotFound, repoID)
 		}
-		return time.Time{}, fmt.Errorf("failed to get latest commit date for repository %s: %w", repoName, err)
+		return time.Time{}, fmt.Errorf("failed to get latest commit date for repository %d: %w", repoID, err)
 	}
 
 	if !latestDate.Valid {
-		return time.Time{}, fmt.Errorf("%w: repository %s", ErrNoCommitsFound, repoName)
+		return time.Time{}, fmt.Errorf("%w: repository %d", ErrNoCommitsFound, repoID)
 	}
 
 	return latestDate.Time, nil
@@ -47,82 +58,542 @@ func (db *DB) BatchInsert(ctx context.Context, commits []models.Commit) error {
 	}
 
 	safeLogInfo("Starting batch insertion of commits", zap.Int("count", len(commits)))
-	tx, err := db.conn.BeginTx(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("%w: %v", ErrTransactionFailed, err)
+	if err := db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		return db.writeCommits(ctx, tx.Tx, commits)
+	}); err != nil {
+		return err
 	}
-	defer tx.Rollback()
 
-	query := `
-		INSERT INTO commits (sha, repository_id, message, author_name, date, url)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		ON CONFLICT (sha) DO UPDATE SET
-			message = EXCLUDED.message,
-			author_name = EXCLUDED.author_name,
-			date = EXCLUDED.date,
-			url = EXCLUDED.url
-		WHERE commits.date < EXCLUDED.date
-	`
+	safeLogInfo("Successfully inserted commits", zap.Int("count", len(commits)))
+	return nil
+}
+
+// commitColumns lists the columns selected for commit queries, from commits
+// aliased as c
+const commitColumns = `c.id, c.sha, c.repository_id, COALESCE(c.message, '') AS message,
+			COALESCE(c.author_name, '') AS author_name, c.date, COALESCE(c.url, '') AS url,
+			COALESCE(c.api_url, '') AS api_url, COALESCE(c.message_hash, '') AS message_hash, c.message_gzip,
+			COALESCE(c.committer_date, c.date) AS committer_date, c.created_at`
+
+// commitUpsertQuery inserts a commit or updates its stored values
+const commitUpsertQuery = `
+	INSERT INTO commits (sha, repository_id, message, author_name, date, url, message_hash, api_url, committer_date, message_gzip)
+	VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8, ''), $9, $10)
+	ON CONFLICT (repository_id, sha) DO UPDATE SET
+		message = EXCLUDED.message,
+		message_gzip = EXCLUDED.message_gzip,
+		author_name = EXCLUDED.author_name,
+		date = EXCLUDED.date,
+		url = EXCLUDED.url,
+		message_hash = EXCLUDED.message_hash,
+		api_url = EXCLUDED.api_url,
+		committer_date = EXCLUDED.committer_date,
+		updated_at = CURRENT_TIMESTAMP
+	WHERE (commits.message, commits.author_name, commits.date, commits.url, commits.message_hash, commits.api_url, commits.committer_date, commits.message_gzip)
+		IS DISTINCT FROM (EXCLUDED.message, EXCLUDED.author_name, EXCLUDED.date, EXCLUDED.url, EXCLUDED.message_hash, EXCLUDED.api_url, EXCLUDED.committer_date, EXCLUDED.message_gzip)
+`
 
-	stmt, err := tx.PrepareContext(ctx, query)
+// commitUpsertArgs returns the parameters of commitUpsertQuery for commit
+func (db *DB) commitUpsertArgs(commit models.Commit) ([]interface{}, error) {
+	message, compressed, err := db.storedMessage(commit.Message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert commit %s: %w", commit.SHA, err)
+	}
+	// A nil slice would be written as an empty value rather than NULL
+	var compressedArg interface{}
+	if compressed != nil {
+		compressedArg = compressed
+	}
+	return []interface{}{
+		commit.SHA,
+		commit.RepoID,
+		message,
+		commit.AuthorName,
+		commit.Date,
+		commit.URL,
+		commit.MessageHash,
+		commit.APIURL,
+		commit.CommitterDate,
+		compressedArg,
+	}, nil
+}
+
+// insertCommits upserts commits within tx, one row at a time through a
+// prepared statement. Existing rows are only rewritten when a value changed,
+// so re-ingesting identical commits is a no-op while re-parsed commits (e.g.
+// from a replay) replace the stored values. A page is stored in one
+// transaction, which runs its statements one after another on a single
+// connection, so inserting from several goroutines would not speed it up.
+func (db *DB) insertCommits(ctx context.Context, tx *sql.Tx, commits []models.Commit) error {
+	started := time.Now()
+	stmt, err := tx.PrepareContext(ctx, commitUpsertQuery)
 	if err != nil {
 		return fmt.Errorf("failed to prepare commit insert statement: %w", err)
 	}
 	defer stmt.Close()
 
-	// Use a worker pool for batch processing
-	const batchSize = 1000
-	const maxWorkers = 5
-	sem := make(chan struct{}, maxWorkers)
-	errChan := make(chan error, len(commits))
-	var wg sync.WaitGroup
-
-	for i := 0; i < len(commits); i += batchSize {
-		end := i + batchSize
-		if end > len(commits) {
-			end = len(commits)
-		}
-
-		batch := commits[i:end]
-		wg.Add(1)
-		go func(batch []models.Commit) {
-			defer wg.Done()
-			sem <- struct{}{}        // Acquire semaphore
-			defer func() { <-sem }() // Release semaphore
-
-			for _, commit := range batch {
-				if _, err := stmt.ExecContext(ctx,
-					commit.SHA,
-					commit.RepoID,
-					commit.Message,
-					commit.AuthorName,
-					commit.Date,
-					commit.URL,
-				); err != nil {
-					errChan <- fmt.Errorf("failed to insert commit %s: %w", commit.SHA, err)
-					return
-				}
-			}
-		}(batch)
+	for _, commit := range commits {
+		args, err := db.commitUpsertArgs(commit)
+		if err != nil {
+			return err
+		}
+		if _, err := stmt.ExecContext(ctx, args...); err != nil {
+			return fmt.Errorf("failed to insert commit %s: %w", commit.SHA, err)
+		}
 	}
 
-	wg.Wait()
-	close(errChan)
+	metrics.DBInsertRows.Add(int64(len(commits)))
+	metrics.DBInsertMillis.Add(time.Since(started).Milliseconds())
+	return nil
+}
 
-	// Collect errors
-	var errs []error
-	for err := range errChan {
-		errs = append(errs, err)
+// writeCommits inserts commits and their parents within tx. Unless failed
+// commits are isolated, one failing commit fails them all.
+func (db *DB) writeCommits(ctx context.Context, tx *sql.Tx, commits []models.Commit) error {
+	if !db.isolateFailedCommits {
+		if err := db.insertCommits(ctx, tx, commits); err != nil {
+			return err
+		}
+		return insertCommitParents(ctx, tx, commits, nil)
 	}
 
-	if len(errs) > 0 {
-		return fmt.Errorf("errors occurred while inserting commits: %v", errs)
+	rejected, err := db.insertCommitsIsolated(ctx, tx, commits)
+	if err != nil {
+		return err
 	}
+	if err := insertCommitParents(ctx, tx, commits, rejected); err != nil {
+		return err
+	}
+	if len(rejected) == 0 {
+		return nil
+	}
+	if err := recordRejectedCommits(ctx, tx, rejected); err != nil {
+		return err
+	}
+
+	metrics.CommitsRejected.Add(int64(len(rejected)))
+	logger.Warn("Rejected commits that failed to insert",
+		zap.Int("rejected", len(rejected)),
+		zap.Int("inserted", len(commits)-len(rejected)),
+		zap.String("first_error", rejected[0].Error))
+	return nil
+}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("%w: failed to commit transaction: %v", ErrTransactionFailed, err)
+// insertCommitParents stores the parents of the inserted commits, skipping
+// rejected ones, in a single statement. Parents never change for a SHA, so
+// parents already stored are left alone.
+func insertCommitParents(ctx context.Context, tx *sql.Tx, commits []models.Commit, rejected []models.RejectedCommit) error {
+	skip := make(map[string]bool, len(rejected))
+	for _, r := range rejected {
+		skip[r.SHA] = true
 	}
 
-	safeLogInfo("Successfully inserted commits", zap.Int("count", len(commits)))
+	var repoIDs, positions []int64
+	var shas, parents []string
+	for _, c := range commits {
+		if skip[c.SHA] {
+			continue
+		}
+		for i, parent := range c.Parents {
+			repoIDs = append(repoIDs, int64(c.RepoID))
+			shas = append(shas, c.SHA)
+			positions = append(positions, int64(i))
+			parents = append(parents, parent)
+		}
+	}
+	if len(shas) == 0 {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO commit_parents (repository_id, sha, position, parent_sha)
+		SELECT * FROM unnest($1::int[], $2::text[], $3::smallint[], $4::text[])
+		ON CONFLICT DO NOTHING
+	`, pq.Array(repoIDs), pq.Array(shas), pq.Array(positions), pq.Array(parents)); err != nil {
+		return fmt.Errorf("failed to insert commit parents: %w", err)
+	}
 	return nil
 }
+
+// insertCommitsIsolated inserts the whole batch at once and, only if that
+// fails, retries commit by commit, each under its own savepoint so a failing
+// commit does not abort the transaction. It returns the commits that failed.
+func (db *DB) insertCommitsIsolated(ctx context.Context, tx *sql.Tx, commits []models.Commit) ([]models.RejectedCommit, error) {
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT commit_batch"); err != nil {
+		return nil, fmt.Errorf("failed to create savepoint: %w", err)
+	}
+	batchErr := db.insertCommits(ctx, tx, commits)
+	if batchErr == nil {
+		if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT commit_batch"); err != nil {
+			return nil, fmt.Errorf("failed to release savepoint: %w", err)
+		}
+		return nil, nil
+	}
+	if ctx.Err() != nil {
+		return nil, batchErr
+	}
+	if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT commit_batch"); err != nil {
+		return nil, fmt.Errorf("failed to roll back to savepoint: %w", err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, commitUpsertQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare commit insert statement: %w", err)
+	}
+	defer stmt.Close()
+
+	var rejected []models.RejectedCommit
+	for _, commit := range commits {
+		if _, err := tx.ExecContext(ctx, "SAVEPOINT commit_row"); err != nil {
+			return nil, fmt.Errorf("failed to create savepoint: %w", err)
+		}
+		args, err := db.commitUpsertArgs(commit)
+		if err != nil {
+			return nil, err
+		}
+		_, insertErr := stmt.ExecContext(ctx, args...)
+		if insertErr == nil {
+			if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT commit_row"); err != nil {
+				return nil, fmt.Errorf("failed to release savepoint: %w", err)
+			}
+			continue
+		}
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("failed to insert commit %s: %w", commit.SHA, insertErr)
+		}
+		if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT commit_row"); err != nil {
+			return nil, fmt.Errorf("failed to roll back to savepoint: %w", err)
+		}
+
+		payload, err := json.Marshal(commit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode rejected commit %s: %w", commit.SHA, err)
+		}
+		rejected = append(rejected, models.RejectedCommit{
+			RepoID:  commit.RepoID,
+			SHA:     commit.SHA,
+			Payload: payload,
+			Error:   insertErr.Error(),
+		})
+	}
+	return rejected, nil
+}
+
+// recordRejectedCommits stores commits that failed to insert along with
+// their error. The commit itself is kept as encoded bytes, which Postgres
+// accepts whatever made the commit fail.
+func recordRejectedCommits(ctx context.Context, tx *sql.Tx, rejected []models.RejectedCommit) error {
+	query := `
+		INSERT INTO rejected_commits (repository_id, sha, payload, error)
+		VALUES ($1, $2, $3, $4)
+	`
+	for _, r := range rejected {
+		if _, err := tx.ExecContext(ctx, query, r.RepoID, postgresText(r.SHA), r.Payload, postgresText(r.Error)); err != nil {
+			return fmt.Errorf("failed to record rejected commit %s: %w", r.SHA, err)
+		}
+	}
+	return nil
+}
+
+// postgresText makes s storable in a text column, which rejects NUL bytes
+// and invalid UTF-8
+func postgresText(s string) string {
+	return strings.ReplaceAll(strings.ToValidUTF8(s, "\uFFFD"), "\x00", "")
+}
+
+// KnownCommitSHAs returns which of the given SHAs are already stored for a
+// repository
+func (db *DB) KnownCommitSHAs(ctx context.Context, repoID int, shas []string) (map[string]bool, error) {
+	known := make(map[string]bool)
+	if len(shas) == 0 {
+		return known, nil
+	}
+
+	var stored []string
+	query := `SELECT sha FROM commits WHERE repository_id = $1 AND sha = ANY($2)`
+	if err := sqlx.SelectContext(ctx, db.ext(ctx), &stored, query, repoID, pq.Array(shas)); err != nil {
+		return nil, fmt.Errorf("failed to look up commits of repository %d: %w", repoID, err)
+	}
+	for _, sha := range stored {
+		known[sha] = true
+	}
+	return known, nil
+}
+
+// SampleCommits returns up to n commits of a repository picked at random
+func (db *DB) SampleCommits(ctx context.Context, repoID, n int) ([]models.Commit, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("%w: sample size must be positive", ErrInvalidInput)
+	}
+
+	commits := []models.Commit{}
+	query := `
+		SELECT ` + commitColumns + `
+		FROM commits c
+		WHERE c.repository_id = $1
+		ORDER BY random()
+		LIMIT $2
+	`
+	if err := db.conn.SelectContext(ctx, &commits, query, repoID, n); err != nil {
+		return nil, fmt.Errorf("failed to sample commits of repository %d: %w", repoID, err)
+	}
+	if err := inflateMessages(commits); err != nil {
+		return nil, err
+	}
+	return commits, nil
+}
+
+// IngestCommitPage stores one page of commits exactly once.
+//
+// A page is identified by its repository and a hash of its content, so a
+// page that is retried, replayed or fetched concurrently by another instance
+// is only ingested by the first transaction to claim it; later attempts
+// return false without writing. The cursor records where the page came from.
+// Claiming the page and inserting its commits happen in one transaction, so a
+// failed ingestion leaves the page unclaimed for the next attempt.
+func (db *DB) IngestCommitPage(ctx context.Context, repoID int, cursor string, commits []models.Commit) (bool, error) {
+	if len(commits) == 0 {
+		return false, nil
+	}
+
+	// Concurrent claims of the same page block on the unique index until the
+	// first transaction finishes, then either conflict or take over
+	hash := PageContentHash(commits)
+	err := db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		var pageID int
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO ingested_pages (repository_id, page_cursor, content_hash, commit_count)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (repository_id, content_hash) DO NOTHING
+			RETURNING id
+		`, repoID, cursor, hash, len(commits)).Scan(&pageID)
+		if err == sql.ErrNoRows {
+			return errPageIngested
+		}
+		if err != nil {
+			return fmt.Errorf("failed to claim page %s: %w", cursor, err)
+		}
+		return db.writeCommits(ctx, tx.Tx, commits)
+	})
+	if err == errPageIngested {
+		safeLogInfo("Skipping already ingested page",
+			zap.Int("repository_id", repoID),
+			zap.String("cursor", cursor),
+			zap.String("content_hash", hash))
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	safeLogInfo("Ingested commit page",
+		zap.Int("repository_id", repoID),
+		zap.String("cursor", cursor),
+		zap.Int("count", len(commits)))
+	return true, nil
+}
+
+// errPageIngested rolls back the claim of a page ingested before
+var errPageIngested = errors.New("page already ingested")
+
+// PageContentHash returns the idempotency key of a page of commits: the hex
+// SHA-256 of the stored fields of each commit, in SHA order
+func PageContentHash(commits []models.Commit) string {
+	sorted := make([]models.Commit, len(commits))
+	copy(sorted, commits)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].SHA < sorted[j].SHA })
+
+	h := sha256.New()
+	for _, c := range sorted {
+		// Length-prefix each field so values cannot run into each other
+		fields := []string{
+			c.SHA, c.Message, c.AuthorName, c.Date.UTC().Format(time.RFC3339Nano), c.URL, c.APIURL,
+			c.CommitterDate.UTC().Format(time.RFC3339Nano),
+		}
+		// Pages stored without parents keep their hash, so enabling parent
+		// ingestion makes a reset sync write them again with parents
+		if len(c.Parents) > 0 {
+			fields = append(fields, strings.Join(c.Parents, " "))
+		}
+		for _, field := range fields {
+			fmt.Fprintf(h, "%d:%s", len(field), field)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ListCommits returns a page of commits for a repository, newest first,
+// limited to the commits matching the named path filter unless pathFilter is
+// empty
+func (db *DB) ListCommits(ctx context.Context, repoName, pathFilter string, params models.PaginationParams) ([]models.Commit, error) {
+	if repoName == "" {
+		return nil, fmt.Errorf("%w: repository name cannot be empty", ErrInvalidInput)
+	}
+
+	condition, filterArgs, err := db.pathFilterCondition(ctx, repoName, pathFilter, 5)
+	if err != nil {
+		return nil, err
+	}
+
+	commits := []models.Commit{}
+	query := `
+		SELECT ` + commitColumns + `
+		FROM commits c
+		JOIN repositories r ON c.repository_id = r.id
+		WHERE r.name = $1 AND r.tenant_id = $2` + condition + `
+		ORDER BY c.date DESC, c.id DESC
+		LIMIT $3 OFFSET $4
+	`
+
+	args := append([]interface{}{repoName, tenant.FromContext(ctx), params.PageSize, params.Offset()}, filterArgs...)
+	if err := db.conn.SelectContext(ctx, &commits, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to list commits for repository %s: %w", repoName, err)
+	}
+	if err := inflateMessages(commits); err != nil {
+		return nil, err
+	}
+
+	return commits, nil
+}
+
+// minSHAPrefix is the fewest characters of a SHA a commit is looked up by,
+// as git abbreviates them
+const minSHAPrefix = 7
+
+// GetCommitBySHA returns the commit of the context's tenant with the given
+// SHA, with its parents when they were ingested and its files when they were
+// fetched. A SHA of at least minSHAPrefix characters but shorter than a full
+// one matches the commits starting with it. The commit is looked up in the
+// named repository, or in every repository when repoName is empty; a SHA
+// matching more than one commit is rejected.
+func (db *DB) GetCommitBySHA(ctx context.Context, repoName, sha string) (*models.CommitDetail, error) {
+	sha = strings.ToLower(sha)
+	if len(sha) < minSHAPrefix || strings.Trim(sha, "0123456789abcdef") != "" {
+		return nil, fmt.Errorf("%w: invalid commit SHA %q, want at least %d hexadecimal characters", ErrInvalidInput, sha, minSHAPrefix)
+	}
+	// Full SHA-1 and SHA-256 object names match exactly, so the primary
+	// key serves the lookup
+	condition, arg := "c.sha = $2", sha
+	if len(sha) != 40 && len(sha) != 64 {
+		condition, arg = "c.sha LIKE $2", sha+"%"
+	}
+
+	var rows []struct {
+		models.CommitDetail
+		FilesFetched bool `db:"files_fetched"`
+	}
+	query := `
+		SELECT ` + commitColumns + `,
+			r.owner AS repo_owner, r.name AS repo_name, c.files_fetched
+		FROM commits c
+		JOIN repositories r ON c.repository_id = r.id
+		WHERE r.tenant_id = $1 AND ` + condition + ` AND ($3 = '' OR r.name = $3)
+		ORDER BY r.name, c.sha
+		LIMIT 2
+	`
+	if err := db.conn.SelectContext(ctx, &rows, query, tenant.FromContext(ctx), arg, repoName); err != nil {
+		return nil, fmt.Errorf("failed to get commit %s: %w", sha, err)
+	}
+	switch {
+	case len(rows) == 0 && repoName != "":
+		return nil, fmt.Errorf("%w: commit %s of repository %s", ErrCommitNotFound, sha, repoName)
+	case len(rows) == 0:
+		return nil, fmt.Errorf("%w: commit %s", ErrCommitNotFound, sha)
+	case len(rows) > 1:
+		return nil, fmt.Errorf("%w: commit %s is ambiguous, it matches %s/%s@%s and %s/%s@%s", ErrInvalidInput, sha,
+			rows[0].RepoOwner, rows[0].RepoName, rows[0].SHA, rows[1].RepoOwner, rows[1].RepoName, rows[1].SHA)
+	}
+	commit := rows[0].CommitDetail
+	if err := inflateMessage(&commit.Commit); err != nil {
+		return nil, err
+	}
+
+	if err := db.conn.SelectContext(ctx, &commit.Parents, `
+		SELECT parent_sha FROM commit_parents WHERE repository_id = $1 AND sha = $2 ORDER BY position
+	`, commit.RepoID, commit.SHA); err != nil {
+		return nil, fmt.Errorf("failed to get parents of commit %s: %w", commit.SHA, err)
+	}
+	if rows[0].FilesFetched {
+		commit.Files = []string{}
+		if err := db.conn.SelectContext(ctx, &commit.Files, `
+			SELECT path FROM commit_files WHERE repository_id = $1 AND sha = $2 ORDER BY path
+		`, commit.RepoID, commit.SHA); err != nil {
+			return nil, fmt.Errorf("failed to get files of commit %s: %w", commit.SHA, err)
+		}
+	}
+	return &commit, nil
+}
+
+// CommitsBetween returns the commits of a repository of the context's tenant
+// committed after after and up to until, oldest first, such as the commits
+// between two releases
+func (db *DB) CommitsBetween(ctx context.Context, repoName string, after, until time.Time) ([]models.Commit, error) {
+	commits := []models.Commit{}
+	query := `
+		SELECT ` + commitColumns + `
+		FROM commits c
+		JOIN repositories r ON c.repository_id = r.id
+		WHERE r.name = $1 AND r.tenant_id = $2
+			AND COALESCE(c.committer_date, c.date) > $3 AND COALESCE(c.committer_date, c.date) <= $4
+		ORDER BY COALESCE(c.committer_date, c.date), c.id
+	`
+	if err := db.conn.SelectContext(ctx, &commits, query, repoName, tenant.FromContext(ctx), after, until); err != nil {
+		return nil, fmt.Errorf("failed to list commits between %s and %s for repository %s: %w",
+			after.Format(time.RFC3339), until.Format(time.RFC3339), repoName, err)
+	}
+	if err := inflateMessages(commits); err != nil {
+		return nil, err
+	}
+	return commits, nil
+}
+
+// GetTopAuthors returns the authors with the most commits across the tenant's repositories
+func (db *DB) GetTopAuthors(ctx context.Context, limit int) ([]models.AuthorStats, error) {
+	if limit < 1 {
+		return nil, fmt.Errorf("%w: limit must be positive", ErrInvalidInput)
+	}
+
+	authors := []models.AuthorStats{}
+	query := `
+		SELECT COALESCE(c.author_name, '') AS author_name, COUNT(*) AS count
+		FROM commits c
+		JOIN repositories r ON c.repository_id = r.id
+		WHERE r.tenant_id = $1
+		GROUP BY c.author_name
+		ORDER BY count DESC
+		LIMIT $2
+	`
+
+	if err := db.conn.SelectContext(ctx, &authors, query, tenant.FromContext(ctx), limit); err != nil {
+		return nil, fmt.Errorf("failed to get top authors: %w", err)
+	}
+
+	return authors, nil
+}
+
+// Now returns the database's current time, which the timestamps it writes
+// are taken from
+func (db *DB) Now(ctx context.Context) (time.Time, error) {
+	var now time.Time
+	if err := sqlx.GetContext(ctx, db.ext(ctx), &now, `SELECT CURRENT_TIMESTAMP`); err != nil {
+		return time.Time{}, fmt.Errorf("failed to get database time: %w", err)
+	}
+	return now, nil
+}
+
+// CountCommitWrites returns how many of a repository's commits were inserted,
+// and how many existing ones updated, at or after since
+func (db *DB) CountCommitWrites(ctx context.Context, repoID int, since time.Time) (inserted, updated int, err error) {
+	var counts struct {
+		Inserted int `db:"inserted"`
+		Updated  int `db:"updated"`
+	}
+	query := `SELECT
+			COUNT(*) FILTER (WHERE created_at >= $2) AS inserted,
+			COUNT(*) FILTER (WHERE created_at < $2 AND updated_at >= $2) AS updated
+		FROM commits WHERE repository_id = $1 AND updated_at >= $2`
+	if err := sqlx.GetContext(ctx, db.ext(ctx), &counts, query, repoID, since); err != nil {
+		return 0, 0, fmt.Errorf("failed to count commit writes of repository %d: %w", repoID, err)
+	}
+	return counts.Inserted, counts.Updated, nil
+}
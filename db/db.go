@@ -2,19 +2,25 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"log"
+	"net/url"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/spf13/viper"
 
+	"githubapifetch/backoff"
 	"githubapifetch/logger"
+	"githubapifetch/secrets"
 )
 
 // DB represents a database connection
@@ -25,6 +31,16 @@ type DB struct {
 		sync.RWMutex
 		statements map[string]*sqlx.Stmt
 	}
+	// encryptor protects sensitive columns at rest; nil when no key is configured
+	encryptor secrets.Encryptor
+	// isolateFailedCommits keeps the rest of a batch when single commits
+	// fail to insert, recording those in rejected_commits
+	isolateFailedCommits bool
+	// compressMessages stores long commit messages gzip-compressed
+	compressMessages bool
+	// txBackoff spaces out the retries of transactions that failed to
+	// serialize
+	txBackoff backoff.Backoff
 }
 
 // safeLogInfo safely logs info messages, falling back to standard log if logger is not initialized
@@ -37,23 +53,39 @@ func safeLogInfo(msg string, fields ...zap.Field) {
 	}
 }
 
+// defaultApplicationName tags our sessions in pg_stat_activity
+const defaultApplicationName = "githubapifetch"
+
 // New creates a new database connection
 func New() (*DB, error) {
-	dsn := fmt.Sprintf(
-		"user=%s password=%s dbname=%s port=%s host=%s sslmode=disable",
-		viper.GetString("POSTGRES_USER"),
-		viper.GetString("POSTGRES_PASSWORD"),
-		viper.GetString("POSTGRES_DB"),
-		viper.GetString("POSTGRES_PORT"),
-		viper.GetString("POSTGRES_HOST"),
-	)
-
-	safeLogInfo("Connecting to database", zap.String("dsn", dsn))
-	db, err := sqlx.Connect("postgres", dsn)
+	dsn, redactedDSN, err := buildDSN()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDatabaseConnection, err)
+	}
+
+	// Never log the password
+	safeLogInfo("Connecting to database", zap.String("dsn", redactedDSN))
+	connector, err := pq.NewConnector(dsn)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", ErrDatabaseConnection, err)
 	}
 
+	// Time statements when query logging or the slow query threshold is on
+	var driverConnector driver.Connector = connector
+	queries := queryLog{
+		all:  viper.GetBool("QUERY_LOG"),
+		slow: time.Duration(max(viper.GetInt("SLOW_QUERY_MS"), 0)) * time.Millisecond,
+	}
+	if queries.enabled() {
+		driverConnector = &loggingConnector{Connector: connector, log: queries}
+	}
+
+	db := sqlx.NewDb(sql.OpenDB(driverConnector), "postgres")
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%w: %v", ErrDatabaseConnection, err)
+	}
+
 	// Configure connection pool with defaults
 	maxOpenConns := 25 // Default value
 	if val := viper.GetString("DB_MAX_OPEN_CONNS"); val != "" {
@@ -82,17 +114,125 @@ func New() (*DB, error) {
 
 	// Initialize statement cache
 	database := &DB{
-		conn: db,
+		conn:      db,
+		txBackoff: backoff.Exponential{Base: TxRetryDelay},
 	}
 	database.stmtCache.statements = make(map[string]*sqlx.Stmt)
 
 	safeLogInfo("Database connection established",
 		zap.Int("max_open_conns", maxOpenConns),
 		zap.Int("max_idle_conns", maxIdleConns),
-		zap.Duration("conn_max_lifetime", connMaxLifetime))
+		zap.Duration("conn_max_lifetime", connMaxLifetime),
+		zap.Bool("query_log", queries.all),
+		zap.Duration("slow_query_threshold", queries.slow))
 	return database, nil
 }
 
+// buildDSN returns the connection string and a copy safe to log. DATABASE_URL,
+// when set, replaces the POSTGRES_* connection settings; the SSL, schema and
+// application name settings apply on top of either.
+func buildDSN() (dsn, redacted string, err error) {
+	var params, redactedParams []string
+	if rawURL := viper.GetString("DATABASE_URL"); rawURL != "" {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid DATABASE_URL: %v", err)
+		}
+		dsn, err := pq.ParseURL(rawURL)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid DATABASE_URL: %v", err)
+		}
+		params = append(params, dsn)
+		redactedParams = append(redactedParams, u.Redacted())
+	} else {
+		for _, p := range []struct{ key, setting string }{
+			{"user", "POSTGRES_USER"},
+			{"password", "POSTGRES_PASSWORD"},
+			{"dbname", "POSTGRES_DB"},
+			{"port", "POSTGRES_PORT"},
+			{"host", "POSTGRES_HOST"},
+		} {
+			value := viper.GetString(p.setting)
+			if value == "" {
+				continue
+			}
+			params = append(params, dsnParam(p.key, value))
+			if p.key == "password" {
+				value = "[REDACTED]"
+			}
+			redactedParams = append(redactedParams, dsnParam(p.key, value))
+		}
+	}
+
+	// Without a URL, connections stay unencrypted unless configured
+	sslMode := viper.GetString("POSTGRES_SSLMODE")
+	if sslMode == "" && viper.GetString("DATABASE_URL") == "" {
+		sslMode = "disable"
+	}
+	applicationName := viper.GetString("POSTGRES_APPLICATION_NAME")
+	if applicationName == "" && !strings.Contains(strings.Join(params, " "), "application_name=") {
+		applicationName = defaultApplicationName
+	}
+	for _, p := range []struct{ key, value string }{
+		{"sslmode", sslMode},
+		{"sslrootcert", viper.GetString("POSTGRES_SSLROOTCERT")},
+		// Sent as a run-time parameter, so unqualified names resolve to it
+		{"search_path", viper.GetString("POSTGRES_SCHEMA")},
+		{"application_name", applicationName},
+	} {
+		if p.value != "" {
+			params = append(params, dsnParam(p.key, p.value))
+			redactedParams = append(redactedParams, dsnParam(p.key, p.value))
+		}
+	}
+	return strings.Join(params, " "), strings.Join(redactedParams, " "), nil
+}
+
+// dsnParam formats a key=value connection parameter, quoting the value
+func dsnParam(key, value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+	return key + "='" + value + "'"
+}
+
+// SetEncryptor sets the encryptor used for sensitive columns
+func (db *DB) SetEncryptor(e secrets.Encryptor) {
+	db.encryptor = e
+}
+
+// SetTxBackoff sets the backoff between the retries of transactions that
+// failed to serialize
+func (db *DB) SetTxBackoff(b backoff.Backoff) {
+	db.txBackoff = b
+}
+
+// SetIsolateFailedCommits sets whether a commit that fails to insert is
+// rejected on its own instead of failing its whole batch
+func (db *DB) SetIsolateFailedCommits(enabled bool) {
+	db.isolateFailedCommits = enabled
+}
+
+// encrypt encrypts a sensitive value before it is written
+func (db *DB) encrypt(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	if db.encryptor == nil {
+		return "", ErrEncryptionKeyMissing
+	}
+	return db.encryptor.Encrypt(value)
+}
+
+// decrypt decrypts a sensitive value after it is read
+func (db *DB) decrypt(value string) (string, error) {
+	if !secrets.IsEncrypted(value) {
+		return value, nil
+	}
+	if db.encryptor == nil {
+		return "", ErrEncryptionKeyMissing
+	}
+	return db.encryptor.Decrypt(value)
+}
+
 // getStmt returns a prepared statement from cache or creates a new one
 func (db *DB) getStmt(ctx context.Context, query string) (*sqlx.Stmt, error) {
 	db.stmtCache.RLock()
@@ -0,0 +1,185 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"githubapifetch/models"
+)
+
+// DeploymentTerminalStates are the deployment states that no longer change
+// on their own
+var DeploymentTerminalStates = []string{"success", "failure", "error", "inactive"}
+
+const deploymentColumns = `repository_id, github_id, sha, ref, environment, creator_login, state, log_url, requested_at, succeeded_at`
+
+// StoreDeployments inserts or updates deployments of a repository. A
+// deployment keeps the first time it succeeded.
+func (db *DB) StoreDeployments(ctx context.Context, repoID int, deployments []models.Deployment) error {
+	if len(deployments) == 0 {
+		return nil
+	}
+	return db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		for _, d := range deployments {
+			if _, err := tx.ExecContext(ctx, `
+				INSERT INTO deployments (repository_id, github_id, sha, ref, environment, creator_login, state, log_url, requested_at, succeeded_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+				ON CONFLICT (repository_id, github_id) DO UPDATE SET
+					state = EXCLUDED.state,
+					log_url = EXCLUDED.log_url,
+					succeeded_at = COALESCE(deployments.succeeded_at, EXCLUDED.succeeded_at),
+					updated_at = CURRENT_TIMESTAMP
+			`, repoID, d.GitHubID, d.SHA, d.Ref, d.Environment, d.CreatorLogin, d.State, d.LogURL, d.RequestedAt, d.SucceededAt); err != nil {
+				return fmt.Errorf("failed to store deployment %d of repository %d: %w", d.GitHubID, repoID, err)
+			}
+		}
+		return nil
+	})
+}
+
+// DeploymentSyncStart returns when deployment syncs of a repository start
+// listing: at the oldest stored deployment still in progress, whose status
+// may have changed, or else at the newest stored deployment. It is the zero
+// time when none is stored.
+func (db *DB) DeploymentSyncStart(ctx context.Context, repoID int) (time.Time, error) {
+	var start sql.NullTime
+	if err := sqlx.GetContext(ctx, db.ext(ctx), &start, `
+		SELECT COALESCE(MIN(requested_at) FILTER (WHERE NOT state = ANY($2)), MAX(requested_at))
+		FROM deployments WHERE repository_id = $1
+	`, repoID, pq.Array(DeploymentTerminalStates)); err != nil {
+		return time.Time{}, fmt.Errorf("failed to get deployment sync start of repository %d: %w", repoID, err)
+	}
+	return start.Time, nil
+}
+
+// DeploymentCommits returns the stored commits a deployment of a repository
+// of the context's tenant shipped, identified by its GitHub ID. Commits are
+// told apart by their committer dates, like in changelogs.
+func (db *DB) DeploymentCommits(ctx context.Context, repoName string, githubID int64) (*models.DeploymentCommits, error) {
+	repo, err := db.GetByName(ctx, repoName)
+	if err != nil {
+		return nil, err
+	}
+	result := &models.DeploymentCommits{}
+	if err := db.conn.GetContext(ctx, &result.Deployment,
+		`SELECT `+deploymentColumns+` FROM deployments WHERE repository_id = $1 AND github_id = $2`, repo.ID, githubID,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: deployment %d of repository %s", ErrDeploymentNotFound, githubID, repoName)
+		}
+		return nil, fmt.Errorf("failed to get deployment %d of repository %s: %w", githubID, repoName, err)
+	}
+	result.Deployment.HTMLURL = deploymentsURL(repo.URL, result.Deployment.Environment)
+
+	var previous models.Deployment
+	err = db.conn.GetContext(ctx, &previous, `
+		SELECT `+deploymentColumns+` FROM deployments
+		WHERE repository_id = $1 AND environment = $2 AND succeeded_at IS NOT NULL
+			AND requested_at < $3 AND github_id <> $4
+		ORDER BY requested_at DESC
+		LIMIT 1
+	`, repo.ID, result.Deployment.Environment, result.Deployment.RequestedAt, githubID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return nil, fmt.Errorf("failed to get the deployment before deployment %d of repository %s: %w", githubID, repoName, err)
+	default:
+		previous.HTMLURL = result.Deployment.HTMLURL
+		result.Previous = &previous
+		result.CompareURL = fmt.Sprintf("%s/compare/%s...%s", repo.URL, previous.SHA, result.Deployment.SHA)
+	}
+
+	until, err := db.committedAt(ctx, repo.ID, result.Deployment.SHA)
+	if err != nil {
+		return nil, err
+	}
+	// A previous commit that is not stored predates the sync, so every
+	// stored commit up to the deployed one shipped
+	var after time.Time
+	if result.Previous != nil {
+		after, err = db.committedAt(ctx, repo.ID, result.Previous.SHA)
+		if err != nil && !errors.Is(err, ErrCommitNotFound) {
+			return nil, err
+		}
+	}
+	if result.Commits, err = db.CommitsBetween(ctx, repoName, after, until); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// CommitDeployment returns when a stored commit of a repository of the
+// context's tenant first reached an environment: the first successful
+// deployment of a commit committed at or after it
+func (db *DB) CommitDeployment(ctx context.Context, repoName, sha, environment string) (*models.CommitDeployment, error) {
+	repo, err := db.GetByName(ctx, repoName)
+	if err != nil {
+		return nil, err
+	}
+	result := &models.CommitDeployment{Environment: environment}
+	if err := db.conn.GetContext(ctx, &result.Commit, `
+		SELECT `+commitColumns+`
+		FROM commits c
+		WHERE c.repository_id = $1 AND c.sha = $2
+	`, repo.ID, sha); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: commit %s of repository %s", ErrCommitNotFound, sha, repoName)
+		}
+		return nil, fmt.Errorf("failed to get commit %s of repository %s: %w", sha, repoName, err)
+	}
+	if err := inflateMessage(&result.Commit); err != nil {
+		return nil, err
+	}
+
+	var deployment models.Deployment
+	err = db.conn.GetContext(ctx, &deployment, `
+		SELECT d.repository_id, d.github_id, d.sha, d.ref, d.environment, d.creator_login, d.state, d.log_url, d.requested_at, d.succeeded_at
+		FROM deployments d
+		JOIN commits c ON c.repository_id = d.repository_id AND c.sha = d.sha
+		WHERE d.repository_id = $1 AND d.environment = $2 AND d.succeeded_at IS NOT NULL
+			AND COALESCE(c.committer_date, c.date) >= $3
+		ORDER BY d.succeeded_at
+		LIMIT 1
+	`, repo.ID, environment, result.Commit.CommitterDate)
+	if errors.Is(err, sql.ErrNoRows) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the deployment of commit %s of repository %s: %w", sha, repoName, err)
+	}
+	deployment.HTMLURL = deploymentsURL(repo.URL, environment)
+	result.Deployment = &deployment
+	hours := deployment.SucceededAt.Sub(result.Commit.CommitterDate).Hours()
+	result.LeadTimeHours = &hours
+	return result, nil
+}
+
+// committedAt returns the committer date of a stored commit
+func (db *DB) committedAt(ctx context.Context, repoID int, sha string) (time.Time, error) {
+	var committed time.Time
+	if err := db.conn.GetContext(ctx, &committed,
+		`SELECT COALESCE(committer_date, date) FROM commits WHERE repository_id = $1 AND sha = $2`, repoID, sha,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return time.Time{}, fmt.Errorf("%w: commit %s of repository %d", ErrCommitNotFound, sha, repoID)
+		}
+		return time.Time{}, fmt.Errorf("failed to get commit %s of repository %d: %w", sha, repoID, err)
+	}
+	return committed, nil
+}
+
+// deploymentsURL links to the deployments of an environment of a repository
+// on GitHub
+func deploymentsURL(repoURL, environment string) string {
+	if repoURL == "" {
+		return ""
+	}
+	return repoURL + "/deployments/" + url.PathEscape(environment)
+}
@@ -0,0 +1,235 @@
+package db
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"go.uber.org/zap"
+
+	"githubapifetch/models"
+)
+
+const dumpFormat = "githubapifetch-dump"
+
+// restoreBatchSize is the number of rows inserted per statement on restore
+const restoreBatchSize = 500
+
+// dumpTables lists the tables a dump holds, each after the tables it
+// references
+var dumpTables = []string{
+	"tenants",
+	"repositories",
+	"commits",
+	"commit_files",
+	"commit_parents",
+	"ingested_pages",
+	"sync_checkpoints",
+	"repository_failures",
+	"rejected_commits",
+	"repository_path_filters",
+	"repository_snapshots",
+	"issues",
+	"pull_requests",
+	"pull_request_reviews",
+	"releases",
+	"deployments",
+	"rate_limit_samples",
+	"owners",
+	"audit_log",
+	"raw_payloads",
+	"webhook_dead_letters",
+}
+
+// serialTables are the dumped tables with a SERIAL id, whose sequences a
+// restore moves past the restored ids
+var serialTables = []string{
+	"tenants",
+	"repositories",
+	"commits",
+	"ingested_pages",
+	"rejected_commits",
+	"repository_path_filters",
+	"repository_snapshots",
+	"issues",
+	"pull_requests",
+	"pull_request_reviews",
+	"releases",
+	"deployments",
+	"rate_limit_samples",
+	"owners",
+	"audit_log",
+	"raw_payloads",
+	"webhook_dead_letters",
+}
+
+// dumpHeader opens every dump
+type dumpHeader struct {
+	Format        string    `json:"format"`
+	SchemaVersion int       `json:"schema_version"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Dump writes every table of every tenant to w as a gzip-compressed stream
+// of JSON lines: a header with the schema version, then for each table a
+// line with its name and row count followed by one line per row. The tables
+// are read in one snapshot, so the dump is consistent while syncs go on.
+func (db *DB) Dump(ctx context.Context, w io.Writer) ([]models.DumpTable, error) {
+	tx, err := db.conn.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTransactionFailed, err)
+	}
+	defer tx.Rollback()
+
+	gz := gzip.NewWriter(w)
+	enc := json.NewEncoder(gz)
+	if err := enc.Encode(dumpHeader{Format: dumpFormat, SchemaVersion: SchemaVersion, CreatedAt: time.Now().UTC()}); err != nil {
+		return nil, fmt.Errorf("failed to write dump: %w", err)
+	}
+
+	tables := make([]models.DumpTable, 0, len(dumpTables))
+	for _, table := range dumpTables {
+		t := models.DumpTable{Table: table}
+		if err := tx.GetContext(ctx, &t.Rows, `SELECT COUNT(*) FROM `+table); err != nil {
+			return nil, fmt.Errorf("failed to count rows of %s: %w", table, err)
+		}
+		if err := enc.Encode(t); err != nil {
+			return nil, fmt.Errorf("failed to write dump: %w", err)
+		}
+		if err := dumpRows(ctx, tx.Tx, gz, table); err != nil {
+			return nil, err
+		}
+		tables = append(tables, t)
+	}
+
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write dump: %w", err)
+	}
+	safeLogInfo("Dumped database", zap.Int("schema_version", SchemaVersion), zap.Int("tables", len(tables)))
+	return tables, nil
+}
+
+// dumpRows writes every row of table as a JSON line
+func dumpRows(ctx context.Context, tx *sql.Tx, w io.Writer, table string) error {
+	rows, err := tx.QueryContext(ctx, `SELECT row_to_json(t) FROM `+table+` t`)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row []byte
+		if err := rows.Scan(&row); err != nil {
+			return fmt.Errorf("failed to read %s: %w", table, err)
+		}
+		if _, err := w.Write(append(row, '\n')); err != nil {
+			return fmt.Errorf("failed to write dump: %w", err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", table, err)
+	}
+	return nil
+}
+
+// Restore replaces every table with the contents of a dump written by Dump,
+// in one transaction. It refuses a dump of another schema version, and a
+// database that already holds repositories unless replace is set.
+func (db *DB) Restore(ctx context.Context, r io.Reader, replace bool) ([]models.DumpTable, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDump, err)
+	}
+	defer gz.Close()
+	dec := json.NewDecoder(gz)
+
+	var header dumpHeader
+	if err := dec.Decode(&header); err != nil || header.Format != dumpFormat {
+		return nil, fmt.Errorf("%w: missing header", ErrInvalidDump)
+	}
+	if header.SchemaVersion != SchemaVersion {
+		return nil, fmt.Errorf("%w: dump has schema version %d, database has %d",
+			ErrInvalidDump, header.SchemaVersion, SchemaVersion)
+	}
+
+	tx, err := db.conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTransactionFailed, err)
+	}
+	defer tx.Rollback()
+
+	if !replace {
+		var populated bool
+		if err := tx.GetContext(ctx, &populated, `SELECT EXISTS (SELECT 1 FROM repositories)`); err != nil {
+			return nil, fmt.Errorf("failed to check for existing repositories: %w", err)
+		}
+		if populated {
+			return nil, ErrDatabaseNotEmpty
+		}
+	}
+
+	truncate := `TRUNCATE ` + dumpTables[0]
+	for _, table := range dumpTables[1:] {
+		truncate += ", " + table
+	}
+	if _, err := tx.ExecContext(ctx, truncate+` RESTART IDENTITY CASCADE`); err != nil {
+		return nil, fmt.Errorf("failed to clear tables: %w", err)
+	}
+
+	tables := make([]models.DumpTable, 0, len(dumpTables))
+	for _, table := range dumpTables {
+		var t models.DumpTable
+		if err := dec.Decode(&t); err != nil || t.Table != table {
+			return nil, fmt.Errorf("%w: expected table %s", ErrInvalidDump, table)
+		}
+		if err := restoreRows(ctx, tx.Tx, dec, t); err != nil {
+			return nil, err
+		}
+		tables = append(tables, t)
+	}
+
+	for _, table := range serialTables {
+		query := `SELECT setval(pg_get_serial_sequence('` + table + `', 'id'), COALESCE(MAX(id), 0) + 1, false) FROM ` + table
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return nil, fmt.Errorf("failed to reset id sequence of %s: %w", table, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTransactionFailed, err)
+	}
+	safeLogInfo("Restored database",
+		zap.Int("schema_version", header.SchemaVersion),
+		zap.Time("dumped_at", header.CreatedAt))
+	return tables, nil
+}
+
+// restoreRows inserts the rows of one table of a dump in batches
+func restoreRows(ctx context.Context, tx *sql.Tx, dec *json.Decoder, t models.DumpTable) error {
+	query := `INSERT INTO ` + t.Table + ` SELECT * FROM json_populate_recordset(NULL::` + t.Table + `, $1)`
+	var batch bytes.Buffer
+	for i := 0; i < t.Rows; i += restoreBatchSize {
+		batch.Reset()
+		batch.WriteByte('[')
+		for j := i; j < t.Rows && j < i+restoreBatchSize; j++ {
+			var row json.RawMessage
+			if err := dec.Decode(&row); err != nil {
+				return fmt.Errorf("%w: %s ends after %d of %d rows", ErrInvalidDump, t.Table, j, t.Rows)
+			}
+			if j > i {
+				batch.WriteByte(',')
+			}
+			batch.Write(row)
+		}
+		batch.WriteByte(']')
+		if _, err := tx.ExecContext(ctx, query, batch.String()); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", t.Table, err)
+		}
+	}
+	return nil
+}
@@ -1,12 +1,66 @@
 package db
 
-import "fmt"
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/lib/pq"
+)
 
 // Common errors
 var (
-	ErrNoCommitsFound     = fmt.Errorf("no commits found")
-	ErrRepositoryNotFound = fmt.Errorf("repository not found")
-	ErrInvalidInput       = fmt.Errorf("invalid input")
-	ErrDatabaseConnection = fmt.Errorf("database connection error")
-	ErrTransactionFailed  = fmt.Errorf("transaction failed")
+	ErrNoCommitsFound       = fmt.Errorf("no commits found")
+	ErrRepositoryNotFound   = fmt.Errorf("repository not found")
+	ErrInvalidInput         = fmt.Errorf("invalid input")
+	ErrDatabaseConnection   = fmt.Errorf("database connection error")
+	ErrTransactionFailed    = fmt.Errorf("transaction failed")
+	ErrTenantNotFound       = fmt.Errorf("tenant not found")
+	ErrEncryptionKeyMissing = fmt.Errorf("encryption key required for sensitive columns")
+	ErrCheckpointNotFound   = fmt.Errorf("sync checkpoint not found")
+	ErrPathFilterNotFound   = fmt.Errorf("path filter not found")
+	ErrCommitNotFound       = fmt.Errorf("commit not found")
+	ErrDeploymentNotFound   = fmt.Errorf("deployment not found")
+	ErrOwnerNotFound        = fmt.Errorf("owner not found")
+	ErrInvalidDump          = fmt.Errorf("invalid dump")
+	ErrDatabaseNotEmpty     = fmt.Errorf("database already holds repositories")
+	ErrSchemaOutdated       = fmt.Errorf("database schema is outdated")
 )
+
+// Postgres error codes of a server that is shutting down or not yet up
+const (
+	adminShutdown  = "57P01"
+	crashShutdown  = "57P02"
+	cannotConnect  = "57P03"
+	connectionFail = "08"
+)
+
+// IsUnavailable reports whether err means the database could not be reached,
+// rather than that it refused the statement: the connection was refused,
+// dropped or timed out, or the server is shutting down or starting up.
+// Cancelled contexts are not outages.
+func IsUnavailable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, ErrDatabaseConnection) || errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, sql.ErrConnDone) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		code := string(pqErr.Code)
+		return strings.HasPrefix(code, connectionFail) ||
+			code == adminShutdown || code == crashShutdown || code == cannotConnect
+	}
+	return false
+}
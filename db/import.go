@@ -0,0 +1,56 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+
+	"githubapifetch/models"
+	"githubapifetch/tenant"
+)
+
+// RegisterRepositories adds repositories to the context's tenant without
+// syncing them; monitoring syncs each from its start date on the next poll.
+// Repositories already stored, whatever their status, are left untouched.
+// All rows are inserted in one transaction. It returns how many were added.
+func (db *DB) RegisterRepositories(ctx context.Context, repos []models.Repository) (int, error) {
+	for _, repo := range repos {
+		if repo.Name == "" || repo.Owner == "" {
+			return 0, fmt.Errorf("%w: repository name and owner cannot be empty", ErrInvalidInput)
+		}
+	}
+
+	// Metadata columns are filled in by the first sync
+	query := `
+		INSERT INTO repositories (
+			tenant_id, owner, name, url, created_at, updated_at, description, language,
+			forks_count, stars_count, open_issues_count, watchers_count, status,
+			start_date, start_date_auto, poll_interval
+		)
+		VALUES ($1, $2, $3, '', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, '', '', 0, 0, 0, 0, $4, $5, $6, NULLIF($7, 0))
+		ON CONFLICT (tenant_id, name, owner) DO NOTHING
+	`
+	tenantID := tenant.FromContext(ctx)
+	added := 0
+	if err := db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		added = 0
+		for _, repo := range repos {
+			result, err := tx.ExecContext(ctx, query,
+				tenantID, repo.Owner, repo.Name, models.RepoStatusActive, repo.StartDate, repo.StartDateAuto, repo.PollInterval)
+			if err != nil {
+				return fmt.Errorf("failed to register repository %s/%s: %w", repo.Owner, repo.Name, err)
+			}
+			if rows, err := result.RowsAffected(); err == nil {
+				added += int(rows)
+			}
+		}
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+
+	safeLogInfo("Repositories registered", zap.Int("added", added), zap.Int("existing", len(repos)-added))
+	return added, nil
+}
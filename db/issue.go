@@ -0,0 +1,163 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"githubapifetch/models"
+)
+
+// StoreIssues inserts or updates issues of a repository
+func (db *DB) StoreIssues(ctx context.Context, repoID int, issues []models.Issue) error {
+	if len(issues) == 0 {
+		return nil
+	}
+	return db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		for _, issue := range issues {
+			labels := issue.Labels
+			if labels == nil {
+				labels = []string{}
+			}
+			if _, err := tx.ExecContext(ctx, `
+				INSERT INTO issues (repository_id, number, title, state, author_login, labels, opened_at, closed_at, github_updated_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+				ON CONFLICT (repository_id, number) DO UPDATE SET
+					title = EXCLUDED.title,
+					state = EXCLUDED.state,
+					author_login = EXCLUDED.author_login,
+					labels = EXCLUDED.labels,
+					opened_at = EXCLUDED.opened_at,
+					closed_at = EXCLUDED.closed_at,
+					github_updated_at = EXCLUDED.github_updated_at,
+					updated_at = CURRENT_TIMESTAMP
+			`, repoID, issue.Number, issue.Title, issue.State, issue.AuthorLogin, pq.Array(labels),
+				issue.OpenedAt, issue.ClosedAt, issue.GitHubUpdatedAt,
+			); err != nil {
+				return fmt.Errorf("failed to store issue #%d of repository %d: %w", issue.Number, repoID, err)
+			}
+		}
+		return nil
+	})
+}
+
+// LatestIssueUpdate returns when the most recently updated stored issue of
+// a repository last changed on GitHub, where issue syncs continue from, or
+// the zero time when none is stored
+func (db *DB) LatestIssueUpdate(ctx context.Context, repoID int) (time.Time, error) {
+	var latest sql.NullTime
+	if err := sqlx.GetContext(ctx, db.ext(ctx), &latest,
+		`SELECT MAX(github_updated_at) FROM issues WHERE repository_id = $1`, repoID,
+	); err != nil {
+		return time.Time{}, fmt.Errorf("failed to get latest issue update of repository %d: %w", repoID, err)
+	}
+	return latest.Time, nil
+}
+
+// IssueStats describes the stored issues of a repository of the context's
+// tenant over [since, until): how old the issues open at until are, how long
+// the issues closed in the period took, and how many were opened and closed
+// every week. A non-empty label limits them to the issues with that label.
+// With a calendar, ages and times to close count only its working days.
+func (db *DB) IssueStats(ctx context.Context, repoName, label string, since, until time.Time, cal *models.WorkCalendar) (*models.IssueStats, error) {
+	if !since.Before(until) {
+		return nil, fmt.Errorf("%w: the period must end after it starts", ErrInvalidInput)
+	}
+	if err := validCalendar(cal); err != nil {
+		return nil, err
+	}
+	repo, err := db.GetByName(ctx, repoName)
+	if err != nil {
+		return nil, err
+	}
+	stats := &models.IssueStats{Repo: repo.Name, Label: label, Since: since, Until: until, Calendar: cal}
+
+	// width_bucket numbers the buckets from 0, below the first bound
+	bounds := make([]float64, len(models.IssueAgeBuckets))
+	stats.OpenAge = make([]models.AgeBucket, len(bounds)+1)
+	for i, days := range models.IssueAgeBuckets {
+		bounds[i] = float64(days)
+		stats.OpenAge[i].MaxDays = days
+		stats.OpenAge[i+1].MinDays = days
+	}
+	var ages []struct {
+		Bucket int `db:"bucket"`
+		Issues int `db:"issues"`
+	}
+	if err := db.conn.SelectContext(ctx, &ages, `
+		SELECT width_bucket(`+hoursBetween("opened_at", "$3::timestamptz", cal, 5)+` / 24, $4::float8[]) AS bucket, COUNT(*) AS issues
+		FROM issues
+		WHERE repository_id = $1 AND ($2 = '' OR $2 = ANY(labels))
+			AND opened_at < $3 AND (closed_at IS NULL OR closed_at >= $3)
+		GROUP BY bucket
+	`, append([]interface{}{repo.ID, label, until, pq.Array(bounds)}, calendarArgs(cal)...)...); err != nil {
+		return nil, fmt.Errorf("failed to compute open issue ages of repository %s: %w", repoName, err)
+	}
+	for _, age := range ages {
+		stats.OpenAge[age.Bucket].Issues = age.Issues
+		stats.Open += age.Issues
+	}
+
+	var closed struct {
+		Closed int             `db:"closed"`
+		P50    sql.NullFloat64 `db:"p50"`
+		P75    sql.NullFloat64 `db:"p75"`
+		P90    sql.NullFloat64 `db:"p90"`
+	}
+	if err := db.conn.GetContext(ctx, &closed, `
+		SELECT COUNT(*) AS closed,
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY hours) AS p50,
+			percentile_cont(0.75) WITHIN GROUP (ORDER BY hours) AS p75,
+			percentile_cont(0.9) WITHIN GROUP (ORDER BY hours) AS p90
+		FROM (
+			SELECT `+hoursBetween("opened_at", "closed_at", cal, 5)+` AS hours
+			FROM issues
+			WHERE repository_id = $1 AND ($2 = '' OR $2 = ANY(labels))
+				AND closed_at >= $3 AND closed_at < $4
+		) closed
+	`, append([]interface{}{repo.ID, label, since, until}, calendarArgs(cal)...)...); err != nil {
+		return nil, fmt.Errorf("failed to compute time to close of repository %s: %w", repoName, err)
+	}
+	stats.Closed = closed.Closed
+	if closed.Closed > 0 {
+		stats.TimeToClose = &models.DurationPercentiles{P50: closed.P50.Float64, P75: closed.P75.Float64, P90: closed.P90.Float64}
+	}
+
+	stats.Weekly = []models.IssueThroughput{}
+	if err := db.conn.SelectContext(ctx, &stats.Weekly, `
+		WITH scoped AS (
+			SELECT opened_at, closed_at
+			FROM issues
+			WHERE repository_id = $1 AND ($2 = '' OR $2 = ANY(labels))
+		)
+		SELECT w.week,
+			(SELECT COUNT(*) FROM scoped s
+				WHERE s.opened_at >= GREATEST(w.week, $3) AND s.opened_at < LEAST(w.week + INTERVAL '1 week', $4)) AS opened,
+			(SELECT COUNT(*) FROM scoped s
+				WHERE s.closed_at >= GREATEST(w.week, $3) AND s.closed_at < LEAST(w.week + INTERVAL '1 week', $4)) AS closed
+		FROM generate_series(date_trunc('week', $3::timestamptz AT TIME ZONE 'UTC') AT TIME ZONE 'UTC', $4::timestamptz, INTERVAL '1 week') AS w(week)
+		WHERE w.week < $4
+		ORDER BY w.week
+	`, repo.ID, label, since, until); err != nil {
+		return nil, fmt.Errorf("failed to compute issue throughput of repository %s: %w", repoName, err)
+	}
+
+	stats.Labels = []models.LabelIssues{}
+	if err := db.conn.SelectContext(ctx, &stats.Labels, `
+		SELECT l.label,
+			COUNT(*) FILTER (WHERE i.opened_at >= $3 AND i.opened_at < $4) AS opened,
+			COUNT(*) FILTER (WHERE i.closed_at >= $3 AND i.closed_at < $4) AS closed
+		FROM issues i, unnest(i.labels) AS l(label)
+		WHERE i.repository_id = $1 AND ($2 = '' OR $2 = ANY(i.labels))
+			AND ((i.opened_at >= $3 AND i.opened_at < $4) OR (i.closed_at >= $3 AND i.closed_at < $4))
+		GROUP BY l.label
+		ORDER BY COUNT(*) DESC, l.label
+	`, repo.ID, label, since, until); err != nil {
+		return nil, fmt.Errorf("failed to count issues by label of repository %s: %w", repoName, err)
+	}
+	return stats, nil
+}
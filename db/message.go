@@ -0,0 +1,78 @@
+package db
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"githubapifetch/models"
+)
+
+// minCompressedMessage is the shortest message worth compressing: below it
+// the gzip header and the subject kept alongside outweigh the savings
+const minCompressedMessage = 256
+
+// messageWriters reuses gzip writers, whose buffers are costly to allocate
+// for every commit
+var messageWriters = sync.Pool{New: func() any { return gzip.NewWriter(nil) }}
+
+// SetCompressMessages sets whether commit messages are stored compressed.
+// Messages stored either way are read back the same.
+func (db *DB) SetCompressMessages(enabled bool) {
+	db.compressMessages = enabled
+}
+
+// storedMessage returns the message and compressed message columns of a
+// commit message. A compressed message keeps its subject in the message
+// column, so queries on the column still see the first line.
+func (db *DB) storedMessage(message string) (string, []byte, error) {
+	if !db.compressMessages || len(message) < minCompressedMessage {
+		return message, nil, nil
+	}
+	var buf bytes.Buffer
+	zw := messageWriters.Get().(*gzip.Writer)
+	defer messageWriters.Put(zw)
+	zw.Reset(&buf)
+	if _, err := io.WriteString(zw, message); err != nil {
+		return "", nil, fmt.Errorf("failed to compress message: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return "", nil, fmt.Errorf("failed to compress message: %w", err)
+	}
+	subject, _, _ := strings.Cut(message, "\n")
+	if buf.Len()+len(subject) >= len(message) {
+		return message, nil, nil
+	}
+	return subject, buf.Bytes(), nil
+}
+
+// inflateMessages replaces the stored subject of every commit read with a
+// compressed message with the whole message
+func inflateMessages(commits []models.Commit) error {
+	for i := range commits {
+		if err := inflateMessage(&commits[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inflateMessage decompresses the message of c, if it was stored compressed
+func inflateMessage(c *models.Commit) error {
+	if len(c.MessageGzip) == 0 {
+		return nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(c.MessageGzip))
+	if err != nil {
+		return fmt.Errorf("failed to decompress message of commit %s: %w", c.SHA, err)
+	}
+	message, err := io.ReadAll(zr)
+	if err != nil {
+		return fmt.Errorf("failed to decompress message of commit %s: %w", c.SHA, err)
+	}
+	c.Message, c.MessageGzip = string(message), nil
+	return nil
+}
@@ -0,0 +1,10 @@
+ALTER TABLE commits DROP CONSTRAINT IF EXISTS commits_repository_sha_key;
+ALTER TABLE commits ADD CONSTRAINT commits_sha_key UNIQUE (sha);
+
+DROP INDEX IF EXISTS idx_repositories_tenant_id;
+
+ALTER TABLE repositories DROP CONSTRAINT IF EXISTS repositories_tenant_name_owner_key;
+ALTER TABLE repositories ADD CONSTRAINT repositories_name_owner_key UNIQUE (name, owner);
+ALTER TABLE repositories DROP COLUMN IF EXISTS tenant_id;
+
+DROP TABLE IF EXISTS tenants;
@@ -0,0 +1,26 @@
+-- Create tenants table
+CREATE TABLE IF NOT EXISTS tenants (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    github_token TEXT NOT NULL DEFAULT '',
+    repo_owner VARCHAR(255) NOT NULL DEFAULT '',
+    poll_interval INTEGER NOT NULL DEFAULT 3600,
+    api_token VARCHAR(255) UNIQUE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- The default tenant owns all pre-existing data and uses the configured token
+INSERT INTO tenants (id, name) VALUES (1, 'default') ON CONFLICT (id) DO NOTHING;
+SELECT setval('tenants_id_seq', GREATEST((SELECT MAX(id) FROM tenants), 1));
+
+-- Scope repositories by tenant
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants(id) ON DELETE CASCADE;
+ALTER TABLE repositories DROP CONSTRAINT IF EXISTS repositories_name_owner_key;
+ALTER TABLE repositories DROP CONSTRAINT IF EXISTS repositories_owner_name_key;
+ALTER TABLE repositories ADD CONSTRAINT repositories_tenant_name_owner_key UNIQUE (tenant_id, name, owner);
+
+CREATE INDEX IF NOT EXISTS idx_repositories_tenant_id ON repositories(tenant_id);
+
+-- The same commit can belong to repositories of different tenants (and forks)
+ALTER TABLE commits DROP CONSTRAINT IF EXISTS commits_sha_key;
+ALTER TABLE commits ADD CONSTRAINT commits_repository_sha_key UNIQUE (repository_id, sha);
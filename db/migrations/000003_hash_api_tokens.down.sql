@@ -0,0 +1,2 @@
+-- Hashed API tokens cannot be recovered; tenants must be issued new tokens
+UPDATE tenants SET api_token = NULL;
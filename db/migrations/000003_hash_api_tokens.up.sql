@@ -0,0 +1,4 @@
+-- API tokens are only compared, so store their SHA-256 digest instead of the token
+UPDATE tenants
+SET api_token = encode(sha256(convert_to(api_token, 'UTF8')), 'hex')
+WHERE api_token IS NOT NULL;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_audit_log_action;
+DROP INDEX IF EXISTS idx_audit_log_tenant_created;
+DROP TABLE IF EXISTS audit_log;
+
+ALTER TABLE repositories DROP COLUMN IF EXISTS status;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_raw_payloads_repo;
+DROP TABLE IF EXISTS raw_payloads;
@@ -0,0 +1,13 @@
+-- Raw GitHub API responses, gzip-compressed, kept for replay
+CREATE TABLE IF NOT EXISTS raw_payloads (
+    id SERIAL PRIMARY KEY,
+    tenant_id INTEGER NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    owner VARCHAR(255) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    page INTEGER NOT NULL DEFAULT 0,
+    payload BYTEA NOT NULL,
+    fetched_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_raw_payloads_repo ON raw_payloads(tenant_id, owner, name, fetched_at);
@@ -0,0 +1,11 @@
+-- Pages of commits already ingested, keyed by content so retried, replayed
+-- or concurrently fetched pages are stored exactly once
+CREATE TABLE IF NOT EXISTS ingested_pages (
+    id SERIAL PRIMARY KEY,
+    repository_id INTEGER NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    page_cursor VARCHAR(255) NOT NULL,
+    content_hash CHAR(64) NOT NULL,
+    commit_count INTEGER NOT NULL,
+    ingested_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (repository_id, content_hash)
+);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_repositories_github_id;
+ALTER TABLE repositories DROP COLUMN IF EXISTS github_id;
@@ -0,0 +1,4 @@
+-- Numeric GitHub repository ID, stable across renames and transfers
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS github_id BIGINT;
+
+CREATE INDEX IF NOT EXISTS idx_repositories_github_id ON repositories(github_id);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_repositories_node_id;
+DROP INDEX IF EXISTS idx_repositories_github_id;
+CREATE INDEX IF NOT EXISTS idx_repositories_github_id ON repositories(github_id);
+
+ALTER TABLE repositories DROP COLUMN IF EXISTS node_id;
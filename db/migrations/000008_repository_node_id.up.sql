@@ -0,0 +1,8 @@
+-- GraphQL node ID of the repository
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS node_id VARCHAR(255);
+
+-- A GitHub repository maps to at most one row per tenant. Rows split by a
+-- rename must be merged (see the reconcile command) before this migration.
+DROP INDEX IF EXISTS idx_repositories_github_id;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_repositories_github_id ON repositories(tenant_id, github_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_repositories_node_id ON repositories(tenant_id, node_id);
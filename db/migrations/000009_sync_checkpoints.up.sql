@@ -0,0 +1,8 @@
+-- Where an interrupted commit sync resumes: the since date it started from
+-- and the next page to fetch
+CREATE TABLE IF NOT EXISTS sync_checkpoints (
+    repository_id INTEGER PRIMARY KEY REFERENCES repositories(id) ON DELETE CASCADE,
+    since TIMESTAMP WITH TIME ZONE NOT NULL,
+    next_page INTEGER NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
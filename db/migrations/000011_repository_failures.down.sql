@@ -0,0 +1,2 @@
+UPDATE repositories SET status = 'active' WHERE status = 'quarantined';
+DROP TABLE IF EXISTS repository_failures;
@@ -0,0 +1,9 @@
+-- Consecutive sync failures of a repository; repositories failing too often
+-- are quarantined and only re-checked from next_check_at on
+CREATE TABLE IF NOT EXISTS repository_failures (
+    repository_id INTEGER PRIMARY KEY REFERENCES repositories(id) ON DELETE CASCADE,
+    failures INTEGER NOT NULL,
+    last_error TEXT NOT NULL,
+    next_check_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS rejected_commits;
@@ -0,0 +1,12 @@
+-- Commits that failed to insert while the rest of their batch was stored.
+-- The commit is kept as JSON-encoded bytes since its values may be ones a
+-- text column rejects.
+CREATE TABLE IF NOT EXISTS rejected_commits (
+    id SERIAL PRIMARY KEY,
+    repository_id INTEGER NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    sha TEXT NOT NULL,
+    payload BYTEA NOT NULL,
+    error TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_rejected_commits_repo ON rejected_commits(repository_id, created_at);
@@ -0,0 +1,3 @@
+-- SHA-256 of a commit message as received from GitHub, kept when the stored
+-- message had to be sanitized or truncated
+ALTER TABLE commits ADD COLUMN IF NOT EXISTS message_hash CHAR(64);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_commits_api_url;
+DROP INDEX IF EXISTS idx_commits_url;
+ALTER TABLE commits DROP COLUMN IF EXISTS api_url;
@@ -0,0 +1,17 @@
+-- Store the REST API URL of commits next to their HTML URL, both in
+-- canonical form (lowercase, no trailing slash), so URLs can be joined
+-- against other datasets
+ALTER TABLE commits ADD COLUMN IF NOT EXISTS api_url TEXT;
+
+UPDATE commits SET url = lower(regexp_replace(url, '/+$', ''))
+WHERE url IS DISTINCT FROM lower(regexp_replace(url, '/+$', ''));
+
+-- Commits stored before API URLs were kept get theirs derived from the
+-- HTML URL, which only works for github.com
+UPDATE commits SET api_url = regexp_replace(url,
+    '^https://github\.com/([^/]+)/([^/]+)/commit/([0-9a-f]+)$',
+    'https://api.github.com/repos/\1/\2/commits/\3')
+WHERE api_url IS NULL AND url ~ '^https://github\.com/[^/]+/[^/]+/commit/[0-9a-f]+$';
+
+CREATE INDEX IF NOT EXISTS idx_commits_url ON commits(url);
+CREATE INDEX IF NOT EXISTS idx_commits_api_url ON commits(api_url);
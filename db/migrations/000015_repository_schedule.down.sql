@@ -0,0 +1,3 @@
+ALTER TABLE repositories DROP COLUMN IF EXISTS last_checked_at;
+ALTER TABLE repositories DROP COLUMN IF EXISTS poll_interval;
+ALTER TABLE repositories DROP COLUMN IF EXISTS start_date;
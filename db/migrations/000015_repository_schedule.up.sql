@@ -0,0 +1,6 @@
+-- Per-repository sync settings for bulk-imported repositories: the date the
+-- first sync starts from and a poll interval in seconds overriding the
+-- tenant's. last_checked_at tracks when an interval repository was last synced.
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS start_date TIMESTAMP WITH TIME ZONE;
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS poll_interval INTEGER CHECK (poll_interval > 0);
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS last_checked_at TIMESTAMP WITH TIME ZONE;
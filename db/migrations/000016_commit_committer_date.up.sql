@@ -0,0 +1,9 @@
+-- Incremental syncs continue from the newest committer date: author dates
+-- survive rebases and can lie far in the past. Rows stored before committer
+-- dates were kept start out with their author date; re-fetching or replaying
+-- them stores the real one.
+ALTER TABLE commits ADD COLUMN IF NOT EXISTS committer_date TIMESTAMP;
+
+UPDATE commits SET committer_date = date WHERE committer_date IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_commits_committer_date ON commits(repository_id, committer_date);
@@ -0,0 +1,3 @@
+-- The default branch GitHub reported on the last sync, so a switch such as
+-- master to main is noticed instead of silently syncing the stale branch
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS default_branch TEXT;
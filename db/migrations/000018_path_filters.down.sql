@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS repository_path_filters;
+DROP TABLE IF EXISTS commit_files;
+ALTER TABLE commits DROP COLUMN IF EXISTS files_fetched;
@@ -0,0 +1,22 @@
+-- Path filters scope statistics to the commits touching part of a repository,
+-- e.g. one service in a monorepo. The files a commit touched are only fetched
+-- for repositories with path filters; files_fetched marks commits whose file
+-- list is complete, including commits that touched no files.
+ALTER TABLE commits ADD COLUMN IF NOT EXISTS files_fetched BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS commit_files (
+    repository_id INT NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    sha TEXT NOT NULL,
+    path TEXT NOT NULL,
+    PRIMARY KEY (repository_id, sha, path)
+);
+
+CREATE TABLE IF NOT EXISTS repository_path_filters (
+    id SERIAL PRIMARY KEY,
+    repository_id INT NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    pattern TEXT NOT NULL,
+    path_regex TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(repository_id, name)
+);
@@ -0,0 +1,13 @@
+-- Parent SHAs of each commit, in order, so merge structure can be rebuilt:
+-- position 0 is the first parent, merges have more than one. Only stored
+-- when INGEST_COMMIT_PARENTS is enabled.
+CREATE TABLE IF NOT EXISTS commit_parents (
+    repository_id INT NOT NULL,
+    sha TEXT NOT NULL,
+    position SMALLINT NOT NULL,
+    parent_sha TEXT NOT NULL,
+    PRIMARY KEY (repository_id, sha, position),
+    FOREIGN KEY (repository_id, sha) REFERENCES commits(repository_id, sha) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_commit_parents_parent ON commit_parents(repository_id, parent_sha);
@@ -0,0 +1 @@
+ALTER TABLE sync_checkpoints DROP COLUMN IF EXISTS next_sha;
@@ -0,0 +1,3 @@
+-- First-parent syncs resume by looking for the next mainline commit, which
+-- the checkpoint records
+ALTER TABLE sync_checkpoints ADD COLUMN IF NOT EXISTS next_sha TEXT;
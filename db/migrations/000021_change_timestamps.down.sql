@@ -0,0 +1,33 @@
+DROP INDEX IF EXISTS idx_tenants_updated_at;
+DROP INDEX IF EXISTS idx_repositories_row_updated_at;
+DROP INDEX IF EXISTS idx_commits_updated_at;
+DROP INDEX IF EXISTS idx_audit_log_updated_at;
+DROP INDEX IF EXISTS idx_raw_payloads_updated_at;
+DROP INDEX IF EXISTS idx_ingested_pages_updated_at;
+DROP INDEX IF EXISTS idx_sync_checkpoints_updated_at;
+DROP INDEX IF EXISTS idx_webhook_dead_letters_updated_at;
+DROP INDEX IF EXISTS idx_repository_failures_updated_at;
+DROP INDEX IF EXISTS idx_rejected_commits_updated_at;
+DROP INDEX IF EXISTS idx_commit_files_updated_at;
+DROP INDEX IF EXISTS idx_repository_path_filters_updated_at;
+DROP INDEX IF EXISTS idx_commit_parents_updated_at;
+
+ALTER TABLE tenants DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE repositories DROP COLUMN IF EXISTS row_created_at;
+ALTER TABLE repositories DROP COLUMN IF EXISTS row_updated_at;
+ALTER TABLE commits DROP COLUMN IF EXISTS created_at;
+ALTER TABLE commits DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE audit_log DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE raw_payloads DROP COLUMN IF EXISTS created_at;
+ALTER TABLE raw_payloads DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE ingested_pages DROP COLUMN IF EXISTS created_at;
+ALTER TABLE ingested_pages DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE sync_checkpoints DROP COLUMN IF EXISTS created_at;
+ALTER TABLE webhook_dead_letters DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE repository_failures DROP COLUMN IF EXISTS created_at;
+ALTER TABLE rejected_commits DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE commit_files DROP COLUMN IF EXISTS created_at;
+ALTER TABLE commit_files DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE repository_path_filters DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE commit_parents DROP COLUMN IF EXISTS created_at;
+ALTER TABLE commit_parents DROP COLUMN IF EXISTS updated_at;
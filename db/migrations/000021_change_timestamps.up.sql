@@ -0,0 +1,37 @@
+-- Every table records when each row was created and last changed, so
+-- downstream ETL can extract the rows changed since its last run. The data
+-- layer sets updated_at on every write. The created_at and updated_at
+-- columns of repositories hold GitHub's timestamps, so its own are prefixed.
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS row_created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS row_updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE commits ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE commits ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE raw_payloads ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE raw_payloads ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE ingested_pages ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE ingested_pages ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE sync_checkpoints ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE webhook_dead_letters ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE repository_failures ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE rejected_commits ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE commit_files ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE commit_files ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE repository_path_filters ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE commit_parents ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE commit_parents ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_tenants_updated_at ON tenants(updated_at);
+CREATE INDEX IF NOT EXISTS idx_repositories_row_updated_at ON repositories(row_updated_at);
+CREATE INDEX IF NOT EXISTS idx_commits_updated_at ON commits(updated_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_updated_at ON audit_log(updated_at);
+CREATE INDEX IF NOT EXISTS idx_raw_payloads_updated_at ON raw_payloads(updated_at);
+CREATE INDEX IF NOT EXISTS idx_ingested_pages_updated_at ON ingested_pages(updated_at);
+CREATE INDEX IF NOT EXISTS idx_sync_checkpoints_updated_at ON sync_checkpoints(updated_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_dead_letters_updated_at ON webhook_dead_letters(updated_at);
+CREATE INDEX IF NOT EXISTS idx_repository_failures_updated_at ON repository_failures(updated_at);
+CREATE INDEX IF NOT EXISTS idx_rejected_commits_updated_at ON rejected_commits(updated_at);
+CREATE INDEX IF NOT EXISTS idx_commit_files_updated_at ON commit_files(updated_at);
+CREATE INDEX IF NOT EXISTS idx_repository_path_filters_updated_at ON repository_path_filters(updated_at);
+CREATE INDEX IF NOT EXISTS idx_commit_parents_updated_at ON commit_parents(updated_at);
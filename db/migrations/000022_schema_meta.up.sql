@@ -0,0 +1,10 @@
+-- schema_meta records the version of the newest applied migration, so the
+-- service can refuse to start against a schema older than it needs. Every
+-- later migration updates it: up sets its own number, down the one before.
+CREATE TABLE IF NOT EXISTS schema_meta (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    version INT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+    );
+INSERT INTO schema_meta (version) VALUES (22)
+ON CONFLICT (id) DO UPDATE SET version = EXCLUDED.version, updated_at = CURRENT_TIMESTAMP;
@@ -0,0 +1,3 @@
+ALTER TABLE repositories DROP COLUMN IF EXISTS start_date_auto;
+
+UPDATE schema_meta SET version = 22, updated_at = CURRENT_TIMESTAMP;
@@ -0,0 +1,5 @@
+-- Repositories can start their first sync from their creation on GitHub
+-- rather than a fixed start date
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS start_date_auto BOOLEAN NOT NULL DEFAULT false;
+
+UPDATE schema_meta SET version = 23, updated_at = CURRENT_TIMESTAMP;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS repository_snapshots;
+
+UPDATE schema_meta SET version = 23, updated_at = CURRENT_TIMESTAMP;
@@ -0,0 +1,23 @@
+-- The counters GitHub reports for a repository are recorded over time, so
+-- reports can compare them between two dates. A snapshot is only taken when
+-- a counter changed since the previous one.
+CREATE TABLE IF NOT EXISTS repository_snapshots (
+    id SERIAL PRIMARY KEY,
+    repository_id INT NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    stars_count INT NOT NULL,
+    forks_count INT NOT NULL,
+    open_issues_count INT NOT NULL,
+    watchers_count INT NOT NULL,
+    recorded_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_repository_snapshots_recorded_at ON repository_snapshots(repository_id, recorded_at);
+CREATE INDEX IF NOT EXISTS idx_repository_snapshots_updated_at ON repository_snapshots(updated_at);
+
+-- Repositories synced before start from their current counters
+INSERT INTO repository_snapshots (repository_id, stars_count, forks_count, open_issues_count, watchers_count)
+SELECT id, stars_count, COALESCE(forks_count, 0), COALESCE(open_issues_count, 0), COALESCE(watchers_count, 0)
+FROM repositories
+WHERE stars_count IS NOT NULL;
+
+UPDATE schema_meta SET version = 24, updated_at = CURRENT_TIMESTAMP;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS issues;
+
+UPDATE schema_meta SET version = 24, updated_at = CURRENT_TIMESTAMP;
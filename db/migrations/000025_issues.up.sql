@@ -0,0 +1,20 @@
+-- Issues of the repositories synced with SYNC_ISSUES, pull requests left
+-- out. Labels are kept by name, as they are at the issue's last update.
+CREATE TABLE IF NOT EXISTS issues (
+    id SERIAL PRIMARY KEY,
+    repository_id INT NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    number INT NOT NULL,
+    title TEXT NOT NULL,
+    state VARCHAR(16) NOT NULL,
+    author_login VARCHAR(255) NOT NULL DEFAULT '',
+    labels TEXT[] NOT NULL DEFAULT '{}',
+    opened_at TIMESTAMPTZ NOT NULL,
+    closed_at TIMESTAMPTZ,
+    github_updated_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (repository_id, number)
+);
+CREATE INDEX IF NOT EXISTS idx_issues_labels ON issues USING GIN (labels);
+CREATE INDEX IF NOT EXISTS idx_issues_updated_at ON issues(updated_at);
+
+UPDATE schema_meta SET version = 25, updated_at = CURRENT_TIMESTAMP;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS pull_request_reviews;
+DROP TABLE IF EXISTS pull_requests;
+
+UPDATE schema_meta SET version = 25, updated_at = CURRENT_TIMESTAMP;
@@ -0,0 +1,37 @@
+-- Pull requests of the repositories synced with SYNC_PULL_REQUESTS, with
+-- the size of their change and their submitted reviews, for the cycle-time
+-- statistics
+CREATE TABLE IF NOT EXISTS pull_requests (
+    id SERIAL PRIMARY KEY,
+    repository_id INT NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    number INT NOT NULL,
+    title TEXT NOT NULL,
+    state VARCHAR(16) NOT NULL,
+    author_login VARCHAR(255) NOT NULL DEFAULT '',
+    draft BOOLEAN NOT NULL DEFAULT false,
+    additions INT NOT NULL DEFAULT 0,
+    deletions INT NOT NULL DEFAULT 0,
+    changed_files INT NOT NULL DEFAULT 0,
+    opened_at TIMESTAMPTZ NOT NULL,
+    merged_at TIMESTAMPTZ,
+    closed_at TIMESTAMPTZ,
+    github_updated_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (repository_id, number)
+);
+CREATE INDEX IF NOT EXISTS idx_pull_requests_opened_at ON pull_requests(repository_id, opened_at);
+CREATE INDEX IF NOT EXISTS idx_pull_requests_updated_at ON pull_requests(updated_at);
+
+CREATE TABLE IF NOT EXISTS pull_request_reviews (
+    id SERIAL PRIMARY KEY,
+    pull_request_id INT NOT NULL REFERENCES pull_requests(id) ON DELETE CASCADE,
+    github_id BIGINT NOT NULL,
+    reviewer_login VARCHAR(255) NOT NULL DEFAULT '',
+    state VARCHAR(32) NOT NULL,
+    submitted_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (pull_request_id, github_id)
+);
+CREATE INDEX IF NOT EXISTS idx_pull_request_reviews_updated_at ON pull_request_reviews(updated_at);
+
+UPDATE schema_meta SET version = 26, updated_at = CURRENT_TIMESTAMP;
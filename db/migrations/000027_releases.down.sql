@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS releases;
+
+UPDATE schema_meta SET version = 26, updated_at = CURRENT_TIMESTAMP;
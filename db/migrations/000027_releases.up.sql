@@ -0,0 +1,17 @@
+-- Published releases of the repositories synced with SYNC_RELEASES, for the
+-- release cadence and changelogs
+CREATE TABLE IF NOT EXISTS releases (
+    id SERIAL PRIMARY KEY,
+    repository_id INT NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    github_id BIGINT NOT NULL,
+    tag_name TEXT NOT NULL,
+    name TEXT NOT NULL DEFAULT '',
+    prerelease BOOLEAN NOT NULL DEFAULT false,
+    published_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (repository_id, github_id)
+);
+CREATE INDEX IF NOT EXISTS idx_releases_published_at ON releases(repository_id, published_at);
+CREATE INDEX IF NOT EXISTS idx_releases_updated_at ON releases(updated_at);
+
+UPDATE schema_meta SET version = 27, updated_at = CURRENT_TIMESTAMP;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS deployments;
+
+UPDATE schema_meta SET version = 27, updated_at = CURRENT_TIMESTAMP;
@@ -0,0 +1,22 @@
+-- Deployments of the repositories synced with SYNC_DEPLOYMENTS, for tracing
+-- commits to the deployments that shipped them. state is the latest status
+-- reported; succeeded_at is when the deployment first reported success.
+CREATE TABLE IF NOT EXISTS deployments (
+    id SERIAL PRIMARY KEY,
+    repository_id INT NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    github_id BIGINT NOT NULL,
+    sha TEXT NOT NULL,
+    ref TEXT NOT NULL DEFAULT '',
+    environment TEXT NOT NULL,
+    creator_login VARCHAR(255) NOT NULL DEFAULT '',
+    state VARCHAR(32) NOT NULL DEFAULT '',
+    log_url TEXT NOT NULL DEFAULT '',
+    requested_at TIMESTAMPTZ NOT NULL,
+    succeeded_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (repository_id, github_id)
+);
+CREATE INDEX IF NOT EXISTS idx_deployments_environment ON deployments(repository_id, environment, succeeded_at);
+CREATE INDEX IF NOT EXISTS idx_deployments_updated_at ON deployments(updated_at);
+
+UPDATE schema_meta SET version = 28, updated_at = CURRENT_TIMESTAMP;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS rate_limit_samples;
+
+UPDATE schema_meta SET version = 28, updated_at = CURRENT_TIMESTAMP;
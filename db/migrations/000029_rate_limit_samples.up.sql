@@ -0,0 +1,16 @@
+-- Rate limits GitHub reported on API responses, recorded with
+-- RECORD_RATE_LIMITS to correlate slow syncs with rate limit exhaustion
+CREATE TABLE IF NOT EXISTS rate_limit_samples (
+    id SERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    resource VARCHAR(64) NOT NULL,
+    rate_limit INT NOT NULL,
+    remaining INT NOT NULL,
+    reset_at TIMESTAMPTZ NOT NULL,
+    observed_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_rate_limit_samples_observed_at ON rate_limit_samples(tenant_id, observed_at);
+CREATE INDEX IF NOT EXISTS idx_rate_limit_samples_updated_at ON rate_limit_samples(updated_at);
+
+UPDATE schema_meta SET version = 29, updated_at = CURRENT_TIMESTAMP;
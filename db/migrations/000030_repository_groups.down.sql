@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_repositories_repo_group;
+ALTER TABLE repositories DROP COLUMN IF EXISTS repo_group;
+
+UPDATE schema_meta SET version = 29, updated_at = CURRENT_TIMESTAMP;
@@ -0,0 +1,6 @@
+-- A free-form group, e.g. a team or product area, that administrative
+-- commands such as reset-sync -group act on together
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS repo_group TEXT;
+CREATE INDEX IF NOT EXISTS idx_repositories_repo_group ON repositories(tenant_id, repo_group);
+
+UPDATE schema_meta SET version = 30, updated_at = CURRENT_TIMESTAMP;
@@ -0,0 +1,4 @@
+-- Compressed messages are lost with the column; their subjects remain
+ALTER TABLE commits DROP COLUMN IF EXISTS message_gzip;
+
+UPDATE schema_meta SET version = 30, updated_at = CURRENT_TIMESTAMP;
@@ -0,0 +1,5 @@
+-- Commit messages stored gzip-compressed with COMPRESS_MESSAGES; the message
+-- column then keeps only the subject
+ALTER TABLE commits ADD COLUMN IF NOT EXISTS message_gzip BYTEA;
+
+UPDATE schema_meta SET version = 31, updated_at = CURRENT_TIMESTAMP;
@@ -0,0 +1,4 @@
+-- When a sync of each repository last finished, successful or not
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS last_sync_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE schema_meta SET version = 32, updated_at = CURRENT_TIMESTAMP;
@@ -0,0 +1,3 @@
+ALTER TABLE repositories DROP COLUMN IF EXISTS last_success_at;
+
+UPDATE schema_meta SET version = 32, updated_at = CURRENT_TIMESTAMP;
@@ -0,0 +1,5 @@
+-- When a sync of each repository last succeeded; monitoring syncs the
+-- repositories longest without one first
+ALTER TABLE repositories ADD COLUMN IF NOT EXISTS last_success_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE schema_meta SET version = 33, updated_at = CURRENT_TIMESTAMP;
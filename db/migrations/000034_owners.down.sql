@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS owners;
+
+UPDATE schema_meta SET version = 33, updated_at = CURRENT_TIMESTAMP;
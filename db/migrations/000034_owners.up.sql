@@ -0,0 +1,18 @@
+-- Profiles of the users and organizations owning tracked repositories,
+-- fetched with SYNC_OWNERS
+CREATE TABLE IF NOT EXISTS owners (
+    id SERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    login TEXT NOT NULL,
+    github_id BIGINT NOT NULL,
+    type VARCHAR(16) NOT NULL,
+    name TEXT NOT NULL DEFAULT '',
+    avatar_url TEXT NOT NULL DEFAULT '',
+    public_repos INT NOT NULL DEFAULT 0,
+    fetched_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (tenant_id, login)
+);
+CREATE INDEX IF NOT EXISTS idx_owners_updated_at ON owners(updated_at);
+
+UPDATE schema_meta SET version = 34, updated_at = CURRENT_TIMESTAMP;
@@ -1,7 +1,22 @@
 -- SQL migration file to initialize database schema
 
+CREATE TABLE IF NOT EXISTS tenants (
+                                       id SERIAL PRIMARY KEY,
+                                       name TEXT UNIQUE NOT NULL,
+                                       github_token TEXT NOT NULL DEFAULT '',
+                                       repo_owner TEXT NOT NULL DEFAULT '',
+                                       poll_interval INT NOT NULL DEFAULT 3600,
+                                       api_token TEXT UNIQUE,
+                                       created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                                       updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+    );
+
+INSERT INTO tenants (id, name) VALUES (1, 'default') ON CONFLICT (id) DO NOTHING;
+SELECT setval('tenants_id_seq', GREATEST((SELECT MAX(id) FROM tenants), 1));
+
 CREATE TABLE IF NOT EXISTS repositories (
                                             id SERIAL PRIMARY KEY,
+                                            tenant_id INT NOT NULL DEFAULT 1 REFERENCES tenants(id) ON DELETE CASCADE,
                                             owner TEXT NOT NULL,
                                             name TEXT NOT NULL,
                                             description TEXT,
@@ -13,15 +28,279 @@ CREATE TABLE IF NOT EXISTS repositories (
                                             watchers_count INT,
                                             created_at TIMESTAMP,
                                             updated_at TIMESTAMP,
-                                            UNIQUE(owner, name)
+                                            status TEXT NOT NULL DEFAULT 'active',
+                                            github_id BIGINT,
+                                            node_id TEXT,
+                                            start_date TIMESTAMPTZ,
+                                            start_date_auto BOOLEAN NOT NULL DEFAULT false,
+                                            poll_interval INT CHECK (poll_interval > 0),
+                                            last_checked_at TIMESTAMPTZ,
+                                            default_branch TEXT,
+                                            repo_group TEXT,
+                                            last_sync_at TIMESTAMPTZ,
+                                            last_success_at TIMESTAMPTZ,
+                                            row_created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                                            row_updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                                            UNIQUE(tenant_id, name, owner)
     );
 
 CREATE TABLE IF NOT EXISTS commits (
                                        id SERIAL PRIMARY KEY,
-                                       sha TEXT UNIQUE NOT NULL,
+                                       sha TEXT NOT NULL,
                                        repository_id INT REFERENCES repositories(id) ON DELETE CASCADE,
     message TEXT,
     author_name TEXT,
     date TIMESTAMP,
-    url TEXT
-    );
\ No newline at end of file
+    url TEXT,
+    api_url TEXT,
+    message_hash CHAR(64),
+    committer_date TIMESTAMP,
+    message_gzip BYTEA,
+    files_fetched BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(repository_id, sha)
+    );
+CREATE TABLE IF NOT EXISTS audit_log (
+                                         id SERIAL PRIMARY KEY,
+                                         tenant_id INT NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    actor TEXT NOT NULL,
+    actor_type TEXT NOT NULL,
+    action TEXT NOT NULL,
+    parameters JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+    );
+CREATE TABLE IF NOT EXISTS raw_payloads (
+                                            id SERIAL PRIMARY KEY,
+                                            tenant_id INT NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    owner TEXT NOT NULL,
+    name TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    page INT NOT NULL DEFAULT 0,
+    payload BYTEA NOT NULL,
+    fetched_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+    );
+CREATE INDEX IF NOT EXISTS idx_raw_payloads_repo ON raw_payloads(tenant_id, owner, name, fetched_at);
+CREATE TABLE IF NOT EXISTS ingested_pages (
+                                              id SERIAL PRIMARY KEY,
+                                              repository_id INT NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    page_cursor TEXT NOT NULL,
+    content_hash CHAR(64) NOT NULL,
+    commit_count INT NOT NULL,
+    ingested_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(repository_id, content_hash)
+    );
+CREATE UNIQUE INDEX IF NOT EXISTS idx_repositories_github_id ON repositories(tenant_id, github_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_repositories_node_id ON repositories(tenant_id, node_id);
+CREATE INDEX IF NOT EXISTS idx_repositories_repo_group ON repositories(tenant_id, repo_group);
+CREATE TABLE IF NOT EXISTS sync_checkpoints (
+                                                repository_id INT PRIMARY KEY REFERENCES repositories(id) ON DELETE CASCADE,
+    since TIMESTAMPTZ NOT NULL,
+    next_page INT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    next_sha TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+    );
+CREATE TABLE IF NOT EXISTS webhook_dead_letters (
+                                                    id SERIAL PRIMARY KEY,
+                                                    tenant_id INT NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    event_id VARCHAR(64) NOT NULL,
+    event_type VARCHAR(64) NOT NULL,
+    payload JSONB NOT NULL,
+    attempts INT NOT NULL,
+    last_error TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+    );
+CREATE INDEX IF NOT EXISTS idx_webhook_dead_letters_tenant ON webhook_dead_letters(tenant_id, created_at);
+CREATE TABLE IF NOT EXISTS repository_failures (
+                                                   repository_id INT PRIMARY KEY REFERENCES repositories(id) ON DELETE CASCADE,
+    failures INT NOT NULL,
+    last_error TEXT NOT NULL,
+    next_check_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+    );
+CREATE TABLE IF NOT EXISTS rejected_commits (
+                                                id SERIAL PRIMARY KEY,
+                                                repository_id INT NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    sha TEXT NOT NULL,
+    payload BYTEA NOT NULL,
+    error TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+    );
+CREATE INDEX IF NOT EXISTS idx_rejected_commits_repo ON rejected_commits(repository_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_commits_url ON commits(url);
+CREATE INDEX IF NOT EXISTS idx_commits_api_url ON commits(api_url);
+CREATE INDEX IF NOT EXISTS idx_commits_committer_date ON commits(repository_id, committer_date);
+CREATE TABLE IF NOT EXISTS commit_files (
+                                            repository_id INT NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    sha TEXT NOT NULL,
+    path TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (repository_id, sha, path)
+    );
+CREATE TABLE IF NOT EXISTS repository_path_filters (
+                                                       id SERIAL PRIMARY KEY,
+                                                       repository_id INT NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    pattern TEXT NOT NULL,
+    path_regex TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(repository_id, name)
+    );
+CREATE TABLE IF NOT EXISTS commit_parents (
+                                              repository_id INT NOT NULL,
+    sha TEXT NOT NULL,
+    position SMALLINT NOT NULL,
+    parent_sha TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (repository_id, sha, position),
+    FOREIGN KEY (repository_id, sha) REFERENCES commits(repository_id, sha) ON DELETE CASCADE
+    );
+CREATE INDEX IF NOT EXISTS idx_commit_parents_parent ON commit_parents(repository_id, parent_sha);
+CREATE INDEX IF NOT EXISTS idx_tenants_updated_at ON tenants(updated_at);
+CREATE INDEX IF NOT EXISTS idx_repositories_row_updated_at ON repositories(row_updated_at);
+CREATE INDEX IF NOT EXISTS idx_commits_updated_at ON commits(updated_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_updated_at ON audit_log(updated_at);
+CREATE INDEX IF NOT EXISTS idx_raw_payloads_updated_at ON raw_payloads(updated_at);
+CREATE INDEX IF NOT EXISTS idx_ingested_pages_updated_at ON ingested_pages(updated_at);
+CREATE INDEX IF NOT EXISTS idx_sync_checkpoints_updated_at ON sync_checkpoints(updated_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_dead_letters_updated_at ON webhook_dead_letters(updated_at);
+CREATE INDEX IF NOT EXISTS idx_repository_failures_updated_at ON repository_failures(updated_at);
+CREATE INDEX IF NOT EXISTS idx_rejected_commits_updated_at ON rejected_commits(updated_at);
+CREATE INDEX IF NOT EXISTS idx_commit_files_updated_at ON commit_files(updated_at);
+CREATE INDEX IF NOT EXISTS idx_repository_path_filters_updated_at ON repository_path_filters(updated_at);
+CREATE INDEX IF NOT EXISTS idx_commit_parents_updated_at ON commit_parents(updated_at);
+CREATE TABLE IF NOT EXISTS repository_snapshots (
+    id SERIAL PRIMARY KEY,
+    repository_id INT NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    stars_count INT NOT NULL,
+    forks_count INT NOT NULL,
+    open_issues_count INT NOT NULL,
+    watchers_count INT NOT NULL,
+    recorded_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+    );
+CREATE INDEX IF NOT EXISTS idx_repository_snapshots_recorded_at ON repository_snapshots(repository_id, recorded_at);
+CREATE INDEX IF NOT EXISTS idx_repository_snapshots_updated_at ON repository_snapshots(updated_at);
+CREATE TABLE IF NOT EXISTS issues (
+    id SERIAL PRIMARY KEY,
+    repository_id INT NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    number INT NOT NULL,
+    title TEXT NOT NULL,
+    state VARCHAR(16) NOT NULL,
+    author_login VARCHAR(255) NOT NULL DEFAULT '',
+    labels TEXT[] NOT NULL DEFAULT '{}',
+    opened_at TIMESTAMPTZ NOT NULL,
+    closed_at TIMESTAMPTZ,
+    github_updated_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (repository_id, number)
+    );
+CREATE INDEX IF NOT EXISTS idx_issues_labels ON issues USING GIN (labels);
+CREATE INDEX IF NOT EXISTS idx_issues_updated_at ON issues(updated_at);
+CREATE TABLE IF NOT EXISTS pull_requests (
+    id SERIAL PRIMARY KEY,
+    repository_id INT NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    number INT NOT NULL,
+    title TEXT NOT NULL,
+    state VARCHAR(16) NOT NULL,
+    author_login VARCHAR(255) NOT NULL DEFAULT '',
+    draft BOOLEAN NOT NULL DEFAULT false,
+    additions INT NOT NULL DEFAULT 0,
+    deletions INT NOT NULL DEFAULT 0,
+    changed_files INT NOT NULL DEFAULT 0,
+    opened_at TIMESTAMPTZ NOT NULL,
+    merged_at TIMESTAMPTZ,
+    closed_at TIMESTAMPTZ,
+    github_updated_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (repository_id, number)
+    );
+CREATE INDEX IF NOT EXISTS idx_pull_requests_opened_at ON pull_requests(repository_id, opened_at);
+CREATE INDEX IF NOT EXISTS idx_pull_requests_updated_at ON pull_requests(updated_at);
+CREATE TABLE IF NOT EXISTS pull_request_reviews (
+    id SERIAL PRIMARY KEY,
+    pull_request_id INT NOT NULL REFERENCES pull_requests(id) ON DELETE CASCADE,
+    github_id BIGINT NOT NULL,
+    reviewer_login VARCHAR(255) NOT NULL DEFAULT '',
+    state VARCHAR(32) NOT NULL,
+    submitted_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (pull_request_id, github_id)
+    );
+CREATE INDEX IF NOT EXISTS idx_pull_request_reviews_updated_at ON pull_request_reviews(updated_at);
+CREATE TABLE IF NOT EXISTS releases (
+    id SERIAL PRIMARY KEY,
+    repository_id INT NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    github_id BIGINT NOT NULL,
+    tag_name TEXT NOT NULL,
+    name TEXT NOT NULL DEFAULT '',
+    prerelease BOOLEAN NOT NULL DEFAULT false,
+    published_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (repository_id, github_id)
+    );
+CREATE INDEX IF NOT EXISTS idx_releases_published_at ON releases(repository_id, published_at);
+CREATE INDEX IF NOT EXISTS idx_releases_updated_at ON releases(updated_at);
+CREATE TABLE IF NOT EXISTS deployments (
+    id SERIAL PRIMARY KEY,
+    repository_id INT NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    github_id BIGINT NOT NULL,
+    sha TEXT NOT NULL,
+    ref TEXT NOT NULL DEFAULT '',
+    environment TEXT NOT NULL,
+    creator_login VARCHAR(255) NOT NULL DEFAULT '',
+    state VARCHAR(32) NOT NULL DEFAULT '',
+    log_url TEXT NOT NULL DEFAULT '',
+    requested_at TIMESTAMPTZ NOT NULL,
+    succeeded_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (repository_id, github_id)
+    );
+CREATE INDEX IF NOT EXISTS idx_deployments_environment ON deployments(repository_id, environment, succeeded_at);
+CREATE INDEX IF NOT EXISTS idx_deployments_updated_at ON deployments(updated_at);
+CREATE TABLE IF NOT EXISTS rate_limit_samples (
+    id SERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    resource VARCHAR(64) NOT NULL,
+    rate_limit INT NOT NULL,
+    remaining INT NOT NULL,
+    reset_at TIMESTAMPTZ NOT NULL,
+    observed_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+    );
+CREATE INDEX IF NOT EXISTS idx_rate_limit_samples_observed_at ON rate_limit_samples(tenant_id, observed_at);
+CREATE INDEX IF NOT EXISTS idx_rate_limit_samples_updated_at ON rate_limit_samples(updated_at);
+CREATE TABLE IF NOT EXISTS owners (
+    id SERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    login TEXT NOT NULL,
+    github_id BIGINT NOT NULL,
+    type VARCHAR(16) NOT NULL,
+    name TEXT NOT NULL DEFAULT '',
+    avatar_url TEXT NOT NULL DEFAULT '',
+    public_repos INT NOT NULL DEFAULT 0,
+    fetched_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (tenant_id, login)
+    );
+CREATE INDEX IF NOT EXISTS idx_owners_updated_at ON owners(updated_at);
+CREATE TABLE IF NOT EXISTS schema_meta (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    version INT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+    );
+INSERT INTO schema_meta (version) VALUES (34)
+ON CONFLICT (id) DO UPDATE SET version = EXCLUDED.version, updated_at = CURRENT_TIMESTAMP;
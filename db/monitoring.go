@@ -2,81 +2,64 @@ package db
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
-	"log"
-	"sync"
 	"time"
 
 	"githubapifetch/models"
+	"githubapifetch/tenant"
 )
 
-// MonitorRepositoryChanges starts a goroutine to monitor repository changes
-func (db *DB) MonitorRepositoryChanges(ctx context.Context, interval time.Duration, callback func(repoName string, latestDate time.Time) error) {
-	go func() {
-		ticker := time.NewTicker(interval)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				if err := db.checkRepositories(ctx, callback); err != nil {
-					log.Printf("Error checking repositories: %v", err)
-				}
-			}
-		}
-	}()
-}
-
-// checkRepositories checks all repositories for changes
-func (db *DB) checkRepositories(ctx context.Context, callback func(repoName string, latestDate time.Time) error) error {
+// DueRepositories returns the active repositories of the context's tenant,
+// along with quarantined repositories that are due for a re-check.
+// Repositories with their own poll interval are left out until it has passed
+// since their last check.
+func (db *DB) DueRepositories(ctx context.Context) ([]models.Repository, error) {
 	var repos []models.Repository
-	if err := db.conn.SelectContext(ctx, &repos, "SELECT * FROM repositories"); err != nil {
-		return fmt.Errorf("failed to fetch repositories for monitoring: %w", err)
+	query := `SELECT ` + repositoryColumns + ` FROM repositories
+		WHERE tenant_id = $1 AND (status = $2 OR (status = $3 AND id IN (
+			SELECT repository_id FROM repository_failures WHERE next_check_at <= CURRENT_TIMESTAMP)))
+		AND (poll_interval IS NULL OR last_checked_at IS NULL
+			OR last_checked_at <= CURRENT_TIMESTAMP - poll_interval * INTERVAL '1 second')`
+	if err := db.conn.SelectContext(ctx, &repos, query,
+		tenant.FromContext(ctx), models.RepoStatusActive, models.RepoStatusQuarantined); err != nil {
+		return nil, fmt.Errorf("failed to fetch repositories for monitoring: %w", err)
 	}
+	return repos, nil
+}
 
-	// Process repositories concurrently with a worker pool
-	const maxWorkers = 5
-	sem := make(chan struct{}, maxWorkers)
-	errChan := make(chan error, len(repos))
-	var wg sync.WaitGroup
-
-	for _, repo := range repos {
-		wg.Add(1)
-		go func(repo models.Repository) {
-			defer wg.Done()
-			sem <- struct{}{}        // Acquire semaphore
-			defer func() { <-sem }() // Release semaphore
-
-			latestDate, err := db.GetLatestDate(ctx, repo.Name)
-			if err != nil {
-				if err == ErrNoCommitsFound {
-					log.Printf("No commits found for repository %s, skipping...", repo.Name)
-					return
-				}
-				errChan <- fmt.Errorf("error getting latest date for repository %s: %w", repo.Name, err)
-				return
-			}
-
-			if err := callback(repo.Name, latestDate); err != nil {
-				errChan <- fmt.Errorf("error processing repository %s: %w", repo.Name, err)
-			}
-		}(repo)
+// LatestCommitDate returns the newest committer date of a repository's
+// stored commits, or the zero time when none is stored. Committer dates
+// change when commits are rebased, so unlike author dates they do not lag
+// behind what GitHub returns for since.
+func (db *DB) LatestCommitDate(ctx context.Context, repoID int) (time.Time, error) {
+	var latestDate sql.NullTime
+	query := `SELECT MAX(COALESCE(committer_date, date)) FROM commits WHERE repository_id = $1`
+	if err := db.conn.GetContext(ctx, &latestDate, query, repoID); err != nil {
+		return time.Time{}, fmt.Errorf("failed to get latest commit date for repository %d: %w", repoID, err)
 	}
+	return latestDate.Time, nil
+}
 
-	wg.Wait()
-	close(errChan)
-
-	// Collect errors
-	var errs []error
-	for err := range errChan {
-		errs = append(errs, err)
+// MarkChecked records that a repository with its own poll interval was
+// checked
+func (db *DB) MarkChecked(ctx context.Context, repoID int) error {
+	query := `UPDATE repositories SET last_checked_at = CURRENT_TIMESTAMP, row_updated_at = CURRENT_TIMESTAMP WHERE id = $1`
+	if _, err := db.conn.ExecContext(ctx, query, repoID); err != nil {
+		return fmt.Errorf("failed to mark repository %d checked: %w", repoID, err)
 	}
+	return nil
+}
 
-	if len(errs) > 0 {
-		return fmt.Errorf("errors occurred while processing repositories: %v", errs)
+// MarkSynced records that a sync of a repository finished, and when it
+// succeeded that it did
+func (db *DB) MarkSynced(ctx context.Context, repoID int, succeeded bool) error {
+	query := `UPDATE repositories SET last_sync_at = CURRENT_TIMESTAMP,
+			last_success_at = CASE WHEN $2 THEN CURRENT_TIMESTAMP ELSE last_success_at END,
+			row_updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1`
+	if _, err := db.conn.ExecContext(ctx, query, repoID, succeeded); err != nil {
+		return fmt.Errorf("failed to mark repository %d synced: %w", repoID, err)
 	}
-
 	return nil
 }
@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"githubapifetch/models"
+	"githubapifetch/tenant"
+)
+
+// ownerColumns are the columns an owner is read from, with the owners table
+// as o
+const ownerColumns = `o.id, o.tenant_id, o.login, o.github_id, o.type, o.name, o.avatar_url, o.public_repos, o.fetched_at`
+
+// StoreOwner inserts or updates the profile of an owner of the context's
+// tenant
+func (db *DB) StoreOwner(ctx context.Context, o models.Owner) error {
+	if o.Login == "" {
+		return fmt.Errorf("%w: owner requires a login", ErrInvalidInput)
+	}
+	if o.Type != models.OwnerTypeUser && o.Type != models.OwnerTypeOrg {
+		return fmt.Errorf("%w: unknown owner type %q", ErrInvalidInput, o.Type)
+	}
+	if _, err := db.conn.ExecContext(ctx, `
+		INSERT INTO owners (tenant_id, login, github_id, type, name, avatar_url, public_repos, fetched_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (tenant_id, login) DO UPDATE SET
+			github_id = EXCLUDED.github_id,
+			type = EXCLUDED.type,
+			name = EXCLUDED.name,
+			avatar_url = EXCLUDED.avatar_url,
+			public_repos = EXCLUDED.public_repos,
+			fetched_at = EXCLUDED.fetched_at,
+			updated_at = CURRENT_TIMESTAMP
+	`, tenant.FromContext(ctx), o.Login, o.GitHubID, o.Type, o.Name, o.AvatarURL, o.PublicRepos, o.FetchedAt); err != nil {
+		return fmt.Errorf("failed to store owner %s: %w", o.Login, err)
+	}
+	return nil
+}
+
+// GetOwner returns the stored profile of an owner of the context's tenant
+func (db *DB) GetOwner(ctx context.Context, login string) (*models.Owner, error) {
+	var owner models.Owner
+	err := db.conn.GetContext(ctx, &owner, `
+		SELECT `+ownerColumns+`
+		FROM owners o
+		WHERE o.tenant_id = $1 AND o.login = $2
+	`, tenant.FromContext(ctx), login)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrOwnerNotFound, login)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get owner %s: %w", login, err)
+	}
+	return &owner, nil
+}
+
+// ListOwners returns the stored owners of the context's tenant by login,
+// each with the number of its repositories tracked and not removed
+func (db *DB) ListOwners(ctx context.Context) ([]models.OwnerListing, error) {
+	owners := []models.OwnerListing{}
+	if err := db.conn.SelectContext(ctx, &owners, `
+		SELECT `+ownerColumns+`,
+			(SELECT COUNT(*) FROM repositories r
+			 WHERE r.tenant_id = o.tenant_id AND r.owner = o.login AND r.status <> $2) AS repositories
+		FROM owners o
+		WHERE o.tenant_id = $1
+		ORDER BY lower(o.login), o.id
+	`, tenant.FromContext(ctx), models.RepoStatusRemoved); err != nil {
+		return nil, fmt.Errorf("failed to list owners: %w", err)
+	}
+	return owners, nil
+}
@@ -0,0 +1,148 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+
+	"githubapifetch/models"
+	"githubapifetch/tenant"
+)
+
+const pathFilterColumns = `pf.id, pf.repository_id, pf.name, pf.pattern, pf.path_regex, pf.created_at`
+
+// SetPathFilter adds a path filter to a repository, replacing the pattern of
+// an existing filter with the same name
+func (db *DB) SetPathFilter(ctx context.Context, repoName string, f models.PathFilter) error {
+	if repoName == "" || f.Name == "" || f.Pattern == "" || f.Regexp == "" {
+		return fmt.Errorf("%w: repository, filter name and pattern cannot be empty", ErrInvalidInput)
+	}
+
+	query := `
+		INSERT INTO repository_path_filters (repository_id, name, pattern, path_regex)
+		SELECT id, $1, $2, $3 FROM repositories WHERE name = $4 AND tenant_id = $5
+		ON CONFLICT (repository_id, name) DO UPDATE SET
+			pattern = EXCLUDED.pattern,
+			path_regex = EXCLUDED.path_regex,
+			updated_at = CURRENT_TIMESTAMP
+		RETURNING id
+	`
+	var id int
+	if err := db.conn.QueryRowxContext(ctx, query,
+		f.Name, f.Pattern, f.Regexp, repoName, tenant.FromContext(ctx),
+	).Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: repository %s not found", ErrRepositoryNotFound, repoName)
+		}
+		return fmt.Errorf("failed to set path filter %s of repository %s: %w", f.Name, repoName, err)
+	}
+
+	safeLogInfo("Path filter set",
+		zap.String("repo_name", repoName),
+		zap.String("filter", f.Name),
+		zap.String("pattern", f.Pattern))
+	return nil
+}
+
+// RemovePathFilter removes a path filter from a repository
+func (db *DB) RemovePathFilter(ctx context.Context, repoName, name string) error {
+	query := `
+		DELETE FROM repository_path_filters pf USING repositories r
+		WHERE pf.repository_id = r.id AND r.name = $1 AND r.tenant_id = $2 AND pf.name = $3
+	`
+	result, err := db.conn.ExecContext(ctx, query, repoName, tenant.FromContext(ctx), name)
+	if err != nil {
+		return fmt.Errorf("failed to remove path filter %s of repository %s: %w", name, repoName, err)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("%w: %s of repository %s", ErrPathFilterNotFound, name, repoName)
+	}
+	return nil
+}
+
+// ListPathFilters returns the path filters of a repository ordered by name
+func (db *DB) ListPathFilters(ctx context.Context, repoName string) ([]models.PathFilter, error) {
+	filters := []models.PathFilter{}
+	query := `
+		SELECT ` + pathFilterColumns + `
+		FROM repository_path_filters pf
+		JOIN repositories r ON pf.repository_id = r.id
+		WHERE r.name = $1 AND r.tenant_id = $2
+		ORDER BY pf.name
+	`
+	if err := db.conn.SelectContext(ctx, &filters, query, repoName, tenant.FromContext(ctx)); err != nil {
+		return nil, fmt.Errorf("failed to list path filters of repository %s: %w", repoName, err)
+	}
+	return filters, nil
+}
+
+// pathFilterCondition returns the condition restricting a commit query, with
+// commits aliased as c, to the commits touching the paths of a repository's
+// path filter. The filter's pattern is bound as parameter argN. An empty
+// filter name leaves the query unrestricted.
+func (db *DB) pathFilterCondition(ctx context.Context, repoName, name string, argN int) (string, []interface{}, error) {
+	if name == "" {
+		return "", nil, nil
+	}
+
+	var f models.PathFilter
+	query := `
+		SELECT ` + pathFilterColumns + `
+		FROM repository_path_filters pf
+		JOIN repositories r ON pf.repository_id = r.id
+		WHERE r.name = $1 AND r.tenant_id = $2 AND pf.name = $3
+	`
+	if err := db.conn.GetContext(ctx, &f, query, repoName, tenant.FromContext(ctx), name); err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil, fmt.Errorf("%w: %s of repository %s", ErrPathFilterNotFound, name, repoName)
+		}
+		return "", nil, fmt.Errorf("failed to get path filter %s of repository %s: %w", name, repoName, err)
+	}
+
+	condition := fmt.Sprintf(` AND EXISTS (
+			SELECT 1 FROM commit_files f
+			WHERE f.repository_id = c.repository_id AND f.sha = c.sha AND f.path ~ $%d)`, argN)
+	return condition, []interface{}{f.Regexp}, nil
+}
+
+// CommitsWithoutFiles returns which of the given stored commits of a
+// repository have no file list yet
+func (db *DB) CommitsWithoutFiles(ctx context.Context, repoID int, shas []string) ([]string, error) {
+	missing := []string{}
+	if len(shas) == 0 {
+		return missing, nil
+	}
+
+	query := `SELECT sha FROM commits WHERE repository_id = $1 AND sha = ANY($2) AND NOT files_fetched`
+	if err := sqlx.SelectContext(ctx, db.ext(ctx), &missing, query, repoID, pq.Array(shas)); err != nil {
+		return nil, fmt.Errorf("failed to look up file lists of repository %d: %w", repoID, err)
+	}
+	return missing, nil
+}
+
+// StoreCommitFiles records the paths a stored commit touched and marks its
+// file list as complete
+func (db *DB) StoreCommitFiles(ctx context.Context, repoID int, sha string, paths []string) error {
+	return db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		if len(paths) > 0 {
+			if _, err := tx.ExecContext(ctx, `
+				INSERT INTO commit_files (repository_id, sha, path)
+				SELECT $1, $2, unnest($3::text[])
+				ON CONFLICT DO NOTHING
+			`, repoID, sha, pq.Array(paths)); err != nil {
+				return fmt.Errorf("failed to store files of commit %s: %w", sha, err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE commits SET files_fetched = TRUE, updated_at = CURRENT_TIMESTAMP WHERE repository_id = $1 AND sha = $2`,
+			repoID, sha,
+		); err != nil {
+			return fmt.Errorf("failed to mark files of commit %s: %w", sha, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,67 @@
+package db
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+
+	"githubapifetch/models"
+	"githubapifetch/tenant"
+)
+
+// StoreRawPayload stores a gzip-compressed API response for the context's tenant
+func (db *DB) StoreRawPayload(ctx context.Context, p models.RawPayload) error {
+	if p.Owner == "" || p.Name == "" || p.Kind == "" {
+		return fmt.Errorf("%w: raw payload requires owner, name and kind", ErrInvalidInput)
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(p.Payload); err != nil {
+		return fmt.Errorf("failed to compress payload: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to compress payload: %w", err)
+	}
+
+	query := `
+		INSERT INTO raw_payloads (tenant_id, owner, name, kind, page, payload)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	if _, err := db.conn.ExecContext(ctx, query,
+		tenant.FromContext(ctx), p.Owner, p.Name, p.Kind, p.Page, buf.Bytes(),
+	); err != nil {
+		return fmt.Errorf("failed to store %s payload for %s/%s: %w", p.Kind, p.Owner, p.Name, err)
+	}
+	return nil
+}
+
+// ListRawPayloads returns the decompressed payloads stored for a repository
+// in the order they were fetched
+func (db *DB) ListRawPayloads(ctx context.Context, owner, name string) ([]models.RawPayload, error) {
+	payloads := []models.RawPayload{}
+	query := `
+		SELECT id, tenant_id, owner, name, kind, page, payload, fetched_at
+		FROM raw_payloads
+		WHERE tenant_id = $1 AND owner = $2 AND name = $3
+		ORDER BY fetched_at, id
+	`
+	if err := db.conn.SelectContext(ctx, &payloads, query, tenant.FromContext(ctx), owner, name); err != nil {
+		return nil, fmt.Errorf("failed to list payloads for %s/%s: %w", owner, name, err)
+	}
+
+	for i := range payloads {
+		zr, err := gzip.NewReader(bytes.NewReader(payloads[i].Payload))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress payload %d: %w", payloads[i].ID, err)
+		}
+		body, err := io.ReadAll(zr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress payload %d: %w", payloads[i].ID, err)
+		}
+		payloads[i].Payload = body
+	}
+	return payloads, nil
+}
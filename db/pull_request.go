@@ -0,0 +1,179 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"githubapifetch/models"
+	"githubapifetch/tenant"
+)
+
+// StorePullRequests inserts or updates pull requests of a repository and
+// their reviews. Reviews are only ever added: GitHub keeps submitted
+// reviews, dismissing them at most.
+func (db *DB) StorePullRequests(ctx context.Context, repoID int, pulls []models.PullRequest) error {
+	if len(pulls) == 0 {
+		return nil
+	}
+	return db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		for _, pull := range pulls {
+			var pullID int
+			if err := tx.QueryRowxContext(ctx, `
+				INSERT INTO pull_requests (repository_id, number, title, state, author_login, draft,
+					additions, deletions, changed_files, opened_at, merged_at, closed_at, github_updated_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+				ON CONFLICT (repository_id, number) DO UPDATE SET
+					title = EXCLUDED.title,
+					state = EXCLUDED.state,
+					author_login = EXCLUDED.author_login,
+					draft = EXCLUDED.draft,
+					additions = EXCLUDED.additions,
+					deletions = EXCLUDED.deletions,
+					changed_files = EXCLUDED.changed_files,
+					opened_at = EXCLUDED.opened_at,
+					merged_at = EXCLUDED.merged_at,
+					closed_at = EXCLUDED.closed_at,
+					github_updated_at = EXCLUDED.github_updated_at,
+					updated_at = CURRENT_TIMESTAMP
+				RETURNING id
+			`, repoID, pull.Number, pull.Title, pull.State, pull.AuthorLogin, pull.Draft,
+				pull.Additions, pull.Deletions, pull.ChangedFiles, pull.OpenedAt, pull.MergedAt, pull.ClosedAt, pull.GitHubUpdatedAt,
+			).Scan(&pullID); err != nil {
+				return fmt.Errorf("failed to store pull request #%d of repository %d: %w", pull.Number, repoID, err)
+			}
+
+			for _, review := range pull.Reviews {
+				if _, err := tx.ExecContext(ctx, `
+					INSERT INTO pull_request_reviews (pull_request_id, github_id, reviewer_login, state, submitted_at)
+					VALUES ($1, $2, $3, $4, $5)
+					ON CONFLICT (pull_request_id, github_id) DO UPDATE SET
+						state = EXCLUDED.state,
+						updated_at = CURRENT_TIMESTAMP
+				`, pullID, review.GitHubID, review.ReviewerLogin, review.State, review.SubmittedAt); err != nil {
+					return fmt.Errorf("failed to store review %d of pull request #%d of repository %d: %w", review.GitHubID, pull.Number, repoID, err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// LatestPullRequestUpdate returns when the most recently updated stored
+// pull request of a repository last changed on GitHub, where pull request
+// syncs continue from, or the zero time when none is stored
+func (db *DB) LatestPullRequestUpdate(ctx context.Context, repoID int) (time.Time, error) {
+	var latest sql.NullTime
+	if err := sqlx.GetContext(ctx, db.ext(ctx), &latest,
+		`SELECT MAX(github_updated_at) FROM pull_requests WHERE repository_id = $1`, repoID,
+	); err != nil {
+		return time.Time{}, fmt.Errorf("failed to get latest pull request update of repository %d: %w", repoID, err)
+	}
+	return latest.Time, nil
+}
+
+// PullRequestStats describes the stored pull requests opened over [since,
+// until) in a repository of the context's tenant, or in all of them when
+// repoName is empty: how long they waited for a first review by someone
+// other than their author, how long the merged ones took to merge, and how
+// large they are. Non-empty authors limits them to the pull requests those
+// logins opened, e.g. the members of a team. With a calendar, the times to
+// review and merge count only its working days.
+func (db *DB) PullRequestStats(ctx context.Context, repoName string, authors []string, since, until time.Time, cal *models.WorkCalendar) (*models.PullRequestStats, error) {
+	if !since.Before(until) {
+		return nil, fmt.Errorf("%w: the period must end after it starts", ErrInvalidInput)
+	}
+	if err := validCalendar(cal); err != nil {
+		return nil, err
+	}
+	stats := &models.PullRequestStats{Authors: authors, Since: since, Until: until, Calendar: cal}
+	repoID := 0
+	if repoName != "" {
+		repo, err := db.GetByName(ctx, repoName)
+		if err != nil {
+			return nil, err
+		}
+		repoID, stats.Repo = repo.ID, repo.Name
+	}
+	if authors == nil {
+		authors = []string{}
+	}
+	const scoped = `
+		WITH scoped AS (
+			SELECT p.opened_at, p.merged_at, p.additions + p.deletions AS lines,
+				(SELECT MIN(rv.submitted_at) FROM pull_request_reviews rv
+					WHERE rv.pull_request_id = p.id AND rv.reviewer_login <> p.author_login) AS first_review
+			FROM pull_requests p
+			JOIN repositories r ON r.id = p.repository_id
+			WHERE r.tenant_id = $1 AND ($2 = 0 OR r.id = $2)
+				AND (cardinality($3::text[]) = 0 OR p.author_login = ANY($3::text[]))
+				AND p.opened_at >= $4 AND p.opened_at < $5
+		)`
+	args := []interface{}{tenant.FromContext(ctx), repoID, pq.Array(authors), since, until}
+
+	// percentile_cont ignores the NULL durations of pull requests not yet
+	// reviewed or merged
+	var cycle struct {
+		Opened   int             `db:"opened"`
+		Reviewed int             `db:"reviewed"`
+		Merged   int             `db:"merged"`
+		Review50 sql.NullFloat64 `db:"review_p50"`
+		Review75 sql.NullFloat64 `db:"review_p75"`
+		Review90 sql.NullFloat64 `db:"review_p90"`
+		Merge50  sql.NullFloat64 `db:"merge_p50"`
+		Merge75  sql.NullFloat64 `db:"merge_p75"`
+		Merge90  sql.NullFloat64 `db:"merge_p90"`
+	}
+	if err := db.conn.GetContext(ctx, &cycle, scoped+`
+		SELECT COUNT(*) AS opened, COUNT(first_review) AS reviewed, COUNT(merged_at) AS merged,
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY review_hours) AS review_p50,
+			percentile_cont(0.75) WITHIN GROUP (ORDER BY review_hours) AS review_p75,
+			percentile_cont(0.9) WITHIN GROUP (ORDER BY review_hours) AS review_p90,
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY merge_hours) AS merge_p50,
+			percentile_cont(0.75) WITHIN GROUP (ORDER BY merge_hours) AS merge_p75,
+			percentile_cont(0.9) WITHIN GROUP (ORDER BY merge_hours) AS merge_p90
+		FROM (
+			SELECT first_review, merged_at,
+				`+hoursBetween("opened_at", "first_review", cal, 6)+` AS review_hours,
+				`+hoursBetween("opened_at", "merged_at", cal, 6)+` AS merge_hours
+			FROM scoped
+		) durations
+	`, append(args, calendarArgs(cal)...)...); err != nil {
+		return nil, fmt.Errorf("failed to compute pull request cycle times: %w", err)
+	}
+	stats.Opened, stats.Reviewed, stats.Merged = cycle.Opened, cycle.Reviewed, cycle.Merged
+	if cycle.Reviewed > 0 {
+		stats.TimeToFirstReview = &models.DurationPercentiles{P50: cycle.Review50.Float64, P75: cycle.Review75.Float64, P90: cycle.Review90.Float64}
+	}
+	if cycle.Merged > 0 {
+		stats.TimeToMerge = &models.DurationPercentiles{P50: cycle.Merge50.Float64, P75: cycle.Merge75.Float64, P90: cycle.Merge90.Float64}
+	}
+
+	// width_bucket numbers the buckets from 0, below the first bound
+	bounds := make([]float64, len(models.PullRequestSizeBuckets))
+	stats.Sizes = make([]models.SizeBucket, len(bounds)+1)
+	for i, lines := range models.PullRequestSizeBuckets {
+		bounds[i] = float64(lines)
+		stats.Sizes[i].MaxLines = lines
+		stats.Sizes[i+1].MinLines = lines
+	}
+	var sizes []struct {
+		Bucket       int `db:"bucket"`
+		PullRequests int `db:"pull_requests"`
+	}
+	if err := db.conn.SelectContext(ctx, &sizes, scoped+`
+		SELECT width_bucket(lines::float8, $6::float8[]) AS bucket, COUNT(*) AS pull_requests
+		FROM scoped
+		GROUP BY bucket
+	`, append(args, pq.Array(bounds))...); err != nil {
+		return nil, fmt.Errorf("failed to compute pull request sizes: %w", err)
+	}
+	for _, size := range sizes {
+		stats.Sizes[size.Bucket].PullRequests = size.PullRequests
+	}
+	return stats, nil
+}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+
+	"githubapifetch/models"
+	"githubapifetch/tenant"
+)
+
+// purgeStatements delete the rows of a repository from every table holding
+// any, each after the tables referencing it. They take the repository's id,
+// except those byName, which take its tenant, owner and name.
+var purgeStatements = []struct {
+	table  string
+	query  string
+	byName bool
+}{
+	{"pull_request_reviews", `DELETE FROM pull_request_reviews WHERE pull_request_id IN (SELECT id FROM pull_requests WHERE repository_id = $1)`, false},
+	{"pull_requests", `DELETE FROM pull_requests WHERE repository_id = $1`, false},
+	{"issues", `DELETE FROM issues WHERE repository_id = $1`, false},
+	{"releases", `DELETE FROM releases WHERE repository_id = $1`, false},
+	{"deployments", `DELETE FROM deployments WHERE repository_id = $1`, false},
+	{"repository_snapshots", `DELETE FROM repository_snapshots WHERE repository_id = $1`, false},
+	{"commit_files", `DELETE FROM commit_files WHERE repository_id = $1`, false},
+	{"commit_parents", `DELETE FROM commit_parents WHERE repository_id = $1`, false},
+	{"commits", `DELETE FROM commits WHERE repository_id = $1`, false},
+	{"ingested_pages", `DELETE FROM ingested_pages WHERE repository_id = $1`, false},
+	{"sync_checkpoints", `DELETE FROM sync_checkpoints WHERE repository_id = $1`, false},
+	{"repository_failures", `DELETE FROM repository_failures WHERE repository_id = $1`, false},
+	{"rejected_commits", `DELETE FROM rejected_commits WHERE repository_id = $1`, false},
+	{"repository_path_filters", `DELETE FROM repository_path_filters WHERE repository_id = $1`, false},
+	{"raw_payloads", `DELETE FROM raw_payloads WHERE tenant_id = $1 AND owner = $2 AND name = $3`, true},
+	{"repositories", `DELETE FROM repositories WHERE id = $1`, false},
+}
+
+// errPurgeDryRun rolls back the transaction of a dry run
+var errPurgeDryRun = errors.New("dry run")
+
+// PurgeRepository deletes a repository of the context's tenant and every row
+// stored for it, in one transaction, reporting the rows deleted from each
+// table. With dryRun the deletes are rolled back, so only the counts are
+// reported. The repository is named by owner and name, as other owners may
+// have repositories of the same name. The audit log is kept.
+func (db *DB) PurgeRepository(ctx context.Context, owner, name string, dryRun bool) (*models.PurgeResult, error) {
+	if owner == "" || name == "" {
+		return nil, fmt.Errorf("%w: repository owner and name cannot be empty", ErrInvalidInput)
+	}
+
+	tenantID := tenant.FromContext(ctx)
+	var result *models.PurgeResult
+	err := db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		var repo struct {
+			ID    int    `db:"id"`
+			Owner string `db:"owner"`
+			Name  string `db:"name"`
+		}
+		// Locking the repository holds off syncs storing into it meanwhile
+		if err := tx.GetContext(ctx, &repo,
+			`SELECT id, owner, name FROM repositories WHERE owner = $1 AND name = $2 AND tenant_id = $3 FOR UPDATE`,
+			owner, name, tenantID,
+		); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("%w: repository %s/%s not found", ErrRepositoryNotFound, owner, name)
+			}
+			return fmt.Errorf("failed to get repository %s/%s: %w", owner, name, err)
+		}
+
+		result = &models.PurgeResult{Owner: repo.Owner, Name: repo.Name, DryRun: dryRun}
+		for _, stmt := range purgeStatements {
+			args := []interface{}{repo.ID}
+			if stmt.byName {
+				args = []interface{}{tenantID, repo.Owner, repo.Name}
+			}
+			res, err := tx.ExecContext(ctx, stmt.query, args...)
+			if err != nil {
+				return fmt.Errorf("failed to purge %s of repository %s/%s: %w", stmt.table, owner, name, err)
+			}
+			rows, err := res.RowsAffected()
+			if err != nil {
+				return fmt.Errorf("failed to count purged %s of repository %s/%s: %w", stmt.table, owner, name, err)
+			}
+			result.Tables = append(result.Tables, models.DumpTable{Table: stmt.table, Rows: int(rows)})
+		}
+		if dryRun {
+			return errPurgeDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errPurgeDryRun) {
+		return nil, err
+	}
+
+	if !dryRun {
+		safeLogInfo("Repository purged",
+			zap.String("owner", result.Owner),
+			zap.String("name", result.Name))
+	}
+	return result, nil
+}
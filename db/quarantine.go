@@ -0,0 +1,112 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"githubapifetch/models"
+	"githubapifetch/tenant"
+)
+
+// RecordRepositoryFailure counts a failed sync of a repository and returns
+// its consecutive failures so far
+func (db *DB) RecordRepositoryFailure(ctx context.Context, repoID int, lastError string) (*models.RepositoryFailure, error) {
+	var failure models.RepositoryFailure
+	query := `
+		INSERT INTO repository_failures (repository_id, failures, last_error, updated_at)
+		SELECT id, 1, $3, CURRENT_TIMESTAMP FROM repositories WHERE id = $1 AND tenant_id = $2
+		ON CONFLICT (repository_id) DO UPDATE SET
+			failures = repository_failures.failures + 1,
+			last_error = EXCLUDED.last_error,
+			updated_at = EXCLUDED.updated_at
+		RETURNING repository_id, failures, last_error, next_check_at, updated_at
+	`
+	if err := db.conn.GetContext(ctx, &failure, query, repoID, tenant.FromContext(ctx), lastError); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: repository %d not found", ErrRepositoryNotFound, repoID)
+		}
+		return nil, fmt.Errorf("failed to record failure of repository %d: %w", repoID, err)
+	}
+	return &failure, nil
+}
+
+// QuarantineRepository stops monitoring a failing repository until nextCheck,
+// when it is synced once more. Paused and removed repositories are left as
+// they are.
+func (db *DB) QuarantineRepository(ctx context.Context, repoID int, nextCheck time.Time) error {
+	query := `
+		WITH repo AS (
+			UPDATE repositories SET status = $3, row_updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1 AND tenant_id = $2 AND status IN ($3, $4)
+			RETURNING id
+		)
+		UPDATE repository_failures SET next_check_at = $5, updated_at = CURRENT_TIMESTAMP
+		WHERE repository_id IN (SELECT id FROM repo)
+	`
+	result, err := db.conn.ExecContext(ctx, query,
+		repoID, tenant.FromContext(ctx), models.RepoStatusQuarantined, models.RepoStatusActive, nextCheck)
+	if err != nil {
+		return fmt.Errorf("failed to quarantine repository %d: %w", repoID, err)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("%w: repository %d is not monitored", ErrRepositoryNotFound, repoID)
+	}
+
+	safeLogInfo("Repository quarantined", zap.Int("repo_id", repoID), zap.Time("next_check_at", nextCheck))
+	return nil
+}
+
+// ClearRepositoryFailures resets the failure count of a repository after a
+// successful sync, returning a quarantined repository to monitoring. It
+// reports whether the repository was quarantined.
+func (db *DB) ClearRepositoryFailures(ctx context.Context, repoID int) (bool, error) {
+	return db.clearFailures(ctx, "id = $3 AND tenant_id = $4", repoID, tenant.FromContext(ctx))
+}
+
+// clearFailures resets the failure count of the repositories matching where,
+// whose arguments start at $3, and returns the quarantined ones to
+// monitoring. It reports whether any was quarantined.
+func (db *DB) clearFailures(ctx context.Context, where string, args ...interface{}) (bool, error) {
+	query := `
+		WITH cleared AS (
+			DELETE FROM repository_failures
+			WHERE repository_id IN (SELECT id FROM repositories WHERE ` + where + `)
+			RETURNING repository_id
+		)
+		UPDATE repositories SET status = $1, row_updated_at = CURRENT_TIMESTAMP
+		WHERE id IN (SELECT repository_id FROM cleared) AND status = $2
+	`
+	args = append([]interface{}{models.RepoStatusActive, models.RepoStatusQuarantined}, args...)
+	result, err := db.conn.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, fmt.Errorf("failed to clear repository failures: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, nil
+	}
+	return rows > 0, nil
+}
+
+// RequeueRepository returns a quarantined repository to monitoring straight
+// away, resetting its failure count
+func (db *DB) RequeueRepository(ctx context.Context, name string) error {
+	if name == "" {
+		return fmt.Errorf("%w: repository name cannot be empty", ErrInvalidInput)
+	}
+
+	released, err := db.clearFailures(ctx, "name = $3 AND tenant_id = $4", name, tenant.FromContext(ctx))
+	if err != nil {
+		return err
+	}
+	if !released {
+		return fmt.Errorf("%w: repository %s is not quarantined", ErrInvalidInput, name)
+	}
+
+	safeLogInfo("Repository requeued", zap.String("name", name))
+	return nil
+}
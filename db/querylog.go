@@ -0,0 +1,277 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"githubapifetch/logger"
+)
+
+// maxLoggedStatement caps the length of a logged statement
+const maxLoggedStatement = 2000
+
+// queryLog times every statement sent to Postgres and logs it. Argument
+// values are never logged, only their types, since they include tokens and
+// commit contents.
+type queryLog struct {
+	// all logs every statement at Info
+	all bool
+	// slow logs statements taking at least this long at Warn; 0 disables it
+	slow time.Duration
+}
+
+func (l queryLog) enabled() bool {
+	return l.all || l.slow > 0
+}
+
+// record logs a statement that started at start
+func (l queryLog) record(query string, args []driver.NamedValue, start time.Time, err error) {
+	if errors.Is(err, driver.ErrSkip) {
+		return
+	}
+	elapsed := time.Since(start)
+	slow := l.slow > 0 && elapsed >= l.slow
+	if !slow && !l.all {
+		return
+	}
+
+	fields := []zap.Field{
+		zap.String("statement", compactStatement(query)),
+		zap.Strings("args", redactArgs(args)),
+		zap.Duration("duration", elapsed),
+	}
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	}
+	if slow {
+		logger.Warn("Slow query", fields...)
+	} else {
+		logger.Info("Query", fields...)
+	}
+}
+
+// compactStatement collapses the whitespace of a statement onto one line
+func compactStatement(query string) string {
+	query = strings.Join(strings.Fields(query), " ")
+	if len(query) > maxLoggedStatement {
+		query = query[:maxLoggedStatement] + "..."
+	}
+	return query
+}
+
+// redactArgs describes each argument by its type only
+func redactArgs(args []driver.NamedValue) []string {
+	out := make([]string, len(args))
+	for i, a := range args {
+		switch v := a.Value.(type) {
+		case nil:
+			out[i] = "NULL"
+		case []byte:
+			out[i] = fmt.Sprintf("[]byte(%d)", len(v))
+		case string:
+			out[i] = fmt.Sprintf("string(%d)", len(v))
+		default:
+			out[i] = fmt.Sprintf("%T", v)
+		}
+	}
+	return out
+}
+
+// loggingConnector opens connections whose statements are logged
+type loggingConnector struct {
+	driver.Connector
+	log queryLog
+}
+
+func (c *loggingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &loggingConn{conn: conn, log: c.log}, nil
+}
+
+// loggingConn wraps a pq connection. Besides the methods it times, it passes
+// on the optional interfaces pq implements.
+type loggingConn struct {
+	conn driver.Conn
+	log  queryLog
+}
+
+func (c *loggingConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *loggingConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+	if p, ok := c.conn.(driver.ConnPrepareContext); ok {
+		stmt, err = p.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &loggingStmt{stmt: stmt, query: query, log: c.log}, nil
+}
+
+func (c *loggingConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *loggingConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *loggingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.conn.Begin()
+}
+
+func (c *loggingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	res, err := e.ExecContext(ctx, query, args)
+	c.log.record(query, args, start, err)
+	return res, err
+}
+
+func (c *loggingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	rows, err := q.QueryContext(ctx, query, args)
+	if err != nil {
+		c.log.record(query, args, start, err)
+		return nil, err
+	}
+	return &loggingRows{Rows: rows, query: query, args: args, start: start, log: c.log}, nil
+}
+
+func (c *loggingConn) Ping(ctx context.Context) error {
+	if p, ok := c.conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *loggingConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *loggingConn) IsValid() bool {
+	if v, ok := c.conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+// loggingStmt times the executions of a prepared statement
+type loggingStmt struct {
+	stmt  driver.Stmt
+	query string
+	log   queryLog
+}
+
+func (s *loggingStmt) Close() error {
+	return s.stmt.Close()
+}
+
+func (s *loggingStmt) NumInput() int {
+	return s.stmt.NumInput()
+}
+
+func (s *loggingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.stmt.Exec(args)
+}
+
+func (s *loggingStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.stmt.Query(args)
+}
+
+func (s *loggingStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	var (
+		res driver.Result
+		err error
+	)
+	if e, ok := s.stmt.(driver.StmtExecContext); ok {
+		res, err = e.ExecContext(ctx, args)
+	} else {
+		res, err = s.stmt.Exec(values(args))
+	}
+	s.log.record(s.query, args, start, err)
+	return res, err
+}
+
+func (s *loggingStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var (
+		rows driver.Rows
+		err  error
+	)
+	if q, ok := s.stmt.(driver.StmtQueryContext); ok {
+		rows, err = q.QueryContext(ctx, args)
+	} else {
+		rows, err = s.stmt.Query(values(args))
+	}
+	if err != nil {
+		s.log.record(s.query, args, start, err)
+		return nil, err
+	}
+	return &loggingRows{Rows: rows, query: s.query, args: args, start: start, log: s.log}, nil
+}
+
+// values drops the names of positional arguments
+func values(args []driver.NamedValue) []driver.Value {
+	out := make([]driver.Value, len(args))
+	for i, a := range args {
+		out[i] = a.Value
+	}
+	return out
+}
+
+// loggingRows logs its query once the rows are closed, so the duration
+// includes reading them
+type loggingRows struct {
+	driver.Rows
+	query string
+	args  []driver.NamedValue
+	start time.Time
+	log   queryLog
+	err   error
+}
+
+func (r *loggingRows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return err
+}
+
+func (r *loggingRows) Close() error {
+	err := r.Rows.Close()
+	r.log.record(r.query, r.args, r.start, r.err)
+	return err
+}
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"githubapifetch/models"
+	"githubapifetch/tenant"
+)
+
+// RecordRateLimit stores a rate limit sample for the context's tenant
+func (db *DB) RecordRateLimit(ctx context.Context, sample models.RateLimitSample) error {
+	if sample.Resource == "" {
+		return fmt.Errorf("%w: rate limit sample requires a resource", ErrInvalidInput)
+	}
+	if _, err := db.conn.ExecContext(ctx, `
+		INSERT INTO rate_limit_samples (tenant_id, resource, rate_limit, remaining, reset_at, observed_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, tenant.FromContext(ctx), sample.Resource, sample.Limit, sample.Remaining, sample.ResetAt, sample.ObservedAt); err != nil {
+		return fmt.Errorf("failed to record %s rate limit: %w", sample.Resource, err)
+	}
+	return nil
+}
+
+// ListRateLimitSamples returns the rate limit samples of the context's
+// tenant observed in [since, until), oldest first
+func (db *DB) ListRateLimitSamples(ctx context.Context, since, until time.Time) ([]models.RateLimitSample, error) {
+	if !since.Before(until) {
+		return nil, fmt.Errorf("%w: the period must end after it starts", ErrInvalidInput)
+	}
+	samples := []models.RateLimitSample{}
+	if err := db.conn.SelectContext(ctx, &samples, `
+		SELECT id, tenant_id, resource, rate_limit, remaining, reset_at, observed_at
+		FROM rate_limit_samples
+		WHERE tenant_id = $1 AND observed_at >= $2 AND observed_at < $3
+		ORDER BY observed_at, id
+	`, tenant.FromContext(ctx), since, until); err != nil {
+		return nil, fmt.Errorf("failed to list rate limit samples: %w", err)
+	}
+	return samples, nil
+}
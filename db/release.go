@@ -0,0 +1,103 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"githubapifetch/models"
+	"githubapifetch/tenant"
+)
+
+// StoreReleases inserts or updates releases of a repository and returns the
+// ones it had not stored before
+func (db *DB) StoreReleases(ctx context.Context, repoID int, releases []models.Release) ([]models.Release, error) {
+	if len(releases) == 0 {
+		return nil, nil
+	}
+	var added []models.Release
+	err := db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		// A retried transaction starts over
+		added = nil
+		for _, release := range releases {
+			// xmax is only zero on rows the statement inserted
+			var inserted bool
+			if err := tx.QueryRowxContext(ctx, `
+				INSERT INTO releases (repository_id, github_id, tag_name, name, prerelease, published_at)
+				VALUES ($1, $2, $3, $4, $5, $6)
+				ON CONFLICT (repository_id, github_id) DO UPDATE SET
+					tag_name = EXCLUDED.tag_name,
+					name = EXCLUDED.name,
+					prerelease = EXCLUDED.prerelease,
+					published_at = EXCLUDED.published_at,
+					updated_at = CURRENT_TIMESTAMP
+				RETURNING xmax = 0
+			`, repoID, release.GitHubID, release.TagName, release.Name, release.Prerelease, release.PublishedAt).Scan(&inserted); err != nil {
+				return fmt.Errorf("failed to store release %s of repository %d: %w", release.TagName, repoID, err)
+			}
+			if inserted {
+				added = append(added, release)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return added, nil
+}
+
+// ListReleases returns the stored releases of a repository of the context's
+// tenant, newest first
+func (db *DB) ListReleases(ctx context.Context, repoName string) ([]models.Release, error) {
+	releases := []models.Release{}
+	if err := db.conn.SelectContext(ctx, &releases, `
+		SELECT rl.repository_id, rl.github_id, rl.tag_name, rl.name, rl.prerelease, rl.published_at
+		FROM releases rl
+		JOIN repositories r ON r.id = rl.repository_id
+		WHERE r.name = $1 AND r.tenant_id = $2
+		ORDER BY rl.published_at DESC, rl.id DESC
+	`, repoName, tenant.FromContext(ctx)); err != nil {
+		return nil, fmt.Errorf("failed to list releases of repository %s: %w", repoName, err)
+	}
+	return releases, nil
+}
+
+// ReleaseCadence describes how often each repository of the context's
+// tenant published releases in [since, until), for the repositories with at
+// least one. Prereleases are left out unless prereleases is set.
+func (db *DB) ReleaseCadence(ctx context.Context, since, until time.Time, prereleases bool) ([]models.ReleaseCadence, error) {
+	if !since.Before(until) {
+		return nil, fmt.Errorf("%w: the period must end after it starts", ErrInvalidInput)
+	}
+
+	// The gap of the first release of the period is measured from the
+	// release before it, so the window runs over every release
+	cadence := []models.ReleaseCadence{}
+	if err := db.conn.SelectContext(ctx, &cadence, `
+		WITH gaps AS (
+			SELECT rl.repository_id, rl.tag_name, rl.published_at,
+				(EXTRACT(EPOCH FROM rl.published_at - lag(rl.published_at) OVER (
+					PARTITION BY rl.repository_id ORDER BY rl.published_at
+				)) / 86400)::float8 AS days
+			FROM releases rl
+			JOIN repositories r ON r.id = rl.repository_id
+			WHERE r.tenant_id = $1 AND ($4 OR NOT rl.prerelease)
+		)
+		SELECT r.name AS repo_name, COUNT(*) AS releases,
+			(array_agg(g.tag_name ORDER BY g.published_at DESC))[1] AS last_tag,
+			MAX(g.published_at) AS last_release,
+			AVG(g.days) AS mean_days,
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY g.days) AS median_days
+		FROM gaps g
+		JOIN repositories r ON r.id = g.repository_id
+		WHERE g.published_at >= $2 AND g.published_at < $3
+		GROUP BY r.id, r.name
+		ORDER BY r.name
+	`, tenant.FromContext(ctx), since, until, prereleases); err != nil {
+		return nil, fmt.Errorf("failed to compute release cadence: %w", err)
+	}
+	return cadence, nil
+}
@@ -0,0 +1,249 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"githubapifetch/models"
+	"githubapifetch/tenant"
+)
+
+// KnowledgeConcentration reports, for every repository of the context's
+// tenant with commits dated in [since, until), how much of the work was done
+// by its top authors. Authors are ranked by commit count, ties by name.
+func (db *DB) KnowledgeConcentration(ctx context.Context, since, until time.Time, top int) ([]models.KnowledgeConcentration, error) {
+	if top <= 0 {
+		return nil, fmt.Errorf("%w: the number of top authors must be positive", ErrInvalidInput)
+	}
+	if !since.Before(until) {
+		return nil, fmt.Errorf("%w: the period must end after it starts", ErrInvalidInput)
+	}
+
+	query := `
+		WITH period AS (
+			SELECT c.repository_id, c.sha, c.author_name
+			FROM commits c
+			JOIN repositories r ON c.repository_id = r.id
+			WHERE r.tenant_id = $1 AND r.status <> $2 AND c.date >= $3 AND c.date < $4
+		),
+		authors AS (
+			SELECT repository_id, author_name, COUNT(*) AS commits,
+				ROW_NUMBER() OVER w AS rank,
+				SUM(COUNT(*)) OVER w AS running,
+				SUM(COUNT(*)) OVER (PARTITION BY repository_id) AS total
+			FROM period
+			GROUP BY repository_id, author_name
+			WINDOW w AS (PARTITION BY repository_id ORDER BY COUNT(*) DESC, author_name)
+		),
+		owners AS (
+			SELECT DISTINCT ON (f.repository_id, f.path) f.repository_id, f.path, p.author_name
+			FROM commit_files f
+			JOIN period p ON p.repository_id = f.repository_id AND p.sha = f.sha
+			GROUP BY f.repository_id, f.path, p.author_name
+			ORDER BY f.repository_id, f.path, COUNT(*) DESC, p.author_name
+		)
+		SELECT r.name AS repo_name,
+			MAX(a.total)::int AS total_commits,
+			COUNT(*) AS authors,
+			COALESCE(SUM(a.commits) FILTER (WHERE a.rank <= $5), 0)::int AS top_commits,
+			MIN(a.rank) FILTER (WHERE a.running * 2 > a.total) AS bus_factor,
+			(SELECT COUNT(*) FROM owners o WHERE o.repository_id = r.id) AS files,
+			(SELECT COUNT(*) FROM owners o
+				JOIN authors t ON t.repository_id = o.repository_id AND t.author_name = o.author_name
+				WHERE o.repository_id = r.id AND t.rank <= $5) AS top_files
+		FROM authors a
+		JOIN repositories r ON a.repository_id = r.id
+		GROUP BY r.id, r.name
+		ORDER BY r.name
+	`
+	report := []models.KnowledgeConcentration{}
+	if err := db.conn.SelectContext(ctx, &report, query,
+		tenant.FromContext(ctx), models.RepoStatusRemoved, since, until, top,
+	); err != nil {
+		return nil, fmt.Errorf("failed to compute knowledge concentration: %w", err)
+	}
+	return report, nil
+}
+
+// RepositoryDiff reports how a repository of the context's tenant changed
+// between from and to: its counters, from the last snapshots taken at or
+// before each, and the commits authored in (from, to]
+func (db *DB) RepositoryDiff(ctx context.Context, repoName string, from, to time.Time) (*models.RepositoryDiff, error) {
+	if !from.Before(to) {
+		return nil, fmt.Errorf("%w: the period must end after it starts", ErrInvalidInput)
+	}
+	repo, err := db.GetByName(ctx, repoName)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &models.RepositoryDiff{Owner: repo.Owner, Name: repo.Name, From: from, To: to, NewAuthors: []string{}}
+	if diff.Before, err = db.snapshotAt(ctx, repo.ID, from); err != nil {
+		return nil, err
+	}
+	if diff.After, err = db.snapshotAt(ctx, repo.ID, to); err != nil {
+		return nil, err
+	}
+
+	if err := db.conn.GetContext(ctx, &diff.CommitsAdded,
+		`SELECT COUNT(*) FROM commits WHERE repository_id = $1 AND date > $2 AND date <= $3`,
+		repo.ID, from, to,
+	); err != nil {
+		return nil, fmt.Errorf("failed to count commits of repository %s: %w", repoName, err)
+	}
+
+	query := `
+		SELECT DISTINCT c.author_name
+		FROM commits c
+		WHERE c.repository_id = $1 AND c.date > $2 AND c.date <= $3 AND c.author_name <> ''
+			AND NOT EXISTS (
+				SELECT 1 FROM commits p
+				WHERE p.repository_id = c.repository_id AND p.author_name = c.author_name AND p.date <= $2
+			)
+		ORDER BY c.author_name
+	`
+	if err := db.conn.SelectContext(ctx, &diff.NewAuthors, query, repo.ID, from, to); err != nil {
+		return nil, fmt.Errorf("failed to list new authors of repository %s: %w", repoName, err)
+	}
+	return diff, nil
+}
+
+// snapshotAt returns the last snapshot of a repository taken at or before
+// at, or nil when there is none
+func (db *DB) snapshotAt(ctx context.Context, repoID int, at time.Time) (*models.RepositorySnapshot, error) {
+	var snapshot models.RepositorySnapshot
+	err := db.conn.GetContext(ctx, &snapshot, `
+		SELECT stars_count, forks_count, open_issues_count, watchers_count, recorded_at
+		FROM repository_snapshots
+		WHERE repository_id = $1 AND recorded_at <= $2
+		ORDER BY recorded_at DESC, id DESC
+		LIMIT 1
+	`, repoID, at)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get snapshot of repository %d: %w", repoID, err)
+	}
+	return &snapshot, nil
+}
+
+// CommitHeatmap counts the commits of the context's tenant authored in
+// [since, until) by day of week and hour of day in the named time zone.
+// An empty repoName covers every repository that is not removed, and an
+// empty author every author.
+func (db *DB) CommitHeatmap(ctx context.Context, repoName, author string, since, until time.Time, timezone string) (*models.Heatmap, error) {
+	if !since.Before(until) {
+		return nil, fmt.Errorf("%w: the period must end after it starts", ErrInvalidInput)
+	}
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unknown time zone %q", ErrInvalidInput, timezone)
+	}
+	if repoName != "" {
+		if _, err := db.GetByName(ctx, repoName); err != nil {
+			return nil, err
+		}
+	}
+
+	// Commit dates are stored as UTC without a zone
+	query := `
+		SELECT EXTRACT(DOW FROM local_date)::int AS day, EXTRACT(HOUR FROM local_date)::int AS hour, COUNT(*) AS commits
+		FROM (
+			SELECT (c.date AT TIME ZONE 'UTC') AT TIME ZONE $1 AS local_date
+			FROM commits c
+			JOIN repositories r ON c.repository_id = r.id
+			WHERE r.tenant_id = $2 AND r.status <> $3 AND c.date >= $4 AND c.date < $5
+				AND ($6 = '' OR r.name = $6) AND ($7 = '' OR c.author_name = $7)
+		) dated
+		GROUP BY day, hour
+	`
+	var cells []struct {
+		Day     int `db:"day"`
+		Hour    int `db:"hour"`
+		Commits int `db:"commits"`
+	}
+	if err := db.conn.SelectContext(ctx, &cells, query,
+		loc.String(), tenant.FromContext(ctx), models.RepoStatusRemoved, since.UTC(), until.UTC(), repoName, author,
+	); err != nil {
+		return nil, fmt.Errorf("failed to compute commit heatmap: %w", err)
+	}
+
+	heatmap := &models.Heatmap{Repo: repoName, Author: author, Since: since, Until: until, Timezone: loc.String()}
+	for _, c := range cells {
+		heatmap.Cells[c.Day][c.Hour] = c.Commits
+		heatmap.Total += c.Commits
+	}
+	return heatmap, nil
+}
+
+// PathOwnership infers who works on which part of a repository of the
+// context's tenant from the files touched by its commits authored in
+// [since, until), which only repositories with path filters record. Files
+// are grouped by their directory cut to depth levels, and the limit busiest
+// prefixes are returned with their top committers, most commits first.
+func (db *DB) PathOwnership(ctx context.Context, repoName string, since, until time.Time, depth, top, limit int) ([]models.PathOwnership, error) {
+	if depth <= 0 || top <= 0 || limit <= 0 {
+		return nil, fmt.Errorf("%w: the depth and the numbers of committers and prefixes must be positive", ErrInvalidInput)
+	}
+	if !since.Before(until) {
+		return nil, fmt.Errorf("%w: the period must end after it starts", ErrInvalidInput)
+	}
+	repo, err := db.GetByName(ctx, repoName)
+	if err != nil {
+		return nil, err
+	}
+
+	// The last segment of a path is the file name
+	query := `
+		WITH touched AS (
+			SELECT DISTINCT c.sha, c.author_name,
+				array_to_string((string_to_array(f.path, '/'))[1:LEAST($4, cardinality(string_to_array(f.path, '/')) - 1)], '/') AS prefix
+			FROM commit_files f
+			JOIN commits c ON c.repository_id = f.repository_id AND c.sha = f.sha
+			WHERE f.repository_id = $1 AND c.date >= $2 AND c.date < $3
+		),
+		prefixes AS (
+			SELECT prefix, COUNT(*) AS commits
+			FROM touched
+			GROUP BY prefix
+			ORDER BY commits DESC, prefix
+			LIMIT $6
+		),
+		committers AS (
+			SELECT t.prefix, t.author_name, COUNT(*) AS commits,
+				ROW_NUMBER() OVER (PARTITION BY t.prefix ORDER BY COUNT(*) DESC, t.author_name) AS rank
+			FROM touched t
+			JOIN prefixes p ON p.prefix = t.prefix
+			GROUP BY t.prefix, t.author_name
+		)
+		SELECT p.prefix, p.commits AS prefix_commits, c.author_name, c.commits
+		FROM prefixes p
+		JOIN committers c ON c.prefix = p.prefix
+		WHERE c.rank <= $5
+		ORDER BY p.commits DESC, p.prefix, c.rank
+	`
+	var rows []struct {
+		Prefix        string `db:"prefix"`
+		PrefixCommits int    `db:"prefix_commits"`
+		models.PathCommitter
+	}
+	if err := db.conn.SelectContext(ctx, &rows, query, repo.ID, since.UTC(), until.UTC(), depth, top, limit); err != nil {
+		return nil, fmt.Errorf("failed to compute path ownership of repository %s: %w", repoName, err)
+	}
+
+	paths := []models.PathOwnership{}
+	for _, row := range rows {
+		if len(paths) == 0 || paths[len(paths)-1].Prefix != row.Prefix {
+			paths = append(paths, models.PathOwnership{Prefix: row.Prefix, Commits: row.PrefixCommits})
+		}
+		last := &paths[len(paths)-1]
+		last.Committers = append(last.Committers, row.PathCommitter)
+	}
+	return paths, nil
+}
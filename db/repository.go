@@ -3,50 +3,108 @@ package db
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
 
 	"githubapifetch/models"
+	"githubapifetch/tenant"
 )
 
+// repositoryColumns lists the columns selected for repository queries
+const repositoryColumns = `id, tenant_id, COALESCE(github_id, 0) AS github_id,
+			COALESCE(node_id, '') AS node_id, name, owner, url,
+			created_at, updated_at, description, language, forks_count, stars_count,
+			open_issues_count, watchers_count, status, start_date, start_date_auto,
+			COALESCE(poll_interval, 0) AS poll_interval,
+			COALESCE(default_branch, '') AS default_branch,
+			COALESCE(repo_group, '') AS repo_group, last_sync_at, last_success_at,
+			EXISTS (SELECT 1 FROM repository_path_filters pf WHERE pf.repository_id = repositories.id) AS has_path_filters`
+
 // StoreRepository stores a repository in the database
 func (db *DB) StoreRepository(ctx context.Context, repo models.Repository) error {
 	if repo.Name == "" || repo.Owner == "" {
 		return fmt.Errorf("%w: repository name and owner cannot be empty", ErrInvalidInput)
 	}
 
+	tenantID := repo.TenantID
+	if tenantID == 0 {
+		tenantID = tenant.FromContext(ctx)
+	}
+
+	// A known GitHub ID identifies the row even after a rename or transfer;
+	// move it to the current owner/name first so the upsert below updates it
+	if repo.GitHubID != 0 {
+		existing, err := db.GetByGitHubID(ctx, repo.GitHubID)
+		if err != nil && !errors.Is(err, ErrRepositoryNotFound) {
+			return err
+		}
+		if err == nil && (existing.Owner != repo.Owner || existing.Name != repo.Name) {
+			if _, err := db.RenameRepository(ctx, existing.ID, repo.Owner, repo.Name); err != nil {
+				return err
+			}
+		}
+	}
+
 	safeLogInfo("Storing repository", zap.String("owner", repo.Owner), zap.String("name", repo.Name))
+	// The counters are snapshotted in the same statement whenever one of
+	// them changed since the repository's last snapshot
 	query := `
-		INSERT INTO repositories (
-			name, owner, url, created_at, updated_at,
-			description, language, forks_count, stars_count,
-			open_issues_count, watchers_count
+		WITH stored AS (
+			INSERT INTO repositories (
+				name, owner, url, created_at, updated_at,
+				description, language, forks_count, stars_count,
+				open_issues_count, watchers_count, tenant_id, github_id, node_id
+			)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NULLIF($13, 0), NULLIF($14, ''))
+			ON CONFLICT (tenant_id, name, owner) DO UPDATE SET
+				github_id = COALESCE(EXCLUDED.github_id, repositories.github_id),
+				node_id = COALESCE(EXCLUDED.node_id, repositories.node_id),
+				url = EXCLUDED.url,
+				created_at = EXCLUDED.created_at,
+				updated_at = EXCLUDED.updated_at,
+				description = EXCLUDED.description,
+				language = EXCLUDED.language,
+				forks_count = EXCLUDED.forks_count,
+				stars_count = EXCLUDED.stars_count,
+				open_issues_count = EXCLUDED.open_issues_count,
+				watchers_count = EXCLUDED.watchers_count,
+				row_updated_at = CURRENT_TIMESTAMP
+			RETURNING id, stars_count, forks_count, open_issues_count, watchers_count
+		), snapshot AS (
+			INSERT INTO repository_snapshots (repository_id, stars_count, forks_count, open_issues_count, watchers_count)
+			SELECT s.id, s.stars_count, s.forks_count, s.open_issues_count, s.watchers_count FROM stored s
+			WHERE NOT EXISTS (
+				SELECT 1 FROM (
+					SELECT stars_count, forks_count, open_issues_count, watchers_count FROM repository_snapshots
+					WHERE repository_id = s.id
+					ORDER BY recorded_at DESC, id DESC
+					LIMIT 1
+				) last
+				WHERE (last.stars_count, last.forks_count, last.open_issues_count, last.watchers_count) =
+					(s.stars_count, s.forks_count, s.open_issues_count, s.watchers_count)
+			)
 		)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-		ON CONFLICT (name, owner) DO UPDATE SET
-			url = EXCLUDED.url,
-			updated_at = EXCLUDED.updated_at,
-			description = EXCLUDED.description,
-			language = EXCLUDED.language,
-			forks_count = EXCLUDED.forks_count,
-			stars_count = EXCLUDED.stars_count,
-			open_issues_count = EXCLUDED.open_issues_count,
-			watchers_count = EXCLUDED.watchers_count
+		SELECT id FROM stored
 	`
 
-	_, err := db.conn.ExecContext(ctx, query,
+	var id int
+	if err := db.ext(ctx).QueryRowxContext(ctx, query,
 		repo.Name, repo.Owner, repo.URL, repo.CreatedAt, repo.UpdatedAt,
 		repo.Description, repo.Language, repo.ForksCount, repo.StarsCount,
-		repo.OpenIssuesCount, repo.WatchersCount,
-	)
-	if err != nil {
+		repo.OpenIssuesCount, repo.WatchersCount, tenantID, repo.GitHubID, repo.NodeID,
+	).Scan(&id); err != nil {
 		return fmt.Errorf("failed to store repository: %w", err)
 	}
 
 	safeLogInfo("Repository stored successfully",
 		zap.String("owner", repo.Owner),
-		zap.String("name", repo.Name))
+		zap.String("name", repo.Name),
+		zap.Int("id", id))
 	return nil
 }
 
@@ -59,14 +117,12 @@ func (db *DB) GetByName(ctx context.Context, name string) (*models.Repository, e
 	safeLogInfo("Retrieving repository by name", zap.String("name", name))
 	var repo models.Repository
 	query := `
-		SELECT id, name, owner, url, created_at, updated_at,
-			description, language, forks_count, stars_count,
-			open_issues_count, watchers_count
+		SELECT ` + repositoryColumns + `
 		FROM repositories
-		WHERE name = $1
+		WHERE name = $1 AND tenant_id = $2
 	`
 
-	if err := db.conn.GetContext(ctx, &repo, query, name); err != nil {
+	if err := sqlx.GetContext(ctx, db.ext(ctx), &repo, query, name, tenant.FromContext(ctx)); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("%w: repository %s not found", ErrRepositoryNotFound, name)
 		}
@@ -77,13 +133,77 @@ func (db *DB) GetByName(ctx context.Context, name string) (*models.Repository, e
 	return &repo, nil
 }
 
-// GetRepositoryStats returns statistics about a repository
-func (db *DB) GetRepositoryStats(ctx context.Context, repoName string) (*models.RepositoryStats, error) {
+// GetByOwnerAndName retrieves a repository by its owner and name, which
+// unlike its name alone identify it among the tenant's repositories
+func (db *DB) GetByOwnerAndName(ctx context.Context, owner, name string) (*models.Repository, error) {
+	if owner == "" || name == "" {
+		return nil, fmt.Errorf("%w: repository owner and name cannot be empty", ErrInvalidInput)
+	}
+
+	var repo models.Repository
+	query := `
+		SELECT ` + repositoryColumns + `
+		FROM repositories
+		WHERE owner = $1 AND name = $2 AND tenant_id = $3
+	`
+	if err := sqlx.GetContext(ctx, db.ext(ctx), &repo, query, owner, name, tenant.FromContext(ctx)); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: repository %s/%s not found", ErrRepositoryNotFound, owner, name)
+		}
+		return nil, fmt.Errorf("failed to get repository %s/%s: %w", owner, name, err)
+	}
+	return &repo, nil
+}
+
+// GetByGitHubID retrieves a repository by its numeric GitHub ID, which unlike
+// its name survives renames and transfers
+func (db *DB) GetByGitHubID(ctx context.Context, githubID int64) (*models.Repository, error) {
+	if githubID <= 0 {
+		return nil, fmt.Errorf("%w: GitHub ID must be positive", ErrInvalidInput)
+	}
+
+	var repo models.Repository
+	query := `
+		SELECT ` + repositoryColumns + `
+		FROM repositories
+		WHERE github_id = $1 AND tenant_id = $2
+	`
+
+	if err := sqlx.GetContext(ctx, db.ext(ctx), &repo, query, githubID, tenant.FromContext(ctx)); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: repository with GitHub ID %d not found", ErrRepositoryNotFound, githubID)
+		}
+		return nil, fmt.Errorf("failed to get repository %d: %w", githubID, err)
+	}
+	return &repo, nil
+}
+
+// GetRepositoryStats returns statistics about a repository, limited to the
+// commits matching the named path filter unless pathFilter is empty, and to
+// those filter selects. A period without commits has no first or last commit
+// date.
+func (db *DB) GetRepositoryStats(ctx context.Context, repoName, pathFilter string, filter models.StatsFilter) (*models.RepositoryStats, error) {
 	if repoName == "" {
 		return nil, fmt.Errorf("%w: repository name cannot be empty", ErrInvalidInput)
 	}
+	if !filter.Since.IsZero() && !filter.Until.IsZero() && !filter.Since.Before(filter.Until) {
+		return nil, fmt.Errorf("%w: the period must end after it starts", ErrInvalidInput)
+	}
 
-	stats := &models.RepositoryStats{}
+	condition, args, err := db.pathFilterCondition(ctx, repoName, pathFilter, 3)
+	if err != nil {
+		return nil, err
+	}
+	args = append([]interface{}{repoName, tenant.FromContext(ctx)}, args...)
+	filterCondition, args := statsFilterCondition(filter, args)
+	condition += filterCondition
+
+	var row struct {
+		TotalCommits    int          `db:"total_commits"`
+		UniqueAuthors   int          `db:"unique_authors"`
+		FirstCommitDate sql.NullTime `db:"first_commit_date"`
+		LastCommitDate  sql.NullTime `db:"last_commit_date"`
+	}
 	query := `
 		SELECT 
 			COUNT(*) as total_commits,
@@ -92,15 +212,347 @@ func (db *DB) GetRepositoryStats(ctx context.Context, repoName string) (*models.
 			MAX(c.date) as last_commit_date
 		FROM commits c
 		JOIN repositories r ON c.repository_id = r.id
-		WHERE r.name = $1
+		WHERE r.name = $1 AND r.tenant_id = $2` + condition + `
 	`
-
-	if err := db.conn.GetContext(ctx, stats, query, repoName); err != nil {
+	if err := db.conn.GetContext(ctx, &row, query, args...); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("%w: no statistics found for repository %s", ErrRepositoryNotFound, repoName)
 		}
 		return nil, fmt.Errorf("failed to get repository statistics: %w", err)
 	}
 
-	return stats, nil
+	return &models.RepositoryStats{
+		TotalCommits:    row.TotalCommits,
+		UniqueAuthors:   row.UniqueAuthors,
+		FirstCommitDate: row.FirstCommitDate.Time,
+		LastCommitDate:  row.LastCommitDate.Time,
+	}, nil
+}
+
+// statsFilterCondition returns the conditions on the commits c that filter
+// selects, each starting with AND, and args with their parameters appended
+func statsFilterCondition(filter models.StatsFilter, args []interface{}) (string, []interface{}) {
+	condition := ""
+	// Commit dates are stored as UTC without a zone
+	if !filter.Since.IsZero() {
+		args = append(args, filter.Since.UTC())
+		condition += fmt.Sprintf(" AND c.date >= $%d", len(args))
+	}
+	if !filter.Until.IsZero() {
+		args = append(args, filter.Until.UTC())
+		condition += fmt.Sprintf(" AND c.date < $%d", len(args))
+	}
+	if filter.Author != "" {
+		args = append(args, filter.Author)
+		condition += fmt.Sprintf(" AND c.author_name = $%d", len(args))
+	}
+	return condition, args
+}
+
+// SetRepositoryStatus changes whether a repository is monitored
+func (db *DB) SetRepositoryStatus(ctx context.Context, name, status string) error {
+	if name == "" {
+		return fmt.Errorf("%w: repository name cannot be empty", ErrInvalidInput)
+	}
+	switch status {
+	case models.RepoStatusActive, models.RepoStatusPaused, models.RepoStatusRemoved:
+	default:
+		return fmt.Errorf("%w: unknown repository status %q", ErrInvalidInput, status)
+	}
+
+	query := `UPDATE repositories SET status = $1, row_updated_at = CURRENT_TIMESTAMP WHERE name = $2 AND tenant_id = $3`
+	result, err := db.conn.ExecContext(ctx, query, status, name, tenant.FromContext(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to set status of repository %s: %w", name, err)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("%w: repository %s not found", ErrRepositoryNotFound, name)
+	}
+
+	safeLogInfo("Repository status changed", zap.String("name", name), zap.String("status", status))
+	return nil
+}
+
+// ActivateRepository resumes monitoring a repository, whatever its status.
+// It is keyed by ID, so other owners' repositories of the same name are left
+// as they are.
+func (db *DB) ActivateRepository(ctx context.Context, repoID int) error {
+	query := `UPDATE repositories SET status = $1, row_updated_at = CURRENT_TIMESTAMP WHERE id = $2 AND tenant_id = $3`
+	result, err := db.conn.ExecContext(ctx, query, models.RepoStatusActive, repoID, tenant.FromContext(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to activate repository %d: %w", repoID, err)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("%w: repository %d not found", ErrRepositoryNotFound, repoID)
+	}
+
+	safeLogInfo("Repository status changed", zap.Int("repo_id", repoID), zap.String("status", models.RepoStatusActive))
+	return nil
+}
+
+// SetStartDate sets where a repository's first sync starts, which applies
+// again whenever it has no commits stored. A zero start date starts from the
+// repository's creation on GitHub.
+func (db *DB) SetStartDate(ctx context.Context, repoID int, start time.Time) error {
+	var date *time.Time
+	if !start.IsZero() {
+		date = &start
+	}
+	query := `UPDATE repositories SET start_date = $1, start_date_auto = $2, row_updated_at = CURRENT_TIMESTAMP WHERE id = $3 AND tenant_id = $4`
+	result, err := db.conn.ExecContext(ctx, query, date, start.IsZero(), repoID, tenant.FromContext(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to set start date of repository %d: %w", repoID, err)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("%w: repository %d not found", ErrRepositoryNotFound, repoID)
+	}
+	return nil
+}
+
+// SetRepositoryGroup puts a repository in a group; an empty group takes it
+// out of its group
+func (db *DB) SetRepositoryGroup(ctx context.Context, name, group string) error {
+	if name == "" {
+		return fmt.Errorf("%w: repository name cannot be empty", ErrInvalidInput)
+	}
+
+	query := `UPDATE repositories SET repo_group = NULLIF($1, ''), row_updated_at = CURRENT_TIMESTAMP WHERE name = $2 AND tenant_id = $3`
+	result, err := db.conn.ExecContext(ctx, query, group, name, tenant.FromContext(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to set group of repository %s: %w", name, err)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("%w: repository %s not found", ErrRepositoryNotFound, name)
+	}
+	return nil
+}
+
+// ListRepositories returns the repositories of the context's tenant that have
+// not been removed
+func (db *DB) ListRepositories(ctx context.Context) ([]models.Repository, error) {
+	repos := []models.Repository{}
+	query := `SELECT ` + repositoryColumns + ` FROM repositories WHERE tenant_id = $1 AND status <> $2 ORDER BY id`
+	if err := db.conn.SelectContext(ctx, &repos, query, tenant.FromContext(ctx), models.RepoStatusRemoved); err != nil {
+		return nil, fmt.Errorf("failed to list repositories: %w", err)
+	}
+	return repos, nil
+}
+
+// repositoryOrders are the ORDER BY clauses of the repository sorts, each
+// ending in the ID so pages are stable
+var repositoryOrders = map[string]string{
+	models.RepoSortName:     "lower(owner), lower(name), id",
+	models.RepoSortLastSync: "last_sync_at DESC NULLS LAST, id",
+	models.RepoSortStars:    "COALESCE(stars_count, 0) DESC, id",
+	models.RepoSortCommits:  "commit_count DESC, id",
+}
+
+// SearchRepositories returns a page of the repositories of the context's
+// tenant the filter selects, in its order, with their stored commit counts
+func (db *DB) SearchRepositories(ctx context.Context, filter models.RepositoryFilter, params models.PaginationParams) ([]models.RepositoryListing, error) {
+	sort := filter.Sort
+	if sort == "" {
+		sort = models.RepoSortName
+	}
+	order, ok := repositoryOrders[sort]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown sort %q, want one of %s", ErrInvalidInput, filter.Sort, strings.Join(models.RepoSorts, ", "))
+	}
+	switch filter.Status {
+	case "", models.RepoStatusActive, models.RepoStatusPaused, models.RepoStatusRemoved, models.RepoStatusQuarantined:
+	default:
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidInput, filter.Status)
+	}
+	if filter.MinStars < 0 {
+		return nil, fmt.Errorf("%w: minimum stars cannot be negative", ErrInvalidInput)
+	}
+
+	repos := []models.RepositoryListing{}
+	query := `
+		SELECT ` + repositoryColumns + `,
+			(SELECT COUNT(*) FROM commits c WHERE c.repository_id = repositories.id) AS commit_count
+		FROM repositories
+		WHERE tenant_id = $1
+			AND (($2 = '' AND status <> $3) OR status = $2)
+			AND ($4 = '' OR lower(owner) = lower($4))
+			AND ($5 = '' OR lower(language) = lower($5))
+			AND ($6 = '' OR repo_group = $6)
+			AND COALESCE(stars_count, 0) >= $7
+		ORDER BY ` + order + `
+		LIMIT $8 OFFSET $9
+	`
+	if err := db.conn.SelectContext(ctx, &repos, query,
+		tenant.FromContext(ctx), filter.Status, models.RepoStatusRemoved, filter.Owner, filter.Language, filter.Group, filter.MinStars,
+		params.PageSize, params.Offset(),
+	); err != nil {
+		return nil, fmt.Errorf("failed to search repositories: %w", err)
+	}
+	return repos, nil
+}
+
+// SetGitHubIdentity records the numeric GitHub ID and node ID of a stored
+// repository
+func (db *DB) SetGitHubIdentity(ctx context.Context, repoID int, githubID int64, nodeID string) error {
+	if githubID <= 0 {
+		return fmt.Errorf("%w: GitHub ID must be positive", ErrInvalidInput)
+	}
+
+	query := `UPDATE repositories SET github_id = $1, node_id = NULLIF($2, ''), row_updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3 AND tenant_id = $4`
+	result, err := db.conn.ExecContext(ctx, query, githubID, nodeID, repoID, tenant.FromContext(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to set GitHub ID of repository %d: %w", repoID, err)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("%w: repository %d not found", ErrRepositoryNotFound, repoID)
+	}
+	return nil
+}
+
+// SetDefaultBranch records the default branch GitHub reports for a stored
+// repository and returns the previously recorded one, which is empty if none
+// was recorded yet
+func (db *DB) SetDefaultBranch(ctx context.Context, repoID int, branch string) (string, error) {
+	if branch == "" {
+		return "", fmt.Errorf("%w: default branch cannot be empty", ErrInvalidInput)
+	}
+
+	query := `
+		UPDATE repositories r SET default_branch = $1, row_updated_at = CURRENT_TIMESTAMP
+		FROM (SELECT id, default_branch FROM repositories WHERE id = $2 AND tenant_id = $3 FOR UPDATE) old
+		WHERE r.id = old.id
+		RETURNING COALESCE(old.default_branch, '')
+	`
+	var previous string
+	if err := db.ext(ctx).QueryRowxContext(ctx, query, branch, repoID, tenant.FromContext(ctx)).Scan(&previous); err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("%w: repository %d not found", ErrRepositoryNotFound, repoID)
+		}
+		return "", fmt.Errorf("failed to set default branch of repository %d: %w", repoID, err)
+	}
+
+	if previous != branch {
+		safeLogInfo("Repository default branch recorded",
+			zap.Int("repo_id", repoID),
+			zap.String("previous", previous),
+			zap.String("branch", branch))
+	}
+	return previous, nil
+}
+
+// mergeStatements carry the history of a duplicate repository, $2, over to
+// the repository it is merged into, $1, and then delete the duplicate. Rows
+// already stored for the surviving repository win: commits, their files and
+// parents by SHA, pages by content, issues and pull requests by number,
+// releases and deployments by GitHub ID and path filters by name. Reviews
+// follow their pull requests. Only the duplicate's sync checkpoint and
+// failure count are dropped, as they describe syncs of the row going away.
+var mergeStatements = []string{
+	`INSERT INTO commits (sha, repository_id, message, author_name, date, url, api_url, message_hash, committer_date, message_gzip, files_fetched)
+		SELECT sha, $1, message, author_name, date, url, api_url, message_hash, committer_date, message_gzip, files_fetched FROM commits WHERE repository_id = $2
+		ON CONFLICT (repository_id, sha) DO NOTHING`,
+	`INSERT INTO commit_files (repository_id, sha, path)
+		SELECT $1, sha, path FROM commit_files WHERE repository_id = $2
+		ON CONFLICT (repository_id, sha, path) DO NOTHING`,
+	`INSERT INTO commit_parents (repository_id, sha, position, parent_sha)
+		SELECT $1, sha, position, parent_sha FROM commit_parents WHERE repository_id = $2
+		ON CONFLICT (repository_id, sha, position) DO NOTHING`,
+	`UPDATE ingested_pages SET repository_id = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE repository_id = $2 AND content_hash NOT IN (
+			SELECT content_hash FROM ingested_pages WHERE repository_id = $1)`,
+	`UPDATE rejected_commits SET repository_id = $1, updated_at = CURRENT_TIMESTAMP WHERE repository_id = $2`,
+	`UPDATE repository_snapshots SET repository_id = $1, updated_at = CURRENT_TIMESTAMP WHERE repository_id = $2`,
+	`UPDATE repository_path_filters SET repository_id = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE repository_id = $2 AND name NOT IN (
+			SELECT name FROM repository_path_filters WHERE repository_id = $1)`,
+	`UPDATE issues SET repository_id = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE repository_id = $2 AND number NOT IN (
+			SELECT number FROM issues WHERE repository_id = $1)`,
+	`UPDATE pull_requests SET repository_id = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE repository_id = $2 AND number NOT IN (
+			SELECT number FROM pull_requests WHERE repository_id = $1)`,
+	`UPDATE releases SET repository_id = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE repository_id = $2 AND github_id NOT IN (
+			SELECT github_id FROM releases WHERE repository_id = $1)`,
+	`UPDATE deployments SET repository_id = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE repository_id = $2 AND github_id NOT IN (
+			SELECT github_id FROM deployments WHERE repository_id = $1)`,
+	`DELETE FROM repositories WHERE id = $2`,
+}
+
+// RenameRepository moves a stored repository to a new owner/name after it was
+// renamed or transferred on GitHub. If a second row already exists under the
+// new owner/name, typically created by a sync that followed GitHub's redirect,
+// its history is merged into the renamed repository and the row is removed,
+// so one repository never ends up split across two rows. It reports whether
+// rows were merged.
+func (db *DB) RenameRepository(ctx context.Context, repoID int, newOwner, newName string) (bool, error) {
+	if newOwner == "" || newName == "" {
+		return false, fmt.Errorf("%w: repository name and owner cannot be empty", ErrInvalidInput)
+	}
+	tenantID := tenant.FromContext(ctx)
+
+	var old struct {
+		Owner string `db:"owner"`
+		Name  string `db:"name"`
+	}
+	var merged bool
+	err := db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		if err := tx.GetContext(ctx, &old,
+			`SELECT owner, name FROM repositories WHERE id = $1 AND tenant_id = $2 FOR UPDATE`,
+			repoID, tenantID,
+		); err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("%w: repository %d not found", ErrRepositoryNotFound, repoID)
+			}
+			return fmt.Errorf("failed to lock repository %d: %w", repoID, err)
+		}
+
+		var duplicateID int
+		err := tx.GetContext(ctx, &duplicateID,
+			`SELECT id FROM repositories WHERE tenant_id = $1 AND owner = $2 AND name = $3 AND id <> $4 FOR UPDATE`,
+			tenantID, newOwner, newName, repoID,
+		)
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("failed to look up %s/%s: %w", newOwner, newName, err)
+		}
+
+		merged = err == nil
+		if merged {
+			for _, stmt := range mergeStatements {
+				if _, err := tx.ExecContext(ctx, stmt, repoID, duplicateID); err != nil {
+					return fmt.Errorf("failed to merge repository %d into %d: %w", duplicateID, repoID, err)
+				}
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE repositories SET owner = $1, name = $2, row_updated_at = CURRENT_TIMESTAMP WHERE id = $3`,
+			newOwner, newName, repoID,
+		); err != nil {
+			return fmt.Errorf("failed to rename repository %d: %w", repoID, err)
+		}
+
+		// Keep stored payloads replayable under the new name
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE raw_payloads SET owner = $1, name = $2, updated_at = CURRENT_TIMESTAMP
+				WHERE tenant_id = $3 AND owner = $4 AND name = $5`,
+			newOwner, newName, tenantID, old.Owner, old.Name,
+		); err != nil {
+			return fmt.Errorf("failed to move payloads of repository %d: %w", repoID, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	safeLogInfo("Repository renamed",
+		zap.String("old_owner", old.Owner),
+		zap.String("old_name", old.Name),
+		zap.String("owner", newOwner),
+		zap.String("name", newName),
+		zap.Bool("merged", merged))
+	return merged, nil
 }
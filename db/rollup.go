@@ -0,0 +1,62 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"githubapifetch/models"
+	"githubapifetch/tenant"
+)
+
+// RollupStats aggregates the commits filter selects across the tracked
+// repositories of the context's tenant owned by owner and in group, either
+// of which may be empty to not restrict on it, along with the top
+// repositories with the most of them
+func (db *DB) RollupStats(ctx context.Context, owner, group string, filter models.StatsFilter, top int) (*models.RollupStats, error) {
+	if top <= 0 {
+		return nil, fmt.Errorf("%w: the number of busiest repositories must be positive", ErrInvalidInput)
+	}
+	if !filter.Since.IsZero() && !filter.Until.IsZero() && !filter.Since.Before(filter.Until) {
+		return nil, fmt.Errorf("%w: the period must end after it starts", ErrInvalidInput)
+	}
+
+	// GitHub owners are case insensitive
+	scope := `r.tenant_id = $1 AND r.status <> $2
+		AND ($3 = '' OR lower(r.owner) = lower($3)) AND ($4 = '' OR r.repo_group = $4)`
+	condition, args := statsFilterCondition(filter, []interface{}{
+		tenant.FromContext(ctx), models.RepoStatusRemoved, owner, group,
+	})
+
+	stats := models.RollupStats{Owner: owner, Group: group, Busiest: []models.RepositoryActivity{}}
+	query := `
+		SELECT COUNT(DISTINCT r.id) AS repositories,
+			COUNT(DISTINCT c.repository_id) AS active_repositories,
+			COUNT(c.sha) AS total_commits,
+			COUNT(DISTINCT c.author_name) AS unique_authors
+		FROM repositories r
+		LEFT JOIN commits c ON c.repository_id = r.id` + condition + `
+		WHERE ` + scope + `
+	`
+	if err := db.conn.GetContext(ctx, &stats, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to compute rollup statistics: %w", err)
+	}
+	if stats.TotalCommits == 0 {
+		return &stats, nil
+	}
+
+	query = fmt.Sprintf(`
+		SELECT r.name AS repo_name, r.owner, COUNT(*) AS commits,
+			COUNT(DISTINCT c.author_name) AS authors,
+			MAX(c.date) AS last_commit_date
+		FROM repositories r
+		JOIN commits c ON c.repository_id = r.id`+condition+`
+		WHERE `+scope+`
+		GROUP BY r.id, r.name, r.owner
+		ORDER BY commits DESC, r.name
+		LIMIT $%d
+	`, len(args)+1)
+	if err := db.conn.SelectContext(ctx, &stats.Busiest, query, append(args, top)...); err != nil {
+		return nil, fmt.Errorf("failed to rank repositories: %w", err)
+	}
+	return &stats, nil
+}
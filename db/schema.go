@@ -0,0 +1,80 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/lib/pq"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// SchemaVersion is the number of the newest migration in db/migrations, the
+// schema this build needs. Dumps record it, and a dump is only restored into
+// a database of the same version.
+const SchemaVersion = 34
+
+// undefinedTable is the Postgres error code for a missing table
+const undefinedTable = "42P01"
+
+// AppliedSchemaVersion returns the version of the newest migration applied
+// to the database, or 0 for a database migrated before versions were
+// recorded
+func (db *DB) AppliedSchemaVersion(ctx context.Context) (int, error) {
+	var version int
+	err := db.conn.GetContext(ctx, &version, `SELECT version FROM schema_meta`)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == undefinedTable {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// CheckSchema fails with ErrSchemaOutdated when the database lacks
+// migrations this build needs. A newer schema is allowed, since migrations
+// only add to it, so an older build keeps running during a rollout.
+func (db *DB) CheckSchema(ctx context.Context) error {
+	applied, err := db.AppliedSchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if applied < SchemaVersion {
+		return fmt.Errorf("%w: the database is at version %d but this build needs version %d; apply the migrations with: %s",
+			ErrSchemaOutdated, applied, SchemaVersion, migrateCommand())
+	}
+	if applied > SchemaVersion {
+		safeLogInfo("Database schema is newer than this build",
+			zap.Int("applied_version", applied),
+			zap.Int("schema_version", SchemaVersion))
+	}
+	return nil
+}
+
+// migrateCommand suggests the migrate invocation bringing the configured
+// database up to date. The password is left for the operator to fill in.
+func migrateCommand() string {
+	if rawURL := viper.GetString("DATABASE_URL"); rawURL != "" {
+		if u, err := url.Parse(rawURL); err == nil {
+			if u.User != nil {
+				u.User = url.UserPassword(u.User.Username(), "PASSWORD")
+			}
+			return fmt.Sprintf(`migrate -path db/migrations -database "%s" up`, u)
+		}
+	}
+	sslMode := viper.GetString("POSTGRES_SSLMODE")
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+	return fmt.Sprintf(`migrate -path db/migrations -database "postgres://%s:PASSWORD@%s:%s/%s?sslmode=%s" up`,
+		viper.GetString("POSTGRES_USER"),
+		viper.GetString("POSTGRES_HOST"),
+		viper.GetString("POSTGRES_PORT"),
+		viper.GetString("POSTGRES_DB"),
+		sslMode,
+	)
+}
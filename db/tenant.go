@@ -0,0 +1,148 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"githubapifetch/models"
+	"githubapifetch/secrets"
+)
+
+// tenantColumns lists the columns selected for tenant queries
+const tenantColumns = `id, name, github_token, repo_owner, poll_interval, api_token, created_at`
+
+// CreateTenant stores a new tenant and returns its ID. The GitHub token is
+// encrypted and the API token hashed before they are written.
+func (db *DB) CreateTenant(ctx context.Context, t models.Tenant) (int, error) {
+	if t.Name == "" {
+		return 0, fmt.Errorf("%w: tenant name cannot be empty", ErrInvalidInput)
+	}
+	if t.PollInterval <= 0 {
+		return 0, fmt.Errorf("%w: tenant poll interval must be positive", ErrInvalidInput)
+	}
+
+	githubToken, err := db.encrypt(t.GitHubToken)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encrypt token for tenant %s: %w", t.Name, err)
+	}
+	var apiTokenHash *string
+	if t.APIToken != nil && *t.APIToken != "" {
+		hash := secrets.HashToken(*t.APIToken)
+		apiTokenHash = &hash
+	}
+
+	safeLogInfo("Creating tenant", zap.String("name", t.Name))
+	query := `
+		INSERT INTO tenants (name, github_token, repo_owner, poll_interval, api_token)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+
+	var id int
+	if err := db.conn.QueryRowxContext(ctx, query,
+		t.Name, githubToken, t.RepoOwner, t.PollInterval, apiTokenHash,
+	).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to create tenant %s: %w", t.Name, err)
+	}
+
+	safeLogInfo("Tenant created successfully", zap.String("name", t.Name), zap.Int("id", id))
+	return id, nil
+}
+
+// ListTenants returns all tenants ordered by ID
+func (db *DB) ListTenants(ctx context.Context) ([]models.Tenant, error) {
+	tenants := []models.Tenant{}
+	query := `SELECT ` + tenantColumns + ` FROM tenants ORDER BY id`
+
+	if err := db.conn.SelectContext(ctx, &tenants, query); err != nil {
+		return nil, fmt.Errorf("failed to list tenants: %w", err)
+	}
+	for i := range tenants {
+		if err := db.decryptTenant(&tenants[i]); err != nil {
+			return nil, err
+		}
+	}
+	return tenants, nil
+}
+
+// GetTenantByName retrieves a tenant by its unique name
+func (db *DB) GetTenantByName(ctx context.Context, name string) (*models.Tenant, error) {
+	if name == "" {
+		return nil, fmt.Errorf("%w: tenant name cannot be empty", ErrInvalidInput)
+	}
+
+	var t models.Tenant
+	query := `SELECT ` + tenantColumns + ` FROM tenants WHERE name = $1`
+
+	if err := db.conn.GetContext(ctx, &t, query, name); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: tenant %s not found", ErrTenantNotFound, name)
+		}
+		return nil, fmt.Errorf("failed to get tenant %s: %w", name, err)
+	}
+	if err := db.decryptTenant(&t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetTenantByAPIToken retrieves the tenant an API token grants access to
+func (db *DB) GetTenantByAPIToken(ctx context.Context, token string) (*models.Tenant, error) {
+	if token == "" {
+		return nil, fmt.Errorf("%w: API token cannot be empty", ErrInvalidInput)
+	}
+
+	var t models.Tenant
+	query := `SELECT ` + tenantColumns + ` FROM tenants WHERE api_token = $1`
+
+	if err := db.conn.GetContext(ctx, &t, query, secrets.HashToken(token)); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: no tenant for API token", ErrTenantNotFound)
+		}
+		return nil, fmt.Errorf("failed to get tenant by API token: %w", err)
+	}
+	if err := db.decryptTenant(&t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// EncryptTenantTokens encrypts GitHub tokens still stored in plaintext and
+// returns how many were updated
+func (db *DB) EncryptTenantTokens(ctx context.Context) (int, error) {
+	if db.encryptor == nil {
+		return 0, ErrEncryptionKeyMissing
+	}
+
+	var tenants []models.Tenant
+	query := `SELECT ` + tenantColumns + ` FROM tenants WHERE github_token <> '' AND github_token NOT LIKE 'enc:%'`
+	if err := db.conn.SelectContext(ctx, &tenants, query); err != nil {
+		return 0, fmt.Errorf("failed to find plaintext tenant tokens: %w", err)
+	}
+
+	for _, t := range tenants {
+		encrypted, err := db.encrypt(t.GitHubToken)
+		if err != nil {
+			return 0, fmt.Errorf("failed to encrypt token for tenant %s: %w", t.Name, err)
+		}
+		if _, err := db.conn.ExecContext(ctx, `UPDATE tenants SET github_token = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`, encrypted, t.ID); err != nil {
+			return 0, fmt.Errorf("failed to update token for tenant %s: %w", t.Name, err)
+		}
+	}
+
+	safeLogInfo("Encrypted tenant tokens", zap.Int("count", len(tenants)))
+	return len(tenants), nil
+}
+
+// decryptTenant decrypts the tenant's GitHub token in place
+func (db *DB) decryptTenant(t *models.Tenant) error {
+	token, err := db.decrypt(t.GitHubToken)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt token for tenant %s: %w", t.Name, err)
+	}
+	t.GitHubToken = token
+	return nil
+}
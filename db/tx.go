@@ -0,0 +1,138 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// maxTxAttempts bounds how often WithTx runs a transaction that keeps
+// failing to serialize
+const maxTxAttempts = 3
+
+// TxRetryDelay is the base delay before retrying a transaction, which the
+// default exponential backoff doubles after every further attempt
+const TxRetryDelay = 50 * time.Millisecond
+
+// Postgres error codes of transactions that may succeed when run again
+const (
+	serializationFailure = "40001"
+	deadlockDetected     = "40P01"
+)
+
+// txKey carries the transaction started by InTx in a context
+type txKey struct{}
+
+// WithTx runs fn in a transaction, committing it when fn returns nil and
+// rolling it back when fn fails or panics; a panic is passed on after the
+// rollback. A transaction aborted by a serialization failure or a deadlock
+// is run again from the start, up to maxTxAttempts times, so fn must have no
+// effects outside tx and must reset anything it captures.
+//
+// Within InTx, fn joins the context's transaction under a savepoint instead:
+// its failure only undoes its own writes, and retrying is left to InTx.
+func (db *DB) WithTx(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	if tx, ok := ctx.Value(txKey{}).(*sqlx.Tx); ok {
+		return savepoint(ctx, tx, fn)
+	}
+
+	var delay time.Duration
+	for attempt := 1; ; attempt++ {
+		err := db.runTx(ctx, fn)
+		if err == nil || !retryableTxError(err) || attempt == maxTxAttempts {
+			return err
+		}
+
+		delay = db.txBackoff.Delay(attempt, delay)
+		safeLogInfo("Retrying transaction",
+			zap.Error(err),
+			zap.Int("attempt", attempt),
+			zap.Duration("backoff", delay))
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// InTx runs fn in one transaction, like WithTx. Every DB method called with
+// the context passed to fn runs in that transaction, so writes spanning
+// several methods commit or roll back together.
+func (db *DB) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+// ext returns the transaction of ctx, if InTx started one, or the
+// connection pool
+func (db *DB) ext(ctx context.Context) sqlx.ExtContext {
+	if tx, ok := ctx.Value(txKey{}).(*sqlx.Tx); ok {
+		return tx
+	}
+	return db.conn
+}
+
+// savepoint runs fn within tx under a savepoint, rolling back to it when fn
+// fails or panics
+func savepoint(ctx context.Context, tx *sqlx.Tx, fn func(tx *sqlx.Tx) error) error {
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT nested_tx"); err != nil {
+		return fmt.Errorf("%w: failed to create savepoint: %w", ErrTransactionFailed, err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT nested_tx")
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		if _, rbErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT nested_tx"); rbErr != nil {
+			return fmt.Errorf("%w: failed to roll back to savepoint: %v (after %w)", ErrTransactionFailed, rbErr, err)
+		}
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT nested_tx"); err != nil {
+		return fmt.Errorf("%w: failed to release savepoint: %w", ErrTransactionFailed, err)
+	}
+	return nil
+}
+
+// runTx runs fn in one transaction
+func (db *DB) runTx(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	tx, err := db.conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrTransactionFailed, err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w: failed to commit transaction: %w", ErrTransactionFailed, err)
+	}
+	return nil
+}
+
+// retryableTxError reports whether err aborted a transaction that may
+// succeed when run again
+func retryableTxError(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == serializationFailure || pqErr.Code == deadlockDetected
+}
@@ -0,0 +1,36 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"githubapifetch/models"
+	"githubapifetch/tenant"
+)
+
+// RecordDeadLetter stores a webhook delivery that failed every attempt
+func (db *DB) RecordDeadLetter(ctx context.Context, dl models.WebhookDeadLetter) error {
+	if dl.URL == "" || dl.EventID == "" || dl.EventType == "" {
+		return fmt.Errorf("%w: dead letter requires URL, event ID and event type", ErrInvalidInput)
+	}
+
+	tenantID := dl.TenantID
+	if tenantID == 0 {
+		tenantID = tenant.FromContext(ctx)
+	}
+	payload := dl.Payload
+	if len(payload) == 0 {
+		payload = []byte("{}")
+	}
+
+	query := `
+		INSERT INTO webhook_dead_letters (tenant_id, url, event_id, event_type, payload, attempts, last_error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	if _, err := db.conn.ExecContext(ctx, query,
+		tenantID, dl.URL, dl.EventID, dl.EventType, string(payload), dl.Attempts, dl.LastError,
+	); err != nil {
+		return fmt.Errorf("failed to record dead letter for event %s: %w", dl.EventID, err)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+// Package features gates the optional ingestion subsystems. Each feature is
+// on or off for every repository by default, and overrides turn it on or off
+// for single repositories, so a subsystem can be rolled out gradually.
+package features
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Features, named as they are in overrides and reports
+const (
+	Issues       = "issues"
+	PullRequests = "pull_requests"
+	Releases     = "releases"
+	Deployments  = "deployments"
+)
+
+// Names lists every feature, in the order they are reported
+var Names = []string{Issues, PullRequests, Releases, Deployments}
+
+// Override turns a feature on or off for one repository
+type Override struct {
+	// Repo is the repository, as owner/name
+	Repo    string
+	Feature string
+	Enabled bool
+}
+
+// ParseOverrides parses comma-separated owner/name:feature=on|off entries,
+// e.g. "octo/hello:issues=off,octo/big:pull_requests=on"
+func ParseOverrides(raw string) ([]Override, error) {
+	var overrides []Override
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		repo, setting, ok := strings.Cut(entry, ":")
+		owner, name, repoOK := strings.Cut(repo, "/")
+		if !ok || !repoOK || owner == "" || name == "" {
+			return nil, fmt.Errorf("feature override %q: want owner/name:feature=on|off", entry)
+		}
+		feature, value, ok := strings.Cut(setting, "=")
+		if !ok || !known(feature) {
+			return nil, fmt.Errorf("feature override %q: unknown feature %q, want one of %s", entry, feature, strings.Join(Names, ", "))
+		}
+		var enabled bool
+		switch strings.ToLower(value) {
+		case "on", "true":
+			enabled = true
+		case "off", "false":
+		default:
+			return nil, fmt.Errorf("feature override %q: want on or off, got %q", entry, value)
+		}
+		overrides = append(overrides, Override{Repo: repo, Feature: feature, Enabled: enabled})
+	}
+	return overrides, nil
+}
+
+func known(feature string) bool {
+	for _, name := range Names {
+		if name == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// Flags says which features are enabled for which repository. Flags are not
+// changed once built, so they can be shared; With returns a modified copy.
+type Flags struct {
+	defaults map[string]bool
+	// overrides are keyed by lowercase "owner/name", then feature
+	overrides map[string]map[string]bool
+}
+
+// New returns flags enabling each feature by default as defaults says, except
+// for the repositories overrides name; later overrides win
+func New(defaults map[string]bool, overrides []Override) *Flags {
+	f := &Flags{defaults: map[string]bool{}, overrides: map[string]map[string]bool{}}
+	for feature, enabled := range defaults {
+		f.defaults[feature] = enabled
+	}
+	for _, o := range overrides {
+		key := strings.ToLower(o.Repo)
+		if f.overrides[key] == nil {
+			f.overrides[key] = map[string]bool{}
+		}
+		f.overrides[key][o.Feature] = o.Enabled
+	}
+	return f
+}
+
+// With returns a copy of f with the default of feature set to enabled; the
+// overrides stay. A nil f has every feature off.
+func (f *Flags) With(feature string, enabled bool) *Flags {
+	if f == nil {
+		return New(map[string]bool{feature: enabled}, nil)
+	}
+	g := New(f.defaults, nil)
+	g.overrides = f.overrides
+	g.defaults[feature] = enabled
+	return g
+}
+
+// Enabled reports whether feature is enabled for the repository owner/name.
+// A nil f has every feature off.
+func (f *Flags) Enabled(feature, owner, name string) bool {
+	if f == nil {
+		return false
+	}
+	if enabled, ok := f.overrides[strings.ToLower(owner+"/"+name)][feature]; ok {
+		return enabled
+	}
+	return f.defaults[feature]
+}
+
+// Status is whether a feature is enabled by default, and the repositories
+// overriding that
+type Status struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	// EnabledFor and DisabledFor list the overridden repositories, sorted
+	EnabledFor  []string `json:"enabled_for,omitempty"`
+	DisabledFor []string `json:"disabled_for,omitempty"`
+}
+
+// Snapshot returns the status of every feature, in the order of Names
+func (f *Flags) Snapshot() []Status {
+	out := make([]Status, 0, len(Names))
+	for _, name := range Names {
+		s := Status{Name: name}
+		if f != nil {
+			s.Enabled = f.defaults[name]
+			for repo, features := range f.overrides {
+				enabled, ok := features[name]
+				switch {
+				case !ok:
+				case enabled:
+					s.EnabledFor = append(s.EnabledFor, repo)
+				default:
+					s.DisabledFor = append(s.DisabledFor, repo)
+				}
+			}
+			sort.Strings(s.EnabledFor)
+			sort.Strings(s.DisabledFor)
+		}
+		out = append(out, s)
+	}
+	return out
+}
@@ -0,0 +1,51 @@
+package features
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOverrides(t *testing.T) {
+	overrides, err := ParseOverrides(" octo/hello:issues=off,octo/big:pull_requests=ON,, ")
+	require.NoError(t, err)
+	assert.Equal(t, []Override{
+		{Repo: "octo/hello", Feature: Issues, Enabled: false},
+		{Repo: "octo/big", Feature: PullRequests, Enabled: true},
+	}, overrides)
+
+	for _, raw := range []string{"hello:issues=on", "octo/hello:traffic=on", "octo/hello:issues", "octo/hello:issues=maybe", "octo/:issues=on"} {
+		_, err := ParseOverrides(raw)
+		assert.Error(t, err, raw)
+	}
+}
+
+func TestFlags(t *testing.T) {
+	f := New(map[string]bool{Issues: true}, []Override{
+		{Repo: "Octo/Hello", Feature: Issues, Enabled: false},
+		{Repo: "octo/big", Feature: Releases, Enabled: true},
+	})
+	assert.True(t, f.Enabled(Issues, "octo", "other"))
+	assert.False(t, f.Enabled(Issues, "octo", "hello"), "repositories match case-insensitively")
+	assert.True(t, f.Enabled(Releases, "octo", "big"))
+	assert.False(t, f.Enabled(Releases, "octo", "other"))
+
+	// With leaves f as it is
+	g := f.With(Releases, true)
+	assert.True(t, g.Enabled(Releases, "octo", "other"))
+	assert.False(t, g.Enabled(Issues, "octo", "hello"))
+	assert.False(t, f.Enabled(Releases, "octo", "other"))
+
+	var none *Flags
+	assert.False(t, none.Enabled(Issues, "octo", "hello"))
+	assert.True(t, none.With(Issues, true).Enabled(Issues, "octo", "hello"))
+	assert.Len(t, none.Snapshot(), len(Names))
+
+	assert.Equal(t, []Status{
+		{Name: Issues, Enabled: true, DisabledFor: []string{"octo/hello"}},
+		{Name: PullRequests},
+		{Name: Releases, EnabledFor: []string{"octo/big"}},
+		{Name: Deployments},
+	}, f.Snapshot())
+}
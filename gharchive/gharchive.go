@@ -0,0 +1,220 @@
+// Package gharchive reads the hourly event dumps published by GH Archive
+// (https://www.gharchive.org), and the BigQuery exports of its tables, so
+// the history of a repository can be loaded without going through the
+// GitHub API.
+//
+// A dump holds one JSON event per line, gzip-compressed or not. Only push
+// events are read: they are the only events that carry commits. BigQuery
+// exports, which hold the payload as a JSON string and format timestamps
+// their own way, are read the same. Dumps in the timeline format GH Archive
+// used before 2015 are not supported.
+package gharchive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pushEventType is the type of the events carrying pushed commits
+const pushEventType = "PushEvent"
+
+// PushEvent is a push to a repository
+type PushEvent struct {
+	RepoID int64
+	// RepoName is the repository's owner/name at the time of the push
+	RepoName string
+	// Ref is the pushed ref, such as refs/heads/main
+	Ref string
+	// CreatedAt is when the push happened
+	CreatedAt time.Time
+	Commits   []Commit
+}
+
+// Owner returns the owner part of RepoName
+func (e PushEvent) Owner() string {
+	owner, _, _ := strings.Cut(e.RepoName, "/")
+	return owner
+}
+
+// Name returns the name part of RepoName
+func (e PushEvent) Name() string {
+	_, name, _ := strings.Cut(e.RepoName, "/")
+	return name
+}
+
+// Branch returns the pushed branch, or "" when a tag was pushed
+func (e PushEvent) Branch() string {
+	branch, ok := strings.CutPrefix(e.Ref, "refs/heads/")
+	if !ok {
+		return ""
+	}
+	return branch
+}
+
+// Commit is a commit of a push. Pushes record neither the commit's date nor
+// its committer.
+type Commit struct {
+	SHA         string
+	AuthorName  string
+	AuthorEmail string
+	Message     string
+	// URL is the commit's REST API URL
+	URL string
+	// Distinct is false when the commit was already pushed to another
+	// branch of the repository
+	Distinct bool
+}
+
+type event struct {
+	Type string `json:"type"`
+	Repo struct {
+		ID   flexInt `json:"id"`
+		Name string  `json:"name"`
+	} `json:"repo"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt flexTime        `json:"created_at"`
+}
+
+// flexInt is an integer that BigQuery exports may quote
+type flexInt int64
+
+func (n *flexInt) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	v, err := strconv.ParseInt(string(bytes.Trim(data, `"`)), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid integer %s", data)
+	}
+	*n = flexInt(v)
+	return nil
+}
+
+// bigQueryTimeLayout is how BigQuery exports timestamps, such as
+// 2015-01-01 15:00:00 UTC; fractional seconds are accepted too
+const bigQueryTimeLayout = "2006-01-02 15:04:05 MST"
+
+// flexTime is a timestamp in RFC 3339, as in the dumps, or in BigQuery's
+// layout
+type flexTime time.Time
+
+func (t *flexTime) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if raw == "" {
+		return nil
+	}
+	parsed, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		if parsed, err = time.Parse(bigQueryTimeLayout, raw); err != nil {
+			return fmt.Errorf("invalid timestamp %q", raw)
+		}
+	}
+	*t = flexTime(parsed.UTC())
+	return nil
+}
+
+type pushPayload struct {
+	Ref     string `json:"ref"`
+	Commits []struct {
+		SHA    string `json:"sha"`
+		Author struct {
+			Name  string `json:"name"`
+			Email string `json:"email"`
+		} `json:"author"`
+		Message  string `json:"message"`
+		URL      string `json:"url"`
+		Distinct bool   `json:"distinct"`
+	} `json:"commits"`
+}
+
+// Reader reads the push events of a dump
+type Reader struct {
+	dec *json.Decoder
+	// events is the number of events read, of any type
+	events int
+}
+
+// NewReader returns a Reader for a dump, which is decompressed when it
+// starts with the gzip header
+func NewReader(r io.Reader) (*Reader, error) {
+	buffered := bufio.NewReader(r)
+	magic, err := buffered.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	var src io.Reader = buffered
+	if bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress archive: %w", err)
+		}
+		src = gz
+	}
+	return &Reader{dec: json.NewDecoder(src)}, nil
+}
+
+// Next returns the next push event, or io.EOF after the last one. Events of
+// other types are skipped.
+func (r *Reader) Next() (PushEvent, error) {
+	for {
+		var e event
+		if err := r.dec.Decode(&e); err != nil {
+			if errors.Is(err, io.EOF) {
+				return PushEvent{}, io.EOF
+			}
+			return PushEvent{}, fmt.Errorf("failed to decode event %d: %w", r.events+1, err)
+		}
+		r.events++
+		if e.Type != pushEventType {
+			continue
+		}
+
+		raw := []byte(e.Payload)
+		// BigQuery holds the payload as a string of JSON
+		if len(raw) > 0 && raw[0] == '"' {
+			var quoted string
+			if err := json.Unmarshal(raw, &quoted); err != nil {
+				return PushEvent{}, fmt.Errorf("failed to decode payload of event %d: %w", r.events, err)
+			}
+			raw = []byte(quoted)
+		}
+		var payload pushPayload
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			return PushEvent{}, fmt.Errorf("failed to decode payload of event %d: %w", r.events, err)
+		}
+		push := PushEvent{
+			RepoID:    int64(e.Repo.ID),
+			RepoName:  e.Repo.Name,
+			Ref:       payload.Ref,
+			CreatedAt: time.Time(e.CreatedAt),
+			Commits:   make([]Commit, len(payload.Commits)),
+		}
+		for i, c := range payload.Commits {
+			push.Commits[i] = Commit{
+				SHA:         c.SHA,
+				AuthorName:  c.Author.Name,
+				AuthorEmail: c.Author.Email,
+				Message:     c.Message,
+				URL:         c.URL,
+				Distinct:    c.Distinct,
+			}
+		}
+		return push, nil
+	}
+}
+
+// Events returns the number of events read so far, of any type
+func (r *Reader) Events() int {
+	return r.events
+}
@@ -0,0 +1,81 @@
+package gharchive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const dump = `{"id":"1","type":"WatchEvent","repo":{"id":7,"name":"octo/repo"},"payload":{"action":"started"},"created_at":"2024-01-01T10:00:00Z"}
+{"id":"2","type":"PushEvent","repo":{"id":7,"name":"octo/repo"},"payload":{"ref":"refs/heads/main","commits":[{"sha":"abc","author":{"name":"Octo Cat","email":"octo@example.com"},"message":"Fix it","url":"https://api.github.com/repos/octo/repo/commits/abc","distinct":true}]},"created_at":"2024-01-01T10:05:00Z"}
+{"id":"3","type":"PushEvent","repo":{"id":"8","name":"octo/other"},"payload":"{\"ref\":\"refs/tags/v1\",\"commits\":[]}","created_at":"2024-01-01 10:10:00.000000 UTC"}
+`
+
+func readAll(t *testing.T, r io.Reader) ([]PushEvent, int) {
+	t.Helper()
+	reader, err := NewReader(r)
+	require.NoError(t, err)
+	var events []PushEvent
+	for {
+		e, err := reader.Next()
+		if err == io.EOF {
+			return events, reader.Events()
+		}
+		require.NoError(t, err)
+		events = append(events, e)
+	}
+}
+
+func TestReader(t *testing.T) {
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	_, err := w.Write([]byte(dump))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	for name, r := range map[string]io.Reader{
+		"plain":   strings.NewReader(dump),
+		"gzipped": &gz,
+	} {
+		t.Run(name, func(t *testing.T) {
+			events, read := readAll(t, r)
+			assert.Equal(t, 3, read)
+			require.Len(t, events, 2)
+
+			push := events[0]
+			assert.Equal(t, int64(7), push.RepoID)
+			assert.Equal(t, "octo", push.Owner())
+			assert.Equal(t, "repo", push.Name())
+			assert.Equal(t, "main", push.Branch())
+			assert.Equal(t, time.Date(2024, 1, 1, 10, 5, 0, 0, time.UTC), push.CreatedAt)
+			assert.Equal(t, []Commit{{
+				SHA:         "abc",
+				AuthorName:  "Octo Cat",
+				AuthorEmail: "octo@example.com",
+				Message:     "Fix it",
+				URL:         "https://api.github.com/repos/octo/repo/commits/abc",
+				Distinct:    true,
+			}}, push.Commits)
+
+			// Exported from BigQuery
+			tag := events[1]
+			assert.Equal(t, int64(8), tag.RepoID)
+			assert.Equal(t, "", tag.Branch())
+			assert.Equal(t, time.Date(2024, 1, 1, 10, 10, 0, 0, time.UTC), tag.CreatedAt)
+			assert.Empty(t, tag.Commits)
+		})
+	}
+}
+
+func TestReaderMalformed(t *testing.T) {
+	reader, err := NewReader(strings.NewReader(`{"type":"PushEvent","payload":{"ref":1}}`))
+	require.NoError(t, err)
+	_, err = reader.Next()
+	assert.ErrorContains(t, err, "event 1")
+}
@@ -0,0 +1,165 @@
+package github
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// rateLimitResponse is the part of the rate limit endpoint response
+// describing the core REST API limit
+type rateLimitResponse struct {
+	Resources struct {
+		Core struct {
+			Limit     int   `json:"limit"`
+			Remaining int   `json:"remaining"`
+			Reset     int64 `json:"reset"`
+		} `json:"core"`
+	} `json:"resources"`
+}
+
+// RateLimit returns the core rate limit of the client's token. The endpoint
+// does not count against the limit, so it doubles as a cheap check that the
+// token is accepted at all.
+func (c *Client) RateLimit(ctx context.Context) (*RateLimit, error) {
+	reqURL := c.baseURL.ResolveReference(&url.URL{Path: "/rate_limit"})
+	body, _, err := c.get(ctx, reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check rate limit: %w", err)
+	}
+	defer releaseBuffer(body)
+
+	var resp rateLimitResponse
+	if err := json.Unmarshal(body.Bytes(), &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode rate limit response: %w", err)
+	}
+	core := resp.Resources.Core
+	return &RateLimit{Limit: core.Limit, Remaining: core.Remaining, Reset: time.Unix(core.Reset, 0)}, nil
+}
+
+// CheckRepoAccess verifies that the client's token can read the commits of a
+// repository, by listing a single one. It fails with ErrNotFound when the
+// repository does not exist or is not visible to the token, and with
+// ErrInsufficientPermissions when the token may see but not read it.
+func (c *Client) CheckRepoAccess(ctx context.Context, owner, name string) error {
+	reqURL := c.baseURL.ResolveReference(&url.URL{Path: fmt.Sprintf("/repos/%s/%s/commits", owner, name)})
+	reqURL.RawQuery = url.Values{"per_page": {"1"}}.Encode()
+	body, _, err := c.get(ctx, reqURL, nil)
+	// Empty repositories answer 409 Conflict, which still proves access
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot read repository %s/%s: %w", owner, name, err)
+	}
+	releaseBuffer(body)
+	return nil
+}
+
+// CountCommits returns how many commits a repository's default branch has
+// since the given time, or in all if since is zero, for one request: it
+// lists a single commit per page and reads the number of pages from the
+// Link header.
+func (c *Client) CountCommits(ctx context.Context, owner, name string, since time.Time) (int, error) {
+	reqURL := c.baseURL.ResolveReference(&url.URL{Path: fmt.Sprintf("/repos/%s/%s/commits", owner, name)})
+	q := url.Values{"per_page": {"1"}}
+	if !since.IsZero() {
+		q.Set("since", since.Format(time.RFC3339))
+	}
+	reqURL.RawQuery = q.Encode()
+	body, resp, err := c.get(ctx, reqURL, nil)
+	// Empty repositories answer 409 Conflict
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to count commits of %s/%s: %w", owner, name, err)
+	}
+	defer releaseBuffer(body)
+
+	if last := lastPage(resp.Header.Get("Link")); last > 0 {
+		return last, nil
+	}
+	commits, err := DecodeCommits(body.Bytes())
+	if err != nil {
+		return 0, err
+	}
+	return len(commits), nil
+}
+
+// FetchCommit returns a single commit of a repository. It fails with
+// ErrNotFound when the repository or the commit does not exist, for
+// instance after a force push dropped the commit and it was garbage
+// collected.
+func (c *Client) FetchCommit(ctx context.Context, owner, name, sha string) (*CommitResponse, error) {
+	reqURL := c.baseURL.ResolveReference(&url.URL{Path: fmt.Sprintf("/repos/%s/%s/commits/%s", owner, name, sha)})
+	body, _, err := c.get(ctx, reqURL, nil)
+	// Unknown SHAs answer 422 Unprocessable Entity
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnprocessableEntity {
+		return nil, fmt.Errorf("failed to fetch commit %s of %s/%s: %w: %w", sha, owner, name, ErrNotFound, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch commit %s of %s/%s: %w", sha, owner, name, err)
+	}
+	defer releaseBuffer(body)
+
+	var commit CommitResponse
+	if err := json.Unmarshal(body.Bytes(), &commit); err != nil {
+		return nil, fmt.Errorf("failed to decode commit response: %w", err)
+	}
+	return &commit, nil
+}
+
+// CodeownersPaths are the locations GitHub looks for a CODEOWNERS file in,
+// in the order it does
+var CodeownersPaths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// contentsResponse is the part of the contents endpoint response describing
+// a file
+type contentsResponse struct {
+	Type     string `json:"type"`
+	Encoding string `json:"encoding"`
+	Content  string `json:"content"`
+}
+
+// FetchCodeowners returns the CODEOWNERS file of a repository's default
+// branch and the path it was found at. It fails with ErrNotFound when the
+// repository has none.
+func (c *Client) FetchCodeowners(ctx context.Context, owner, name string) (string, []byte, error) {
+	for _, path := range CodeownersPaths {
+		reqURL := c.baseURL.ResolveReference(&url.URL{Path: fmt.Sprintf("/repos/%s/%s/contents/%s", owner, name, path)})
+		body, _, err := c.get(ctx, reqURL, nil)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to fetch %s of %s/%s: %w", path, owner, name, err)
+		}
+
+		var file contentsResponse
+		err = json.Unmarshal(body.Bytes(), &file)
+		releaseBuffer(body)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to decode contents response: %w", err)
+		}
+		if file.Type != "file" || file.Encoding != "base64" {
+			return "", nil, fmt.Errorf("%s of %s/%s is not a file", path, owner, name)
+		}
+		// GitHub wraps the encoded content every 60 characters
+		content, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(file.Content, "\n", ""))
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to decode %s of %s/%s: %w", path, owner, name, err)
+		}
+		return path, content, nil
+	}
+	return "", nil, fmt.Errorf("no CODEOWNERS file in %s/%s: %w", owner, name, ErrNotFound)
+}
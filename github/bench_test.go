@@ -0,0 +1,66 @@
+package github
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+// commitPageBody returns a full page of commits as GitHub serves it
+func commitPageBody(b *testing.B) []byte {
+	b.Helper()
+	page := make([]CommitResponse, 100)
+	for i := range page {
+		page[i].SHA = fmt.Sprintf("%040d", i)
+		page[i].Commit.Message = fmt.Sprintf("Commit %d\n\nWith a body long enough to matter", i)
+		page[i].Commit.Author.Name = "Octo Cat"
+		page[i].Commit.Author.Email = "octocat@example.com"
+		page[i].Commit.Author.Date = time.Date(2024, 1, 1, 0, 0, i, 0, time.UTC)
+		page[i].HTMLURL = "https://github.com/octo/hello/commit/" + page[i].SHA
+	}
+	body, err := json.Marshal(page)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return body
+}
+
+// BenchmarkReadCommitPages compares reading and decoding commit pages into
+// fresh buffers and slices with the pooled path FetchCommits uses
+func BenchmarkReadCommitPages(b *testing.B) {
+	body := commitPageBody(b)
+
+	b.Run("fresh", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(body)))
+		for i := 0; i < b.N; i++ {
+			raw, err := io.ReadAll(bytes.NewReader(body))
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := DecodeCommits(raw); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(body)))
+		var commits []CommitResponse
+		for i := 0; i < b.N; i++ {
+			buf, err := readPooled(bytes.NewReader(body))
+			if err != nil {
+				b.Fatal(err)
+			}
+			commits, err = decodeCommitsInto(commits, buf.Bytes())
+			releaseBuffer(buf)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
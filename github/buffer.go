@@ -0,0 +1,36 @@
+package github
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// maxPooledBufferSize caps the buffers kept for reuse so a single huge
+// response does not pin its memory for the life of the process
+const maxPooledBufferSize = 8 << 20
+
+// bufferPool holds response body buffers shared by all clients
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// readPooled reads r into a buffer from the pool. The buffer must be handed
+// back with releaseBuffer once its bytes are no longer referenced.
+func readPooled(r io.Reader) (*bytes.Buffer, error) {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	if _, err := buf.ReadFrom(r); err != nil {
+		releaseBuffer(buf)
+		return nil, err
+	}
+	return buf, nil
+}
+
+// releaseBuffer returns a buffer to the pool
+func releaseBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	buf.Reset()
+	bufferPool.Put(buf)
+}
@@ -1,20 +1,107 @@
 package github
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"expvar"
 	"fmt"
+	"githubapifetch/backoff"
+	"githubapifetch/clock"
 	"githubapifetch/logger"
+	"githubapifetch/metrics"
+	"githubapifetch/models"
+	"io"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
 )
 
+// DefaultAPIVersion is the REST API version requested unless configured otherwise
+const DefaultAPIVersion = "2022-11-28"
+
+// maxRateLimitRetries is how many times a rate limited request is retried
+// after waiting for the limit to reset
+const maxRateLimitRetries = 3
+
+// maxUnavailableRetries is how many times a request is retried while GitHub
+// answers 502, 503 or 504, as it does for a while during incidents
+const maxUnavailableRetries = 3
+
+// unavailableBackoff spaces out retries of a request GitHub was unavailable
+// for when neither GitHub nor WithBackoff says how long to wait
+var unavailableBackoff backoff.Backoff = backoff.Exponential{Base: time.Second, Max: time.Minute}
+
+// Client errors
+var (
+	// ErrRateLimited is returned when a request is still rate limited after retrying
+	ErrRateLimited = errors.New("github rate limit exceeded")
+	// ErrNotFound is returned when a repository does not exist or is not accessible
+	ErrNotFound = errors.New("github repository not found")
+	// ErrInsufficientPermissions is returned when the token is valid but may
+	// not read what was requested, typically a fine-grained token missing a
+	// repository permission
+	ErrInsufficientPermissions = errors.New("github token lacks required permissions")
+	// ErrIncompleteListing is returned when a paginated listing ends before
+	// the last page its Link header announced
+	ErrIncompleteListing = errors.New("github listing ended early")
+	// ErrUnavailable is returned when GitHub still answers 502, 503 or 504
+	// after retrying; the failure is transient and worth trying again later
+	ErrUnavailable = errors.New("github temporarily unavailable")
+)
+
+// APIError is returned when GitHub answers a request with an unexpected
+// status code. It matches ErrNotFound for 404 responses,
+// ErrInsufficientPermissions for 403 responses refusing the token access and
+// ErrUnavailable for 502, 503 and 504 responses.
+type APIError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	// Message is GitHub's explanation from the response body, if any
+	Message string
+	// AcceptedPermissions lists the permissions that would have allowed the
+	// request, from X-Accepted-GitHub-Permissions, e.g. "contents=read"
+	AcceptedPermissions string
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%s %s: status code %d", e.Method, e.Path, e.StatusCode)
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.AcceptedPermissions != "" {
+		msg += " (the token needs " + e.AcceptedPermissions + ")"
+	}
+	return msg
+}
+
+// Is matches ErrNotFound, ErrInsufficientPermissions and ErrUnavailable
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrUnavailable:
+		return unavailableStatus(e.StatusCode)
+	case ErrInsufficientPermissions:
+		// Rate limits are 403s too, but never reach an APIError
+		return e.StatusCode == http.StatusForbidden &&
+			(e.AcceptedPermissions != "" || strings.HasPrefix(e.Message, "Resource not accessible"))
+	}
+	return false
+}
+
 // RateLimit represents GitHub's rate limit information
 type RateLimit struct {
+	// Resource is the limit the request counted against, e.g. "core" or
+	// "search"; empty when GitHub did not say
+	Resource  string
 	Limit     int
 	Remaining int
 	Reset     time.Time
@@ -22,12 +109,166 @@ type RateLimit struct {
 
 // Client represents a GitHub API client
 type Client struct {
+	// token authenticates requests; SetToken replaces it while requests run
+	tokenMu    sync.RWMutex
 	token      string
 	httpClient *http.Client
+	transport  http.RoundTripper
+	middleware []Middleware
 	baseURL    *url.URL
+	clock      clock.Clock
+	sink       PayloadSink
+	progress   ProgressFunc
+	rateLimits RateLimitFunc
+	apiVersion string
+	backoff    backoff.Backoff
+
+	// policies override the timeout and retries of single endpoints, whose
+	// requests are sent with endpointClients
+	policies        map[string]EndpointPolicy
+	endpointClients map[string]*http.Client
+
+	deprecationWarning sync.Once
+}
+
+// Payload is the raw body of a successful API response
+type Payload struct {
+	Kind  string // models.PayloadKindRepo or models.PayloadKindCommits
+	Owner string
+	Name  string
+	Page  int // Page number of commit listings
+	Body  []byte
+}
+
+// PayloadSink receives raw response bodies before they are decoded
+type PayloadSink func(ctx context.Context, p Payload) error
+
+// Progress describes how far a commit listing has been fetched
+type Progress struct {
+	Owner    string
+	Name     string
+	Page     int   // Pages fetched so far
+	LastPage int   // Last page announced by the Link header; 0 while unknown
+	Commits  int   // Commits fetched so far
+	Done     bool  // The listing was fetched completely or failed
+	Err      error // Why the listing failed, if it did
+}
+
+// ProgressFunc is called after every commit page and once when the listing ends
+type ProgressFunc func(ctx context.Context, p Progress)
+
+// RateLimitFunc receives the rate limit a response reported and when it
+// was observed
+type RateLimitFunc func(ctx context.Context, rl RateLimit, observed time.Time)
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithBaseURL points the client at a different API endpoint, such as GitHub
+// Enterprise or a githubtest server
+func WithBaseURL(rawURL string) Option {
+	return func(c *Client) {
+		baseURL, err := url.Parse(rawURL)
+		if err != nil {
+			logger.Error("Invalid GitHub base URL, using default", zap.Error(err), zap.String("base_url", rawURL))
+			return
+		}
+		c.baseURL = baseURL
+	}
+}
+
+// WithPayloadSink passes every successful response body to sink, e.g. to
+// persist it for replay. Sink errors are logged and do not fail the request.
+func WithPayloadSink(sink PayloadSink) Option {
+	return func(c *Client) {
+		c.sink = sink
+	}
+}
+
+// WithProgress reports the progress of commit listings to fn
+func WithProgress(fn ProgressFunc) Option {
+	return func(c *Client) {
+		c.progress = fn
+	}
+}
+
+// WithRateLimitObserver passes the rate limit of every response carrying
+// one to fn, e.g. to record its history
+func WithRateLimitObserver(fn RateLimitFunc) Option {
+	return func(c *Client) {
+		c.rateLimits = fn
+	}
+}
+
+// WithAPIVersion pins the REST API version sent in the X-GitHub-Api-Version
+// header, so GitHub behavior changes only when the version is changed
+func WithAPIVersion(version string) Option {
+	return func(c *Client) {
+		if version != "" {
+			c.apiVersion = version
+		}
+	}
+}
+
+// WithClock sets the clock rate limit waits are measured and slept on
+func WithClock(c clock.Clock) Option {
+	return func(client *Client) {
+		client.clock = c
+	}
+}
+
+// WithBackoff sets a least wait before retrying a rate limited request, or
+// one GitHub was unavailable for. The client still waits as long as GitHub
+// asks, but never less than b gives, so retries that GitHub would allow right
+// away are spread out too.
+func WithBackoff(b backoff.Backoff) Option {
+	return func(c *Client) {
+		c.backoff = b
+	}
+}
+
+// WithHTTPClient replaces the HTTP client used for requests
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// Middleware wraps the transport requests are sent through, e.g. to cache,
+// record, throttle or trace them
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc adapts a function to an http.RoundTripper
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip calls f(req)
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// WithTransport sends requests through rt instead of the HTTP client's own
+// transport
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *Client) {
+		c.transport = rt
+	}
+}
+
+// WithMiddleware wraps the transport in mw. The first middleware is the
+// outermost: it sees each request first and each response last. Every
+// attempt is sent through the middleware, including retries after a rate
+// limit, with the authentication and API version headers already set.
+func WithMiddleware(mw ...Middleware) Option {
+	return func(c *Client) {
+		c.middleware = append(c.middleware, mw...)
+	}
 }
 
 type RepoResponse struct {
+	ID              int64     `json:"id"`
+	NodeID          string    `json:"node_id"`
+	Name            string    `json:"name"`
+	Owner           RepoOwner `json:"owner"`
 	Description     string    `json:"description"`
 	HTMLURL         string    `json:"html_url"`
 	Language        string    `json:"language"`
@@ -37,31 +278,106 @@ type RepoResponse struct {
 	WatchersCount   int       `json:"watchers_count"`
 	CreatedAt       time.Time `json:"created_at"`
 	UpdatedAt       time.Time `json:"updated_at"`
+	PushedAt        time.Time `json:"pushed_at"` // Last push to any branch
+	DefaultBranch   string    `json:"default_branch"`
+}
+
+// RepoOwner is the account owning a repository
+type RepoOwner struct {
+	Login string `json:"login"`
 }
 
+// CommitResponse is one commit of the commits endpoint response
 type CommitResponse struct {
-	SHA    string `json:"sha"`
-	Commit struct {
-		Message string `json:"message"`
-		Author  struct {
-			Name  string    `json:"name"`
-			Email string    `json:"email"`
-			Date  time.Time `json:"date"`
-		} `json:"author"`
-	} `json:"commit"`
-	HTMLURL string `json:"html_url"`
+	SHA     string         `json:"sha"`
+	Commit  CommitDetail   `json:"commit"`
+	URL     string         `json:"url"` // REST API URL of the commit
+	HTMLURL string         `json:"html_url"`
+	Parents []CommitParent `json:"parents"`
 }
 
-func NewClient(token string) *Client {
+// CommitDetail is the git commit behind a GitHub commit: its message,
+// author and committer
+type CommitDetail struct {
+	Message string       `json:"message"`
+	Author  CommitAuthor `json:"author"`
+	// The committer date changes when a commit is rebased or amended;
+	// the author date does not
+	Committer CommitAuthor `json:"committer"`
+}
+
+// CommitAuthor is an author or committer as recorded in git, which need not
+// be a GitHub user
+type CommitAuthor struct {
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+	Date  time.Time `json:"date"`
+}
+
+// CommitParent references a parent of a commit
+type CommitParent struct {
+	SHA string `json:"sha"`
+}
+
+func NewClient(token string, opts ...Option) *Client {
 	baseURL, _ := url.Parse("https://api.github.com")
-	logger.Info("Initializing GitHub client", zap.String("base_url", baseURL.String()))
-	return &Client{
+	c := &Client{
 		token: token,
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
-		baseURL: baseURL,
+		baseURL:    baseURL,
+		clock:      clock.Real,
+		apiVersion: DefaultAPIVersion,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.transport != nil || len(c.middleware) > 0 {
+		c.httpClient = c.wrapTransport()
 	}
+	c.endpointClients = endpointClients(c.httpClient, c.policies)
+	logger.Info("Initializing GitHub client",
+		zap.String("base_url", c.baseURL.String()),
+		zap.String("api_version", c.apiVersion))
+	return c
+}
+
+// SetToken replaces the token requests are authenticated with, for rotating
+// it without a restart. The new token is first tried on GET /rate_limit,
+// which does not count against the limit; when GitHub does not accept it,
+// or cannot be asked, it is refused and the old one kept. Requests already
+// sent finish on the old token; every request sent afterwards, retries
+// included, uses the new one.
+func (c *Client) SetToken(ctx context.Context, token string) error {
+	if _, err := TokenType(token); err != nil {
+		return err
+	}
+	if _, err := c.RateLimit(context.WithValue(ctx, tokenKey{}, token)); err != nil {
+		return fmt.Errorf("failed to verify new token: %w", err)
+	}
+	c.tokenMu.Lock()
+	c.token = token
+	c.tokenMu.Unlock()
+	return nil
+}
+
+type tokenKey struct{}
+
+// currentToken returns the token the next request is authenticated with
+func (c *Client) currentToken() string {
+	c.tokenMu.RLock()
+	defer c.tokenMu.RUnlock()
+	return c.token
+}
+
+// tokenFrom returns the token requests made with ctx are authenticated with:
+// the one SetToken is verifying, or else the current one
+func (c *Client) tokenFrom(ctx context.Context) string {
+	if token, ok := ctx.Value(tokenKey{}).(string); ok {
+		return token
+	}
+	return c.currentToken()
 }
 
 func (c *Client) FetchRepo(ctx context.Context, owner, name string) (*RepoResponse, error) {
@@ -73,39 +389,23 @@ func (c *Client) FetchRepo(ctx context.Context, owner, name string) (*RepoRespon
 		zap.String("name", name),
 		zap.String("url", reqURL.String()))
 
-	req, err := http.NewRequestWithContext(ctx, "GET", reqURL.String(), nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Authorization", fmt.Sprintf("token %s", c.token))
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
-
-	resp, err := c.httpClient.Do(req)
+	body, _, err := c.get(ctx, reqURL, &Payload{Kind: models.PayloadKindRepo, Owner: owner, Name: name})
 	if err != nil {
 		logger.Error("Failed to fetch repository",
 			zap.Error(err),
 			zap.String("owner", owner),
 			zap.String("name", name))
-		return nil, fmt.Errorf("failed to fetch repository: %w", err)
+		return nil, fmt.Errorf("failed to fetch repository %s/%s: %w", owner, name, err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		logger.Error("Failed to fetch repository",
-			zap.Int("status_code", resp.StatusCode),
-			zap.String("owner", owner),
-			zap.String("name", name))
-		return nil, fmt.Errorf("failed to fetch repository: status code %d", resp.StatusCode)
-	}
-
-	var repo RepoResponse
-	if err := json.NewDecoder(resp.Body).Decode(&repo); err != nil {
+	repo, err := DecodeRepo(body.Bytes())
+	releaseBuffer(body)
+	if err != nil {
 		logger.Error("Failed to decode repository response",
 			zap.Error(err),
 			zap.String("owner", owner),
 			zap.String("name", name))
-		return nil, fmt.Errorf("failed to decode repository response: %w", err)
+		return nil, err
 	}
 
 	logger.Info("Successfully fetched repository",
@@ -114,9 +414,150 @@ func (c *Client) FetchRepo(ctx context.Context, owner, name string) (*RepoRespon
 		zap.String("language", repo.Language),
 		zap.Int("stars", repo.StargazersCount))
 
+	return repo, nil
+}
+
+// FetchRepoByID fetches a repository by its numeric GitHub ID, which is stable
+// across renames and transfers
+func (c *Client) FetchRepoByID(ctx context.Context, id int64) (*RepoResponse, error) {
+	reqURL := c.baseURL.ResolveReference(&url.URL{Path: fmt.Sprintf("/repositories/%d", id)})
+
+	logger.Info("Fetching repository by ID",
+		zap.Int64("github_id", id),
+		zap.String("url", reqURL.String()))
+
+	body, _, err := c.get(ctx, reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch repository %d: %w", id, err)
+	}
+	defer releaseBuffer(body)
+	return DecodeRepo(body.Bytes())
+}
+
+// DecodeRepo parses a repository endpoint response body
+func DecodeRepo(body []byte) (*RepoResponse, error) {
+	var repo RepoResponse
+	if err := json.Unmarshal(body, &repo); err != nil {
+		return nil, fmt.Errorf("failed to decode repository response: %w", err)
+	}
 	return &repo, nil
 }
 
+// DecodeCommits parses one page of the commits endpoint response
+func DecodeCommits(body []byte) ([]CommitResponse, error) {
+	return decodeCommitsInto(nil, body)
+}
+
+// decodeCommitsInto parses a commit listing into dst, reusing its capacity.
+// Listings are decoded by commitDecoder, and by encoding/json if it gives
+// up, so malformed input fails with the same errors as before.
+func decodeCommitsInto(dst []CommitResponse, body []byte) ([]CommitResponse, error) {
+	// Both decoders write into the existing elements, so clear them to keep
+	// fields missing from the new page from leaking through
+	clear(dst[:cap(dst)])
+	if commits, err := decodeCommitsFast(dst[:0], body); err == nil {
+		return commits, nil
+	}
+	clear(dst[:cap(dst)])
+	dst = dst[:0]
+	if err := json.Unmarshal(body, &dst); err != nil {
+		return nil, fmt.Errorf("failed to decode commits response: %w", err)
+	}
+	return dst, nil
+}
+
+// get requests reqURL and returns the body of a successful response in a
+// pooled buffer, which the caller must release, along with the response for
+// its headers. Any other status is returned as an *APIError. When p is set,
+// a copy of the body is handed to the payload sink as p. Attempts that time
+// out are retried as the policy of the URL's endpoint allows.
+func (c *Client) get(ctx context.Context, reqURL *url.URL, p *Payload) (*bytes.Buffer, *http.Response, error) {
+	endpoint := endpointOf(reqURL.Path)
+	retries := c.policies[endpoint].Retries
+	for attempt := 0; ; attempt++ {
+		body, resp, err := c.getOnce(ctx, endpoint, reqURL)
+		if err == nil {
+			if p != nil {
+				c.recordPayload(ctx, body, *p)
+			}
+			return body, resp, nil
+		}
+		if attempt >= retries || !timedOut(ctx, err) {
+			return nil, resp, err
+		}
+		metrics.GitHubErrors.Add("timeout", 1)
+		logger.Warn("GitHub request timed out, retrying",
+			zap.Error(err),
+			zap.String("endpoint", endpoint),
+			zap.String("path", reqURL.Path),
+			zap.Int("attempt", attempt+1))
+	}
+}
+
+// getOnce makes one attempt of get
+func (c *Client) getOnce(ctx context.Context, endpoint string, reqURL *url.URL) (*bytes.Buffer, *http.Response, error) {
+	resp, err := c.doRequest(ctx, c.httpClientFor(endpoint), http.MethodGet, reqURL.String())
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		metrics.GitHubErrors.Add(strconv.Itoa(resp.StatusCode), 1)
+		return nil, resp, &APIError{
+			Method:              http.MethodGet,
+			Path:                reqURL.Path,
+			StatusCode:          resp.StatusCode,
+			Message:             errorMessage(resp),
+			AcceptedPermissions: resp.Header.Get("X-Accepted-GitHub-Permissions"),
+		}
+	}
+
+	body, err := readPooled(resp.Body)
+	if err != nil {
+		return nil, resp, fmt.Errorf("failed to read response of %s: %w", reqURL.Path, err)
+	}
+	return body, resp, nil
+}
+
+// maxErrorBody caps how much of an error response is read for its message
+const maxErrorBody = 64 << 10
+
+// errorMessage returns the message of a GitHub error response body, or ""
+// if it has none. Bodies that are not JSON, such as the HTML pages proxies
+// serve during incidents, are described rather than quoted.
+func errorMessage(resp *http.Response) string {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	if len(bytes.TrimSpace(body)) == 0 {
+		return ""
+	}
+	var apiErr struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &apiErr); err != nil {
+		contentType := resp.Header.Get("Content-Type")
+		if contentType == "" {
+			contentType = "unknown content type"
+		}
+		return fmt.Sprintf("%s (non-JSON response, %s)", http.StatusText(resp.StatusCode), contentType)
+	}
+	return apiErr.Message
+}
+
+// recordPayload hands a copy of a response body to the payload sink, if any
+func (c *Client) recordPayload(ctx context.Context, body *bytes.Buffer, p Payload) {
+	if c.sink != nil {
+		p.Body = bytes.Clone(body.Bytes())
+		if err := c.sink(ctx, p); err != nil {
+			logger.Warn("Failed to record raw payload",
+				zap.Error(err),
+				zap.String("kind", p.Kind),
+				zap.String("owner", p.Owner),
+				zap.String("name", p.Name))
+		}
+	}
+}
+
 // parseRateLimit parses rate limit information from response headers
 func parseRateLimit(resp *http.Response) RateLimit {
 	limit, _ := strconv.Atoi(resp.Header.Get("X-RateLimit-Limit"))
@@ -124,32 +565,233 @@ func parseRateLimit(resp *http.Response) RateLimit {
 	reset, _ := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
 
 	return RateLimit{
+		Resource:  resp.Header.Get("X-RateLimit-Resource"),
 		Limit:     limit,
 		Remaining: remaining,
 		Reset:     time.Unix(reset, 0),
 	}
 }
 
-// handleRateLimit handles rate limit responses and implements exponential backoff
-func (c *Client) handleRateLimit(resp *http.Response) error {
-	if resp.StatusCode == http.StatusForbidden && resp.Header.Get("X-RateLimit-Remaining") == "0" {
-		resetTime, _ := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
-		waitTime := time.Until(time.Unix(resetTime, 0))
+// observeRateLimit publishes the rate limit a response reported as metrics,
+// keyed by resource, and passes it to the rate limit observer
+func (c *Client) observeRateLimit(ctx context.Context, resp *http.Response) {
+	if resp.Header.Get("X-RateLimit-Remaining") == "" {
+		return
+	}
+	rl := parseRateLimit(resp)
+	resource := rl.Resource
+	if resource == "" {
+		resource = "core"
+	}
+	metrics.GitHubRateLimitRemaining.Set(resource, intVar(int64(rl.Remaining)))
+	metrics.GitHubRateLimitReset.Set(resource, intVar(rl.Reset.Unix()))
+	if c.rateLimits != nil {
+		c.rateLimits(ctx, rl, c.clock.Now())
+	}
+}
+
+func intVar(n int64) *expvar.Int {
+	v := new(expvar.Int)
+	v.Set(n)
+	return v
+}
+
+// rateLimitWait reports whether resp is a rate limit rejection and, if so,
+// how long to wait from now before retrying
+func rateLimitWait(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	// Secondary rate limits specify the wait directly
+	if wait, ok := retryAfter(resp, now); ok {
+		return wait, true
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return 0, false
+	}
+	wait := parseRateLimit(resp).Reset.Sub(now)
+	if wait < 0 {
+		wait = 0
+	}
+	return wait, true
+}
+
+// unavailableStatus reports whether code means GitHub, or a proxy in front of
+// it, could not serve the request for now
+func unavailableStatus(code int) bool {
+	return code == http.StatusBadGateway || code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout
+}
+
+// unavailableWait reports whether resp says GitHub is unavailable and, if
+// so, how long GitHub asked to wait from now before retrying, if at all
+func unavailableWait(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if !unavailableStatus(resp.StatusCode) {
+		return 0, false
+	}
+	wait, _ := retryAfter(resp, now)
+	return wait, true
+}
+
+// retryAfter returns the wait from now that the Retry-After header of resp
+// asks for, given in seconds or as an HTTP date
+func retryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0), true
+	}
+	at, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	return max(at.Sub(now), 0), true
+}
+
+// wrapTransport returns a copy of the HTTP client sending requests through
+// the configured transport and middleware, leaving a client passed to
+// WithHTTPClient untouched
+func (c *Client) wrapTransport() *http.Client {
+	rt := c.transport
+	if rt == nil {
+		rt = c.httpClient.Transport
+	}
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		rt = c.middleware[i](rt)
+	}
+	httpClient := *c.httpClient
+	httpClient.Transport = rt
+	return &httpClient
+}
+
+// doRequest performs an authenticated request with httpClient, waiting for the
+// rate limit to reset and retrying when the request is rate limited, and
+// backing off and retrying while GitHub is unavailable. Every
+// call to the API goes through it, so headers, metering, deprecation warnings
+// and the transport middleware apply uniformly.
+func (c *Client) doRequest(ctx context.Context, httpClient *http.Client, method, reqURL string) (*http.Response, error) {
+	var limitedRetries, unavailableRetries int
+	var delay, unavailableDelay time.Duration
+	for {
+		req, err := http.NewRequestWithContext(ctx, method, reqURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+c.tokenFrom(ctx))
+		req.Header.Set("Accept", acceptFrom(ctx))
+		req.Header.Set("X-GitHub-Api-Version", c.apiVersion)
+		// Commit pages run to several MB; asking for gzip explicitly keeps
+		// compression on with custom transports, and meterResponse decodes it
+		req.Header.Set("Accept-Encoding", "gzip")
+
+		start := time.Now()
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		meterResponse(resp, start)
+		c.observeRateLimit(ctx, resp)
+		c.warnIfDeprecated(resp)
+
+		if waitTime, unavailable := unavailableWait(resp, c.clock.Now()); unavailable {
+			// The last response is left to the caller, which reports it as
+			// an APIError matching ErrUnavailable
+			if unavailableRetries == maxUnavailableRetries {
+				return resp, nil
+			}
+			resp.Body.Close()
+			unavailableRetries++
+			b := c.backoff
+			if b == nil {
+				b = unavailableBackoff
+			}
+			unavailableDelay = b.Delay(unavailableRetries, unavailableDelay)
+			waitTime = max(waitTime, unavailableDelay)
+
+			metrics.GitHubErrors.Add("unavailable", 1)
+			logger.Warn("GitHub unavailable, retrying",
+				zap.Int("status", resp.StatusCode),
+				zap.String("path", req.URL.Path),
+				zap.Duration("wait_time", waitTime),
+				zap.Int("attempt", unavailableRetries))
+			if err := c.clock.Sleep(ctx, waitTime); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		waitTime, limited := rateLimitWait(resp, c.clock.Now())
+		if !limited {
+			return resp, nil
+		}
+		resp.Body.Close()
+
+		if limitedRetries == maxRateLimitRetries {
+			metrics.GitHubErrors.Add("rate_limited", 1)
+			return nil, fmt.Errorf("%w: still limited after %d retries", ErrRateLimited, maxRateLimitRetries)
+		}
+		limitedRetries++
+		if c.backoff != nil {
+			delay = c.backoff.Delay(limitedRetries, delay)
+			waitTime = max(waitTime, delay)
+		}
+
 		logger.Info("Rate limit exceeded, waiting for reset",
 			zap.Int("limit", parseRateLimit(resp).Limit),
-			zap.Time("reset_time", time.Unix(resetTime, 0)),
+			zap.Time("reset_time", parseRateLimit(resp).Reset),
 			zap.Duration("wait_time", waitTime))
-		time.Sleep(waitTime)
-		return nil
+		if err := c.clock.Sleep(ctx, waitTime); err != nil {
+			return nil, err
+		}
 	}
-	return nil
 }
 
 // FetchCommits fetches commits from a repository with pagination support
 func (c *Client) FetchCommits(ctx context.Context, owner, name string, since time.Time) ([]CommitResponse, error) {
 	var allCommits []CommitResponse
-	page := 1
+	err := c.FetchCommitPages(ctx, owner, name, "", since, 1, func(_ int, commits []CommitResponse) error {
+		allCommits = append(allCommits, commits...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Successfully fetched all commits",
+		zap.String("owner", owner),
+		zap.String("name", name),
+		zap.Int("total_count", len(allCommits)))
+
+	return allCommits, nil
+}
+
+// CommitPageFunc receives one page of a commit listing. The slice is reused
+// for the next page and must not be retained.
+type CommitPageFunc func(page int, commits []CommitResponse) error
+
+// FetchCommitPages fetches the commits of a repository page by page, starting
+// at startPage, and passes each page to fn as soon as it arrives. Commits are
+// listed from branch, or from the default branch if branch is empty. An error
+// from fn stops the listing and is returned. A listing ending before the last
+// page an earlier page announced returns ErrIncompleteListing, after passing
+// every page it got to fn.
+func (c *Client) FetchCommitPages(ctx context.Context, owner, name, branch string, since time.Time, startPage int, fn CommitPageFunc) (err error) {
+	page := startPage
+	if page < 1 {
+		page = 1
+	}
 	perPage := 100 // GitHub's maximum allowed per page
+	var commits []CommitResponse
+	progress := Progress{Owner: owner, Name: name}
+	defer func() {
+		progress.Done, progress.Err = true, err
+		c.reportProgress(ctx, progress)
+	}()
 
 	for {
 		path := fmt.Sprintf("/repos/%s/%s/commits", owner, name)
@@ -161,83 +803,154 @@ func (c *Client) FetchCommits(ctx context.Context, owner, name string, since tim
 		if !since.IsZero() {
 			q.Set("since", since.Format(time.RFC3339))
 		}
+		if branch != "" {
+			q.Set("sha", branch)
+		}
 		reqURL.RawQuery = q.Encode()
 
 		logger.Info("Fetching commits page",
 			zap.String("owner", owner),
 			zap.String("name", name),
 			zap.Int("page", page),
+			zap.String("branch", branch),
 			zap.Time("since", since),
 			zap.String("url", reqURL.String()))
 
-		req, err := http.NewRequestWithContext(ctx, "GET", reqURL.String(), nil)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create request: %w", err)
-		}
-
-		req.Header.Set("Authorization", fmt.Sprintf("token %s", c.token))
-		req.Header.Set("Accept", "application/vnd.github.v3+json")
-
-		resp, err := c.httpClient.Do(req)
+		body, resp, err := c.get(ctx, reqURL, &Payload{Kind: models.PayloadKindCommits, Owner: owner, Name: name, Page: page})
 		if err != nil {
 			logger.Error("Failed to fetch commits",
 				zap.Error(err),
 				zap.String("owner", owner),
 				zap.String("name", name))
-			return nil, fmt.Errorf("failed to fetch commits: %w", err)
-		}
-
-		// Handle rate limiting
-		if err := c.handleRateLimit(resp); err != nil {
-			resp.Body.Close()
-			return nil, err
+			return fmt.Errorf("failed to fetch commits: %w", err)
 		}
 
-		if resp.StatusCode != http.StatusOK {
-			resp.Body.Close()
-			logger.Error("Failed to fetch commits",
-				zap.Int("status_code", resp.StatusCode),
-				zap.String("owner", owner),
-				zap.String("name", name))
-			return nil, fmt.Errorf("failed to fetch commits: status code %d", resp.StatusCode)
-		}
-
-		var commits []CommitResponse
-		if err := json.NewDecoder(resp.Body).Decode(&commits); err != nil {
-			resp.Body.Close()
+		// Pages are decoded into the same slice, so a backfill allocates one
+		// page worth of commits instead of one per page
+		commits, err = decodeCommitsInto(commits, body.Bytes())
+		releaseBuffer(body)
+		if err != nil {
 			logger.Error("Failed to decode commits response",
 				zap.Error(err),
 				zap.String("owner", owner),
 				zap.String("name", name))
-			return nil, fmt.Errorf("failed to decode commits response: %w", err)
+			return err
 		}
-		resp.Body.Close()
 
-		// If no commits returned, we've reached the end
+		// If no commits returned, we've reached the end, unless an earlier
+		// page announced more
 		if len(commits) == 0 {
+			if page <= progress.LastPage {
+				return fmt.Errorf("%w: commits of %s/%s were empty at page %d of %d", ErrIncompleteListing, owner, name, page, progress.LastPage)
+			}
 			break
 		}
 
-		allCommits = append(allCommits, commits...)
+		if err := fn(page, commits); err != nil {
+			return err
+		}
 
 		// Check if we've reached the last page
 		linkHeader := resp.Header.Get("Link")
+		progress.Page, progress.Commits = page, progress.Commits+len(commits)
+		if last := lastPage(linkHeader); last > 0 {
+			progress.LastPage = last
+		}
 		if linkHeader == "" || !containsNextPage(linkHeader) {
+			if page < progress.LastPage {
+				return fmt.Errorf("%w: commits of %s/%s stopped at page %d of %d", ErrIncompleteListing, owner, name, page, progress.LastPage)
+			}
 			break
 		}
+		c.reportProgress(ctx, progress)
 
 		page++
 	}
 
-	logger.Info("Successfully fetched all commits",
-		zap.String("owner", owner),
-		zap.String("name", name),
-		zap.Int("total_count", len(allCommits)))
+	return nil
+}
 
-	return allCommits, nil
+// commitFilesResponse is the part of the single commit endpoint listing the
+// files a commit touched
+type commitFilesResponse struct {
+	Files []commitFile `json:"files"`
+}
+
+// commitFile is a file a commit touched
+type commitFile struct {
+	Filename         string `json:"filename"`
+	PreviousFilename string `json:"previous_filename"` // Set for renames
+}
+
+// FetchCommitFiles returns the paths a commit touched. A renamed file is
+// reported under both its old and new path.
+func (c *Client) FetchCommitFiles(ctx context.Context, owner, name, sha string) ([]string, error) {
+	var paths []string
+	// GitHub pages the files of large commits, 300 per page
+	for page := 1; ; page++ {
+		path := fmt.Sprintf("/repos/%s/%s/commits/%s", owner, name, sha)
+		reqURL := c.baseURL.ResolveReference(&url.URL{Path: path})
+		q := reqURL.Query()
+		q.Set("page", strconv.Itoa(page))
+		reqURL.RawQuery = q.Encode()
+
+		body, resp, err := c.get(ctx, reqURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch files of commit %s: %w", sha, err)
+		}
+		var files commitFilesResponse
+		err = json.Unmarshal(body.Bytes(), &files)
+		releaseBuffer(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode commit response: %w", err)
+		}
+
+		for _, f := range files.Files {
+			paths = append(paths, f.Filename)
+			if f.PreviousFilename != "" {
+				paths = append(paths, f.PreviousFilename)
+			}
+		}
+		if !containsNextPage(resp.Header.Get("Link")) {
+			return paths, nil
+		}
+	}
+}
+
+// reportProgress passes p to the progress callback, if any
+func (c *Client) reportProgress(ctx context.Context, p Progress) {
+	if c.progress != nil {
+		c.progress(ctx, p)
+	}
+}
+
+// lastPage returns the page number of the Link header's last page, or 0 if
+// the header has none. GitHub omits it on the last page itself.
+func lastPage(linkHeader string) int {
+	for _, link := range strings.Split(linkHeader, ",") {
+		target, rel, ok := strings.Cut(link, ";")
+		if !ok || !strings.Contains(rel, `rel="last"`) {
+			continue
+		}
+		u, err := url.Parse(strings.Trim(strings.TrimSpace(target), "<>"))
+		if err != nil {
+			return 0
+		}
+		page, err := strconv.Atoi(u.Query().Get("page"))
+		if err != nil {
+			return 0
+		}
+		return page
+	}
+	return 0
 }
 
 // containsNextPage checks if the Link header contains a next page
 func containsNextPage(linkHeader string) bool {
-	return linkHeader != "" && linkHeader[len(linkHeader)-1] == '>'
+	for _, link := range strings.Split(linkHeader, ",") {
+		if strings.Contains(link, `rel="next"`) {
+			return true
+		}
+	}
+	return false
 }
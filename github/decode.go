@@ -0,0 +1,524 @@
+package github
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// errDecode makes decodeCommitsInto fall back to encoding/json, which then
+// reports what is wrong with the input
+var errDecode = errors.New("unexpected input")
+
+// commitDecoder decodes a commit listing without reflection. Strings are
+// sliced out of a single copy of the body, escaped ones are unescaped into a
+// single arena and all parents share a single slice, so a page costs a fixed
+// handful of allocations however many commits and fields it holds. In
+// exchange the commits of a page keep all of its memory alive until the last
+// of them is released.
+//
+// Keys are matched exactly; anything it does not handle the way
+// encoding/json would, such as differently cased keys or a field of the
+// wrong type, makes it fail with errDecode.
+type commitDecoder struct {
+	data    string
+	pos     int
+	arena   strings.Builder
+	parents []CommitParent
+}
+
+// decodeCommitsFast decodes a commit listing into dst, which must be cleared
+func decodeCommitsFast(dst []CommitResponse, body []byte) ([]CommitResponse, error) {
+	d := &commitDecoder{data: string(body)}
+	d.skipSpace()
+	if d.peek() != '[' {
+		return nil, errDecode
+	}
+	d.pos++
+	for first := true; ; first = false {
+		more, err := d.nextElement(first)
+		if err != nil {
+			return nil, err
+		}
+		if !more {
+			break
+		}
+		if len(dst) < cap(dst) {
+			dst = dst[:len(dst)+1]
+		} else {
+			dst = append(dst, CommitResponse{})
+		}
+		if err := d.commit(&dst[len(dst)-1]); err != nil {
+			return nil, err
+		}
+	}
+	d.skipSpace()
+	if d.pos != len(d.data) {
+		return nil, errDecode
+	}
+	return dst, nil
+}
+
+func (d *commitDecoder) commit(c *CommitResponse) error {
+	if d.null() {
+		return nil
+	}
+	if err := d.beginObject(); err != nil {
+		return err
+	}
+	for first := true; ; first = false {
+		key, more, err := d.nextKey(first)
+		if err != nil || !more {
+			return err
+		}
+		switch key {
+		case "sha":
+			err = d.stringField(&c.SHA)
+		case "url":
+			err = d.stringField(&c.URL)
+		case "html_url":
+			err = d.stringField(&c.HTMLURL)
+		case "commit":
+			err = d.gitCommit(&c.Commit)
+		case "parents":
+			err = d.parentList(&c.Parents)
+		default:
+			err = d.skipField(key, "sha", "url", "html_url", "commit", "parents")
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (d *commitDecoder) gitCommit(c *CommitDetail) error {
+	if d.null() {
+		return nil
+	}
+	if err := d.beginObject(); err != nil {
+		return err
+	}
+	for first := true; ; first = false {
+		key, more, err := d.nextKey(first)
+		if err != nil || !more {
+			return err
+		}
+		switch key {
+		case "message":
+			err = d.stringField(&c.Message)
+		case "author":
+			err = d.gitActor(&c.Author)
+		case "committer":
+			err = d.gitActor(&c.Committer)
+		default:
+			err = d.skipField(key, "message", "author", "committer")
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (d *commitDecoder) gitActor(a *CommitAuthor) error {
+	if d.null() {
+		return nil
+	}
+	if err := d.beginObject(); err != nil {
+		return err
+	}
+	for first := true; ; first = false {
+		key, more, err := d.nextKey(first)
+		if err != nil || !more {
+			return err
+		}
+		switch key {
+		case "name":
+			err = d.stringField(&a.Name)
+		case "email":
+			err = d.stringField(&a.Email)
+		case "date":
+			err = d.timeField(&a.Date)
+		default:
+			err = d.skipField(key, "name", "email", "date")
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// parentList decodes parents into the shared slice. Each commit's parents
+// are capped so appending to them cannot overwrite the next commit's.
+func (d *commitDecoder) parentList(parents *[]CommitParent) error {
+	if d.null() {
+		*parents = nil
+		return nil
+	}
+	if d.peek() != '[' {
+		return errDecode
+	}
+	d.pos++
+	if d.parents == nil {
+		d.parents = make([]CommitParent, 0, 128)
+	}
+	start := len(d.parents)
+	for first := true; ; first = false {
+		more, err := d.nextElement(first)
+		if err != nil {
+			return err
+		}
+		if !more {
+			break
+		}
+		var p CommitParent
+		if err := d.parent(&p); err != nil {
+			return err
+		}
+		d.parents = append(d.parents, p)
+	}
+	*parents = d.parents[start:len(d.parents):len(d.parents)]
+	return nil
+}
+
+func (d *commitDecoder) parent(p *CommitParent) error {
+	if d.null() {
+		return nil
+	}
+	if err := d.beginObject(); err != nil {
+		return err
+	}
+	for first := true; ; first = false {
+		key, more, err := d.nextKey(first)
+		if err != nil || !more {
+			return err
+		}
+		if key == "sha" {
+			err = d.stringField(&p.SHA)
+		} else {
+			err = d.skipField(key, "sha")
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// beginObject consumes the opening brace of an object
+func (d *commitDecoder) beginObject() error {
+	if d.peek() != '{' {
+		return errDecode
+	}
+	d.pos++
+	return nil
+}
+
+// nextKey consumes the separator before the next key of an object, the key
+// and its colon. It reports false once it consumed the closing brace.
+func (d *commitDecoder) nextKey(first bool) (string, bool, error) {
+	d.skipSpace()
+	if d.peek() == '}' {
+		d.pos++
+		return "", false, nil
+	}
+	if !first {
+		if d.peek() != ',' {
+			return "", false, errDecode
+		}
+		d.pos++
+		d.skipSpace()
+	}
+	key, err := d.str()
+	if err != nil {
+		return "", false, err
+	}
+	d.skipSpace()
+	if d.peek() != ':' {
+		return "", false, errDecode
+	}
+	d.pos++
+	d.skipSpace()
+	return key, true, nil
+}
+
+// nextElement consumes the separator before the next element of an array.
+// It reports false once it consumed the closing bracket.
+func (d *commitDecoder) nextElement(first bool) (bool, error) {
+	d.skipSpace()
+	if d.peek() == ']' {
+		d.pos++
+		return false, nil
+	}
+	if !first {
+		if d.peek() != ',' {
+			return false, errDecode
+		}
+		d.pos++
+		d.skipSpace()
+	}
+	return true, nil
+}
+
+// stringField decodes a string into dst, leaving it alone for null
+func (d *commitDecoder) stringField(dst *string) error {
+	if d.null() {
+		return nil
+	}
+	s, err := d.str()
+	if err != nil {
+		return err
+	}
+	*dst = s
+	return nil
+}
+
+// timeField decodes an RFC 3339 timestamp into dst, leaving it alone for
+// null, as time.Time.UnmarshalJSON does
+func (d *commitDecoder) timeField(dst *time.Time) error {
+	if d.null() {
+		return nil
+	}
+	s, err := d.str()
+	if err != nil {
+		return err
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return errDecode
+	}
+	*dst = t
+	return nil
+}
+
+// str decodes a string. Strings without escapes that are valid UTF-8 are
+// returned as slices of the body; others are unescaped into the arena.
+func (d *commitDecoder) str() (string, error) {
+	if d.peek() != '"' {
+		return "", errDecode
+	}
+	start := d.pos + 1
+	end, escaped, ascii := d.scanString(start)
+	if end < 0 {
+		return "", errDecode
+	}
+	d.pos = end + 1
+	raw := d.data[start:end]
+	if !escaped && (ascii || utf8.ValidString(raw)) {
+		return raw, nil
+	}
+	return d.unescape(raw)
+}
+
+// scanString finds the closing quote of a string starting at start and
+// reports whether the string has escapes and whether it is plain ASCII. It
+// returns -1 for unterminated strings and raw control characters.
+func (d *commitDecoder) scanString(start int) (end int, escaped, ascii bool) {
+	ascii = true
+	for i := start; i < len(d.data); i++ {
+		switch c := d.data[i]; {
+		case c == '"':
+			return i, escaped, ascii
+		case c == '\\':
+			escaped = true
+			i++
+		case c < 0x20:
+			return -1, false, false
+		case c >= utf8.RuneSelf:
+			ascii = false
+		}
+	}
+	return -1, false, false
+}
+
+// unescape decodes the escapes of a raw string into the arena and replaces
+// invalid UTF-8 and unpaired surrogates with U+FFFD, like encoding/json
+func (d *commitDecoder) unescape(raw string) (string, error) {
+	if d.arena.Cap() == 0 {
+		d.arena.Grow(len(d.data) - d.pos + len(raw))
+	}
+	from := d.arena.Len()
+	for i := 0; i < len(raw); {
+		c := raw[i]
+		if c >= utf8.RuneSelf {
+			r, size := utf8.DecodeRuneInString(raw[i:])
+			if r == utf8.RuneError && size == 1 {
+				d.arena.WriteRune(unicode.ReplacementChar)
+			} else {
+				d.arena.WriteString(raw[i : i+size])
+			}
+			i += size
+			continue
+		}
+		if c != '\\' {
+			d.arena.WriteByte(c)
+			i++
+			continue
+		}
+		if i+1 >= len(raw) {
+			return "", errDecode
+		}
+		switch raw[i+1] {
+		case '"', '\\', '/':
+			d.arena.WriteByte(raw[i+1])
+		case 'b':
+			d.arena.WriteByte('\b')
+		case 'f':
+			d.arena.WriteByte('\f')
+		case 'n':
+			d.arena.WriteByte('\n')
+		case 'r':
+			d.arena.WriteByte('\r')
+		case 't':
+			d.arena.WriteByte('\t')
+		case 'u':
+			r, ok := hex4(raw[i+2:])
+			if !ok {
+				return "", errDecode
+			}
+			i += 6
+			if utf16.IsSurrogate(r) {
+				// A surrogate only counts when its pair follows;
+				// otherwise it and only it is replaced
+				if len(raw) >= i+6 && raw[i] == '\\' && raw[i+1] == 'u' {
+					if r2, ok := hex4(raw[i+2:]); ok {
+						if pair := utf16.DecodeRune(r, r2); pair != unicode.ReplacementChar {
+							d.arena.WriteRune(pair)
+							i += 6
+							continue
+						}
+					}
+				}
+				r = unicode.ReplacementChar
+			}
+			d.arena.WriteRune(r)
+			continue
+		default:
+			return "", errDecode
+		}
+		i += 2
+	}
+	return d.arena.String()[from:], nil
+}
+
+// hex4 parses the four hex digits of a \u escape
+func hex4(s string) (rune, bool) {
+	if len(s) < 4 {
+		return 0, false
+	}
+	var r rune
+	for _, c := range []byte(s[:4]) {
+		switch {
+		case '0' <= c && c <= '9':
+			c -= '0'
+		case 'a' <= c && c <= 'f':
+			c -= 'a' - 10
+		case 'A' <= c && c <= 'F':
+			c -= 'A' - 10
+		default:
+			return 0, false
+		}
+		r = r<<4 | rune(c)
+	}
+	return r, true
+}
+
+// skipField skips the value of a field the decoder does not keep. A key
+// matching one of the kept fields in another case is one encoding/json would
+// decode, so it is left to it.
+func (d *commitDecoder) skipField(key string, kept ...string) error {
+	for _, k := range kept {
+		if strings.EqualFold(key, k) {
+			return errDecode
+		}
+	}
+	return d.skipValue()
+}
+
+// skipValue skips a value
+func (d *commitDecoder) skipValue() error {
+	switch c := d.peek(); {
+	case c == '"':
+		end, _, _ := d.scanString(d.pos + 1)
+		if end < 0 {
+			return errDecode
+		}
+		d.pos = end + 1
+	case c == '{':
+		d.pos++
+		for first := true; ; first = false {
+			_, more, err := d.nextKey(first)
+			if err != nil || !more {
+				return err
+			}
+			if err := d.skipValue(); err != nil {
+				return err
+			}
+		}
+	case c == '[':
+		d.pos++
+		for first := true; ; first = false {
+			more, err := d.nextElement(first)
+			if err != nil || !more {
+				return err
+			}
+			if err := d.skipValue(); err != nil {
+				return err
+			}
+		}
+	case c == 't':
+		return d.literal("true")
+	case c == 'f':
+		return d.literal("false")
+	case c == 'n':
+		return d.literal("null")
+	case c == '-' || '0' <= c && c <= '9':
+		start := d.pos
+		for d.pos < len(d.data) && strings.IndexByte("+-.0123456789eE", d.data[d.pos]) >= 0 {
+			d.pos++
+		}
+		if d.pos == start {
+			return errDecode
+		}
+	default:
+		return errDecode
+	}
+	return nil
+}
+
+func (d *commitDecoder) literal(s string) error {
+	if !strings.HasPrefix(d.data[d.pos:], s) {
+		return errDecode
+	}
+	d.pos += len(s)
+	return nil
+}
+
+// null consumes a null, reporting whether there was one
+func (d *commitDecoder) null() bool {
+	if strings.HasPrefix(d.data[d.pos:], "null") {
+		d.pos += 4
+		return true
+	}
+	return false
+}
+
+// peek returns the current byte, or 0 at the end of the input
+func (d *commitDecoder) peek() byte {
+	if d.pos < len(d.data) {
+		return d.data[d.pos]
+	}
+	return 0
+}
+
+func (d *commitDecoder) skipSpace() {
+	for d.pos < len(d.data) {
+		switch d.data[d.pos] {
+		case ' ', '\t', '\n', '\r':
+			d.pos++
+		default:
+			return
+		}
+	}
+}
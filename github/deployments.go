@@ -0,0 +1,90 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// DeploymentResponse is one deployment of the deployments endpoint response
+type DeploymentResponse struct {
+	ID          int64     `json:"id"`
+	SHA         string    `json:"sha"`
+	Ref         string    `json:"ref"`
+	Environment string    `json:"environment"`
+	Creator     RepoOwner `json:"creator"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// DeploymentStatusResponse is one status a deployment reported
+type DeploymentStatusResponse struct {
+	State     string    `json:"state"` // e.g. "queued", "in_progress", "success", "failure", "inactive"
+	LogURL    string    `json:"log_url"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// FetchDeployments returns the deployments of a repository created at or
+// after since, newest first. The deployments endpoint cannot filter by time,
+// so the listing stops at the first page reaching past since.
+func (c *Client) FetchDeployments(ctx context.Context, owner, name string, since time.Time) ([]DeploymentResponse, error) {
+	var deployments []DeploymentResponse
+	for page := 1; ; page++ {
+		reqURL := c.baseURL.ResolveReference(&url.URL{Path: fmt.Sprintf("/repos/%s/%s/deployments", owner, name)})
+		q := reqURL.Query()
+		q.Set("page", strconv.Itoa(page))
+		q.Set("per_page", "100")
+		reqURL.RawQuery = q.Encode()
+
+		body, resp, err := c.get(ctx, reqURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch deployments of %s/%s: %w", owner, name, err)
+		}
+		var listed []DeploymentResponse
+		err = json.Unmarshal(body.Bytes(), &listed)
+		releaseBuffer(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode deployments response: %w", err)
+		}
+
+		for _, deployment := range listed {
+			if deployment.CreatedAt.Before(since) {
+				return deployments, nil
+			}
+			deployments = append(deployments, deployment)
+		}
+		if !containsNextPage(resp.Header.Get("Link")) {
+			return deployments, nil
+		}
+	}
+}
+
+// FetchDeploymentStatuses returns the statuses of a deployment, newest first
+func (c *Client) FetchDeploymentStatuses(ctx context.Context, owner, name string, id int64) ([]DeploymentStatusResponse, error) {
+	var statuses []DeploymentStatusResponse
+	for page := 1; ; page++ {
+		reqURL := c.baseURL.ResolveReference(&url.URL{Path: fmt.Sprintf("/repos/%s/%s/deployments/%d/statuses", owner, name, id)})
+		q := reqURL.Query()
+		q.Set("page", strconv.Itoa(page))
+		q.Set("per_page", "100")
+		reqURL.RawQuery = q.Encode()
+
+		body, resp, err := c.get(ctx, reqURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch statuses of deployment %d of %s/%s: %w", id, owner, name, err)
+		}
+		var listed []DeploymentStatusResponse
+		err = json.Unmarshal(body.Bytes(), &listed)
+		releaseBuffer(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode deployment statuses response: %w", err)
+		}
+
+		statuses = append(statuses, listed...)
+		if !containsNextPage(resp.Header.Get("Link")) {
+			return statuses, nil
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Endpoints whose requests can be given their own timeout and retries
+const (
+	// EndpointRepo is the repository itself, by name or by ID
+	EndpointRepo = "repo"
+	// EndpointCommits is the commit listing and single commits
+	EndpointCommits = "commits"
+	// EndpointStats is the repository statistics under /stats
+	EndpointStats = "stats"
+	// EndpointGraphQL is the GraphQL API
+	EndpointGraphQL = "graphql"
+)
+
+// Endpoints lists every endpoint a policy can be set for
+var Endpoints = []string{EndpointRepo, EndpointCommits, EndpointStats, EndpointGraphQL}
+
+// EndpointPolicy is how long the requests to an endpoint may take and how
+// often they are retried when they take longer
+type EndpointPolicy struct {
+	// Timeout bounds each attempt, reading the response included; 0 keeps
+	// the HTTP client's timeout
+	Timeout time.Duration
+	// Retries is how many times an attempt that timed out is retried.
+	// Rate limited requests are retried regardless.
+	Retries int
+}
+
+// WithEndpointPolicy sets the timeout and retries of the requests to
+// endpoint, one of Endpoints, in place of the client's
+func WithEndpointPolicy(endpoint string, p EndpointPolicy) Option {
+	return func(c *Client) {
+		if c.policies == nil {
+			c.policies = map[string]EndpointPolicy{}
+		}
+		c.policies[endpoint] = p
+	}
+}
+
+// ParseEndpointPolicies parses comma-separated endpoint=seconds timeouts and
+// endpoint=count retries, e.g. "repo=10,commits=120" and "commits=2", into
+// the policies of the endpoints they name
+func ParseEndpointPolicies(timeouts, retries string) (map[string]EndpointPolicy, error) {
+	policies := map[string]EndpointPolicy{}
+	err := parseEndpointValues(timeouts, func(endpoint string, n int) {
+		p := policies[endpoint]
+		p.Timeout = time.Duration(n) * time.Second
+		policies[endpoint] = p
+	})
+	if err != nil {
+		return nil, fmt.Errorf("timeouts: %w", err)
+	}
+	err = parseEndpointValues(retries, func(endpoint string, n int) {
+		p := policies[endpoint]
+		p.Retries = n
+		policies[endpoint] = p
+	})
+	if err != nil {
+		return nil, fmt.Errorf("retries: %w", err)
+	}
+	return policies, nil
+}
+
+// parseEndpointValues calls set for every endpoint=n entry of raw
+func parseEndpointValues(raw string, set func(endpoint string, n int)) error {
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		endpoint, value, ok := strings.Cut(entry, "=")
+		endpoint = strings.ToLower(strings.TrimSpace(endpoint))
+		if !ok || !knownEndpoint(endpoint) {
+			return fmt.Errorf("%q: want endpoint=value with endpoint one of %s", entry, strings.Join(Endpoints, ", "))
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || n < 0 {
+			return fmt.Errorf("%q: want a non-negative whole number", entry)
+		}
+		set(endpoint, n)
+	}
+	return nil
+}
+
+func knownEndpoint(endpoint string) bool {
+	for _, e := range Endpoints {
+		if e == endpoint {
+			return true
+		}
+	}
+	return false
+}
+
+// endpointOf returns the endpoint a request path belongs to, or "" for the
+// requests no policy applies to
+func endpointOf(path string) string {
+	if strings.HasSuffix(path, "/graphql") {
+		return EndpointGraphQL
+	}
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case len(parts) == 2 && parts[0] == "repositories":
+		return EndpointRepo
+	case len(parts) < 3 || parts[0] != "repos":
+		return ""
+	case len(parts) == 3:
+		return EndpointRepo
+	case parts[3] == "commits":
+		return EndpointCommits
+	case parts[3] == "stats":
+		return EndpointStats
+	}
+	return ""
+}
+
+// endpointClients returns a copy of httpClient for every policy setting a
+// timeout, sharing its transport
+func endpointClients(httpClient *http.Client, policies map[string]EndpointPolicy) map[string]*http.Client {
+	clients := map[string]*http.Client{}
+	for endpoint, p := range policies {
+		if p.Timeout > 0 {
+			client := *httpClient
+			client.Timeout = p.Timeout
+			clients[endpoint] = &client
+		}
+	}
+	return clients
+}
+
+// httpClientFor returns the HTTP client the requests to endpoint are sent with
+func (c *Client) httpClientFor(endpoint string) *http.Client {
+	if client, ok := c.endpointClients[endpoint]; ok {
+		return client
+	}
+	return c.httpClient
+}
+
+// timedOut reports whether err is an attempt timing out rather than ctx
+// ending
+func timedOut(ctx context.Context, err error) bool {
+	var netErr net.Error
+	return ctx.Err() == nil && errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,89 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"githubapifetch/logger"
+)
+
+// IssueResponse is one issue of the issues endpoint response
+type IssueResponse struct {
+	Number    int          `json:"number"`
+	Title     string       `json:"title"`
+	State     string       `json:"state"` // "open" or "closed"
+	User      RepoOwner    `json:"user"`
+	Labels    []IssueLabel `json:"labels"`
+	HTMLURL   string       `json:"html_url"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+	ClosedAt  *time.Time   `json:"closed_at"`
+	// PullRequest is set for pull requests, which GitHub lists as issues
+	PullRequest *struct{} `json:"pull_request"`
+}
+
+// IssueLabel is a label of an issue
+type IssueLabel struct {
+	Name string `json:"name"`
+}
+
+// IssuePageFunc receives the issues of one page of an issue listing
+type IssuePageFunc func(issues []IssueResponse) error
+
+// FetchIssuePages fetches the open and closed issues of a repository updated
+// at or after since, least recently updated first, and passes each page to
+// fn as soon as it arrives. Pull requests are left out. An error from fn
+// stops the listing and is returned.
+func (c *Client) FetchIssuePages(ctx context.Context, owner, name string, since time.Time, fn IssuePageFunc) error {
+	for page := 1; ; page++ {
+		reqURL := c.baseURL.ResolveReference(&url.URL{Path: fmt.Sprintf("/repos/%s/%s/issues", owner, name)})
+		q := reqURL.Query()
+		q.Set("state", "all")
+		q.Set("sort", "updated")
+		q.Set("direction", "asc")
+		q.Set("page", strconv.Itoa(page))
+		q.Set("per_page", "100")
+		if !since.IsZero() {
+			q.Set("since", since.UTC().Format(time.RFC3339))
+		}
+		reqURL.RawQuery = q.Encode()
+
+		logger.Info("Fetching issues page",
+			zap.String("owner", owner),
+			zap.String("name", name),
+			zap.Int("page", page),
+			zap.Time("since", since))
+
+		body, resp, err := c.get(ctx, reqURL, nil)
+		if err != nil {
+			return fmt.Errorf("failed to fetch issues: %w", err)
+		}
+		var listed []IssueResponse
+		err = json.Unmarshal(body.Bytes(), &listed)
+		releaseBuffer(body)
+		if err != nil {
+			return fmt.Errorf("failed to decode issues response: %w", err)
+		}
+
+		issues := listed[:0]
+		for _, issue := range listed {
+			if issue.PullRequest == nil {
+				issues = append(issues, issue)
+			}
+		}
+		if len(issues) > 0 {
+			if err := fn(issues); err != nil {
+				return err
+			}
+		}
+		if !containsNextPage(resp.Header.Get("Link")) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package github
+
+import (
+	"context"
+	"strings"
+)
+
+// DefaultMediaType is the Accept header of requests that ask for no other
+// media type
+const DefaultMediaType = "application/vnd.github.v3+json"
+
+type acceptKey struct{}
+
+// ContextWithAccept returns a copy of ctx whose requests ask for the given
+// media types instead of DefaultMediaType, e.g. the preview media type
+// "application/vnd.github.mercy-preview+json" for the topics of a
+// repository, or "application/vnd.github.full+json" for issue bodies
+// rendered as HTML too. Without media types ctx is returned as is. Responses
+// are still decoded as JSON by the calling method, so a media type must keep
+// them in its shape.
+func ContextWithAccept(ctx context.Context, mediaTypes ...string) context.Context {
+	if len(mediaTypes) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, acceptKey{}, strings.Join(mediaTypes, ", "))
+}
+
+// acceptFrom returns the Accept header of the requests made with ctx
+func acceptFrom(ctx context.Context) string {
+	if accept, ok := ctx.Value(acceptKey{}).(string); ok {
+		return accept
+	}
+	return DefaultMediaType
+}
@@ -0,0 +1,36 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// OwnerResponse is the users endpoint response, which describes users and
+// organizations alike
+type OwnerResponse struct {
+	ID    int64  `json:"id"`
+	Login string `json:"login"`
+	// Type is "User" or "Organization"
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	AvatarURL   string `json:"avatar_url"`
+	PublicRepos int    `json:"public_repos"`
+}
+
+// FetchOwner fetches the profile of the user or organization login
+func (c *Client) FetchOwner(ctx context.Context, login string) (*OwnerResponse, error) {
+	reqURL := c.baseURL.ResolveReference(&url.URL{Path: fmt.Sprintf("/users/%s", login)})
+	body, _, err := c.get(ctx, reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch owner %s: %w", login, err)
+	}
+	defer releaseBuffer(body)
+
+	var owner OwnerResponse
+	if err := json.Unmarshal(body.Bytes(), &owner); err != nil {
+		return nil, fmt.Errorf("failed to decode owner response: %w", err)
+	}
+	return &owner, nil
+}
@@ -0,0 +1,137 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"githubapifetch/logger"
+)
+
+// PullRequestResponse is a pull request as the pulls endpoints return it.
+// Listings leave out the size of the change; Additions, Deletions and
+// ChangedFiles are only set by FetchPullRequest.
+type PullRequestResponse struct {
+	Number       int        `json:"number"`
+	Title        string     `json:"title"`
+	State        string     `json:"state"` // "open" or "closed"
+	Draft        bool       `json:"draft"`
+	User         RepoOwner  `json:"user"`
+	HTMLURL      string     `json:"html_url"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	ClosedAt     *time.Time `json:"closed_at"`
+	MergedAt     *time.Time `json:"merged_at"`
+	Additions    int        `json:"additions"`
+	Deletions    int        `json:"deletions"`
+	ChangedFiles int        `json:"changed_files"`
+}
+
+// ReviewResponse is one review of a pull request
+type ReviewResponse struct {
+	ID          int64     `json:"id"`
+	User        RepoOwner `json:"user"`
+	State       string    `json:"state"` // e.g. "APPROVED", "CHANGES_REQUESTED", "COMMENTED"
+	SubmittedAt time.Time `json:"submitted_at"`
+}
+
+// FetchUpdatedPullRequests lists the open and closed pull requests of a
+// repository updated at or after since, most recently updated first. The
+// pulls endpoint cannot filter by update time, so the listing stops at the
+// first page reaching past since.
+func (c *Client) FetchUpdatedPullRequests(ctx context.Context, owner, name string, since time.Time) ([]PullRequestResponse, error) {
+	var pulls []PullRequestResponse
+	for page := 1; ; page++ {
+		reqURL := c.baseURL.ResolveReference(&url.URL{Path: fmt.Sprintf("/repos/%s/%s/pulls", owner, name)})
+		q := reqURL.Query()
+		q.Set("state", "all")
+		q.Set("sort", "updated")
+		q.Set("direction", "desc")
+		q.Set("page", strconv.Itoa(page))
+		q.Set("per_page", "100")
+		reqURL.RawQuery = q.Encode()
+
+		logger.Info("Fetching pull requests page",
+			zap.String("owner", owner),
+			zap.String("name", name),
+			zap.Int("page", page),
+			zap.Time("since", since))
+
+		body, resp, err := c.get(ctx, reqURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch pull requests: %w", err)
+		}
+		var listed []PullRequestResponse
+		err = json.Unmarshal(body.Bytes(), &listed)
+		releaseBuffer(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode pull requests response: %w", err)
+		}
+
+		for _, pull := range listed {
+			if pull.UpdatedAt.Before(since) {
+				return pulls, nil
+			}
+			pulls = append(pulls, pull)
+		}
+		if !containsNextPage(resp.Header.Get("Link")) {
+			return pulls, nil
+		}
+	}
+}
+
+// FetchPullRequest returns a single pull request, with the size of its
+// change
+func (c *Client) FetchPullRequest(ctx context.Context, owner, name string, number int) (*PullRequestResponse, error) {
+	reqURL := c.baseURL.ResolveReference(&url.URL{Path: fmt.Sprintf("/repos/%s/%s/pulls/%d", owner, name, number)})
+	body, _, err := c.get(ctx, reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pull request #%d of %s/%s: %w", number, owner, name, err)
+	}
+	defer releaseBuffer(body)
+
+	var pull PullRequestResponse
+	if err := json.Unmarshal(body.Bytes(), &pull); err != nil {
+		return nil, fmt.Errorf("failed to decode pull request response: %w", err)
+	}
+	return &pull, nil
+}
+
+// FetchReviews returns the submitted reviews of a pull request, oldest
+// first
+func (c *Client) FetchReviews(ctx context.Context, owner, name string, number int) ([]ReviewResponse, error) {
+	var reviews []ReviewResponse
+	for page := 1; ; page++ {
+		reqURL := c.baseURL.ResolveReference(&url.URL{Path: fmt.Sprintf("/repos/%s/%s/pulls/%d/reviews", owner, name, number)})
+		q := reqURL.Query()
+		q.Set("page", strconv.Itoa(page))
+		q.Set("per_page", "100")
+		reqURL.RawQuery = q.Encode()
+
+		body, resp, err := c.get(ctx, reqURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch reviews of pull request #%d of %s/%s: %w", number, owner, name, err)
+		}
+		var listed []ReviewResponse
+		err = json.Unmarshal(body.Bytes(), &listed)
+		releaseBuffer(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode reviews response: %w", err)
+		}
+
+		// Pending reviews have not been submitted yet
+		for _, review := range listed {
+			if review.State != "PENDING" {
+				reviews = append(reviews, review)
+			}
+		}
+		if !containsNextPage(resp.Header.Get("Link")) {
+			return reviews, nil
+		}
+	}
+}
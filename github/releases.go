@@ -0,0 +1,55 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ReleaseResponse is one release of the releases endpoint response
+type ReleaseResponse struct {
+	ID         int64  `json:"id"`
+	TagName    string `json:"tag_name"`
+	Name       string `json:"name"`
+	Draft      bool   `json:"draft"`
+	Prerelease bool   `json:"prerelease"`
+	HTMLURL    string `json:"html_url"`
+	// PublishedAt is nil for drafts
+	PublishedAt *time.Time `json:"published_at"`
+}
+
+// FetchReleases returns every release of a repository, newest first. Drafts
+// are left out.
+func (c *Client) FetchReleases(ctx context.Context, owner, name string) ([]ReleaseResponse, error) {
+	var releases []ReleaseResponse
+	for page := 1; ; page++ {
+		reqURL := c.baseURL.ResolveReference(&url.URL{Path: fmt.Sprintf("/repos/%s/%s/releases", owner, name)})
+		q := reqURL.Query()
+		q.Set("page", strconv.Itoa(page))
+		q.Set("per_page", "100")
+		reqURL.RawQuery = q.Encode()
+
+		body, resp, err := c.get(ctx, reqURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch releases of %s/%s: %w", owner, name, err)
+		}
+		var listed []ReleaseResponse
+		err = json.Unmarshal(body.Bytes(), &listed)
+		releaseBuffer(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode releases response: %w", err)
+		}
+
+		for _, release := range listed {
+			if !release.Draft && release.PublishedAt != nil {
+				releases = append(releases, release)
+			}
+		}
+		if !containsNextPage(resp.Header.Get("Link")) {
+			return releases, nil
+		}
+	}
+}
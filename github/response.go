@@ -0,0 +1,103 @@
+package github
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"githubapifetch/logger"
+	"githubapifetch/metrics"
+)
+
+// meteredBody transparently decompresses a gzip-encoded response body and,
+// once closed, logs and records how many bytes crossed the wire, how many
+// they decoded to and how long the response took to serve
+type meteredBody struct {
+	wire     countingReader
+	body     io.ReadCloser
+	decoded  io.Reader
+	gzip     bool
+	path     string
+	status   int
+	start    time.Time
+	bytes    int64
+	recorded bool
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// meterResponse replaces the body of resp with a metered, decompressing one.
+// start is when the request was sent.
+func meterResponse(resp *http.Response, start time.Time) {
+	m := &meteredBody{
+		body:   resp.Body,
+		path:   resp.Request.URL.Path,
+		status: resp.StatusCode,
+		start:  start,
+	}
+	m.wire.r = resp.Body
+	m.decoded = &m.wire
+
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		m.gzip = true
+		// Mirror what net/http does for the compression it negotiates itself
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+		resp.Uncompressed = true
+	}
+	resp.Body = m
+}
+
+func (m *meteredBody) Read(p []byte) (int, error) {
+	if m.gzip {
+		// The gzip header is read lazily so that empty bodies close cleanly
+		gz, err := gzip.NewReader(&m.wire)
+		if err != nil {
+			return 0, err
+		}
+		m.decoded, m.gzip = gz, false
+	}
+	n, err := m.decoded.Read(p)
+	m.bytes += int64(n)
+	return n, err
+}
+
+func (m *meteredBody) Close() error {
+	err := m.body.Close()
+	if !m.recorded {
+		m.recorded = true
+		m.record()
+	}
+	return err
+}
+
+// record logs and publishes the size and serve time of the response
+func (m *meteredBody) record() {
+	elapsed := time.Since(m.start)
+	metrics.GitHubResponses.Add(1)
+	metrics.GitHubResponseBytes.Add("wire", m.wire.n)
+	metrics.GitHubResponseBytes.Add("decoded", m.bytes)
+	metrics.GitHubResponseMillis.Add(elapsed.Milliseconds())
+
+	logger.Info("GitHub response",
+		zap.String("path", m.path),
+		zap.Int("status_code", m.status),
+		zap.Int64("wire_bytes", m.wire.n),
+		zap.Int64("bytes", m.bytes),
+		zap.Duration("serve_time", elapsed))
+}
@@ -0,0 +1,38 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// FetchStarred returns every repository starred by the user the client's
+// token authenticates, most recently starred first
+func (c *Client) FetchStarred(ctx context.Context) ([]RepoResponse, error) {
+	var starred []RepoResponse
+	for page := 1; ; page++ {
+		reqURL := c.baseURL.ResolveReference(&url.URL{Path: "/user/starred"})
+		q := reqURL.Query()
+		q.Set("page", strconv.Itoa(page))
+		q.Set("per_page", "100")
+		reqURL.RawQuery = q.Encode()
+
+		body, resp, err := c.get(ctx, reqURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch starred repositories: %w", err)
+		}
+		var listed []RepoResponse
+		err = json.Unmarshal(body.Bytes(), &listed)
+		releaseBuffer(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode starred repositories response: %w", err)
+		}
+
+		starred = append(starred, listed...)
+		if !containsNextPage(resp.Header.Get("Link")) {
+			return starred, nil
+		}
+	}
+}
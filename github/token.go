@@ -0,0 +1,68 @@
+package github
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Token types, told apart by their prefix
+const (
+	TokenClassic      = "classic"        // ghp_
+	TokenFineGrained  = "fine-grained"   // github_pat_
+	TokenInstallation = "installation"   // ghs_, issued to GitHub Apps
+	TokenOAuth        = "oauth"          // gho_
+	TokenUserToServer = "user-to-server" // ghu_
+	TokenLegacy       = "legacy"         // 40 hex characters, issued before April 2021
+	TokenUnknown      = "unknown"        // any other format, e.g. from GitHub Enterprise
+)
+
+// ErrInvalidToken is returned for tokens that cannot authenticate API
+// requests
+var ErrInvalidToken = errors.New("invalid github token")
+
+var tokenPrefixes = []struct {
+	prefix string
+	kind   string
+}{
+	{"github_pat_", TokenFineGrained},
+	{"ghp_", TokenClassic},
+	{"ghs_", TokenInstallation},
+	{"gho_", TokenOAuth},
+	{"ghu_", TokenUserToServer},
+}
+
+var (
+	tokenBody   = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+	legacyToken = regexp.MustCompile(`^[0-9a-f]{40}$`)
+	headerSafe  = regexp.MustCompile(`^[!-~]+$`)
+)
+
+// TokenType returns the type of a GitHub token, or an error wrapping
+// ErrInvalidToken if it is one GitHub is known to reject for API requests.
+// Tokens in a format it does not recognize are TokenUnknown rather than an
+// error: GitHub Enterprise and token types added later may still accept
+// them, and GitHub answering 401 is the real check. The token itself never
+// appears in the error.
+func TokenType(token string) (string, error) {
+	for _, p := range tokenPrefixes {
+		if rest, ok := strings.CutPrefix(token, p.prefix); ok {
+			if !tokenBody.MatchString(rest) {
+				return "", fmt.Errorf("%w: malformed %s token", ErrInvalidToken, p.kind)
+			}
+			return p.kind, nil
+		}
+	}
+	switch {
+	case strings.HasPrefix(token, "ghr_"):
+		return "", fmt.Errorf("%w: refresh tokens (ghr_) cannot authenticate API requests", ErrInvalidToken)
+	case legacyToken.MatchString(token):
+		return TokenLegacy, nil
+	case token == "":
+		return "", fmt.Errorf("%w: empty token", ErrInvalidToken)
+	case !headerSafe.MatchString(token):
+		return "", fmt.Errorf("%w: token contains spaces or control characters", ErrInvalidToken)
+	}
+	return TokenUnknown, nil
+}
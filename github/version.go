@@ -0,0 +1,64 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"go.uber.org/zap"
+
+	"githubapifetch/logger"
+)
+
+// APIVersion describes how GitHub serves the requested REST API version
+type APIVersion struct {
+	Requested  string
+	Selected   string // Version GitHub reports having served, if any
+	Deprecated bool
+	Sunset     string // When a deprecated version stops working, if announced
+}
+
+// apiVersionOf reads the API version headers of a response
+func (c *Client) apiVersionOf(resp *http.Response) APIVersion {
+	return APIVersion{
+		Requested:  c.apiVersion,
+		Selected:   resp.Header.Get("X-GitHub-Api-Version-Selected"),
+		Deprecated: resp.Header.Get("Deprecation") != "",
+		Sunset:     resp.Header.Get("Sunset"),
+	}
+}
+
+// CheckAPIVersion asks GitHub whether the configured API version is
+// supported and not deprecated. The rate limit endpoint is used as it does
+// not count against the rate limit.
+func (c *Client) CheckAPIVersion(ctx context.Context) (*APIVersion, error) {
+	reqURL := c.baseURL.ResolveReference(&url.URL{Path: "/rate_limit"})
+	body, resp, err := c.get(ctx, reqURL, nil)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusBadRequest {
+		return nil, fmt.Errorf("API version %s is not supported by GitHub: %w", c.apiVersion, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to check API version %s: %w", c.apiVersion, err)
+	}
+	releaseBuffer(body)
+
+	version := c.apiVersionOf(resp)
+	return &version, nil
+}
+
+// warnIfDeprecated logs, once per client, that GitHub marked the requested
+// API version as deprecated
+func (c *Client) warnIfDeprecated(resp *http.Response) {
+	if resp.Header.Get("Deprecation") == "" {
+		return
+	}
+	c.deprecationWarning.Do(func() {
+		version := c.apiVersionOf(resp)
+		logger.Warn("GitHub API version is deprecated; set GITHUB_API_VERSION to a supported version",
+			zap.String("api_version", version.Requested),
+			zap.String("sunset", version.Sunset))
+	})
+}
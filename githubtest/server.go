@@ -0,0 +1,1290 @@
+// Package githubtest provides an in-process fake of the GitHub REST API
+// endpoints used by package github, so code embedding this library can be
+// tested without network access or a GitHub token.
+//
+// The fake serves repositories, commits, issues, pull requests, releases,
+// deployments and owners added with AddRepo, AddCommits, AddIssues,
+// AddPullRequests, AddReleases, AddDeployments and AddOwner, lists the
+// repositories starred with Star as the authenticated user's, paginates listings with Link headers, tracks a primary rate limit
+// with X-RateLimit-* headers, validates the X-GitHub-Api-Version header,
+// redirects renamed repositories to their ID, gzips responses for clients
+// accepting it and can be told to fail specific requests or cut listings
+// short:
+//
+//	srv := githubtest.NewServer(githubtest.WithToken("token"))
+//	defer srv.Close()
+//	srv.AddRepo(githubtest.Repo{Owner: "octo", Name: "hello"})
+//	client := github.NewClient("token", github.WithBaseURL(srv.URL))
+package githubtest
+
+import (
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAPIVersion = "2022-11-28"
+	defaultRateLimit  = 5000
+	defaultRateWindow = time.Hour
+	defaultPerPage    = 30
+	maxPerPage        = 100
+)
+
+// Repo is a repository served by the fake. A zero ID is assigned automatically.
+type Repo struct {
+	ID          int64
+	Owner       string
+	Name        string
+	Description string
+	Language    string
+	Forks       int
+	Stars       int
+	OpenIssues  int
+	Watchers    int
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	PushedAt    time.Time
+	// DefaultBranch defaults to main
+	DefaultBranch string
+	// Files maps paths to the contents of the files on the default branch
+	// served by the contents endpoint
+	Files map[string]string
+}
+
+// Commit is a commit served by the fake
+type Commit struct {
+	SHA         string
+	Message     string
+	AuthorName  string
+	AuthorEmail string
+	Date        time.Time
+	// CommitterDate defaults to Date; like GitHub, since filters on it
+	CommitterDate time.Time
+	// Branch limits the commit to one branch; commits without one are on
+	// every branch
+	Branch string
+	// Files are the paths the commit touched
+	Files []string
+	// Parents are the parent SHAs, first parent first
+	Parents []string
+}
+
+// Issue is an issue, or a pull request, served by the fake
+type Issue struct {
+	Number int
+	Title  string
+	Author string
+	Labels []string
+	// ClosedAt is zero for open issues
+	ClosedAt  time.Time
+	CreatedAt time.Time
+	// UpdatedAt defaults to the later of CreatedAt and ClosedAt
+	UpdatedAt   time.Time
+	PullRequest bool
+}
+
+// PullRequest is a pull request served by the pulls endpoints, with its
+// reviews
+type PullRequest struct {
+	Number int
+	Title  string
+	Author string
+	Draft  bool
+	// ClosedAt is zero for open pull requests; merged ones have MergedAt
+	// set too
+	ClosedAt  time.Time
+	MergedAt  time.Time
+	CreatedAt time.Time
+	// UpdatedAt defaults to the later of CreatedAt and ClosedAt
+	UpdatedAt    time.Time
+	Additions    int
+	Deletions    int
+	ChangedFiles int
+	Reviews      []Review
+}
+
+// Review is a review of a pull request. A zero ID is assigned automatically.
+type Review struct {
+	ID          int64
+	Reviewer    string
+	State       string
+	SubmittedAt time.Time
+}
+
+// Release is a published release served by the fake. A zero ID is assigned
+// automatically. The commit endpoint resolves TagName to SHA.
+type Release struct {
+	ID          int64
+	TagName     string
+	Name        string
+	SHA         string
+	Prerelease  bool
+	PublishedAt time.Time
+}
+
+// Deployment is a deployment served by the fake, with its statuses. A zero
+// ID is assigned automatically.
+type Deployment struct {
+	ID          int64
+	SHA         string
+	Ref         string
+	Environment string
+	Creator     string
+	CreatedAt   time.Time
+	Statuses    []DeploymentStatus
+}
+
+// DeploymentStatus is a status a deployment reported
+type DeploymentStatus struct {
+	State     string
+	LogURL    string
+	CreatedAt time.Time
+}
+
+// Owner is a user or organization served by the users endpoint. A zero ID is
+// assigned automatically.
+type Owner struct {
+	ID           int64
+	Login        string
+	Name         string
+	Organization bool
+	AvatarURL    string
+	PublicRepos  int
+}
+
+// updated returns the time p was last updated
+func (p PullRequest) updated() time.Time {
+	switch {
+	case !p.UpdatedAt.IsZero():
+		return p.UpdatedAt
+	case p.ClosedAt.After(p.CreatedAt):
+		return p.ClosedAt
+	}
+	return p.CreatedAt
+}
+
+// updated returns the time i was last updated
+func (i Issue) updated() time.Time {
+	switch {
+	case !i.UpdatedAt.IsZero():
+		return i.UpdatedAt
+	case i.ClosedAt.After(i.CreatedAt):
+		return i.ClosedAt
+	}
+	return i.CreatedAt
+}
+
+// committed returns the committer date of c
+func (c Commit) committed() time.Time {
+	if c.CommitterDate.IsZero() {
+		return c.Date
+	}
+	return c.CommitterDate
+}
+
+// defaultBranch returns the default branch of r
+func (r Repo) defaultBranch() string {
+	if r.DefaultBranch == "" {
+		return "main"
+	}
+	return r.DefaultBranch
+}
+
+// Option configures a Server
+type Option func(*Server)
+
+// WithToken makes the server reject requests not authenticated with token
+func WithToken(token string) Option {
+	return func(s *Server) {
+		s.token = token
+	}
+}
+
+// WithRateLimit sets the number of requests allowed per window
+func WithRateLimit(limit int, window time.Duration) Option {
+	return func(s *Server) {
+		s.limit = limit
+		s.window = window
+	}
+}
+
+// WithClock sets the clock used for rate limit windows
+func WithClock(now func() time.Time) Option {
+	return func(s *Server) {
+		s.now = now
+	}
+}
+
+// WithAPIVersions sets the REST API versions the server accepts in the
+// X-GitHub-Api-Version header; others are rejected with 400 like GitHub does.
+// By default only 2022-11-28 is supported.
+func WithAPIVersions(versions ...string) Option {
+	return func(s *Server) {
+		s.versions = make(map[string]bool)
+		for _, v := range versions {
+			s.versions[v] = true
+		}
+	}
+}
+
+// WithDeprecatedAPIVersion accepts version but marks its responses with
+// Deprecation and Sunset headers
+func WithDeprecatedAPIVersion(version string, sunset time.Time) Option {
+	return func(s *Server) {
+		s.sunsets[version] = sunset
+	}
+}
+
+// Server is a fake GitHub API server
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	token     string
+	now       func() time.Time
+	limit     int
+	window    time.Duration
+	remaining int
+	reset     time.Time
+	repos     map[string]*repoState
+	owners    map[string]Owner
+	starred   []int64 // Repository IDs, most recently starred first
+	renamed   map[string]int64
+	nextID    int64
+	failures  map[string][]int
+	empties   map[string]int
+	requests  int
+	versions  map[string]bool
+	sunsets   map[string]time.Time // Deprecated API versions
+}
+
+type repoState struct {
+	repo        Repo
+	commits     []Commit
+	issues      []Issue
+	pulls       []PullRequest
+	releases    []Release
+	deployments []Deployment
+}
+
+// NewServer starts a fake GitHub API server. Callers must Close it.
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		now:      time.Now,
+		limit:    defaultRateLimit,
+		window:   defaultRateWindow,
+		repos:    make(map[string]*repoState),
+		owners:   make(map[string]Owner),
+		renamed:  make(map[string]int64),
+		nextID:   1000,
+		failures: make(map[string][]int),
+		empties:  make(map[string]int),
+		versions: map[string]bool{defaultAPIVersion: true},
+		sunsets:  make(map[string]time.Time),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.remaining = s.limit
+	s.reset = s.now().Add(s.window)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/{owner}/{name}", s.handleRepo)
+	mux.HandleFunc("GET /repos/{owner}/{name}/commits", s.handleCommits)
+	mux.HandleFunc("GET /repos/{owner}/{name}/commits/{sha}", s.handleCommit)
+	mux.HandleFunc("GET /repos/{owner}/{name}/contents/{path...}", s.handleContents)
+	mux.HandleFunc("GET /repos/{owner}/{name}/issues", s.handleIssues)
+	mux.HandleFunc("GET /repos/{owner}/{name}/pulls", s.handlePulls)
+	mux.HandleFunc("GET /repos/{owner}/{name}/releases", s.handleReleases)
+	mux.HandleFunc("GET /repos/{owner}/{name}/deployments", s.handleDeployments)
+	mux.HandleFunc("GET /repos/{owner}/{name}/deployments/{deployment_id}/statuses", s.handleDeploymentStatuses)
+	mux.HandleFunc("GET /repos/{owner}/{name}/pulls/{number}", s.handlePull)
+	mux.HandleFunc("GET /repos/{owner}/{name}/pulls/{number}/reviews", s.handleReviews)
+	mux.HandleFunc("GET /repositories/{id}", s.handleRepo)
+	mux.HandleFunc("GET /repositories/{id}/commits", s.handleCommits)
+	mux.HandleFunc("GET /users/{login}", s.handleOwner)
+	mux.HandleFunc("GET /user/starred", s.handleStarred)
+	mux.HandleFunc("GET /rate_limit", s.handleRateLimit)
+	s.Server = httptest.NewServer(s.middleware(mux))
+	return s
+}
+
+// AddRepo adds or replaces a repository, keeping any commits already added
+func (s *Server) AddRepo(repo Repo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := s.repoLocked(repo.Owner, repo.Name)
+	if repo.ID == 0 {
+		repo.ID = state.repo.ID
+	}
+	state.repo = repo
+}
+
+// RenameRepo renames or transfers a repository. Like GitHub, requests for the
+// old owner/name are redirected to the repository's ID.
+func (s *Server) RenameRepo(owner, name, newOwner, newName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	oldKey := owner + "/" + name
+	state, ok := s.repos[oldKey]
+	if !ok {
+		return
+	}
+	delete(s.repos, oldKey)
+	state.repo.Owner = newOwner
+	state.repo.Name = newName
+	s.repos[newOwner+"/"+newName] = state
+	s.renamed[oldKey] = state.repo.ID
+}
+
+// AddCommits adds commits to a repository, creating it if necessary
+func (s *Server) AddCommits(owner, name string, commits ...Commit) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := s.repoLocked(owner, name)
+	state.commits = append(state.commits, commits...)
+	// GitHub lists commits newest first
+	sort.SliceStable(state.commits, func(i, j int) bool {
+		return state.commits[i].Date.After(state.commits[j].Date)
+	})
+}
+
+// AddIssues adds issues and pull requests to a repository, creating it if
+// necessary
+func (s *Server) AddIssues(owner, name string, issues ...Issue) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := s.repoLocked(owner, name)
+	state.issues = append(state.issues, issues...)
+}
+
+// AddPullRequests adds pull requests to a repository, creating it if
+// necessary. A pull request added again replaces the earlier one.
+func (s *Server) AddPullRequests(owner, name string, pulls ...PullRequest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := s.repoLocked(owner, name)
+	for _, pull := range pulls {
+		for i := range pull.Reviews {
+			if pull.Reviews[i].ID == 0 {
+				s.nextID++
+				pull.Reviews[i].ID = s.nextID
+			}
+		}
+		replaced := false
+		for i := range state.pulls {
+			if state.pulls[i].Number == pull.Number {
+				state.pulls[i], replaced = pull, true
+			}
+		}
+		if !replaced {
+			state.pulls = append(state.pulls, pull)
+		}
+	}
+}
+
+// AddReleases adds releases to a repository, creating it if necessary
+func (s *Server) AddReleases(owner, name string, releases ...Release) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := s.repoLocked(owner, name)
+	for _, release := range releases {
+		if release.ID == 0 {
+			s.nextID++
+			release.ID = s.nextID
+		}
+		state.releases = append(state.releases, release)
+	}
+	// GitHub lists releases newest first
+	sort.SliceStable(state.releases, func(i, j int) bool {
+		return state.releases[i].PublishedAt.After(state.releases[j].PublishedAt)
+	})
+}
+
+// AddDeployments adds deployments to a repository, creating it if
+// necessary. A deployment added again with the same ID replaces the earlier
+// one, e.g. to add statuses.
+func (s *Server) AddDeployments(owner, name string, deployments ...Deployment) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := s.repoLocked(owner, name)
+	for _, deployment := range deployments {
+		if deployment.ID == 0 {
+			s.nextID++
+			deployment.ID = s.nextID
+		}
+		replaced := false
+		for i := range state.deployments {
+			if state.deployments[i].ID == deployment.ID {
+				state.deployments[i], replaced = deployment, true
+			}
+		}
+		if !replaced {
+			state.deployments = append(state.deployments, deployment)
+		}
+	}
+	// GitHub lists deployments newest first
+	sort.SliceStable(state.deployments, func(i, j int) bool {
+		return state.deployments[i].CreatedAt.After(state.deployments[j].CreatedAt)
+	})
+}
+
+// AddOwner adds or replaces a user or organization
+func (s *Server) AddOwner(owner Owner) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if owner.ID == 0 {
+		s.nextID++
+		owner.ID = s.nextID
+	}
+	s.owners[strings.ToLower(owner.Login)] = owner
+}
+
+// Star stars repositories as the authenticated user, creating them if
+// necessary; like GitHub, the last starred is listed first
+func (s *Server) Star(repos ...Repo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, repo := range repos {
+		state := s.repoLocked(repo.Owner, repo.Name)
+		s.starred = append([]int64{state.repo.ID}, s.starred...)
+	}
+}
+
+// FailNext makes the next request for path (e.g. "/repos/octo/hello/commits")
+// fail with status. Repeated calls queue further failures.
+func (s *Server) FailNext(path string, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[path] = append(s.failures[path], status)
+}
+
+// EmptyNext answers the next request to path with an empty page and no Link
+// header, like a listing GitHub cut short under load
+func (s *Server) EmptyNext(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.empties[path]++
+}
+
+// ExhaustRateLimit uses up the remaining requests of the current window
+func (s *Server) ExhaustRateLimit() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remaining = 0
+}
+
+// Requests returns the number of requests received
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *Server) repoLocked(owner, name string) *repoState {
+	key := owner + "/" + name
+	state, ok := s.repos[key]
+	if !ok {
+		s.nextID++
+		state = &repoState{repo: Repo{ID: s.nextID, Owner: owner, Name: name}}
+		s.repos[key] = state
+		delete(s.renamed, key)
+	}
+	return state
+}
+
+// middleware applies authentication, rate limiting and injected failures in
+// the order GitHub does
+func (s *Server) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests++
+
+		// GitHub accepts both schemes
+		auth := r.Header.Get("Authorization")
+		if s.token != "" && auth != "Bearer "+s.token && auth != "token "+s.token {
+			s.mu.Unlock()
+			writeError(w, http.StatusUnauthorized, "Bad credentials")
+			return
+		}
+
+		version := r.Header.Get("X-GitHub-Api-Version")
+		if version == "" {
+			version = defaultAPIVersion
+		}
+		sunset, deprecated := s.sunsets[version]
+		if !s.versions[version] && !deprecated {
+			s.mu.Unlock()
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Unsupported 'X-GitHub-Api-Version' provided: %s", version))
+			return
+		}
+		w.Header().Set("X-GitHub-Api-Version-Selected", version)
+		if deprecated {
+			w.Header().Set("Deprecation", "true")
+			w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+		}
+
+		now := s.now()
+		if !now.Before(s.reset) {
+			s.remaining = s.limit
+			s.reset = now.Add(s.window)
+		}
+		// Like GitHub, checking the rate limit does not count against it
+		limited := s.remaining == 0 && r.URL.Path != "/rate_limit"
+		if !limited && r.URL.Path != "/rate_limit" {
+			s.remaining--
+		}
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(s.limit))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(s.remaining))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(s.reset.Unix(), 10))
+		w.Header().Set("X-RateLimit-Resource", "core")
+
+		status := 0
+		if queued := s.failures[r.URL.Path]; len(queued) > 0 {
+			status = queued[0]
+			s.failures[r.URL.Path] = queued[1:]
+		}
+		empty := status == 0 && s.empties[r.URL.Path] > 0
+		if empty {
+			s.empties[r.URL.Path]--
+		}
+		s.mu.Unlock()
+
+		switch {
+		case limited:
+			writeError(w, http.StatusForbidden, "API rate limit exceeded")
+		case status != 0:
+			writeError(w, status, http.StatusText(status))
+		case empty:
+			writeJSON(w, http.StatusOK, []interface{}{})
+		case strings.Contains(r.Header.Get("Accept-Encoding"), "gzip"):
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			defer gz.Close()
+			next.ServeHTTP(gzipResponseWriter{ResponseWriter: w, w: gz}, r)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// gzipResponseWriter compresses the bodies of successful responses, as GitHub
+// does for clients accepting gzip
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	w io.Writer
+}
+
+func (g gzipResponseWriter) Write(p []byte) (int, error) {
+	return g.w.Write(p)
+}
+
+func (s *Server) handleRateLimit(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	core := map[string]interface{}{
+		"limit":     s.limit,
+		"remaining": s.remaining,
+		"used":      s.limit - s.remaining,
+		"reset":     s.reset.Unix(),
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"resources": map[string]interface{}{"core": core},
+		"rate":      core,
+	})
+}
+
+// lookup finds the repository addressed by either owner/name or ID
+func (s *Server) lookup(r *http.Request) (*repoState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rawID := r.PathValue("id"); rawID != "" {
+		id, err := strconv.ParseInt(rawID, 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		for _, state := range s.repos {
+			if state.repo.ID == id {
+				return state, true
+			}
+		}
+		return nil, false
+	}
+
+	state, ok := s.repos[r.PathValue("owner")+"/"+r.PathValue("name")]
+	return state, ok
+}
+
+// redirectRenamed redirects requests for a renamed repository, reporting
+// whether it did
+func (s *Server) redirectRenamed(w http.ResponseWriter, r *http.Request, suffix string) bool {
+	s.mu.Lock()
+	id, ok := s.renamed[r.PathValue("owner")+"/"+r.PathValue("name")]
+	s.mu.Unlock()
+	if !ok {
+		return false
+	}
+
+	target := fmt.Sprintf("/repositories/%d%s", id, suffix)
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	w.Header().Set("Location", target)
+	writeError(w, http.StatusMovedPermanently, "Moved Permanently")
+	return true
+}
+
+func (s *Server) handleRepo(w http.ResponseWriter, r *http.Request) {
+	state, ok := s.lookup(r)
+	if !ok {
+		if !s.redirectRenamed(w, r, "") {
+			writeError(w, http.StatusNotFound, "Not Found")
+		}
+		return
+	}
+
+	s.mu.Lock()
+	repo := state.repo
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, repoBody(repo))
+}
+
+// handleStarred lists the repositories starred by the authenticated user
+func (s *Server) handleStarred(w http.ResponseWriter, r *http.Request) {
+	page, perPage, _, ok := listParams(w, r)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	var repos []Repo
+	for _, id := range s.starred {
+		for _, state := range s.repos {
+			if state.repo.ID == id {
+				repos = append(repos, state.repo)
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	start, end := paginate(w, r, len(repos), page, perPage)
+	body := make([]map[string]interface{}, 0, end-start)
+	for _, repo := range repos[start:end] {
+		body = append(body, repoBody(repo))
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+// repoBody is the repository object of the repository endpoints
+func repoBody(repo Repo) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                repo.ID,
+		"node_id":           nodeID(repo.ID),
+		"name":              repo.Name,
+		"full_name":         repo.Owner + "/" + repo.Name,
+		"owner":             map[string]string{"login": repo.Owner},
+		"description":       repo.Description,
+		"html_url":          "https://github.com/" + repo.Owner + "/" + repo.Name,
+		"language":          repo.Language,
+		"forks_count":       repo.Forks,
+		"stargazers_count":  repo.Stars,
+		"open_issues_count": repo.OpenIssues,
+		"watchers_count":    repo.Watchers,
+		"created_at":        repo.CreatedAt,
+		"updated_at":        repo.UpdatedAt,
+		"pushed_at":         repo.PushedAt,
+		"default_branch":    repo.defaultBranch(),
+	}
+}
+
+// handleOwner serves the profile of a user or organization; like GitHub,
+// logins match regardless of case
+func (s *Server) handleOwner(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	owner, ok := s.owners[strings.ToLower(r.PathValue("login"))]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	ownerType := "User"
+	if owner.Organization {
+		ownerType = "Organization"
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"id":           owner.ID,
+		"login":        owner.Login,
+		"type":         ownerType,
+		"name":         owner.Name,
+		"avatar_url":   owner.AvatarURL,
+		"public_repos": owner.PublicRepos,
+	})
+}
+
+func (s *Server) handleCommits(w http.ResponseWriter, r *http.Request) {
+	state, ok := s.lookup(r)
+	if !ok {
+		if !s.redirectRenamed(w, r, "/commits") {
+			writeError(w, http.StatusNotFound, "Not Found")
+		}
+		return
+	}
+
+	query := r.URL.Query()
+	page, perPage, since, ok := listParams(w, r)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	repo := state.repo
+	branch := query.Get("sha")
+	if branch == "" {
+		branch = repo.defaultBranch()
+	}
+	var matching []Commit
+	for _, c := range state.commits {
+		if c.Branch != "" && c.Branch != branch {
+			continue
+		}
+		if since.IsZero() || !c.committed().Before(since) {
+			matching = append(matching, c)
+		}
+	}
+	s.mu.Unlock()
+
+	start, end := paginate(w, r, len(matching), page, perPage)
+	body := make([]map[string]interface{}, 0, end-start)
+	for _, c := range matching[start:end] {
+		body = append(body, commitBody(r, repo, c))
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+// commitBody renders a commit the way GitHub lists it
+func commitBody(r *http.Request, repo Repo, c Commit) map[string]interface{} {
+	return map[string]interface{}{
+		"sha": c.SHA,
+		"commit": map[string]interface{}{
+			"message": c.Message,
+			"author": map[string]interface{}{
+				"name":  c.AuthorName,
+				"email": c.AuthorEmail,
+				"date":  c.Date,
+			},
+			"committer": map[string]interface{}{
+				"name":  c.AuthorName,
+				"email": c.AuthorEmail,
+				"date":  c.committed(),
+			},
+		},
+		"url":      fmt.Sprintf("http://%s/repos/%s/%s/commits/%s", r.Host, repo.Owner, repo.Name, c.SHA),
+		"html_url": fmt.Sprintf("https://github.com/%s/%s/commit/%s", repo.Owner, repo.Name, c.SHA),
+		"parents":  parentRefs(r, repo, c.Parents),
+	}
+}
+
+// handleCommit serves a single commit with the files it touched, addressed
+// by its SHA or the tag of a release
+func (s *Server) handleCommit(w http.ResponseWriter, r *http.Request) {
+	state, ok := s.lookup(r)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	sha := r.PathValue("sha")
+	s.mu.Lock()
+	repo := state.repo
+	for _, release := range state.releases {
+		if release.TagName == sha {
+			sha = release.SHA
+		}
+	}
+	var body map[string]interface{}
+	for _, c := range state.commits {
+		if c.SHA == sha {
+			body = commitBody(r, repo, c)
+			files := []map[string]interface{}{}
+			for _, path := range c.Files {
+				files = append(files, map[string]interface{}{"filename": path, "status": "modified"})
+			}
+			body["files"] = files
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	if body == nil {
+		writeError(w, http.StatusUnprocessableEntity, "No commit found for SHA: "+sha)
+		return
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+// handleIssues lists the issues and pull requests of a repository. Only the
+// state, since, sort=updated and direction parameters are supported.
+func (s *Server) handleIssues(w http.ResponseWriter, r *http.Request) {
+	state, ok := s.lookup(r)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	page, perPage, since, ok := listParams(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	if sortBy := query.Get("sort"); sortBy != "" && sortBy != "updated" {
+		writeError(w, http.StatusUnprocessableEntity, "Unsupported sort "+sortBy)
+		return
+	}
+	wantState := query.Get("state")
+	if wantState == "" {
+		wantState = "open"
+	}
+
+	s.mu.Lock()
+	repo := state.repo
+	var matching []Issue
+	for _, i := range state.issues {
+		open := i.ClosedAt.IsZero()
+		if (wantState == "open" && !open) || (wantState == "closed" && open) {
+			continue
+		}
+		if since.IsZero() || !i.updated().Before(since) {
+			matching = append(matching, i)
+		}
+	}
+	s.mu.Unlock()
+
+	sort.SliceStable(matching, func(a, b int) bool {
+		if query.Get("direction") == "asc" {
+			return matching[a].updated().Before(matching[b].updated())
+		}
+		return matching[a].updated().After(matching[b].updated())
+	})
+
+	start, end := paginate(w, r, len(matching), page, perPage)
+	body := make([]map[string]interface{}, 0, end-start)
+	for _, i := range matching[start:end] {
+		issueState, closedAt := "open", interface{}(nil)
+		if !i.ClosedAt.IsZero() {
+			issueState, closedAt = "closed", i.ClosedAt
+		}
+		labels := []map[string]string{}
+		for _, label := range i.Labels {
+			labels = append(labels, map[string]string{"name": label})
+		}
+		issue := map[string]interface{}{
+			"number":     i.Number,
+			"title":      i.Title,
+			"state":      issueState,
+			"user":       map[string]string{"login": i.Author},
+			"labels":     labels,
+			"created_at": i.CreatedAt,
+			"updated_at": i.updated(),
+			"closed_at":  closedAt,
+			"html_url":   fmt.Sprintf("https://github.com/%s/%s/issues/%d", repo.Owner, repo.Name, i.Number),
+		}
+		if i.PullRequest {
+			issue["pull_request"] = map[string]string{
+				"html_url": fmt.Sprintf("https://github.com/%s/%s/pull/%d", repo.Owner, repo.Name, i.Number),
+			}
+		}
+		body = append(body, issue)
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+// handlePulls lists the pull requests of a repository. Only the state,
+// sort=updated and direction parameters are supported.
+func (s *Server) handlePulls(w http.ResponseWriter, r *http.Request) {
+	state, ok := s.lookup(r)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	page, perPage, _, ok := listParams(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	if sortBy := query.Get("sort"); sortBy != "" && sortBy != "updated" {
+		writeError(w, http.StatusUnprocessableEntity, "Unsupported sort "+sortBy)
+		return
+	}
+	wantState := query.Get("state")
+	if wantState == "" {
+		wantState = "open"
+	}
+
+	s.mu.Lock()
+	repo := state.repo
+	var matching []PullRequest
+	for _, p := range state.pulls {
+		open := p.ClosedAt.IsZero()
+		if (wantState == "open" && !open) || (wantState == "closed" && open) {
+			continue
+		}
+		matching = append(matching, p)
+	}
+	s.mu.Unlock()
+
+	sort.SliceStable(matching, func(a, b int) bool {
+		if query.Get("direction") == "asc" {
+			return matching[a].updated().Before(matching[b].updated())
+		}
+		return matching[a].updated().After(matching[b].updated())
+	})
+
+	start, end := paginate(w, r, len(matching), page, perPage)
+	body := make([]map[string]interface{}, 0, end-start)
+	for _, p := range matching[start:end] {
+		body = append(body, pullBody(repo, p))
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+// handlePull serves a single pull request with the size of its change
+func (s *Server) handlePull(w http.ResponseWriter, r *http.Request) {
+	repo, pull, ok := s.lookupPull(r)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	body := pullBody(repo, pull)
+	body["additions"] = pull.Additions
+	body["deletions"] = pull.Deletions
+	body["changed_files"] = pull.ChangedFiles
+	writeJSON(w, http.StatusOK, body)
+}
+
+// handleReviews lists the reviews of a pull request, oldest first
+func (s *Server) handleReviews(w http.ResponseWriter, r *http.Request) {
+	_, pull, ok := s.lookupPull(r)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	page, perPage, _, ok := listParams(w, r)
+	if !ok {
+		return
+	}
+
+	reviews := append([]Review(nil), pull.Reviews...)
+	sort.SliceStable(reviews, func(a, b int) bool {
+		return reviews[a].SubmittedAt.Before(reviews[b].SubmittedAt)
+	})
+	start, end := paginate(w, r, len(reviews), page, perPage)
+	body := make([]map[string]interface{}, 0, end-start)
+	for _, review := range reviews[start:end] {
+		body = append(body, map[string]interface{}{
+			"id":           review.ID,
+			"user":         map[string]string{"login": review.Reviewer},
+			"state":        review.State,
+			"submitted_at": review.SubmittedAt,
+		})
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+// lookupPull finds the pull request addressed by a request
+func (s *Server) lookupPull(r *http.Request) (Repo, PullRequest, bool) {
+	state, ok := s.lookup(r)
+	if !ok {
+		return Repo{}, PullRequest{}, false
+	}
+	number, err := strconv.Atoi(r.PathValue("number"))
+	if err != nil {
+		return Repo{}, PullRequest{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range state.pulls {
+		if p.Number == number {
+			return state.repo, p, true
+		}
+	}
+	return Repo{}, PullRequest{}, false
+}
+
+// pullBody renders a pull request the way GitHub lists it
+func pullBody(repo Repo, p PullRequest) map[string]interface{} {
+	pullState, closedAt, mergedAt := "open", interface{}(nil), interface{}(nil)
+	if !p.ClosedAt.IsZero() {
+		pullState, closedAt = "closed", p.ClosedAt
+	}
+	if !p.MergedAt.IsZero() {
+		mergedAt = p.MergedAt
+	}
+	return map[string]interface{}{
+		"number":     p.Number,
+		"title":      p.Title,
+		"state":      pullState,
+		"draft":      p.Draft,
+		"user":       map[string]string{"login": p.Author},
+		"created_at": p.CreatedAt,
+		"updated_at": p.updated(),
+		"closed_at":  closedAt,
+		"merged_at":  mergedAt,
+		"html_url":   fmt.Sprintf("https://github.com/%s/%s/pull/%d", repo.Owner, repo.Name, p.Number),
+	}
+}
+
+// handleReleases lists the releases of a repository, newest first
+func (s *Server) handleReleases(w http.ResponseWriter, r *http.Request) {
+	state, ok := s.lookup(r)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	page, perPage, _, ok := listParams(w, r)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	repo := state.repo
+	releases := append([]Release(nil), state.releases...)
+	s.mu.Unlock()
+
+	start, end := paginate(w, r, len(releases), page, perPage)
+	body := make([]map[string]interface{}, 0, end-start)
+	for _, release := range releases[start:end] {
+		body = append(body, map[string]interface{}{
+			"id":               release.ID,
+			"tag_name":         release.TagName,
+			"target_commitish": repo.defaultBranch(),
+			"name":             release.Name,
+			"draft":            false,
+			"prerelease":       release.Prerelease,
+			"created_at":       release.PublishedAt,
+			"published_at":     release.PublishedAt,
+			"html_url":         fmt.Sprintf("https://github.com/%s/%s/releases/tag/%s", repo.Owner, repo.Name, release.TagName),
+		})
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+// handleDeployments lists the deployments of a repository, newest first.
+// Only the environment filter is supported.
+func (s *Server) handleDeployments(w http.ResponseWriter, r *http.Request) {
+	state, ok := s.lookup(r)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	page, perPage, _, ok := listParams(w, r)
+	if !ok {
+		return
+	}
+	environment := r.URL.Query().Get("environment")
+
+	s.mu.Lock()
+	var matching []Deployment
+	for _, d := range state.deployments {
+		if environment == "" || d.Environment == environment {
+			matching = append(matching, d)
+		}
+	}
+	s.mu.Unlock()
+
+	start, end := paginate(w, r, len(matching), page, perPage)
+	body := make([]map[string]interface{}, 0, end-start)
+	for _, d := range matching[start:end] {
+		body = append(body, map[string]interface{}{
+			"id":          d.ID,
+			"sha":         d.SHA,
+			"ref":         d.Ref,
+			"environment": d.Environment,
+			"creator":     map[string]string{"login": d.Creator},
+			"created_at":  d.CreatedAt,
+			"updated_at":  d.CreatedAt,
+		})
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+// handleDeploymentStatuses lists the statuses of a deployment, newest first
+func (s *Server) handleDeploymentStatuses(w http.ResponseWriter, r *http.Request) {
+	state, ok := s.lookup(r)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	id, err := strconv.ParseInt(r.PathValue("deployment_id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	page, perPage, _, ok := listParams(w, r)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	var statuses []DeploymentStatus
+	found := false
+	for _, d := range state.deployments {
+		if d.ID == id {
+			statuses, found = append([]DeploymentStatus(nil), d.Statuses...), true
+		}
+	}
+	s.mu.Unlock()
+	if !found {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	sort.SliceStable(statuses, func(a, b int) bool {
+		return statuses[a].CreatedAt.After(statuses[b].CreatedAt)
+	})
+	start, end := paginate(w, r, len(statuses), page, perPage)
+	body := make([]map[string]interface{}, 0, end-start)
+	for _, status := range statuses[start:end] {
+		body = append(body, map[string]interface{}{
+			"state":      status.State,
+			"log_url":    status.LogURL,
+			"created_at": status.CreatedAt,
+		})
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+// handleContents serves a file of the default branch, base64-encoded
+func (s *Server) handleContents(w http.ResponseWriter, r *http.Request) {
+	state, ok := s.lookup(r)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	path := r.PathValue("path")
+	s.mu.Lock()
+	content, ok := state.repo.Files[path]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"type":     "file",
+		"path":     path,
+		"size":     len(content),
+		"encoding": "base64",
+		"content":  base64.StdEncoding.EncodeToString([]byte(content)),
+	})
+}
+
+// parentRefs lists parent commits the way GitHub does
+func parentRefs(r *http.Request, repo Repo, parents []string) []map[string]string {
+	refs := make([]map[string]string, 0, len(parents))
+	for _, sha := range parents {
+		refs = append(refs, map[string]string{
+			"sha": sha,
+			"url": fmt.Sprintf("http://%s/repos/%s/%s/commits/%s", r.Host, repo.Owner, repo.Name, sha),
+		})
+	}
+	return refs
+}
+
+// listParams parses the page, per_page and since parameters of a listing,
+// answering the request with an error when they are invalid
+func listParams(w http.ResponseWriter, r *http.Request) (page, perPage int, since time.Time, ok bool) {
+	query := r.URL.Query()
+	page, err := positiveInt(query.Get("page"), 1)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return 0, 0, time.Time{}, false
+	}
+	perPage, err = positiveInt(query.Get("per_page"), defaultPerPage)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return 0, 0, time.Time{}, false
+	}
+	if perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+	if raw := query.Get("since"); raw != "" {
+		if since, err = time.Parse(time.RFC3339, raw); err != nil {
+			writeError(w, http.StatusUnprocessableEntity, "Invalid since timestamp")
+			return 0, 0, time.Time{}, false
+		}
+	}
+	return page, perPage, since, true
+}
+
+// paginate returns the bounds of a page of n items and links the next one
+func paginate(w http.ResponseWriter, r *http.Request, n, page, perPage int) (start, end int) {
+	lastPage := (n + perPage - 1) / perPage
+	start = (page - 1) * perPage
+	end = start + perPage
+	if start > n {
+		start = n
+	}
+	if end > n {
+		end = n
+	}
+	if page < lastPage {
+		w.Header().Set("Link", linkHeader(r, page+1, lastPage))
+	}
+	return start, end
+}
+
+// linkHeader builds a GitHub style Link header pointing at the next and last pages
+func linkHeader(r *http.Request, next, last int) string {
+	pageURL := func(page int) string {
+		u := url.URL{Scheme: "http", Host: r.Host, Path: r.URL.Path}
+		q := r.URL.Query()
+		q.Set("page", strconv.Itoa(page))
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+	return strings.Join([]string{
+		fmt.Sprintf(`<%s>; rel="next"`, pageURL(next)),
+		fmt.Sprintf(`<%s>; rel="last"`, pageURL(last)),
+	}, ", ")
+}
+
+// nodeID derives a stable GraphQL node ID from a repository ID
+func nodeID(id int64) string {
+	return base64.RawStdEncoding.EncodeToString([]byte(fmt.Sprintf("010:Repository%d", id)))
+}
+
+func positiveInt(raw string, def int) (int, error) {
+	if raw == "" {
+		return def, nil
+	}
+	val, err := strconv.Atoi(raw)
+	if err != nil || val < 1 {
+		return 0, fmt.Errorf("invalid value %q", raw)
+	}
+	return val, nil
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{
+		"message":           msg,
+		"documentation_url": "https://docs.github.com/rest",
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,61 @@
+package githubtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitsPagination(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		srv.AddCommits("octo", "hello", Commit{SHA: string(rune('a' + i)), Date: now.Add(time.Duration(i) * time.Minute)})
+	}
+
+	resp, err := http.Get(srv.URL + "/repos/octo/hello/commits?per_page=2&page=2")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var body []struct {
+		SHA string `json:"sha"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	// Newest first: e d | c b | a
+	require.Len(t, body, 2)
+	assert.Equal(t, "c", body[0].SHA)
+	assert.Contains(t, resp.Header.Get("Link"), `page=3&per_page=2>; rel="next"`)
+	assert.Equal(t, "5000", resp.Header.Get("X-RateLimit-Limit"))
+	assert.Equal(t, "4999", resp.Header.Get("X-RateLimit-Remaining"))
+}
+
+func TestRateLimitAndFailures(t *testing.T) {
+	clock := time.Now()
+	srv := NewServer(WithRateLimit(2, time.Minute), WithClock(func() time.Time { return clock }))
+	defer srv.Close()
+	srv.AddRepo(Repo{Owner: "octo", Name: "hello"})
+	srv.FailNext("/repos/octo/hello", http.StatusInternalServerError)
+
+	statuses := make([]int, 0, 3)
+	for i := 0; i < 3; i++ {
+		resp, err := http.Get(srv.URL + "/repos/octo/hello")
+		require.NoError(t, err)
+		resp.Body.Close()
+		statuses = append(statuses, resp.StatusCode)
+	}
+	assert.Equal(t, []int{http.StatusInternalServerError, http.StatusOK, http.StatusForbidden}, statuses)
+
+	// The window resets once the clock passes X-RateLimit-Reset
+	clock = clock.Add(time.Minute)
+	resp, err := http.Get(srv.URL + "/repos/octo/hello")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 4, srv.Requests())
+}
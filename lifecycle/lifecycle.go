@@ -0,0 +1,205 @@
+// Package lifecycle runs the long-running components of the service under
+// supervision. A component that fails, or panics, is restarted after a
+// backoff or, when it may not be restarted, stops the whole service with its
+// error; on shutdown the components are stopped one at a time, the last
+// added first.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"githubapifetch/backoff"
+	"githubapifetch/clock"
+	"githubapifetch/logger"
+	"githubapifetch/metrics"
+)
+
+// DefaultStopTimeout is how long shutdown waits for a component to stop
+const DefaultStopTimeout = 30 * time.Second
+
+// Component is a long-running part of the service
+type Component struct {
+	// Name identifies the component in logs, errors and metrics
+	Name string
+	// Run runs the component until ctx is done. Returning before that is
+	// only a failure when it returns an error.
+	Run func(ctx context.Context) error
+	// Stop, if set, is called at shutdown for components that do not stop
+	// when their context is done, such as an HTTP server
+	Stop func(ctx context.Context) error
+	// Restart runs the component again after it fails, rather than
+	// stopping the service
+	Restart bool
+}
+
+// Options configures a Supervisor
+type Options struct {
+	// Backoff spaces out the restarts of a failing component; the count of
+	// restarts resets once the component ran for a minute
+	Backoff backoff.Backoff
+	// StopTimeout bounds how long shutdown waits for each component;
+	// DefaultStopTimeout when zero
+	StopTimeout time.Duration
+	Clock       clock.Clock
+}
+
+// Supervisor runs components and stops them in order
+type Supervisor struct {
+	opts       Options
+	components []Component
+}
+
+// stableRun is how long a component must run before its next failure counts
+// as the first again
+const stableRun = time.Minute
+
+// New returns a supervisor without components
+func New(opts Options) *Supervisor {
+	if opts.Backoff == nil {
+		opts.Backoff = backoff.Exponential{Base: time.Second, Max: time.Minute}
+	}
+	if opts.StopTimeout <= 0 {
+		opts.StopTimeout = DefaultStopTimeout
+	}
+	if opts.Clock == nil {
+		opts.Clock = clock.Real
+	}
+	return &Supervisor{opts: opts}
+}
+
+// Add adds a component, which starts after and stops before those added
+// earlier
+func (s *Supervisor) Add(c Component) {
+	s.components = append(s.components, c)
+}
+
+// ErrAbandoned is wrapped by the error of Run for each component that did
+// not stop within the stop timeout and was left running
+var ErrAbandoned = errors.New("component did not stop in time")
+
+// Run starts every component and blocks until ctx is done or a component
+// that may not be restarted fails, then stops them all, the last added
+// first. It returns the error of the component that failed, if any, joined
+// with an ErrAbandoned error for every component that would not stop. Run
+// does not wait for abandoned components, so one ignoring its context
+// cannot keep the process from exiting.
+func (s *Supervisor) Run(ctx context.Context) error {
+	failed, fail := context.WithCancel(ctx)
+	defer fail()
+	var mu sync.Mutex
+	var failure error
+
+	type running struct {
+		cancel context.CancelFunc
+		done   chan struct{}
+	}
+	runs := make([]running, len(s.components))
+	for i, c := range s.components {
+		// Components are stopped one by one, not all at once with ctx
+		cctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		done := make(chan struct{})
+		runs[i] = running{cancel: cancel, done: done}
+		go func() {
+			defer close(done)
+			if err := s.supervise(cctx, c); err != nil {
+				mu.Lock()
+				if failure == nil {
+					failure = err
+				}
+				mu.Unlock()
+				fail()
+			}
+		}()
+	}
+
+	<-failed.Done()
+	var abandoned []error
+	for i := len(s.components) - 1; i >= 0; i-- {
+		if !s.stop(s.components[i], runs[i].cancel, runs[i].done) {
+			abandoned = append(abandoned, fmt.Errorf("%s: %w", s.components[i].Name, ErrAbandoned))
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	return errors.Join(append([]error{failure}, abandoned...)...)
+}
+
+// stop stops one component and waits for it to return, at most for the stop
+// timeout. It reports whether the component returned.
+func (s *Supervisor) stop(c Component, cancel context.CancelFunc, done <-chan struct{}) bool {
+	logger.Info("Stopping component", zap.String("component", c.Name))
+	stopCtx, cancelStop := context.WithTimeout(context.Background(), s.opts.StopTimeout)
+	defer cancelStop()
+	cancel()
+	if c.Stop != nil {
+		if err := c.Stop(stopCtx); err != nil {
+			logger.Warn("Failed to stop component", zap.String("component", c.Name), zap.Error(err))
+		}
+	}
+	select {
+	case <-done:
+		return true
+	case <-stopCtx.Done():
+		metrics.ComponentsAbandoned.Add(c.Name, 1)
+		logger.Warn("Component did not stop in time, abandoning it", zap.String("component", c.Name),
+			zap.Duration("timeout", s.opts.StopTimeout))
+		return false
+	}
+}
+
+// supervise runs a component until ctx is done, restarting it after
+// failures when it may be restarted
+func (s *Supervisor) supervise(ctx context.Context, c Component) error {
+	var delay time.Duration
+	for restarts := 0; ; {
+		started := s.opts.Clock.Now()
+		err := runSafely(ctx, c)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err == nil {
+			logger.Info("Component finished", zap.String("component", c.Name))
+			return nil
+		}
+		metrics.ComponentFailures.Add(c.Name, 1)
+		if !c.Restart {
+			logger.Error("Component failed", zap.String("component", c.Name), zap.Error(err))
+			return fmt.Errorf("%s: %w", c.Name, err)
+		}
+
+		if s.opts.Clock.Now().Sub(started) >= stableRun {
+			restarts, delay = 0, 0
+		}
+		restarts++
+		delay = s.opts.Backoff.Delay(restarts, delay)
+		logger.Error("Component failed, restarting",
+			zap.String("component", c.Name),
+			zap.Error(err),
+			zap.Int("restarts", restarts),
+			zap.Duration("delay", delay))
+		if err := s.opts.Clock.Sleep(ctx, delay); err != nil {
+			return nil
+		}
+	}
+}
+
+// ErrPanic is wrapped by the error of a component that panicked
+var ErrPanic = errors.New("component panicked")
+
+// runSafely runs a component, turning a panic into an error
+func runSafely(ctx context.Context, c Component) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v\n%s", ErrPanic, r, debug.Stack())
+		}
+	}()
+	return c.Run(ctx)
+}
@@ -0,0 +1,114 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"githubapifetch/backoff"
+)
+
+// recorder records the order components stop in
+type recorder struct {
+	mu      sync.Mutex
+	stopped []string
+}
+
+func (r *recorder) record(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stopped = append(r.stopped, name)
+}
+
+// blocking returns a component running until its context is done
+func (r *recorder) blocking(name string) Component {
+	return Component{Name: name, Run: func(ctx context.Context) error {
+		<-ctx.Done()
+		r.record(name)
+		return nil
+	}}
+}
+
+func TestSupervisorRestartsFailedComponents(t *testing.T) {
+	sup := New(Options{Backoff: backoff.Constant{}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	runs := 0
+	sup.Add(Component{Name: "flaky", Restart: true, Run: func(ctx context.Context) error {
+		runs++
+		switch runs {
+		case 1:
+			return errors.New("connection reset")
+		case 2:
+			panic("nil map")
+		}
+		cancel()
+		<-ctx.Done()
+		return nil
+	}})
+
+	require.NoError(t, sup.Run(ctx))
+	assert.Equal(t, 3, runs)
+}
+
+func TestSupervisorStopsInReverseOrder(t *testing.T) {
+	r := &recorder{}
+	sup := New(Options{})
+	sup.Add(r.blocking("sink"))
+	sup.Add(r.blocking("monitor"))
+	stopAPI := make(chan struct{})
+	sup.Add(Component{
+		Name: "api",
+		// Like an HTTP server, only Stop ends it
+		Run: func(context.Context) error {
+			<-stopAPI
+			return nil
+		},
+		Stop: func(context.Context) error {
+			r.record("api")
+			close(stopAPI)
+			return nil
+		},
+	})
+	sup.Add(Component{Name: "listener", Run: func(context.Context) error {
+		return errors.New("address already in use")
+	}})
+
+	err := sup.Run(context.Background())
+	assert.EqualError(t, err, "listener: address already in use")
+	assert.Equal(t, []string{"api", "monitor", "sink"}, r.stopped)
+}
+
+func TestSupervisorAbandonsComponentsThatDoNotStop(t *testing.T) {
+	r := &recorder{}
+	sup := New(Options{StopTimeout: 10 * time.Millisecond})
+	sup.Add(r.blocking("sink"))
+	hung := make(chan struct{})
+	defer close(hung)
+	sup.Add(Component{Name: "stuck", Run: func(context.Context) error {
+		// Ignores its context
+		<-hung
+		return nil
+	}})
+	sup.Add(r.blocking("monitor"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	done := make(chan error, 1)
+	go func() { done <- sup.Run(ctx) }()
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, ErrAbandoned)
+		assert.EqualError(t, err, "stuck: component did not stop in time")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run waited for a component that never returns")
+	}
+	// The components after it are still stopped in order
+	assert.Equal(t, []string{"monitor", "sink"}, r.stopped)
+}
@@ -0,0 +1,133 @@
+// Package metrics publishes the service's counters and gauges through expvar.
+package metrics
+
+import (
+	"expvar"
+	"net/http"
+)
+
+// GitHub API response metrics
+var (
+	// GitHubResponses counts responses received from GitHub
+	GitHubResponses = expvar.NewInt("github_responses")
+	// GitHubResponseBytes sums response body sizes, keyed "wire" for the
+	// bytes transferred and "decoded" for the bytes after decompression
+	GitHubResponseBytes = expvar.NewMap("github_response_bytes")
+	// GitHubResponseMillis sums the time from sending a request to reading
+	// the end of its response
+	GitHubResponseMillis = expvar.NewInt("github_response_time_ms")
+	// GitHubErrors counts failed API requests, keyed by status code, or
+	// "rate_limited" when retries ran out
+	GitHubErrors = expvar.NewMap("github_errors")
+	// GitHubRateLimitRemaining is the number of requests left in the
+	// current rate limit window and GitHubRateLimitReset the Unix time the
+	// window resets at, as the last response reported them, keyed by rate
+	// limit resource, e.g. "core" or "search"
+	GitHubRateLimitRemaining = expvar.NewMap("github_rate_limit_remaining")
+	GitHubRateLimitReset     = expvar.NewMap("github_rate_limit_reset")
+)
+
+// Ingestion metrics
+var (
+	// CommitsRejected counts commits that failed to insert and were set
+	// aside in rejected_commits
+	CommitsRejected = expvar.NewInt("commits_rejected")
+	// CommitsInvalid counts commits dropped by validation before insert,
+	// keyed by the offending field
+	CommitsInvalid = expvar.NewMap("commits_invalid")
+	// CommitsTruncated counts commits stored with a truncated message
+	CommitsTruncated = expvar.NewInt("commits_truncated")
+	// CommitsSanitized counts commits stored with NUL bytes or invalid
+	// UTF-8 removed
+	CommitsSanitized = expvar.NewInt("commits_sanitized")
+)
+
+// Database metrics
+var (
+	// DBInsertRows counts commits written by batch inserts, and
+	// DBInsertMillis sums the time those inserts took
+	DBInsertRows   = expvar.NewInt("db_insert_rows")
+	DBInsertMillis = expvar.NewInt("db_insert_time_ms")
+)
+
+// Monitoring metrics
+var (
+	// MonitorCycles counts finished monitoring cycles, across tenants, and
+	// MonitorCycleMillis is how long the last one took
+	MonitorCycles      = expvar.NewInt("monitor_cycles")
+	MonitorCycleMillis = expvar.NewInt("monitor_cycle_time_ms")
+	// MonitorRepositories counts repositories due for a check, keyed
+	// "checked", what came of them, "processed" or "failed", and
+	// "backfilled" for those synced from their start date for lack of
+	// stored commits
+	MonitorRepositories = expvar.NewMap("monitor_repositories")
+)
+
+// Lifecycle metrics
+var (
+	// ComponentFailures counts how often a supervised component failed or
+	// panicked, keyed by component name
+	ComponentFailures = expvar.NewMap("component_failures")
+	// ComponentsAbandoned counts components left running at shutdown after
+	// they did not stop in time, keyed by component name
+	ComponentsAbandoned = expvar.NewMap("components_abandoned")
+)
+
+// Analytical sink metrics
+var (
+	// SinkRowsWritten counts commits written to the analytical sink
+	SinkRowsWritten = expvar.NewInt("sink_rows_written")
+	// SinkRowsDropped counts commits never written to the analytical sink,
+	// because its buffer was full or every attempt failed
+	SinkRowsDropped = expvar.NewInt("sink_rows_dropped")
+)
+
+// Spool metrics
+var (
+	// SpoolBytes is the size of the commit pages spooled while the database
+	// was unavailable and not yet stored
+	SpoolBytes = expvar.NewInt("spool_bytes")
+)
+
+// Metrics keyed by "owner/name"
+var (
+	// PagesFetched counts commit pages fetched from GitHub
+	PagesFetched = expvar.NewMap("github_pages_fetched")
+	// CommitsFetched counts commits fetched from GitHub
+	CommitsFetched = expvar.NewMap("github_commits_fetched")
+	// PagesRemaining is the number of commit pages a running fetch has left,
+	// as announced by GitHub's Link header
+	PagesRemaining = expvar.NewMap("sync_pages_remaining")
+	// SyncFailures counts failed syncs; a successful sync does not reset it
+	SyncFailures = expvar.NewMap("sync_failures")
+	// PartialSyncs counts syncs whose commit listing ended before its last
+	// page
+	PartialSyncs = expvar.NewMap("partial_syncs")
+	// PagesSpooled counts commit pages spooled because the database was
+	// unavailable
+	PagesSpooled = expvar.NewMap("sync_pages_spooled")
+	// Quarantines counts how often a repository was quarantined
+	Quarantines = expvar.NewMap("repository_quarantines")
+	// SyncLagSeconds is how far a repository's stored commits are behind
+	// GitHub, and SyncLagEWMASeconds its exponentially weighted average
+	SyncLagSeconds     = expvar.NewMap("sync_lag_seconds")
+	SyncLagEWMASeconds = expvar.NewMap("sync_lag_ewma_seconds")
+	// SyncLagBreaches counts how often the average lag breached the SLO
+	SyncLagBreaches = expvar.NewMap("sync_lag_slo_breaches")
+	// DefaultBranchChanges counts how often GitHub reported a new default
+	// branch for a repository
+	DefaultBranchChanges = expvar.NewMap("default_branch_changes")
+	// CommitDriftPercent is the share of sampled commits that the last
+	// drift check found missing on GitHub or differing from it
+	CommitDriftPercent = expvar.NewMap("commit_drift_percent")
+)
+
+// RepoKey returns the key a repository's metrics are recorded under
+func RepoKey(owner, name string) string {
+	return owner + "/" + name
+}
+
+// Handler serves all published metrics as JSON
+func Handler() http.Handler {
+	return expvar.Handler()
+}
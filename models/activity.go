@@ -0,0 +1,83 @@
+package models
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Kinds of activity feed events
+const (
+	ActivityCommit            = "commit"
+	ActivityRelease           = "release"
+	ActivityPullRequestMerged = "pull_request_merged"
+)
+
+// ActivityEvent is something that happened in a tracked repository: a
+// commit landing, a release being published or a pull request being merged
+type ActivityEvent struct {
+	Kind      string    `db:"kind" json:"kind"`
+	At        time.Time `db:"at" json:"at"`
+	RepoOwner string    `db:"repo_owner" json:"repo_owner"`
+	RepoName  string    `db:"repo_name" json:"repo"`
+	// Ref is the commit SHA, the release tag or the pull request number
+	Ref string `db:"ref" json:"ref"`
+	// Title is the first line of the commit message, the release name or
+	// the pull request title
+	Title string `db:"title" json:"title"`
+	// Actor is the commit author or the pull request author; releases have
+	// none
+	Actor string `db:"actor" json:"actor,omitempty"`
+	URL   string `db:"url" json:"url,omitempty"`
+	// Key orders events at the same instant
+	Key string `db:"key" json:"-"`
+}
+
+// ActivityPage is a page of the activity feed, newest first. NextCursor
+// reads the page after it, and is empty on the last one.
+type ActivityPage struct {
+	Events     []ActivityEvent `json:"events"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// ActivityCursor is where a page of the activity feed ends; the zero value
+// starts at the newest event
+type ActivityCursor struct {
+	At  time.Time
+	Key string
+}
+
+// IsZero reports whether the cursor starts at the newest event
+func (c ActivityCursor) IsZero() bool {
+	return c.At.IsZero() && c.Key == ""
+}
+
+// String encodes the cursor for clients to pass back as is
+func (c ActivityCursor) String() string {
+	if c.IsZero() {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(c.At.UTC().Format(time.RFC3339Nano) + "|" + c.Key))
+}
+
+// ParseActivityCursor decodes a cursor String returned, the zero cursor for
+// an empty one
+func ParseActivityCursor(s string) (ActivityCursor, error) {
+	if s == "" {
+		return ActivityCursor{}, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return ActivityCursor{}, fmt.Errorf("invalid cursor %q", s)
+	}
+	at, key, ok := strings.Cut(string(raw), "|")
+	if !ok || key == "" {
+		return ActivityCursor{}, fmt.Errorf("invalid cursor %q", s)
+	}
+	t, err := time.Parse(time.RFC3339Nano, at)
+	if err != nil {
+		return ActivityCursor{}, fmt.Errorf("invalid cursor %q", s)
+	}
+	return ActivityCursor{At: t, Key: key}, nil
+}
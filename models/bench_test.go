@@ -0,0 +1,41 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// BenchmarkCommitPrepare measures the per-commit work between decoding and
+// inserting: canonicalizing URLs, validating and sanitizing. Dirty messages
+// carry NUL bytes and invalid UTF-8 and are truncated.
+func BenchmarkCommitPrepare(b *testing.B) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	messages := map[string]string{
+		"clean": "Commit message\n\nWith a body long enough to matter",
+		"dirty": strings.Repeat("Commit \x00message \xff", 500),
+	}
+
+	for _, name := range []string{"clean", "dirty"} {
+		message := messages[name]
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				sha := fmt.Sprintf("%040x", i)
+				c := Commit{
+					SHA:        sha,
+					Message:    message,
+					AuthorName: "Octo Cat",
+					Date:       now.Add(-time.Hour),
+					URL:        CanonicalURL("https://github.com/octo/hello/commit/" + sha),
+					APIURL:     CanonicalURL("https://api.github.com/repos/octo/hello/commits/" + sha),
+				}
+				if err := c.Validate(now); err != nil {
+					b.Fatal(err)
+				}
+				c.Sanitize(4096)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// weekdays names the days of the week as calendars list them, by
+// time.Weekday
+var weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// WorkCalendar says which days are worked, for statistics that measure
+// durations in working time: two teams taking a day to review, one of them
+// over a weekend, compare as equally fast.
+type WorkCalendar struct {
+	// Timezone is the IANA time zone days start and end in
+	Timezone string `json:"timezone"`
+	// Weekend lists the days of the week not worked, as "sat", "sun" and
+	// so on
+	Weekend []string `json:"weekend"`
+	// Holidays lists the dates not worked, as YYYY-MM-DD
+	Holidays []string `json:"holidays,omitempty"`
+}
+
+// DefaultWorkCalendar is worked Monday to Friday, in UTC, without holidays
+func DefaultWorkCalendar() WorkCalendar {
+	return WorkCalendar{Timezone: "UTC", Weekend: []string{"sat", "sun"}}
+}
+
+// ParseWorkCalendar builds a calendar from a time zone and comma-separated
+// weekend days and holidays, e.g. "Europe/Berlin", "sat,sun" and
+// "2024-12-25,2024-12-26"
+func ParseWorkCalendar(timezone, weekend, holidays string) (WorkCalendar, error) {
+	c := WorkCalendar{Timezone: timezone, Weekend: []string{}}
+	if c.Timezone == "" {
+		c.Timezone = "UTC"
+	}
+	for _, day := range strings.Split(weekend, ",") {
+		if day = strings.ToLower(strings.TrimSpace(day)); day != "" {
+			c.Weekend = append(c.Weekend, day)
+		}
+	}
+	for _, date := range strings.Split(holidays, ",") {
+		if date = strings.TrimSpace(date); date != "" {
+			c.Holidays = append(c.Holidays, date)
+		}
+	}
+	return c, c.Validate()
+}
+
+// Validate checks that the time zone exists, the weekend names days of the
+// week and the holidays are dates
+func (c WorkCalendar) Validate() error {
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		return fmt.Errorf("unknown time zone %q", c.Timezone)
+	}
+	for _, day := range c.Weekend {
+		if weekday(day) < 0 {
+			return fmt.Errorf("unknown weekend day %q, want one of %s", day, strings.Join(weekdays, ", "))
+		}
+	}
+	if len(c.Weekend) == len(weekdays) {
+		return fmt.Errorf("the weekend cannot take the whole week")
+	}
+	for _, date := range c.Holidays {
+		if _, err := time.Parse(time.DateOnly, date); err != nil {
+			return fmt.Errorf("invalid holiday %q, want YYYY-MM-DD", date)
+		}
+	}
+	return nil
+}
+
+// WeekendDays returns the weekend as time.Weekday numbers, Sunday being 0
+func (c WorkCalendar) WeekendDays() []int {
+	days := make([]int, 0, len(c.Weekend))
+	for _, day := range c.Weekend {
+		if d := weekday(day); d >= 0 {
+			days = append(days, d)
+		}
+	}
+	return days
+}
+
+func weekday(name string) int {
+	for i, day := range weekdays {
+		if day == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Deployment is a deployment of a commit of a repository to an environment
+type Deployment struct {
+	RepoID       int    `db:"repository_id" json:"-"`
+	GitHubID     int64  `db:"github_id" json:"id"`
+	SHA          string `db:"sha" json:"sha"`
+	Ref          string `db:"ref" json:"ref"`
+	Environment  string `db:"environment" json:"environment"`
+	CreatorLogin string `db:"creator_login" json:"creator_login"`
+	// State is the latest status the deployment reported, "" before any
+	State       string    `db:"state" json:"state"`
+	LogURL      string    `db:"log_url" json:"log_url,omitempty"`
+	RequestedAt time.Time `db:"requested_at" json:"requested_at"`
+	// SucceededAt is when the deployment first reported success; it stays
+	// set once a later deployment makes it inactive
+	SucceededAt *time.Time `db:"succeeded_at" json:"succeeded_at,omitempty"`
+	// HTMLURL links to the deployments of the environment on GitHub
+	HTMLURL string `db:"-" json:"html_url,omitempty"`
+}
+
+// DeploymentCommits are the commits a deployment shipped: those after the
+// commit of the previous successful deployment of its environment, up to
+// its own commit
+type DeploymentCommits struct {
+	Deployment Deployment `json:"deployment"`
+	// Previous is nil for the first successful deployment of the
+	// environment, which shipped every stored commit up to its own
+	Previous *Deployment `json:"previous,omitempty"`
+	Commits  []Commit    `json:"commits"`
+	// CompareURL links to the comparison of the two deployed commits on
+	// GitHub, when there is a previous deployment
+	CompareURL string `json:"compare_url,omitempty"`
+}
+
+// CommitDeployment is when a commit reached an environment
+type CommitDeployment struct {
+	Commit      Commit `json:"commit"`
+	Environment string `json:"environment"`
+	// Deployment is the first successful deployment of the environment
+	// that shipped the commit, nil while none did
+	Deployment *Deployment `json:"deployment,omitempty"`
+	// LeadTimeHours is how long after being committed the commit reached
+	// the environment
+	LeadTimeHours *float64 `json:"lead_time_hours,omitempty"`
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Commit fields that can be left out of storage
+const (
+	// FieldMessage is the whole commit message
+	FieldMessage = "message"
+	// FieldMessageBody is the commit message past its first line
+	FieldMessageBody = "message_body"
+	// FieldURL is the commit's HTML URL
+	FieldURL = "url"
+	// FieldAPIURL is the commit's REST API URL
+	FieldAPIURL = "api_url"
+)
+
+// OmittableFields lists every field OmitFields can leave out
+var OmittableFields = []string{FieldMessage, FieldMessageBody, FieldURL, FieldAPIURL}
+
+// OmitFields says which commit fields are not stored, for deployments that
+// only need counts and dates. The zero value stores everything.
+type OmitFields struct {
+	Message     bool
+	MessageBody bool
+	URL         bool
+	APIURL      bool
+}
+
+// ParseOmitFields parses a comma-separated list of OmittableFields, e.g.
+// "message_body,api_url"
+func ParseOmitFields(list string) (OmitFields, error) {
+	var o OmitFields
+	for _, field := range strings.Split(list, ",") {
+		switch field = strings.ToLower(strings.TrimSpace(field)); field {
+		case "":
+		case FieldMessage:
+			o.Message = true
+		case FieldMessageBody:
+			o.MessageBody = true
+		case FieldURL:
+			o.URL = true
+		case FieldAPIURL:
+			o.APIURL = true
+		default:
+			return OmitFields{}, fmt.Errorf("unknown field %q, want one of %s", field, strings.Join(OmittableFields, ", "))
+		}
+	}
+	return o, nil
+}
+
+// String lists the omitted fields as ParseOmitFields takes them
+func (o OmitFields) String() string {
+	var fields []string
+	for _, f := range []struct {
+		name    string
+		omitted bool
+	}{{FieldMessage, o.Message}, {FieldMessageBody, o.MessageBody}, {FieldURL, o.URL}, {FieldAPIURL, o.APIURL}} {
+		if f.omitted {
+			fields = append(fields, f.name)
+		}
+	}
+	return strings.Join(fields, ",")
+}
+
+// Apply clears the omitted fields of c. A message cut to its first line
+// loses its trailing whitespace too.
+func (o OmitFields) Apply(c *Commit) {
+	switch {
+	case o.Message:
+		c.Message = ""
+	case o.MessageBody:
+		subject, _, _ := strings.Cut(c.Message, "\n")
+		c.Message = strings.TrimRight(subject, " \t\r")
+	}
+	if o.URL {
+		c.URL = ""
+	}
+	if o.APIURL {
+		c.APIURL = ""
+	}
+}
@@ -0,0 +1,84 @@
+package models
+
+import "time"
+
+// Issue states as GitHub reports them
+const (
+	IssueStateOpen   = "open"
+	IssueStateClosed = "closed"
+)
+
+// Issue is an issue of a repository. Pull requests, which GitHub lists as
+// issues too, are not stored as issues.
+type Issue struct {
+	RepoID      int        `db:"repository_id" json:"-"`
+	Number      int        `db:"number" json:"number"`
+	Title       string     `db:"title" json:"title"`
+	State       string     `db:"state" json:"state"`
+	AuthorLogin string     `db:"author_login" json:"author_login"`
+	Labels      []string   `db:"-" json:"labels"`
+	OpenedAt    time.Time  `db:"opened_at" json:"opened_at"`
+	ClosedAt    *time.Time `db:"closed_at" json:"closed_at,omitempty"`
+	// GitHubUpdatedAt is when the issue last changed on GitHub; issue syncs
+	// continue from the latest one stored
+	GitHubUpdatedAt time.Time `db:"github_updated_at" json:"github_updated_at"`
+}
+
+// IssueAgeBuckets are the upper bounds, in days, of the age buckets of open
+// issues; older issues fall in a last, unbounded bucket
+var IssueAgeBuckets = []int{7, 30, 90, 365}
+
+// IssueStats describes the issues of a repository over a period, all of them
+// or those with one label
+type IssueStats struct {
+	Repo  string    `json:"repo"`
+	Label string    `json:"label,omitempty"`
+	Since time.Time `json:"since"`
+	Until time.Time `json:"until"`
+	// Open counts the issues open at Until, and OpenAge splits them by age
+	Open    int         `json:"open"`
+	OpenAge []AgeBucket `json:"open_age"`
+	// Closed counts the issues closed in the period, and TimeToClose is
+	// how long they had been open; it is nil when none were
+	Closed      int                  `json:"closed"`
+	TimeToClose *DurationPercentiles `json:"time_to_close,omitempty"`
+	// Weekly counts the issues opened and closed every week of the period,
+	// weeks starting on Monday
+	Weekly []IssueThroughput `json:"weekly"`
+	// Labels splits the issues opened and closed in the period by label;
+	// an issue with several labels counts under each
+	Labels []LabelIssues `json:"labels"`
+	// Calendar is the calendar whose working days ages and times to close
+	// count, nil when they are calendar time
+	Calendar *WorkCalendar `json:"calendar,omitempty"`
+}
+
+// AgeBucket counts the issues whose age in days is at least MinDays and
+// below MaxDays; MaxDays is 0 for the last bucket
+type AgeBucket struct {
+	MinDays int `json:"min_days"`
+	MaxDays int `json:"max_days,omitempty"`
+	Issues  int `json:"issues"`
+}
+
+// DurationPercentiles are percentiles of durations, in hours
+type DurationPercentiles struct {
+	P50 float64 `db:"p50" json:"p50_hours"`
+	P75 float64 `db:"p75" json:"p75_hours"`
+	P90 float64 `db:"p90" json:"p90_hours"`
+}
+
+// IssueThroughput counts the issues opened and closed in the week starting
+// on Week
+type IssueThroughput struct {
+	Week   time.Time `db:"week" json:"week"`
+	Opened int       `db:"opened" json:"opened"`
+	Closed int       `db:"closed" json:"closed"`
+}
+
+// LabelIssues counts the issues with a label opened and closed in a period
+type LabelIssues struct {
+	Label  string `db:"label" json:"label"`
+	Opened int    `db:"opened" json:"opened"`
+	Closed int    `db:"closed" json:"closed"`
+}
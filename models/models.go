@@ -1,11 +1,28 @@
 // Package models defines the core data structures used throughout the application.
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
+
+// Tenant represents a namespace tracking one GitHub organization with its own token and schedule
+type Tenant struct {
+	ID           int       `db:"id" json:"id"`
+	Name         string    `db:"name" json:"name"`
+	GitHubToken  string    `db:"github_token" json:"-"`
+	RepoOwner    string    `db:"repo_owner" json:"repo_owner"`
+	PollInterval int       `db:"poll_interval" json:"poll_interval"`
+	APIToken     *string   `db:"api_token" json:"-"`
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+}
 
 // Repository represents a GitHub repository
 type Repository struct {
 	ID              int       `db:"id" json:"id"`
+	TenantID        int       `db:"tenant_id" json:"tenant_id"`
+	GitHubID        int64     `db:"github_id" json:"github_id,omitempty"` // Stable across renames; 0 until known
+	NodeID          string    `db:"node_id" json:"node_id,omitempty"`     // GraphQL global ID
 	Name            string    `db:"name" json:"name"`
 	Owner           string    `db:"owner" json:"owner"`
 	Description     string    `db:"description" json:"description"`
@@ -17,8 +34,38 @@ type Repository struct {
 	WatchersCount   int       `db:"watchers_count" json:"watchers_count"`
 	CreatedAt       time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt       time.Time `db:"updated_at" json:"updated_at"`
+	Status          string    `db:"status" json:"status"`
+	// StartDate is where the first sync of an imported repository starts,
+	// or with StartDateAuto its creation on GitHub; PollInterval, in
+	// seconds, overrides the tenant's when positive
+	StartDate     *time.Time `db:"start_date" json:"start_date,omitempty"`
+	StartDateAuto bool       `db:"start_date_auto" json:"start_date_auto,omitempty"`
+	PollInterval  int        `db:"poll_interval" json:"poll_interval,omitempty"`
+	// DefaultBranch is the branch commits are synced from, as last reported
+	// by GitHub
+	DefaultBranch string `db:"default_branch" json:"default_branch,omitempty"`
+	// Group is the repository group administrative commands can act on
+	// together; empty when the repository is in none
+	Group string `db:"repo_group" json:"group,omitempty"`
+	// LastSyncAt is when a sync of the repository last finished, nil
+	// until one has
+	LastSyncAt *time.Time `db:"last_sync_at" json:"last_sync_at,omitempty"`
+	// LastSuccessAt is when a sync of the repository last succeeded, nil
+	// until one has
+	LastSuccessAt *time.Time `db:"last_success_at" json:"last_success_at,omitempty"`
+	// HasPathFilters is set when path filters are configured, which makes
+	// syncs fetch the files each commit touched
+	HasPathFilters bool `db:"has_path_filters" json:"-"`
 }
 
+// Repository statuses
+const (
+	RepoStatusActive      = "active"      // Monitored for new commits
+	RepoStatusPaused      = "paused"      // Kept but not monitored until resumed
+	RepoStatusRemoved     = "removed"     // No longer tracked; data is retained
+	RepoStatusQuarantined = "quarantined" // Failing repeatedly; re-checked with backoff
+)
+
 // Commit represents a GitHub commit
 type Commit struct {
 	ID         int       `db:"id" json:"id"`
@@ -26,9 +73,32 @@ type Commit struct {
 	RepoID     int       `db:"repository_id" json:"repository_id"`
 	Message    string    `db:"message" json:"message"`
 	AuthorName string    `db:"author_name" json:"author_name"`
-	Date       time.Time `db:"date" json:"date"`
-	URL        string    `db:"url" json:"url"`
-	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+	Date       time.Time `db:"date" json:"date"` // Author date
+	// CommitterDate is when the commit was last rewritten, e.g. by a
+	// rebase; incremental syncs continue from the newest one
+	CommitterDate time.Time `db:"committer_date" json:"committer_date"`
+	URL           string    `db:"url" json:"url"`                   // Canonical HTML URL
+	APIURL        string    `db:"api_url" json:"api_url,omitempty"` // Canonical REST API URL
+	// MessageHash is the hex SHA-256 of the message as received, set when
+	// the stored message was sanitized or truncated
+	MessageHash string `db:"message_hash" json:"message_hash,omitempty"`
+	// MessageGzip is the message as stored compressed, only until it is
+	// read back into Message
+	MessageGzip []byte    `db:"message_gzip" json:"-"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+	// Parents are the parent SHAs, first parent first, when parent
+	// ingestion is enabled; they are stored in commit_parents
+	Parents []string `db:"-" json:"parents,omitempty"`
+}
+
+// CommitDetail is a commit with the repository it belongs to and the files
+// it touched. Files is nil unless the file list was fetched, which only
+// happens for repositories with path filters.
+type CommitDetail struct {
+	Commit
+	RepoOwner string   `db:"repo_owner" json:"repo_owner"`
+	RepoName  string   `db:"repo_name" json:"repo"`
+	Files     []string `db:"-" json:"files"`
 }
 
 // AuthorStats represents commit statistics for a specific author.
@@ -58,6 +128,61 @@ func NewPaginationParams(page, pageSize int) PaginationParams {
 	}
 }
 
+// Offset returns the number of rows to skip for the current page.
+func (p PaginationParams) Offset() int {
+	return (p.Page - 1) * p.PageSize
+}
+
+// Window returns the highest row position the page reaches, which is what
+// result-window caps are enforced against.
+func (p PaginationParams) Window() int {
+	return p.Page * p.PageSize
+}
+
+// StatsFilter limits repository statistics to the commits dated in [Since,
+// Until) by Author. A zero Since or Until leaves that end open, and an empty
+// Author counts everyone.
+type StatsFilter struct {
+	Since  time.Time
+	Until  time.Time
+	Author string
+}
+
+// Orders repository listings can be sorted in
+const (
+	// RepoSortName sorts by owner and name, the default
+	RepoSortName = "name"
+	// RepoSortLastSync puts the most recently synced repositories first
+	RepoSortLastSync = "last_sync"
+	// RepoSortStars puts the most starred repositories first
+	RepoSortStars = "stars"
+	// RepoSortCommits puts the repositories with the most stored commits
+	// first
+	RepoSortCommits = "commits"
+)
+
+// RepoSorts lists every order repository listings can be sorted in
+var RepoSorts = []string{RepoSortName, RepoSortLastSync, RepoSortStars, RepoSortCommits}
+
+// RepositoryFilter selects and orders the repositories a listing returns.
+// Empty fields select every repository; without a Status, removed
+// repositories are left out. Owner and Language match case-insensitively.
+type RepositoryFilter struct {
+	Owner    string
+	Language string
+	Status   string
+	Group    string
+	MinStars int
+	// Sort is one of RepoSorts; empty sorts by name
+	Sort string
+}
+
+// RepositoryListing is a repository with the number of its stored commits
+type RepositoryListing struct {
+	Repository
+	Commits int `db:"commit_count" json:"commits"`
+}
+
 // RepositoryStats represents statistics about a repository
 type RepositoryStats struct {
 	TotalCommits    int       `db:"total_commits" json:"total_commits"`
@@ -65,3 +190,261 @@ type RepositoryStats struct {
 	FirstCommitDate time.Time `db:"first_commit_date" json:"first_commit_date"`
 	LastCommitDate  time.Time `db:"last_commit_date" json:"last_commit_date"`
 }
+
+// RepositorySnapshot holds a repository's counters as GitHub reported them
+// at RecordedAt
+type RepositorySnapshot struct {
+	StarsCount      int       `db:"stars_count" json:"stars_count"`
+	ForksCount      int       `db:"forks_count" json:"forks_count"`
+	OpenIssuesCount int       `db:"open_issues_count" json:"open_issues_count"`
+	WatchersCount   int       `db:"watchers_count" json:"watchers_count"`
+	RecordedAt      time.Time `db:"recorded_at" json:"recorded_at"`
+}
+
+// RepositoryDiff is how a repository changed between two times
+type RepositoryDiff struct {
+	Owner string    `json:"owner"`
+	Name  string    `json:"name"`
+	From  time.Time `json:"from"`
+	To    time.Time `json:"to"`
+	// Before and After are the last snapshots taken at or before From and
+	// To; nil when none was taken by then
+	Before *RepositorySnapshot `json:"before"`
+	After  *RepositorySnapshot `json:"after"`
+	// CommitsAdded counts the commits authored in (From, To], and NewAuthors
+	// lists their authors without an earlier commit
+	CommitsAdded int      `json:"commits_added"`
+	NewAuthors   []string `json:"new_authors"`
+}
+
+// StarsGained returns the change in stars, and false without both snapshots
+func (d RepositoryDiff) StarsGained() (int, bool) {
+	return d.counterDelta(func(s *RepositorySnapshot) int { return s.StarsCount })
+}
+
+// ForksGained returns the change in forks, and false without both snapshots
+func (d RepositoryDiff) ForksGained() (int, bool) {
+	return d.counterDelta(func(s *RepositorySnapshot) int { return s.ForksCount })
+}
+
+// OpenIssuesChange returns the change in open issues, and false without
+// both snapshots
+func (d RepositoryDiff) OpenIssuesChange() (int, bool) {
+	return d.counterDelta(func(s *RepositorySnapshot) int { return s.OpenIssuesCount })
+}
+
+func (d RepositoryDiff) counterDelta(counter func(*RepositorySnapshot) int) (int, bool) {
+	if d.Before == nil || d.After == nil {
+		return 0, false
+	}
+	return counter(d.After) - counter(d.Before), true
+}
+
+// Heatmap counts commits by day of week and hour of day: the punch card of
+// a repository, an author, or both
+type Heatmap struct {
+	Repo     string    `json:"repo,omitempty"`
+	Author   string    `json:"author,omitempty"`
+	Since    time.Time `json:"since"`
+	Until    time.Time `json:"until"`
+	Timezone string    `json:"timezone"`
+	// Cells[day][hour] counts the commits authored on a day of the week,
+	// Sunday first, at an hour of the day in Timezone
+	Cells [7][24]int `json:"cells"`
+	Total int        `json:"total"`
+}
+
+// RollupStats aggregates the commit statistics of the repositories of an
+// owner or a repository group, or of the whole tenant when neither is given
+type RollupStats struct {
+	Owner string `json:"owner,omitempty"`
+	Group string `json:"group,omitempty"`
+	// Repositories counts the tracked repositories in scope, and
+	// ActiveRepositories those with commits matching the filter
+	Repositories       int `db:"repositories" json:"repositories"`
+	ActiveRepositories int `db:"active_repositories" json:"active_repositories"`
+	TotalCommits       int `db:"total_commits" json:"total_commits"`
+	// UniqueAuthors counts each author once however many repositories they
+	// committed to
+	UniqueAuthors int `db:"unique_authors" json:"unique_authors"`
+	// Busiest lists the repositories with the most commits, most first
+	Busiest []RepositoryActivity `json:"busiest"`
+}
+
+// RepositoryActivity is the commit count of one repository of a rollup
+type RepositoryActivity struct {
+	RepoName       string    `db:"repo_name" json:"repo"`
+	Owner          string    `db:"owner" json:"owner"`
+	Commits        int       `db:"commits" json:"commits"`
+	Authors        int       `db:"authors" json:"authors"`
+	LastCommitDate time.Time `db:"last_commit_date" json:"last_commit_date"`
+}
+
+// KnowledgeConcentration measures how much of a repository's recent work
+// rests with its most active authors
+type KnowledgeConcentration struct {
+	RepoName     string `db:"repo_name" json:"repo"`
+	TotalCommits int    `db:"total_commits" json:"total_commits"`
+	Authors      int    `db:"authors" json:"authors"`
+	// TopCommits is the number of commits by the top authors
+	TopCommits int `db:"top_commits" json:"top_commits"`
+	// BusFactor is the fewest authors who together made more than half of
+	// the commits
+	BusFactor int `db:"bus_factor" json:"bus_factor"`
+	// Files counts the files with known changes; only repositories with path
+	// filters have file lists. Each file is owned by the author who changed
+	// it most, and TopFiles counts those owned by the top authors.
+	Files    int `db:"files" json:"files"`
+	TopFiles int `db:"top_files" json:"top_files"`
+}
+
+// CommitShare returns the share of commits made by the top authors
+func (k KnowledgeConcentration) CommitShare() float64 {
+	if k.TotalCommits == 0 {
+		return 0
+	}
+	return float64(k.TopCommits) / float64(k.TotalCommits)
+}
+
+// FileShare returns the share of files owned by the top authors, and false
+// when no file lists are known
+func (k KnowledgeConcentration) FileShare() (float64, bool) {
+	if k.Files == 0 {
+		return 0, false
+	}
+	return float64(k.TopFiles) / float64(k.Files), true
+}
+
+// PathOwnership is the activity under one path prefix of a repository: a
+// directory a fixed number of levels deep, or a shallower one holding files
+type PathOwnership struct {
+	// Prefix is the directory, "" for files at the root
+	Prefix string `json:"prefix"`
+	// Commits counts the commits touching files under Prefix
+	Commits    int             `json:"commits"`
+	Committers []PathCommitter `json:"committers"`
+	// CodeOwners are the owners the repository's CODEOWNERS file assigns to
+	// Prefix, when it was compared
+	CodeOwners []string `json:"code_owners,omitempty"`
+}
+
+// PathCommitter is an author of commits under a path prefix
+type PathCommitter struct {
+	Name    string `db:"author_name" json:"name"`
+	Commits int    `db:"commits" json:"commits"`
+}
+
+// DumpTable is the number of rows of one table in a dump, or deleted by a
+// purge
+type DumpTable struct {
+	Table string `json:"table"`
+	Rows  int    `json:"rows"`
+}
+
+// PurgeResult is what purging a repository deleted, or would delete on a
+// dry run, table by table
+type PurgeResult struct {
+	Owner  string      `json:"owner"`
+	Name   string      `json:"name"`
+	DryRun bool        `json:"dry_run"`
+	Tables []DumpTable `json:"tables"`
+}
+
+// Change is one row of a table as it was at its last write, for incremental
+// extraction
+type Change struct {
+	Table     string          `json:"table"`
+	Key       string          `json:"key"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	Row       json.RawMessage `json:"row"`
+}
+
+// Actor types recorded in the audit log
+const (
+	ActorTypeCLI = "cli"
+	ActorTypeAPI = "api"
+)
+
+// AuditEntry records an administrative action
+type AuditEntry struct {
+	ID         int             `db:"id" json:"id"`
+	TenantID   int             `db:"tenant_id" json:"tenant_id"`
+	Actor      string          `db:"actor" json:"actor"`
+	ActorType  string          `db:"actor_type" json:"actor_type"`
+	Action     string          `db:"action" json:"action"`
+	Parameters json.RawMessage `db:"parameters" json:"parameters"`
+	CreatedAt  time.Time       `db:"created_at" json:"created_at"`
+}
+
+// Raw payload kinds
+const (
+	PayloadKindRepo    = "repo"    // Response of the repository endpoint
+	PayloadKindCommits = "commits" // One page of the commits endpoint
+)
+
+// RawPayload is a GitHub API response body stored for replay
+type RawPayload struct {
+	ID        int       `db:"id" json:"id"`
+	TenantID  int       `db:"tenant_id" json:"tenant_id"`
+	Owner     string    `db:"owner" json:"owner"`
+	Name      string    `db:"name" json:"name"`
+	Kind      string    `db:"kind" json:"kind"`
+	Page      int       `db:"page" json:"page"`
+	Payload   []byte    `db:"payload" json:"-"`
+	FetchedAt time.Time `db:"fetched_at" json:"fetched_at"`
+}
+
+// SyncCheckpoint records how far an interrupted commit sync got, so the next
+// sync resumes from the same since date at the next page
+type SyncCheckpoint struct {
+	RepoID    int       `db:"repository_id" json:"repository_id"`
+	Since     time.Time `db:"since" json:"since"`
+	NextPage  int       `db:"next_page" json:"next_page"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+	// NextSHA is the next mainline commit of a first-parent sync
+	NextSHA string `db:"next_sha" json:"next_sha,omitempty"`
+}
+
+// WebhookDeadLetter is a webhook delivery that failed every attempt
+type WebhookDeadLetter struct {
+	ID        int       `db:"id" json:"id"`
+	TenantID  int       `db:"tenant_id" json:"tenant_id"`
+	URL       string    `db:"url" json:"url"`
+	EventID   string    `db:"event_id" json:"event_id"`
+	EventType string    `db:"event_type" json:"event_type"`
+	Payload   []byte    `db:"payload" json:"payload"`
+	Attempts  int       `db:"attempts" json:"attempts"`
+	LastError string    `db:"last_error" json:"last_error"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// RepositoryFailure counts the consecutive failed syncs of a repository
+type RepositoryFailure struct {
+	RepoID      int        `db:"repository_id" json:"repository_id"`
+	Failures    int        `db:"failures" json:"failures"`
+	LastError   string     `db:"last_error" json:"last_error"`
+	NextCheckAt *time.Time `db:"next_check_at" json:"next_check_at,omitempty"`
+	UpdatedAt   time.Time  `db:"updated_at" json:"updated_at"`
+}
+
+// RejectedCommit is a commit that failed to insert while the rest of its
+// batch was stored
+type RejectedCommit struct {
+	ID        int       `db:"id" json:"id"`
+	RepoID    int       `db:"repository_id" json:"repository_id"`
+	SHA       string    `db:"sha" json:"sha"`
+	Payload   []byte    `db:"payload" json:"payload"` // The commit, JSON encoded
+	Error     string    `db:"error" json:"error"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// PathFilter scopes a repository's statistics to the commits touching paths
+// matching a glob pattern, e.g. services/payments/** in a monorepo
+type PathFilter struct {
+	ID        int       `db:"id" json:"id"`
+	RepoID    int       `db:"repository_id" json:"repository_id"`
+	Name      string    `db:"name" json:"name"`
+	Pattern   string    `db:"pattern" json:"pattern"`
+	Regexp    string    `db:"path_regex" json:"-"` // Pattern compiled for matching in queries
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
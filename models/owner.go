@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Owner types
+const (
+	OwnerTypeUser = "user"
+	OwnerTypeOrg  = "org"
+)
+
+// Owner is the GitHub user or organization owning tracked repositories, as
+// its profile read when it was last fetched
+type Owner struct {
+	ID       int    `db:"id" json:"-"`
+	TenantID int    `db:"tenant_id" json:"-"`
+	Login    string `db:"login" json:"login"`
+	GitHubID int64  `db:"github_id" json:"github_id"`
+	// Type is OwnerTypeUser or OwnerTypeOrg
+	Type string `db:"type" json:"type"`
+	// Name is the display name, empty when the profile sets none
+	Name        string `db:"name" json:"name"`
+	AvatarURL   string `db:"avatar_url" json:"avatar_url"`
+	PublicRepos int    `db:"public_repos" json:"public_repos"`
+	// FetchedAt is when the profile was last fetched from GitHub
+	FetchedAt time.Time `db:"fetched_at" json:"fetched_at"`
+}
+
+// OwnerListing is an owner with the number of its repositories tracked
+type OwnerListing struct {
+	Owner
+	Repositories int `db:"repositories" json:"repositories"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// validFilterName restricts path filter names to what fits in a query string
+var validFilterName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// NewPathFilter validates a path filter and compiles its pattern. Patterns are
+// relative to the repository root: * and ? match within one path segment, **
+// matches any number of segments, and a pattern without wildcards matches the
+// path itself and everything below it.
+func NewPathFilter(name, pattern string) (*PathFilter, error) {
+	if !validFilterName.MatchString(name) {
+		return nil, fmt.Errorf("invalid path filter name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	pattern = strings.TrimSuffix(strings.TrimSpace(pattern), "/")
+	if pattern == "" {
+		return nil, fmt.Errorf("path filter pattern cannot be empty")
+	}
+	if strings.HasPrefix(pattern, "/") {
+		return nil, fmt.Errorf("invalid path filter pattern %q: paths are relative to the repository root", pattern)
+	}
+	return &PathFilter{Name: name, Pattern: pattern, Regexp: globRegexp(pattern)}, nil
+}
+
+// globRegexp translates a path glob into an anchored regular expression that
+// Go and PostgreSQL interpret the same way
+func globRegexp(pattern string) string {
+	if !strings.ContainsAny(pattern, "*?") {
+		return "^" + regexp.QuoteMeta(pattern) + "(/.*)?$"
+	}
+
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			b.WriteString("[^/]*")
+		case pattern[i] == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPathFilter(t *testing.T) {
+	tests := []struct {
+		pattern string
+		matches []string
+		misses  []string
+	}{
+		{
+			pattern: "services/payments/**",
+			matches: []string{"services/payments/main.go", "services/payments/api/v1/handler.go"},
+			misses:  []string{"services/payments", "services/payments-v2/main.go", "services/billing/main.go"},
+		},
+		{
+			pattern: "services/payments",
+			matches: []string{"services/payments", "services/payments/main.go"},
+			misses:  []string{"services/payments-v2/main.go", "libs/services/payments/main.go"},
+		},
+		{
+			pattern: "**/*.proto",
+			matches: []string{"api.proto", "services/payments/api.proto"},
+			misses:  []string{"api.proto.bak", "services/payments/main.go"},
+		},
+		{
+			pattern: "services/*/go.mod",
+			matches: []string{"services/payments/go.mod"},
+			misses:  []string{"services/payments/api/go.mod"},
+		},
+		{
+			pattern: "docs/v?.md",
+			matches: []string{"docs/v1.md"},
+			misses:  []string{"docs/v10.md", "docs/v/.md"},
+		},
+		{
+			pattern: "web/app.(legacy)+/",
+			matches: []string{"web/app.(legacy)+/index.js"},
+			misses:  []string{"web/appx(legacy)+/index.js"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			f, err := NewPathFilter("team", tt.pattern)
+			require.NoError(t, err)
+			re := regexp.MustCompile(f.Regexp)
+			for _, path := range tt.matches {
+				assert.True(t, re.MatchString(path), "%s should match", path)
+			}
+			for _, path := range tt.misses {
+				assert.False(t, re.MatchString(path), "%s should not match", path)
+			}
+		})
+	}
+
+	for _, invalid := range []struct{ name, pattern string }{
+		{"", "services/**"},
+		{"team a", "services/**"},
+		{"team", ""},
+		{"team", "/services/**"},
+	} {
+		_, err := NewPathFilter(invalid.name, invalid.pattern)
+		assert.Error(t, err, "%q %q", invalid.name, invalid.pattern)
+	}
+}
@@ -0,0 +1,70 @@
+package models
+
+import "time"
+
+// PullRequest is a pull request of a repository, with the size of its change
+type PullRequest struct {
+	ID           int        `db:"id" json:"-"`
+	RepoID       int        `db:"repository_id" json:"-"`
+	Number       int        `db:"number" json:"number"`
+	Title        string     `db:"title" json:"title"`
+	State        string     `db:"state" json:"state"`
+	AuthorLogin  string     `db:"author_login" json:"author_login"`
+	Draft        bool       `db:"draft" json:"draft"`
+	Additions    int        `db:"additions" json:"additions"`
+	Deletions    int        `db:"deletions" json:"deletions"`
+	ChangedFiles int        `db:"changed_files" json:"changed_files"`
+	OpenedAt     time.Time  `db:"opened_at" json:"opened_at"`
+	MergedAt     *time.Time `db:"merged_at" json:"merged_at,omitempty"`
+	ClosedAt     *time.Time `db:"closed_at" json:"closed_at,omitempty"`
+	// GitHubUpdatedAt is when the pull request last changed on GitHub; pull
+	// request syncs continue from the latest one stored
+	GitHubUpdatedAt time.Time `db:"github_updated_at" json:"github_updated_at"`
+	Reviews         []Review  `db:"-" json:"reviews,omitempty"`
+}
+
+// Review is a submitted review of a pull request
+type Review struct {
+	GitHubID      int64     `db:"github_id" json:"id"`
+	ReviewerLogin string    `db:"reviewer_login" json:"reviewer_login"`
+	State         string    `db:"state" json:"state"`
+	SubmittedAt   time.Time `db:"submitted_at" json:"submitted_at"`
+}
+
+// PullRequestSizeBuckets are the upper bounds, in changed lines, of the size
+// buckets of pull requests; larger ones fall in a last, unbounded bucket
+var PullRequestSizeBuckets = []int{10, 100, 500, 1000}
+
+// PullRequestStats describes the pull requests of a repository, or of every
+// repository, opened in a period, by everyone or by a team of authors
+type PullRequestStats struct {
+	// Repo is empty for the statistics of every repository
+	Repo    string    `json:"repo,omitempty"`
+	Authors []string  `json:"authors,omitempty"`
+	Since   time.Time `json:"since"`
+	Until   time.Time `json:"until"`
+	Opened  int       `json:"opened"`
+	// Reviewed counts the pull requests reviewed by someone other than
+	// their author, and TimeToFirstReview is how long the first such review
+	// took; it is nil when none were
+	Reviewed          int                  `json:"reviewed"`
+	TimeToFirstReview *DurationPercentiles `json:"time_to_first_review,omitempty"`
+	// Merged counts the pull requests merged so far, and TimeToMerge is
+	// how long they took from being opened; it is nil when none were
+	Merged      int                  `json:"merged"`
+	TimeToMerge *DurationPercentiles `json:"time_to_merge,omitempty"`
+	// Sizes splits the opened pull requests by the number of lines they
+	// add and delete
+	Sizes []SizeBucket `json:"sizes"`
+	// Calendar is the calendar whose working days the times to review and
+	// merge count, nil when they are calendar time
+	Calendar *WorkCalendar `json:"calendar,omitempty"`
+}
+
+// SizeBucket counts the pull requests changing at least MinLines and fewer
+// than MaxLines lines; MaxLines is 0 for the last bucket
+type SizeBucket struct {
+	MinLines     int `json:"min_lines"`
+	MaxLines     int `json:"max_lines,omitempty"`
+	PullRequests int `json:"pull_requests"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// RateLimitSample is the rate limit GitHub reported on one API response
+type RateLimitSample struct {
+	ID       int    `db:"id" json:"-"`
+	TenantID int    `db:"tenant_id" json:"-"`
+	Resource string `db:"resource" json:"resource"`
+	Limit    int    `db:"rate_limit" json:"limit"`
+	// Remaining is how many requests the window had left after the
+	// response
+	Remaining  int       `db:"remaining" json:"remaining"`
+	ResetAt    time.Time `db:"reset_at" json:"reset_at"`
+	ObservedAt time.Time `db:"observed_at" json:"observed_at"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Release is a published release of a repository
+type Release struct {
+	RepoID      int       `db:"repository_id" json:"-"`
+	GitHubID    int64     `db:"github_id" json:"id"`
+	TagName     string    `db:"tag_name" json:"tag_name"`
+	Name        string    `db:"name" json:"name"`
+	Prerelease  bool      `db:"prerelease" json:"prerelease"`
+	PublishedAt time.Time `db:"published_at" json:"published_at"`
+}
+
+// ReleaseCadence describes how often a repository published releases over
+// a period
+type ReleaseCadence struct {
+	RepoName string `db:"repo_name" json:"repo"`
+	Releases int    `db:"releases" json:"releases"`
+	// LastTag and LastRelease are the newest release of the period
+	LastTag     string     `db:"last_tag" json:"last_tag"`
+	LastRelease *time.Time `db:"last_release" json:"last_release,omitempty"`
+	// MeanDays and MedianDays are the days between each release of the
+	// period and the release before it, which may predate the period; they
+	// are nil when the repository has a single release
+	MeanDays   *float64 `db:"mean_days" json:"mean_days,omitempty"`
+	MedianDays *float64 `db:"median_days" json:"median_days,omitempty"`
+}
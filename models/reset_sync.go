@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// ResetSyncResult reports what resetting a repository's sync point did
+type ResetSyncResult struct {
+	Owner string    `json:"owner"`
+	Name  string    `json:"name"`
+	Since time.Time `json:"since"`
+	// CommitsFetched counts the commits GitHub listed from Since on
+	CommitsFetched int `json:"commits_fetched"`
+	// CommitsInserted and CommitsUpdated count the stored commits written
+	// while the reset ran; fetched commits stored unchanged count towards
+	// neither
+	CommitsInserted int   `json:"commits_inserted"`
+	CommitsUpdated  int   `json:"commits_updated"`
+	DurationMS      int64 `json:"duration_ms"`
+}
@@ -0,0 +1,164 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Commit validation limits
+const (
+	// DefaultMaxMessageBytes is the default cap on stored commit messages;
+	// longer messages are truncated
+	DefaultMaxMessageBytes = 64 * 1024
+	// MaxCommitDateSkew is how far in the future a commit date may lie, to
+	// allow for clock skew between committers and GitHub
+	MaxCommitDateSkew = 24 * time.Hour
+)
+
+// minCommitDate is the earliest commit date accepted; anything before the
+// Unix epoch comes from a broken or forged clock
+var minCommitDate = time.Unix(0, 0).UTC()
+
+// CommitValidationError describes why a commit cannot be stored
+type CommitValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (e *CommitValidationError) Error() string {
+	return fmt.Sprintf("invalid commit %s: %s", e.Field, e.Reason)
+}
+
+// Validate checks that a commit is fit to store as of now. SHAs, including
+// parent SHAs, must be 40 (SHA-1) or 64 (SHA-256) lowercase hex characters,
+// the author date and any committer date must lie between the Unix epoch and
+// MaxCommitDateSkew from now, and the HTML and API URLs, when present, must
+// be absolute http(s) URLs.
+func (c *Commit) Validate(now time.Time) error {
+	if reason := checkSHA(c.SHA); reason != "" {
+		return &CommitValidationError{Field: "sha", Reason: reason}
+	}
+	for _, parent := range c.Parents {
+		if reason := checkSHA(parent); reason != "" {
+			return &CommitValidationError{Field: "parents", Reason: reason}
+		}
+	}
+
+	if c.Date.Before(minCommitDate) {
+		return &CommitValidationError{Field: "date", Reason: fmt.Sprintf("%s is before the Unix epoch", c.Date.Format(time.RFC3339))}
+	}
+	if c.Date.After(now.Add(MaxCommitDateSkew)) {
+		return &CommitValidationError{Field: "date", Reason: fmt.Sprintf("%s is in the future", c.Date.Format(time.RFC3339))}
+	}
+	if !c.CommitterDate.IsZero() && c.CommitterDate.Before(minCommitDate) {
+		return &CommitValidationError{Field: "committer_date", Reason: fmt.Sprintf("%s is before the Unix epoch", c.CommitterDate.Format(time.RFC3339))}
+	}
+	if c.CommitterDate.After(now.Add(MaxCommitDateSkew)) {
+		return &CommitValidationError{Field: "committer_date", Reason: fmt.Sprintf("%s is in the future", c.CommitterDate.Format(time.RFC3339))}
+	}
+
+	if !isHTTPURL(c.URL) {
+		return &CommitValidationError{Field: "url", Reason: "not an absolute http(s) URL"}
+	}
+	if !isHTTPURL(c.APIURL) {
+		return &CommitValidationError{Field: "api_url", Reason: "not an absolute http(s) URL"}
+	}
+	return nil
+}
+
+// checkSHA returns why sha is not a valid commit SHA, or "" if it is
+func checkSHA(sha string) string {
+	if len(sha) != 40 && len(sha) != 64 {
+		return fmt.Sprintf("length %d, want 40 or 64", len(sha))
+	}
+	for i := 0; i < len(sha); i++ {
+		if ch := sha[i]; (ch < '0' || ch > '9') && (ch < 'a' || ch > 'f') {
+			return "not lowercase hex"
+		}
+	}
+	return ""
+}
+
+// isHTTPURL reports whether s is empty or an absolute http(s) URL
+func isHTTPURL(s string) bool {
+	if s == "" {
+		return true
+	}
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// CanonicalURL normalizes a GitHub URL so the same resource always has the
+// same string: scheme, host and path are lowercased (GitHub paths are case
+// insensitive and SHAs are lowercase hex), default ports, trailing slashes
+// and fragments are dropped. The query is kept. Values that do not parse are
+// returned unchanged for validation to reject.
+func CanonicalURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return raw
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if (u.Scheme == "https" && port == "443") || (u.Scheme == "http" && port == "80") {
+		port = ""
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+	u.Path = strings.TrimRight(strings.ToLower(u.Path), "/")
+	u.RawPath = ""
+	u.Fragment = ""
+	u.RawFragment = ""
+	return u.String()
+}
+
+// Sanitize makes a commit storable in Postgres text columns, which reject
+// NUL bytes and invalid UTF-8: NUL bytes are removed and invalid sequences
+// replaced with U+FFFD in the message and author name. Messages over
+// maxMessageBytes are then cut at a rune boundary. When the message changes,
+// MessageHash records the SHA-256 of the message as received. It reports
+// whether anything was sanitized and whether the message was truncated.
+func (c *Commit) Sanitize(maxMessageBytes int) (sanitized, truncated bool) {
+	original := c.Message
+
+	var authorChanged bool
+	c.Message, sanitized = sanitizeText(c.Message)
+	c.AuthorName, authorChanged = sanitizeText(c.AuthorName)
+	sanitized = sanitized || authorChanged
+
+	if maxMessageBytes > 0 && len(c.Message) > maxMessageBytes {
+		cut := maxMessageBytes
+		for cut > 0 && !utf8.RuneStart(c.Message[cut]) {
+			cut--
+		}
+		c.Message = c.Message[:cut]
+		truncated = true
+	}
+
+	if c.Message != original {
+		sum := sha256.Sum256([]byte(original))
+		c.MessageHash = hex.EncodeToString(sum[:])
+	}
+	return sanitized, truncated
+}
+
+// sanitizeText removes NUL bytes and replaces invalid UTF-8, reporting
+// whether s changed
+func sanitizeText(s string) (string, bool) {
+	if utf8.ValidString(s) && !strings.Contains(s, "\x00") {
+		return s, false
+	}
+	return strings.ReplaceAll(strings.ToValidUTF8(s, "\uFFFD"), "\x00", ""), true
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommitValidate(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	valid := Commit{
+		SHA:  "a94a8fe5ccb19ba61c4c0873d391e987982fbbd3",
+		Date: now.Add(-time.Hour),
+		URL:  "https://github.com/octo/hello/commit/a94a8fe5ccb19ba61c4c0873d391e987982fbbd3",
+	}
+
+	tests := []struct {
+		name   string
+		modify func(c *Commit)
+		field  string
+	}{
+		{name: "valid", modify: func(c *Commit) {}},
+		{name: "SHA-256", modify: func(c *Commit) { c.SHA = strings.Repeat("ab", 32) }},
+		{name: "no URL", modify: func(c *Commit) { c.URL = "" }},
+		{name: "within clock skew", modify: func(c *Commit) { c.Date = now.Add(time.Hour) }},
+		{name: "empty SHA", modify: func(c *Commit) { c.SHA = "" }, field: "sha"},
+		{name: "short SHA", modify: func(c *Commit) { c.SHA = "abc123" }, field: "sha"},
+		{name: "uppercase SHA", modify: func(c *Commit) { c.SHA = strings.ToUpper(c.SHA) }, field: "sha"},
+		{name: "zero date", modify: func(c *Commit) { c.Date = time.Time{} }, field: "date"},
+		{name: "far future date", modify: func(c *Commit) { c.Date = now.AddDate(1, 0, 0) }, field: "date"},
+		{name: "rebased commit", modify: func(c *Commit) { c.CommitterDate = now }},
+		{name: "future committer date", modify: func(c *Commit) { c.CommitterDate = now.AddDate(1, 0, 0) }, field: "committer_date"},
+		{name: "pre-epoch committer date", modify: func(c *Commit) { c.CommitterDate = time.Date(1960, 1, 1, 0, 0, 0, 0, time.UTC) }, field: "committer_date"},
+		{name: "merge commit", modify: func(c *Commit) { c.Parents = []string{strings.Repeat("1", 40), strings.Repeat("2", 40)} }},
+		{name: "invalid parent SHA", modify: func(c *Commit) { c.Parents = []string{"abc123"} }, field: "parents"},
+		{name: "relative URL", modify: func(c *Commit) { c.URL = "/octo/hello" }, field: "url"},
+		{name: "non-http URL", modify: func(c *Commit) { c.URL = "javascript:alert(1)" }, field: "url"},
+		{name: "relative API URL", modify: func(c *Commit) { c.APIURL = "repos/octo/hello" }, field: "api_url"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid
+			tt.modify(&c)
+			err := c.Validate(now)
+			if tt.field == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var verr *CommitValidationError
+			if assert.ErrorAs(t, err, &verr) {
+				assert.Equal(t, tt.field, verr.Field)
+			}
+		})
+	}
+}
+
+func TestCommitSanitize(t *testing.T) {
+	c := Commit{Message: "short", AuthorName: "Octo"}
+	sanitized, truncated := c.Sanitize(DefaultMaxMessageBytes)
+	assert.False(t, sanitized)
+	assert.False(t, truncated)
+	assert.Equal(t, "short", c.Message)
+	assert.Empty(t, c.MessageHash)
+
+	c = Commit{Message: "fix\x00 bug \xff", AuthorName: "Oc\x00to"}
+	sanitized, truncated = c.Sanitize(DefaultMaxMessageBytes)
+	assert.True(t, sanitized)
+	assert.False(t, truncated)
+	assert.Equal(t, "fix bug \uFFFD", c.Message)
+	assert.Equal(t, "Octo", c.AuthorName)
+	sum := sha256.Sum256([]byte("fix\x00 bug \xff"))
+	assert.Equal(t, hex.EncodeToString(sum[:]), c.MessageHash)
+
+	// A multi-byte rune straddling the cap is dropped whole
+	full := strings.Repeat("a", 9) + "é" + "tail"
+	c = Commit{Message: full}
+	sanitized, truncated = c.Sanitize(10)
+	assert.False(t, sanitized)
+	assert.True(t, truncated)
+	assert.Equal(t, strings.Repeat("a", 9), c.Message)
+	sum = sha256.Sum256([]byte(full))
+	assert.Equal(t, hex.EncodeToString(sum[:]), c.MessageHash)
+
+	// No cap keeps long messages whole
+	c = Commit{Message: full}
+	_, truncated = c.Sanitize(0)
+	assert.False(t, truncated)
+	assert.Equal(t, full, c.Message)
+}
+
+func TestCanonicalURL(t *testing.T) {
+	tests := map[string]string{
+		"": "",
+		"https://github.com/octo/hello/commit/ab":                 "https://github.com/octo/hello/commit/ab",
+		"HTTPS://GitHub.com/Octo/Hello/commit/AB/":                "https://github.com/octo/hello/commit/ab",
+		"https://github.com:443/octo/hello//":                     "https://github.com/octo/hello",
+		"https://api.github.com/repos/octo/hello/commits/ab#frag": "https://api.github.com/repos/octo/hello/commits/ab",
+		"http://localhost:8080/repos/octo/hello?page=2":           "http://localhost:8080/repos/octo/hello?page=2",
+		"not a url": "not a url",
+	}
+	for in, want := range tests {
+		assert.Equal(t, want, CanonicalURL(in), in)
+	}
+}
+
+func TestOmitFields(t *testing.T) {
+	o, err := ParseOmitFields(" message_body, API_URL ")
+	assert.NoError(t, err)
+	assert.Equal(t, OmitFields{MessageBody: true, APIURL: true}, o)
+	assert.Equal(t, "message_body,api_url", o.String())
+
+	c := Commit{Message: "Fix parser  \r\n\nLong explanation", URL: "https://github.com/o/r/commit/abc", APIURL: "https://api.github.com/repos/o/r/commits/abc"}
+	o.Apply(&c)
+	assert.Equal(t, "Fix parser", c.Message)
+	assert.Equal(t, "https://github.com/o/r/commit/abc", c.URL)
+	assert.Empty(t, c.APIURL)
+
+	OmitFields{Message: true, MessageBody: true, URL: true}.Apply(&c)
+	assert.Equal(t, Commit{}, c)
+
+	_, err = ParseOmitFields("message,author_name")
+	assert.Error(t, err)
+	o, err = ParseOmitFields("")
+	assert.NoError(t, err)
+	assert.Equal(t, OmitFields{}, o)
+}
@@ -0,0 +1,182 @@
+// Package progress tracks long running commit fetches so that backfills can
+// be followed through logs, metrics and the status API.
+package progress
+
+import (
+	"expvar"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"githubapifetch/logger"
+	"githubapifetch/metrics"
+)
+
+// DefaultLogInterval is how often the progress of a running fetch is logged
+const DefaultLogInterval = 30 * time.Second
+
+// Fetch is the progress of fetching the commits of one repository
+type Fetch struct {
+	TenantID   int        `json:"tenant_id"`
+	Owner      string     `json:"owner"`
+	Name       string     `json:"name"`
+	Pages      int        `json:"pages"`
+	TotalPages int        `json:"total_pages,omitempty"`
+	Commits    int        `json:"commits"`
+	StartedAt  time.Time  `json:"started_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	ETA        *time.Time `json:"eta,omitempty"`
+	Done       bool       `json:"done"`
+	Error      string     `json:"error,omitempty"`
+
+	lastLogged time.Time
+}
+
+// Remaining returns the number of pages left to fetch, or 0 while unknown
+func (f Fetch) Remaining() int {
+	if f.TotalPages <= f.Pages {
+		return 0
+	}
+	return f.TotalPages - f.Pages
+}
+
+// Update is a progress report for a fetch
+type Update struct {
+	Owner      string
+	Name       string
+	Pages      int
+	TotalPages int // 0 while unknown
+	Commits    int
+	Done       bool
+	Err        error
+}
+
+type key struct {
+	tenantID    int
+	owner, name string
+}
+
+// Tracker keeps the latest progress of every fetch. The most recent fetch of
+// a repository stays visible after it finishes.
+type Tracker struct {
+	mu          sync.Mutex
+	fetches     map[key]*Fetch
+	now         func() time.Time
+	logInterval time.Duration
+}
+
+// NewTracker creates a tracker logging running fetches every logInterval
+func NewTracker(logInterval time.Duration) *Tracker {
+	if logInterval <= 0 {
+		logInterval = DefaultLogInterval
+	}
+	return &Tracker{
+		fetches:     make(map[key]*Fetch),
+		now:         time.Now,
+		logInterval: logInterval,
+	}
+}
+
+// Report records an update for a fetch of the given tenant. Reports to a nil
+// tracker are discarded.
+func (t *Tracker) Report(tenantID int, u Update) {
+	if t == nil {
+		return
+	}
+	now := t.now()
+	k := key{tenantID, u.Owner, u.Name}
+
+	t.mu.Lock()
+	f, ok := t.fetches[k]
+	if !ok || f.Done {
+		// A fresh report after a finished fetch starts the next one
+		f = &Fetch{TenantID: tenantID, Owner: u.Owner, Name: u.Name, StartedAt: now, lastLogged: now}
+		t.fetches[k] = f
+	}
+	newPages, newCommits := u.Pages-f.Pages, u.Commits-f.Commits
+	f.Pages, f.Commits, f.UpdatedAt = u.Pages, u.Commits, now
+	if u.TotalPages > 0 {
+		f.TotalPages = u.TotalPages
+	}
+	f.Done = u.Done
+	if u.Err != nil {
+		f.Error = u.Err.Error()
+	}
+	f.ETA = nil
+	if remaining := f.Remaining(); !f.Done && remaining > 0 && f.Pages > 0 {
+		perPage := now.Sub(f.StartedAt) / time.Duration(f.Pages)
+		eta := now.Add(perPage * time.Duration(remaining))
+		f.ETA = &eta
+	}
+	shouldLog := f.Done || now.Sub(f.lastLogged) >= t.logInterval
+	if shouldLog {
+		f.lastLogged = now
+	}
+	snapshot := *f
+	t.mu.Unlock()
+
+	repoKey := metrics.RepoKey(u.Owner, u.Name)
+	if newPages > 0 {
+		metrics.PagesFetched.Add(repoKey, int64(newPages))
+	}
+	if newCommits > 0 {
+		metrics.CommitsFetched.Add(repoKey, int64(newCommits))
+	}
+	remaining := new(expvar.Int)
+	if !snapshot.Done {
+		remaining.Set(int64(snapshot.Remaining()))
+	}
+	metrics.PagesRemaining.Set(repoKey, remaining)
+
+	if shouldLog {
+		logFetch(snapshot)
+	}
+}
+
+// Snapshot returns the fetches of a tenant ordered by repository
+func (t *Tracker) Snapshot(tenantID int) []Fetch {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	fetches := make([]Fetch, 0, len(t.fetches))
+	for k, f := range t.fetches {
+		if k.tenantID == tenantID {
+			fetches = append(fetches, *f)
+		}
+	}
+	sort.Slice(fetches, func(i, j int) bool {
+		if fetches[i].Owner != fetches[j].Owner {
+			return fetches[i].Owner < fetches[j].Owner
+		}
+		return fetches[i].Name < fetches[j].Name
+	})
+	return fetches
+}
+
+func logFetch(f Fetch) {
+	fields := []zap.Field{
+		zap.Int("tenant_id", f.TenantID),
+		zap.String("owner", f.Owner),
+		zap.String("name", f.Name),
+		zap.Int("pages", f.Pages),
+		zap.Int("commits", f.Commits),
+		zap.Duration("elapsed", f.UpdatedAt.Sub(f.StartedAt)),
+	}
+	if f.TotalPages > 0 {
+		fields = append(fields, zap.Int("total_pages", f.TotalPages), zap.Int("pages_remaining", f.Remaining()))
+	}
+	if f.ETA != nil {
+		fields = append(fields, zap.Time("eta", *f.ETA))
+	}
+
+	switch {
+	case f.Error != "":
+		logger.Warn("Commit fetch failed", append(fields, zap.String("error", f.Error))...)
+	case f.Done:
+		logger.Info("Commit fetch finished", fields...)
+	default:
+		logger.Info("Commit fetch progress", fields...)
+	}
+}
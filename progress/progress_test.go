@@ -0,0 +1,77 @@
+package progress
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"githubapifetch/metrics"
+)
+
+func TestTrackerEstimatesRemainingTime(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := start
+	tracker := NewTracker(time.Minute)
+	tracker.now = func() time.Time { return clock }
+
+	tracker.Report(1, Update{Owner: "octo", Name: "hello"})
+	clock = start.Add(20 * time.Second)
+	tracker.Report(1, Update{Owner: "octo", Name: "hello", Pages: 2, TotalPages: 10, Commits: 200})
+
+	fetches := tracker.Snapshot(1)
+	require.Len(t, fetches, 1)
+	f := fetches[0]
+	assert.Equal(t, 2, f.Pages)
+	assert.Equal(t, 8, f.Remaining())
+	assert.Equal(t, 200, f.Commits)
+	// 10s per page for the 8 pages left
+	require.NotNil(t, f.ETA)
+	assert.Equal(t, clock.Add(80*time.Second), *f.ETA)
+
+	assert.Equal(t, "8", metrics.PagesRemaining.Get(metrics.RepoKey("octo", "hello")).String())
+
+	// The last page carries no Link "last" entry; the known total is kept
+	clock = start.Add(100 * time.Second)
+	tracker.Report(1, Update{Owner: "octo", Name: "hello", Pages: 10, Commits: 950, Done: true})
+
+	f = tracker.Snapshot(1)[0]
+	assert.True(t, f.Done)
+	assert.Equal(t, 10, f.TotalPages)
+	assert.Nil(t, f.ETA)
+	assert.Equal(t, "0", metrics.PagesRemaining.Get(metrics.RepoKey("octo", "hello")).String())
+}
+
+func TestTrackerRestartsFinishedFetches(t *testing.T) {
+	tracker := NewTracker(time.Minute)
+
+	tracker.Report(1, Update{Owner: "octo", Name: "hello", Pages: 3, Commits: 300, Done: true, Err: errors.New("boom")})
+	assert.Equal(t, "boom", tracker.Snapshot(1)[0].Error)
+
+	tracker.Report(1, Update{Owner: "octo", Name: "hello", Pages: 1, Commits: 100})
+	f := tracker.Snapshot(1)[0]
+	assert.False(t, f.Done)
+	assert.Empty(t, f.Error)
+	assert.Equal(t, 1, f.Pages)
+}
+
+func TestTrackerSnapshotIsTenantScoped(t *testing.T) {
+	tracker := NewTracker(time.Minute)
+	tracker.Report(1, Update{Owner: "octo", Name: "b"})
+	tracker.Report(1, Update{Owner: "octo", Name: "a"})
+	tracker.Report(2, Update{Owner: "acme", Name: "c"})
+
+	fetches := tracker.Snapshot(1)
+	require.Len(t, fetches, 2)
+	assert.Equal(t, "a", fetches[0].Name)
+	assert.Equal(t, "b", fetches[1].Name)
+	assert.Len(t, tracker.Snapshot(2), 1)
+	assert.Empty(t, tracker.Snapshot(3))
+}
+
+func TestNilTrackerDiscardsReports(t *testing.T) {
+	var tracker *Tracker
+	assert.NotPanics(t, func() { tracker.Report(1, Update{Owner: "octo", Name: "hello"}) })
+}
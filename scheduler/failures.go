@@ -0,0 +1,123 @@
+package scheduler
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// RepoError is the failure of one repository. The errors of a cycle are
+// joined with errors.Join, so errors.As finds each of them and errors.Is
+// matches their causes.
+type RepoError struct {
+	Owner string
+	Name  string
+	Err   error
+}
+
+func (e *RepoError) Error() string {
+	return e.Owner + "/" + e.Name + ": " + e.Err.Error()
+}
+
+func (e *RepoError) Unwrap() error {
+	return e.Err
+}
+
+// RepoErrors returns the repository failures joined into err
+func RepoErrors(err error) []*RepoError {
+	var errs []*RepoError
+	var walk func(error)
+	walk = func(err error) {
+		switch e := err.(type) {
+		case nil:
+		case *RepoError:
+			errs = append(errs, e)
+		case interface{ Unwrap() []error }:
+			for _, err := range e.Unwrap() {
+				walk(err)
+			}
+		case interface{ Unwrap() error }:
+			walk(e.Unwrap())
+		}
+	}
+	walk(err)
+	return errs
+}
+
+// Failure is the latest failed check of a repository
+type Failure struct {
+	TenantID int       `json:"tenant_id"`
+	Owner    string    `json:"owner"`
+	Name     string    `json:"name"`
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+type key struct {
+	tenantID    int
+	owner, name string
+}
+
+// Failures keeps the repositories whose latest check failed, until one
+// succeeds
+type Failures struct {
+	mu       sync.Mutex
+	failures map[key]Failure
+}
+
+// NewFailures returns an empty failure tracker
+func NewFailures() *Failures {
+	return &Failures{failures: make(map[key]Failure)}
+}
+
+// record records the outcome of checking a repository; a nil err clears its
+// failure
+func (f *Failures) record(tenantID int, owner, name string, err error, at time.Time) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	k := key{tenantID, owner, name}
+	if err == nil {
+		delete(f.failures, k)
+		return
+	}
+	f.failures[k] = Failure{TenantID: tenantID, Owner: owner, Name: name, Error: err.Error(), FailedAt: at}
+}
+
+// Forget drops the failure of a repository that is no longer monitored
+func (f *Failures) Forget(tenantID int, name string) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for k := range f.failures {
+		if k.tenantID == tenantID && k.name == name {
+			delete(f.failures, k)
+		}
+	}
+}
+
+// Snapshot returns the failing repositories of a tenant, ordered by name
+func (f *Failures) Snapshot(tenantID int) []Failure {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	failures := []Failure{}
+	for k, failure := range f.failures {
+		if k.tenantID == tenantID {
+			failures = append(failures, failure)
+		}
+	}
+	sort.Slice(failures, func(i, j int) bool {
+		if failures[i].Owner != failures[j].Owner {
+			return failures[i].Owner < failures[j].Owner
+		}
+		return failures[i].Name < failures[j].Name
+	})
+	return failures
+}
@@ -0,0 +1,257 @@
+// Package scheduler checks the repositories of a tenant for changes on a
+// fixed interval. It owns the ticker and the pool of workers syncing the
+// repositories due for a check, and asks its store only for data, so it runs
+// against any storage and can be tested without a database.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"githubapifetch/clock"
+	"githubapifetch/logger"
+	"githubapifetch/metrics"
+	"githubapifetch/models"
+	"githubapifetch/tenant"
+)
+
+// Store is the data the scheduler needs. Every method is scoped to the tenant
+// of the context.
+type Store interface {
+	// DueRepositories returns the repositories due for a check
+	DueRepositories(ctx context.Context) ([]models.Repository, error)
+	// LatestCommitDate returns the date of a repository's newest stored
+	// commit, or the zero time when none is stored
+	LatestCommitDate(ctx context.Context, repoID int) (time.Time, error)
+	// MarkChecked records that a repository with its own poll interval was
+	// checked
+	MarkChecked(ctx context.Context, repoID int) error
+	// MarkSynced records that a sync of a repository finished, and whether
+	// it succeeded, unless shutdown cut it short
+	MarkSynced(ctx context.Context, repoID int, succeeded bool) error
+}
+
+// SyncFunc syncs a stored repository, with its own owner, from a date. A zero
+// date syncs it from its creation.
+type SyncFunc func(ctx context.Context, repo models.Repository, since time.Time) error
+
+// DefaultWorkers is how many repositories a cycle syncs at once by default
+const DefaultWorkers = 5
+
+// Options configures a Scheduler
+type Options struct {
+	// Interval is the time between cycles
+	Interval time.Duration
+	// DefaultStart is where repositories without commits are synced from when
+	// they have no start date of their own; zero for their creation
+	DefaultStart time.Time
+	// Workers bounds how many repositories a cycle syncs at once;
+	// DefaultWorkers when zero
+	Workers int
+	// Failures, if set, keeps the repositories whose latest check failed
+	Failures *Failures
+	Clock    clock.Clock
+}
+
+// Scheduler runs monitoring cycles
+type Scheduler struct {
+	store Store
+	sync  SyncFunc
+	opts  Options
+}
+
+// New returns a scheduler syncing the repositories of store with sync
+func New(store Store, sync SyncFunc, opts Options) *Scheduler {
+	if opts.Workers <= 0 {
+		opts.Workers = DefaultWorkers
+	}
+	if opts.Clock == nil {
+		opts.Clock = clock.Real
+	}
+	return &Scheduler{store: store, sync: sync, opts: opts}
+}
+
+// Run checks the repositories of the tenant the context is scoped to every
+// interval, starting one interval from now, until ctx is done
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := s.opts.Clock.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			started := s.opts.Clock.Now()
+			summary, err := s.cycle(ctx)
+			summary.publish(ctx, s.opts.Clock.Now().Sub(started))
+			if err != nil {
+				logger.Error("Error checking repositories",
+					zap.Int("tenant_id", tenant.FromContext(ctx)),
+					zap.Error(err))
+			}
+		}
+	}
+}
+
+// Summary counts what one cycle did with the repositories due for a check.
+// Every checked repository is either processed or failed; Backfilled counts
+// those among them without stored commits, which were synced from their
+// start date.
+type Summary struct {
+	Checked    int64
+	Processed  int64
+	Failed     int64
+	Backfilled int64
+}
+
+// publish logs the summary of a cycle and adds it to the monitoring metrics
+func (s *Summary) publish(ctx context.Context, elapsed time.Duration) {
+	metrics.MonitorCycles.Add(1)
+	metrics.MonitorCycleMillis.Set(elapsed.Milliseconds())
+	metrics.MonitorRepositories.Add("checked", s.Checked)
+	metrics.MonitorRepositories.Add("processed", s.Processed)
+	metrics.MonitorRepositories.Add("failed", s.Failed)
+	metrics.MonitorRepositories.Add("backfilled", s.Backfilled)
+
+	logger.Info("Monitoring cycle finished",
+		zap.Int("tenant_id", tenant.FromContext(ctx)),
+		zap.Int64("checked", s.Checked),
+		zap.Int64("processed", s.Processed),
+		zap.Int64("failed", s.Failed),
+		zap.Int64("backfilled", s.Backfilled),
+		zap.Duration("duration", elapsed))
+}
+
+// cycle syncs every repository due for a check from its newest stored
+// commit. The failure of each repository is a RepoError. Repositories
+// without commits, such as one whose initial sync failed, are synced from
+// their start date, or the default start date without one, until their
+// commits are stored. Workers take the repositories longest without a
+// successful sync first.
+func (s *Scheduler) cycle(ctx context.Context) (*Summary, error) {
+	summary := &Summary{}
+	repos, err := s.store.DueRepositories(ctx)
+	if err != nil {
+		return summary, err
+	}
+	summary.Checked = int64(len(repos))
+	prioritize(repos)
+
+	sem := make(chan struct{}, s.opts.Workers)
+	errChan := make(chan error, len(repos))
+	var wg sync.WaitGroup
+
+	for _, repo := range repos {
+		// Repositories still waiting for a worker at shutdown are not
+		// started
+		if !acquire(ctx, sem) {
+			atomic.AddInt64(&summary.Failed, 1)
+			errChan <- &RepoError{Owner: repo.Owner, Name: repo.Name, Err: ctx.Err()}
+			continue
+		}
+		wg.Add(1)
+		go func(repo models.Repository) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			err := s.check(ctx, repo, summary)
+			s.opts.Failures.record(tenant.FromContext(ctx), repo.Owner, repo.Name, err, s.opts.Clock.Now())
+			if err != nil {
+				atomic.AddInt64(&summary.Failed, 1)
+				errChan <- &RepoError{Owner: repo.Owner, Name: repo.Name, Err: err}
+				return
+			}
+			atomic.AddInt64(&summary.Processed, 1)
+		}(repo)
+	}
+
+	wg.Wait()
+	close(errChan)
+
+	// Collect errors
+	var errs []error
+	for err := range errChan {
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return summary, fmt.Errorf("errors occurred while processing repositories: %w", errors.Join(errs...))
+	}
+
+	return summary, nil
+}
+
+// prioritize orders repositories by how long they have gone without a
+// successful sync, those that never had one first
+func prioritize(repos []models.Repository) {
+	sort.SliceStable(repos, func(i, j int) bool {
+		a, b := repos[i].LastSuccessAt, repos[j].LastSuccessAt
+		if a == nil || b == nil {
+			return a == nil && b != nil
+		}
+		return a.Before(*b)
+	})
+}
+
+// acquire takes a worker from sem, reporting false when ctx is done first
+func acquire(ctx context.Context, sem chan struct{}) bool {
+	select {
+	case sem <- struct{}{}:
+		if ctx.Err() != nil {
+			<-sem
+			return false
+		}
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// check syncs one repository due for a check
+func (s *Scheduler) check(ctx context.Context, repo models.Repository, summary *Summary) error {
+	if repo.PollInterval > 0 {
+		if err := s.store.MarkChecked(ctx, repo.ID); err != nil {
+			return err
+		}
+	}
+
+	since, err := s.store.LatestCommitDate(ctx, repo.ID)
+	if err != nil {
+		return fmt.Errorf("error getting latest date for repository %s: %w", repo.Name, err)
+	}
+	if since.IsZero() {
+		// A zero date syncs from the repository's creation
+		atomic.AddInt64(&summary.Backfilled, 1)
+		switch {
+		case repo.StartDateAuto:
+		case repo.StartDate != nil:
+			since = *repo.StartDate
+		default:
+			since = s.opts.DefaultStart
+		}
+		logger.Debug("No commits found for repository, syncing from its start date",
+			zap.String("repo_owner", repo.Owner),
+			zap.String("repo_name", repo.Name),
+			zap.Time("start_date", since))
+	}
+
+	syncErr := s.sync(ctx, repo, since)
+	// A sync cut short by shutdown did not finish
+	if ctx.Err() == nil {
+		if err := s.store.MarkSynced(ctx, repo.ID, syncErr == nil); err != nil && syncErr == nil {
+			return err
+		}
+	}
+	if syncErr != nil {
+		return fmt.Errorf("error processing repository %s: %w", repo.Name, syncErr)
+	}
+	return nil
+}
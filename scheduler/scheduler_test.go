@@ -0,0 +1,210 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"githubapifetch/clock"
+	"githubapifetch/models"
+	"githubapifetch/tenant"
+)
+
+// fakeStore serves repositories and commit dates from memory
+type fakeStore struct {
+	mu      sync.Mutex
+	repos   []models.Repository
+	latest  map[int]time.Time
+	checked []int
+	synced  []int
+	failed  []int
+	tenants []int
+}
+
+func (f *fakeStore) DueRepositories(ctx context.Context) ([]models.Repository, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tenants = append(f.tenants, tenant.FromContext(ctx))
+	return f.repos, nil
+}
+
+func (f *fakeStore) LatestCommitDate(ctx context.Context, repoID int) (time.Time, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.latest[repoID], nil
+}
+
+func (f *fakeStore) MarkChecked(ctx context.Context, repoID int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.checked = append(f.checked, repoID)
+	return nil
+}
+
+func (f *fakeStore) MarkSynced(ctx context.Context, repoID int, succeeded bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.synced = append(f.synced, repoID)
+	if !succeeded {
+		f.failed = append(f.failed, repoID)
+	}
+	return nil
+}
+
+func TestSchedulerRun(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	latest := time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC)
+	store := &fakeStore{
+		repos: []models.Repository{
+			{ID: 1, Owner: "octo", Name: "hello"},
+			{ID: 2, Owner: "octo", Name: "slow", PollInterval: 3600},
+		},
+		latest: map[int]time.Time{1: latest, 2: latest},
+	}
+
+	ctx, cancel := context.WithCancel(tenant.WithID(context.Background(), 1))
+	defer cancel()
+	synced := make(chan string, 2)
+	sched := New(store, func(ctx context.Context, repo models.Repository, since time.Time) error {
+		assert.Equal(t, latest, since)
+		synced <- repo.Owner + "/" + repo.Name
+		return nil
+	}, Options{Interval: time.Minute, Clock: fake})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		sched.Run(ctx)
+	}()
+
+	// Nothing is checked until the first tick
+	fake.BlockUntil(1)
+	select {
+	case repo := <-synced:
+		t.Fatalf("%s checked before the first tick", repo)
+	default:
+	}
+
+	fake.Advance(time.Minute)
+	assert.ElementsMatch(t, []string{"octo/hello", "octo/slow"}, []string{<-synced, <-synced})
+	// Every finished sync is recorded
+	require.Eventually(t, func() bool {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		return len(store.synced) == 2
+	}, time.Second, time.Millisecond)
+	assert.ElementsMatch(t, []int{1, 2}, store.synced)
+
+	// Monitoring returns once the context is done
+	cancel()
+	<-stopped
+	assert.Equal(t, []int{1}, store.tenants)
+	// Only repositories with their own poll interval are marked checked
+	assert.Equal(t, []int{2}, store.checked)
+}
+
+var errSyncFailed = errors.New("sync failed")
+
+func TestSchedulerStartDates(t *testing.T) {
+	start := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	defaultStart := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := &fakeStore{repos: []models.Repository{
+		{ID: 1, Owner: "octo", Name: "fixed", StartDate: &start},
+		{ID: 2, Owner: "octo", Name: "auto", StartDateAuto: true},
+		{ID: 3, Owner: "octo", Name: "unset"},
+	}}
+
+	var mu sync.Mutex
+	synced := map[string]time.Time{}
+	sched := New(store, func(ctx context.Context, repo models.Repository, since time.Time) error {
+		mu.Lock()
+		defer mu.Unlock()
+		synced[repo.Name] = since
+		if repo.Name == "fixed" {
+			return errSyncFailed
+		}
+		return nil
+	}, Options{DefaultStart: defaultStart})
+
+	summary, err := sched.cycle(context.Background())
+	require.ErrorContains(t, err, "error processing repository fixed")
+	assert.Equal(t, &Summary{Checked: 3, Processed: 2, Failed: 1, Backfilled: 3}, summary)
+	// Without commits, repositories sync from their start date, a zero date
+	// standing for their creation, or else from the default start date
+	assert.Equal(t, map[string]time.Time{"fixed": start, "auto": {}, "unset": defaultStart}, synced)
+}
+
+func TestSchedulerPrioritizesLag(t *testing.T) {
+	recent := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	old := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	store := &fakeStore{repos: []models.Repository{
+		{ID: 1, Owner: "octo", Name: "recent", LastSuccessAt: &recent},
+		{ID: 2, Owner: "octo", Name: "never"},
+		{ID: 3, Owner: "octo", Name: "old", LastSuccessAt: &old},
+	}}
+
+	var order []string
+	sched := New(store, func(ctx context.Context, repo models.Repository, since time.Time) error {
+		order = append(order, repo.Name)
+		if repo.Name == "old" {
+			return errSyncFailed
+		}
+		return nil
+	}, Options{Workers: 1})
+
+	_, err := sched.cycle(context.Background())
+	require.ErrorIs(t, err, errSyncFailed)
+	// With one worker, the repositories are synced longest lagging first
+	assert.Equal(t, []string{"never", "old", "recent"}, order)
+	assert.Equal(t, []int{2, 3, 1}, store.synced)
+	assert.Equal(t, []int{3}, store.failed)
+}
+
+func TestSchedulerFailures(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	store := &fakeStore{repos: []models.Repository{
+		{ID: 1, Owner: "octo", Name: "hello"},
+		{ID: 2, Owner: "octo", Name: "broken"},
+		{ID: 3, Owner: "acme", Name: "gone"},
+	}}
+	errGone := errors.New("repository gone")
+	failing := map[string]error{"broken": errSyncFailed, "gone": errGone}
+	failures := NewFailures()
+	sched := New(store, func(ctx context.Context, repo models.Repository, since time.Time) error {
+		return failing[repo.Name]
+	}, Options{Failures: failures, Clock: fake})
+	ctx := tenant.WithID(context.Background(), 2)
+
+	_, err := sched.cycle(ctx)
+	require.Error(t, err)
+	// Each failure stays inspectable and attributed to its repository
+	assert.ErrorIs(t, err, errSyncFailed)
+	assert.ErrorIs(t, err, errGone)
+	repoErrs := RepoErrors(err)
+	require.Len(t, repoErrs, 2)
+	names := []string{repoErrs[0].Owner + "/" + repoErrs[0].Name, repoErrs[1].Owner + "/" + repoErrs[1].Name}
+	assert.ElementsMatch(t, []string{"octo/broken", "acme/gone"}, names)
+
+	snapshot := failures.Snapshot(2)
+	require.Len(t, snapshot, 2)
+	assert.Equal(t, "acme", snapshot[0].Owner)
+	assert.Equal(t, "broken", snapshot[1].Name)
+	assert.Contains(t, snapshot[1].Error, "sync failed")
+	assert.Equal(t, fake.Now(), snapshot[1].FailedAt)
+	assert.Empty(t, failures.Snapshot(1))
+
+	// A successful check clears the failure of a repository
+	delete(failing, "broken")
+	_, err = sched.cycle(ctx)
+	require.Error(t, err)
+	snapshot = failures.Snapshot(2)
+	require.Len(t, snapshot, 1)
+	assert.Equal(t, "gone", snapshot[0].Name)
+
+	failures.Forget(2, "gone")
+	assert.Empty(t, failures.Snapshot(2))
+}
@@ -0,0 +1,196 @@
+// Package secrets provides envelope encryption for sensitive values stored at rest.
+//
+// Each value is encrypted with a fresh data key using AES-256-GCM. The data key
+// is wrapped by a KeyWrapper (a static key from configuration, or a KMS) and
+// stored alongside the ciphertext, so rotating the wrapping key only requires
+// re-wrapping data keys.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// prefix marks values produced by Encrypt
+const prefix = "enc:v1:"
+
+// dataKeySize is the size of generated data keys (AES-256)
+const dataKeySize = 32
+
+// Errors
+var (
+	ErrNoKey           = errors.New("no encryption key configured")
+	ErrInvalidKey      = errors.New("invalid encryption key")
+	ErrMalformedSecret = errors.New("malformed encrypted value")
+)
+
+// KeyWrapper wraps and unwraps data keys with a key encryption key
+type KeyWrapper interface {
+	Wrap(dataKey []byte) ([]byte, error)
+	Unwrap(wrapped []byte) ([]byte, error)
+}
+
+// Encryptor encrypts and decrypts values for storage
+type Encryptor interface {
+	Encrypt(plaintext string) (string, error)
+	Decrypt(value string) (string, error)
+}
+
+// StaticKeyWrapper wraps data keys with a fixed AES-256 key
+type StaticKeyWrapper struct {
+	aead cipher.AEAD
+}
+
+// NewStaticKeyWrapper creates a wrapper from a base64-encoded 32-byte key
+func NewStaticKeyWrapper(encodedKey string) (*StaticKeyWrapper, error) {
+	if encodedKey == "" {
+		return nil, ErrNoKey
+	}
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: key must be base64 encoded: %v", ErrInvalidKey, err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("%w: key must be 32 bytes, got %d", ErrInvalidKey, len(key))
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &StaticKeyWrapper{aead: aead}, nil
+}
+
+// Wrap encrypts a data key
+func (w *StaticKeyWrapper) Wrap(dataKey []byte) ([]byte, error) {
+	return seal(w.aead, dataKey)
+}
+
+// Unwrap decrypts a data key
+func (w *StaticKeyWrapper) Unwrap(wrapped []byte) ([]byte, error) {
+	return open(w.aead, wrapped)
+}
+
+// EnvelopeEncryptor encrypts each value with its own wrapped data key
+type EnvelopeEncryptor struct {
+	wrapper KeyWrapper
+}
+
+// NewEnvelopeEncryptor creates an encryptor using the given key wrapper
+func NewEnvelopeEncryptor(wrapper KeyWrapper) *EnvelopeEncryptor {
+	return &EnvelopeEncryptor{wrapper: wrapper}
+}
+
+// Encrypt encrypts plaintext. Empty values are stored as-is.
+func (e *EnvelopeEncryptor) Encrypt(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := seal(aead, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+
+	wrapped, err := e.wrapper.Wrap(dataKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to wrap data key: %w", err)
+	}
+
+	// Layout: uint16 wrapped key length | wrapped key | nonce + ciphertext
+	buf := make([]byte, 2, 2+len(wrapped)+len(sealed))
+	binary.BigEndian.PutUint16(buf, uint16(len(wrapped)))
+	buf = append(buf, wrapped...)
+	buf = append(buf, sealed...)
+
+	return prefix + base64.StdEncoding.EncodeToString(buf), nil
+}
+
+// Decrypt decrypts a value produced by Encrypt. Values without the encryption
+// prefix are legacy plaintext and returned unchanged.
+func (e *EnvelopeEncryptor) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	buf, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, prefix))
+	if err != nil || len(buf) < 2 {
+		return "", ErrMalformedSecret
+	}
+	wrappedLen := int(binary.BigEndian.Uint16(buf))
+	if len(buf) < 2+wrappedLen {
+		return "", ErrMalformedSecret
+	}
+
+	dataKey, err := e.wrapper.Unwrap(buf[2 : 2+wrappedLen])
+	if err != nil {
+		return "", fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(aead, buf[2+wrappedLen:])
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// IsEncrypted reports whether a stored value was produced by Encrypt
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// HashToken returns the hex SHA-256 digest of a lookup token. Tokens that are
+// only ever compared are stored hashed rather than encrypted.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts data, prefixing the result with a random nonce
+func seal(aead cipher.AEAD, data []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, data, nil), nil
+}
+
+// open decrypts data produced by seal
+func open(aead cipher.AEAD, data []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, ErrMalformedSecret
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedSecret, err)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,83 @@
+package secrets
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestKey(t *testing.T) string {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func TestEnvelopeEncryptorRoundTrip(t *testing.T) {
+	wrapper, err := NewStaticKeyWrapper(newTestKey(t))
+	require.NoError(t, err)
+	enc := NewEnvelopeEncryptor(wrapper)
+
+	encrypted, err := enc.Encrypt("github_pat_secret")
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(encrypted))
+	assert.NotContains(t, encrypted, "github_pat_secret")
+
+	// Every encryption uses a fresh data key and nonce
+	again, err := enc.Encrypt("github_pat_secret")
+	require.NoError(t, err)
+	assert.NotEqual(t, encrypted, again)
+
+	decrypted, err := enc.Decrypt(encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "github_pat_secret", decrypted)
+}
+
+func TestEnvelopeEncryptorLegacyPlaintext(t *testing.T) {
+	wrapper, err := NewStaticKeyWrapper(newTestKey(t))
+	require.NoError(t, err)
+	enc := NewEnvelopeEncryptor(wrapper)
+
+	decrypted, err := enc.Decrypt("plain-token")
+	require.NoError(t, err)
+	assert.Equal(t, "plain-token", decrypted)
+
+	empty, err := enc.Encrypt("")
+	require.NoError(t, err)
+	assert.Equal(t, "", empty)
+}
+
+func TestEnvelopeEncryptorWrongKey(t *testing.T) {
+	wrapper, err := NewStaticKeyWrapper(newTestKey(t))
+	require.NoError(t, err)
+	encrypted, err := NewEnvelopeEncryptor(wrapper).Encrypt("secret")
+	require.NoError(t, err)
+
+	other, err := NewStaticKeyWrapper(newTestKey(t))
+	require.NoError(t, err)
+	_, err = NewEnvelopeEncryptor(other).Decrypt(encrypted)
+	assert.ErrorIs(t, err, ErrMalformedSecret)
+
+	_, err = NewEnvelopeEncryptor(other).Decrypt(prefix + "not-base64!")
+	assert.ErrorIs(t, err, ErrMalformedSecret)
+}
+
+func TestNewStaticKeyWrapperValidation(t *testing.T) {
+	_, err := NewStaticKeyWrapper("")
+	assert.ErrorIs(t, err, ErrNoKey)
+
+	_, err = NewStaticKeyWrapper("not base64!")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+
+	_, err = NewStaticKeyWrapper(base64.StdEncoding.EncodeToString([]byte("short")))
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
+
+func TestHashToken(t *testing.T) {
+	assert.Equal(t, HashToken("abc"), HashToken("abc"))
+	assert.NotEqual(t, HashToken("abc"), HashToken("abd"))
+	assert.Len(t, HashToken("abc"), 64)
+}
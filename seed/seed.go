@@ -0,0 +1,192 @@
+// Package seed generates synthetic repositories and commit histories for
+// development, so the API and queries can be exercised without GitHub.
+//
+// Histories look like real ones where it matters for queries: a few authors
+// make most of the commits, commits cluster on working hours and weekdays,
+// repository sizes follow a long tail and every commit records its parents,
+// with the occasional merge. The same seed always yields the same data.
+package seed
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"githubapifetch/models"
+)
+
+// Owner owns every generated repository
+const Owner = "seed"
+
+// PageSize is the number of commits History hands over at a time
+const PageSize = 100
+
+var (
+	prefixes    = []string{"payments", "billing", "search", "auth", "catalog", "checkout", "notifications", "inventory", "analytics", "gateway", "profile", "shipping"}
+	suffixes    = []string{"api", "service", "worker", "web", "sdk", "cli"}
+	languages   = []string{"Go", "Go", "Go", "TypeScript", "TypeScript", "Python", "Java", "Rust"}
+	firstNames  = []string{"Alex", "Sam", "Jordan", "Taylor", "Morgan", "Casey", "Riley", "Jamie", "Avery", "Quinn", "Robin", "Drew", "Skyler", "Reese", "Emerson"}
+	lastNames   = []string{"Chen", "Garcia", "Okafor", "Novak", "Silva", "Kim", "Haddad", "Larsen", "Patel", "Moreau", "Ito", "Kowalski"}
+	kinds       = []string{"fix", "feat", "chore", "refactor", "docs", "test", "perf"}
+	scopes      = []string{"api", "db", "ui", "config", "auth", "ci", "deps", "logging"}
+	subjects    = []string{"handle empty responses", "retry on timeout", "add pagination", "remove dead code", "update dependencies", "tighten validation", "cache lookups", "fix flaky test", "rename fields", "improve error messages", "add metrics", "bump version"}
+	branchNames = []string{"feature", "bugfix", "hotfix", "experiment"}
+)
+
+// Generator produces synthetic data from a seeded source
+type Generator struct {
+	rng *rand.Rand
+	// end is the date of the newest generated commit
+	end time.Time
+}
+
+// New returns a generator whose histories end at end
+func New(seed int64, end time.Time) *Generator {
+	return &Generator{rng: rand.New(rand.NewSource(seed)), end: end.UTC()}
+}
+
+// Split divides total commits between n repositories with a long tail: the
+// first repositories get the most
+func (g *Generator) Split(total, n int) []int {
+	if n <= 0 {
+		return nil
+	}
+	weights := make([]float64, n)
+	sum := 0.0
+	for i := range weights {
+		weights[i] = 1 / math.Pow(float64(i+1), 0.8) * (0.75 + g.rng.Float64()/2)
+		sum += weights[i]
+	}
+	counts := make([]int, n)
+	assigned := 0
+	for i, w := range weights {
+		counts[i] = int(float64(total) * w / sum)
+		assigned += counts[i]
+	}
+	// Rounding leftovers go to the largest repository
+	counts[0] += total - assigned
+	return counts
+}
+
+// Repository returns the i-th repository. Names are unique across i.
+func (g *Generator) Repository(i int) models.Repository {
+	name := prefixes[i%len(prefixes)] + "-" + suffixes[(i/len(prefixes))%len(suffixes)]
+	if round := i / (len(prefixes) * len(suffixes)); round > 0 {
+		name = fmt.Sprintf("%s-%d", name, round+1)
+	}
+	created := g.end.AddDate(-1-g.rng.Intn(6), -g.rng.Intn(12), 0)
+	stars := int(math.Exp(g.rng.Float64() * 8))
+	return models.Repository{
+		Owner:           Owner,
+		Name:            name,
+		Description:     fmt.Sprintf("Synthetic %s repository", name),
+		URL:             "https://github.com/" + Owner + "/" + name,
+		Language:        languages[g.rng.Intn(len(languages))],
+		StarsCount:      stars,
+		WatchersCount:   stars,
+		ForksCount:      stars / 10,
+		OpenIssuesCount: g.rng.Intn(50),
+		CreatedAt:       created,
+		UpdatedAt:       g.end,
+	}
+}
+
+// History generates n commits of repo, oldest first, spread from its
+// creation to the generator's end date, and hands them to fn PageSize at a
+// time
+func (g *Generator) History(repo models.Repository, n int, fn func([]models.Commit) error) error {
+	if n <= 0 {
+		return nil
+	}
+	authors := g.authors()
+	zipf := rand.NewZipf(g.rng, 1.3, 1, uint64(len(authors)-1))
+	span := g.end.Sub(repo.CreatedAt)
+	if span <= 0 {
+		span = 24 * time.Hour
+	}
+	step := span / time.Duration(n)
+
+	var (
+		page   []models.Commit
+		recent []string
+		pr     = 1
+	)
+	for i := 0; i < n; i++ {
+		date := workingHours(repo.CreatedAt.Add(step*time.Duration(i)+time.Duration(g.rng.Int63n(int64(step)+1))), g.rng)
+		if date.After(g.end) {
+			date = g.end
+		}
+		sha := commitSHA(repo, i)
+		c := models.Commit{
+			SHA:           sha,
+			AuthorName:    authors[zipf.Uint64()],
+			Date:          date,
+			CommitterDate: date,
+			URL:           repo.URL + "/commit/" + sha,
+			APIURL:        "https://api.github.com/repos/" + repo.Owner + "/" + repo.Name + "/commits/" + sha,
+		}
+		if len(recent) > 0 {
+			c.Parents = []string{recent[len(recent)-1]}
+		}
+		if len(recent) > 3 && g.rng.Intn(8) == 0 {
+			// A merge of a branch that forked a few commits back
+			c.Parents = append(c.Parents, recent[len(recent)-2-g.rng.Intn(len(recent)-2)])
+			c.Message = fmt.Sprintf("Merge pull request #%d from %s/%s-%d", pr, repo.Owner, branchNames[g.rng.Intn(len(branchNames))], pr)
+			pr++
+		} else {
+			c.Message = fmt.Sprintf("%s(%s): %s", kinds[g.rng.Intn(len(kinds))], scopes[g.rng.Intn(len(scopes))], subjects[g.rng.Intn(len(subjects))])
+		}
+
+		recent = append(recent, sha)
+		if len(recent) > 10 {
+			recent = recent[1:]
+		}
+		page = append(page, c)
+		if len(page) == PageSize {
+			if err := fn(page); err != nil {
+				return err
+			}
+			page = nil
+		}
+	}
+	if len(page) > 0 {
+		return fn(page)
+	}
+	return nil
+}
+
+// authors returns the contributors of one repository, most active first
+func (g *Generator) authors() []string {
+	n := 3 + g.rng.Intn(28)
+	authors := make([]string, n)
+	for i := range authors {
+		authors[i] = firstNames[g.rng.Intn(len(firstNames))] + " " + lastNames[g.rng.Intn(len(lastNames))]
+	}
+	return authors
+}
+
+// workingHours moves most weekend and night-time dates into the working week
+func workingHours(t time.Time, rng *rand.Rand) time.Time {
+	if rng.Intn(10) == 0 {
+		return t
+	}
+	switch t.Weekday() {
+	case time.Saturday:
+		t = t.AddDate(0, 0, 2)
+	case time.Sunday:
+		t = t.AddDate(0, 0, 1)
+	}
+	if h := t.Hour(); h < 9 || h >= 18 {
+		t = time.Date(t.Year(), t.Month(), t.Day(), 9+rng.Intn(9), t.Minute(), t.Second(), 0, time.UTC)
+	}
+	return t
+}
+
+// commitSHA returns the SHA of the i-th commit of repo, stable across runs
+func commitSHA(repo models.Repository, i int) string {
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s/%s#%d", repo.Owner, repo.Name, i)))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,69 @@
+package seed
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"githubapifetch/models"
+)
+
+var end = time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func generate(t *testing.T, seed int64, repos, commits int) ([]models.Repository, [][]models.Commit) {
+	g := New(seed, end)
+	var (
+		generated []models.Repository
+		histories [][]models.Commit
+	)
+	for i, n := range g.Split(commits, repos) {
+		repo := g.Repository(i)
+		var history []models.Commit
+		require.NoError(t, g.History(repo, n, func(page []models.Commit) error {
+			assert.LessOrEqual(t, len(page), PageSize)
+			history = append(history, page...)
+			return nil
+		}))
+		generated = append(generated, repo)
+		histories = append(histories, history)
+	}
+	return generated, histories
+}
+
+func TestGeneratedDataIsValid(t *testing.T) {
+	repos, histories := generate(t, 1, 20, 5000)
+
+	names := map[string]bool{}
+	total := 0
+	for i, repo := range repos {
+		assert.False(t, names[repo.Name], "duplicate name %s", repo.Name)
+		names[repo.Name] = true
+		assert.Equal(t, Owner, repo.Owner)
+
+		shas := map[string]bool{}
+		for j, c := range histories[i] {
+			require.NoError(t, c.Validate(end), "commit %d of %s", j, repo.Name)
+			assert.False(t, shas[c.SHA])
+			shas[c.SHA] = true
+			for _, parent := range c.Parents {
+				assert.True(t, shas[parent], "parent of %s generated after it", c.SHA)
+			}
+			assert.Equal(t, j > 0, len(c.Parents) > 0)
+		}
+		total += len(histories[i])
+	}
+	assert.Equal(t, 5000, total)
+	assert.Greater(t, len(histories[0]), len(histories[len(histories)-1]))
+}
+
+func TestSameSeedSameData(t *testing.T) {
+	reposA, historiesA := generate(t, 7, 3, 300)
+	reposB, historiesB := generate(t, 7, 3, 300)
+	assert.Equal(t, reposA, reposB)
+	assert.Equal(t, historiesA, historiesB)
+
+	_, historiesC := generate(t, 8, 3, 300)
+	assert.NotEqual(t, historiesA, historiesC)
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"githubapifetch/audit"
+	"githubapifetch/config"
+	"githubapifetch/logger"
+	"githubapifetch/models"
+	"githubapifetch/tenant"
+)
+
+// AddRepository starts tracking a repository, performing its initial sync
+// from start, or the configured start date when start is nil. A zero start
+// syncs from the repository's creation on GitHub. A given start is kept on
+// the repository, so a later backfill begins there too.
+func (s *Service) AddRepository(ctx context.Context, owner, name string, start *time.Time) error {
+	if owner == "" || name == "" {
+		return fmt.Errorf("repository owner and name cannot be empty")
+	}
+
+	since := s.config.StartDate
+	if start != nil {
+		since = *start
+	}
+	err := s.addRepository(ctx, owner, name, since, start != nil)
+	s.recordAudit(ctx, audit.ActionAddRepo, map[string]interface{}{
+		"owner":      owner,
+		"repo":       name,
+		"start_date": config.FormatStartDate(since),
+	}, err)
+	return err
+}
+
+func (s *Service) addRepository(ctx context.Context, owner, name string, since time.Time, keepStart bool) error {
+	if err := s.processorFor(tenant.FromContext(ctx)).Process(ctx, owner, name, since); err != nil {
+		return fmt.Errorf("failed to sync repository %s/%s: %w", owner, name, err)
+	}
+	// The start date and status are set on the repository just synced
+	// only, not on another owner's repository of the same name
+	repo, err := s.database.GetByOwnerAndName(ctx, owner, name)
+	if err != nil {
+		return fmt.Errorf("failed to get repository %s/%s: %w", owner, name, err)
+	}
+	if keepStart {
+		if err := s.database.SetStartDate(ctx, repo.ID, since); err != nil {
+			return fmt.Errorf("failed to set start date of repository %s/%s: %w", owner, name, err)
+		}
+	}
+	// Re-adding a removed or paused repository resumes monitoring
+	if err := s.database.ActivateRepository(ctx, repo.ID); err != nil {
+		return fmt.Errorf("failed to activate repository %s/%s: %w", owner, name, err)
+	}
+	return nil
+}
+
+// RemoveRepository stops tracking a repository while keeping its stored data
+func (s *Service) RemoveRepository(ctx context.Context, name string) error {
+	return s.setRepositoryStatus(ctx, audit.ActionRemoveRepo, name, models.RepoStatusRemoved)
+}
+
+// PurgeRepository deletes a repository and everything stored for it, along
+// with its spooled pages, which would otherwise store it again. With dryRun
+// nothing is deleted and the result counts the rows that would be.
+func (s *Service) PurgeRepository(ctx context.Context, owner, name string, dryRun bool) (*models.PurgeResult, error) {
+	if owner == "" || name == "" {
+		return nil, fmt.Errorf("repository owner and name cannot be empty")
+	}
+
+	result, err := s.database.PurgeRepository(ctx, owner, name, dryRun)
+	if dryRun {
+		return result, err
+	}
+	params := map[string]interface{}{"owner": owner, "repo": name}
+	if result != nil {
+		for _, t := range result.Tables {
+			params[t.Table] = t.Rows
+		}
+	}
+	s.recordAudit(ctx, audit.ActionPurgeRepo, params, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to purge repository %s/%s: %w", owner, name, err)
+	}
+
+	tenantID := tenant.FromContext(ctx)
+	s.syncLag.Forget(tenantID, name)
+	s.failures.Forget(tenantID, name)
+	if s.spool != nil {
+		entries, err := s.spool.Pending(tenantID, result.Owner, result.Name)
+		if err != nil {
+			return result, fmt.Errorf("failed to read spooled pages of repository %s: %w", name, err)
+		}
+		for _, e := range entries {
+			if err := s.spool.Remove(e); err != nil {
+				return result, fmt.Errorf("failed to remove spooled pages of repository %s: %w", name, err)
+			}
+		}
+	}
+	return result, nil
+}
+
+// PauseRepository suspends monitoring of a repository
+func (s *Service) PauseRepository(ctx context.Context, name string) error {
+	return s.setRepositoryStatus(ctx, audit.ActionPauseRepo, name, models.RepoStatusPaused)
+}
+
+// ResumeRepository resumes monitoring of a paused repository
+func (s *Service) ResumeRepository(ctx context.Context, name string) error {
+	return s.setRepositoryStatus(ctx, audit.ActionResumeRepo, name, models.RepoStatusActive)
+}
+
+func (s *Service) setRepositoryStatus(ctx context.Context, action, name, status string) error {
+	if name == "" {
+		return fmt.Errorf("repository name cannot be empty")
+	}
+
+	err := s.database.SetRepositoryStatus(ctx, name, status)
+	s.recordAudit(ctx, action, map[string]interface{}{"repo": name}, err)
+	if err != nil {
+		return fmt.Errorf("failed to %s: %w", action, err)
+	}
+	// Repositories no longer monitored fall behind by design
+	if status != models.RepoStatusActive {
+		s.syncLag.Forget(tenant.FromContext(ctx), name)
+		s.failures.Forget(tenant.FromContext(ctx), name)
+	}
+	return nil
+}
+
+// AuditLog returns recent administrative actions, optionally filtered by action
+func (s *Service) AuditLog(ctx context.Context, action string, limit int) ([]models.AuditEntry, error) {
+	return s.database.ListAuditEntries(ctx, action, limit)
+}
+
+// recordAudit records an administrative action performed by the context's
+// actor. The outcome is recorded alongside the parameters; failing to write
+// the entry is logged rather than failing the action, which already happened.
+func (s *Service) recordAudit(ctx context.Context, action string, params map[string]interface{}, actionErr error) {
+	actor := audit.ActorFromContext(ctx)
+
+	if actionErr != nil {
+		params["error"] = actionErr.Error()
+	}
+	encoded, err := json.Marshal(params)
+	if err != nil {
+		logger.Error("Failed to encode audit parameters", zap.Error(err), zap.String("action", action))
+		encoded = []byte("{}")
+	}
+
+	if err := s.database.RecordAudit(ctx, models.AuditEntry{
+		Actor:      actor.Name,
+		ActorType:  actor.Type,
+		Action:     action,
+		Parameters: encoded,
+	}); err != nil {
+		logger.Error("Failed to record audit entry",
+			zap.Error(err),
+			zap.String("action", action),
+			zap.String("actor", actor.Name))
+	}
+}
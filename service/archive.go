@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"githubapifetch/audit"
+	"githubapifetch/gharchive"
+	"githubapifetch/github"
+	"githubapifetch/logger"
+	"githubapifetch/models"
+	"githubapifetch/tenant"
+
+	"go.uber.org/zap"
+)
+
+// ArchiveImportReport summarizes the import of a GH Archive dump
+type ArchiveImportReport struct {
+	Events       int // Events read, of any type
+	Pushes       int // Pushes to the default branch of a tracked repository
+	Commits      int // Commits handed to storage, before deduplication
+	Repositories int // Tracked repositories commits were imported for
+}
+
+// archiveRepo collects the commits of a tracked repository during an import
+type archiveRepo struct {
+	repo    models.Repository
+	pending []github.CommitResponse
+	pages   int
+	seen    map[string]bool
+}
+
+// ImportArchive warm-starts the tracked repositories of the context's tenant
+// from a GH Archive dump, or a BigQuery export of one, read from r; source
+// names the dump in page cursors, so importing it again is a no-op. Pushes
+// are matched to repositories by GitHub ID, or by owner/name for
+// repositories not synced yet, and only pushes to a repository's default
+// branch are imported. Repositories must be tracked already, for instance
+// with import-repos.
+//
+// Pushes do not record commit dates, so commits are stored with the time of
+// the push; monitoring then syncs each repository from its newest imported
+// commit through the API, and commits it fetches again are corrected.
+func (s *Service) ImportArchive(ctx context.Context, r io.Reader, source string) (*ArchiveImportReport, error) {
+	report, err := s.importArchive(ctx, r, source)
+	params := map[string]interface{}{"source": source}
+	if report != nil {
+		params["commits"] = report.Commits
+		params["repositories"] = report.Repositories
+	}
+	s.recordAudit(ctx, audit.ActionImportArchive, params, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to import archive %s: %w", source, err)
+	}
+	return report, nil
+}
+
+func (s *Service) importArchive(ctx context.Context, r io.Reader, source string) (*ArchiveImportReport, error) {
+	stored, err := s.database.ListRepositories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]*archiveRepo)
+	byName := make(map[string]*archiveRepo)
+	for _, repo := range stored {
+		entry := &archiveRepo{repo: repo, seen: make(map[string]bool)}
+		if repo.GitHubID != 0 {
+			byID[repo.GitHubID] = entry
+		}
+		byName[strings.ToLower(repo.Owner+"/"+repo.Name)] = entry
+	}
+
+	reader, err := gharchive.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	processor := s.processorFor(tenant.FromContext(ctx))
+	cursor := "gharchive=" + source
+	flush := func(entry *archiveRepo) error {
+		if len(entry.pending) == 0 {
+			return nil
+		}
+		if err := processor.storeCommits(ctx, entry.repo.Owner, entry.repo.Name, entry.repo.ID, cursor, entry.pages+1, entry.pending); err != nil {
+			return err
+		}
+		entry.pages++
+		entry.pending = entry.pending[:0]
+		return nil
+	}
+
+	report := &ArchiveImportReport{}
+	imported := make(map[int]bool)
+	for {
+		if ctx.Err() != nil {
+			return report, fmt.Errorf("context cancelled: %w", ctx.Err())
+		}
+		push, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return report, err
+		}
+
+		entry := byID[push.RepoID]
+		if entry == nil {
+			// Repositories synced since their last rename are matched by ID
+			// alone, so a new repository under an old name is not mixed in
+			if entry = byName[strings.ToLower(push.RepoName)]; entry == nil || entry.repo.GitHubID != 0 {
+				continue
+			}
+		}
+		branch := push.Branch()
+		if branch == "" || (entry.repo.DefaultBranch != "" && branch != entry.repo.DefaultBranch) {
+			continue
+		}
+		report.Pushes++
+
+		for _, c := range push.Commits {
+			// A commit pushed twice, say to a branch and then through a
+			// merge, is written once, with the time of its first push
+			if entry.seen[c.SHA] {
+				continue
+			}
+			entry.seen[c.SHA] = true
+			author := github.CommitAuthor{Name: c.AuthorName, Email: c.AuthorEmail, Date: push.CreatedAt}
+			entry.pending = append(entry.pending, github.CommitResponse{
+				SHA:     c.SHA,
+				Commit:  github.CommitDetail{Message: c.Message, Author: author, Committer: author},
+				URL:     c.URL,
+				HTMLURL: fmt.Sprintf("https://github.com/%s/%s/commit/%s", entry.repo.Owner, entry.repo.Name, c.SHA),
+			})
+			report.Commits++
+			imported[entry.repo.ID] = true
+			if len(entry.pending) == ingestPageSize {
+				if err := flush(entry); err != nil {
+					return report, err
+				}
+			}
+		}
+	}
+
+	for _, entry := range byName {
+		if err := flush(entry); err != nil {
+			return report, err
+		}
+	}
+	report.Events = reader.Events()
+	report.Repositories = len(imported)
+
+	logger.Info("Imported archive",
+		zap.String("source", source),
+		zap.Int("events", report.Events),
+		zap.Int("commits", report.Commits),
+		zap.Int("repositories", report.Repositories))
+	return report, nil
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"githubapifetch/github"
+	"githubapifetch/models"
+)
+
+// ingestOnlyDB accepts every commit page without storing it
+type ingestOnlyDB struct {
+	DBInterface
+}
+
+func (ingestOnlyDB) IngestCommitPage(ctx context.Context, repoID int, cursor string, commits []models.Commit) (bool, error) {
+	return true, nil
+}
+
+// BenchmarkStoreCommits measures converting a backfill of commits to models,
+// whose slices are pooled across polls
+func BenchmarkStoreCommits(b *testing.B) {
+	commits := make([]github.CommitResponse, 1000)
+	for i := range commits {
+		commits[i].SHA = fmt.Sprintf("%040d", i)
+		commits[i].Commit.Author.Date = time.Date(2024, 1, 1, 0, 0, i, 0, time.UTC)
+	}
+	processor := NewRepositoryProcessor(ingestOnlyDB{}, nil)
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := processor.storeCommits(ctx, "octo", "hello", 1, "since=2024-01-01T00:00:00Z", 1, commits); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
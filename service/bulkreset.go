@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"githubapifetch/audit"
+	"githubapifetch/models"
+	"githubapifetch/scheduler"
+)
+
+// DefaultResetWorkers is how many repositories ResetSyncPoints refetches at
+// once unless told otherwise
+const DefaultResetWorkers = 4
+
+// SetRepositoryGroup puts a repository in a group; an empty group takes it
+// out of its group
+func (s *Service) SetRepositoryGroup(ctx context.Context, name, group string) error {
+	err := s.database.SetRepositoryGroup(ctx, name, group)
+	s.recordAudit(ctx, audit.ActionSetGroup, map[string]interface{}{"repo": name, "group": group}, err)
+	if err != nil {
+		return fmt.Errorf("failed to set repository group: %w", err)
+	}
+	return nil
+}
+
+// RepositoriesInGroup returns the tracked repositories of a group, or every
+// tracked repository when group is empty
+func (s *Service) RepositoriesInGroup(ctx context.Context, group string) ([]models.Repository, error) {
+	repos, err := s.database.ListRepositories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if group == "" {
+		return repos, nil
+	}
+	inGroup := []models.Repository{}
+	for _, repo := range repos {
+		if repo.Group == group {
+			inGroup = append(inGroup, repo)
+		}
+	}
+	return inGroup, nil
+}
+
+// ResetSyncPoints resets the sync point of several repositories, refetching
+// up to workers of them at once. Results are in the order of repos, nil for
+// the repositories that failed; the failure of each is a
+// scheduler.RepoError. Repositories still waiting for a worker when ctx is
+// cancelled are not started.
+func (s *Service) ResetSyncPoints(ctx context.Context, repos []models.Repository, since time.Time, workers int) ([]*models.ResetSyncResult, error) {
+	if workers <= 0 {
+		workers = DefaultResetWorkers
+	}
+
+	results := make([]*models.ResetSyncResult, len(repos))
+	errs := make([]error, len(repos))
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+
+	for i, repo := range repos {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				errs[i] = &scheduler.RepoError{Owner: repo.Owner, Name: repo.Name, Err: ctx.Err()}
+				return
+			}
+			defer func() { <-sem }()
+
+			result, err := s.ResetSyncPoint(ctx, repo.Name, since)
+			if err != nil {
+				errs[i] = &scheduler.RepoError{Owner: repo.Owner, Name: repo.Name, Err: err}
+				return
+			}
+			results[i] = result
+		}()
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return results, fmt.Errorf("errors occurred while resetting sync points: %w", err)
+	}
+	return results, nil
+}
@@ -0,0 +1,15 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"githubapifetch/models"
+)
+
+// Changes returns up to limit rows of table changed at or after since, after
+// afterKey among rows changed at the same instant, for incremental extraction
+// by downstream ETL. It reads every tenant's rows.
+func (s *Service) Changes(ctx context.Context, table string, since time.Time, afterKey string, limit int) ([]models.Change, error) {
+	return s.database.Changes(ctx, table, since, afterKey, limit)
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"githubapifetch/logger"
+	"githubapifetch/models"
+)
+
+// syncDeployments fetches the deployments of a repository created since the
+// oldest stored deployment still in progress, or since the newest stored one,
+// together with their statuses, and stores them
+func (p *RepositoryProcessor) syncDeployments(ctx context.Context, owner, name string, repoID int) error {
+	since, err := p.db.DeploymentSyncStart(ctx, repoID)
+	if err != nil {
+		return err
+	}
+	listed, err := p.client.FetchDeployments(ctx, owner, name, since)
+	if err != nil {
+		return fmt.Errorf("failed to sync deployments of %s/%s: %w", owner, name, err)
+	}
+
+	deployments := make([]models.Deployment, len(listed))
+	for i, deployment := range listed {
+		statuses, err := p.client.FetchDeploymentStatuses(ctx, owner, name, deployment.ID)
+		if err != nil {
+			return fmt.Errorf("failed to sync deployments of %s/%s: %w", owner, name, err)
+		}
+		d := models.Deployment{
+			RepoID:       repoID,
+			GitHubID:     deployment.ID,
+			SHA:          deployment.SHA,
+			Ref:          deployment.Ref,
+			Environment:  deployment.Environment,
+			CreatorLogin: deployment.Creator.Login,
+			RequestedAt:  deployment.CreatedAt,
+		}
+		// Statuses are listed newest first: the first is the current state
+		// and the oldest success is when the deployment went live
+		for j, status := range statuses {
+			if j == 0 {
+				d.State = status.State
+			}
+			if d.LogURL == "" {
+				d.LogURL = status.LogURL
+			}
+			if status.State == "success" {
+				succeeded := status.CreatedAt
+				d.SucceededAt = &succeeded
+			}
+		}
+		deployments[i] = d
+	}
+	if err := p.db.StoreDeployments(ctx, repoID, deployments); err != nil {
+		return err
+	}
+
+	logger.Info("Synced deployments",
+		zap.String("repo_owner", owner),
+		zap.String("repo_name", name),
+		zap.Int("deployment_count", len(deployments)))
+	return nil
+}
+
+// DeploymentCommits returns the stored commits a deployment of a repository
+// of the context's tenant shipped. Deployments are only stored while
+// SYNC_DEPLOYMENTS is set.
+func (s *Service) DeploymentCommits(ctx context.Context, repoName string, githubID int64) (*models.DeploymentCommits, error) {
+	return s.database.DeploymentCommits(ctx, repoName, githubID)
+}
+
+// CommitDeployment returns when a stored commit of a repository of the
+// context's tenant first reached an environment
+func (s *Service) CommitDeployment(ctx context.Context, repoName, sha, environment string) (*models.CommitDeployment, error) {
+	return s.database.CommitDeployment(ctx, repoName, sha, environment)
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"githubapifetch/models"
+)
+
+// Formats a repository diff can be rendered in
+const (
+	DiffFormatText     = "text"
+	DiffFormatJSON     = "json"
+	DiffFormatMarkdown = "markdown"
+)
+
+// RepositoryDiff reports how a repository of the context's tenant changed
+// between two times: stars, forks and open issues from the snapshots taken
+// on every sync, and the commits and new authors from the stored commits
+func (s *Service) RepositoryDiff(ctx context.Context, name string, from, to time.Time) (*models.RepositoryDiff, error) {
+	return s.database.RepositoryDiff(ctx, name, from, to)
+}
+
+// diffRow is one line of a rendered diff
+type diffRow struct {
+	label, before, after, change string
+}
+
+// diffRows lays out a diff as rows; counters without both snapshots show "-"
+func diffRows(d *models.RepositoryDiff) []diffRow {
+	counter := func(label string, value func(*models.RepositorySnapshot) int, delta func() (int, bool)) diffRow {
+		row := diffRow{label: label, before: "-", after: "-", change: "-"}
+		if d.Before != nil {
+			row.before = strconv.Itoa(value(d.Before))
+		}
+		if d.After != nil {
+			row.after = strconv.Itoa(value(d.After))
+		}
+		if n, ok := delta(); ok {
+			row.change = fmt.Sprintf("%+d", n)
+		}
+		return row
+	}
+	return []diffRow{
+		counter("Stars", func(s *models.RepositorySnapshot) int { return s.StarsCount }, d.StarsGained),
+		counter("Forks", func(s *models.RepositorySnapshot) int { return s.ForksCount }, d.ForksGained),
+		counter("Open issues", func(s *models.RepositorySnapshot) int { return s.OpenIssuesCount }, d.OpenIssuesChange),
+		{label: "Commits", before: "-", after: "-", change: fmt.Sprintf("%+d", d.CommitsAdded)},
+		{label: "New authors", before: "-", after: "-", change: fmt.Sprintf("%+d", len(d.NewAuthors))},
+	}
+}
+
+// WriteRepositoryDiff renders a repository diff as text, JSON or Markdown
+func WriteRepositoryDiff(w io.Writer, d *models.RepositoryDiff, format string) error {
+	title := fmt.Sprintf("%s/%s from %s to %s", d.Owner, d.Name, d.From.Format(time.RFC3339), d.To.Format(time.RFC3339))
+	switch format {
+	case DiffFormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(d)
+	case DiffFormatMarkdown:
+		fmt.Fprintf(w, "## %s\n\n", title)
+		fmt.Fprintln(w, "| | Before | After | Change |")
+		fmt.Fprintln(w, "|---|---:|---:|---:|")
+		for _, row := range diffRows(d) {
+			fmt.Fprintf(w, "| %s | %s | %s | %s |\n", row.label, row.before, row.after, row.change)
+		}
+		if len(d.NewAuthors) > 0 {
+			fmt.Fprintf(w, "\nNew authors: %s\n", strings.Join(d.NewAuthors, ", "))
+		}
+		return nil
+	case DiffFormatText, "":
+		fmt.Fprintf(w, "%s\n\n", title)
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "\tBEFORE\tAFTER\tCHANGE")
+		for _, row := range diffRows(d) {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", row.label, row.before, row.after, row.change)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		if len(d.NewAuthors) > 0 {
+			fmt.Fprintf(w, "\nNew authors: %s\n", strings.Join(d.NewAuthors, ", "))
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown format %q, want %s, %s or %s", format, DiffFormatText, DiffFormatJSON, DiffFormatMarkdown)
+}
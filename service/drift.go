@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"expvar"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"githubapifetch/github"
+	"githubapifetch/logger"
+	"githubapifetch/metrics"
+	"githubapifetch/models"
+	"githubapifetch/tenant"
+)
+
+// Fields compared by drift checks
+const (
+	driftFieldMessage       = "message"
+	driftFieldAuthor        = "author_name"
+	driftFieldDate          = "date"
+	driftFieldCommitterDate = "committer_date"
+)
+
+// DriftReport is the outcome of comparing a sample of stored commits against
+// GitHub
+type DriftReport struct {
+	Repos []RepoDrift
+}
+
+// RepoDrift is the drift found in one repository. Err is set when the
+// repository could not be checked.
+type RepoDrift struct {
+	Owner string
+	Name  string
+	// Sampled is the number of stored commits compared, Missing how many of
+	// them GitHub no longer has and Mismatched how many differ from it
+	Sampled    int
+	Missing    int
+	Mismatched int
+	// Fields counts the mismatched commits by differing field
+	Fields map[string]int
+	Err    error
+}
+
+// Percent returns the share of sampled commits that drifted, in percent
+func (d RepoDrift) Percent() float64 {
+	if d.Sampled == 0 {
+		return 0
+	}
+	return float64(d.Missing+d.Mismatched) * 100 / float64(d.Sampled)
+}
+
+// Percent returns the share of all sampled commits that drifted, in percent
+func (r *DriftReport) Percent() float64 {
+	total := RepoDrift{}
+	for _, d := range r.Repos {
+		total.Sampled += d.Sampled
+		total.Missing += d.Missing
+		total.Mismatched += d.Mismatched
+	}
+	return total.Percent()
+}
+
+// CheckDrift compares a random sample of up to sampleSize stored commits of
+// every repository of the context's tenant against GitHub, one request per
+// commit; a sampleSize of 0 samples DRIFT_SAMPLE_SIZE commits. A commit has
+// drifted when GitHub no longer has it, or when its checksum over the
+// message, author and dates differs from the stored one. Messages fetched
+// from GitHub are cleaned up the way they are on ingestion first, so
+// truncated messages do not count as drift. Removed repositories are
+// skipped.
+func (s *Service) CheckDrift(ctx context.Context, sampleSize int) (*DriftReport, error) {
+	if sampleSize <= 0 {
+		sampleSize = s.config.DriftSampleSize
+	}
+	repos, err := s.database.ListRepositories(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	processor := s.processorFor(tenant.FromContext(ctx))
+	report := &DriftReport{}
+	for _, repo := range repos {
+		if repo.Status == models.RepoStatusRemoved {
+			continue
+		}
+		if ctx.Err() != nil {
+			return report, fmt.Errorf("context cancelled: %w", ctx.Err())
+		}
+		drift := processor.checkDrift(ctx, repo, sampleSize)
+		if drift.Err == nil {
+			metrics.CommitDriftPercent.Set(metrics.RepoKey(repo.Owner, repo.Name), percentVar(drift.Percent()))
+		}
+		report.Repos = append(report.Repos, drift)
+	}
+	return report, nil
+}
+
+// checkDrift compares a sample of a repository's stored commits against GitHub
+func (p *RepositoryProcessor) checkDrift(ctx context.Context, repo models.Repository, sampleSize int) RepoDrift {
+	drift := RepoDrift{Owner: repo.Owner, Name: repo.Name, Fields: make(map[string]int)}
+	stored, err := p.db.SampleCommits(ctx, repo.ID, sampleSize)
+	if err != nil {
+		drift.Err = err
+		return drift
+	}
+
+	for _, commit := range stored {
+		remote, err := p.client.FetchCommit(ctx, repo.Owner, repo.Name, commit.SHA)
+		if errors.Is(err, github.ErrNotFound) {
+			drift.Sampled++
+			drift.Missing++
+			logger.Warn("Stored commit is missing on GitHub",
+				zap.String("repo_owner", repo.Owner),
+				zap.String("repo_name", repo.Name),
+				zap.String("sha", commit.SHA))
+			continue
+		}
+		if err != nil {
+			drift.Err = err
+			return drift
+		}
+		drift.Sampled++
+
+		upstream := p.commitModel(repo.ID, *remote)
+		upstream.Sanitize(p.maxMessageBytes)
+		if commitChecksum(commit) == commitChecksum(upstream) {
+			continue
+		}
+		drift.Mismatched++
+		fields := driftFields(commit, upstream)
+		for _, field := range fields {
+			drift.Fields[field]++
+		}
+		logger.Warn("Stored commit differs from GitHub",
+			zap.String("repo_owner", repo.Owner),
+			zap.String("repo_name", repo.Name),
+			zap.String("sha", commit.SHA),
+			zap.Strings("fields", fields))
+	}
+	return drift
+}
+
+// commitChecksum hashes the fields of a commit that drift checks compare
+func commitChecksum(c models.Commit) string {
+	h := sha256.New()
+	for _, field := range []string{
+		c.Message,
+		c.MessageHash,
+		c.AuthorName,
+		c.Date.UTC().Format(time.RFC3339),
+		c.CommitterDate.UTC().Format(time.RFC3339),
+	} {
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// driftFields lists the compared fields in which two commits differ
+func driftFields(stored, upstream models.Commit) []string {
+	var fields []string
+	if stored.Message != upstream.Message || stored.MessageHash != upstream.MessageHash {
+		fields = append(fields, driftFieldMessage)
+	}
+	if stored.AuthorName != upstream.AuthorName {
+		fields = append(fields, driftFieldAuthor)
+	}
+	if !stored.Date.Truncate(time.Second).Equal(upstream.Date.Truncate(time.Second)) {
+		fields = append(fields, driftFieldDate)
+	}
+	if !stored.CommitterDate.Truncate(time.Second).Equal(upstream.CommitterDate.Truncate(time.Second)) {
+		fields = append(fields, driftFieldCommitterDate)
+	}
+	return fields
+}
+
+func percentVar(percent float64) *expvar.Float {
+	f := new(expvar.Float)
+	f.Set(percent)
+	return f
+}
+
+// driftLoop periodically checks the repositories of the context's tenant for
+// drift from GitHub
+func (s *Service) driftLoop(ctx context.Context, interval time.Duration) {
+	ticker := s.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			report, err := s.CheckDrift(ctx, 0)
+			if err != nil {
+				logger.Error("Drift check failed", zap.Error(err))
+				continue
+			}
+			for _, d := range report.Repos {
+				if d.Err != nil {
+					logger.Error("Drift check of repository failed",
+						zap.String("repo_owner", d.Owner),
+						zap.String("repo_name", d.Name),
+						zap.Error(d.Err))
+				}
+			}
+			logger.Info("Checked stored commits for drift",
+				zap.Int("repositories", len(report.Repos)),
+				zap.Float64("drift_percent", report.Percent()))
+		}
+	}
+}
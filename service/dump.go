@@ -0,0 +1,25 @@
+package service
+
+import (
+	"context"
+	"io"
+
+	"githubapifetch/audit"
+	"githubapifetch/models"
+)
+
+// Dump writes every tenant's data to w as a portable archive
+func (s *Service) Dump(ctx context.Context, w io.Writer) ([]models.DumpTable, error) {
+	tables, err := s.database.Dump(ctx, w)
+	s.recordAudit(ctx, audit.ActionDump, map[string]interface{}{"tables": len(tables)}, err)
+	return tables, err
+}
+
+// Restore replaces every tenant's data with the contents of an archive
+// written by Dump. Unless replace is set, it refuses a database that already
+// holds repositories.
+func (s *Service) Restore(ctx context.Context, r io.Reader, replace bool) ([]models.DumpTable, error) {
+	tables, err := s.database.Restore(ctx, r, replace)
+	s.recordAudit(ctx, audit.ActionRestore, map[string]interface{}{"replace": replace}, err)
+	return tables, err
+}
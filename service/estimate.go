@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"githubapifetch/models"
+	"githubapifetch/tenant"
+)
+
+// commitsPerPage is how many commits a page of the commit listing holds
+const commitsPerPage = 100
+
+// RequestEstimate estimates the GitHub API requests a tenant's repositories
+// cost, to size tokens before enabling many repositories at once
+type RequestEstimate struct {
+	// HourlyLimit is the hourly request limit of the tenant's token
+	HourlyLimit int
+	Repos       []RepoRequestEstimate
+}
+
+// RepoRequestEstimate estimates the requests of one repository. Err is set,
+// and the estimate left empty, when the repository could not be looked up.
+type RepoRequestEstimate struct {
+	Owner string
+	Name  string
+	// Since is where a backfill starts and Commits how many commits it
+	// fetches from there
+	Since   time.Time
+	Commits int
+	// PollInterval is the number of seconds between checks of the repository
+	PollInterval int
+	// Backfill is the number of requests a backfill takes, and PerHour the
+	// number per hour of keeping the repository current afterwards
+	Backfill int
+	PerHour  float64
+	Err      error
+}
+
+// Backfill returns the number of requests backfilling every repository takes
+func (e *RequestEstimate) Backfill() int {
+	total := 0
+	for _, r := range e.Repos {
+		total += r.Backfill
+	}
+	return total
+}
+
+// PerHour returns the number of requests per hour of keeping every repository
+// current
+func (e *RequestEstimate) PerHour() float64 {
+	total := 0.0
+	for _, r := range e.Repos {
+		total += r.PerHour
+	}
+	return total
+}
+
+// BackfillHours returns how many hours the backfill takes at the token's
+// limit, alongside keeping the repositories current; +Inf when keeping them
+// current alone exceeds the limit
+func (e *RequestEstimate) BackfillHours() float64 {
+	spare := float64(e.HourlyLimit) - e.PerHour()
+	if spare <= 0 {
+		return math.Inf(1)
+	}
+	return float64(e.Backfill()) / spare
+}
+
+// EstimateRequests estimates the GitHub API requests of the repositories of
+// the context's tenant: those listed in r, in the format of
+// ImportRepositories, or the tracked ones when r is nil. Each repository is
+// looked up on GitHub to count its commits since its start date, which costs
+// two requests per repository.
+//
+// A backfill takes one request for the repository, one per page of 100
+// commits and, with path filters, one per commit for its files. Once current,
+// every check costs one request for the repository and one for its newest
+// commits, and every reconciliation one more.
+func (s *Service) EstimateRequests(ctx context.Context, r io.Reader) (*RequestEstimate, error) {
+	repos, err := s.estimatedRepositories(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	client := s.processorFor(tenant.FromContext(ctx)).client
+	limit, err := client.RateLimit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := s.tenantPollInterval(tenant.FromContext(ctx))
+	estimate := &RequestEstimate{HourlyLimit: limit.Limit}
+	for _, repo := range repos {
+		e := RepoRequestEstimate{Owner: repo.Owner, Name: repo.Name}
+		remote, err := client.FetchRepo(ctx, repo.Owner, repo.Name)
+		if err != nil {
+			e.Err = err
+			estimate.Repos = append(estimate.Repos, e)
+			continue
+		}
+		e.Since = s.config.StartDate
+		switch {
+		case repo.StartDateAuto:
+			e.Since = time.Time{}
+		case repo.StartDate != nil:
+			e.Since = *repo.StartDate
+		}
+		if e.Since.IsZero() {
+			e.Since = remote.CreatedAt
+		}
+		if e.Commits, err = client.CountCommits(ctx, repo.Owner, repo.Name, e.Since); err != nil {
+			e.Err = err
+			estimate.Repos = append(estimate.Repos, e)
+			continue
+		}
+
+		pages := max(1, (e.Commits+commitsPerPage-1)/commitsPerPage)
+		e.Backfill = 1 + pages
+		if repo.HasPathFilters {
+			e.Backfill += e.Commits
+		}
+		// Poll intervals shorter than the tenant's have no effect
+		e.PollInterval = max(interval, repo.PollInterval)
+		if e.PollInterval > 0 {
+			e.PerHour = 2 * 3600 / float64(e.PollInterval)
+		}
+		if s.config.ReconcileInterval > 0 {
+			e.PerHour += 3600 / float64(s.config.ReconcileInterval)
+		}
+		estimate.Repos = append(estimate.Repos, e)
+	}
+	return estimate, nil
+}
+
+// estimatedRepositories returns the repositories listed in r, or the tracked
+// ones of the context's tenant when r is nil: its active and quarantined
+// repositories and, for the default tenant, the configured one
+func (s *Service) estimatedRepositories(ctx context.Context, r io.Reader) ([]models.Repository, error) {
+	if r != nil {
+		repos, report, err := parseRepositoryImport(r, s.config.StartDate, s.clock.Now())
+		if err != nil {
+			return nil, err
+		}
+		if len(report.Errors) > 0 {
+			errs := make([]error, len(report.Errors))
+			for i, rowErr := range report.Errors {
+				errs[i] = rowErr
+			}
+			return nil, fmt.Errorf("invalid repository file: %w", errors.Join(errs...))
+		}
+		return repos, nil
+	}
+
+	stored, err := s.database.ListRepositories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var repos []models.Repository
+	index := make(map[string]int)
+	add := func(repo models.Repository) {
+		key := repo.Owner + "/" + repo.Name
+		if i, ok := index[key]; ok {
+			repos[i] = repo
+			return
+		}
+		index[key] = len(repos)
+		repos = append(repos, repo)
+	}
+	if tenant.FromContext(ctx) == tenant.DefaultID && s.config.RepoOwner != "" && s.config.RepoName != "" {
+		add(models.Repository{Owner: s.config.RepoOwner, Name: s.config.RepoName})
+	}
+	for _, repo := range stored {
+		if repo.Status == models.RepoStatusActive || repo.Status == models.RepoStatusQuarantined {
+			add(repo)
+		}
+	}
+	return repos, nil
+}
+
+// tenantPollInterval returns the poll interval in seconds of a tenant
+func (s *Service) tenantPollInterval(tenantID int) int {
+	for _, t := range s.tenants {
+		if t.ID == tenantID {
+			return s.pollInterval(t)
+		}
+	}
+	return s.config.PollInterval
+}
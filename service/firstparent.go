@@ -0,0 +1,75 @@
+package service
+
+import "githubapifetch/github"
+
+// firstParentWalk follows the first-parent chain through a commit listing,
+// which GitHub returns newest first. The first commit listed is the branch
+// tip; every later commit is on the chain only if it is the first parent of
+// the previous one.
+type firstParentWalk struct {
+	// next is the SHA of the next commit on the chain; empty once a root
+	// commit has been reached
+	next    string
+	started bool
+	// off holds the commits passed over so far. The listing is ordered by
+	// commit date, so a parent dated after its child is listed before it and
+	// is only recognised once the child is reached.
+	off map[string]github.CommitResponse
+}
+
+// resume continues a walk an earlier sync stopped at next. An empty next
+// starts from the tip again.
+func (w *firstParentWalk) resume(next string) {
+	if w == nil || next == "" {
+		return
+	}
+	w.next, w.started = next, true
+}
+
+// mainline returns the commits of a page that are on the chain, in chain
+// order
+func (w *firstParentWalk) mainline(commits []github.CommitResponse) []github.CommitResponse {
+	if w.off == nil {
+		w.off = make(map[string]github.CommitResponse)
+	}
+	kept := make([]github.CommitResponse, 0, len(commits))
+	for _, commit := range commits {
+		if w.started && commit.SHA != w.next {
+			w.off[commit.SHA] = commit
+			continue
+		}
+		w.started = true
+		kept = w.follow(kept, commit)
+	}
+	return kept
+}
+
+// follow appends commit and any of its first-parent ancestors listed earlier
+func (w *firstParentWalk) follow(kept []github.CommitResponse, commit github.CommitResponse) []github.CommitResponse {
+	for {
+		kept = append(kept, commit)
+		w.next = ""
+		if len(commit.Parents) > 0 {
+			w.next = commit.Parents[0].SHA
+		}
+		parent, ok := w.off[w.next]
+		if !ok {
+			return kept
+		}
+		delete(w.off, w.next)
+		commit = parent
+	}
+}
+
+// skipped returns how many commits the walk has left out
+func (w *firstParentWalk) skipped() int {
+	return len(w.off)
+}
+
+// nextSHA returns the SHA a checkpoint resumes the walk from
+func (w *firstParentWalk) nextSHA() string {
+	if w == nil {
+		return ""
+	}
+	return w.next
+}
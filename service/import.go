@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"githubapifetch/audit"
+	"githubapifetch/config"
+	"githubapifetch/models"
+)
+
+// GitHub's rules for account and repository names
+var (
+	ownerPattern    = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9-]{0,38})$`)
+	repoNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,100}$`)
+)
+
+// ImportRowError describes an invalid line of a repository import file
+type ImportRowError struct {
+	Line   int
+	Reason string
+}
+
+func (e ImportRowError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Reason)
+}
+
+// ImportReport summarizes a repository import
+type ImportReport struct {
+	Rows     int // Repository rows read, valid or not
+	Added    int // Repositories newly registered
+	Existing int // Repositories already stored, left untouched
+	Errors   []ImportRowError
+}
+
+// ImportRepositories registers the repositories listed in a CSV file with
+// the columns owner, name, start_date and interval; a header row and lines
+// starting with # are skipped. start_date (RFC 3339, YYYY-MM-DD or auto for
+// the repository's creation) defaults to the configured start date and interval (seconds or a duration such as
+// 6h) to the tenant's poll interval. The whole file is validated first and
+// nothing is registered if any row is invalid, or when dryRun is set.
+// Repositories are synced by monitoring, not during the import.
+func (s *Service) ImportRepositories(ctx context.Context, r io.Reader, dryRun bool) (*ImportReport, error) {
+	repos, report, err := parseRepositoryImport(r, s.config.StartDate, s.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+	if len(report.Errors) > 0 || dryRun {
+		return report, nil
+	}
+
+	added, err := s.database.RegisterRepositories(ctx, repos)
+	s.recordAudit(ctx, audit.ActionImportRepos, map[string]interface{}{
+		"rows":  report.Rows,
+		"added": added,
+	}, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to import repositories: %w", err)
+	}
+
+	report.Added = added
+	report.Existing = len(repos) - added
+	return report, nil
+}
+
+// parseRepositoryImport reads and validates a repository import file. Only
+// read errors are returned as an error; invalid rows are collected in the
+// report.
+func parseRepositoryImport(r io.Reader, defaultStart, now time.Time) ([]models.Repository, *ImportReport, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	report := &ImportReport{}
+	var repos []models.Repository
+	seen := make(map[string]int)
+
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			report.Rows++
+			report.Errors = append(report.Errors, ImportRowError{Line: parseErr.Line, Reason: parseErr.Err.Error()})
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read import file: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		if first && strings.EqualFold(strings.TrimSpace(record[0]), "owner") {
+			continue
+		}
+		report.Rows++
+
+		repo, err := parseImportRow(record, defaultStart, now)
+		if err != nil {
+			report.Errors = append(report.Errors, ImportRowError{Line: line, Reason: err.Error()})
+			continue
+		}
+
+		// GitHub names are case insensitive
+		key := strings.ToLower(repo.Owner + "/" + repo.Name)
+		if prev, ok := seen[key]; ok {
+			report.Errors = append(report.Errors, ImportRowError{
+				Line:   line,
+				Reason: fmt.Sprintf("%s/%s duplicates line %d", repo.Owner, repo.Name, prev),
+			})
+			continue
+		}
+		seen[key] = line
+		repos = append(repos, repo)
+	}
+
+	return repos, report, nil
+}
+
+// parseImportRow validates one owner,name,start_date,interval record
+func parseImportRow(record []string, defaultStart, now time.Time) (models.Repository, error) {
+	if len(record) < 2 || len(record) > 4 {
+		return models.Repository{}, fmt.Errorf("got %d columns, want owner,name[,start_date[,interval]]", len(record))
+	}
+	field := func(i int) string {
+		if i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	repo := models.Repository{Owner: field(0), Name: field(1)}
+	if !ownerPattern.MatchString(repo.Owner) {
+		return repo, fmt.Errorf("invalid owner %q", repo.Owner)
+	}
+	if !repoNamePattern.MatchString(repo.Name) || repo.Name == "." || repo.Name == ".." {
+		return repo, fmt.Errorf("invalid repository name %q", repo.Name)
+	}
+
+	start := defaultStart
+	if raw := field(2); raw != "" {
+		var err error
+		if start, err = config.ParseStartDate(raw); err != nil {
+			return repo, fmt.Errorf("invalid start_date %q: want RFC 3339, YYYY-MM-DD or auto", raw)
+		}
+		if start.After(now) {
+			return repo, fmt.Errorf("start_date %s is in the future", raw)
+		}
+	}
+	if start.IsZero() {
+		repo.StartDateAuto = true
+	} else {
+		repo.StartDate = &start
+	}
+
+	if raw := field(3); raw != "" {
+		interval, err := parseInterval(raw)
+		if err != nil {
+			return repo, err
+		}
+		repo.PollInterval = int(interval / time.Second)
+	}
+	return repo, nil
+}
+
+// parseInterval parses a poll interval given in seconds or as a duration
+func parseInterval(raw string) (time.Duration, error) {
+	interval, err := time.ParseDuration(raw)
+	if seconds, atoiErr := strconv.Atoi(raw); atoiErr == nil {
+		interval, err = time.Duration(seconds)*time.Second, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid interval %q: want seconds or a duration such as 6h", raw)
+	}
+	if interval < time.Second {
+		return 0, fmt.Errorf("interval %q must be at least one second", raw)
+	}
+	return interval, nil
+}
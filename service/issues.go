@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"githubapifetch/github"
+	"githubapifetch/logger"
+	"githubapifetch/models"
+)
+
+// syncIssues fetches the issues of a repository updated since the last
+// stored issue update and stores them page by page. The listing includes
+// the last stored update, so an interrupted sync loses nothing.
+func (p *RepositoryProcessor) syncIssues(ctx context.Context, owner, name string, repoID int) error {
+	since, err := p.db.LatestIssueUpdate(ctx, repoID)
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	err = p.client.FetchIssuePages(ctx, owner, name, since, func(page []github.IssueResponse) error {
+		issues := make([]models.Issue, len(page))
+		for i, issue := range page {
+			issues[i] = p.issueModel(repoID, issue)
+		}
+		if err := p.db.StoreIssues(ctx, repoID, issues); err != nil {
+			return err
+		}
+		count += len(issues)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to sync issues of %s/%s: %w", owner, name, err)
+	}
+
+	logger.Info("Synced issues",
+		zap.String("repo_owner", owner),
+		zap.String("repo_name", name),
+		zap.Time("since", since),
+		zap.Int("issue_count", count))
+	return nil
+}
+
+// issueModel converts an issue response to a model
+func (p *RepositoryProcessor) issueModel(repoID int, issue github.IssueResponse) models.Issue {
+	labels := make([]string, len(issue.Labels))
+	for i, label := range issue.Labels {
+		labels[i] = label.Name
+	}
+	return models.Issue{
+		RepoID:          repoID,
+		Number:          issue.Number,
+		Title:           issue.Title,
+		State:           issue.State,
+		AuthorLogin:     p.anonymizer.Login(issue.User.Login),
+		Labels:          labels,
+		OpenedAt:        issue.CreatedAt,
+		ClosedAt:        issue.ClosedAt,
+		GitHubUpdatedAt: issue.UpdatedAt,
+	}
+}
+
+// IssueStats describes the issues of a repository of the context's tenant
+// over [since, until), those with label when it is not empty, in the working
+// time of the configured calendar when workingTime is set. Issues are only
+// stored while SYNC_ISSUES is set.
+func (s *Service) IssueStats(ctx context.Context, repoName, label string, since, until time.Time, workingTime bool) (*models.IssueStats, error) {
+	return s.database.IssueStats(ctx, repoName, label, since, until, s.workCalendar(workingTime))
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+
+	"githubapifetch/db"
+	"githubapifetch/logger"
+	"githubapifetch/models"
+)
+
+// ownerRefresh is how long a fetched owner profile is kept before it is
+// fetched again. Profiles rarely change, and every repository of an owner
+// would otherwise fetch the same one on every sync.
+const ownerRefresh = 24 * time.Hour
+
+// syncOwner fetches and stores the profile of the user or organization
+// login, unless it was fetched within ownerRefresh. The profile is not worth
+// failing a sync over, so errors are only logged; the next sync tries again.
+func (p *RepositoryProcessor) syncOwner(ctx context.Context, login string) {
+	stored, err := p.db.GetOwner(ctx, login)
+	switch {
+	case err == nil && p.clock.Now().Sub(stored.FetchedAt) < ownerRefresh:
+		return
+	case err != nil && !errors.Is(err, db.ErrOwnerNotFound):
+		logger.Warn("Failed to load owner", zap.Error(err), zap.String("owner", login))
+		return
+	}
+
+	fetched, err := p.client.FetchOwner(ctx, login)
+	if err != nil {
+		logger.Warn("Failed to fetch owner", zap.Error(err), zap.String("owner", login))
+		return
+	}
+	owner := models.Owner{
+		Login:       fetched.Login,
+		GitHubID:    fetched.ID,
+		Type:        ownerType(fetched.Type),
+		Name:        fetched.Name,
+		AvatarURL:   fetched.AvatarURL,
+		PublicRepos: fetched.PublicRepos,
+		FetchedAt:   p.clock.Now(),
+	}
+	if err := p.db.StoreOwner(ctx, owner); err != nil {
+		logger.Warn("Failed to store owner", zap.Error(err), zap.String("owner", login))
+		return
+	}
+	logger.Info("Synced owner",
+		zap.String("owner", owner.Login),
+		zap.String("type", owner.Type))
+}
+
+// ownerType maps the account type GitHub reports to the stored one; only
+// organizations are told apart from users
+func ownerType(githubType string) string {
+	if githubType == "Organization" {
+		return models.OwnerTypeOrg
+	}
+	return models.OwnerTypeUser
+}
+
+// ListOwners returns the owners of the context's tenant fetched with
+// SYNC_OWNERS, each with the number of its tracked repositories
+func (s *Service) ListOwners(ctx context.Context) ([]models.OwnerListing, error) {
+	return s.database.ListOwners(ctx)
+}
+
+// GetOwner returns an owner of the context's tenant fetched with SYNC_OWNERS
+func (s *Service) GetOwner(ctx context.Context, login string) (*models.Owner, error) {
+	return s.database.GetOwner(ctx, login)
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"githubapifetch/codeowners"
+	"githubapifetch/github"
+	"githubapifetch/models"
+	"githubapifetch/tenant"
+)
+
+// OwnershipReport is the ownership of a repository's busiest paths, as
+// inferred from its commits and, when compared, as its CODEOWNERS file
+// declares it
+type OwnershipReport struct {
+	Owner string
+	Name  string
+	Since time.Time
+	Until time.Time
+	Paths []models.PathOwnership
+	// Compared is set when the paths were compared against CODEOWNERS, and
+	// Codeowners is the path of the file, "" when the repository has none
+	Compared   bool
+	Codeowners string
+}
+
+// Unowned returns the paths CODEOWNERS assigns no owners to, busiest
+// first. It is empty unless the report was compared against CODEOWNERS.
+func (r *OwnershipReport) Unowned() []models.PathOwnership {
+	var unowned []models.PathOwnership
+	if !r.Compared {
+		return unowned
+	}
+	for _, p := range r.Paths {
+		if len(p.CodeOwners) == 0 {
+			unowned = append(unowned, p)
+		}
+	}
+	return unowned
+}
+
+// OwnershipReport infers the top committers of the limit busiest path
+// prefixes of a repository of the context's tenant, cut to depth
+// directories, from the files touched by its commits authored in
+// [since, until). With compare, the CODEOWNERS file of the repository's
+// default branch is fetched from GitHub and the owners it assigns to each
+// prefix are added, so busy paths nobody owns stand out. File lists are
+// only kept for repositories with path filters.
+func (s *Service) OwnershipReport(ctx context.Context, repoName string, since, until time.Time, depth, top, limit int, compare bool) (*OwnershipReport, error) {
+	repo, err := s.database.GetByName(ctx, repoName)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := s.database.PathOwnership(ctx, repoName, since, until, depth, top, limit)
+	if err != nil {
+		return nil, err
+	}
+	report := &OwnershipReport{Owner: repo.Owner, Name: repo.Name, Since: since, Until: until, Paths: paths}
+	if !compare {
+		return report, nil
+	}
+
+	report.Compared = true
+	client := s.processorFor(tenant.FromContext(ctx)).client
+	path, content, err := client.FetchCodeowners(ctx, repo.Owner, repo.Name)
+	if errors.Is(err, github.ErrNotFound) {
+		return report, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	file, err := codeowners.Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s of %s/%s: %w", path, repo.Owner, repo.Name, err)
+	}
+	report.Codeowners = path
+	for i := range report.Paths {
+		report.Paths[i].CodeOwners, _ = file.Owners(report.Paths[i].Prefix + "/")
+	}
+	return report, nil
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"githubapifetch/clock"
+)
+
+// syncPacer spreads out the repository syncs started by monitoring, so a
+// poll that finds many repositories due does not fire all their requests at
+// GitHub at once and trip its secondary rate limits. It works across
+// tenants, on top of the per-token throttle of each GitHub client. A nil
+// pacer lets every sync start right away.
+type syncPacer struct {
+	clock   clock.Clock
+	slots   chan struct{} // nil without a concurrency ceiling
+	spacing time.Duration
+
+	mu   sync.Mutex
+	next time.Time // earliest start of the next sync
+}
+
+// newSyncPacer returns a pacer running at most concurrency syncs at once and
+// starting them at least spacing apart; 0 disables either limit, and nil is
+// returned when both are
+func newSyncPacer(concurrency int, spacing time.Duration, c clock.Clock) *syncPacer {
+	if concurrency <= 0 && spacing <= 0 {
+		return nil
+	}
+	p := &syncPacer{clock: c, spacing: spacing}
+	if concurrency > 0 {
+		p.slots = make(chan struct{}, concurrency)
+	}
+	return p
+}
+
+// acquire waits until a sync may start and returns the function releasing
+// its slot once it is done, or ctx's error if it ends first
+func (p *syncPacer) acquire(ctx context.Context) (func(), error) {
+	if p == nil {
+		return func() {}, nil
+	}
+	if p.slots != nil {
+		select {
+		case p.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	release := func() {
+		if p.slots != nil {
+			<-p.slots
+		}
+	}
+
+	// Each sync reserves its start, so waiting syncs keep their order
+	p.mu.Lock()
+	now := p.clock.Now()
+	start := p.next
+	if start.Before(now) {
+		start = now
+	}
+	p.next = start.Add(p.spacing)
+	p.mu.Unlock()
+
+	if err := p.clock.Sleep(ctx, start.Sub(now)); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
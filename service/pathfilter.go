@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"githubapifetch/audit"
+	"githubapifetch/models"
+)
+
+// SetPathFilter adds or replaces a named path filter of a repository. Files
+// are fetched for commits synced from then on; resetting the sync point
+// fetches them for older commits too.
+func (s *Service) SetPathFilter(ctx context.Context, repoName, name, pattern string) error {
+	err := s.setPathFilter(ctx, repoName, name, pattern)
+	s.recordAudit(ctx, audit.ActionSetFilter, map[string]interface{}{
+		"repo":    repoName,
+		"filter":  name,
+		"pattern": pattern,
+	}, err)
+	return err
+}
+
+func (s *Service) setPathFilter(ctx context.Context, repoName, name, pattern string) error {
+	if repoName == "" {
+		return fmt.Errorf("repository name cannot be empty")
+	}
+	f, err := models.NewPathFilter(name, pattern)
+	if err != nil {
+		return err
+	}
+	if err := s.database.SetPathFilter(ctx, repoName, *f); err != nil {
+		return fmt.Errorf("failed to set path filter: %w", err)
+	}
+	return nil
+}
+
+// RemovePathFilter removes a named path filter of a repository. The files
+// already stored for its commits are kept.
+func (s *Service) RemovePathFilter(ctx context.Context, repoName, name string) error {
+	err := s.database.RemovePathFilter(ctx, repoName, name)
+	s.recordAudit(ctx, audit.ActionRemoveFilter, map[string]interface{}{"repo": repoName, "filter": name}, err)
+	if err != nil {
+		return fmt.Errorf("failed to remove path filter: %w", err)
+	}
+	return nil
+}
+
+// ListPathFilters returns the path filters of a repository
+func (s *Service) ListPathFilters(ctx context.Context, repoName string) ([]models.PathFilter, error) {
+	return s.database.ListPathFilters(ctx, repoName)
+}
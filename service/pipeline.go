@@ -0,0 +1,346 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"githubapifetch/db"
+	"githubapifetch/github"
+	"githubapifetch/logger"
+	"githubapifetch/metrics"
+	"githubapifetch/models"
+	"githubapifetch/scheduler"
+	"githubapifetch/spool"
+	"githubapifetch/tenant"
+)
+
+// fetchKey identifies a repository of a tenant in a fetch-only process
+type fetchKey struct {
+	tenantID int
+	repo     string // lowercase owner/name
+}
+
+func newFetchKey(tenantID int, owner, name string) fetchKey {
+	return fetchKey{tenantID: tenantID, repo: strings.ToLower(owner + "/" + name)}
+}
+
+// fetchCursor is where a commit listing cut short resumes
+type fetchCursor struct {
+	branch   string
+	since    time.Time
+	nextPage int
+	nextSHA  string
+}
+
+// fetchState is what a fetch-only process, which cannot keep checkpoints in
+// the database, remembers of its listings: where those cut short resume, and
+// the newest commit fetched for each repository, which the database may not
+// hold yet
+type fetchState struct {
+	mu      sync.Mutex
+	cursors map[fetchKey]fetchCursor
+	newest  map[fetchKey]time.Time
+}
+
+func newFetchState() *fetchState {
+	return &fetchState{cursors: map[fetchKey]fetchCursor{}, newest: map[fetchKey]time.Time{}}
+}
+
+func (f *fetchState) cursor(key fetchKey) (fetchCursor, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, ok := f.cursors[key]
+	return c, ok
+}
+
+func (f *fetchState) advance(key fetchKey, c fetchCursor) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cursors[key] = c
+}
+
+// finish forgets the cursor of a completed listing and records its newest
+// commit
+func (f *fetchState) finish(key fetchKey, newest time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.cursors, key)
+	if newest.After(f.newest[key]) {
+		f.newest[key] = newest
+	}
+}
+
+func (f *fetchState) newestFetched(key fetchKey) time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.newest[key]
+}
+
+// fetch is process for a fetch-only processor: it spools the repository and
+// its commit pages for an ingest-only process instead of storing them, so it
+// never writes to the database. A listing cut short resumes where it
+// stopped; a completed one is followed by an entry marking its end.
+func (p *RepositoryProcessor) fetch(ctx, parent context.Context, owner, name string, since time.Time) (int, error) {
+	repo, err := p.client.FetchRepo(ctx, owner, name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch repository %s/%s: %w", owner, name, err)
+	}
+	if repo.Owner.Login != "" && repo.Name != "" {
+		owner, name = repo.Owner.Login, repo.Name
+	}
+	if since.IsZero() {
+		since = repo.CreatedAt
+	}
+	repoJSON, err := json.Marshal(repo)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode repository %s/%s: %w", owner, name, err)
+	}
+
+	tenantID := tenant.FromContext(ctx)
+	key := newFetchKey(tenantID, owner, name)
+	branch := repo.DefaultBranch
+	startPage := 1
+	var walk *firstParentWalk
+	if p.firstParent {
+		walk = &firstParentWalk{}
+	}
+	if c, ok := p.fetched.cursor(key); ok && c.branch == branch {
+		since, startPage = c.since, c.nextPage
+		walk.resume(c.nextSHA)
+	}
+
+	cursor := "since=" + since.UTC().Format(time.RFC3339)
+	if branch != "" {
+		cursor += "&sha=" + branch
+	}
+	entry := spool.Entry{
+		TenantID: tenantID,
+		Owner:    owner,
+		Name:     name,
+		Branch:   branch,
+		Cursor:   cursor,
+		Since:    since,
+		Page:     startPage,
+		Repo:     repoJSON,
+	}
+	commitCount := 0
+	var newest time.Time
+	err = p.client.FetchCommitPages(ctx, owner, name, branch, since, startPage, func(page int, commits []github.CommitResponse) error {
+		if walk != nil {
+			commits = walk.mainline(commits)
+		}
+		entry.Page, entry.NextSHA = page, walk.nextSHA()
+		if _, err := p.spoolPage(entry, commits); err != nil {
+			return err
+		}
+		for _, c := range commits {
+			date := c.Commit.Committer.Date
+			if date.IsZero() {
+				date = c.Commit.Author.Date
+			}
+			if date.After(newest) {
+				newest = date
+			}
+		}
+		commitCount += len(commits)
+		p.fetched.advance(key, fetchCursor{branch: branch, since: since, nextPage: page + 1, nextSHA: entry.NextSHA})
+		entry.Page = page + 1
+		return nil
+	})
+	metrics.SpoolBytes.Set(p.spool.Size())
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) && parent.Err() == nil {
+			return 0, fmt.Errorf("%w: %s/%s after %d commits", ErrSyncDeadline, owner, name, commitCount)
+		}
+		return 0, fmt.Errorf("failed to fetch commits for %s/%s: %w", owner, name, err)
+	}
+
+	entry.Done, entry.Commits, entry.NextSHA = true, nil, ""
+	if _, err := p.spool.Append(entry); err != nil {
+		return 0, fmt.Errorf("failed to spool end of listing of %s/%s: %w", owner, name, err)
+	}
+	p.fetched.finish(key, newest)
+
+	logger.Info("Spooled repository commits for ingestion",
+		zap.String("repo_owner", owner),
+		zap.String("repo_name", name),
+		zap.Int("commit_count", commitCount))
+	return commitCount, nil
+}
+
+// fetchStore is the scheduler store of a fetch-only process. It remembers
+// the due repositories and their newest stored commits, so monitoring goes on
+// through a database outage, and counts the commits fetched but not yet
+// stored as stored, so they are not fetched again.
+type fetchStore struct {
+	store   scheduler.Store
+	fetched *fetchState
+
+	mu     sync.Mutex
+	due    map[int][]models.Repository
+	keys   map[int]fetchKey
+	latest map[int]time.Time
+}
+
+func newFetchStore(store scheduler.Store, fetched *fetchState) *fetchStore {
+	return &fetchStore{
+		store:   store,
+		fetched: fetched,
+		due:     map[int][]models.Repository{},
+		keys:    map[int]fetchKey{},
+		latest:  map[int]time.Time{},
+	}
+}
+
+// DueRepositories returns the repositories due for a check, or those last
+// due while the database is unavailable
+func (f *fetchStore) DueRepositories(ctx context.Context) ([]models.Repository, error) {
+	tenantID := tenant.FromContext(ctx)
+	repos, err := f.store.DueRepositories(ctx)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err != nil {
+		cached, ok := f.due[tenantID]
+		if !ok || !db.IsUnavailable(err) {
+			return nil, err
+		}
+		logger.Warn("Database unavailable, checking the repositories last due", zap.Error(err), zap.Int("repositories", len(cached)))
+		return cached, nil
+	}
+	f.due[tenantID] = repos
+	for _, repo := range repos {
+		f.keys[repo.ID] = newFetchKey(tenantID, repo.Owner, repo.Name)
+	}
+	return repos, nil
+}
+
+// LatestCommitDate returns the date of the newest commit stored or fetched
+func (f *fetchStore) LatestCommitDate(ctx context.Context, repoID int) (time.Time, error) {
+	latest, err := f.store.LatestCommitDate(ctx, repoID)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case err == nil:
+		f.latest[repoID] = latest
+	case db.IsUnavailable(err):
+		latest = f.latest[repoID]
+	default:
+		return time.Time{}, err
+	}
+	if newest := f.fetched.newestFetched(f.keys[repoID]); newest.After(latest) {
+		latest = newest
+	}
+	return latest, nil
+}
+
+// MarkChecked records the check unless the database is unavailable
+func (f *fetchStore) MarkChecked(ctx context.Context, repoID int) error {
+	if err := f.store.MarkChecked(ctx, repoID); err != nil && !db.IsUnavailable(err) {
+		return err
+	}
+	return nil
+}
+
+// MarkSynced records the sync unless the database is unavailable
+func (f *fetchStore) MarkSynced(ctx context.Context, repoID int, succeeded bool) error {
+	if err := f.store.MarkSynced(ctx, repoID, succeeded); err != nil && !db.IsUnavailable(err) {
+		return err
+	}
+	return nil
+}
+
+// ingest stores the pages a fetch-only process spooled for the repository
+// owner/name of the context's tenant, storing the repository as spooled
+// with them first. Pages that fail to store stay spooled and are retried.
+func (p *RepositoryProcessor) ingest(ctx context.Context, owner, name string) error {
+	entries, err := p.spool.Pending(tenant.FromContext(ctx), owner, name)
+	if err != nil || len(entries) == 0 {
+		return err
+	}
+	owner, name = entries[0].Owner, entries[0].Name
+
+	var (
+		storedRepo *models.Repository
+		branch     string
+	)
+	var repo *github.RepoResponse
+	for _, entry := range entries {
+		if len(entry.Repo) > 0 {
+			if err := json.Unmarshal(entry.Repo, &repo); err != nil {
+				return fmt.Errorf("invalid spooled repository %s/%s: %w", owner, name, err)
+			}
+			break
+		}
+	}
+	if repo != nil {
+		err = p.inTx(ctx, func(ctx context.Context) error {
+			var err error
+			if storedRepo, err = p.storeRepository(ctx, owner, name, repo); err != nil {
+				return err
+			}
+			branch, err = p.trackDefaultBranch(ctx, storedRepo, repo.DefaultBranch)
+			return err
+		})
+	} else {
+		// Spooled by a sync that stored the repository itself
+		storedRepo, err = p.db.GetByName(ctx, name)
+		branch = entries[0].Branch
+	}
+	if err != nil {
+		return err
+	}
+	return p.flushSpool(ctx, owner, name, storedRepo, branch, false)
+}
+
+// ingestLoop stores the pages spooled by a fetch-only process every interval
+// until ctx is done
+func (s *Service) ingestLoop(ctx context.Context, interval time.Duration) {
+	ticker := s.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			if err := s.ingestSpooled(ctx); err != nil {
+				logger.Error("Failed to store spooled commits", zap.Error(err))
+			}
+		}
+	}
+}
+
+// ingestSpooled stores the pages spooled for every repository, one
+// repository at a time. The failure of each repository is a
+// scheduler.RepoError.
+func (s *Service) ingestSpooled(ctx context.Context) error {
+	if err := s.spool.Refresh(); err != nil {
+		return err
+	}
+	repos, err := s.spool.Repos()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, repo := range repos {
+		if ctx.Err() != nil {
+			break
+		}
+		repoCtx := tenant.WithID(ctx, repo.TenantID)
+		if err := s.processorFor(repo.TenantID).ingest(repoCtx, repo.Owner, repo.Name); err != nil {
+			errs = append(errs, &scheduler.RepoError{Owner: repo.Owner, Name: repo.Name, Err: err})
+		}
+	}
+	metrics.SpoolBytes.Set(s.spool.Size())
+	return errors.Join(errs...)
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"githubapifetch/github"
+	"githubapifetch/logger"
+	"githubapifetch/models"
+	"githubapifetch/tenant"
+)
+
+// PreflightReport is the outcome of checking a tenant's GitHub token against
+// the repositories it has to sync
+type PreflightReport struct {
+	// RateLimit is the token's core rate limit; nil when the token was
+	// rejected, in which case TokenErr says why and no repository is checked
+	RateLimit *github.RateLimit
+	TokenErr  error
+	Repos     []PreflightRepo
+}
+
+// PreflightRepo is the outcome of checking one repository; Err is nil when
+// the token can read its commits
+type PreflightRepo struct {
+	Owner string
+	Name  string
+	Err   error
+}
+
+// Failed returns the repositories the token cannot read
+func (r *PreflightReport) Failed() []PreflightRepo {
+	var failed []PreflightRepo
+	for _, repo := range r.Repos {
+		if repo.Err != nil {
+			failed = append(failed, repo)
+		}
+	}
+	return failed
+}
+
+// Preflight checks that the GitHub token of the context's tenant is accepted
+// and can read every repository the tenant syncs: its active and quarantined
+// repositories and, for the default tenant, the configured one. It costs one
+// request per repository plus one that does not count against the rate
+// limit. Problems are reported, not returned; the error is only set when the
+// repositories cannot be listed.
+func (s *Service) Preflight(ctx context.Context) (*PreflightReport, error) {
+	repos, err := s.database.ListRepositories(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client := s.processorFor(tenant.FromContext(ctx)).client
+	report := &PreflightReport{}
+	report.RateLimit, report.TokenErr = client.RateLimit(ctx)
+	if report.TokenErr != nil {
+		return report, nil
+	}
+
+	checked := make(map[string]bool)
+	check := func(owner, name string) {
+		key := owner + "/" + name
+		if checked[key] {
+			return
+		}
+		checked[key] = true
+		report.Repos = append(report.Repos, PreflightRepo{Owner: owner, Name: name, Err: client.CheckRepoAccess(ctx, owner, name)})
+	}
+	if tenant.FromContext(ctx) == tenant.DefaultID && s.config.RepoOwner != "" && s.config.RepoName != "" {
+		check(s.config.RepoOwner, s.config.RepoName)
+	}
+	for _, repo := range repos {
+		if repo.Status == models.RepoStatusActive || repo.Status == models.RepoStatusQuarantined {
+			check(repo.Owner, repo.Name)
+		}
+	}
+	return report, nil
+}
+
+// preflight runs Preflight for every tenant at startup and logs the
+// repositories that would fail to sync
+func (s *Service) preflight() {
+	for _, t := range s.tenants {
+		ctx := tenant.WithID(s.ctx, t.ID)
+		report, err := s.Preflight(ctx)
+		if err != nil {
+			logger.Warn("Preflight check failed", zap.String("tenant", t.Name), zap.Error(err))
+			continue
+		}
+		if report.TokenErr != nil {
+			logger.Error("GitHub token rejected; no repository of the tenant will sync",
+				zap.String("tenant", t.Name),
+				zap.Error(report.TokenErr))
+			continue
+		}
+
+		failed := report.Failed()
+		for _, repo := range failed {
+			logger.Error("GitHub token cannot read repository; its syncs will fail",
+				zap.String("tenant", t.Name),
+				zap.String("repo", fmt.Sprintf("%s/%s", repo.Owner, repo.Name)),
+				zap.Error(repo.Err))
+		}
+		logger.Info("Preflight check complete",
+			zap.String("tenant", t.Name),
+			zap.Int("repos", len(report.Repos)),
+			zap.Int("failed", len(failed)),
+			zap.Int("rate_limit_remaining", report.RateLimit.Remaining))
+	}
+}
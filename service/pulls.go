@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"githubapifetch/github"
+	"githubapifetch/logger"
+	"githubapifetch/models"
+)
+
+// syncPullRequests fetches the pull requests of a repository updated since
+// the last stored pull request update, with the size of their change and
+// their reviews, and stores them one by one, least recently updated first,
+// so an interrupted sync continues where it stopped.
+func (p *RepositoryProcessor) syncPullRequests(ctx context.Context, owner, name string, repoID int) error {
+	since, err := p.db.LatestPullRequestUpdate(ctx, repoID)
+	if err != nil {
+		return err
+	}
+	listed, err := p.client.FetchUpdatedPullRequests(ctx, owner, name, since)
+	if err != nil {
+		return fmt.Errorf("failed to sync pull requests of %s/%s: %w", owner, name, err)
+	}
+
+	for i := len(listed) - 1; i >= 0; i-- {
+		pull, err := p.client.FetchPullRequest(ctx, owner, name, listed[i].Number)
+		if err != nil {
+			return fmt.Errorf("failed to sync pull requests of %s/%s: %w", owner, name, err)
+		}
+		reviews, err := p.client.FetchReviews(ctx, owner, name, pull.Number)
+		if err != nil {
+			return fmt.Errorf("failed to sync pull requests of %s/%s: %w", owner, name, err)
+		}
+		if err := p.db.StorePullRequests(ctx, repoID, []models.PullRequest{p.pullRequestModel(repoID, *pull, reviews)}); err != nil {
+			return err
+		}
+	}
+
+	logger.Info("Synced pull requests",
+		zap.String("repo_owner", owner),
+		zap.String("repo_name", name),
+		zap.Time("since", since),
+		zap.Int("pull_request_count", len(listed)))
+	return nil
+}
+
+// pullRequestModel converts a pull request response and its reviews to a
+// model
+func (p *RepositoryProcessor) pullRequestModel(repoID int, pull github.PullRequestResponse, reviews []github.ReviewResponse) models.PullRequest {
+	model := models.PullRequest{
+		RepoID:          repoID,
+		Number:          pull.Number,
+		Title:           pull.Title,
+		State:           pull.State,
+		AuthorLogin:     p.anonymizer.Login(pull.User.Login),
+		Draft:           pull.Draft,
+		Additions:       pull.Additions,
+		Deletions:       pull.Deletions,
+		ChangedFiles:    pull.ChangedFiles,
+		OpenedAt:        pull.CreatedAt,
+		MergedAt:        pull.MergedAt,
+		ClosedAt:        pull.ClosedAt,
+		GitHubUpdatedAt: pull.UpdatedAt,
+	}
+	for _, review := range reviews {
+		model.Reviews = append(model.Reviews, models.Review{
+			GitHubID:      review.ID,
+			ReviewerLogin: p.anonymizer.Login(review.User.Login),
+			State:         review.State,
+			SubmittedAt:   review.SubmittedAt,
+		})
+	}
+	return model
+}
+
+// PullRequestStats describes the cycle times and sizes of the pull requests
+// opened over [since, until) in a repository of the context's tenant, or in
+// all of them when repoName is empty, limited to those opened by authors
+// when it is not empty, in the working time of the configured calendar when
+// workingTime is set. Pull requests are only stored while SYNC_PULL_REQUESTS
+// is set.
+func (s *Service) PullRequestStats(ctx context.Context, repoName string, authors []string, since, until time.Time, workingTime bool) (*models.PullRequestStats, error) {
+	return s.database.PullRequestStats(ctx, repoName, authors, since, until, s.workCalendar(workingTime))
+}
+
+// workCalendar returns the configured calendar when statistics are asked for
+// in working time, nil otherwise
+func (s *Service) workCalendar(workingTime bool) *models.WorkCalendar {
+	if !workingTime {
+		return nil
+	}
+	cal := s.config.WorkCalendar
+	return &cal
+}
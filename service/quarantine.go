@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"githubapifetch/audit"
+	"githubapifetch/backoff"
+	"githubapifetch/db"
+	"githubapifetch/github"
+	"githubapifetch/logger"
+	"githubapifetch/metrics"
+	"githubapifetch/models"
+	"githubapifetch/webhook"
+)
+
+// maxQuarantineBackoff caps the delay between re-checks of a quarantined
+// repository
+const maxQuarantineBackoff = 7 * 24 * time.Hour
+
+// syncRepository syncs a monitored repository, tracking consecutive failures.
+// A repository failing QuarantineAfter times in a row is quarantined: it is
+// left alone until its next re-check, with the delay doubling after every
+// failed re-check. A successful sync returns it to monitoring. Failures are
+// tracked by repository ID, as other owners may have repositories of the
+// same name.
+func (s *Service) syncRepository(ctx context.Context, processor *RepositoryProcessor, repo models.Repository, since time.Time) error {
+	owner, name := repo.Owner, repo.Name
+	err := processor.Process(ctx, owner, name, since)
+	if errors.Is(err, github.ErrInsufficientPermissions) {
+		logger.Error("GitHub token may not read the repository; grant it the permissions named in the error",
+			zap.Error(err),
+			zap.String("repo_owner", owner),
+			zap.String("repo_name", name))
+	}
+	if s.config.QuarantineAfter <= 0 {
+		return err
+	}
+
+	if err == nil {
+		released, clearErr := s.database.ClearRepositoryFailures(ctx, repo.ID)
+		if clearErr != nil {
+			logger.Error("Failed to clear repository failures", zap.Error(clearErr), zap.String("repo_name", name))
+		}
+		if released {
+			logger.Info("Repository released from quarantine",
+				zap.String("repo_owner", owner),
+				zap.String("repo_name", name))
+		}
+		return nil
+	}
+
+	// A sync stopped at its deadline, by a listing cut short, by a database
+	// or GitHub outage or by shutdown made no mistake; it resumes where it
+	// left off
+	if errors.Is(err, ErrSyncDeadline) || errors.Is(err, ErrPartialSync) || errors.Is(err, ErrDatabaseUnavailable) || db.IsUnavailable(err) || errors.Is(err, github.ErrUnavailable) || ctx.Err() != nil {
+		return err
+	}
+	s.recordFailure(ctx, repo, err)
+	return err
+}
+
+// recordFailure counts a failed sync and quarantines the repository once it
+// has failed too often in a row
+func (s *Service) recordFailure(ctx context.Context, repo models.Repository, syncErr error) {
+	owner, name := repo.Owner, repo.Name
+	metrics.SyncFailures.Add(metrics.RepoKey(owner, name), 1)
+
+	failure, err := s.database.RecordRepositoryFailure(ctx, repo.ID, syncErr.Error())
+	if err != nil {
+		logger.Error("Failed to record repository failure", zap.Error(err), zap.String("repo_name", name))
+		return
+	}
+	if failure.Failures < s.config.QuarantineAfter {
+		return
+	}
+
+	strategy := backoff.New(s.config.RetryBackoff, time.Duration(s.config.QuarantineBackoff)*time.Second, maxQuarantineBackoff)
+	nextCheck := s.clock.Now().Add(quarantineBackoff(strategy, failure.Failures-s.config.QuarantineAfter))
+	if err := s.database.QuarantineRepository(ctx, repo.ID, nextCheck); err != nil {
+		logger.Error("Failed to quarantine repository", zap.Error(err), zap.String("repo_name", name))
+		return
+	}
+	metrics.Quarantines.Add(metrics.RepoKey(owner, name), 1)
+
+	logger.Warn("Repository quarantined after consecutive failed syncs",
+		zap.String("repo_owner", owner),
+		zap.String("repo_name", name),
+		zap.Int("failures", failure.Failures),
+		zap.String("last_error", failure.LastError),
+		zap.Time("next_check_at", nextCheck))
+
+	if s.webhooks != nil {
+		s.webhooks.NotifyQuarantine(ctx, webhook.EventRepository{ID: failure.RepoID, Owner: owner, Name: name}, webhook.Quarantine{
+			Failures:    failure.Failures,
+			LastError:   failure.LastError,
+			NextCheckAt: nextCheck.UTC(),
+		})
+	}
+}
+
+// quarantineBackoff returns the re-check delay after the given number of
+// failed re-checks
+func quarantineBackoff(strategy backoff.Backoff, recheckFailures int) time.Duration {
+	return backoff.Nth(strategy, recheckFailures+1)
+}
+
+// RequeueRepository returns a quarantined repository to monitoring without
+// waiting for its next re-check
+func (s *Service) RequeueRepository(ctx context.Context, name string) error {
+	if name == "" {
+		return fmt.Errorf("repository name cannot be empty")
+	}
+
+	err := s.database.RequeueRepository(ctx, name)
+	s.recordAudit(ctx, audit.ActionRequeueRepo, map[string]interface{}{"repo": name}, err)
+	if err != nil {
+		return fmt.Errorf("failed to requeue repository: %w", err)
+	}
+	return nil
+}
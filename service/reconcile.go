@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"githubapifetch/github"
+	"githubapifetch/logger"
+	"githubapifetch/models"
+	"githubapifetch/scheduler"
+	"githubapifetch/tenant"
+)
+
+// Reconcile verifies that a stored repository's owner/name still resolves to
+// the same GitHub repository. Repositories without a known GitHub ID are
+// resolved by name first. When GitHub reports a different owner/name, the row
+// is renamed, merging any row already stored under the new name. It reports
+// whether the repository was renamed.
+func (p *RepositoryProcessor) Reconcile(ctx context.Context, repo models.Repository) (bool, error) {
+	var (
+		remote *github.RepoResponse
+		err    error
+	)
+	if repo.GitHubID == 0 {
+		// GitHub follows renames and transfers of owner/name with a redirect
+		remote, err = p.client.FetchRepo(ctx, repo.Owner, repo.Name)
+		if err != nil {
+			return false, fmt.Errorf("failed to resolve repository %s/%s: %w", repo.Owner, repo.Name, err)
+		}
+		if err := p.db.SetGitHubIdentity(ctx, repo.ID, remote.ID, remote.NodeID); err != nil {
+			return false, err
+		}
+	} else {
+		remote, err = p.client.FetchRepoByID(ctx, repo.GitHubID)
+		if err != nil {
+			return false, fmt.Errorf("failed to resolve repository %s/%s: %w", repo.Owner, repo.Name, err)
+		}
+	}
+
+	if remote.Owner.Login == repo.Owner && remote.Name == repo.Name {
+		return false, nil
+	}
+	if remote.Owner.Login == "" || remote.Name == "" {
+		return false, fmt.Errorf("GitHub returned no owner/name for repository %s/%s", repo.Owner, repo.Name)
+	}
+
+	logger.Info("Repository renamed or transferred on GitHub",
+		zap.String("old_owner", repo.Owner),
+		zap.String("old_name", repo.Name),
+		zap.String("owner", remote.Owner.Login),
+		zap.String("name", remote.Name),
+		zap.Int64("github_id", remote.ID))
+
+	if _, err := p.db.RenameRepository(ctx, repo.ID, remote.Owner.Login, remote.Name); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Reconcile checks every repository of the context's tenant for renames and
+// transfers and returns the number of repositories renamed
+func (s *Service) Reconcile(ctx context.Context) (int, error) {
+	repos, err := s.database.ListRepositories(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	processor := s.processorFor(tenant.FromContext(ctx))
+	renamed := 0
+	var errs []error
+	for _, repo := range repos {
+		if ctx.Err() != nil {
+			return renamed, fmt.Errorf("context cancelled: %w", ctx.Err())
+		}
+		ok, err := processor.Reconcile(ctx, repo)
+		if err != nil {
+			// Deleted or inaccessible repositories are left as they are
+			if errors.Is(err, github.ErrNotFound) {
+				logger.Warn("Repository no longer resolves on GitHub",
+					zap.String("owner", repo.Owner),
+					zap.String("name", repo.Name),
+					zap.Int64("github_id", repo.GitHubID))
+				continue
+			}
+			errs = append(errs, &scheduler.RepoError{Owner: repo.Owner, Name: repo.Name, Err: err})
+			continue
+		}
+		if ok {
+			renamed++
+		}
+	}
+
+	if len(errs) > 0 {
+		return renamed, fmt.Errorf("errors occurred while reconciling repositories: %w", errors.Join(errs...))
+	}
+	return renamed, nil
+}
+
+// reconcileLoop periodically reconciles the repositories of the context's tenant
+func (s *Service) reconcileLoop(ctx context.Context, interval time.Duration) {
+	ticker := s.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			renamed, err := s.Reconcile(ctx)
+			if err != nil {
+				logger.Error("Repository reconciliation failed", zap.Error(err))
+			}
+			if renamed > 0 {
+				logger.Info("Reconciled renamed repositories", zap.Int("renamed", renamed))
+			}
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"githubapifetch/changelog"
+	"githubapifetch/logger"
+	"githubapifetch/models"
+	"githubapifetch/tenant"
+	"githubapifetch/webhook"
+)
+
+// syncReleases fetches the published releases of a repository and stores
+// them. Releases can be edited at any time and the endpoint cannot tell which
+// were, so every sync lists all of them; only the ones stored for the first
+// time are announced.
+func (p *RepositoryProcessor) syncReleases(ctx context.Context, owner, name string, repoID int) error {
+	listed, err := p.client.FetchReleases(ctx, owner, name)
+	if err != nil {
+		return fmt.Errorf("failed to sync releases of %s/%s: %w", owner, name, err)
+	}
+
+	releases := make([]models.Release, len(listed))
+	for i, release := range listed {
+		releases[i] = models.Release{
+			RepoID:      repoID,
+			GitHubID:    release.ID,
+			TagName:     release.TagName,
+			Name:        release.Name,
+			Prerelease:  release.Prerelease,
+			PublishedAt: *release.PublishedAt,
+		}
+	}
+	added, err := p.db.StoreReleases(ctx, repoID, releases)
+	if err != nil {
+		return err
+	}
+	if p.notifier != nil && len(added) > 0 {
+		afterCommit(ctx, func(ctx context.Context) {
+			p.notifier.NotifyReleases(ctx, webhook.EventRepository{ID: repoID, Owner: owner, Name: name}, added)
+		})
+	}
+
+	logger.Info("Synced releases",
+		zap.String("repo_owner", owner),
+		zap.String("repo_name", name),
+		zap.Int("release_count", len(releases)))
+	return nil
+}
+
+// ReleaseCadence describes how often each repository of the context's
+// tenant published releases in [since, until), prereleases included when
+// prereleases is set. Releases are only stored while SYNC_RELEASES is set.
+func (s *Service) ReleaseCadence(ctx context.Context, since, until time.Time, prereleases bool) ([]models.ReleaseCadence, error) {
+	return s.database.ReleaseCadence(ctx, since, until, prereleases)
+}
+
+// Changelog assembles the changes of a repository of the context's tenant
+// from the stored commits committed after the commit tagged from, up to and
+// including the commit tagged to. Tags are resolved on GitHub, so any tag or
+// other ref works. Without from, the release stored before the release of to
+// is used, or every commit up to to when there is none. Commits are told
+// apart by their committer dates, so commits of other branches merged in
+// after from was tagged are missed.
+func (s *Service) Changelog(ctx context.Context, repoName, from, to string) (*changelog.Changelog, error) {
+	repo, err := s.database.GetByName(ctx, repoName)
+	if err != nil {
+		return nil, err
+	}
+	if from == "" {
+		releases, err := s.database.ListReleases(ctx, repoName)
+		if err != nil {
+			return nil, err
+		}
+		for i, release := range releases {
+			if release.TagName == to && i+1 < len(releases) {
+				from = releases[i+1].TagName
+				break
+			}
+		}
+	}
+
+	client := s.processorFor(tenant.FromContext(ctx)).client
+	toCommit, err := client.FetchCommit(ctx, repo.Owner, repo.Name, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s of %s/%s: %w", to, repo.Owner, repo.Name, err)
+	}
+	var after time.Time
+	if from != "" {
+		fromCommit, err := client.FetchCommit(ctx, repo.Owner, repo.Name, from)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s of %s/%s: %w", from, repo.Owner, repo.Name, err)
+		}
+		after = fromCommit.Commit.Committer.Date
+	}
+
+	commits, err := s.database.CommitsBetween(ctx, repoName, after, toCommit.Commit.Committer.Date)
+	if err != nil {
+		return nil, err
+	}
+	return changelog.Build(from, to, repo.URL, commits), nil
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"githubapifetch/audit"
+	"githubapifetch/backoff"
+	"githubapifetch/config"
+	"githubapifetch/github"
+	"githubapifetch/logger"
+	"githubapifetch/models"
+	"githubapifetch/progress"
+	"githubapifetch/tenant"
+)
+
+// newGitHubClient creates a client for token reporting fetch progress to
+// tracker, persisting raw responses when STORE_RAW_PAYLOADS is enabled and
+// rate limits when RECORD_RATE_LIMITS is
+// Base delay and cap of the backoff between rate limited GitHub requests
+const (
+	githubRetryDelay    = time.Second
+	githubMaxRetryDelay = time.Minute
+)
+
+func newGitHubClient(cfg *config.Config, database DBInterface, tracker *progress.Tracker, token string) *github.Client {
+	opts := []github.Option{github.WithAPIVersion(cfg.GitHubAPIVersion), github.WithProgress(func(ctx context.Context, p github.Progress) {
+		tracker.Report(tenant.FromContext(ctx), progress.Update{
+			Owner:      p.Owner,
+			Name:       p.Name,
+			Pages:      p.Page,
+			TotalPages: p.LastPage,
+			Commits:    p.Commits,
+			Done:       p.Done,
+			Err:        p.Err,
+		})
+	}), github.WithBackoff(backoff.New(cfg.RetryBackoff, githubRetryDelay, githubMaxRetryDelay))}
+	for endpoint, policy := range cfg.GitHubEndpoints {
+		opts = append(opts, github.WithEndpointPolicy(endpoint, policy))
+	}
+	if cfg.StoreRawPayloads {
+		opts = append(opts, github.WithPayloadSink(func(ctx context.Context, p github.Payload) error {
+			return database.StoreRawPayload(ctx, models.RawPayload{
+				Owner:   p.Owner,
+				Name:    p.Name,
+				Kind:    p.Kind,
+				Page:    p.Page,
+				Payload: p.Body,
+			})
+		}))
+	}
+	if cfg.RecordRateLimits {
+		opts = append(opts, github.WithRateLimitObserver(func(ctx context.Context, rl github.RateLimit, observed time.Time) {
+			resource := rl.Resource
+			if resource == "" {
+				resource = "core"
+			}
+			if err := database.RecordRateLimit(ctx, models.RateLimitSample{
+				Resource:   resource,
+				Limit:      rl.Limit,
+				Remaining:  rl.Remaining,
+				ResetAt:    rl.Reset,
+				ObservedAt: observed,
+			}); err != nil {
+				logger.Error("Failed to record rate limit", zap.Error(err), zap.String("resource", resource))
+			}
+		}))
+	}
+	return github.NewClient(token, opts...)
+}
+
+// Replay re-processes the stored raw payloads of a repository without
+// contacting GitHub and returns the number of payloads replayed
+func (s *Service) Replay(ctx context.Context, repoName string) (int, error) {
+	if repoName == "" {
+		return 0, fmt.Errorf("repository name cannot be empty")
+	}
+
+	count, err := s.replay(ctx, repoName)
+	s.recordAudit(ctx, audit.ActionReplay, map[string]interface{}{
+		"repo":     repoName,
+		"payloads": count,
+	}, err)
+	return count, err
+}
+
+func (s *Service) replay(ctx context.Context, repoName string) (int, error) {
+	repo, err := s.database.GetByName(ctx, repoName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get repository: %w", err)
+	}
+
+	payloads, err := s.database.ListRawPayloads(ctx, repo.Owner, repo.Name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load raw payloads: %w", err)
+	}
+	if len(payloads) == 0 {
+		return 0, fmt.Errorf("no raw payloads stored for %s/%s; enable STORE_RAW_PAYLOADS to record them", repo.Owner, repo.Name)
+	}
+
+	return s.processorFor(tenant.FromContext(ctx)).Replay(ctx, repo, payloads)
+}
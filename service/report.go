@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"githubapifetch/models"
+)
+
+// KnowledgeReport returns the knowledge concentration of every repository of
+// the context's tenant over commits dated in [since, until), measured against
+// its top authors
+func (s *Service) KnowledgeReport(ctx context.Context, since, until time.Time, top int) ([]models.KnowledgeConcentration, error) {
+	return s.database.KnowledgeConcentration(ctx, since, until, top)
+}
+
+// RepositoryStats returns the commit statistics of a repository of the
+// context's tenant, limited to the commits matching the named path filter
+// unless pathFilter is empty, and to those filter selects
+func (s *Service) RepositoryStats(ctx context.Context, repoName, pathFilter string, filter models.StatsFilter) (*models.RepositoryStats, error) {
+	return s.database.GetRepositoryStats(ctx, repoName, pathFilter, filter)
+}
+
+// RollupStats returns the commit statistics of the repositories of the
+// context's tenant owned by owner and in group, with its top busiest ones
+func (s *Service) RollupStats(ctx context.Context, owner, group string, filter models.StatsFilter, top int) (*models.RollupStats, error) {
+	return s.database.RollupStats(ctx, owner, group, filter, top)
+}
+
+// SearchRepositories returns a page of the repositories of the context's
+// tenant the filter selects, with their stored commit counts
+func (s *Service) SearchRepositories(ctx context.Context, filter models.RepositoryFilter, params models.PaginationParams) ([]models.RepositoryListing, error) {
+	return s.database.SearchRepositories(ctx, filter, params)
+}
+
+// ActivityFeed returns up to limit of the latest commits, releases and
+// merged pull requests of the context's tenant, newest first, after cursor
+func (s *Service) ActivityFeed(ctx context.Context, cursor models.ActivityCursor, limit int) (*models.ActivityPage, error) {
+	return s.database.ActivityFeed(ctx, cursor, limit)
+}
+
+// CommitHeatmap returns the punch card of the context's tenant: its commits
+// authored in [since, until) by day of week and hour of day in timezone,
+// for one repository and one author when given
+func (s *Service) CommitHeatmap(ctx context.Context, repoName, author string, since, until time.Time, timezone string) (*models.Heatmap, error) {
+	return s.database.CommitHeatmap(ctx, repoName, author, since, until, timezone)
+}
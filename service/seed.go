@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"githubapifetch/audit"
+	"githubapifetch/db"
+	"githubapifetch/models"
+	"githubapifetch/seed"
+)
+
+// Seed stores synthetic repositories with commits in total between them,
+// generated from rngSeed, for the context's tenant. The repositories are
+// paused so the monitor never looks them up on GitHub. Seeding again with
+// the same arguments on the same day adds nothing.
+func (s *Service) Seed(ctx context.Context, repos, commits int, rngSeed int64) error {
+	err := s.seed(ctx, repos, commits, rngSeed)
+	s.recordAudit(ctx, audit.ActionSeed, map[string]interface{}{
+		"repos":   repos,
+		"commits": commits,
+		"seed":    rngSeed,
+	}, err)
+	return err
+}
+
+func (s *Service) seed(ctx context.Context, repos, commits int, rngSeed int64) error {
+	if repos <= 0 || commits < 0 {
+		return fmt.Errorf("the number of repositories must be positive and of commits not negative")
+	}
+
+	g := seed.New(rngSeed, s.clock.Now().UTC().Truncate(24*time.Hour))
+	for i, n := range g.Split(commits, repos) {
+		repo := g.Repository(i)
+		existing, err := s.database.GetByName(ctx, repo.Name)
+		switch {
+		case err == nil && existing.Owner != repo.Owner:
+			return fmt.Errorf("repository %s already exists under %s", repo.Name, existing.Owner)
+		case err != nil && !errors.Is(err, db.ErrRepositoryNotFound):
+			return err
+		}
+
+		if err := s.database.StoreRepository(ctx, repo); err != nil {
+			return fmt.Errorf("failed to store repository %s: %w", repo.Name, err)
+		}
+		if err := s.database.SetRepositoryStatus(ctx, repo.Name, models.RepoStatusPaused); err != nil {
+			return fmt.Errorf("failed to pause repository %s: %w", repo.Name, err)
+		}
+		stored, err := s.database.GetByName(ctx, repo.Name)
+		if err != nil {
+			return err
+		}
+
+		page := 0
+		err = g.History(repo, n, func(commits []models.Commit) error {
+			page++
+			_, err := s.database.IngestCommitPage(ctx, stored.ID, fmt.Sprintf("seed=%d&page=%d", rngSeed, page), commits)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to store commits of %s: %w", repo.Name, err)
+		}
+	}
+	return nil
+}
@@ -2,14 +2,33 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"githubapifetch/anonymize"
+	"githubapifetch/api"
+	"githubapifetch/audit"
+	"githubapifetch/backoff"
+	"githubapifetch/clock"
 	"githubapifetch/config"
 	"githubapifetch/db"
+	"githubapifetch/features"
 	"githubapifetch/github"
+	"githubapifetch/lifecycle"
 	"githubapifetch/logger"
+	"githubapifetch/metrics"
 	"githubapifetch/models"
+	"githubapifetch/progress"
+	"githubapifetch/scheduler"
+	"githubapifetch/secrets"
+	"githubapifetch/sink"
+	"githubapifetch/spool"
+	"githubapifetch/synclag"
+	"githubapifetch/tenant"
+	"githubapifetch/webhook"
+	"io"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
@@ -21,8 +40,76 @@ import (
 type DBInterface interface {
 	StoreRepository(ctx context.Context, repo models.Repository) error
 	GetByName(ctx context.Context, name string) (*models.Repository, error)
-	BatchInsert(ctx context.Context, commits []models.Commit) error
-	MonitorRepositoryChanges(ctx context.Context, interval time.Duration, callback func(string, time.Time) error)
+	GetByGitHubID(ctx context.Context, githubID int64) (*models.Repository, error)
+	GetByOwnerAndName(ctx context.Context, owner, name string) (*models.Repository, error)
+	GetLatestDate(ctx context.Context, repoID int) (time.Time, error)
+	IngestCommitPage(ctx context.Context, repoID int, cursor string, commits []models.Commit) (bool, error)
+	scheduler.Store
+	CreateTenant(ctx context.Context, t models.Tenant) (int, error)
+	ListTenants(ctx context.Context) ([]models.Tenant, error)
+	GetTenantByName(ctx context.Context, name string) (*models.Tenant, error)
+	EncryptTenantTokens(ctx context.Context) (int, error)
+	SetRepositoryStatus(ctx context.Context, name, status string) error
+	ActivateRepository(ctx context.Context, repoID int) error
+	SetStartDate(ctx context.Context, repoID int, start time.Time) error
+	SetRepositoryGroup(ctx context.Context, name, group string) error
+	RecordAudit(ctx context.Context, entry models.AuditEntry) error
+	ListAuditEntries(ctx context.Context, action string, limit int) ([]models.AuditEntry, error)
+	StoreRawPayload(ctx context.Context, p models.RawPayload) error
+	RecordDeadLetter(ctx context.Context, dl models.WebhookDeadLetter) error
+	ListRawPayloads(ctx context.Context, owner, name string) ([]models.RawPayload, error)
+	ListRepositories(ctx context.Context) ([]models.Repository, error)
+	SearchRepositories(ctx context.Context, filter models.RepositoryFilter, params models.PaginationParams) ([]models.RepositoryListing, error)
+	SetGitHubIdentity(ctx context.Context, repoID int, githubID int64, nodeID string) error
+	RenameRepository(ctx context.Context, repoID int, newOwner, newName string) (bool, error)
+	GetSyncCheckpoint(ctx context.Context, repoID int) (*models.SyncCheckpoint, error)
+	SaveSyncCheckpoint(ctx context.Context, cp models.SyncCheckpoint) error
+	DeleteSyncCheckpoint(ctx context.Context, repoID int) error
+	Now(ctx context.Context) (time.Time, error)
+	CountCommitWrites(ctx context.Context, repoID int, since time.Time) (inserted, updated int, err error)
+	RecordRepositoryFailure(ctx context.Context, repoID int, lastError string) (*models.RepositoryFailure, error)
+	QuarantineRepository(ctx context.Context, repoID int, nextCheck time.Time) error
+	ClearRepositoryFailures(ctx context.Context, repoID int) (bool, error)
+	RequeueRepository(ctx context.Context, name string) error
+	RegisterRepositories(ctx context.Context, repos []models.Repository) (int, error)
+	KnownCommitSHAs(ctx context.Context, repoID int, shas []string) (map[string]bool, error)
+	SampleCommits(ctx context.Context, repoID, n int) ([]models.Commit, error)
+	SetDefaultBranch(ctx context.Context, repoID int, branch string) (string, error)
+	SetPathFilter(ctx context.Context, repoName string, f models.PathFilter) error
+	RemovePathFilter(ctx context.Context, repoName, name string) error
+	ListPathFilters(ctx context.Context, repoName string) ([]models.PathFilter, error)
+	CommitsWithoutFiles(ctx context.Context, repoID int, shas []string) ([]string, error)
+	StoreCommitFiles(ctx context.Context, repoID int, sha string, paths []string) error
+	KnowledgeConcentration(ctx context.Context, since, until time.Time, top int) ([]models.KnowledgeConcentration, error)
+	RepositoryDiff(ctx context.Context, repoName string, from, to time.Time) (*models.RepositoryDiff, error)
+	CommitHeatmap(ctx context.Context, repoName, author string, since, until time.Time, timezone string) (*models.Heatmap, error)
+	GetRepositoryStats(ctx context.Context, repoName, pathFilter string, filter models.StatsFilter) (*models.RepositoryStats, error)
+	RollupStats(ctx context.Context, owner, group string, filter models.StatsFilter, top int) (*models.RollupStats, error)
+	PathOwnership(ctx context.Context, repoName string, since, until time.Time, depth, top, limit int) ([]models.PathOwnership, error)
+	StoreIssues(ctx context.Context, repoID int, issues []models.Issue) error
+	LatestIssueUpdate(ctx context.Context, repoID int) (time.Time, error)
+	IssueStats(ctx context.Context, repoName, label string, since, until time.Time, cal *models.WorkCalendar) (*models.IssueStats, error)
+	StorePullRequests(ctx context.Context, repoID int, pulls []models.PullRequest) error
+	LatestPullRequestUpdate(ctx context.Context, repoID int) (time.Time, error)
+	PullRequestStats(ctx context.Context, repoName string, authors []string, since, until time.Time, cal *models.WorkCalendar) (*models.PullRequestStats, error)
+	StoreReleases(ctx context.Context, repoID int, releases []models.Release) ([]models.Release, error)
+	ListReleases(ctx context.Context, repoName string) ([]models.Release, error)
+	ReleaseCadence(ctx context.Context, since, until time.Time, prereleases bool) ([]models.ReleaseCadence, error)
+	CommitsBetween(ctx context.Context, repoName string, after, until time.Time) ([]models.Commit, error)
+	StoreDeployments(ctx context.Context, repoID int, deployments []models.Deployment) error
+	RecordRateLimit(ctx context.Context, sample models.RateLimitSample) error
+	StoreOwner(ctx context.Context, o models.Owner) error
+	GetOwner(ctx context.Context, login string) (*models.Owner, error)
+	ListOwners(ctx context.Context) ([]models.OwnerListing, error)
+	DeploymentSyncStart(ctx context.Context, repoID int) (time.Time, error)
+	DeploymentCommits(ctx context.Context, repoName string, githubID int64) (*models.DeploymentCommits, error)
+	CommitDeployment(ctx context.Context, repoName, sha, environment string) (*models.CommitDeployment, error)
+	Dump(ctx context.Context, w io.Writer) ([]models.DumpTable, error)
+	Restore(ctx context.Context, r io.Reader, replace bool) ([]models.DumpTable, error)
+	Changes(ctx context.Context, table string, since time.Time, afterKey string, limit int) ([]models.Change, error)
+	ActivityFeed(ctx context.Context, cursor models.ActivityCursor, limit int) (*models.ActivityPage, error)
+	PurgeRepository(ctx context.Context, owner, name string, dryRun bool) (*models.PurgeResult, error)
+	InTx(ctx context.Context, fn func(ctx context.Context) error) error
 	Close() error
 }
 
@@ -30,34 +117,289 @@ type DBInterface interface {
 // (for testability)
 type GitHubClientInterface interface {
 	FetchRepo(ctx context.Context, owner, name string) (*github.RepoResponse, error)
-	FetchCommits(ctx context.Context, owner, name string, since time.Time) ([]github.CommitResponse, error)
+	FetchRepoByID(ctx context.Context, id int64) (*github.RepoResponse, error)
+	FetchCommitPages(ctx context.Context, owner, name, branch string, since time.Time, startPage int, fn github.CommitPageFunc) error
+	FetchCommitFiles(ctx context.Context, owner, name, sha string) ([]string, error)
+	CheckAPIVersion(ctx context.Context) (*github.APIVersion, error)
+	RateLimit(ctx context.Context) (*github.RateLimit, error)
+	CheckRepoAccess(ctx context.Context, owner, name string) error
+	CountCommits(ctx context.Context, owner, name string, since time.Time) (int, error)
+	FetchCommit(ctx context.Context, owner, name, sha string) (*github.CommitResponse, error)
+	FetchCodeowners(ctx context.Context, owner, name string) (string, []byte, error)
+	FetchIssuePages(ctx context.Context, owner, name string, since time.Time, fn github.IssuePageFunc) error
+	FetchUpdatedPullRequests(ctx context.Context, owner, name string, since time.Time) ([]github.PullRequestResponse, error)
+	FetchPullRequest(ctx context.Context, owner, name string, number int) (*github.PullRequestResponse, error)
+	FetchReviews(ctx context.Context, owner, name string, number int) ([]github.ReviewResponse, error)
+	FetchReleases(ctx context.Context, owner, name string) ([]github.ReleaseResponse, error)
+	FetchDeployments(ctx context.Context, owner, name string, since time.Time) ([]github.DeploymentResponse, error)
+	FetchDeploymentStatuses(ctx context.Context, owner, name string, id int64) ([]github.DeploymentStatusResponse, error)
+	FetchOwner(ctx context.Context, login string) (*github.OwnerResponse, error)
+	FetchStarred(ctx context.Context) ([]github.RepoResponse, error)
 }
 
 // Service errors
 var (
 	ErrServiceInit     = fmt.Errorf("service initialization error")
 	ErrServiceShutdown = fmt.Errorf("service shutdown error")
+	// ErrSyncDeadline is returned when a sync stops at its deadline; it
+	// resumes from its checkpoint on the next sync
+	ErrSyncDeadline = fmt.Errorf("sync deadline exceeded")
+	// ErrPartialSync is returned when a commit listing ends before the last
+	// page GitHub announced; the sync continues from its checkpoint on the
+	// next sync
+	ErrPartialSync = fmt.Errorf("partial sync")
+	// ErrDatabaseUnavailable is returned when the database went away during
+	// a sync whose remaining pages were spooled; the next sync stores them
+	// before fetching anything new
+	ErrDatabaseUnavailable = fmt.Errorf("database unavailable")
 )
 
+// Notifier is told about commits and releases as soon as they are ingested,
+// and about repositories switching their default branch
+type Notifier interface {
+	NotifyCommits(ctx context.Context, repo webhook.EventRepository, commits []models.Commit)
+	NotifyReleases(ctx context.Context, repo webhook.EventRepository, releases []models.Release)
+	NotifyDefaultBranch(ctx context.Context, repo webhook.EventRepository, change webhook.BranchChange)
+}
+
+// CommitSink receives newly ingested commits for an analytical store
+type CommitSink interface {
+	AddCommits(ctx context.Context, repoID int, owner, name string, commits []models.Commit)
+}
+
 // RepositoryProcessor handles the core repository processing logic
 type RepositoryProcessor struct {
-	db     DBInterface
-	client GitHubClientInterface
+	db          DBInterface
+	client      GitHubClientInterface
+	syncTimeout time.Duration
+	notifier    Notifier
+	// maxMessageBytes caps stored commit messages; 0 means no cap
+	maxMessageBytes int
+	syncLag         *synclag.Tracker
+	// storeParents keeps the parent SHAs of every commit
+	storeParents bool
+	// firstParent skips commits off the first-parent chain
+	firstParent bool
+	// features says which repositories have their issues, pull requests,
+	// releases and deployments synced after their commits
+	features *features.Flags
+	// syncOwners fetches the profiles of repository owners not fetched
+	// within ownerRefresh
+	syncOwners bool
+	sink       CommitSink
+	clock      clock.Clock
+	// spool keeps the pages fetched while the database is unavailable, and
+	// with writeAhead every page, until it is stored
+	spool      *spool.Spool
+	writeAhead bool
+	// fetched, set only in a fetch-only process, makes Process spool pages
+	// for an ingest-only process instead of storing them
+	fetched *fetchState
+	// anonymizer, when set, replaces the names of people with pseudonyms
+	anonymizer *anonymize.Anonymizer
+	// omit lists the commit fields left out of storage
+	omit models.OmitFields
+}
+
+// ProcessorOption configures a RepositoryProcessor
+type ProcessorOption func(*RepositoryProcessor)
+
+// WithSyncTimeout bounds how long a single Process call may run; zero means
+// no limit
+func WithSyncTimeout(d time.Duration) ProcessorOption {
+	return func(p *RepositoryProcessor) {
+		p.syncTimeout = d
+	}
+}
+
+// WithNotifier reports every newly ingested page of commits to n
+func WithNotifier(n Notifier) ProcessorOption {
+	return func(p *RepositoryProcessor) {
+		p.notifier = n
+	}
+}
+
+// WithMaxMessageBytes truncates stored commit messages to n bytes; 0 keeps
+// them whole
+func WithMaxMessageBytes(n int) ProcessorOption {
+	return func(p *RepositoryProcessor) {
+		p.maxMessageBytes = n
+	}
+}
+
+// WithAnonymizer stores pseudonyms from a in place of the names, logins and
+// email addresses of the people behind commits, issues and pull requests
+func WithAnonymizer(a *anonymize.Anonymizer) ProcessorOption {
+	return func(p *RepositoryProcessor) {
+		p.anonymizer = a
+	}
+}
+
+// WithOmitFields leaves the fields o omits out of every stored commit
+func WithOmitFields(o models.OmitFields) ProcessorOption {
+	return func(p *RepositoryProcessor) {
+		p.omit = o
+	}
+}
+
+// WithSyncLag reports the pushes GitHub announces and successful syncs to t
+func WithSyncLag(t *synclag.Tracker) ProcessorOption {
+	return func(p *RepositoryProcessor) {
+		p.syncLag = t
+	}
+}
+
+// WithCommitParents stores the parent SHAs of ingested commits, so merge
+// structure can be reconstructed
+func WithCommitParents(enabled bool) ProcessorOption {
+	return func(p *RepositoryProcessor) {
+		p.storeParents = enabled
+	}
+}
+
+// WithFirstParent stores only the commits on the first-parent chain of the
+// synced branch, leaving out those merged in from other branches
+func WithFirstParent(enabled bool) ProcessorOption {
+	return func(p *RepositoryProcessor) {
+		p.firstParent = enabled
+	}
+}
+
+// WithIssues syncs the issues of every processed repository after its
+// commits, from the last stored issue update on
+func WithIssues(enabled bool) ProcessorOption {
+	return func(p *RepositoryProcessor) {
+		p.features = p.features.With(features.Issues, enabled)
+	}
+}
+
+// WithPullRequests syncs the pull requests of every processed repository and
+// their reviews after its commits, from the last stored pull request update
+// on
+func WithPullRequests(enabled bool) ProcessorOption {
+	return func(p *RepositoryProcessor) {
+		p.features = p.features.With(features.PullRequests, enabled)
+	}
+}
+
+// WithReleases syncs the published releases of every processed repository
+// after its commits
+func WithReleases(enabled bool) ProcessorOption {
+	return func(p *RepositoryProcessor) {
+		p.features = p.features.With(features.Releases, enabled)
+	}
+}
+
+// WithDeployments syncs the deployments of every processed repository and
+// their statuses after its commits
+func WithDeployments(enabled bool) ProcessorOption {
+	return func(p *RepositoryProcessor) {
+		p.features = p.features.With(features.Deployments, enabled)
+	}
+}
+
+// WithOwners fetches the profile of the user or organization owning every
+// processed repository, unless it was fetched within the last day
+func WithOwners(enabled bool) ProcessorOption {
+	return func(p *RepositoryProcessor) {
+		p.syncOwners = enabled
+	}
+}
+
+// WithFeatures syncs the issues, pull requests, releases and deployments of
+// the repositories f enables them for, replacing the defaults set by
+// WithIssues and the like
+func WithFeatures(f *features.Flags) ProcessorOption {
+	return func(p *RepositoryProcessor) {
+		p.features = f
+	}
+}
+
+// WithSink copies every newly ingested commit to s
+func WithSink(s CommitSink) ProcessorOption {
+	return func(p *RepositoryProcessor) {
+		p.sink = s
+	}
+}
+
+// WithSpool spools the commit pages a sync fetches once the database has
+// become unavailable to s, and stores them on the repository's next sync
+func WithSpool(s *spool.Spool) ProcessorOption {
+	return func(p *RepositoryProcessor) {
+		p.spool = s
+	}
+}
+
+// WithWriteAhead spools every fetched commit page before storing it, with
+// WithSpool, and stores the pages in the background while the sync goes on
+// fetching; pages a crash left behind are stored on the next sync
+func WithWriteAhead(enabled bool) ProcessorOption {
+	return func(p *RepositoryProcessor) {
+		p.writeAhead = enabled
+	}
+}
+
+// WithFetchOnly makes Process fetch repositories and their commits into the
+// spool set with WithSpool without touching the database, for an ingest-only
+// process to store; issues, pull requests, releases and deployments are not
+// synced
+func WithFetchOnly(enabled bool) ProcessorOption {
+	return func(p *RepositoryProcessor) {
+		p.fetched = nil
+		if enabled {
+			p.fetched = newFetchState()
+		}
+	}
+}
+
+// WithClock sets the clock syncs are timed on
+func WithClock(c clock.Clock) ProcessorOption {
+	return func(p *RepositoryProcessor) {
+		p.clock = c
+	}
 }
 
 // NewRepositoryProcessor creates a new processor
-func NewRepositoryProcessor(db DBInterface, client GitHubClientInterface) *RepositoryProcessor {
-	return &RepositoryProcessor{
-		db:     db,
-		client: client,
+func NewRepositoryProcessor(db DBInterface, client GitHubClientInterface, opts ...ProcessorOption) *RepositoryProcessor {
+	p := &RepositoryProcessor{
+		db:              db,
+		client:          client,
+		maxMessageBytes: models.DefaultMaxMessageBytes,
+		clock:           clock.Real,
+	}
+	for _, opt := range opts {
+		opt(p)
 	}
+	return p
 }
 
-// Process handles a single repository processing operation
+// Process handles a single repository processing operation. A zero since
+// backfills from the repository's creation on GitHub. With a sync timeout, a
+// sync still running at the deadline stops after its last stored page and
+// returns ErrSyncDeadline; the next Process resumes from there, as it does
+// after ErrPartialSync.
 func (p *RepositoryProcessor) Process(ctx context.Context, owner, name string, since time.Time) error {
+	_, err := p.process(ctx, owner, name, since)
+	return err
+}
+
+// process is Process, also returning the number of commits fetched and
+// handed to storage
+func (p *RepositoryProcessor) process(ctx context.Context, owner, name string, since time.Time) (int, error) {
 	// Check context cancellation
 	if ctx.Err() != nil {
-		return fmt.Errorf("context cancelled: %w", ctx.Err())
+		return 0, fmt.Errorf("context cancelled: %w", ctx.Err())
+	}
+
+	parent := ctx
+	started := p.clock.Now()
+	if p.syncTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.syncTimeout)
+		defer cancel()
+	}
+	if p.fetched != nil && p.spool != nil {
+		return p.fetch(ctx, parent, owner, name, since)
 	}
 
 	// First, fetch and store repository information
@@ -67,11 +409,241 @@ func (p *RepositoryProcessor) Process(ctx context.Context, owner, name string, s
 
 	repo, err := p.client.FetchRepo(ctx, owner, name)
 	if err != nil {
-		return fmt.Errorf("failed to fetch repository %s/%s: %w", owner, name, err)
+		return 0, fmt.Errorf("failed to fetch repository %s/%s: %w", owner, name, err)
+	}
+	if since.IsZero() {
+		since = repo.CreatedAt
+	}
+
+	// The repository and its branch are stored together, so a switched
+	// branch never leaves the old branch's checkpoint behind
+	var (
+		storedRepo *models.Repository
+		branch     string
+	)
+	if err := p.inTx(ctx, func(ctx context.Context) error {
+		var err error
+		if storedRepo, err = p.storeRepository(ctx, owner, name, repo); err != nil {
+			return err
+		}
+		branch, err = p.trackDefaultBranch(ctx, storedRepo, repo.DefaultBranch)
+		return err
+	}); err != nil {
+		return 0, err
+	}
+	tenantID := tenant.FromContext(ctx)
+	p.syncLag.Observe(tenantID, storedRepo.ID, storedRepo.Owner, storedRepo.Name, repo.PushedAt)
+
+	// Pages spooled while the database was away are stored first, moving the
+	// checkpoint to where that sync stopped fetching
+	if err := p.flushSpool(ctx, owner, name, storedRepo, branch, true); err != nil {
+		return 0, err
+	}
+
+	// An interrupted sync resumes from its checkpoint rather than from the
+	// newest stored commit, which its first pages have already moved forward
+	startPage := 1
+	var walk *firstParentWalk
+	if p.firstParent {
+		walk = &firstParentWalk{}
+	}
+	checkpoint, err := p.db.GetSyncCheckpoint(ctx, storedRepo.ID)
+	switch {
+	case err == nil:
+		since, startPage = checkpoint.Since, checkpoint.NextPage
+		walk.resume(checkpoint.NextSHA)
+		logger.Info("Resuming sync from checkpoint",
+			zap.String("repo_owner", owner),
+			zap.String("repo_name", name),
+			zap.Time("since", since),
+			zap.Int("page", startPage))
+	case !errors.Is(err, db.ErrCheckpointNotFound):
+		return 0, fmt.Errorf("failed to load sync checkpoint for %s/%s: %w", owner, name, err)
+	}
+
+	// Fetch commits, storing and checkpointing every page as it arrives. A
+	// page's commits, file lists and checkpoint are written in one
+	// transaction, so the checkpoint never moves past a page that was not
+	// stored, nor lags behind one that was.
+	logger.Info("Fetching commits",
+		zap.String("repo_owner", owner),
+		zap.String("repo_name", name),
+		zap.String("branch", branch),
+		zap.Time("since", since))
+
+	cursor := "since=" + since.UTC().Format(time.RFC3339)
+	if branch != "" {
+		cursor += "&sha=" + branch
+	}
+	commitCount, spooled := 0, 0
+	var ingest *pageIngester
+	if p.writeAhead && p.spool != nil {
+		ingest = p.startIngest(ctx, owner, name, storedRepo)
+	}
+	err = p.client.FetchCommitPages(ctx, owner, name, branch, since, startPage, func(page int, commits []github.CommitResponse) error {
+		if walk != nil {
+			commits = walk.mainline(commits)
+		}
+		entry := spool.Entry{
+			TenantID: tenantID,
+			Owner:    storedRepo.Owner,
+			Name:     storedRepo.Name,
+			Branch:   branch,
+			Cursor:   cursor,
+			Since:    since,
+			Page:     page,
+			NextSHA:  walk.nextSHA(),
+		}
+		if ingest != nil {
+			return ingest.add(ctx, entry, commits)
+		}
+		// Once a page is spooled, so are the rest, so they are stored in
+		// order and the checkpoint never moves back
+		if spooled > 0 {
+			if _, err := p.spoolPage(entry, commits); err != nil {
+				return err
+			}
+			spooled++
+			return nil
+		}
+		err := p.storePage(ctx, owner, name, storedRepo, entry, commits)
+		if err == nil {
+			commitCount += len(commits)
+			return nil
+		}
+		if p.spool == nil || !db.IsUnavailable(err) {
+			return err
+		}
+		if _, spoolErr := p.spoolPage(entry, commits); spoolErr != nil {
+			return errors.Join(err, spoolErr)
+		}
+		spooled++
+		logger.Warn("Database unavailable, spooling fetched commit pages",
+			zap.Error(err),
+			zap.String("repo_owner", owner),
+			zap.String("repo_name", name),
+			zap.Int("page", page))
+		return nil
+	})
+	if ingest != nil {
+		var storeErr error
+		commitCount, spooled, storeErr = ingest.wait(ctx)
+		if err == nil {
+			err = storeErr
+		}
+	}
+	if spooled > 0 {
+		metrics.PagesSpooled.Add(metrics.RepoKey(owner, name), int64(spooled))
+		metrics.SpoolBytes.Set(p.spool.Size())
+		// The listing's own error, if any, still stands; the spooled pages
+		// are stored on the next sync either way
+		if err == nil {
+			return 0, fmt.Errorf("%w: %s/%s: %d commit pages spooled", ErrDatabaseUnavailable, owner, name, spooled)
+		}
+	}
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) && parent.Err() == nil {
+			logger.Warn("Sync deadline reached, resuming from checkpoint on the next sync",
+				zap.String("repo_owner", owner),
+				zap.String("repo_name", name),
+				zap.Duration("sync_timeout", p.syncTimeout),
+				zap.Int("commit_count", commitCount))
+			return 0, fmt.Errorf("%w: %s/%s after %d commits", ErrSyncDeadline, owner, name, commitCount)
+		}
+		// The checkpoint stays at the first page not stored, and the newest
+		// stored commit does not stand for the history before it
+		if errors.Is(err, github.ErrIncompleteListing) {
+			metrics.PartialSyncs.Add(metrics.RepoKey(owner, name), 1)
+			logger.Warn("Commit listing ended early, continuing from checkpoint on the next sync",
+				zap.Error(err),
+				zap.String("repo_owner", owner),
+				zap.String("repo_name", name),
+				zap.Int("commit_count", commitCount))
+			return 0, fmt.Errorf("%w: %s/%s after %d commits: %w", ErrPartialSync, owner, name, commitCount, err)
+		}
+		return 0, fmt.Errorf("failed to fetch commits for %s/%s: %w", owner, name, err)
+	}
+
+	if err := p.db.DeleteSyncCheckpoint(ctx, storedRepo.ID); err != nil {
+		return 0, err
+	}
+	p.syncLag.Synced(tenantID, storedRepo.Owner, storedRepo.Name, started)
+
+	if p.features.Enabled(features.Issues, owner, name) {
+		if err := p.syncIssues(ctx, owner, name, storedRepo.ID); err != nil {
+			return 0, err
+		}
+	}
+	if p.features.Enabled(features.PullRequests, owner, name) {
+		if err := p.syncPullRequests(ctx, owner, name, storedRepo.ID); err != nil {
+			return 0, err
+		}
+	}
+	if p.features.Enabled(features.Releases, owner, name) {
+		if err := p.syncReleases(ctx, owner, name, storedRepo.ID); err != nil {
+			return 0, err
+		}
+	}
+	if p.features.Enabled(features.Deployments, owner, name) {
+		if err := p.syncDeployments(ctx, owner, name, storedRepo.ID); err != nil {
+			return 0, err
+		}
+	}
+
+	if p.syncOwners {
+		p.syncOwner(ctx, storedRepo.Owner)
+	}
+
+	if walk != nil && walk.skipped() > 0 {
+		logger.Info("Skipped commits off the first-parent chain",
+			zap.String("repo_owner", owner),
+			zap.String("repo_name", name),
+			zap.Int("skipped", walk.skipped()))
+	}
+
+	if commitCount == 0 {
+		logger.Info("No new commits found",
+			zap.String("repo_owner", owner),
+			zap.String("repo_name", name))
+		return commitCount, nil
+	}
+
+	logger.Info("Successfully processed repository",
+		zap.String("repo_owner", owner),
+		zap.String("repo_name", name),
+		zap.Int("commit_count", commitCount))
+
+	return commitCount, nil
+}
+
+// storePage stores a page of commits fetched for the repository owner/name,
+// stored as repo, their file lists if repo has path filters, and the
+// checkpoint after the page in one transaction
+func (p *RepositoryProcessor) storePage(ctx context.Context, owner, name string, repo *models.Repository, entry spool.Entry, commits []github.CommitResponse) error {
+	return p.inTx(ctx, func(ctx context.Context) error {
+		if err := p.storeCommits(ctx, owner, name, repo.ID, entry.Cursor, entry.Page, commits); err != nil {
+			return err
+		}
+		if repo.HasPathFilters {
+			if err := p.storeCommitFiles(ctx, owner, name, repo.ID, commits); err != nil {
+				return err
+			}
+		}
+		return p.db.SaveSyncCheckpoint(ctx, models.SyncCheckpoint{RepoID: repo.ID, Since: entry.Since, NextPage: entry.Page + 1, NextSHA: entry.NextSHA})
+	})
+}
+
+// storeRepository converts a repository response to a model, stores it and
+// returns the stored row. GitHub answers requests for a renamed repository
+// with its current owner/name, which are stored instead of the requested ones.
+func (p *RepositoryProcessor) storeRepository(ctx context.Context, owner, name string, repo *github.RepoResponse) (*models.Repository, error) {
+	if repo.Owner.Login != "" && repo.Name != "" {
+		owner, name = repo.Owner.Login, repo.Name
 	}
 
-	// Convert to model and store
 	repoModel := models.Repository{
+		GitHubID:        repo.ID,
+		NodeID:          repo.NodeID,
 		Name:            name,
 		Owner:           owner,
 		Description:     repo.Description,
@@ -86,181 +658,799 @@ func (p *RepositoryProcessor) Process(ctx context.Context, owner, name string, s
 	}
 
 	if err := p.db.StoreRepository(ctx, repoModel); err != nil {
-		return fmt.Errorf("failed to store repository %s/%s: %w", owner, name, err)
+		return nil, fmt.Errorf("failed to store repository %s/%s: %w", owner, name, err)
+	}
+
+	// Get the stored repository to get its ID, by GitHub ID when known
+	var (
+		storedRepo *models.Repository
+		err        error
+	)
+	if repo.ID != 0 {
+		storedRepo, err = p.db.GetByGitHubID(ctx, repo.ID)
+	} else {
+		storedRepo, err = p.db.GetByName(ctx, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stored repository %s: %w", name, err)
 	}
+	return storedRepo, nil
+}
 
-	// Get the stored repository to get its ID
-	storedRepo, err := p.db.GetByName(ctx, name)
+// trackDefaultBranch records the default branch GitHub reports for a stored
+// repository and returns the branch to sync commits from. When the branch
+// changed since the last sync, e.g. from master to main, the change is logged
+// and announced and any checkpoint of the old branch is dropped, so the sync
+// continues on the new branch. An empty branch means GitHub did not report
+// one and the listing uses whatever its default is.
+func (p *RepositoryProcessor) trackDefaultBranch(ctx context.Context, repo *models.Repository, branch string) (string, error) {
+	if branch == "" {
+		return "", nil
+	}
+	previous, err := p.db.SetDefaultBranch(ctx, repo.ID, branch)
 	if err != nil {
-		return fmt.Errorf("failed to get stored repository %s: %w", name, err)
+		return "", fmt.Errorf("failed to record default branch of %s/%s: %w", repo.Owner, repo.Name, err)
+	}
+	if previous == "" || previous == branch {
+		return branch, nil
 	}
 
-	// Fetch commits
-	logger.Info("Fetching commits",
-		zap.String("repo_owner", owner),
-		zap.String("repo_name", name),
-		zap.Time("since", since))
+	logger.Warn("Repository default branch changed, syncing commits from the new branch",
+		zap.String("repo_owner", repo.Owner),
+		zap.String("repo_name", repo.Name),
+		zap.String("previous_branch", previous),
+		zap.String("branch", branch))
+	metrics.DefaultBranchChanges.Add(metrics.RepoKey(repo.Owner, repo.Name), 1)
 
-	commits, err := p.client.FetchCommits(ctx, owner, name, since)
+	// A checkpoint holds a page number into the old branch's listing
+	if err := p.db.DeleteSyncCheckpoint(ctx, repo.ID); err != nil {
+		return "", err
+	}
+	if p.notifier != nil {
+		afterCommit(ctx, func(ctx context.Context) {
+			p.notifier.NotifyDefaultBranch(ctx, webhook.EventRepository{ID: repo.ID, Owner: repo.Owner, Name: repo.Name},
+				webhook.BranchChange{Previous: previous, Current: branch})
+		})
+	}
+	return branch, nil
+}
+
+// storeCommitFiles fetches and stores the files touched by the stored
+// commits of a page that have no file list yet. It costs one request per
+// commit, so it only runs for repositories with path filters.
+func (p *RepositoryProcessor) storeCommitFiles(ctx context.Context, owner, name string, repoID int, commits []github.CommitResponse) error {
+	shas := make([]string, len(commits))
+	for i, c := range commits {
+		shas[i] = c.SHA
+	}
+	missing, err := p.db.CommitsWithoutFiles(ctx, repoID, shas)
 	if err != nil {
-		return fmt.Errorf("failed to fetch commits for %s/%s: %w", owner, name, err)
+		return err
 	}
 
-	if len(commits) == 0 {
-		logger.Info("No new commits found",
-			zap.String("repo_owner", owner),
-			zap.String("repo_name", name))
-		return nil
+	for _, sha := range missing {
+		files, err := p.client.FetchCommitFiles(ctx, owner, name, sha)
+		if err != nil {
+			return fmt.Errorf("failed to fetch files of commit %s in %s/%s: %w", sha, owner, name, err)
+		}
+		if err := p.db.StoreCommitFiles(ctx, repoID, sha, files); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// maxPooledCommits caps the commit model slices kept for reuse
+const maxPooledCommits = 50000
+
+// commitModelPool holds the commit model slices built for every fetch, which
+// are reused across polls
+var commitModelPool = sync.Pool{
+	New: func() interface{} { return new([]models.Commit) },
+}
+
+// ingestPageSize is the number of commits per ingested page, matching the
+// page size the GitHub client requests
+const ingestPageSize = 100
+
+// commitModel converts a commit response to the model stored for it
+func (p *RepositoryProcessor) commitModel(repoID int, commit github.CommitResponse) models.Commit {
+	commitModel := models.Commit{
+		SHA:           commit.SHA,
+		RepoID:        repoID,
+		Message:       p.anonymizer.Text(commit.Commit.Message),
+		AuthorName:    p.anonymizer.Name(commit.Commit.Author.Name),
+		Date:          commit.Commit.Author.Date,
+		CommitterDate: commit.Commit.Committer.Date,
+		URL:           models.CanonicalURL(commit.HTMLURL),
+		APIURL:        models.CanonicalURL(commit.URL),
+	}
+	// Payloads without a committer, such as hand-written fixtures, keep
+	// the author date as the closest approximation
+	if commitModel.CommitterDate.IsZero() {
+		commitModel.CommitterDate = commitModel.Date
+	}
+	if p.storeParents && len(commit.Parents) > 0 {
+		commitModel.Parents = make([]string, len(commit.Parents))
+		for i, parent := range commit.Parents {
+			commitModel.Parents[i] = parent.SHA
+		}
 	}
+	p.omit.Apply(&commitModel)
+	return commitModel
+}
+
+// storeCommits converts commit responses to models and ingests them page by
+// page, so pages already ingested by a previous attempt, a replay or another
+// instance are skipped rather than written again
+func (p *RepositoryProcessor) storeCommits(ctx context.Context, owner, name string, repoID int, cursor string, firstPage int, commits []github.CommitResponse) error {
+	pooled := commitModelPool.Get().(*[]models.Commit)
+	defer func() {
+		if cap(*pooled) > maxPooledCommits {
+			return
+		}
+		clear(*pooled)
+		*pooled = (*pooled)[:0]
+		commitModelPool.Put(pooled)
+	}()
 
-	// Convert commits to models
-	var commitModels []models.Commit
+	// Malformed commits are dropped here so they never reach the database
+	commitModels := (*pooled)[:0]
+	now := p.clock.Now()
 	for _, commit := range commits {
-		commitModel := models.Commit{
-			SHA:        commit.SHA,
-			RepoID:     storedRepo.ID,
-			Message:    commit.Commit.Message,
-			AuthorName: commit.Commit.Author.Name,
-			Date:       commit.Commit.Author.Date,
-			URL:        commit.HTMLURL,
+		commitModel := p.commitModel(repoID, commit)
+		if err := commitModel.Validate(now); err != nil {
+			var invalid *models.CommitValidationError
+			if errors.As(err, &invalid) {
+				metrics.CommitsInvalid.Add(invalid.Field, 1)
+			}
+			logger.Warn("Dropping invalid commit",
+				zap.String("repo_owner", owner),
+				zap.String("repo_name", name),
+				zap.String("sha", commit.SHA),
+				zap.Error(err))
+			continue
+		}
+		// Postgres rejects NUL bytes and invalid UTF-8 mid-batch, so they
+		// are cleaned up here rather than failing the page
+		sanitized, truncated := commitModel.Sanitize(p.maxMessageBytes)
+		if sanitized {
+			metrics.CommitsSanitized.Add(1)
+		}
+		if truncated {
+			metrics.CommitsTruncated.Add(1)
+		}
+		if sanitized || truncated {
+			logger.Info("Cleaned up commit message",
+				zap.String("repo_owner", owner),
+				zap.String("repo_name", name),
+				zap.String("sha", commit.SHA),
+				zap.Bool("sanitized", sanitized),
+				zap.Bool("truncated", truncated),
+				zap.Int("message_bytes", len(commit.Commit.Message)),
+				zap.String("message_hash", commitModel.MessageHash))
 		}
 		commitModels = append(commitModels, commitModel)
 	}
+	*pooled = commitModels
 
-	// Store commits in batches
 	logger.Info("Storing commits",
 		zap.String("repo_owner", owner),
 		zap.String("repo_name", name),
 		zap.Int("commit_count", len(commits)))
 
-	if err := p.db.BatchInsert(ctx, commitModels); err != nil {
-		return fmt.Errorf("failed to store commits for %s/%s: %w", owner, name, err)
-	}
+	skipped := 0
+	for i := 0; i < len(commitModels); i += ingestPageSize {
+		end := i + ingestPageSize
+		if end > len(commitModels) {
+			end = len(commitModels)
+		}
 
-	logger.Info("Successfully processed repository",
-		zap.String("repo_owner", owner),
-		zap.String("repo_name", name),
-		zap.Int("commit_count", len(commits)))
+		page := commitModels[i:end]
+
+		known, err := p.knownCommits(ctx, repoID, page)
+		if err != nil {
+			return fmt.Errorf("failed to store commits for %s/%s: %w", owner, name, err)
+		}
+
+		pageCursor := fmt.Sprintf("%s&page=%d", cursor, firstPage+i/ingestPageSize)
+		ingested, err := p.db.IngestCommitPage(ctx, repoID, pageCursor, page)
+		if err != nil {
+			return fmt.Errorf("failed to store commits for %s/%s: %w", owner, name, err)
+		}
+		if !ingested {
+			skipped++
+			continue
+		}
+		if p.notifier == nil && p.sink == nil {
+			continue
+		}
+		fresh := make([]models.Commit, 0, len(page))
+		for _, c := range page {
+			if !known[c.SHA] {
+				fresh = append(fresh, c)
+			}
+		}
+		if len(fresh) == 0 {
+			continue
+		}
+		afterCommit(ctx, func(ctx context.Context) {
+			if p.notifier != nil {
+				p.notifier.NotifyCommits(ctx, webhook.EventRepository{ID: repoID, Owner: owner, Name: name}, fresh)
+			}
+			if p.sink != nil {
+				p.sink.AddCommits(ctx, repoID, owner, name, fresh)
+			}
+		})
+	}
 
+	if skipped > 0 {
+		logger.Info("Skipped pages already ingested",
+			zap.String("repo_owner", owner),
+			zap.String("repo_name", name),
+			zap.Int("skipped_pages", skipped))
+	}
 	return nil
 }
 
+// knownCommits returns which commits of a page are already stored, so that
+// commits fetched again by an overlapping sync are not announced or copied to
+// the sink twice. Without a notifier or sink nothing is looked up.
+func (p *RepositoryProcessor) knownCommits(ctx context.Context, repoID int, page []models.Commit) (map[string]bool, error) {
+	if p.notifier == nil && p.sink == nil {
+		return nil, nil
+	}
+	shas := make([]string, len(page))
+	for i, c := range page {
+		shas[i] = c.SHA
+	}
+	return p.db.KnownCommitSHAs(ctx, repoID, shas)
+}
+
+// Replay re-processes stored raw payloads of a repository, in the order they
+// were fetched, through the same conversion and storage steps as Process.
+// It returns the number of payloads replayed.
+func (p *RepositoryProcessor) Replay(ctx context.Context, repo *models.Repository, payloads []models.RawPayload) (int, error) {
+	storedRepo := repo
+	for i, payload := range payloads {
+		if ctx.Err() != nil {
+			return i, fmt.Errorf("context cancelled: %w", ctx.Err())
+		}
+
+		switch payload.Kind {
+		case models.PayloadKindRepo:
+			decoded, err := github.DecodeRepo(payload.Payload)
+			if err != nil {
+				return i, fmt.Errorf("failed to replay payload %d: %w", payload.ID, err)
+			}
+			if storedRepo, err = p.storeRepository(ctx, repo.Owner, repo.Name, decoded); err != nil {
+				return i, err
+			}
+		case models.PayloadKindCommits:
+			commits, err := github.DecodeCommits(payload.Payload)
+			if err != nil {
+				return i, fmt.Errorf("failed to replay payload %d: %w", payload.ID, err)
+			}
+			if len(commits) == 0 {
+				continue
+			}
+			cursor := fmt.Sprintf("payload=%d", payload.ID)
+			if err := p.storeCommits(ctx, repo.Owner, repo.Name, storedRepo.ID, cursor, 1, commits); err != nil {
+				return i, err
+			}
+		default:
+			return i, fmt.Errorf("unknown payload kind %q for payload %d", payload.Kind, payload.ID)
+		}
+	}
+
+	logger.Info("Successfully replayed repository",
+		zap.String("repo_owner", repo.Owner),
+		zap.String("repo_name", repo.Name),
+		zap.Int("payload_count", len(payloads)))
+
+	return len(payloads), nil
+}
+
 // Service represents the main application service
 type Service struct {
-	config    *config.Config
-	database  DBInterface
-	client    GitHubClientInterface
-	processor *RepositoryProcessor
-	ctx       context.Context
-	cancel    context.CancelFunc
+	config     *config.Config
+	database   DBInterface
+	client     GitHubClientInterface
+	processor  *RepositoryProcessor
+	tenants    []models.Tenant
+	processors map[int]*RepositoryProcessor
+	progress   *progress.Tracker
+	webhooks   *webhook.Dispatcher
+	syncLag    *synclag.Tracker
+	failures   *scheduler.Failures
+	pacer      *syncPacer
+	commitSink *sink.Batcher
+	api        *api.Server
+	clock      clock.Clock
+	ctx        context.Context
+	cancel     context.CancelFunc
+
+	// tokens is the default tenant's GitHub client, whose token is rotated
+	// from GITHUB_TOKEN_FILE; nil when it cannot be rotated
+	tokens tokenSetter
+	// spool keeps the commit pages fetched while the database is away;
+	// nil without SPOOL_DIR
+	spool *spool.Spool
 }
 
 // NewService creates a new service instance
 func NewService() (*Service, error) {
-	// Load configuration
 	cfg := config.NewConfig()
-	if err := cfg.Load(); err != nil {
+	return newService(cfg, cfg.Load)
+}
+
+// NewOfflineService creates a service for commands that never call GitHub,
+// which then needs no GitHub token
+func NewOfflineService() (*Service, error) {
+	cfg := config.NewConfig()
+	return newService(cfg, cfg.LoadOffline)
+}
+
+func newService(cfg *config.Config, load func() error) (*Service, error) {
+	// Load configuration
+	if err := load(); err != nil {
 		return nil, fmt.Errorf("%w: failed to load configuration: %v", ErrServiceInit, err)
 	}
 
+	// Catch tokens GitHub is known to reject before the first request
+	if cfg.GitHubToken != "" {
+		kind, err := checkGitHubToken(cfg.GitHubToken, zap.String("setting", "GITHUB_TOKEN"))
+		if err != nil {
+			return nil, fmt.Errorf("%w: GITHUB_TOKEN: %v", ErrServiceInit, err)
+		}
+		logger.Info("Using GitHub token", zap.String("token_type", kind))
+	}
+
+	if keys := config.DeprecatedEnv(); len(keys) > 0 {
+		logger.Warn("Settings read from unprefixed environment variables, which are deprecated; add the prefix",
+			zap.Strings("keys", keys),
+			zap.String("prefix", config.EnvPrefix()+"_"))
+	}
+
 	// Initialize database
 	database, err := db.New()
 	if err != nil {
 		return nil, fmt.Errorf("%w: failed to initialize database: %v", ErrServiceInit, err)
 	}
 
+	// Refuse to run against a schema missing columns this build uses
+	if err := database.CheckSchema(context.Background()); err != nil {
+		database.Close()
+		return nil, fmt.Errorf("%w: %v", ErrServiceInit, err)
+	}
+
+	// Protect secrets stored in the database
+	if cfg.EncryptionKey != "" {
+		wrapper, err := secrets.NewStaticKeyWrapper(cfg.EncryptionKey)
+		if err != nil {
+			database.Close()
+			return nil, fmt.Errorf("%w: invalid ENCRYPTION_KEY: %v", ErrServiceInit, err)
+		}
+		database.SetEncryptor(secrets.NewEnvelopeEncryptor(wrapper))
+	}
+
+	database.SetIsolateFailedCommits(cfg.IsolateFailedCommits)
+	database.SetCompressMessages(cfg.CompressMessages)
+	database.SetTxBackoff(backoff.New(cfg.RetryBackoff, db.TxRetryDelay, 0))
+
 	// Initialize GitHub client
-	client := github.NewClient(cfg.GitHubToken)
+	tracker := progress.NewTracker(progress.DefaultLogInterval)
+	client := newGitHubClient(cfg, database, tracker, cfg.GitHubToken)
 
 	// Create context with cancellation
 	ctx, cancel := context.WithCancel(context.Background())
 
 	// Create repository processor
-	processor := NewRepositoryProcessor(database, client)
+	// Push ingested commits to webhook consumers, if any are configured
+	var webhooks *webhook.Dispatcher
+	if len(cfg.WebhookURLs) > 0 {
+		webhooks = webhook.NewDispatcher(database, webhook.Options{
+			URLs:        cfg.WebhookURLs,
+			Secret:      cfg.WebhookSecret,
+			MaxAttempts: cfg.WebhookMaxAttempts,
+			Backoff:     backoff.New(cfg.RetryBackoff, webhookRetryDelay, webhookMaxRetryDelay),
+		})
+	}
+	syncLag := synclag.NewTracker(synclag.Options{
+		SLO:      time.Duration(cfg.SyncLagSLO) * time.Second,
+		HalfLife: time.Duration(cfg.SyncLagHalfLife) * time.Second,
+		Alert:    syncLagAlert(webhooks),
+	})
+	failures := scheduler.NewFailures()
+	commitSink := newCommitSink(cfg)
+	var pageSpool *spool.Spool
+	if cfg.SpoolDir != "" {
+		if pageSpool, err = spool.Open(cfg.SpoolDir, cfg.SpoolMaxBytes); err != nil {
+			cancel()
+			database.Close()
+			return nil, fmt.Errorf("%w: %v", ErrServiceInit, err)
+		}
+		metrics.SpoolBytes.Set(pageSpool.Size())
+	}
+	processorOpts := processorOptions(cfg, webhooks, syncLag, commitSink, pageSpool)
+	processor := NewRepositoryProcessor(database, client, processorOpts...)
+
+	// Create a processor per tenant, each with its own GitHub token
+	tenants, err := database.ListTenants(ctx)
+	if err != nil {
+		cancel()
+		database.Close()
+		return nil, fmt.Errorf("%w: failed to load tenants: %v", ErrServiceInit, err)
+	}
+	processors := map[int]*RepositoryProcessor{tenant.DefaultID: processor}
+	for _, t := range tenants {
+		if t.ID == tenant.DefaultID || t.GitHubToken == "" {
+			continue
+		}
+		if _, err := checkGitHubToken(t.GitHubToken, zap.String("tenant", t.Name)); err != nil {
+			cancel()
+			database.Close()
+			return nil, fmt.Errorf("%w: GitHub token of tenant %s: %v", ErrServiceInit, t.Name, err)
+		}
+		processors[t.ID] = NewRepositoryProcessor(database, newGitHubClient(cfg, database, tracker, t.GitHubToken), processorOpts...)
+	}
 
 	logger.Info("Service initialized successfully",
 		zap.String("repo_owner", cfg.RepoOwner),
 		zap.String("repo_name", cfg.RepoName),
-		zap.Int("poll_interval", cfg.PollInterval))
-
-	return &Service{
-		config:    cfg,
-		database:  database,
-		client:    client,
-		processor: processor,
-		ctx:       ctx,
-		cancel:    cancel,
-	}, nil
+		zap.Int("poll_interval", cfg.PollInterval),
+		zap.String("run_mode", cfg.RunMode),
+		zap.Int("tenants", len(tenants)))
+	for _, f := range cfg.Features.Snapshot() {
+		logger.Info("Optional feature",
+			zap.String("feature", f.Name),
+			zap.Bool("enabled", f.Enabled),
+			zap.Strings("enabled_for", f.EnabledFor),
+			zap.Strings("disabled_for", f.DisabledFor))
+	}
+
+	svc := &Service{
+		config:     cfg,
+		database:   database,
+		client:     client,
+		tokens:     client,
+		processor:  processor,
+		tenants:    tenants,
+		processors: processors,
+		progress:   tracker,
+		webhooks:   webhooks,
+		syncLag:    syncLag,
+		failures:   failures,
+		pacer:      newSyncPacer(cfg.SyncConcurrency, time.Duration(cfg.SyncSpacingMS)*time.Millisecond, clock.Real),
+		commitSink: commitSink,
+		clock:      clock.Real,
+		ctx:        ctx,
+		cancel:     cancel,
+		spool:      pageSpool,
+	}
+
+	// Create the query API server if an address is configured
+	if cfg.APIAddr != "" {
+		svc.api = api.NewServer(database, api.Options{
+			Addr:            cfg.APIAddr,
+			RateLimit:       cfg.APIRateLimit,
+			RateBurst:       cfg.APIRateBurst,
+			MaxPageSize:     cfg.APIMaxPageSize,
+			MaxResultWindow: cfg.APIMaxResultWindow,
+			APIToken:        cfg.APIToken,
+			AllowAnonymous:  cfg.APIAllowAnonymous,
+			Calendar:        &cfg.WorkCalendar,
+			Progress:        tracker,
+			SyncLag:         syncLag,
+			Failures:        failures,
+			Features:        cfg.Features,
+			Syncer:          svc,
+			Resetter:        svc,
+		})
+	}
+	return svc, nil
 }
 
-// Start initializes and starts the service
+// Start initializes the service and runs its components until an interrupt
+// or SIGTERM, or until a component that cannot be restarted fails, whose
+// error it returns
 func (s *Service) Start() error {
-	// Process initial repository
-	if err := s.processInitialRepository(); err != nil {
-		logger.Warn("Error processing initial repository",
-			zap.Error(err),
-			zap.String("repo_owner", s.config.RepoOwner),
-			zap.String("repo_name", s.config.RepoName))
-		// Continue despite initial processing error
+	// An ingest-only process leaves GitHub to the fetch-only one
+	if s.config.RunMode != config.RunModeIngestOnly {
+		s.checkAPIVersion()
+		if !s.config.SkipPreflight {
+			s.preflight()
+		}
 	}
 
-	// Start repository monitoring
-	s.startMonitoring()
+	ctx, stop := signal.NotifyContext(s.ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err := s.supervisor().Run(ctx)
+	if err == nil {
+		logger.Info("Shutdown signal received, service stopped")
+	}
+	s.cancel()
+	return err
+}
 
-	// Wait for interrupt signal
-	s.waitForShutdown()
+// Base delay and cap of the backoff between restarts of a failed component
+const (
+	componentRestartDelay    = time.Second
+	componentMaxRestartDelay = 5 * time.Minute
+)
 
-	return nil
+// Base delay and cap of the backoff between attempts of a failed sink batch
+const (
+	sinkRetryDelay    = time.Second
+	sinkMaxRetryDelay = time.Minute
+)
+
+// Base delay and cap of the backoff between attempts of a failed webhook
+// delivery
+const (
+	webhookRetryDelay    = time.Second
+	webhookMaxRetryDelay = time.Minute
+)
+
+// supervisor returns the supervisor of the service's components, added in
+// the order they stop last to first: the query API stops taking requests,
+// then monitoring stops with its running syncs, and only then the
+// components delivering what those syncs produced. Everything but the query
+// API, which fails when it cannot listen, is restarted after failures. An
+// ingest-only process stores spooled pages instead of monitoring.
+func (s *Service) supervisor() *lifecycle.Supervisor {
+	sup := lifecycle.New(lifecycle.Options{
+		Backoff: backoff.New(s.config.RetryBackoff, componentRestartDelay, componentMaxRestartDelay),
+		Clock:   s.clock,
+	})
+	if s.commitSink != nil {
+		sup.Add(lifecycle.Component{Name: "sink", Restart: true, Run: func(ctx context.Context) error {
+			s.commitSink.Run(ctx)
+			return nil
+		}})
+	}
+	if s.webhooks != nil {
+		sup.Add(lifecycle.Component{Name: "webhooks", Restart: true, Run: func(ctx context.Context) error {
+			s.webhooks.Run(ctx)
+			return nil
+		}})
+	}
+	sup.Add(lifecycle.Component{Name: "sync-lag", Restart: true, Run: func(ctx context.Context) error {
+		s.syncLag.Run(ctx, synclag.DefaultSampleInterval)
+		return nil
+	}})
+	if s.config.GitHubTokenFile != "" && s.tokens != nil {
+		sup.Add(lifecycle.Component{Name: "github-token", Restart: true, Run: func(ctx context.Context) error {
+			s.watchTokenFile(ctx, s.config.GitHubTokenFile, time.Duration(s.config.TokenReloadInterval)*time.Second, s.tokens)
+			return nil
+		}})
+	}
+
+	if s.config.RunMode == config.RunModeIngestOnly {
+		if s.spool != nil {
+			sup.Add(lifecycle.Component{Name: "ingest", Restart: true, Run: func(ctx context.Context) error {
+				s.ingestLoop(ctx, time.Duration(s.config.IngestInterval)*time.Second)
+				return nil
+			}})
+		}
+	} else {
+		s.addMonitoring(sup)
+	}
+
+	if s.api != nil {
+		sup.Add(lifecycle.Component{
+			Name: "api",
+			Run:  func(context.Context) error { return s.api.Start() },
+			Stop: s.api.Shutdown,
+		})
+	}
+	return sup
+}
+
+// addMonitoring adds the monitoring, reconciliation and drift checks of
+// every tenant to sup
+func (s *Service) addMonitoring(sup *lifecycle.Supervisor) {
+	for _, t := range s.tenants {
+		sup.Add(lifecycle.Component{Name: "monitor/" + t.Name, Restart: true, Run: s.monitor(t)})
+		sup.Add(lifecycle.Component{Name: "reconcile/" + t.Name, Restart: true, Run: func(ctx context.Context) error {
+			s.reconcileLoop(tenant.WithID(ctx, t.ID), time.Duration(s.config.ReconcileInterval)*time.Second)
+			return nil
+		}})
+		if s.config.DiscoverStarredInterval > 0 {
+			sup.Add(lifecycle.Component{Name: "discover/" + t.Name, Restart: true, Run: func(ctx context.Context) error {
+				s.discoverLoop(tenant.WithID(ctx, t.ID), time.Duration(s.config.DiscoverStarredInterval)*time.Second)
+				return nil
+			}})
+		}
+		if s.config.DriftCheckInterval > 0 {
+			sup.Add(lifecycle.Component{Name: "drift/" + t.Name, Restart: true, Run: func(ctx context.Context) error {
+				s.driftLoop(tenant.WithID(ctx, t.ID), time.Duration(s.config.DriftCheckInterval)*time.Second)
+				return nil
+			}})
+		}
+	}
+}
+
+// processorOptions returns the options processors are created with
+func processorOptions(cfg *config.Config, webhooks *webhook.Dispatcher, syncLag *synclag.Tracker, commitSink *sink.Batcher, pageSpool *spool.Spool) []ProcessorOption {
+	opts := []ProcessorOption{
+		WithSyncTimeout(time.Duration(cfg.SyncTimeout) * time.Second),
+		WithMaxMessageBytes(cfg.MaxMessageBytes),
+		WithSyncLag(syncLag),
+		WithCommitParents(cfg.IngestCommitParents),
+		WithFirstParent(cfg.SyncFirstParent),
+		WithFeatures(cfg.Features),
+		WithOmitFields(cfg.OmitFields),
+		WithOwners(cfg.SyncOwners),
+	}
+	if cfg.AnonymizeAuthors {
+		opts = append(opts, WithAnonymizer(anonymize.New(cfg.AnonymizeSalt)))
+	}
+	if webhooks != nil {
+		opts = append(opts, WithNotifier(webhooks))
+	}
+	if commitSink != nil {
+		opts = append(opts, WithSink(commitSink))
+	}
+	if pageSpool != nil {
+		opts = append(opts, WithSpool(pageSpool), WithWriteAhead(cfg.SpoolWriteAhead), WithFetchOnly(cfg.RunMode == config.RunModeFetchOnly))
+	}
+	return opts
+}
+
+// newCommitSink returns the batcher copying ingested commits to the
+// configured analytical store, or nil when none is configured
+func newCommitSink(cfg *config.Config) *sink.Batcher {
+	var w sink.Writer
+	switch cfg.SinkType {
+	case sink.TypeClickHouse:
+		w = sink.NewClickHouse(sink.ClickHouseOptions{
+			URL:      cfg.ClickHouseURL,
+			Table:    cfg.ClickHouseTable,
+			User:     cfg.ClickHouseUser,
+			Password: cfg.ClickHousePassword,
+		})
+	case sink.TypeBigQuery:
+		w = sink.NewBigQuery(sink.BigQueryOptions{
+			Project: cfg.BigQueryProject,
+			Dataset: cfg.BigQueryDataset,
+			Table:   cfg.BigQueryTable,
+		})
+	default:
+		return nil
+	}
+	return sink.NewBatcher(w, sink.Options{
+		BatchSize:     cfg.SinkBatchSize,
+		FlushInterval: time.Duration(cfg.SinkFlushInterval) * time.Second,
+		Backoff:       backoff.New(cfg.RetryBackoff, sinkRetryDelay, sinkMaxRetryDelay),
+	})
+}
+
+// checkAPIVersion warns when GitHub rejects or deprecates the configured API
+// version, before it changes behavior under us
+func (s *Service) checkAPIVersion() {
+	version, err := s.client.CheckAPIVersion(s.ctx)
+	if err != nil {
+		logger.Warn("Failed to verify GitHub API version",
+			zap.Error(err),
+			zap.String("api_version", s.config.GitHubAPIVersion))
+		return
+	}
+	if version.Deprecated {
+		logger.Warn("Configured GitHub API version is deprecated",
+			zap.String("api_version", version.Requested),
+			zap.String("sunset", version.Sunset))
+		return
+	}
+	logger.Info("GitHub API version verified",
+		zap.String("api_version", version.Requested),
+		zap.String("selected", version.Selected))
 }
 
 // processInitialRepository processes the initial repository state
-func (s *Service) processInitialRepository() error {
+func (s *Service) processInitialRepository(ctx context.Context) error {
 	logger.Info("Processing initial repository",
 		zap.String("repo_owner", s.config.RepoOwner),
 		zap.String("repo_name", s.config.RepoName),
 		zap.Time("start_date", s.config.StartDate))
 
 	// Check if context is already cancelled
-	if s.ctx.Err() != nil {
-		return fmt.Errorf("service context cancelled: %w", s.ctx.Err())
+	if ctx.Err() != nil {
+		return fmt.Errorf("service context cancelled: %w", ctx.Err())
 	}
 
-	return s.processor.Process(s.ctx, s.config.RepoOwner, s.config.RepoName, s.config.StartDate)
+	return s.processor.Process(ctx, s.config.RepoOwner, s.config.RepoName, s.config.StartDate)
 }
 
-// startMonitoring starts the repository monitoring process
-func (s *Service) startMonitoring() {
-	logger.Info("Starting repository monitoring",
-		zap.Int("poll_interval", s.config.PollInterval))
+// monitor returns the run function of a tenant's repository monitoring. The
+// default tenant first processes the configured repository, once, so that
+// monitoring never syncs it at the same time.
+func (s *Service) monitor(t models.Tenant) func(ctx context.Context) error {
+	initialized := t.ID != tenant.DefaultID
+	return func(ctx context.Context) error {
+		if !initialized {
+			if err := s.processInitialRepository(ctx); err != nil {
+				logger.Warn("Error processing initial repository",
+					zap.Error(err),
+					zap.String("repo_owner", s.config.RepoOwner),
+					zap.String("repo_name", s.config.RepoName))
+				// Continue despite initial processing error
+			}
+			initialized = true
+		}
+
+		pollInterval := s.pollInterval(t)
+		processor := s.processorFor(t.ID)
+		ctx = tenant.WithID(ctx, t.ID)
+		logger.Info("Starting repository monitoring",
+			zap.String("tenant", t.Name),
+			zap.Int("poll_interval", pollInterval))
 
-	s.database.MonitorRepositoryChanges(
-		s.ctx,
-		time.Duration(s.config.PollInterval)*time.Second,
-		func(repoName string, latestDate time.Time) error {
+		// A fetch-only process goes on fetching while the database is away
+		var store scheduler.Store = s.database
+		if processor.fetched != nil {
+			store = newFetchStore(s.database, processor.fetched)
+		}
+		scheduler.New(store, func(ctx context.Context, repo models.Repository, latestDate time.Time) error {
 			// Check if context is already cancelled
-			if s.ctx.Err() != nil {
-				return fmt.Errorf("service context cancelled: %w", s.ctx.Err())
+			if ctx.Err() != nil {
+				return fmt.Errorf("service context cancelled: %w", ctx.Err())
 			}
 
-			return s.processor.Process(s.ctx, s.config.RepoOwner, repoName, latestDate)
-		},
-	)
+			release, err := s.pacer.acquire(ctx)
+			if err != nil {
+				return fmt.Errorf("service context cancelled: %w", err)
+			}
+			defer release()
+
+			// Repositories carry their own owner, which reconciliation keeps
+			// current across transfers
+			return s.syncRepository(ctx, processor, repo, s.overlapSince(latestDate))
+		}, scheduler.Options{
+			Interval:     time.Duration(pollInterval) * time.Second,
+			DefaultStart: s.config.StartDate,
+			Failures:     s.failures,
+			Clock:        s.clock,
+		}).Run(ctx)
+		return nil
+	}
 }
 
-// waitForShutdown waits for the shutdown signal
-func (s *Service) waitForShutdown() {
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-	<-sigChan
+// pollInterval returns the poll interval in seconds for a tenant. The default
+// tenant is driven by the service configuration.
+func (s *Service) pollInterval(t models.Tenant) int {
+	if t.ID == tenant.DefaultID {
+		return s.config.PollInterval
+	}
+	return t.PollInterval
+}
 
-	logger.Info("Shutdown signal received, initiating graceful shutdown")
-	s.cancel()
+// processorFor returns the processor using the given tenant's GitHub token
+func (s *Service) processorFor(tenantID int) *RepositoryProcessor {
+	if p, ok := s.processors[tenantID]; ok {
+		return p
+	}
+	return s.processor
 }
 
 // Close performs cleanup operations
 func (s *Service) Close() error {
 	logger.Info("Closing service")
 	s.cancel()
+	// Commits ingested since the last batch, also by CLI commands that never
+	// start the background writer
+	if s.commitSink != nil {
+		flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		s.commitSink.Flush(flushCtx)
+		cancel()
+	}
+	if s.api != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := s.api.Shutdown(shutdownCtx); err != nil {
+			logger.Warn("Failed to stop query API", zap.Error(err))
+		}
+	}
 	if err := s.database.Close(); err != nil {
 		return fmt.Errorf("%w: failed to close database: %v", ErrServiceShutdown, err)
 	}
@@ -268,22 +1458,126 @@ func (s *Service) Close() error {
 }
 
 // ResetSyncPoint resets the sync point for a repository to a specific date.
-// This will trigger a new fetch of commits from the specified date.
-func (s *Service) ResetSyncPoint(ctx context.Context, repoName string, newDate time.Time) error {
+// This will trigger a new fetch of commits from the specified date, and
+// reports how many commits it fetched and wrote.
+func (s *Service) ResetSyncPoint(ctx context.Context, repoName string, newDate time.Time) (*models.ResetSyncResult, error) {
 	if repoName == "" {
-		return fmt.Errorf("repository name cannot be empty")
+		return nil, fmt.Errorf("repository name cannot be empty")
+	}
+
+	result, err := s.resetSyncPoint(ctx, repoName, newDate)
+	params := map[string]interface{}{
+		"repo":     repoName,
+		"new_date": newDate,
 	}
+	if result != nil {
+		params["commits_fetched"] = result.CommitsFetched
+		params["commits_inserted"] = result.CommitsInserted
+		params["commits_updated"] = result.CommitsUpdated
+	}
+	s.recordAudit(ctx, audit.ActionResetSync, params, err)
+	return result, err
+}
 
+// resetSyncPoint reprocesses a repository from the given date
+func (s *Service) resetSyncPoint(ctx context.Context, repoName string, newDate time.Time) (*models.ResetSyncResult, error) {
 	// Get the repository to find its owner
 	repo, err := s.database.GetByName(ctx, repoName)
 	if err != nil {
-		return fmt.Errorf("failed to get repository: %w", err)
+		return nil, fmt.Errorf("failed to get repository: %w", err)
+	}
+
+	// A checkpoint would resume the interrupted sync instead of the new date
+	if err := s.database.DeleteSyncCheckpoint(ctx, repo.ID); err != nil {
+		return nil, fmt.Errorf("failed to clear sync checkpoint: %w", err)
+	}
+
+	// Writes are counted by the database's clock, which stamps them
+	processor := s.processorFor(tenant.FromContext(ctx))
+	started := processor.clock.Now()
+	writesSince, err := s.database.Now(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	// Process the repository with the new date
-	if err := s.processor.Process(ctx, repo.Owner, repo.Name, newDate); err != nil {
-		return fmt.Errorf("failed to process repository with new sync point: %w", err)
+	fetched, err := processor.process(ctx, repo.Owner, repo.Name, newDate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to process repository with new sync point: %w", err)
 	}
 
-	return nil
+	inserted, updated, err := s.database.CountCommitWrites(ctx, repo.ID, writesSince)
+	if err != nil {
+		return nil, err
+	}
+	return &models.ResetSyncResult{
+		Owner:           repo.Owner,
+		Name:            repo.Name,
+		Since:           newDate,
+		CommitsFetched:  fetched,
+		CommitsInserted: inserted,
+		CommitsUpdated:  updated,
+		DurationMS:      processor.clock.Now().Sub(started).Milliseconds(),
+	}, nil
+}
+
+// TenantContext returns ctx scoped to the named tenant. An empty name selects
+// the default tenant.
+func (s *Service) TenantContext(ctx context.Context, name string) (context.Context, error) {
+	if name == "" || name == tenant.DefaultName {
+		return tenant.WithID(ctx, tenant.DefaultID), nil
+	}
+
+	t, err := s.database.GetTenantByName(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve tenant: %w", err)
+	}
+	return tenant.WithID(ctx, t.ID), nil
+}
+
+// AddTenant registers a new tenant and performs an initial sync of the given
+// repositories under the tenant's owner.
+func (s *Service) AddTenant(ctx context.Context, t models.Tenant, repoNames []string) (int, error) {
+	if t.GitHubToken == "" {
+		return 0, fmt.Errorf("tenant GitHub token cannot be empty")
+	}
+	if _, err := checkGitHubToken(t.GitHubToken, zap.String("tenant", t.Name)); err != nil {
+		return 0, fmt.Errorf("tenant GitHub token: %w", err)
+	}
+
+	id, err := s.database.CreateTenant(ctx, t)
+	s.recordAudit(ctx, audit.ActionAddTenant, map[string]interface{}{
+		"tenant":        t.Name,
+		"owner":         t.RepoOwner,
+		"poll_interval": t.PollInterval,
+		"repos":         repoNames,
+	}, err)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create tenant: %w", err)
+	}
+
+	processor := NewRepositoryProcessor(s.database, newGitHubClient(s.config, s.database, s.progress, t.GitHubToken), processorOptions(s.config, s.webhooks, s.syncLag, s.commitSink, s.spool)...)
+	s.processors[id] = processor
+
+	tenantCtx := tenant.WithID(ctx, id)
+	for _, name := range repoNames {
+		if err := processor.Process(tenantCtx, t.RepoOwner, name, s.config.StartDate); err != nil {
+			return id, fmt.Errorf("failed to sync repository %s/%s for tenant %s: %w", t.RepoOwner, name, t.Name, err)
+		}
+	}
+
+	return id, nil
+}
+
+// EncryptStoredSecrets encrypts secrets that were stored before an encryption
+// key was configured
+func (s *Service) EncryptStoredSecrets(ctx context.Context) (int, error) {
+	count, err := s.database.EncryptTenantTokens(ctx)
+	s.recordAudit(ctx, audit.ActionEncryptToken, map[string]interface{}{"count": count}, err)
+	return count, err
+}
+
+// ListTenants returns all registered tenants
+func (s *Service) ListTenants(ctx context.Context) ([]models.Tenant, error) {
+	return s.database.ListTenants(ctx)
 }
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"githubapifetch/db"
+	"githubapifetch/github"
+	"githubapifetch/logger"
+	"githubapifetch/metrics"
+	"githubapifetch/models"
+	"githubapifetch/spool"
+	"githubapifetch/tenant"
+)
+
+// writeAheadPages is how many spooled pages a write-ahead sync fetches ahead
+// of the pages it has stored
+const writeAheadPages = 16
+
+// spoolPage writes a page of commits to the spool as entry and returns the
+// entry as spooled
+func (p *RepositoryProcessor) spoolPage(entry spool.Entry, commits []github.CommitResponse) (spool.Entry, error) {
+	data, err := json.Marshal(commits)
+	if err != nil {
+		return entry, fmt.Errorf("failed to encode commits to spool: %w", err)
+	}
+	entry.Commits = data
+	if entry.Seq, err = p.spool.Append(entry); err != nil {
+		return entry, fmt.Errorf("failed to spool commits of %s/%s: %w", entry.Owner, entry.Name, err)
+	}
+	return entry, nil
+}
+
+// keepSpooled reports whether spooled pages that failed to store with err
+// stay spooled for the next sync: the database was unavailable or the sync
+// was cut short. Pages that failed any other way are dropped and fetched
+// again from the checkpoint, so a page that cannot be stored does not block
+// the repository's syncs.
+func keepSpooled(err error) bool {
+	return db.IsUnavailable(err) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// flushSpool stores the pages spooled for repo, oldest first, removing each
+// once stored; the end of a listing clears the checkpoint. Pages spooled from
+// another branch than the one now synced are dropped, as their checkpoint
+// went with the branch switch. With dropFailed, a page that fails to store
+// for a reason keepSpooled does not excuse is dropped with the pages after
+// it, for the sync to fetch them again from the checkpoint.
+func (p *RepositoryProcessor) flushSpool(ctx context.Context, owner, name string, repo *models.Repository, branch string, dropFailed bool) error {
+	if p.spool == nil {
+		return nil
+	}
+	entries, err := p.spool.Pending(tenant.FromContext(ctx), repo.Owner, repo.Name)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	stored := 0
+	for i, entry := range entries {
+		if entry.Branch == branch {
+			if err := p.storeSpooled(ctx, owner, name, repo, entry); err != nil {
+				if dropFailed && !keepSpooled(err) {
+					p.dropSpooled(entries[i:])
+				}
+				return fmt.Errorf("failed to store spooled commits of %s/%s: %w", repo.Owner, repo.Name, err)
+			}
+			stored++
+		}
+		if err := p.spool.Remove(entry); err != nil {
+			return err
+		}
+	}
+	metrics.SpoolBytes.Set(p.spool.Size())
+	logger.Info("Stored spooled commit pages",
+		zap.String("repo_owner", repo.Owner),
+		zap.String("repo_name", repo.Name),
+		zap.Int("pages", stored),
+		zap.Int("dropped", len(entries)-stored))
+	return nil
+}
+
+// storeSpooled stores a spooled page, or clears the checkpoint at the end of
+// a listing
+func (p *RepositoryProcessor) storeSpooled(ctx context.Context, owner, name string, repo *models.Repository, entry spool.Entry) error {
+	if entry.Done {
+		return p.db.DeleteSyncCheckpoint(ctx, repo.ID)
+	}
+	var commits []github.CommitResponse
+	if err := json.Unmarshal(entry.Commits, &commits); err != nil {
+		return err
+	}
+	return p.storePage(ctx, owner, name, repo, entry, commits)
+}
+
+// dropSpooled removes entries from the spool without storing them
+func (p *RepositoryProcessor) dropSpooled(entries []spool.Entry) {
+	for _, entry := range entries {
+		if err := p.spool.Remove(entry); err != nil {
+			logger.Error("Failed to drop spooled commit page", zap.Error(err), zap.Int("page", entry.Page))
+		}
+	}
+	metrics.SpoolBytes.Set(p.spool.Size())
+}
+
+// spooledPage is a page of commits written to the spool, waiting to be
+// stored
+type spooledPage struct {
+	entry   spool.Entry
+	commits []github.CommitResponse
+}
+
+// pageIngester stores the pages a write-ahead sync spools, in order, while
+// the sync goes on fetching
+type pageIngester struct {
+	p           *RepositoryProcessor
+	owner, name string
+	repo        *models.Repository
+	queue       chan spooledPage
+	done        chan struct{}
+
+	mu     sync.Mutex
+	err    error
+	stored int
+}
+
+// startIngest starts storing the pages a write-ahead sync of repo spools
+func (p *RepositoryProcessor) startIngest(ctx context.Context, owner, name string, repo *models.Repository) *pageIngester {
+	in := &pageIngester{
+		p:     p,
+		owner: owner,
+		name:  name,
+		repo:  repo,
+		queue: make(chan spooledPage, writeAheadPages),
+		done:  make(chan struct{}),
+	}
+	go in.run(ctx)
+	return in
+}
+
+// run stores queued pages until the queue is closed. After the first page
+// that fails to store, the rest are left in the spool.
+func (in *pageIngester) run(ctx context.Context) {
+	defer close(in.done)
+	for page := range in.queue {
+		if in.failed() != nil {
+			continue
+		}
+		if err := in.p.storePage(ctx, in.owner, in.name, in.repo, page.entry, page.commits); err != nil {
+			if db.IsUnavailable(err) {
+				logger.Warn("Database unavailable, spooling fetched commit pages",
+					zap.Error(err),
+					zap.String("repo_owner", in.owner),
+					zap.String("repo_name", in.name),
+					zap.Int("page", page.entry.Page))
+			}
+			in.mu.Lock()
+			in.err = err
+			in.mu.Unlock()
+			continue
+		}
+		if err := in.p.spool.Remove(page.entry); err != nil {
+			logger.Error("Failed to remove stored commit page from spool", zap.Error(err), zap.Int("page", page.entry.Page))
+		}
+		in.mu.Lock()
+		in.stored += len(page.commits)
+		in.mu.Unlock()
+	}
+}
+
+// failed returns the error the first page failed to store with, if any
+func (in *pageIngester) failed() error {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return in.err
+}
+
+// add spools a fetched page and queues it to be stored, waiting while the
+// queue is full. Once the database has become unavailable pages are only
+// spooled; any other failure to store a page stops the fetch.
+func (in *pageIngester) add(ctx context.Context, entry spool.Entry, commits []github.CommitResponse) error {
+	entry, err := in.p.spoolPage(entry, commits)
+	if err != nil {
+		return err
+	}
+	if err := in.failed(); err != nil {
+		if db.IsUnavailable(err) {
+			return nil
+		}
+		return err
+	}
+	select {
+	case in.queue <- spooledPage{entry: entry, commits: commits}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// wait stores the pages still queued and returns the number of commits
+// stored, and the number of pages left spooled because the database became
+// unavailable. Any other failure is returned, and the pages after it are
+// dropped from the spool unless the sync was cut short.
+func (in *pageIngester) wait(ctx context.Context) (int, int, error) {
+	close(in.queue)
+	<-in.done
+
+	err := in.failed()
+	if err == nil {
+		metrics.SpoolBytes.Set(in.p.spool.Size())
+		return in.stored, 0, nil
+	}
+	left, pendingErr := in.p.spool.Pending(tenant.FromContext(ctx), in.repo.Owner, in.repo.Name)
+	if pendingErr != nil {
+		return in.stored, 0, errors.Join(err, pendingErr)
+	}
+	switch {
+	case db.IsUnavailable(err):
+		return in.stored, len(left), nil
+	case !keepSpooled(err):
+		in.p.dropSpooled(left)
+	}
+	return in.stored, 0, err
+}
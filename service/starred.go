@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"githubapifetch/logger"
+	"githubapifetch/models"
+	"githubapifetch/tenant"
+)
+
+// StarredReport summarizes a discovery of starred repositories
+type StarredReport struct {
+	Starred  int // Repositories starred on GitHub
+	Added    int // Repositories newly registered
+	Existing int // Repositories already stored, left untouched
+}
+
+// DiscoverStarred registers the repositories starred by the user the
+// context's tenant's token authenticates, from the configured start date
+// like imported ones. Repositories already stored are left as they are,
+// whatever their status, so a removed repository stays removed, and
+// unstarring a repository does not stop its monitoring.
+func (s *Service) DiscoverStarred(ctx context.Context) (*StarredReport, error) {
+	starred, err := s.processorFor(tenant.FromContext(ctx)).client.FetchStarred(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	repos := make([]models.Repository, 0, len(starred))
+	for _, remote := range starred {
+		repo := models.Repository{Owner: remote.Owner.Login, Name: remote.Name}
+		if s.config.StartDate.IsZero() {
+			repo.StartDateAuto = true
+		} else {
+			start := s.config.StartDate
+			repo.StartDate = &start
+		}
+		repos = append(repos, repo)
+	}
+	report := &StarredReport{Starred: len(repos)}
+	if len(repos) == 0 {
+		return report, nil
+	}
+
+	added, err := s.database.RegisterRepositories(ctx, repos)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register starred repositories: %w", err)
+	}
+	report.Added = added
+	report.Existing = len(repos) - added
+	return report, nil
+}
+
+// discoverLoop registers the starred repositories of the context's tenant
+// right away and then periodically, so repositories starred since are
+// monitored from the next poll on
+func (s *Service) discoverLoop(ctx context.Context, interval time.Duration) {
+	ticker := s.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := s.DiscoverStarred(ctx)
+		switch {
+		case err != nil:
+			logger.Error("Discovery of starred repositories failed", zap.Error(err))
+		case report.Added > 0:
+			logger.Info("Registered newly starred repositories",
+				zap.Int("starred", report.Starred),
+				zap.Int("added", report.Added))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package service
+
+import (
+	"context"
+
+	"githubapifetch/synclag"
+	"githubapifetch/webhook"
+)
+
+// syncLagAlert returns the alert function sending sync lag breaches and
+// recoveries to webhook consumers, or nil without webhooks; breaches are
+// logged either way
+func syncLagAlert(webhooks *webhook.Dispatcher) synclag.AlertFunc {
+	if webhooks == nil {
+		return nil
+	}
+	return func(ctx context.Context, a synclag.Alert) {
+		webhooks.NotifySyncLag(ctx, webhook.EventRepository{ID: a.RepoID, Owner: a.Owner, Name: a.Name}, a.Breached, webhook.SyncLag{
+			LagSeconds:  a.Lag.Seconds(),
+			EWMASeconds: a.EWMA.Seconds(),
+			SLOSeconds:  a.SLO.Seconds(),
+			SyncedAt:    a.SyncedAt,
+			PushedAt:    a.PushedAt,
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"githubapifetch/config"
+	"githubapifetch/github"
+	"githubapifetch/logger"
+)
+
+// checkGitHubToken returns the type of a GitHub token, or an error if GitHub
+// is known to reject it. A token in a format GitHub might still accept, such
+// as one issued by GitHub Enterprise, is logged as a warning and let through;
+// the first request tells whether it works.
+func checkGitHubToken(token string, fields ...zap.Field) (string, error) {
+	kind, err := github.TokenType(token)
+	if err != nil {
+		return "", err
+	}
+	if kind == github.TokenUnknown {
+		logger.Warn("GitHub token has an unrecognized format; GitHub will reject it if it is invalid", fields...)
+	}
+	return kind, nil
+}
+
+// tokenSetter is a GitHub client whose token can be replaced while it runs
+type tokenSetter interface {
+	SetToken(ctx context.Context, token string) error
+}
+
+// watchTokenFile rereads path every interval and hands the token it holds to
+// client whenever it changes, until ctx is done. A file that cannot be read,
+// or holds a token the client refuses, is logged and the current token kept;
+// the file is tried again on the next tick.
+func (s *Service) watchTokenFile(ctx context.Context, path string, interval time.Duration, client tokenSetter) {
+	current := s.config.GitHubToken
+	ticker := s.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			token, err := config.ReadTokenFile(path)
+			if err != nil {
+				logger.Error("Failed to reload GitHub token", zap.Error(err))
+				continue
+			}
+			if token == current {
+				continue
+			}
+			if err := client.SetToken(ctx, token); err != nil {
+				logger.Error("Refusing to rotate to a GitHub token that was not accepted",
+					zap.Error(err),
+					zap.String("path", path))
+				continue
+			}
+			current = token
+			kind, _ := github.TokenType(token)
+			logger.Info("Rotated GitHub token", zap.String("token_type", kind), zap.String("path", path))
+		}
+	}
+}
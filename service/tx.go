@@ -0,0 +1,35 @@
+package service
+
+import "context"
+
+// afterCommitKey carries the functions to run once inTx commits
+type afterCommitKey struct{}
+
+// inTx runs fn in one database transaction. Functions fn passes to
+// afterCommit run only once the transaction has committed, so webhooks and
+// the sink never announce commits that were rolled back. They are given ctx,
+// which no longer carries the finished transaction.
+func (p *RepositoryProcessor) inTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	var hooks []func(ctx context.Context)
+	if err := p.db.InTx(ctx, func(ctx context.Context) error {
+		// A retried transaction starts over
+		hooks = hooks[:0]
+		return fn(context.WithValue(ctx, afterCommitKey{}, &hooks))
+	}); err != nil {
+		return err
+	}
+	for _, hook := range hooks {
+		hook(ctx)
+	}
+	return nil
+}
+
+// afterCommit runs fn once the transaction of ctx commits, or right away
+// outside one
+func afterCommit(ctx context.Context, fn func(ctx context.Context)) {
+	if hooks, ok := ctx.Value(afterCommitKey{}).(*[]func(ctx context.Context)); ok {
+		*hooks = append(*hooks, fn)
+		return
+	}
+	fn(ctx)
+}
@@ -0,0 +1,161 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	bigQueryBaseURL = "https://bigquery.googleapis.com/bigquery/v2"
+	// metadataTokenURL hands out access tokens of the service account a
+	// workload runs as on Google Cloud
+	metadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+// BigQueryOptions configures a BigQuery writer
+type BigQueryOptions struct {
+	Project string
+	Dataset string
+	Table   string
+	// BaseURL and TokenURL default to the BigQuery API and the Google Cloud
+	// metadata server
+	BaseURL    string
+	TokenURL   string
+	HTTPClient *http.Client
+}
+
+// BigQuery writes rows with the tabledata.insertAll streaming API, using the
+// access token of the service account the service runs as
+type BigQuery struct {
+	opts   BigQueryOptions
+	client *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewBigQuery creates a BigQuery writer
+func NewBigQuery(opts BigQueryOptions) *BigQuery {
+	if opts.BaseURL == "" {
+		opts.BaseURL = bigQueryBaseURL
+	}
+	if opts.TokenURL == "" {
+		opts.TokenURL = metadataTokenURL
+	}
+	client := opts.HTTPClient
+	if client == nil {
+		client = defaultHTTPClient()
+	}
+	return &BigQuery{opts: opts, client: client}
+}
+
+type insertAllRow struct {
+	// InsertID lets BigQuery drop rows of a batch written twice
+	InsertID string `json:"insertId"`
+	JSON     Row    `json:"json"`
+}
+
+type insertAllResponse struct {
+	InsertErrors []struct {
+		Index  int `json:"index"`
+		Errors []struct {
+			Reason  string `json:"reason"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	} `json:"insertErrors"`
+}
+
+// WriteRows streams rows with one insertAll request. Rows BigQuery rejects
+// fail the whole batch.
+func (b *BigQuery) WriteRows(ctx context.Context, rows []Row) error {
+	request := struct {
+		Rows []insertAllRow `json:"rows"`
+	}{Rows: make([]insertAllRow, len(rows))}
+	for i, row := range rows {
+		request.Rows[i] = insertAllRow{
+			InsertID: fmt.Sprintf("%d/%d/%s", row.TenantID, row.RepositoryID, row.SHA),
+			JSON:     row,
+		}
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to encode rows: %w", err)
+	}
+
+	token, err := b.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	endpoint := fmt.Sprintf("%s/projects/%s/datasets/%s/tables/%s/insertAll",
+		b.opts.BaseURL, url.PathEscape(b.opts.Project), url.PathEscape(b.opts.Dataset), url.PathEscape(b.opts.Table))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("BigQuery responded with status code %d", resp.StatusCode)
+	}
+	var result insertAllResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to decode insertAll response: %w", err)
+	}
+	if len(result.InsertErrors) > 0 {
+		first := result.InsertErrors[0]
+		reason := "unknown"
+		if len(first.Errors) > 0 {
+			reason = first.Errors[0].Reason + ": " + first.Errors[0].Message
+		}
+		return fmt.Errorf("BigQuery rejected %d rows, row %d: %s", len(result.InsertErrors), first.Index, reason)
+	}
+	return nil
+}
+
+// accessToken returns a cached access token, fetching a new one from the
+// metadata server shortly before it expires
+func (b *BigQuery) accessToken(ctx context.Context) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.token != "" && time.Now().Before(b.expires) {
+		return b.token, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.opts.TokenURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch access token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata server responded with status code %d", resp.StatusCode)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil || token.AccessToken == "" {
+		return "", fmt.Errorf("invalid access token response")
+	}
+
+	b.token = token.AccessToken
+	b.expires = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return b.token, nil
+}
@@ -0,0 +1,84 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ClickHouseOptions configures a ClickHouse writer
+type ClickHouseOptions struct {
+	// URL is the HTTP interface, e.g. http://clickhouse:8123
+	URL      string
+	Table    string
+	User     string
+	Password string
+	// HTTPClient defaults to a client with a 30 second timeout
+	HTTPClient *http.Client
+}
+
+// ClickHouse writes rows through ClickHouse's HTTP interface as JSONEachRow
+type ClickHouse struct {
+	opts   ClickHouseOptions
+	client *http.Client
+}
+
+// NewClickHouse creates a ClickHouse writer
+func NewClickHouse(opts ClickHouseOptions) *ClickHouse {
+	client := opts.HTTPClient
+	if client == nil {
+		client = defaultHTTPClient()
+	}
+	return &ClickHouse{opts: opts, client: client}
+}
+
+// WriteRows inserts rows with one INSERT ... FORMAT JSONEachRow request
+func (c *ClickHouse) WriteRows(ctx context.Context, rows []Row) error {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, row := range rows {
+		if err := enc.Encode(row); err != nil {
+			return fmt.Errorf("failed to encode row %s: %w", row.SHA, err)
+		}
+	}
+
+	query := url.Values{}
+	query.Set("query", "INSERT INTO "+c.opts.Table+" FORMAT JSONEachRow")
+	// Timestamps are sent as RFC 3339
+	query.Set("date_time_input_format", "best_effort")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		strings.TrimRight(c.opts.URL, "/")+"/?"+query.Encode(), &body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if c.opts.User != "" {
+		req.Header.Set("X-ClickHouse-User", c.opts.User)
+		req.Header.Set("X-ClickHouse-Key", c.opts.Password)
+	}
+	return do(c.client, req)
+}
+
+// do sends req and turns a non-2xx response into an error carrying the
+// start of its body
+func do(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s responded with status code %d: %s", req.URL.Host, resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+func defaultHTTPClient() *http.Client {
+	return &http.Client{Timeout: 30 * time.Second}
+}
@@ -0,0 +1,233 @@
+// Package sink copies ingested commits to an analytical store, such as
+// ClickHouse or BigQuery, so heavy aggregate queries stay off Postgres.
+//
+// Commits are buffered and written in micro-batches in the background, once a
+// batch is full or the flush interval passes. Postgres stays the system of
+// record: a batch that fails every attempt is dropped and counted, never
+// retried later, and adding commits never blocks ingestion.
+package sink
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"githubapifetch/backoff"
+	"githubapifetch/clock"
+	"githubapifetch/logger"
+	"githubapifetch/metrics"
+	"githubapifetch/models"
+	"githubapifetch/tenant"
+)
+
+// Sink types
+const (
+	TypeClickHouse = "clickhouse"
+	TypeBigQuery   = "bigquery"
+)
+
+const (
+	defaultBatchSize     = 500
+	defaultFlushInterval = 10 * time.Second
+	defaultMaxBuffered   = 100000
+	defaultMaxAttempts   = 5
+)
+
+// Row is one commit as written to the analytical store
+type Row struct {
+	TenantID      int       `json:"tenant_id"`
+	RepositoryID  int       `json:"repository_id"`
+	RepoOwner     string    `json:"repo_owner"`
+	RepoName      string    `json:"repo_name"`
+	SHA           string    `json:"sha"`
+	AuthorName    string    `json:"author_name"`
+	Message       string    `json:"message"`
+	Date          time.Time `json:"date"`
+	CommitterDate time.Time `json:"committer_date"`
+	URL           string    `json:"url"`
+	Parents       []string  `json:"parents"`
+	IngestedAt    time.Time `json:"ingested_at"`
+}
+
+// Writer writes one batch of rows to an analytical store. A batch may be
+// written more than once after a failed attempt, so stores should
+// deduplicate on tenant, repository and SHA.
+type Writer interface {
+	WriteRows(ctx context.Context, rows []Row) error
+}
+
+// Options configures a Batcher
+type Options struct {
+	// BatchSize is the number of rows written at once
+	BatchSize int
+	// FlushInterval is the longest a row waits for its batch to fill
+	FlushInterval time.Duration
+	// MaxBuffered caps the rows waiting to be written; rows beyond it are
+	// dropped
+	MaxBuffered int
+	MaxAttempts int
+	// Backoff spaces out the attempts of a failed batch; exponential from a
+	// second up to a minute when nil
+	Backoff backoff.Backoff
+	Clock   clock.Clock
+}
+
+// Batcher buffers commits and writes them in batches. AddCommits never blocks;
+// call Run to start writing.
+type Batcher struct {
+	writer Writer
+	opts   Options
+
+	mu      sync.Mutex
+	pending []Row
+	full    chan struct{}
+}
+
+// NewBatcher creates a batcher writing to w
+func NewBatcher(w Writer, opts Options) *Batcher {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = defaultFlushInterval
+	}
+	if opts.MaxBuffered <= 0 {
+		opts.MaxBuffered = defaultMaxBuffered
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultMaxAttempts
+	}
+	if opts.Backoff == nil {
+		opts.Backoff = backoff.Exponential{Base: time.Second, Max: time.Minute}
+	}
+	if opts.Clock == nil {
+		opts.Clock = clock.Real
+	}
+	return &Batcher{
+		writer: w,
+		opts:   opts,
+		full:   make(chan struct{}, 1),
+	}
+}
+
+// AddCommits buffers newly ingested commits of a repository of the
+// context's tenant
+func (b *Batcher) AddCommits(ctx context.Context, repoID int, owner, name string, commits []models.Commit) {
+	tenantID := tenant.FromContext(ctx)
+	now := b.opts.Clock.Now().UTC()
+
+	b.mu.Lock()
+	room := b.opts.MaxBuffered - len(b.pending)
+	dropped := 0
+	if len(commits) > room {
+		dropped = len(commits) - max(room, 0)
+		commits = commits[:max(room, 0)]
+	}
+	for _, c := range commits {
+		// Stores reject a null array column
+		parents := c.Parents
+		if parents == nil {
+			parents = []string{}
+		}
+		b.pending = append(b.pending, Row{
+			TenantID:      tenantID,
+			RepositoryID:  repoID,
+			RepoOwner:     owner,
+			RepoName:      name,
+			SHA:           c.SHA,
+			AuthorName:    c.AuthorName,
+			Message:       c.Message,
+			Date:          c.Date,
+			CommitterDate: c.CommitterDate,
+			URL:           c.URL,
+			Parents:       parents,
+			IngestedAt:    now,
+		})
+	}
+	ready := len(b.pending) >= b.opts.BatchSize
+	b.mu.Unlock()
+
+	if dropped > 0 {
+		metrics.SinkRowsDropped.Add(int64(dropped))
+		logger.Warn("Sink buffer full, dropping commits",
+			zap.String("repo_owner", owner),
+			zap.String("repo_name", name),
+			zap.Int("dropped", dropped))
+	}
+	if ready {
+		select {
+		case b.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Run writes buffered rows until ctx is cancelled. Rows still buffered then
+// are left for a final Flush.
+func (b *Batcher) Run(ctx context.Context) {
+	ticker := b.opts.Clock.NewTicker(b.opts.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			b.Flush(ctx)
+		case <-b.full:
+			b.Flush(ctx)
+		}
+	}
+}
+
+// Flush writes every buffered row, one batch at a time
+func (b *Batcher) Flush(ctx context.Context) {
+	for {
+		b.mu.Lock()
+		n := min(len(b.pending), b.opts.BatchSize)
+		batch := b.pending[:n:n]
+		b.pending = b.pending[n:]
+		if len(b.pending) == 0 {
+			b.pending = nil
+		}
+		b.mu.Unlock()
+
+		if n == 0 {
+			return
+		}
+		b.write(ctx, batch)
+	}
+}
+
+// write writes one batch, retrying after the configured backoff, and drops it
+// once every attempt has failed
+func (b *Batcher) write(ctx context.Context, batch []Row) {
+	var delay time.Duration
+	for attempt := 1; ; attempt++ {
+		err := b.writer.WriteRows(ctx, batch)
+		if err == nil {
+			metrics.SinkRowsWritten.Add(int64(len(batch)))
+			return
+		}
+		if attempt == b.opts.MaxAttempts || ctx.Err() != nil {
+			metrics.SinkRowsDropped.Add(int64(len(batch)))
+			logger.Error("Dropping sink batch",
+				zap.Error(err),
+				zap.Int("rows", len(batch)),
+				zap.Int("attempts", attempt))
+			return
+		}
+
+		delay = b.opts.Backoff.Delay(attempt, delay)
+		logger.Warn("Sink write failed, retrying",
+			zap.Error(err),
+			zap.Int("rows", len(batch)),
+			zap.Int("attempt", attempt),
+			zap.Duration("backoff", delay))
+		if err := b.opts.Clock.Sleep(ctx, delay); err != nil {
+			metrics.SinkRowsDropped.Add(int64(len(batch)))
+			return
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"githubapifetch/backoff"
+	"githubapifetch/clock"
+	"githubapifetch/models"
+	"githubapifetch/tenant"
+)
+
+type recordingWriter struct {
+	mu      sync.Mutex
+	batches [][]Row
+	fail    int
+	// written, when set, receives the size of every batch written
+	written chan int
+}
+
+func (w *recordingWriter) WriteRows(ctx context.Context, rows []Row) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.fail > 0 {
+		w.fail--
+		return errors.New("unavailable")
+	}
+	w.batches = append(w.batches, append([]Row(nil), rows...))
+	if w.written != nil {
+		w.written <- len(rows)
+	}
+	return nil
+}
+
+func commits(n int) []models.Commit {
+	out := make([]models.Commit, n)
+	for i := range out {
+		out[i] = models.Commit{SHA: string(rune('a' + i)), AuthorName: "alice"}
+	}
+	return out
+}
+
+func TestBatcherWritesBatches(t *testing.T) {
+	w := &recordingWriter{}
+	b := NewBatcher(w, Options{BatchSize: 2})
+	ctx := tenant.WithID(context.Background(), 3)
+
+	b.AddCommits(ctx, 7, "octo", "hello", commits(5))
+	b.Flush(ctx)
+
+	require.Len(t, w.batches, 3)
+	assert.Len(t, w.batches[0], 2)
+	assert.Len(t, w.batches[2], 1)
+	row := w.batches[0][0]
+	assert.Equal(t, 3, row.TenantID)
+	assert.Equal(t, 7, row.RepositoryID)
+	assert.Equal(t, "hello", row.RepoName)
+	assert.NotNil(t, row.Parents)
+}
+
+func TestBatcherRetriesThenDrops(t *testing.T) {
+	w := &recordingWriter{fail: 1}
+	fake := clock.NewFake(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+	b := NewBatcher(w, Options{BatchSize: 10, MaxAttempts: 2, Backoff: backoff.Constant{Base: time.Minute}, Clock: fake})
+	// flush flushes in the background, advancing the clock through the
+	// backoff before the retry
+	flush := func() {
+		done := make(chan struct{})
+		go func() {
+			b.Flush(context.Background())
+			close(done)
+		}()
+		fake.BlockUntil(1)
+		fake.Advance(time.Minute)
+		<-done
+	}
+
+	b.AddCommits(context.Background(), 1, "octo", "hello", commits(3))
+	flush()
+	require.Len(t, w.batches, 1)
+
+	w.fail = 2
+	b.AddCommits(context.Background(), 1, "octo", "hello", commits(3))
+	flush()
+	assert.Len(t, w.batches, 1)
+	assert.Empty(t, b.pending)
+}
+
+func TestBatcherCapsBuffer(t *testing.T) {
+	w := &recordingWriter{}
+	b := NewBatcher(w, Options{BatchSize: 10, MaxBuffered: 4})
+
+	b.AddCommits(context.Background(), 1, "octo", "hello", commits(3))
+	b.AddCommits(context.Background(), 1, "octo", "hello", commits(3))
+	b.Flush(context.Background())
+	require.Len(t, w.batches, 1)
+	assert.Len(t, w.batches[0], 4)
+}
+
+func TestBatcherRunFlushes(t *testing.T) {
+	w := &recordingWriter{written: make(chan int, 1)}
+	fake := clock.NewFake(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+	b := NewBatcher(w, Options{BatchSize: 2, FlushInterval: time.Hour, Clock: fake})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		b.Run(ctx)
+		close(done)
+	}()
+	fake.BlockUntil(1)
+
+	// A full batch is written without waiting for the flush interval
+	b.AddCommits(context.Background(), 1, "octo", "hello", commits(2))
+	assert.Equal(t, 2, <-w.written)
+
+	// A partial one waits for it
+	b.AddCommits(context.Background(), 1, "octo", "hello", commits(1))
+	select {
+	case <-w.written:
+		t.Fatal("partial batch written before the flush interval")
+	default:
+	}
+	fake.Advance(time.Hour)
+	assert.Equal(t, 1, <-w.written)
+
+	cancel()
+	<-done
+}
+
+func TestClickHouseWriteRows(t *testing.T) {
+	var (
+		query string
+		user  string
+		rows  []Row
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("query")
+		user = r.Header.Get("X-ClickHouse-User")
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var row Row
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &row))
+			rows = append(rows, row)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClickHouse(ClickHouseOptions{URL: srv.URL, Table: "analytics.commits", User: "ingest"})
+	require.NoError(t, c.WriteRows(context.Background(), []Row{{SHA: "a"}, {SHA: "b"}}))
+	assert.Equal(t, "INSERT INTO analytics.commits FORMAT JSONEachRow", query)
+	assert.Equal(t, "ingest", user)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "b", rows[1].SHA)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Code: 60. Table does not exist", http.StatusNotFound)
+	}))
+	defer failing.Close()
+	err := NewClickHouse(ClickHouseOptions{URL: failing.URL, Table: "missing"}).WriteRows(context.Background(), []Row{{SHA: "a"}})
+	assert.ErrorContains(t, err, "Table does not exist")
+}
+
+func TestBigQueryWriteRows(t *testing.T) {
+	tokenRequests := 0
+	var request struct {
+		Rows []insertAllRow `json:"rows"`
+	}
+	reject := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			tokenRequests++
+			assert.Equal(t, "Google", r.Header.Get("Metadata-Flavor"))
+			w.Write([]byte(`{"access_token":"tok","expires_in":3600}`))
+		case "/projects/p/datasets/d/tables/commits/insertAll":
+			assert.Equal(t, "Bearer tok", r.Header.Get("Authorization"))
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			if reject {
+				w.Write([]byte(`{"insertErrors":[{"index":0,"errors":[{"reason":"invalid","message":"no such field"}]}]}`))
+				return
+			}
+			w.Write([]byte(`{}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	b := NewBigQuery(BigQueryOptions{Project: "p", Dataset: "d", Table: "commits", BaseURL: srv.URL, TokenURL: srv.URL + "/token"})
+	require.NoError(t, b.WriteRows(context.Background(), []Row{{TenantID: 1, RepositoryID: 2, SHA: "a"}}))
+	require.Len(t, request.Rows, 1)
+	assert.Equal(t, "1/2/a", request.Rows[0].InsertID)
+
+	reject = true
+	err := b.WriteRows(context.Background(), []Row{{SHA: "b"}})
+	assert.ErrorContains(t, err, "no such field")
+	assert.Equal(t, 1, tokenRequests)
+}
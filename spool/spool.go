@@ -0,0 +1,327 @@
+// Package spool keeps fetched commit pages on disk until they are stored: a
+// sync interrupted by a database outage or a crash does not spend its API
+// budget again, and with write-ahead spooling fetching need not wait for
+// inserts.
+//
+// Every page is one file, under a directory per tenant and repository, named
+// by the order it was appended in. Files are written to a temporary name,
+// synced and then renamed, so a crash never leaves half a page behind. The
+// spool is bounded: an append that would take it past its size is refused.
+//
+// One process may append to a spool while others store and remove its
+// entries, as a fetch-only and an ingest-only process sharing a volume do.
+package spool
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxBytes bounds the size of a spool when no other size is given
+const DefaultMaxBytes = 256 << 20
+
+// ErrFull is returned by Append when the entry would not fit in the spool
+var ErrFull = errors.New("spool full")
+
+// Entry is one page of commits fetched for a repository and not yet stored
+type Entry struct {
+	TenantID int    `json:"tenant_id"`
+	Owner    string `json:"owner"`
+	Name     string `json:"name"`
+	// Branch, Cursor and Since are those of the sync that fetched the page,
+	// and Page its number; NextSHA is the first-parent walk's position after
+	// it
+	Branch  string    `json:"branch"`
+	Cursor  string    `json:"cursor"`
+	Since   time.Time `json:"since"`
+	Page    int       `json:"page"`
+	NextSHA string    `json:"next_sha,omitempty"`
+	// Commits holds the page's commits as JSON
+	Commits json.RawMessage `json:"commits"`
+	// Repo holds the repository as GitHub returned it, as JSON, for pages
+	// fetched without the database to store the repository in
+	Repo json.RawMessage `json:"repo,omitempty"`
+	// Done marks the end of a listing; the entry has no commits
+	Done bool `json:"done,omitempty"`
+	// Seq orders the entries of a spool; Append returns it, and Pending
+	// sets it
+	Seq uint64 `json:"-"`
+}
+
+// Repo is a repository with spooled entries. Owner and name are lowercase.
+type Repo struct {
+	TenantID int
+	Owner    string
+	Name     string
+}
+
+// staleTemp is how old a temporary file must be for Open to take it for the
+// leftover of an append cut short, rather than one another process is
+// writing
+const staleTemp = time.Minute
+
+// Spool is a bounded directory of entries. It is safe for concurrent use.
+type Spool struct {
+	dir      string
+	maxBytes int64
+
+	mu   sync.Mutex
+	seq  uint64
+	size int64
+}
+
+// Open opens the spool in dir, creating dir if needed, and holding at most
+// maxBytes of entries; maxBytes of 0 means DefaultMaxBytes. Entries left
+// behind by an earlier run count towards the size and are kept.
+func Open(dir string, maxBytes int64) (*Spool, error) {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytes
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+	s := &Spool{dir: dir, maxBytes: maxBytes}
+	if err := s.Refresh(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Refresh rereads the size of the spool from disk, taking in the entries
+// other processes appended or removed
+func (s *Spool) Refresh() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.scan()
+}
+
+// scan computes the size and last sequence number of the spool from its
+// files, removing stale temporary files. s.mu must be held.
+func (s *Spool) scan() error {
+	var size int64
+	err := filepath.WalkDir(s.dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		seq, ok := entrySeq(d.Name())
+		if !ok {
+			if time.Since(info.ModTime()) > staleTemp {
+				return os.Remove(path)
+			}
+			return nil
+		}
+		size += info.Size()
+		if seq > s.seq {
+			s.seq = seq
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read spool directory: %w", err)
+	}
+	s.size = size
+	return nil
+}
+
+// Size returns the number of bytes the spool's entries take
+func (s *Spool) Size() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+// Repos returns the repositories with spooled entries
+func (s *Spool) Repos() ([]Repo, error) {
+	var repos []Repo
+	tenants, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool directory: %w", err)
+	}
+	for _, t := range tenants {
+		tenantID, err := strconv.Atoi(t.Name())
+		if err != nil || !t.IsDir() {
+			continue
+		}
+		owners, err := os.ReadDir(filepath.Join(s.dir, t.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read spool directory: %w", err)
+		}
+		for _, o := range owners {
+			names, err := os.ReadDir(filepath.Join(s.dir, t.Name(), o.Name()))
+			if err != nil {
+				continue
+			}
+			for _, n := range names {
+				files, err := os.ReadDir(filepath.Join(s.dir, t.Name(), o.Name(), n.Name()))
+				if err != nil {
+					continue
+				}
+				for _, f := range files {
+					if _, ok := entrySeq(f.Name()); ok {
+						repos = append(repos, Repo{TenantID: tenantID, Owner: o.Name(), Name: n.Name()})
+						break
+					}
+				}
+			}
+		}
+	}
+	return repos, nil
+}
+
+// Append writes e to the spool, after every entry already in it, and
+// returns its sequence number
+func (s *Spool) Append(e Entry) (uint64, error) {
+	dir, err := s.repoDir(e.TenantID, e.Owner, e.Name)
+	if err != nil {
+		return 0, err
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode spool entry: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.size+int64(len(data)) > s.maxBytes {
+		// Another process may have removed entries since
+		if err := s.scan(); err != nil {
+			return 0, err
+		}
+		if s.size+int64(len(data)) > s.maxBytes {
+			return 0, fmt.Errorf("%w: %d of %d bytes used", ErrFull, s.size, s.maxBytes)
+		}
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return 0, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	seq := s.seq + 1
+	path := filepath.Join(dir, entryName(seq))
+	if err := writeFile(path, data); err != nil {
+		return 0, fmt.Errorf("failed to write spool entry: %w", err)
+	}
+	s.seq = seq
+	s.size += int64(len(data))
+	return seq, nil
+}
+
+// Pending returns the entries spooled for a repository, oldest first
+func (s *Spool) Pending(tenantID int, owner, name string) ([]Entry, error) {
+	dir, err := s.repoDir(tenantID, owner, name)
+	if err != nil {
+		return nil, err
+	}
+	files, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool directory: %w", err)
+	}
+
+	var entries []Entry
+	for _, f := range files {
+		seq, ok := entrySeq(f.Name())
+		if !ok {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read spool entry: %w", err)
+		}
+		var e Entry
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, fmt.Errorf("invalid spool entry %s: %w", f.Name(), err)
+		}
+		e.Seq = seq
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Seq < entries[j].Seq })
+	return entries, nil
+}
+
+// Remove deletes an entry returned by Pending, once it is stored
+func (s *Spool) Remove(e Entry) error {
+	dir, err := s.repoDir(e.TenantID, e.Owner, e.Name)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, entryName(e.Seq))
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to remove spool entry: %w", err)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove spool entry: %w", err)
+	}
+	s.mu.Lock()
+	// Entries another process appended are not counted until Refresh
+	s.size = max(s.size-info.Size(), 0)
+	s.mu.Unlock()
+	return nil
+}
+
+// repoDir returns the directory holding a repository's entries. Owner and
+// name become path elements, so anything that could leave the spool is
+// refused.
+func (s *Spool) repoDir(tenantID int, owner, name string) (string, error) {
+	for _, part := range []string{owner, name} {
+		if part == "" || part == "." || part == ".." || strings.ContainsAny(part, `/\`) {
+			return "", fmt.Errorf("invalid repository %q for spool", owner+"/"+name)
+		}
+	}
+	return filepath.Join(s.dir, strconv.Itoa(tenantID), strings.ToLower(owner), strings.ToLower(name)), nil
+}
+
+// entryName returns the file name of the entry appended as seq; names sort
+// in append order
+func entryName(seq uint64) string {
+	return fmt.Sprintf("%020d.json", seq)
+}
+
+// entrySeq returns the sequence number of an entry file name
+func entrySeq(name string) (uint64, bool) {
+	digits, ok := strings.CutSuffix(name, ".json")
+	if !ok {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(digits, 10, 64)
+	return seq, err == nil
+}
+
+// writeFile writes data to path through a synced temporary file, so path
+// either holds all of data or does not exist
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
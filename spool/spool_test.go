@@ -0,0 +1,92 @@
+package spool
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func entry(page int) Entry {
+	return Entry{
+		TenantID: 1,
+		Owner:    "Octo",
+		Name:     "hello",
+		Cursor:   "since=2024-01-01T00:00:00Z",
+		Since:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Page:     page,
+		Commits:  json.RawMessage(`[{"sha":"abc"}]`),
+	}
+}
+
+func TestSpool(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, 0)
+	require.NoError(t, err)
+
+	for page := 1; page <= 3; page++ {
+		_, err := s.Append(entry(page))
+		require.NoError(t, err)
+	}
+	other := entry(1)
+	other.Name = "world"
+	_, err = s.Append(other)
+	require.NoError(t, err)
+
+	// Entries come back per repository, in the order they were appended
+	entries, err := s.Pending(1, "octo", "hello")
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	for i, e := range entries {
+		assert.Equal(t, i+1, e.Page)
+		assert.JSONEq(t, `[{"sha":"abc"}]`, string(e.Commits))
+	}
+	none, err := s.Pending(2, "octo", "hello")
+	require.NoError(t, err)
+	assert.Empty(t, none)
+
+	require.NoError(t, s.Remove(entries[0]))
+	size := s.Size()
+
+	// Reopening keeps the entries and drops half-written ones
+	tmp := filepath.Join(dir, "1", "octo", "hello", "00000000000000000009.json.tmp")
+	require.NoError(t, os.WriteFile(tmp, []byte("{"), 0o600))
+	old := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(tmp, old, old))
+	s, err = Open(dir, 0)
+	require.NoError(t, err)
+	assert.Equal(t, size, s.Size())
+	assert.NoFileExists(t, tmp)
+
+	repos, err := s.Repos()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []Repo{{TenantID: 1, Owner: "octo", Name: "hello"}, {TenantID: 1, Owner: "octo", Name: "world"}}, repos)
+	seq, err := s.Append(entry(4))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), seq)
+	entries, err = s.Pending(1, "octo", "hello")
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, []int{2, 3, 4}, []int{entries[0].Page, entries[1].Page, entries[2].Page})
+}
+
+func TestSpoolBounds(t *testing.T) {
+	s, err := Open(t.TempDir(), 300)
+	require.NoError(t, err)
+	_, err = s.Append(entry(1))
+	require.NoError(t, err)
+	_, err = s.Append(entry(2))
+	assert.ErrorIs(t, err, ErrFull)
+
+	bad := entry(1)
+	bad.Name = ".."
+	_, err = s.Append(bad)
+	assert.Error(t, err)
+	bad.Name = "a/b"
+	_, err = s.Append(bad)
+	assert.Error(t, err)
+}
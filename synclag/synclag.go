@@ -0,0 +1,284 @@
+// Package synclag measures how far the stored commits of each repository are
+// behind GitHub, and alerts when the smoothed lag breaches the sync lag SLO.
+//
+// A repository is behind once GitHub reports a push newer than the start of
+// its last successful sync. Its lag is then the time since that sync, or
+// since the tracker started for repositories not synced successfully since.
+// The lag is sampled continuously and smoothed with an exponentially weighted
+// moving average, so a single slow sync does not page anyone.
+package synclag
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"githubapifetch/logger"
+	"githubapifetch/metrics"
+	"githubapifetch/tenant"
+)
+
+// Defaults for sampling and smoothing
+const (
+	DefaultSampleInterval = 30 * time.Second
+	DefaultHalfLife       = 15 * time.Minute
+)
+
+// Repo is the sync lag of one repository
+type Repo struct {
+	TenantID int           `json:"tenant_id"`
+	RepoID   int           `json:"repo_id"`
+	Owner    string        `json:"owner"`
+	Name     string        `json:"name"`
+	PushedAt time.Time     `json:"pushed_at"` // Newest push seen on GitHub
+	SyncedAt time.Time     `json:"synced_at"` // Start of the last successful sync; zero if none yet
+	Lag      time.Duration `json:"-"`
+	EWMA     time.Duration `json:"-"`
+	Breached bool          `json:"breached"`
+
+	sampledAt time.Time
+}
+
+// MarshalJSON reports the lag and its moving average in seconds
+func (r Repo) MarshalJSON() ([]byte, error) {
+	type plain Repo
+	return json.Marshal(struct {
+		plain
+		LagSeconds  float64 `json:"lag_seconds"`
+		EWMASeconds float64 `json:"lag_ewma_seconds"`
+	}{plain(r), r.Lag.Seconds(), r.EWMA.Seconds()})
+}
+
+// Alert reports a repository starting or ceasing to breach the SLO
+type Alert struct {
+	Repo
+	SLO time.Duration
+}
+
+// AlertFunc is called with the context scoped to the repository's tenant
+type AlertFunc func(ctx context.Context, a Alert)
+
+// Options configures a Tracker
+type Options struct {
+	// SLO is the smoothed lag above which a repository is alerted on; 0
+	// disables alerts
+	SLO time.Duration
+	// HalfLife is how long it takes a lag sample to lose half its weight
+	HalfLife time.Duration
+	// Alert is called on every breach and recovery, besides logging
+	Alert AlertFunc
+}
+
+type key struct {
+	tenantID    int
+	owner, name string
+}
+
+// Tracker keeps the sync lag of every repository synced since it started
+type Tracker struct {
+	mu      sync.Mutex
+	repos   map[key]*Repo
+	opts    Options
+	started time.Time
+	now     func() time.Time
+}
+
+// NewTracker creates a tracker; call Run to start sampling
+func NewTracker(opts Options) *Tracker {
+	if opts.HalfLife <= 0 {
+		opts.HalfLife = DefaultHalfLife
+	}
+	return &Tracker{
+		repos:   make(map[key]*Repo),
+		opts:    opts,
+		started: time.Now(),
+		now:     time.Now,
+	}
+}
+
+// Observe records the newest push GitHub reports for a repository. Calls on
+// a nil tracker are discarded.
+func (t *Tracker) Observe(tenantID, repoID int, owner, name string, pushedAt time.Time) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	r := t.repo(tenantID, owner, name)
+	r.RepoID = repoID
+	if pushedAt.After(r.PushedAt) {
+		r.PushedAt = pushedAt
+	}
+}
+
+// Synced records a successful sync that started at startedAt. Calls on a nil
+// tracker are discarded.
+func (t *Tracker) Synced(tenantID int, owner, name string, startedAt time.Time) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	r := t.repo(tenantID, owner, name)
+	if startedAt.After(r.SyncedAt) {
+		r.SyncedAt = startedAt
+	}
+}
+
+// Forget stops tracking a repository that is no longer monitored
+func (t *Tracker) Forget(tenantID int, name string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for k := range t.repos {
+		if k.tenantID == tenantID && k.name == name {
+			delete(t.repos, k)
+			metrics.SyncLagSeconds.Delete(metrics.RepoKey(k.owner, k.name))
+			metrics.SyncLagEWMASeconds.Delete(metrics.RepoKey(k.owner, k.name))
+		}
+	}
+}
+
+// repo returns the entry for a repository, creating it; t.mu must be held
+func (t *Tracker) repo(tenantID int, owner, name string) *Repo {
+	k := key{tenantID, owner, name}
+	r, ok := t.repos[k]
+	if !ok {
+		r = &Repo{TenantID: tenantID, Owner: owner, Name: name}
+		t.repos[k] = r
+	}
+	return r
+}
+
+// Run samples the lag of every repository each interval until ctx is
+// cancelled
+func (t *Tracker) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultSampleInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.Sample(ctx)
+		}
+	}
+}
+
+// Sample updates the lag and its moving average for every repository,
+// publishes them and alerts on SLO breaches and recoveries
+func (t *Tracker) Sample(ctx context.Context) {
+	now := t.now()
+	var alerts []Alert
+
+	t.mu.Lock()
+	for _, r := range t.repos {
+		r.Lag = t.lag(r, now)
+		if r.sampledAt.IsZero() {
+			r.EWMA = r.Lag
+		} else {
+			// Weight samples by the time since the last one, so irregular
+			// sampling does not skew the average
+			alpha := 1 - math.Exp2(-float64(now.Sub(r.sampledAt))/float64(t.opts.HalfLife))
+			r.EWMA += time.Duration(alpha * float64(r.Lag-r.EWMA))
+		}
+		r.sampledAt = now
+
+		repoKey := metrics.RepoKey(r.Owner, r.Name)
+		metrics.SyncLagSeconds.Set(repoKey, seconds(r.Lag))
+		metrics.SyncLagEWMASeconds.Set(repoKey, seconds(r.EWMA))
+
+		if t.opts.SLO <= 0 {
+			continue
+		}
+		if breached := r.EWMA > t.opts.SLO; breached != r.Breached {
+			r.Breached = breached
+			if breached {
+				metrics.SyncLagBreaches.Add(repoKey, 1)
+			}
+			alerts = append(alerts, Alert{Repo: *r, SLO: t.opts.SLO})
+		}
+	}
+	t.mu.Unlock()
+
+	for _, a := range alerts {
+		t.alert(ctx, a)
+	}
+}
+
+// lag returns how far a repository is behind GitHub as of now
+func (t *Tracker) lag(r *Repo, now time.Time) time.Duration {
+	if r.PushedAt.IsZero() || (!r.SyncedAt.IsZero() && !r.PushedAt.After(r.SyncedAt)) {
+		return 0
+	}
+	since := r.SyncedAt
+	if since.IsZero() {
+		since = t.started
+	}
+	if lag := now.Sub(since); lag > 0 {
+		return lag
+	}
+	return 0
+}
+
+func (t *Tracker) alert(ctx context.Context, a Alert) {
+	fields := []zap.Field{
+		zap.Int("tenant_id", a.TenantID),
+		zap.String("repo_owner", a.Owner),
+		zap.String("repo_name", a.Name),
+		zap.Duration("lag", a.Lag),
+		zap.Duration("lag_ewma", a.EWMA),
+		zap.Duration("slo", a.SLO),
+	}
+	if a.Breached {
+		logger.Warn("Repository sync lag breached the SLO", fields...)
+	} else {
+		logger.Info("Repository sync lag back within the SLO", fields...)
+	}
+
+	if t.opts.Alert != nil {
+		t.opts.Alert(tenant.WithID(ctx, a.TenantID), a)
+	}
+}
+
+// Snapshot returns the sync lag of a tenant's repositories ordered by
+// repository
+func (t *Tracker) Snapshot(tenantID int) []Repo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	repos := make([]Repo, 0, len(t.repos))
+	for k, r := range t.repos {
+		if k.tenantID == tenantID {
+			repos = append(repos, *r)
+		}
+	}
+	sort.Slice(repos, func(i, j int) bool {
+		if repos[i].Owner != repos[j].Owner {
+			return repos[i].Owner < repos[j].Owner
+		}
+		return repos[i].Name < repos[j].Name
+	})
+	return repos
+}
+
+func seconds(d time.Duration) *expvar.Float {
+	f := new(expvar.Float)
+	f.Set(d.Seconds())
+	return f
+}
@@ -0,0 +1,130 @@
+package synclag
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"githubapifetch/metrics"
+	"githubapifetch/tenant"
+)
+
+func TestTrackerMeasuresLagSinceLastSync(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := start
+	tracker := NewTracker(Options{HalfLife: time.Minute})
+	tracker.started, tracker.now = start, func() time.Time { return clock }
+
+	// Synced after the newest push: not behind
+	tracker.Observe(1, 7, "octo", "hello", start.Add(-time.Hour))
+	tracker.Synced(1, "octo", "hello", start)
+	clock = start.Add(10 * time.Minute)
+	tracker.Sample(context.Background())
+	assert.Zero(t, tracker.Snapshot(1)[0].Lag)
+
+	// A push the next sync failed to pick up puts it behind since the last
+	// successful sync
+	tracker.Observe(1, 7, "octo", "hello", start.Add(5*time.Minute))
+	tracker.Sample(context.Background())
+	r := tracker.Snapshot(1)[0]
+	assert.Equal(t, 10*time.Minute, r.Lag)
+	assert.Equal(t, 7, r.RepoID)
+	assert.Equal(t, "600", metrics.SyncLagSeconds.Get(metrics.RepoKey("octo", "hello")).String())
+
+	tracker.Synced(1, "octo", "hello", start.Add(11*time.Minute))
+	clock = start.Add(12 * time.Minute)
+	tracker.Sample(context.Background())
+	assert.Zero(t, tracker.Snapshot(1)[0].Lag)
+}
+
+func TestTrackerCountsFromStartWithoutSync(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker := NewTracker(Options{})
+	tracker.started, tracker.now = start, func() time.Time { return start.Add(time.Hour) }
+
+	tracker.Observe(1, 7, "octo", "hello", start.AddDate(0, -1, 0))
+	tracker.Sample(context.Background())
+	assert.Equal(t, time.Hour, tracker.Snapshot(1)[0].Lag)
+}
+
+func TestTrackerAlertsOnSmoothedLag(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := start
+	var alerts []Alert
+	var alertTenants []int
+	tracker := NewTracker(Options{
+		SLO:      30 * time.Minute,
+		HalfLife: 10 * time.Minute,
+		Alert: func(ctx context.Context, a Alert) {
+			alerts = append(alerts, a)
+			alertTenants = append(alertTenants, tenant.FromContext(ctx))
+		},
+	})
+	tracker.started, tracker.now = start, func() time.Time { return clock }
+
+	tracker.Observe(2, 7, "octo", "hello", start.Add(time.Minute))
+	tracker.Synced(2, "octo", "hello", start)
+	tracker.Sample(context.Background())
+
+	// The raw lag passes the SLO at 30m, the average only at 40m
+	for i := 1; i <= 3; i++ {
+		clock = start.Add(time.Duration(i) * 10 * time.Minute)
+		tracker.Sample(context.Background())
+	}
+	r := tracker.Snapshot(2)[0]
+	assert.Equal(t, 30*time.Minute, r.Lag)
+	assert.Equal(t, 21*time.Minute+15*time.Second, r.EWMA)
+	assert.False(t, r.Breached)
+	assert.Empty(t, alerts)
+
+	clock = start.Add(40 * time.Minute)
+	tracker.Sample(context.Background())
+	r = tracker.Snapshot(2)[0]
+	assert.Equal(t, 30*time.Minute+37500*time.Millisecond, r.EWMA)
+	assert.True(t, r.Breached)
+	require.Len(t, alerts, 1)
+	assert.True(t, alerts[0].Breached)
+	assert.Equal(t, 30*time.Minute, alerts[0].SLO)
+	assert.Equal(t, []int{2}, alertTenants)
+
+	// Still breached: no repeated alert
+	clock = clock.Add(time.Minute)
+	tracker.Sample(context.Background())
+	assert.Len(t, alerts, 1)
+
+	// Caught up; the average decays below the SLO after a while
+	tracker.Synced(2, "octo", "hello", clock)
+	clock = clock.Add(10 * time.Minute)
+	tracker.Sample(context.Background())
+	require.Len(t, alerts, 2)
+	assert.False(t, alerts[1].Breached)
+}
+
+func TestTrackerForgetsRepositories(t *testing.T) {
+	tracker := NewTracker(Options{})
+	tracker.Observe(1, 7, "octo", "hello", time.Now())
+	tracker.Observe(2, 8, "octo", "hello", time.Now())
+
+	tracker.Forget(1, "hello")
+	assert.Empty(t, tracker.Snapshot(1))
+	assert.Len(t, tracker.Snapshot(2), 1)
+}
+
+func TestRepoJSONReportsSeconds(t *testing.T) {
+	body, err := json.Marshal(Repo{Owner: "octo", Name: "hello", Lag: 90 * time.Second, EWMA: 30 * time.Second})
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `"lag_seconds":90`)
+	assert.Contains(t, string(body), `"lag_ewma_seconds":30`)
+	assert.Contains(t, string(body), `"owner":"octo"`)
+}
+
+func TestNilTrackerDiscardsReports(t *testing.T) {
+	var tracker *Tracker
+	tracker.Observe(1, 7, "octo", "hello", time.Now())
+	tracker.Synced(1, "octo", "hello", time.Now())
+	tracker.Forget(1, "hello")
+}
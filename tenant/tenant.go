@@ -0,0 +1,25 @@
+// Package tenant carries the active tenant through request and job contexts.
+package tenant
+
+import "context"
+
+// DefaultID is the tenant owning data configured through the environment
+const DefaultID = 1
+
+// DefaultName is the name of the default tenant
+const DefaultName = "default"
+
+type ctxKey struct{}
+
+// WithID returns a copy of ctx scoped to the given tenant
+func WithID(ctx context.Context, id int) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the tenant the context is scoped to, or DefaultID
+func FromContext(ctx context.Context) int {
+	if id, ok := ctx.Value(ctxKey{}).(int); ok && id > 0 {
+		return id
+	}
+	return DefaultID
+}
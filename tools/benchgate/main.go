@@ -0,0 +1,161 @@
+// Command benchgate compares two `go test -bench` outputs and fails when a
+// benchmark got slower than a threshold, so a change can be checked against
+// the benchmarks of its base before it is merged:
+//
+//	go run ./tools/benchgate -threshold 10 bench/old.txt bench/new.txt
+//
+// Each benchmark is compared by its median ns/op over all runs in a file,
+// which makes -count > 1 outputs robust to single noisy runs.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+func main() {
+	threshold := flag.Float64("threshold", 10, "Percentage by which a benchmark may get slower")
+	flag.Parse()
+	if flag.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "usage: benchgate [-threshold percent] old.txt new.txt")
+		os.Exit(2)
+	}
+
+	old, err := parseFile(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	cur, err := parseFile(flag.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	results := compare(old, cur, *threshold)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "BENCHMARK\tOLD NS/OP\tNEW NS/OP\tDELTA\t")
+	regressions := 0
+	for _, r := range results {
+		status := ""
+		switch {
+		case r.Old == 0:
+			status = "added"
+		case r.New == 0:
+			status = "removed"
+		case r.Regressed:
+			status = "REGRESSED"
+			regressions++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Name, formatNs(r.Old), formatNs(r.New), formatDelta(r), status)
+	}
+	w.Flush()
+
+	if regressions > 0 {
+		fmt.Printf("\n%d benchmarks got more than %g%% slower\n", regressions, *threshold)
+		os.Exit(1)
+	}
+}
+
+// result compares the median ns/op of one benchmark; Old or New is 0 when
+// the benchmark only ran on one side
+type result struct {
+	Name      string
+	Old, New  float64
+	Regressed bool
+}
+
+// compare pairs up the benchmarks of two outputs, sorted by name
+func compare(old, cur map[string]float64, threshold float64) []result {
+	names := make([]string, 0, len(old)+len(cur))
+	for name := range old {
+		names = append(names, name)
+	}
+	for name := range cur {
+		if _, ok := old[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	results := make([]result, len(names))
+	for i, name := range names {
+		r := result{Name: name, Old: old[name], New: cur[name]}
+		r.Regressed = r.Old > 0 && r.New > r.Old*(1+threshold/100)
+		results[i] = r
+	}
+	return results
+}
+
+func parseFile(path string) (map[string]float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	medians, err := parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return medians, nil
+}
+
+// parse returns the median ns/op of each benchmark in a `go test -bench`
+// output. Lines that are not benchmark results, such as PASS or goos, are
+// skipped.
+func parse(r io.Reader) (map[string]float64, error) {
+	runs := make(map[string][]float64)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+		// Name, iterations, then value/unit pairs
+		for i := 2; i+1 < len(fields); i += 2 {
+			if fields[i+1] != "ns/op" {
+				continue
+			}
+			ns, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid ns/op in %q: %w", scanner.Text(), err)
+			}
+			runs[fields[0]] = append(runs[fields[0]], ns)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	medians := make(map[string]float64, len(runs))
+	for name, ns := range runs {
+		slices.Sort(ns)
+		if len(ns)%2 == 1 {
+			medians[name] = ns[len(ns)/2]
+		} else {
+			medians[name] = (ns[len(ns)/2-1] + ns[len(ns)/2]) / 2
+		}
+	}
+	return medians, nil
+}
+
+func formatNs(ns float64) string {
+	if ns == 0 {
+		return "-"
+	}
+	return strconv.FormatFloat(ns, 'f', 0, 64)
+}
+
+func formatDelta(r result) string {
+	if r.Old == 0 || r.New == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%+.1f%%", (r.New/r.Old-1)*100)
+}
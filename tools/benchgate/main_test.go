@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	output := `goos: linux
+goarch: amd64
+pkg: githubapifetch/db
+BenchmarkBatchInsert/new/100-8         	     100	  1200000 ns/op	   25000 rows/s	  9000 B/op	  120 allocs/op
+BenchmarkBatchInsert/new/100-8         	     100	  1000000 ns/op	   25000 rows/s	  9000 B/op	  120 allocs/op
+BenchmarkBatchInsert/new/100-8         	     100	  9000000 ns/op	   25000 rows/s	  9000 B/op	  120 allocs/op
+BenchmarkCommitPrepare/clean-8         	  200000	     5000 ns/op
+BenchmarkCommitPrepare/clean-8         	  200000	     6000 ns/op
+PASS
+ok  	githubapifetch/db	12.3s
+`
+	medians, err := parse(strings.NewReader(output))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{
+		"BenchmarkBatchInsert/new/100-8": 1200000,
+		"BenchmarkCommitPrepare/clean-8": 5500,
+	}, medians)
+}
+
+func TestCompare(t *testing.T) {
+	old := map[string]float64{"BenchmarkA": 100, "BenchmarkB": 100, "BenchmarkGone": 100}
+	cur := map[string]float64{"BenchmarkA": 109, "BenchmarkB": 111, "BenchmarkNew": 100}
+
+	assert.Equal(t, []result{
+		{Name: "BenchmarkA", Old: 100, New: 109},
+		{Name: "BenchmarkB", Old: 100, New: 111, Regressed: true},
+		{Name: "BenchmarkGone", Old: 100},
+		{Name: "BenchmarkNew", New: 100},
+	}, compare(old, cur, 10))
+}
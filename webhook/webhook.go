@@ -0,0 +1,348 @@
+// Package webhook pushes ingestion events to consumer URLs as signed JSON
+// POST requests, retrying failed deliveries and dead-lettering those that
+// never succeed.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"githubapifetch/backoff"
+	"githubapifetch/clock"
+	"githubapifetch/logger"
+	"githubapifetch/models"
+	"githubapifetch/tenant"
+)
+
+// Event types
+const (
+	EventCommitsIngested       = "commits.ingested"
+	EventReleasesIngested      = "releases.ingested"
+	EventRepositoryQuarantined = "repository.quarantined"
+	EventSyncLagBreached       = "sync_lag.breached"
+	EventSyncLagRecovered      = "sync_lag.recovered"
+	EventDefaultBranchChanged  = "repository.default_branch_changed"
+)
+
+// Delivery headers
+const (
+	SignatureHeader = "X-Hub-Signature-256"
+	EventHeader     = "X-Event-Type"
+	DeliveryHeader  = "X-Delivery-ID"
+)
+
+const (
+	defaultMaxAttempts = 5
+	defaultQueueSize   = 256
+)
+
+// Event is the JSON body delivered to consumers
+type Event struct {
+	ID         string           `json:"id"`
+	Type       string           `json:"type"`
+	TenantID   int              `json:"tenant_id"`
+	Repository EventRepository  `json:"repository"`
+	Commits    []models.Commit  `json:"commits,omitempty"`
+	Releases   []models.Release `json:"releases,omitempty"`
+	Quarantine *Quarantine      `json:"quarantine,omitempty"`
+	SyncLag    *SyncLag         `json:"sync_lag,omitempty"`
+	Branch     *BranchChange    `json:"default_branch,omitempty"`
+	CreatedAt  time.Time        `json:"created_at"`
+}
+
+// Quarantine describes why a repository stopped being monitored and when it
+// is checked again
+type Quarantine struct {
+	Failures    int       `json:"failures"`
+	LastError   string    `json:"last_error"`
+	NextCheckAt time.Time `json:"next_check_at"`
+}
+
+// SyncLag describes how far a repository is behind GitHub against the
+// sync lag SLO, in seconds
+type SyncLag struct {
+	LagSeconds  float64   `json:"lag_seconds"`
+	EWMASeconds float64   `json:"lag_ewma_seconds"`
+	SLOSeconds  float64   `json:"slo_seconds"`
+	SyncedAt    time.Time `json:"synced_at"`
+	PushedAt    time.Time `json:"pushed_at"`
+}
+
+// BranchChange describes a repository's default branch switching, after
+// which commits are synced from the new branch
+type BranchChange struct {
+	Previous string `json:"previous"`
+	Current  string `json:"current"`
+}
+
+// EventRepository identifies the repository an event belongs to
+type EventRepository struct {
+	ID    int    `json:"id"`
+	Owner string `json:"owner"`
+	Name  string `json:"name"`
+}
+
+// DeadLetterStore keeps deliveries that failed every attempt
+type DeadLetterStore interface {
+	RecordDeadLetter(ctx context.Context, dl models.WebhookDeadLetter) error
+}
+
+// Options configures a Dispatcher
+type Options struct {
+	URLs        []string
+	Secret      string
+	MaxAttempts int
+	QueueSize   int
+	HTTPClient  *http.Client
+	// Backoff spaces out the attempts of a failed delivery; exponential from
+	// a second up to a minute when nil
+	Backoff backoff.Backoff
+	Clock   clock.Clock
+}
+
+// Dispatcher delivers events in the background. Notify never blocks
+// ingestion; events that cannot be queued are dead-lettered.
+type Dispatcher struct {
+	opts   Options
+	store  DeadLetterStore
+	client *http.Client
+	queue  chan Event
+}
+
+// NewDispatcher creates a dispatcher delivering to opts.URLs. Call Run to
+// start delivering.
+func NewDispatcher(store DeadLetterStore, opts Options) *Dispatcher {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultMaxAttempts
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = defaultQueueSize
+	}
+	if opts.Backoff == nil {
+		opts.Backoff = backoff.Exponential{Base: time.Second, Max: time.Minute}
+	}
+	if opts.Clock == nil {
+		opts.Clock = clock.Real
+	}
+	client := opts.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Dispatcher{
+		opts:   opts,
+		store:  store,
+		client: client,
+		queue:  make(chan Event, opts.QueueSize),
+	}
+}
+
+// NotifyCommits queues a commits.ingested event for the context's tenant
+func (d *Dispatcher) NotifyCommits(ctx context.Context, repo EventRepository, commits []models.Commit) {
+	d.enqueue(ctx, Event{
+		Type:       EventCommitsIngested,
+		TenantID:   tenant.FromContext(ctx),
+		Repository: repo,
+		// The caller may reuse its slice once this returns
+		Commits: append([]models.Commit(nil), commits...),
+	})
+}
+
+// NotifyReleases queues a releases.ingested event for the context's tenant
+func (d *Dispatcher) NotifyReleases(ctx context.Context, repo EventRepository, releases []models.Release) {
+	d.enqueue(ctx, Event{
+		Type:       EventReleasesIngested,
+		TenantID:   tenant.FromContext(ctx),
+		Repository: repo,
+		Releases:   append([]models.Release(nil), releases...),
+	})
+}
+
+// NotifyQuarantine queues a repository.quarantined event for the context's tenant
+func (d *Dispatcher) NotifyQuarantine(ctx context.Context, repo EventRepository, q Quarantine) {
+	d.enqueue(ctx, Event{
+		Type:       EventRepositoryQuarantined,
+		TenantID:   tenant.FromContext(ctx),
+		Repository: repo,
+		Quarantine: &q,
+	})
+}
+
+// NotifySyncLag queues a sync_lag.breached event for the context's tenant, or
+// sync_lag.recovered once the lag is back within the SLO
+func (d *Dispatcher) NotifySyncLag(ctx context.Context, repo EventRepository, breached bool, l SyncLag) {
+	eventType := EventSyncLagRecovered
+	if breached {
+		eventType = EventSyncLagBreached
+	}
+	d.enqueue(ctx, Event{
+		Type:       eventType,
+		TenantID:   tenant.FromContext(ctx),
+		Repository: repo,
+		SyncLag:    &l,
+	})
+}
+
+// NotifyDefaultBranch queues a repository.default_branch_changed event for
+// the context's tenant
+func (d *Dispatcher) NotifyDefaultBranch(ctx context.Context, repo EventRepository, change BranchChange) {
+	d.enqueue(ctx, Event{
+		Type:       EventDefaultBranchChanged,
+		TenantID:   tenant.FromContext(ctx),
+		Repository: repo,
+		Branch:     &change,
+	})
+}
+
+func (d *Dispatcher) enqueue(ctx context.Context, event Event) {
+	event.ID = newDeliveryID()
+	event.CreatedAt = d.opts.Clock.Now().UTC()
+
+	select {
+	case d.queue <- event:
+	default:
+		logger.Warn("Webhook queue full, dead-lettering event",
+			zap.String("event_id", event.ID),
+			zap.String("type", event.Type))
+		for _, url := range d.opts.URLs {
+			d.deadLetter(ctx, url, event, 0, fmt.Errorf("delivery queue full"))
+		}
+	}
+}
+
+// Run delivers queued events until ctx is cancelled
+func (d *Dispatcher) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-d.queue:
+			for _, url := range d.opts.URLs {
+				d.deliver(ctx, url, event)
+			}
+		}
+	}
+}
+
+// deliver posts event to url, retrying after the configured backoff, and
+// dead-letters it once every attempt has failed
+func (d *Dispatcher) deliver(ctx context.Context, url string, event Event) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		d.deadLetter(ctx, url, event, 0, fmt.Errorf("failed to encode event: %w", err))
+		return
+	}
+
+	var delay time.Duration
+	for attempt := 1; ; attempt++ {
+		err = d.post(ctx, url, event, body)
+		if err == nil {
+			logger.Info("Delivered webhook",
+				zap.String("url", url),
+				zap.String("event_id", event.ID),
+				zap.String("type", event.Type),
+				zap.Int("attempt", attempt))
+			return
+		}
+		if attempt == d.opts.MaxAttempts || ctx.Err() != nil {
+			d.deadLetter(ctx, url, event, attempt, err)
+			return
+		}
+
+		delay = d.opts.Backoff.Delay(attempt, delay)
+		logger.Warn("Webhook delivery failed, retrying",
+			zap.Error(err),
+			zap.String("url", url),
+			zap.String("event_id", event.ID),
+			zap.Int("attempt", attempt),
+			zap.Duration("backoff", delay))
+		if err := d.opts.Clock.Sleep(ctx, delay); err != nil {
+			d.deadLetter(ctx, url, event, attempt, err)
+			return
+		}
+	}
+}
+
+// post sends one signed delivery attempt
+func (d *Dispatcher) post(ctx context.Context, url string, event Event, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event.Type)
+	req.Header.Set(DeliveryHeader, event.ID)
+	if d.opts.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(d.opts.Secret, body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("consumer responded with status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// deadLetter records a delivery that will not be retried
+func (d *Dispatcher) deadLetter(ctx context.Context, url string, event Event, attempts int, cause error) {
+	payload, _ := json.Marshal(event)
+	dl := models.WebhookDeadLetter{
+		TenantID:  event.TenantID,
+		URL:       url,
+		EventID:   event.ID,
+		EventType: event.Type,
+		Payload:   payload,
+		Attempts:  attempts,
+		LastError: cause.Error(),
+	}
+	// Record even when shutdown cancelled the delivery
+	storeCtx := tenant.WithID(context.WithoutCancel(ctx), event.TenantID)
+	if err := d.store.RecordDeadLetter(storeCtx, dl); err != nil {
+		logger.Error("Failed to dead-letter webhook",
+			zap.Error(err),
+			zap.String("url", url),
+			zap.String("event_id", event.ID))
+		return
+	}
+	logger.Warn("Dead-lettered webhook",
+		zap.String("url", url),
+		zap.String("event_id", event.ID),
+		zap.Int("attempts", attempts),
+		zap.String("error", dl.LastError))
+}
+
+// Sign returns the signature header value of body: the hex HMAC-SHA256 of
+// the body keyed with secret, prefixed with "sha256=" as GitHub does
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is a valid signature of body. Consumers
+// written in Go can use it to authenticate deliveries.
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+func newDeliveryID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,174 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"githubapifetch/backoff"
+	"githubapifetch/clock"
+	"githubapifetch/models"
+	"githubapifetch/tenant"
+)
+
+// memoryStore records dead letters in memory
+type memoryStore struct {
+	mu          sync.Mutex
+	deadLetters []models.WebhookDeadLetter
+	tenants     []int
+}
+
+func (m *memoryStore) RecordDeadLetter(ctx context.Context, dl models.WebhookDeadLetter) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deadLetters = append(m.deadLetters, dl)
+	m.tenants = append(m.tenants, tenant.FromContext(ctx))
+	return nil
+}
+
+// sleepRecorder records the waits of a dispatcher instead of sleeping
+type sleepRecorder struct {
+	clock.Clock
+	sleeps []time.Duration
+}
+
+func (r *sleepRecorder) Sleep(_ context.Context, d time.Duration) error {
+	r.sleeps = append(r.sleeps, d)
+	return nil
+}
+
+func newTestDispatcher(store DeadLetterStore, opts Options) (*Dispatcher, *[]time.Duration) {
+	recorder := &sleepRecorder{Clock: clock.NewFake(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))}
+	opts.Clock = recorder
+	return NewDispatcher(store, opts), &recorder.sleeps
+}
+
+func TestDeliverSignsPayload(t *testing.T) {
+	var received Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !Verify("s3cret", body, r.Header.Get(SignatureHeader)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(t, EventCommitsIngested, r.Header.Get(EventHeader))
+		assert.NotEmpty(t, r.Header.Get(DeliveryHeader))
+		require.NoError(t, json.Unmarshal(body, &received))
+	}))
+	defer srv.Close()
+
+	store := &memoryStore{}
+	d, _ := newTestDispatcher(store, Options{URLs: []string{srv.URL}, Secret: "s3cret"})
+
+	ctx := tenant.WithID(context.Background(), 2)
+	d.NotifyCommits(ctx, EventRepository{ID: 1, Owner: "octo", Name: "hello"}, []models.Commit{{SHA: "abc123"}})
+	d.deliver(ctx, srv.URL, <-d.queue)
+
+	assert.Empty(t, store.deadLetters)
+	assert.Equal(t, 2, received.TenantID)
+	assert.Equal(t, "hello", received.Repository.Name)
+	require.Len(t, received.Commits, 1)
+	assert.Equal(t, "abc123", received.Commits[0].SHA)
+}
+
+func TestNotifyReleases(t *testing.T) {
+	d, _ := newTestDispatcher(&memoryStore{}, Options{URLs: []string{"https://hooks.example.com"}, Secret: "s3cret"})
+	published := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	releases := []models.Release{{GitHubID: 900, TagName: "v1.1.0", PublishedAt: published}}
+
+	d.NotifyReleases(tenant.WithID(context.Background(), 2), EventRepository{ID: 1, Owner: "octo", Name: "hello"}, releases)
+	releases[0].TagName = "changed"
+
+	event := <-d.queue
+	assert.Equal(t, EventReleasesIngested, event.Type)
+	assert.Equal(t, 2, event.TenantID)
+	assert.Equal(t, "hello", event.Repository.Name)
+	// The event keeps its own copy of the releases
+	require.Len(t, event.Releases, 1)
+	assert.Equal(t, "v1.1.0", event.Releases[0].TagName)
+
+	body, err := json.Marshal(event)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `"releases":[{"id":900,"tag_name":"v1.1.0"`)
+}
+
+func TestDeliverRetriesWithBackoff(t *testing.T) {
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	store := &memoryStore{}
+	d, waits := newTestDispatcher(store, Options{URLs: []string{srv.URL}, Secret: "s3cret"})
+	d.deliver(context.Background(), srv.URL, Event{ID: "1", Type: EventCommitsIngested})
+
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, *waits)
+	assert.Empty(t, store.deadLetters)
+}
+
+func TestDeliverUsesConfiguredBackoff(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	store := &memoryStore{}
+	d, waits := newTestDispatcher(store, Options{URLs: []string{srv.URL}, MaxAttempts: 3, Backoff: backoff.Constant{Base: 5 * time.Second}})
+	d.deliver(context.Background(), srv.URL, Event{ID: "1", Type: EventCommitsIngested})
+
+	assert.Equal(t, []time.Duration{5 * time.Second, 5 * time.Second}, *waits)
+	assert.Len(t, store.deadLetters, 1)
+}
+
+func TestDeliverDeadLettersAfterMaxAttempts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	store := &memoryStore{}
+	d, _ := newTestDispatcher(store, Options{URLs: []string{srv.URL}, Secret: "s3cret", MaxAttempts: 4})
+	d.deliver(context.Background(), srv.URL, Event{ID: "1", Type: EventCommitsIngested, TenantID: 3})
+
+	require.Len(t, store.deadLetters, 1)
+	dl := store.deadLetters[0]
+	assert.Equal(t, srv.URL, dl.URL)
+	assert.Equal(t, "1", dl.EventID)
+	assert.Equal(t, 4, dl.Attempts)
+	assert.Contains(t, dl.LastError, "status code 500")
+	assert.Equal(t, []int{3}, store.tenants)
+}
+
+func TestNotifyDeadLettersWhenQueueFull(t *testing.T) {
+	store := &memoryStore{}
+	d, _ := newTestDispatcher(store, Options{URLs: []string{"http://a.example", "http://b.example"}, Secret: "s3cret", QueueSize: 1})
+
+	repo := EventRepository{ID: 1, Owner: "octo", Name: "hello"}
+	d.NotifyCommits(context.Background(), repo, nil)
+	d.NotifyCommits(context.Background(), repo, nil)
+
+	assert.Len(t, d.queue, 1)
+	require.Len(t, store.deadLetters, 2)
+	assert.Equal(t, 0, store.deadLetters[0].Attempts)
+}
+
+func TestSign(t *testing.T) {
+	// Matches the example in GitHub's webhook validation documentation
+	assert.Equal(t,
+		"sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17",
+		Sign("It's a Secret to Everybody", []byte("Hello, World!")))
+	assert.False(t, Verify("other", []byte("Hello, World!"), Sign("It's a Secret to Everybody", []byte("Hello, World!"))))
+}